@@ -62,6 +62,7 @@ const (
 	//
 	NotPermError          FsError = FsError(int(unix.EPERM))        // Operation not permitted
 	NotFoundError         FsError = FsError(int(unix.ENOENT))       // No such file or directory
+	InterruptedError      FsError = FsError(int(unix.EINTR))        // Interrupted system call
 	IOError               FsError = FsError(int(unix.EIO))          // I/O error
 	TooBigError           FsError = FsError(int(unix.E2BIG))        // Argument list too long
 	TooManyArgsError      FsError = FsError(int(unix.E2BIG))        // Arg list too long
@@ -72,6 +73,7 @@ const (
 	BadAddressError       FsError = FsError(int(unix.EFAULT))       // Bad address
 	DevBusyError          FsError = FsError(int(unix.EBUSY))        // Device or resource busy
 	FileExistsError       FsError = FsError(int(unix.EEXIST))       // File exists
+	CrossDeviceError      FsError = FsError(int(unix.EXDEV))        // Cross-device link
 	NoDeviceError         FsError = FsError(int(unix.ENODEV))       // No such device
 	NotDirError           FsError = FsError(int(unix.ENOTDIR))      // Not a directory
 	IsDirError            FsError = FsError(int(unix.EISDIR))       // Is a directory
@@ -92,6 +94,7 @@ const (
 	NotSupportedError     FsError = FsError(int(unix.ENOTSUP))      // Operation not supported
 	NoDataError           FsError = FsError(int(unix.ENODATA))      // No data available
 	TimedOut              FsError = FsError(int(unix.ETIMEDOUT))    // Connection Timed Out
+	QuotaExceededError    FsError = FsError(int(unix.EDQUOT))       // Quota exceeded
 )
 
 // Errors that map to constants already defined above
@@ -120,6 +123,7 @@ const (
 	InvalidFileModeError  FsError = InvalidArgError
 	InvalidUserIDError    FsError = InvalidArgError
 	InvalidGroupIDError   FsError = InvalidArgError
+	JournalTrimmedError   FsError = OutOfRangeError
 	StreamNotFound        FsError = NoDataError
 	AccountNotModifiable  FsError = NotPermError
 	OldMetaDataDifferent  FsError = TryAgainError
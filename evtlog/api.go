@@ -55,6 +55,10 @@ const (
 	FormatHeadhunterRecordTransactionDeleteLogSegmentRec
 	FormatHeadhunterRecordTransactionPutBPlusTreeObject
 	FormatHeadhunterRecordTransactionDeleteBPlusTreeObject
+	FormatHeadhunterRecordTransactionPutJournalRec
+	FormatHeadhunterRecordTransactionTrimJournalRecs
+	FormatHeadhunterRecordTransactionPutFingerprintRec
+	FormatHeadhunterRecordTransactionDeleteFingerprintRec
 	FormatHeadhunterMissingInodeRec
 	FormatHeadhunterMissingLogSegmentRec
 	FormatHeadhunterMissingBPlusTreeObject
@@ -300,6 +304,22 @@ var (
 			patternType:  patternS016X,
 			formatString: "%s Headhunter recording DeleteBPlusTreeObject for Volume '%s' Virtual Object# 0x%016X",
 		},
+		eventType{ // FormatHeadhunterRecordTransactionPutJournalRec
+			patternType:  patternS016X,
+			formatString: "%s Headhunter recording PutJournalRec for Volume '%s' Journal# 0x%016X",
+		},
+		eventType{ // FormatHeadhunterRecordTransactionTrimJournalRecs
+			patternType:  patternS016X,
+			formatString: "%s Headhunter recording TrimJournalRecs for Volume '%s' thru Journal# 0x%016X",
+		},
+		eventType{ // FormatHeadhunterRecordTransactionPutFingerprintRec
+			patternType:  patternS016X,
+			formatString: "%s Headhunter recording PutFingerprintRec for Volume '%s' Fingerprint# 0x%016X",
+		},
+		eventType{ // FormatHeadhunterRecordTransactionDeleteFingerprintRec
+			patternType:  patternS016X,
+			formatString: "%s Headhunter recording DeleteFingerprintRec for Volume '%s' Fingerprint# 0x%016X",
+		},
 		eventType{ // FormatHeadhunterMissingInodeRec
 			patternType:  patternS016X,
 			formatString: "%s Headhunter recording DeleteBPlusTreeObject for Volume '%s' Inode# 0x%016X",
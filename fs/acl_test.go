@@ -0,0 +1,115 @@
+package fs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestPosixACL(t *testing.T) {
+	var (
+		aclUserID    = inode.InodeUserID(1000)
+		aclGroupID   = inode.InodeGroupID(1000)
+		otherUserID  = inode.InodeUserID(2000)
+		otherGroupID = inode.InodeGroupID(2000)
+	)
+
+	defaultACL := inode.PackACL([]inode.ACLEntry{
+		{Tag: inode.ACLTagUserObj, Perm: uint16(inode.R_OK | inode.W_OK | inode.X_OK), ID: inode.ACLUndefinedID},
+		{Tag: inode.ACLTagUser, Perm: uint16(inode.R_OK | inode.W_OK), ID: uint32(aclUserID)},
+		{Tag: inode.ACLTagGroupObj, Perm: uint16(inode.R_OK | inode.X_OK), ID: inode.ACLUndefinedID},
+		{Tag: inode.ACLTagMask, Perm: uint16(inode.R_OK | inode.W_OK | inode.X_OK), ID: inode.ACLUndefinedID},
+		{Tag: inode.ACLTagOther, Perm: 0, ID: inode.ACLUndefinedID},
+	})
+
+	dirInodeNumber := createTestDirectory(t, "PosixACL")
+
+	err := mS.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, inode.DefaultACLStreamName, []byte{0x00, 0x01}, 0)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("SetXAttr() of malformed ACL should have failed with InvalidArgError: %v", err)
+	}
+
+	err = mS.SetXAttr(aclUserID, aclGroupID, nil, dirInodeNumber, inode.DefaultACLStreamName, defaultACL, 0)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("SetXAttr() of ACL by non-owner should have failed with NotPermError: %v", err)
+	}
+
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, inode.DefaultACLStreamName, defaultACL, 0)
+	if nil != err {
+		t.Fatalf("SetXAttr() of default ACL failed: %v", err)
+	}
+
+	// A file created in the directory inherits the default ACL as its access ACL (limited by the creation mode)
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "ACLFile", inode.InodeMode(0660))
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, inode.AccessACLStreamName)
+	if nil != err {
+		t.Fatalf("GetXAttr() of inherited access ACL failed: %v", err)
+	}
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, inode.DefaultACLStreamName)
+	if nil == err {
+		t.Fatalf("GetXAttr() of default ACL on a file should have failed")
+	}
+
+	stat, err := mS.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Getstat() failed: %v", err)
+	}
+	if uint64(0660) != (stat[StatMode] & uint64(inode.PosixModePerm)) {
+		t.Fatalf("Create() of file with inherited ACL resulted in mode %04o (expected 0660)", stat[StatMode]&uint64(inode.PosixModePerm))
+	}
+
+	if !mS.Access(aclUserID, aclGroupID, nil, fileInodeNumber, inode.R_OK|inode.W_OK) {
+		t.Fatalf("Access() for named user in ACL should have been granted")
+	}
+	if mS.Access(otherUserID, otherGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access() for user not in ACL should have been denied")
+	}
+
+	// Lowering the group mode bits (i.e. the mask) limits the named user's access
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+	if mS.Access(aclUserID, aclGroupID, nil, fileInodeNumber, inode.R_OK) {
+		t.Fatalf("Access() for named user in ACL should have been denied by mask")
+	}
+
+	// A subdirectory inherits the default ACL both as its default ACL and (limited) as its access ACL
+
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "ACLSubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+
+	subDirDefaultACL, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, inode.DefaultACLStreamName)
+	if nil != err {
+		t.Fatalf("GetXAttr() of inherited default ACL failed: %v", err)
+	}
+	if 0 != bytes.Compare(defaultACL, subDirDefaultACL) {
+		t.Fatalf("GetXAttr() of inherited default ACL returned %v (expected %v)", subDirDefaultACL, defaultACL)
+	}
+	if !mS.Access(aclUserID, aclGroupID, nil, subDirInodeNumber, inode.R_OK|inode.W_OK) {
+		t.Fatalf("Access() for named user in inherited ACL should have been granted")
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "ACLSubDir")
+	if nil != err {
+		t.Fatalf("Rmdir() [subdirectory] failed: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "ACLFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "PosixACL")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
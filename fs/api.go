@@ -5,6 +5,10 @@ package fs
 import "C"
 
 import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/headhunter"
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/utils"
@@ -50,6 +54,28 @@ type HeadResponse struct {
 	NumWrites        uint64
 }
 
+// DefragJobState describes the state of a volume's background defragmentation daemon
+type DefragJobState uint8
+
+const (
+	DefragJobDisabled DefragJobState = iota // Volume has no DefragInterval configured
+	DefragJobIdle                           // Waiting for the next pass (or for quiet hours)
+	DefragJobRunning                        // Scanning for and optimizing fragmented FileInodes
+	DefragJobPaused                         // Paused via PauseDefragJob() until ResumeDefragJob()
+)
+
+// DefragJobStatus is returned by FetchDefragJobStatus
+type DefragJobStatus struct {
+	State              DefragJobState
+	PassesCompleted    uint64
+	PassStartTime      time.Time         // Start of the current (or most recent) pass
+	PassEndTime        time.Time         // End of the most recent pass (if it has completed)
+	InodesScanned      uint64            // FileInodes examined in the current (or most recent) pass
+	InodesOptimized    uint64            // FileInodes passed to Optimize() in the current (or most recent) pass
+	BytesOptimized     uint64            // Bytes rewritten by Optimize() in the current (or most recent) pass
+	CurrentInodeNumber inode.InodeNumber // FileInode currently being optimized (or zero if none)
+}
+
 // The following constants are used to ensure that the length of file fullpath and basenames are POSIX-compliant
 const (
 	FilePathMax = C.PATH_MAX
@@ -62,6 +88,12 @@ const MaxSymlinks = 8 // same as Linux; see include/linux/namei.h in Linux's Git
 // Constant defining the name of the alternate data stream used by Swift Middleware
 const MiddlewareStream = "middleware"
 
+// Constant defining the name of the alternate data stream used to persist POSIX byte-range locks
+const FlockStream = "proxyfs.flock"
+
+// Constant defining the name of the alternate data stream recording the name of a trashed inode's trash entry
+const TrashEntryStream = "proxyfs.trash"
+
 // Byte prefix constants
 const (
 	KiloByte = 1024
@@ -76,23 +108,147 @@ const (
 	FsOptimalTransferSize = 64 * KiloByte
 )
 
-// XXX TBD: For now we are cooking up these numbers...
+// The following defaults are used when responding to StatVfs calls for volumes not specifying VolumeSize or MaxInodes
+//
+// Note that StatVfs reports as used the file data bytes each file references (see inode/accounting.go). Data shared
+// between files (via CloneRange or deduplication) is counted once per file and compressed data at its decompressed size.
 const (
-	VolFakeTotalBlocks = TeraByte / FsBlockSize
-	VolFakeFreeBlocks  = TeraByte / FsBlockSize
-	VolFakeAvailBlocks = TeraByte / FsBlockSize
-
-	VolFakeTotalInodes = TeraByte
-	VolFakeFreeInodes  = TeraByte
-	VolFakeAvailInodes = TeraByte
+	VolumeSizeDefault = TeraByte
+	MaxInodesDefault  = TeraByte
 )
 
+// FlockStruct describes a POSIX byte-range lock
+//
+// A lock is owned by the triple (MountID, ClientID, Pid). MountID is filled in by Flock() from the
+// MountHandle used. ClientID identifies the client (e.g. its connection) via which Pid obtained the
+// lock (so that processes on different clients sharing a Pid are told apart) and is what ReleaseFlocks()
+// matches. ClientHost, if set, identifies the host on which Pid is running. A MountID of zero marks a
+// lock recovered from a prior instance of the volume (see FlockPersistence) that has yet to be reclaimed
+// by its owner... matched by ClientHost (or, if not set, ClientID) and Pid.
 type FlockStruct struct {
-	Type   int32
-	Whence int32
-	Start  uint64
-	Len    uint64
-	Pid    uint64
+	Type       int32
+	Whence     int32
+	Start      uint64
+	Len        uint64
+	Pid        uint64
+	ClientID   string
+	ClientHost string
+	MountID    MountID
+}
+
+// InodeLeaseType specifies the kind of lease (or delegation) held on an inode
+type InodeLeaseType uint32
+
+const (
+	InodeLeaseNone InodeLeaseType = iota
+	InodeLeaseRead
+	InodeLeaseWrite
+)
+
+// InodeLeaseRecallStruct asks a lease holder to flush any dirty state for InodeNumber and release its lease
+type InodeLeaseRecallStruct struct {
+	InodeNumber inode.InodeNumber
+	LeaseType   InodeLeaseType   // Lease being recalled
+	Reason      dlm.NotifyReason // Kind of conflicting access requested
+}
+
+// WatchID identifies a watch (on a directory inode) of a volume
+type WatchID uint64
+
+// WatchEventType specifies the kind of change reported by a WatchEventStruct
+type WatchEventType uint32
+
+const (
+	WatchEventCreate     WatchEventType = iota + 1 // Basename was created (or hard linked) in the directory
+	WatchEventUnlink                               // Basename was removed from the directory
+	WatchEventRenameFrom                           // Basename was renamed away from the directory
+	WatchEventRenameTo                             // Basename was renamed into the directory
+	WatchEventSetattr                              // Attributes (or xattrs) of Basename (or, if "", the directory itself) changed
+	WatchEventWriteClose                           // Basename, having been written, was flushed
+	WatchEventOverflow                             // The watch's queue filled... events have been lost
+)
+
+// WatchEventStruct reports a change to (an entry of) a watched directory. The RenameFrom and RenameTo
+// events of a single Rename() share the same (non-zero) Cookie.
+type WatchEventStruct struct {
+	Type           WatchEventType
+	DirInodeNumber inode.InodeNumber
+	Basename       string
+	InodeNumber    inode.InodeNumber
+	Cookie         uint64
+}
+
+// JournalOp specifies the kind of change recorded by a JournalRecStruct
+type JournalOp uint32
+
+const (
+	JournalOpCreate     JournalOp = iota + 1 // Basename was created (or hard linked) in ParentInodeNumber
+	JournalOpUnlink                          // Basename was removed from ParentInodeNumber
+	JournalOpRenameFrom                      // Basename was renamed away from ParentInodeNumber
+	JournalOpRenameTo                        // Basename was renamed into ParentInodeNumber
+	JournalOpSetattr                         // Attributes (or xattrs) of InodeNumber changed
+	JournalOpWrite                           // Data of InodeNumber was written (or its size changed)
+)
+
+// JournalRecStruct is a record of a volume's change journal. The JournalOpRenameFrom and JournalOpRenameTo
+// records of a single Rename() are adjacent. ParentInodeNumber and Basename are only set for those ops naming
+// a directory entry.
+type JournalRecStruct struct {
+	Nonce             uint64
+	Op                JournalOp
+	InodeNumber       inode.InodeNumber
+	ParentInodeNumber inode.InodeNumber
+	Basename          string
+	Time              time.Time
+}
+
+// QuotaType specifies what a quota limits the usage of
+type QuotaType uint32
+
+const (
+	QuotaTypeUser  QuotaType = iota + 1 // Inodes owned by UserID ID
+	QuotaTypeGroup                      // Inodes owned by GroupID ID
+	QuotaTypeTree                       // Inodes created beneath the directory at Path (whose InodeNumber is ID)
+)
+
+// GetXAttr() of these virtual attributes on the directory of a QuotaTypeTree quota (a "project") returns
+// its total bytes and inodes (as of the last flush) in decimal
+const (
+	ProjectBytesXAttrName  = "proxyfs.project.bytes"
+	ProjectInodesXAttrName = "proxyfs.project.inodes"
+)
+
+// QuotaStatusStruct reports the limits and usage of a quota. A limit of zero is not enforced. Should usage
+// exceed a soft limit, the corresponding GraceExpiration indicates when the soft limit becomes enforced.
+type QuotaStatusStruct struct {
+	Name                  string
+	Type                  QuotaType
+	ID                    uint64
+	Path                  string
+	UsedBytes             uint64
+	UsedInodes            uint64
+	SoftBytes             uint64
+	HardBytes             uint64
+	SoftInodes            uint64
+	HardInodes            uint64
+	GracePeriod           time.Duration
+	BytesGraceExpiration  time.Time // Zero unless UsedBytes exceeds SoftBytes
+	InodesGraceExpiration time.Time // Zero unless UsedInodes exceeds SoftInodes
+}
+
+// TrashDirName is the name of the directory (in the root of a volume with TrashRetention) holding removed inodes
+const TrashDirName = ".__trash__"
+
+// TrashEntryStruct describes an inode moved to a volume's trash by Unlink() or Rmdir(). Name identifies the entry
+// to RestoreTrash(). ParentInodeNumber & Basename record where the inode was removed from (and will be restored to).
+type TrashEntryStruct struct {
+	Name              string
+	InodeNumber       inode.InodeNumber
+	InodeType         inode.InodeType
+	ParentInodeNumber inode.InodeNumber
+	Basename          string
+	DeletionTime      time.Time
+	ExpirationTime    time.Time
 }
 
 type MountOptions uint64
@@ -101,6 +257,11 @@ const (
 	MountReadOnly MountOptions = 1 << iota
 )
 
+// SnapshotDirName is the (virtual) directory under which a volume's snapshots may be mounted. That is,
+// passing a volumeName of "<volumeName>/.snapshot/<snapshotName>" (along with MountReadOnly) to Mount()
+// will mount the named snapshot.
+const SnapshotDirName = ".snapshot"
+
 type StatKey uint64
 
 const (
@@ -151,9 +312,15 @@ func Mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandl
 }
 
 type MountHandle interface {
+	AcquireInodeLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, leaseType InodeLeaseType) (err error)
 	Access(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (accessReturn bool)
+	AddWatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber) (watchID WatchID, err error)
+	BreakInodeLeases(inodeNumbers []inode.InodeNumber, reason dlm.NotifyReason) (leaseBreak *InodeLeaseBreakStruct)
 	CallInodeToProvisionObject() (pPath string, err error)
+	CloneRange(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcInodeNumber inode.InodeNumber, srcOffset uint64, dstInodeNumber inode.InodeNumber, dstOffset uint64, length uint64) (clonedLength uint64, err error)
 	Create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error)
+	FetchInodeLeaseRecalls(timeout time.Duration) (recalls []InodeLeaseRecallStruct, err error)
+	FetchWatchEvents(watchID WatchID, timeout time.Duration) (events []WatchEventStruct, err error)
 	Flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error)
 	Flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlockStruct *FlockStruct) (outFlockStruct *FlockStruct, err error)
 	Getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error)
@@ -170,13 +337,17 @@ type MountHandle interface {
 	MiddlewareDelete(parentDir string, baseName string) (err error)
 	MiddlewareGetAccount(maxEntries uint64, marker string) (accountEnts []AccountEntry, mtime uint64, err error)
 	MiddlewareGetContainer(vContainerName string, maxEntries uint64, marker string, prefix string) (containerEnts []ContainerEntry, err error)
-	MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, err error)
+	MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, leaseID string, err error)
 	MiddlewareHeadResponse(entityPath string) (response HeadResponse, err error)
 	MiddlewareMkdir(vContainerName string, vObjectPath string, metadata []byte) (mtime uint64, inodeNumber inode.InodeNumber, numWrites uint64, err error)
 	MiddlewarePost(parentDir string, baseName string, newMetaData []byte, oldMetaData []byte) (err error)
 	MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error)
 	MiddlewarePutContainer(containerName string, oldMetadata []byte, newMetadata []byte) (err error)
 	Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (newDirInodeNumber inode.InodeNumber, err error)
+	ReleaseFlocks(clientID string) (err error)
+	ReleaseInodeLease(inodeNumber inode.InodeNumber) (err error)
+	ReleaseInodeLeases() (err error)
+	RemoveWatch(watchID WatchID) (err error)
 	RemoveXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (err error)
 	Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error)
 	Read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error)
@@ -192,6 +363,7 @@ type MountHandle interface {
 	StatVfs() (statVFS StatVFS, err error)
 	Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error)
 	Unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error)
+	Unmount() (err error)
 	VolumeName() (volumeName string)
 	Write(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error)
 }
@@ -213,6 +385,98 @@ func ValidateVolume(volumeName string, stopChan chan bool, errChan chan error) {
 	errChan <- validateVolume(volumeName, stopChan)
 }
 
+func GetFragmentationReport(volumeName string, inodeNumber inode.InodeNumber) (fragmentationReport inode.FragmentationReport, err error) {
+	fragmentationReport, err = getFragmentationReport(volumeName, inodeNumber)
+	stats.IncrementOperations(&stats.FsFragmentationReportOps)
+	return
+}
+
+func FetchDefragJobStatus(volumeName string) (defragJobStatus DefragJobStatus, err error) {
+	defragJobStatus, err = fetchDefragJobStatus(volumeName)
+	stats.IncrementOperations(&stats.FsDefragJobStatusOps)
+	return
+}
+
+func PauseDefragJob(volumeName string) (err error) {
+	err = pauseDefragJob(volumeName)
+	stats.IncrementOperations(&stats.FsDefragJobPauseOps)
+	return
+}
+
+func ResumeDefragJob(volumeName string) (err error) {
+	err = resumeDefragJob(volumeName)
+	stats.IncrementOperations(&stats.FsDefragJobResumeOps)
+	return
+}
+
+// CreateSnapshot flushes any in-flight file data of the volume and then creates a snapshot of it.
+func CreateSnapshot(volumeName string, snapshotName string) (snapshot headhunter.SnapshotStruct, err error) {
+	snapshot, err = createSnapshot(volumeName, snapshotName)
+	stats.IncrementOperations(&stats.FsSnapshotCreateOps)
+	return
+}
+
+// DeleteSnapshot deletes the named snapshot of the volume. Any current mounts of it become unusable.
+func DeleteSnapshot(volumeName string, snapshotName string) (err error) {
+	err = deleteSnapshot(volumeName, snapshotName)
+	stats.IncrementOperations(&stats.FsSnapshotDeleteOps)
+	return
+}
+
+func FetchSnapshotList(volumeName string) (snapshotList []headhunter.SnapshotStruct, err error) {
+	snapshotList, err = fetchSnapshotList(volumeName)
+	return
+}
+
+// AddVolumeWatch adds a watch on dirInodeNumber (that userID must be able to read) not tied to any MountHandle
+// (e.g. for httpserver). Such a watch is discarded if not polled via FetchVolumeWatchEvents() for VolumeWatchIdleTimeout.
+func AddVolumeWatch(volumeName string, userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber) (watchID WatchID, err error) {
+	watchID, err = addVolumeWatch(volumeName, userID, groupID, otherGroupIDs, dirInodeNumber)
+	stats.IncrementOperations(&stats.FsWatchAddOps)
+	return
+}
+
+func RemoveVolumeWatch(volumeName string, watchID WatchID) (err error) {
+	err = removeVolumeWatch(volumeName, watchID)
+	stats.IncrementOperations(&stats.FsWatchRemoveOps)
+	return
+}
+
+func FetchVolumeWatchEvents(volumeName string, watchID WatchID, timeout time.Duration) (events []WatchEventStruct, err error) {
+	events, err = fetchVolumeWatchEvents(volumeName, watchID, timeout)
+	return
+}
+
+// FetchJournal returns up to maxRecs records of the volume's change journal following those returned by the
+// call that returned cursor (or, if cursor == "", the oldest retained) along with the cursor to pass next time.
+// If records following cursor have been trimmed, a blunder.JournalTrimmedError is returned.
+func FetchJournal(volumeName string, cursor string, maxRecs uint64) (journalRecs []JournalRecStruct, nextCursor string, err error) {
+	journalRecs, nextCursor, err = fetchJournal(volumeName, cursor, maxRecs)
+	stats.IncrementOperations(&stats.FsJournalFetchOps)
+	return
+}
+
+// FetchQuotaReport returns the limits and usage of each of the volume's quotas
+func FetchQuotaReport(volumeName string) (quotaReport []QuotaStatusStruct, err error) {
+	quotaReport, err = fetchQuotaReport(volumeName)
+	stats.IncrementOperations(&stats.FsQuotaReportOps)
+	return
+}
+
+// FetchTrash returns the entries (oldest first) in the trash of a volume
+func FetchTrash(volumeName string) (trashEntries []TrashEntryStruct, err error) {
+	trashEntries, err = fetchTrash(volumeName)
+	stats.IncrementOperations(&stats.FsTrashFetchOps)
+	return
+}
+
+// RestoreTrash moves the named trash entry back to where it was removed from
+func RestoreTrash(volumeName string, name string) (err error) {
+	err = restoreTrash(volumeName, name)
+	stats.IncrementOperations(&stats.FsTrashRestoreOps)
+	return
+}
+
 func AccountNameToVolumeName(accountName string) (volumeName string, ok bool) {
 	volumeName, ok = inode.AccountNameToVolumeName(accountName)
 	stats.IncrementOperations(&stats.FsAcctToVolumeOps)
@@ -224,3 +488,17 @@ func VolumeNameToActivePeerPrivateIPAddr(volumeName string) (activePeerPrivateIP
 	stats.IncrementOperations(&stats.FsVolumeToActivePeerOps)
 	return
 }
+
+// MiddlewareRenewLease extends the lifetime of the LogSegment lease returned by MiddlewareGetObject().
+func MiddlewareRenewLease(leaseID string) (err error) {
+	err = inode.RenewLogSegmentLease(leaseID)
+	stats.IncrementOperations(&stats.FsMwRenewLeaseOps)
+	return
+}
+
+// MiddlewareReleaseLease drops the LogSegment lease returned by MiddlewareGetObject().
+func MiddlewareReleaseLease(leaseID string) (err error) {
+	err = inode.ReleaseLogSegmentLease(leaseID)
+	stats.IncrementOperations(&stats.FsMwReleaseLeaseOps)
+	return
+}
@@ -105,6 +105,7 @@ func (vS *volumeStruct) untrackInFlightFileInodeData(inodeNumber inode.InodeNumb
 	if flushFirst {
 		inFlightFileInodeData.wg.Wait()
 	}
+	vS.quotaDropUnflushed(inodeNumber)
 }
 
 // untrackInFlightFileInodeDataAll is called to flush all current elements
@@ -163,6 +164,7 @@ func (vS *volumeStruct) inFlightFileInodeDataFlusher(inodeNumber inode.InodeNumb
 			logger.ErrorfWithError(err, "Flush of file data failed on volume '%s' inode %d", vS.volumeName, inodeNumber)
 		}
 	}
+	vS.quotaDropUnflushed(inodeNumber)
 
 	err = inodeLock.Unlock()
 	if nil != err {
@@ -216,11 +218,23 @@ func chunkedPutConnectionPoolStarvationCallback() {
 
 func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandle, err error) {
 	var (
-		mS        *mountStruct
-		ok        bool
-		volStruct *volumeStruct
+		mS           *mountStruct
+		ok           bool
+		snapshotName string
+		volStruct    *volumeStruct
 	)
 
+	volumeName, snapshotName, err = parseMountVolumeName(volumeName)
+	if nil != err {
+		return
+	}
+
+	if ("" != snapshotName) && (0 == (mountOptions & MountReadOnly)) {
+		err = fmt.Errorf("Snapshot \"%s\" of volume \"%s\" may only be mounted with MountReadOnly", snapshotName, volumeName)
+		err = blunder.AddError(err, blunder.ReadOnlyError)
+		return
+	}
+
 	globals.Lock()
 
 	volStruct, ok = globals.volumeMap[volumeName]
@@ -231,6 +245,14 @@ func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandl
 		return
 	}
 
+	if "" != snapshotName {
+		volStruct, err = volStruct.fetchSnapshotVolumeWhileLocked(snapshotName)
+		if nil != err {
+			globals.Unlock()
+			return
+		}
+	}
+
 	globals.lastMountID++
 
 	mS = &mountStruct{
@@ -253,6 +275,63 @@ func mount(volumeName string, mountOptions MountOptions) (mountHandle MountHandl
 	return
 }
 
+// Unmount releases the locks and inode leases held via the mount and forgets it. The MountHandle must not be used afterwards.
+func (mS *mountStruct) Unmount() (err error) {
+	var (
+		mountListIndex int
+		ok             bool
+	)
+
+	err = mS.ReleaseFlocks("")
+	if nil != err {
+		return
+	}
+
+	err = mS.ReleaseInodeLeases()
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.removeMountWatches(mS.id)
+
+	globals.Lock()
+
+	_, ok = globals.mountMap[mS.id]
+	if !ok {
+		globals.Unlock()
+		err = fmt.Errorf("MountID %v of volume \"%s\" already unmounted", mS.id, mS.volStruct.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	delete(globals.mountMap, mS.id)
+
+	mS.volStruct.dataMutex.Lock()
+	for mountListIndex = range mS.volStruct.mountList {
+		if mS.id == mS.volStruct.mountList[mountListIndex] {
+			mS.volStruct.mountList = append(mS.volStruct.mountList[:mountListIndex], mS.volStruct.mountList[mountListIndex+1:]...)
+			break
+		}
+	}
+	mS.volStruct.dataMutex.Unlock()
+
+	globals.Unlock()
+
+	err = nil
+	return
+}
+
+// checkMountWritable returns a blunder.ReadOnlyError if the mount (e.g. that of a snapshot) is read-only
+func (mS *mountStruct) checkMountWritable() (err error) {
+	if 0 != (mS.options & MountReadOnly) {
+		err = blunder.NewError(blunder.ReadOnlyError, "EROFS")
+		return
+	}
+
+	err = nil
+	return
+}
+
 func (mS *mountStruct) Access(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, accessMode inode.InodeMode) (accessReturn bool) {
 
 	mS.volStruct.validateVolumeRWMutex.RLock()
@@ -264,6 +343,11 @@ func (mS *mountStruct) Access(userID inode.InodeUserID, groupID inode.InodeGroup
 }
 
 func (mS *mountStruct) CallInodeToProvisionObject() (pPath string, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -272,7 +356,91 @@ func (mS *mountStruct) CallInodeToProvisionObject() (pPath string, err error) {
 	return
 }
 
+func (mS *mountStruct) CloneRange(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcInodeNumber inode.InodeNumber, srcOffset uint64, dstInodeNumber inode.InodeNumber, dstOffset uint64, length uint64) (clonedLength uint64, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(dstInodeNumber, dlm.ReasonWriteRequest)
+
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	callerID := dlm.GenerateCallerID()
+
+	dstInodeLock, err := mS.volStruct.initInodeLock(dstInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	srcInodeLock, err := mS.volStruct.initInodeLock(srcInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+
+retryLock:
+	err = dstInodeLock.WriteLock()
+	if nil != err {
+		return
+	}
+	// Try to get the source file's lock. If we can't get it, drop the
+	// destination file's lock and try the whole thing again.
+	if srcInodeNumber != dstInodeNumber {
+		err = srcInodeLock.TryReadLock()
+		if blunder.Is(err, blunder.TryAgainError) {
+			dstInodeLock.Unlock()
+			goto retryLock
+		} else if blunder.IsNotSuccess(err) {
+			dstInodeLock.Unlock()
+			return
+		}
+		defer srcInodeLock.Unlock()
+	}
+	defer dstInodeLock.Unlock()
+
+	if !mS.volStruct.VolumeHandle.Access(srcInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(srcInodeNumber, userID, groupID, otherGroupIDs, inode.R_OK,
+		inode.OwnerOverride) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(dstInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK,
+		inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !mS.volStruct.VolumeHandle.Access(dstInodeNumber, userID, groupID, otherGroupIDs, inode.W_OK,
+		inode.OwnerOverride) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	err = mS.volStruct.quotaCheckInode(dstInodeNumber, dstOffset+length)
+	if nil != err {
+		return
+	}
+
+	clonedLength, err = mS.volStruct.VolumeHandle.CloneRange(srcInodeNumber, srcOffset, dstInodeNumber, dstOffset, length)
+	if nil != err {
+		return
+	}
+
+	mS.volStruct.watchNoteWrite(dstInodeNumber)
+	mS.volStruct.journalAppend(JournalOpWrite, inode.InodeNumber(0), "", dstInodeNumber)
+	stats.IncrementOperations(&stats.FsCloneRangeOps)
+	return
+}
+
 func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (fileInodeNumber inode.InodeNumber, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -301,12 +469,35 @@ func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroup
 		return 0, blunder.NewError(blunder.PermDeniedError, "EACCES")
 	}
 
+	err = mS.volStruct.quotaCheckCreate(userID, groupID, dirInodeNumber, 0, 1)
+	if err != nil {
+		return 0, err
+	}
+
 	// create the file and add it to the directory
 	fileInodeNumber, err = mS.volStruct.VolumeHandle.CreateFile(filePerm, userID, groupID)
 	if err != nil {
 		return 0, err
 	}
 
+	err = mS.volStruct.VolumeHandle.InheritACL(dirInodeNumber, fileInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed InheritACL() in fs.Create", fileInodeNumber)
+		}
+		return 0, err
+	}
+
+	err = mS.volStruct.VolumeHandle.InheritQuotaTree(dirInodeNumber, fileInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed InheritQuotaTree() in fs.Create", fileInodeNumber)
+		}
+		return 0, err
+	}
+
 	err = mS.volStruct.VolumeHandle.Link(dirInodeNumber, basename, fileInodeNumber)
 	if err != nil {
 		destroyErr := mS.volStruct.VolumeHandle.Destroy(fileInodeNumber)
@@ -316,6 +507,9 @@ func (mS *mountStruct) Create(userID inode.InodeUserID, groupID inode.InodeGroup
 		return 0, err
 	}
 
+	mS.volStruct.watchNotifyEntry(WatchEventCreate, dirInodeNumber, basename, fileInodeNumber)
+	mS.volStruct.journalAppend(JournalOpCreate, dirInodeNumber, basename, fileInodeNumber)
+
 	stats.IncrementOperations(&stats.FsCreateOps)
 	return fileInodeNumber, nil
 }
@@ -343,6 +537,12 @@ func (mS *mountStruct) doInlineCheckpointIfEnabled() {
 }
 
 func (mS *mountStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (err error) {
+	if "" != mS.volStruct.snapshotName {
+		// Nothing in a snapshot could have been modified
+		err = nil
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -370,27 +570,18 @@ func (mS *mountStruct) Flush(userID inode.InodeUserID, groupID inode.InodeGroupI
 	err = mS.volStruct.VolumeHandle.Flush(inodeNumber, false)
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
 
-	stats.IncrementOperations(&stats.FsFlushOps)
-	return
-}
-
-func (mS *mountStruct) getFileLockList(inodeNumber inode.InodeNumber) (flockList *list.List) {
-	mS.volStruct.dataMutex.Lock()
-	defer mS.volStruct.dataMutex.Unlock()
-
-	flockList, ok := mS.volStruct.FLockMap[inodeNumber]
-	if !ok {
-		flockList = new(list.List)
-		mS.volStruct.FLockMap[inodeNumber] = flockList
+	if nil == err {
+		mS.volStruct.watchNotifyWriteClose(inodeNumber)
 	}
 
+	stats.IncrementOperations(&stats.FsFlushOps)
 	return
 }
 
-// Check for lock conflict with other Pids, if there is a conflict then it will return the first occurance of conflicting range.
+// Check for lock conflict with other owners, if there is a conflict then it will return the first occurance of conflicting range.
 func checkConflict(elm *FlockStruct, flock *FlockStruct) bool {
 
-	if flock.Pid == elm.Pid {
+	if sameFlockOwner(elm, flock) {
 		return false
 	}
 
@@ -405,9 +596,7 @@ func checkConflict(elm *FlockStruct, flock *FlockStruct) bool {
 	return false
 }
 
-func (mS *mountStruct) verifyLock(inodeNumber inode.InodeNumber, flock *FlockStruct) (conflictLock *FlockStruct) {
-	flockList := mS.getFileLockList(inodeNumber)
-
+func verifyLock(flockList *list.List, flock *FlockStruct) (conflictLock *FlockStruct) {
 	for e := flockList.Front(); e != nil; e = e.Next() {
 		elm := e.Value.(*FlockStruct)
 
@@ -419,11 +608,10 @@ func (mS *mountStruct) verifyLock(inodeNumber inode.InodeNumber, flock *FlockStr
 	return nil
 }
 
-// Insert a file lock range to corresponding lock list for the pid.
+// Insert a file lock range to corresponding lock list for the owner.
 // Assumption: There is no lock conflict and the range that is being inserted has no conflict and is free.
-func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *FlockStruct) (err error) {
+func fileLockInsert(flockList *list.List, inFlock *FlockStruct) (err error) {
 	err = nil
-	flockList := mS.getFileLockList(inodeNumber)
 
 	overlapList := new(list.List)
 	var beforeElm *list.Element // Refers to the immediate element that starts before the start of the range.
@@ -458,7 +646,7 @@ func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *Fl
 			return
 		}
 
-		if elm.Pid == inFlock.Pid {
+		if sameFlockOwner(elm, inFlock) {
 			overlapList.PushBack(e)
 		}
 	}
@@ -466,7 +654,7 @@ func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *Fl
 	if overlapList.Len() == 0 {
 		if beforeElm != nil {
 			elm := beforeElm.Value.(*FlockStruct)
-			if elm.Pid == inFlock.Pid && elm.Type == inFlock.Type && (elm.Start+elm.Len) == inFlock.Start {
+			if sameFlockOwner(elm, inFlock) && elm.Type == inFlock.Type && (elm.Start+elm.Len) == inFlock.Start {
 				elm.Len = inFlock.Start + inFlock.Len - elm.Len
 			} else {
 				flockList.InsertAfter(inFlock, beforeElm)
@@ -497,14 +685,14 @@ func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *Fl
 	// First adjust the after:
 	if afterElm != nil {
 		elm := afterElm.Value.(*FlockStruct)
-		if elm.Pid == inFlock.Pid && elm.Type == inFlock.Type && (inFlock.Start+inFlock.Len) == elm.Start {
+		if sameFlockOwner(elm, inFlock) && elm.Type == inFlock.Type && (inFlock.Start+inFlock.Len) == elm.Start {
 			// We can collapse the entry:
 			elm.Len = elm.Start + elm.Len - inFlock.Start
 			elm.Start = inFlock.Start
 
 			if beforeElm != nil {
 				belm := beforeElm.Value.(*FlockStruct)
-				if belm.Pid == elm.Pid && belm.Type == elm.Type && (belm.Start+belm.Len) == elm.Start {
+				if sameFlockOwner(belm, elm) && belm.Type == elm.Type && (belm.Start+belm.Len) == elm.Start {
 					belm.Len = elm.Start + elm.Len - belm.Start
 					flockList.Remove(afterElm)
 				}
@@ -516,7 +704,7 @@ func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *Fl
 
 	if beforeElm != nil {
 		belm := beforeElm.Value.(*FlockStruct)
-		if belm.Pid == inFlock.Pid && belm.Type == inFlock.Type && (belm.Start+belm.Len) == inFlock.Start {
+		if sameFlockOwner(belm, inFlock) && belm.Type == inFlock.Type && (belm.Start+belm.Len) == inFlock.Start {
 			belm.Len = inFlock.Start + inFlock.Len - belm.Start
 		}
 
@@ -534,14 +722,8 @@ func (mS *mountStruct) fileLockInsert(inodeNumber inode.InodeNumber, inFlock *Fl
 
 }
 
-// Unlock a given range. All locks held in this range by the process (indentified by Pid) are removed.
-func (mS *mountStruct) fileUnlock(inodeNumber inode.InodeNumber, inFlock *FlockStruct) (err error) {
-
-	flockList := mS.getFileLockList(inodeNumber)
-	if flockList == nil {
-		logger.Warnf("Unlock of a region not already locked - %+v", inFlock)
-		return
-	}
+// Unlock a given range. All locks held in this range by the owner (indentified by MountID, ClientID, and Pid) are removed.
+func fileUnlock(flockList *list.List, inFlock *FlockStruct) (err error) {
 
 	start := inFlock.Start
 	len := inFlock.Len
@@ -551,7 +733,7 @@ func (mS *mountStruct) fileUnlock(inodeNumber inode.InodeNumber, inFlock *FlockS
 	for e := flockList.Front(); e != nil; e = e.Next() {
 		elm := e.Value.(*FlockStruct)
 
-		if elm.Pid != inFlock.Pid {
+		if !sameFlockOwner(elm, inFlock) {
 			continue
 		}
 
@@ -587,11 +769,9 @@ func (mS *mountStruct) fileUnlock(inodeNumber inode.InodeNumber, inFlock *FlockS
 
 			// Create a new record - handle case #3 both (starts before the range and extends beyond the range)
 			elmTail := new(FlockStruct)
+			*elmTail = *elm
 			elmTail.Start = start + len
 			elmTail.Len = elmLen - elm.Start
-			elmTail.Pid = elm.Pid
-			elmTail.Type = elm.Type
-			elmTail.Whence = elm.Whence
 			flockList.InsertAfter(elmTail, e)
 			break
 		}
@@ -605,25 +785,60 @@ func (mS *mountStruct) fileUnlock(inodeNumber inode.InodeNumber, inFlock *FlockS
 	return
 }
 
-// Implements file locking conforming to fcntl(2) locking description. F_SETLKW is not implemented. Supports F_SETLW and F_GETLW.
+// Implements file locking conforming to fcntl(2) locking description. Supports F_GETLK, F_SETLK, and F_SETLKW.
 // whence: FS supports only SEEK_SET - starting from 0, since it does not manage file handles, caller is expected to supply the start and length relative to offset ZERO.
+// F_SETLKW blocks (without holding any volume or inode locks) until the lock is granted or the waiter is
+// cancelled by ReleaseFlocks() (or the volume going away), in which case InterruptedError is returned.
 func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlock *FlockStruct) (outFlock *FlockStruct, err error) {
-	mS.volStruct.validateVolumeRWMutex.RLock()
-	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+	var (
+		flockGeneration uint64
+		waiter          *flockWaiterStruct
+	)
 
-	outFlock = inFlock
+	inFlock.MountID = mS.id
 
-	if lockCmd == syscall.F_SETLKW {
-		err = blunder.AddError(nil, blunder.NotSupportedError)
+	if lockCmd != syscall.F_SETLKW {
+		outFlock, _, err = mS.flockOnce(userID, groupID, otherGroupIDs, inodeNumber, lockCmd, inFlock)
 		return
 	}
 
+	waiter = mS.volStruct.registerFlockWaiter(mS.id, inFlock.ClientID)
+	defer mS.volStruct.unregisterFlockWaiter(waiter)
+
+	for {
+		outFlock, flockGeneration, err = mS.flockOnce(userID, groupID, otherGroupIDs, inodeNumber, syscall.F_SETLK, inFlock)
+		if !blunder.Is(err, blunder.TryAgainError) {
+			return
+		}
+
+		stats.IncrementOperations(&stats.FsFlockWaitOps)
+
+		err = mS.volStruct.waitForFlockChange(waiter, flockGeneration)
+		if nil != err {
+			return
+		}
+	}
+}
+
+// flockOnce performs a single, non-blocking, F_GETLK or F_SETLK. The returned flockGeneration may be passed
+// to waitForFlockChange() to wait for some lock to be dropped before retrying.
+func (mS *mountStruct) flockOnce(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, lockCmd int32, inFlock *FlockStruct) (outFlock *FlockStruct, flockGeneration uint64, err error) {
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	outFlock = inFlock
+
 	// Make sure the inode does not go away, while we are applying the flock.
 	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
 	if err != nil {
 		return
 	}
-	err = inodeLock.ReadLock()
+	if mS.volStruct.flockPersistence && (lockCmd == syscall.F_SETLK) {
+		// Updating the persisted lock list modifies the inode
+		err = inodeLock.WriteLock()
+	} else {
+		err = inodeLock.ReadLock()
+	}
 	if err != nil {
 		return
 	}
@@ -642,11 +857,24 @@ func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupI
 		inFlock.Len = ^uint64(0)
 	}
 
+	mS.volStruct.flockMutex.Lock()
+	defer mS.volStruct.flockMutex.Unlock()
+
+	flockGeneration = mS.volStruct.flockGeneration
+
+	flockList, err := mS.volStruct.getFileLockListWhileLocked(inodeNumber)
+	if err != nil {
+		return
+	}
+
+	flockListModified := mS.volStruct.reclaimFlocksWhileLocked(flockList, inFlock)
+
 	switch lockCmd {
 	case syscall.F_GETLK:
-		conflictLock := mS.verifyLock(inodeNumber, inFlock)
+		conflictLock := verifyLock(flockList, inFlock)
 		if conflictLock != nil {
-			outFlock = conflictLock
+			outFlock = new(FlockStruct)
+			*outFlock = *conflictLock
 			err = blunder.AddError(nil, blunder.TryAgainError)
 		} else {
 			outFlock = inFlock
@@ -656,14 +884,24 @@ func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupI
 
 	case syscall.F_SETLK:
 		if inFlock.Type == syscall.F_UNLCK {
-			err = mS.fileUnlock(inodeNumber, inFlock)
+			err = fileUnlock(flockList, inFlock)
+			mS.volStruct.flockDroppedWhileLocked()
 		} else if inFlock.Type == syscall.F_WRLCK || inFlock.Type == syscall.F_RDLCK {
-			err = mS.fileLockInsert(inodeNumber, inFlock)
+			downgrade := (inFlock.Type == syscall.F_RDLCK) && ownsOverlappingWriteLock(flockList, inFlock)
+			err = fileLockInsert(flockList, inFlock)
+			if (err == nil) && downgrade {
+				// Readers waiting on the (now read) lock may proceed
+				mS.volStruct.flockDroppedWhileLocked()
+			}
 		} else {
 			err = blunder.NewError(blunder.InvalidArgError, "EINVAL")
 			return
 		}
 
+		if err == nil {
+			flockListModified = true
+		}
+
 		break
 
 	default:
@@ -671,6 +909,13 @@ func (mS *mountStruct) Flock(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
+	if flockListModified && mS.volStruct.flockPersistence && (lockCmd == syscall.F_SETLK) {
+		persistErr := mS.volStruct.persistFileLockListWhileLocked(inodeNumber, flockList)
+		if persistErr != nil {
+			logger.ErrorfWithError(persistErr, "Flock() unable to persist locks of inode %v", inodeNumber)
+		}
+	}
+
 	stats.IncrementOperations(&stats.FsFlockOps)
 	return
 }
@@ -710,6 +955,8 @@ func (mS *mountStruct) getstatHelper(inodeNumber inode.InodeNumber, callerID dlm
 }
 
 func (mS *mountStruct) Getstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber) (stat Stat, err error) {
+	mS.breakLeases(inodeNumber, dlm.ReasonReadRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -791,6 +1038,14 @@ func (mS *mountStruct) GetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 		return
 	}
 
+	if (ProjectBytesXAttrName == streamName) || (ProjectInodesXAttrName == streamName) {
+		value, err = mS.volStruct.quotaTreeXAttr(inodeNumber, streamName)
+		if nil == err {
+			stats.IncrementOperations(&stats.FsGetXattrOps)
+			return
+		}
+	}
+
 	value, err = mS.volStruct.VolumeHandle.GetStream(inodeNumber, streamName)
 	if err != nil {
 		// Did not find the requested stream. However this isn't really an error since
@@ -879,6 +1134,13 @@ func (mS *mountStruct) IsSymlink(userID inode.InodeUserID, groupID inode.InodeGr
 }
 
 func (mS *mountStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber, basename string, targetInodeNumber inode.InodeNumber) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes([]inode.InodeNumber{dirInodeNumber, targetInodeNumber}, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -958,6 +1220,11 @@ func (mS *mountStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupID
 		return
 	}
 
+	err = mS.volStruct.quotaTreeCheckLink(dirInodeNumber, targetInodeNumber)
+	if nil != err {
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.Link(dirInodeNumber, basename, targetInodeNumber)
 
 	// if the link was successful and this is a regular file then any
@@ -966,6 +1233,11 @@ func (mS *mountStruct) Link(userID inode.InodeUserID, groupID inode.InodeGroupID
 		mS.volStruct.untrackInFlightFileInodeData(targetInodeNumber, false)
 	}
 
+	if err == nil {
+		mS.volStruct.watchNotifyEntry(WatchEventCreate, dirInodeNumber, basename, targetInodeNumber)
+		mS.volStruct.journalAppend(JournalOpCreate, dirInodeNumber, basename, targetInodeNumber)
+	}
+
 	stats.IncrementOperations(&stats.FsLinkOps)
 	return err
 }
@@ -1084,6 +1356,17 @@ func (mS *mountStruct) LookupPath(userID inode.InodeUserID, groupID inode.InodeG
 }
 
 func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string) (ino uint64, numWrites uint64, modificationTime uint64, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	leaseBreakInodeNumbers := mS.lookupPathForLeaseBreak(destPath)
+	for _, elementPath := range elementPaths {
+		leaseBreakInodeNumbers = append(leaseBreakInodeNumbers, mS.lookupPathForLeaseBreak(elementPath)...)
+	}
+	mS.breakLeasesOfInodes(leaseBreakInodeNumbers, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -1225,6 +1508,8 @@ func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string
 			return
 		}
 
+		mS.volStruct.journalAppend(JournalOpCreate, cursorInodeNumber, pathComponent, newDirInodeNumber)
+
 		if cursorInodeLock != nil {
 			cursorInodeLock.Unlock()
 		}
@@ -1291,12 +1576,28 @@ func (mS *mountStruct) MiddlewareCoalesce(destPath string, elementPaths []string
 	// We've now jumped through all the requisite hoops to get the required locks, so now we can call inode.Coalesce and
 	// do something useful
 	destInodeNumber, mtime, numWrites, err := mS.volStruct.VolumeHandle.Coalesce(cursorInodeNumber, destFileName, coalesceElements)
+	if nil == err {
+		err = mS.volStruct.VolumeHandle.InheritQuotaTree(cursorInodeNumber, destInodeNumber)
+	}
+	if nil == err {
+		for _, element := range coalesceElements {
+			mS.volStruct.journalAppend(JournalOpUnlink, element.ContainingDirectoryInodeNumber, element.ElementName, element.ElementInodeNumber)
+		}
+		mS.volStruct.journalAppend(JournalOpCreate, cursorInodeNumber, destFileName, destInodeNumber)
+	}
 	ino = uint64(destInodeNumber)
 	modificationTime = uint64(mtime.UnixNano())
 	return
 }
 
 func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes(mS.lookupPathForLeaseBreak(parentDir+"/"+baseName), dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -1354,6 +1655,26 @@ func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err
 		doDestroy = (1 == basenameLinkCount)
 	}
 
+	// An inode that would be destroyed goes to the trash instead (if enabled) just as for Unlink() & Rmdir()
+
+	if doDestroy && mS.volStruct.trashWanted(parentInodeNumber) {
+		if mS.volStruct.trashDirInodeNumber == baseNameInodeNumber {
+			err = fmt.Errorf("MiddlewareDelete() called on the trash directory")
+			err = blunder.AddError(err, blunder.NotPermError)
+			return
+		}
+
+		err = mS.volStruct.trashInsert(parentDirLock.GetCallerID(), parentInodeNumber, baseName, baseNameInodeNumber)
+		if nil != err {
+			return
+		}
+
+		mS.volStruct.journalAppend(JournalOpUnlink, parentInodeNumber, baseName, baseNameInodeNumber)
+
+		stats.IncrementOperations(&stats.FsMwDeleteOps)
+		return
+	}
+
 	// At this point, we *are* going to Unlink... and optionally Destroy... the inode
 
 	err = mS.volStruct.VolumeHandle.Unlink(parentInodeNumber, baseName)
@@ -1361,6 +1682,8 @@ func (mS *mountStruct) MiddlewareDelete(parentDir string, baseName string) (err
 		return
 	}
 
+	mS.volStruct.journalAppend(JournalOpUnlink, parentInodeNumber, baseName, baseNameInodeNumber)
+
 	if doDestroy {
 		err = mS.volStruct.VolumeHandle.Destroy(baseNameInodeNumber)
 		if nil != err {
@@ -1416,6 +1739,10 @@ func (mS *mountStruct) MiddlewareGetAccount(maxEntries uint64, marker string) (a
 			if dirEnt.Basename == "." || dirEnt.Basename == ".." {
 				continue
 			}
+			if dirEnt.InodeNumber == mS.volStruct.trashDirInodeNumber {
+				// The trash is not a container
+				continue
+			}
 
 			statResult, err1 := mS.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirEnt.InodeNumber)
 			if err1 != nil {
@@ -1638,7 +1965,7 @@ func (mS *mountStruct) MiddlewareGetContainer(vContainerName string, maxEntries
 	return
 }
 
-func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, err error) {
+func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPath string, readRangeIn []ReadRangeIn, readRangeOut *[]inode.ReadPlanStep) (fileSize uint64, lastModified uint64, ino uint64, numWrites uint64, serializedMetadata []byte, leaseID string, err error) {
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -1667,6 +1994,9 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 	lastModified = uint64(metadata.ModificationTime.UnixNano())
 	numWrites = metadata.NumWrites
 
+	// The (unabridged) ReadPlanSteps identify the LogSegments to pin
+	leasedReadPlan := make([]inode.ReadPlanStep, 0)
+
 	// If no ranges are given then get range of whole file.  Otherwise, get ranges.
 	if len(readRangeIn) == 0 {
 		// Get ReadPlan for file
@@ -1683,6 +2013,7 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 			return
 		}
 		appendReadPlanEntries(tmpReadEnt, readRangeOut)
+		leasedReadPlan = append(leasedReadPlan, tmpReadEnt...)
 	} else {
 		volumeHandle, err1 := inode.FetchVolumeHandle(volumeName)
 		if err1 != nil {
@@ -1700,6 +2031,7 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 				return
 			}
 			appendReadPlanEntries(tmpReadEnt, readRangeOut)
+			leasedReadPlan = append(leasedReadPlan, tmpReadEnt...)
 		}
 	}
 
@@ -1713,6 +2045,14 @@ func (mS *mountStruct) MiddlewareGetObject(volumeName string, containerObjectPat
 	} else {
 		err = nil
 	}
+
+	// Pin the LogSegments in the ReadPlan (while still holding the inode lock) so
+	// that they survive until the middleware has finished reading them
+	leaseID, err = mS.volStruct.VolumeHandle.CreateLogSegmentLease(leasedReadPlan)
+	if err != nil {
+		return
+	}
+
 	stats.IncrementOperations(&stats.FsMwGetObjOps)
 	return
 }
@@ -1754,6 +2094,13 @@ func (mS *mountStruct) MiddlewareHeadResponse(entityPath string) (response HeadR
 }
 
 func (mS *mountStruct) MiddlewarePost(parentDir string, baseName string, newMetaData []byte, oldMetaData []byte) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes(mS.lookupPathForLeaseBreak(parentDir+"/"+baseName), dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -1782,12 +2129,15 @@ func (mS *mountStruct) MiddlewarePost(parentDir string, baseName string, newMeta
 	// Change looks okay so make it.
 	err = mS.volStruct.VolumeHandle.PutStream(baseNameInodeNumber, MiddlewareStream, newMetaData)
 	mS.volStruct.untrackInFlightFileInodeData(baseNameInodeNumber, false)
+	if nil == err {
+		mS.volStruct.journalAppend(JournalOpSetattr, inode.InodeNumber(0), "", baseNameInodeNumber)
+	}
 
 	stats.IncrementOperations(&stats.FsMwPostOps)
 	return err
 }
 
-func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string, makeInodeFunc func() (inode.InodeNumber, error)) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string, objectLength uint64, makeInodeFunc func() (inode.InodeNumber, error)) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
 
 	// Find the inode of the directory corresponding to the container
 	dirInodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, vContainerName)
@@ -1899,11 +2249,21 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 	// Now, dirInodeNumber is the inode of the lowest existing directory. Anything else is created by us and isn't part
 	// of the filesystem tree until we Link() it in, so we only need to hold this one lock. Call the inode-creator
 	// function and start linking stuff together.
+	err = mS.volStruct.quotaCheckCreate(inode.InodeRootUserID, inode.InodeGroupID(0), dirInodeNumber, objectLength, uint64(1+len(dirs)))
+	if err != nil {
+		return
+	}
+
 	fileInodeNumber, err = makeInodeFunc()
 	if err != nil {
 		return
 	}
 
+	err = mS.volStruct.VolumeHandle.InheritQuotaTree(dirInodeNumber, fileInodeNumber)
+	if err != nil {
+		return
+	}
+
 	highestUnlinkedInodeNumber := fileInodeNumber
 	highestUnlinkedName := vObjectBaseName
 	for i := 0; i < len(dirs); i++ {
@@ -1914,6 +2274,11 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 			return
 		}
 
+		err = mS.volStruct.VolumeHandle.InheritQuotaTree(dirInodeNumber, newDirInodeNumber)
+		if err != nil {
+			return
+		}
+
 		err = mS.volStruct.VolumeHandle.Link(newDirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
 		if err != nil {
 			logger.DebugfIDWithError(internalDebug, err, "mount.Link(%v, %v, %v) failed",
@@ -1921,6 +2286,7 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 			return
 		}
 		mS.volStruct.untrackInFlightFileInodeData(highestUnlinkedInodeNumber, false)
+		mS.volStruct.journalAppend(JournalOpCreate, newDirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
 
 		highestUnlinkedInodeNumber = newDirInodeNumber
 		highestUnlinkedName = dirs[i]
@@ -1986,6 +2352,11 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 		return
 	}
 
+	if haveObstacle {
+		mS.volStruct.journalAppend(JournalOpUnlink, dirInodeNumber, vObjectBaseName, obstacleInodeNumber)
+	}
+	mS.volStruct.journalAppend(JournalOpCreate, dirInodeNumber, highestUnlinkedName, highestUnlinkedInodeNumber)
+
 	// Log errors from inode destruction, but don't let them cause the
 	// RPC call to fail. As far as this function's caller is
 	// concerned, everything worked as intended.
@@ -2010,6 +2381,13 @@ func putObjectHelper(mS *mountStruct, vContainerName string, vObjectPath string,
 }
 
 func (mS *mountStruct) MiddlewarePutComplete(vContainerName string, vObjectPath string, pObjectPaths []string, pObjectLengths []uint64, pObjectMetadata []byte) (mtime uint64, fileInodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes(mS.lookupPathForLeaseBreak(vContainerName+"/"+vObjectPath), dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2046,10 +2424,20 @@ func (mS *mountStruct) MiddlewarePutComplete(vContainerName string, vObjectPath
 		return
 	}
 
-	return putObjectHelper(mS, vContainerName, vObjectPath, reifyTheFile)
+	objectLength := uint64(0)
+	for _, pObjectLength := range pObjectLengths {
+		objectLength += pObjectLength
+	}
+
+	return putObjectHelper(mS, vContainerName, vObjectPath, objectLength, reifyTheFile)
 }
 
 func (mS *mountStruct) MiddlewareMkdir(vContainerName string, vObjectPath string, metadata []byte) (mtime uint64, inodeNumber inode.InodeNumber, numWrites uint64, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2071,7 +2459,7 @@ func (mS *mountStruct) MiddlewareMkdir(vContainerName string, vObjectPath string
 		return
 	}
 
-	return putObjectHelper(mS, vContainerName, vObjectPath, createTheDirectory)
+	return putObjectHelper(mS, vContainerName, vObjectPath, 0, createTheDirectory)
 }
 
 func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata []byte, newMetadata []byte) (err error) {
@@ -2083,6 +2471,11 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 		newDirInodeNumber    inode.InodeNumber
 	)
 
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2121,6 +2514,9 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 		}
 
 		err = mS.volStruct.VolumeHandle.Link(inode.RootDirInodeNumber, containerName, newDirInodeNumber)
+		if nil == err {
+			mS.volStruct.journalAppend(JournalOpCreate, inode.RootDirInodeNumber, containerName, newDirInodeNumber)
+		}
 
 		return
 	}
@@ -2147,12 +2543,20 @@ func (mS *mountStruct) MiddlewarePutContainer(containerName string, oldMetadata
 		return
 	}
 	err = mS.volStruct.VolumeHandle.PutStream(containerInodeNumber, MiddlewareStream, newMetadata)
+	if nil == err {
+		mS.volStruct.journalAppend(JournalOpSetattr, inode.InodeNumber(0), "", containerInodeNumber)
+	}
 
 	stats.IncrementOperations(&stats.FsMwPutContainerOps)
 	return
 }
 
 func (mS *mountStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, filePerm inode.InodeMode) (newDirInodeNumber inode.InodeNumber, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2199,6 +2603,33 @@ func (mS *mountStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return 0, err
 	}
 
+	err = mS.volStruct.quotaCheckCreate(userID, groupID, inodeNumber, 0, 1)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed quotaCheckCreate() in fs.Mkdir", newDirInodeNumber)
+		}
+		return 0, err
+	}
+
+	err = mS.volStruct.VolumeHandle.InheritACL(inodeNumber, newDirInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed InheritACL() in fs.Mkdir", newDirInodeNumber)
+		}
+		return 0, err
+	}
+
+	err = mS.volStruct.VolumeHandle.InheritQuotaTree(inodeNumber, newDirInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed InheritQuotaTree() in fs.Mkdir", newDirInodeNumber)
+		}
+		return 0, err
+	}
+
 	err = mS.volStruct.VolumeHandle.Link(inodeNumber, basename, newDirInodeNumber)
 	if err != nil {
 		destroyErr := mS.volStruct.VolumeHandle.Destroy(newDirInodeNumber)
@@ -2207,11 +2638,22 @@ func (mS *mountStruct) Mkdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		}
 		return 0, err
 	}
+
+	mS.volStruct.watchNotifyEntry(WatchEventCreate, inodeNumber, basename, newDirInodeNumber)
+	mS.volStruct.journalAppend(JournalOpCreate, inodeNumber, basename, newDirInodeNumber)
+
 	stats.IncrementOperations(&stats.FsMkdirOps)
 	return newDirInodeNumber, nil
 }
 
 func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(inodeNumber, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2230,15 +2672,27 @@ func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inode
 		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
 		return
 	}
-	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK,
-		inode.OwnerOverride) {
-		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
-		return
+	if inode.IsACLStreamName(streamName) {
+		// Like the mode bits, POSIX ACLs may only be changed by the owner (or root)
+		if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.P_OK,
+			inode.NoOverride) {
+			err = blunder.NewError(blunder.NotPermError, "EPERM")
+			return
+		}
+	} else {
+		if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK,
+			inode.OwnerOverride) {
+			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+			return
+		}
 	}
 
 	err = mS.volStruct.VolumeHandle.DeleteStream(inodeNumber, streamName)
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to delete XAttr %v of inode %v", streamName, inodeNumber)
+	} else {
+		mS.volStruct.watchNotifyInode(WatchEventSetattr, inodeNumber)
+		mS.volStruct.journalAppend(JournalOpSetattr, inode.InodeNumber(0), "", inodeNumber)
 	}
 
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
@@ -2248,6 +2702,16 @@ func (mS *mountStruct) RemoveXAttr(userID inode.InodeUserID, groupID inode.Inode
 }
 
 func (mS *mountStruct) Rename(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	leaseBreakInodeNumbers := []inode.InodeNumber{srcDirInodeNumber, dstDirInodeNumber}
+	leaseBreakInodeNumbers = append(leaseBreakInodeNumbers, mS.lookupForLeaseBreak(srcDirInodeNumber, srcBasename)...)
+	leaseBreakInodeNumbers = append(leaseBreakInodeNumbers, mS.lookupForLeaseBreak(dstDirInodeNumber, dstBasename)...)
+	mS.breakLeasesOfInodes(leaseBreakInodeNumbers, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2332,8 +2796,29 @@ retryLock:
 		}
 	}
 
+	// A move between quota trees must fit within the destination tree's limits (if any)
+	err = mS.volStruct.quotaCheckMove(srcDirInodeNumber, srcBasename, dstDirInodeNumber)
+	if nil != err {
+		if !srcAndDestDirsAreSame {
+			dstDirLock.Unlock()
+		}
+		srcDirLock.Unlock()
+		return
+	}
+
+	// Note the inode being moved (should either directory be watched or the volume journaled) before moving it
+	movedInodeNumber := mS.volStruct.watchLookup(srcDirInodeNumber, srcBasename, dstDirInodeNumber)
+
+	var quotaTreeMove *quotaTreeMoveStruct
+
 	// Now we have the locks for both directories; we can do the move
 	err = mS.volStruct.VolumeHandle.Move(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename)
+	if nil == err {
+		mS.volStruct.watchNotifyRename(srcDirInodeNumber, srcBasename, dstDirInodeNumber, dstBasename, movedInodeNumber)
+		mS.volStruct.journalAppend(JournalOpRenameFrom, srcDirInodeNumber, srcBasename, movedInodeNumber)
+		mS.volStruct.journalAppend(JournalOpRenameTo, dstDirInodeNumber, dstBasename, movedInodeNumber)
+		quotaTreeMove = mS.volStruct.quotaTreeMove(srcDirInodeNumber, dstDirInodeNumber, dstBasename)
+	}
 
 	// Release our locks and return
 	if !srcAndDestDirsAreSame {
@@ -2341,6 +2826,11 @@ retryLock:
 	}
 	srcDirLock.Unlock()
 
+	// Retag any descendants of a directory moved between quota trees without holding up the directories
+	if nil != quotaTreeMove {
+		mS.volStruct.quotaTreeMoveDescendants(quotaTreeMove)
+	}
+
 	// TODO: Where is the lock on the potentially removed fileInode overwritten by the Move() call?
 
 	stats.IncrementOperations(&stats.FsRenameOps)
@@ -2348,6 +2838,8 @@ retryLock:
 }
 
 func (mS *mountStruct) Read(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, length uint64, profiler *utils.Profiler) (buf []byte, err error) {
+	mS.breakLeases(inodeNumber, dlm.ReasonReadRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2630,6 +3122,13 @@ func (mS *mountStruct) Readsymlink(userID inode.InodeUserID, groupID inode.Inode
 }
 
 func (mS *mountStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, newSize uint64) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(inodeNumber, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2656,13 +3155,29 @@ func (mS *mountStruct) Resize(userID inode.InodeUserID, groupID inode.InodeGroup
 		return
 	}
 
+	err = mS.volStruct.quotaCheckInode(inodeNumber, newSize)
+	if nil != err {
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.SetSize(inodeNumber, newSize)
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
+	if nil == err {
+		mS.volStruct.watchNotifyInode(WatchEventSetattr, inodeNumber)
+		mS.volStruct.journalAppend(JournalOpWrite, inode.InodeNumber(0), "", inodeNumber)
+	}
 	stats.IncrementOperations(&stats.FsSetsizeOps)
 	return err
 }
 
 func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes(append(mS.lookupForLeaseBreak(inodeNumber, basename), inodeNumber), dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2727,11 +3242,33 @@ func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
+	if mS.volStruct.trashWanted(inodeNumber) {
+		if mS.volStruct.trashDirInodeNumber == basenameInodeNumber {
+			err = fmt.Errorf("Rmdir() called on the trash directory")
+			err = blunder.AddError(err, blunder.NotPermError)
+			return
+		}
+
+		err = mS.volStruct.trashInsert(callerID, inodeNumber, basename, basenameInodeNumber)
+		if nil != err {
+			return
+		}
+
+		mS.volStruct.watchNotifyEntry(WatchEventUnlink, inodeNumber, basename, basenameInodeNumber)
+		mS.volStruct.journalAppend(JournalOpUnlink, inodeNumber, basename, basenameInodeNumber)
+
+		stats.IncrementOperations(&stats.FsRmdirOps)
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.Unlink(inodeNumber, basename)
 	if nil != err {
 		return
 	}
 
+	mS.volStruct.watchNotifyEntry(WatchEventUnlink, inodeNumber, basename, basenameInodeNumber)
+	mS.volStruct.journalAppend(JournalOpUnlink, inodeNumber, basename, basenameInodeNumber)
+
 	err = mS.volStruct.VolumeHandle.Destroy(basenameInodeNumber)
 	if nil != err {
 		return
@@ -2742,6 +3279,13 @@ func (mS *mountStruct) Rmdir(userID inode.InodeUserID, groupID inode.InodeGroupI
 }
 
 func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, stat Stat) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(inodeNumber, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2924,6 +3468,9 @@ func (mS *mountStruct) Setstat(userID inode.InodeUserID, groupID inode.InodeGrou
 		}
 	}
 
+	mS.volStruct.watchNotifyInode(WatchEventSetattr, inodeNumber)
+	mS.volStruct.journalAppend(JournalOpSetattr, inode.InodeNumber(0), "", inodeNumber)
+
 	stats.IncrementOperations(&stats.FsSetstatOps)
 	return
 }
@@ -2935,6 +3482,13 @@ const (
 )
 
 func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, streamName string, value []byte, flags int) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(inodeNumber, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -2953,10 +3507,19 @@ func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
 		return
 	}
-	if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK,
-		inode.OwnerOverride) {
-		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
-		return
+	if inode.IsACLStreamName(streamName) {
+		// Like the mode bits, POSIX ACLs may only be changed by the owner (or root)
+		if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.P_OK,
+			inode.NoOverride) {
+			err = blunder.NewError(blunder.NotPermError, "EPERM")
+			return
+		}
+	} else {
+		if !mS.volStruct.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.W_OK,
+			inode.OwnerOverride) {
+			err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+			return
+		}
 	}
 
 	switch flags {
@@ -2976,9 +3539,17 @@ func (mS *mountStruct) SetXAttr(userID inode.InodeUserID, groupID inode.InodeGro
 		return blunder.AddError(err, blunder.InvalidArgError)
 	}
 
-	err = mS.volStruct.VolumeHandle.PutStream(inodeNumber, streamName, value)
+	if inode.IsACLStreamName(streamName) {
+		// POSIX ACLs are validated (and mirrored in the mode bits) by package inode
+		err = mS.volStruct.VolumeHandle.SetACL(inodeNumber, streamName, value)
+	} else {
+		err = mS.volStruct.VolumeHandle.PutStream(inodeNumber, streamName, value)
+	}
 	if err != nil {
 		logger.ErrorfWithError(err, "Failed to set XAttr %v to inode %v", streamName, inodeNumber)
+	} else {
+		mS.volStruct.watchNotifyInode(WatchEventSetattr, inodeNumber)
+		mS.volStruct.journalAppend(JournalOpSetattr, inode.InodeNumber(0), "", inodeNumber)
 	}
 
 	mS.volStruct.untrackInFlightFileInodeData(inodeNumber, false)
@@ -2991,17 +3562,36 @@ func (mS *mountStruct) StatVfs() (statVFS StatVFS, err error) {
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
+	// Usage reflects inodes as of their last flush
+
+	usedBytes, numInodes, err := mS.volStruct.VolumeHandle.FetchVolumeUsage()
+	if nil != err {
+		return
+	}
+
+	totalBlocks := mS.volStruct.volumeSize / FsBlockSize
+	usedBlocks := (usedBytes + FsBlockSize - 1) / FsBlockSize
+	freeBlocks := uint64(0)
+	if usedBlocks < totalBlocks {
+		freeBlocks = totalBlocks - usedBlocks
+	}
+
+	freeInodes := uint64(0)
+	if numInodes < mS.volStruct.maxInodes {
+		freeInodes = mS.volStruct.maxInodes - numInodes
+	}
+
 	statVFS = make(map[StatVFSKey]uint64)
 
 	statVFS[StatVFSFilesystemID] = mS.volStruct.VolumeHandle.GetFSID()
 	statVFS[StatVFSBlockSize] = FsBlockSize
 	statVFS[StatVFSFragmentSize] = FsOptimalTransferSize
-	statVFS[StatVFSTotalBlocks] = VolFakeTotalBlocks
-	statVFS[StatVFSFreeBlocks] = VolFakeFreeBlocks
-	statVFS[StatVFSAvailBlocks] = VolFakeAvailBlocks
-	statVFS[StatVFSTotalInodes] = VolFakeTotalInodes
-	statVFS[StatVFSFreeInodes] = VolFakeAvailInodes
-	statVFS[StatVFSAvailInodes] = VolFakeAvailInodes
+	statVFS[StatVFSTotalBlocks] = totalBlocks
+	statVFS[StatVFSFreeBlocks] = freeBlocks
+	statVFS[StatVFSAvailBlocks] = freeBlocks
+	statVFS[StatVFSTotalInodes] = mS.volStruct.maxInodes
+	statVFS[StatVFSFreeInodes] = freeInodes
+	statVFS[StatVFSAvailInodes] = freeInodes
 	statVFS[StatVFSMountFlags] = 0
 	statVFS[StatVFSMaxFilenameLen] = FileNameMax
 
@@ -3010,6 +3600,11 @@ func (mS *mountStruct) StatVfs() (statVFS StatVFS, err error) {
 }
 
 func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string, target string) (symlinkInodeNumber inode.InodeNumber, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -3060,6 +3655,24 @@ func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGrou
 		return
 	}
 
+	err = mS.volStruct.quotaCheckCreate(userID, groupID, inodeNumber, 0, 1)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(symlinkInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed quotaCheckCreate() in fs.Symlink", symlinkInodeNumber)
+		}
+		return
+	}
+
+	err = mS.volStruct.VolumeHandle.InheritQuotaTree(inodeNumber, symlinkInodeNumber)
+	if err != nil {
+		destroyErr := mS.volStruct.VolumeHandle.Destroy(symlinkInodeNumber)
+		if destroyErr != nil {
+			logger.WarnfWithError(destroyErr, "couldn't destroy inode %v after failed InheritQuotaTree() in fs.Symlink", symlinkInodeNumber)
+		}
+		return
+	}
+
 	err = mS.volStruct.VolumeHandle.Link(inodeNumber, basename, symlinkInodeNumber)
 	if err != nil {
 		destroyErr := mS.volStruct.VolumeHandle.Destroy(symlinkInodeNumber)
@@ -3069,11 +3682,21 @@ func (mS *mountStruct) Symlink(userID inode.InodeUserID, groupID inode.InodeGrou
 		return
 	}
 
+	mS.volStruct.watchNotifyEntry(WatchEventCreate, inodeNumber, basename, symlinkInodeNumber)
+	mS.volStruct.journalAppend(JournalOpCreate, inodeNumber, basename, symlinkInodeNumber)
+
 	stats.IncrementOperations(&stats.FsSymlinkOps)
 	return
 }
 
 func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, basename string) (err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeasesOfInodes(append(mS.lookupForLeaseBreak(inodeNumber, basename), inodeNumber), dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -3125,11 +3748,34 @@ func (mS *mountStruct) Unlink(userID inode.InodeUserID, groupID inode.InodeGroup
 		return
 	}
 
+	if mS.volStruct.trashWanted(inodeNumber) {
+		basenameLinkCount, err := mS.volStruct.VolumeHandle.GetLinkCount(basenameInodeNumber)
+		if nil != err {
+			return err
+		}
+
+		if 1 == basenameLinkCount {
+			err = mS.volStruct.trashInsert(callerID, inodeNumber, basename, basenameInodeNumber)
+			if nil != err {
+				return err
+			}
+
+			mS.volStruct.watchNotifyEntry(WatchEventUnlink, inodeNumber, basename, basenameInodeNumber)
+			mS.volStruct.journalAppend(JournalOpUnlink, inodeNumber, basename, basenameInodeNumber)
+
+			stats.IncrementOperations(&stats.FsUnlinkOps)
+			return nil
+		}
+	}
+
 	err = mS.volStruct.VolumeHandle.Unlink(inodeNumber, basename)
 	if nil != err {
 		return
 	}
 
+	mS.volStruct.watchNotifyEntry(WatchEventUnlink, inodeNumber, basename, basenameInodeNumber)
+	mS.volStruct.journalAppend(JournalOpUnlink, inodeNumber, basename, basenameInodeNumber)
+
 	basenameLinkCount, err := mS.volStruct.VolumeHandle.GetLinkCount(basenameInodeNumber)
 	if nil != err {
 		return
@@ -3153,6 +3799,13 @@ func (mS *mountStruct) VolumeName() (volumeName string) {
 }
 
 func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, offset uint64, buf []byte, profiler *utils.Profiler) (size uint64, err error) {
+	err = mS.checkMountWritable()
+	if nil != err {
+		return
+	}
+
+	mS.breakLeases(inodeNumber, dlm.ReasonWriteRequest)
+
 	mS.volStruct.validateVolumeRWMutex.RLock()
 	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
 
@@ -3180,6 +3833,11 @@ func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupI
 		return
 	}
 
+	err = mS.volStruct.quotaCheckInode(inodeNumber, offset+uint64(len(buf)))
+	if err != nil {
+		return 0, err
+	}
+
 	profiler.AddEventNow("before inode.Write()")
 	err = mS.volStruct.VolumeHandle.Write(inodeNumber, offset, buf, profiler)
 	profiler.AddEventNow("after inode.Write()")
@@ -3190,6 +3848,9 @@ func (mS *mountStruct) Write(userID inode.InodeUserID, groupID inode.InodeGroupI
 
 	logger.Tracef("fs.Write(): tracking write volume '%s' inode %d", mS.volStruct.volumeName, inodeNumber)
 	mS.volStruct.trackInFlightFileInodeData(inodeNumber)
+	mS.volStruct.quotaNoteUnflushed(inodeNumber)
+	mS.volStruct.watchNoteWrite(inodeNumber)
+	mS.volStruct.journalAppend(JournalOpWrite, inode.InodeNumber(0), "", inodeNumber)
 	size = uint64(len(buf))
 	stats.IncrementOperations(&stats.FsWriteOps)
 	return
@@ -3496,7 +4157,8 @@ func removeObstacleToObjectPut(mount *mountStruct, callerID dlm.CallerID, dirIno
 // Utility function to append entries to reply
 func appendReadPlanEntries(readPlan []inode.ReadPlanStep, readRangeOut *[]inode.ReadPlanStep) (numEntries uint64) {
 	for i := range readPlan {
-		entry := inode.ReadPlanStep{ObjectPath: readPlan[i].ObjectPath, Offset: readPlan[i].Offset, Length: readPlan[i].Length}
+		// The middleware fetches (and decompresses) the whole frame of a compressed step (see inode/compress.go)
+		entry := inode.ReadPlanStep{ObjectPath: readPlan[i].ObjectPath, Offset: readPlan[i].Offset, Length: readPlan[i].Length, FrameOffset: readPlan[i].FrameOffset, FrameLength: readPlan[i].FrameLength}
 		*readRangeOut = append(*readRangeOut, entry)
 		numEntries++
 	}
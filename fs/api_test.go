@@ -1203,3 +1203,216 @@ func TestStaleInodes(t *testing.T) {
 		t.Fatalf("Rmdir() of '%s' returned error: %v", testDirname, err)
 	}
 }
+
+func TestStatVfs(t *testing.T) {
+	vS := mS.volStruct
+
+	savedVolumeSize := vS.volumeSize
+	savedMaxInodes := vS.maxInodes
+	defer func() {
+		vS.volumeSize = savedVolumeSize
+		vS.maxInodes = savedMaxInodes
+	}()
+
+	vS.volumeSize = 1024 * FsBlockSize
+	vS.maxInodes = 1024
+
+	initialStatVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [initial] failed: %v", err)
+	}
+	if 1024 != initialStatVFS[StatVFSTotalBlocks] {
+		t.Fatalf("StatVfs() [initial] reported %v total blocks (expected 1024)", initialStatVFS[StatVFSTotalBlocks])
+	}
+	if 1024 != initialStatVFS[StatVFSTotalInodes] {
+		t.Fatalf("StatVfs() [initial] reported %v total inodes (expected 1024)", initialStatVFS[StatVFSTotalInodes])
+	}
+
+	dirInodeNumber := createTestDirectory(t, "TestStatVfs")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, make([]byte, 3*FsBlockSize), nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	statVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [after Write()] failed: %v", err)
+	}
+	if (initialStatVFS[StatVFSFreeBlocks] - 3) != statVFS[StatVFSFreeBlocks] {
+		t.Fatalf("StatVfs() [after Write()] reported %v free blocks (expected %v)", statVFS[StatVFSFreeBlocks], initialStatVFS[StatVFSFreeBlocks]-3)
+	}
+	if (initialStatVFS[StatVFSFreeInodes] - 2) != statVFS[StatVFSFreeInodes] {
+		t.Fatalf("StatVfs() [after Write()] reported %v free inodes (expected %v)", statVFS[StatVFSFreeInodes], initialStatVFS[StatVFSFreeInodes]-2)
+	}
+
+	// A volume whose usage exceeds its configured size reports no free space
+
+	vS.volumeSize = FsBlockSize
+
+	statVFS, err = mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [after shrinking] failed: %v", err)
+	}
+	if (1 != statVFS[StatVFSTotalBlocks]) || (0 != statVFS[StatVFSFreeBlocks]) || (0 != statVFS[StatVFSAvailBlocks]) {
+		t.Fatalf("StatVfs() [after shrinking] reported unexpected %v", statVFS)
+	}
+
+	vS.volumeSize = 1024 * FsBlockSize
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestStatVfs")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+
+	statVFS, err = mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [after cleanup] failed: %v", err)
+	}
+	if (initialStatVFS[StatVFSFreeBlocks] != statVFS[StatVFSFreeBlocks]) || (initialStatVFS[StatVFSFreeInodes] != statVFS[StatVFSFreeInodes]) {
+		t.Fatalf("StatVfs() [after cleanup] reported %v (expected %v)", statVFS, initialStatVFS)
+	}
+}
+
+func TestStatVfsSharedData(t *testing.T) {
+	vS := mS.volStruct
+
+	savedVolumeSize := vS.volumeSize
+	defer func() {
+		vS.volumeSize = savedVolumeSize
+	}()
+
+	vS.volumeSize = 1024 * FsBlockSize
+
+	dirInodeNumber := createTestDirectory(t, "TestStatVfsSharedData")
+
+	srcInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [Src] failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 0, make([]byte, 2*FsBlockSize), nil)
+	if nil != err {
+		t.Fatalf("Write() [Src] failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() [Src] failed: %v", err)
+	}
+	dstInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [Dst] failed: %v", err)
+	}
+
+	initialStatVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [initial] failed: %v", err)
+	}
+
+	clonedLength, err := mS.CloneRange(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 0, dstInodeNumber, 0, 2*FsBlockSize)
+	if (nil != err) || (2*FsBlockSize != clonedLength) {
+		t.Fatalf("CloneRange() returned clonedLength == %v [err: %v] (expected %v)", clonedLength, err, 2*FsBlockSize)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dstInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() [Dst] failed: %v", err)
+	}
+
+	// Although no further LogSegment bytes are consumed, the shared data is counted once per file
+
+	statVFS, err := mS.StatVfs()
+	if nil != err {
+		t.Fatalf("StatVfs() [after CloneRange()] failed: %v", err)
+	}
+	if (initialStatVFS[StatVFSFreeBlocks] - 2) != statVFS[StatVFSFreeBlocks] {
+		t.Fatalf("StatVfs() [after CloneRange()] reported %v free blocks (expected %v)", statVFS[StatVFSFreeBlocks], initialStatVFS[StatVFSFreeBlocks]-2)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src")
+	if nil != err {
+		t.Fatalf("Unlink() [Src] failed: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst")
+	if nil != err {
+		t.Fatalf("Unlink() [Dst] failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestStatVfsSharedData")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
+
+func TestCloneRange(t *testing.T) {
+	dirInodeNumber := createTestDirectory(t, "TestCloneRange")
+
+	srcInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [Src] failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 0, []byte("0123456789"), nil)
+	if nil != err {
+		t.Fatalf("Write() [Src] failed: %v", err)
+	}
+
+	dstInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst", inode.InodeMode(0644))
+	if nil != err {
+		t.Fatalf("Create() [Dst] failed: %v", err)
+	}
+
+	clonedLength, err := mS.CloneRange(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 0, dstInodeNumber, 0, 10)
+	if (nil != err) || (10 != clonedLength) {
+		t.Fatalf("CloneRange() returned clonedLength == %v [err: %v] (expected 10)", clonedLength, err)
+	}
+
+	// Overwriting part of Src must not be visible in Dst
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 2, []byte("XY"), nil)
+	if nil != err {
+		t.Fatalf("Write() [Src overwrite] failed: %v", err)
+	}
+
+	buf, err := mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dstInodeNumber, 0, 10, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("0123456789"), buf)) {
+		t.Fatalf("Read() [Dst] returned \"%s\" [err: %v] (expected \"0123456789\")", string(buf), err)
+	}
+
+	_, err = mS.CloneRange(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcInodeNumber, 0, dirInodeNumber, 0, 10)
+	if !blunder.Is(err, blunder.NotFileError) {
+		t.Fatalf("CloneRange() to a directory should have failed with NotFileError: %v", err)
+	}
+
+	_, err = mS.CloneRange(inode.InodeUserID(1), inode.InodeGroupID(0), nil, srcInodeNumber, 0, dstInodeNumber, 0, 10)
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("CloneRange() by non-owner should have failed with PermDeniedError: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Src")
+	if nil != err {
+		t.Fatalf("Unlink() [Src] failed: %v", err)
+	}
+
+	buf, err = mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dstInodeNumber, 0, 10, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("0123456789"), buf)) {
+		t.Fatalf("Read() [Dst after Unlink() of Src] returned \"%s\" [err: %v] (expected \"0123456789\")", string(buf), err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dst")
+	if nil != err {
+		t.Fatalf("Unlink() [Dst] failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestCloneRange")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
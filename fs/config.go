@@ -34,14 +34,65 @@ type mountStruct struct {
 }
 
 type volumeStruct struct {
-	dataMutex                sync.Mutex
-	volumeName               string
-	doCheckpointPerFlush     bool
-	maxFlushTime             time.Duration
-	FLockMap                 map[inode.InodeNumber]*list.List
-	inFlightFileInodeDataMap map[inode.InodeNumber]*inFlightFileInodeDataStruct
-	mountList                []MountID
-	validateVolumeRWMutex    sync.RWMutex
+	dataMutex                     sync.Mutex
+	volumeName                    string
+	doCheckpointPerFlush          bool
+	maxFlushTime                  time.Duration
+	volumeSize                    uint64                           // Reported by StatVfs() as the volume's capacity in bytes
+	maxInodes                     uint64                           // Reported by StatVfs() as the volume's capacity in inodes
+	FLockMap                      map[inode.InodeNumber]*list.List // Synchronized via flockMutex
+	flockMutex                    sync.Mutex
+	flockCond                     *sync.Cond // Broadcast (with flockGeneration incremented) whenever a lock is dropped
+	flockGeneration               uint64
+	flockWaiterMap                map[*flockWaiterStruct]struct{} // Synchronized via flockMutex
+	flockHalting                  bool                            // Synchronized via flockMutex
+	flockPersistence              bool                            // If true, each FileInode's locks are recorded in its FlockStream
+	flockRecoveryDeadline         time.Time                       // Recovered (persisted) locks not reclaimed by then are discarded
+	flockRecoveryTimer            *time.Timer
+	leaseMutex                    sync.Mutex
+	leaseMap                      map[inode.InodeNumber]map[MountID]*leaseStruct // Synchronized via leaseMutex
+	leaseBreakingMap              map[inode.InodeNumber]uint64                   // Synchronized via leaseMutex; count of breakLeases() in progress
+	leaseRecallsMap               map[MountID]*mountLeaseRecallsStruct           // Synchronized via leaseMutex
+	leaseHalting                  bool                                           // Synchronized via leaseMutex
+	leaseRecallTimeout            time.Duration
+	watchMutex                    sync.Mutex
+	watchMap                      map[WatchID]*watchStruct                            // Synchronized via watchMutex
+	watchDirMap                   map[inode.InodeNumber]*watchDirStruct               // Synchronized via watchMutex
+	watchParentMap                map[inode.InodeNumber]map[watchEntryStruct]struct{} // Synchronized via watchMutex; entries of watched directories
+	watchWrittenMap               map[inode.InodeNumber]struct{}                      // Synchronized via watchMutex; entries written since last Flush()
+	watchHalting                  bool                                                // Synchronized via watchMutex
+	lastWatchID                   WatchID                                             // Synchronized via watchMutex
+	watchCookie                   uint64                                              // Synchronized via watchMutex
+	watchQueueDepth               uint64
+	watchVolumeIdleTimeout        time.Duration
+	watchStopChan                 chan struct{} // If nil, watchDaemon() is not running
+	watchWG                       sync.WaitGroup
+	journalMutex                  sync.Mutex
+	journalRetention              time.Duration // If 0, changes are not recorded
+	journalHeadhunterVolumeHandle headhunter.VolumeHandle
+	journalPendingRecs            []JournalRecStruct // Synchronized via journalMutex; queued by journalAppend() but not yet put
+	journalPutMutex               sync.Mutex         // Serializes journalPutPending() such that records are put in order
+	journalLastTrimTime           time.Time          // Synchronized via journalPutMutex
+	journalKickChan               chan struct{}
+	journalStopChan               chan struct{}
+	journalWG                     sync.WaitGroup
+	quotaMutex                    sync.Mutex
+	quotaList                     []*quotaStruct                              // In QuotaList order; if empty, quotas are not checked
+	quotaUserMap                  map[inode.InodeUserID]*quotaStruct          // Synchronized via quotaMutex
+	quotaGroupMap                 map[inode.InodeGroupID]*quotaStruct         // Synchronized via quotaMutex
+	quotaTreeMap                  map[inode.InodeNumber]*quotaStruct          // Synchronized via quotaMutex; key == quota tree's DirInode
+	quotaUnflushedMap             map[inode.InodeNumber]*quotaUnflushedStruct // Synchronized via quotaMutex; key == FileInode with unflushed growth
+	trashRetention                time.Duration                               // If 0, Unlink() & Rmdir() destroy inodes immediately
+	trashDirInodeNumber           inode.InodeNumber                           // Only valid if trashRetention != 0
+	trashStopChan                 chan struct{}                               // If nil, trashDaemon() is not running
+	trashWG                       sync.WaitGroup
+	inFlightFileInodeDataMap      map[inode.InodeNumber]*inFlightFileInodeDataStruct
+	mountList                     []MountID
+	validateVolumeRWMutex         sync.RWMutex
+	defrag                        *defragStruct            // Synchronized via dataMutex; nil if background defragmentation not enabled
+	snapshotName                  string                   // If != "", this (read-only) volumeStruct presents the named snapshot of volumeName
+	snapshotID                    uint64                   // If snapshotName != "", the headhunter.SnapshotStruct.ID of the snapshot
+	snapshotVolumeMap             map[string]*volumeStruct // Synchronized via globals.Lock(); key == snapshotName
 	inode.VolumeHandle
 }
 
@@ -100,6 +151,7 @@ func Up(confMap conf.ConfMap) (err error) {
 					FLockMap:                 make(map[inode.InodeNumber]*list.List),
 					inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
 					mountList:                make([]MountID, 0),
+					snapshotVolumeMap:        make(map[string]*volumeStruct),
 				}
 
 				replayLogFileName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogFileName")
@@ -121,11 +173,56 @@ func Up(confMap conf.ConfMap) (err error) {
 					return
 				}
 
+				volume.volumeSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "VolumeSize")
+				if nil != err {
+					volume.volumeSize = VolumeSizeDefault
+				}
+
+				volume.maxInodes, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxInodes")
+				if nil != err {
+					volume.maxInodes = MaxInodesDefault
+				}
+
 				volume.VolumeHandle, err = inode.FetchVolumeHandle(volumeName)
 				if nil != err {
 					return
 				}
 
+				err = volume.flockUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.leaseUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.watchUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.journalUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.quotaUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.trashUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
+				err = volume.defragUp(confMap, volumeSectionName)
+				if nil != err {
+					return
+				}
+
 				globals.volumeMap[volumeName] = volume
 			}
 		} else {
@@ -209,6 +306,20 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 		for _, id = range volume.mountList {
 			delete(globals.mountMap, id)
 		}
+		for _, snapshotVolume := range volume.snapshotVolumeMap {
+			for _, id = range snapshotVolume.mountList {
+				delete(globals.mountMap, id)
+			}
+			snapshotVolume.watchDown()
+			snapshotVolume.leaseDown()
+			snapshotVolume.flockDown()
+		}
+		volume.defragDown()
+		volume.watchDown()
+		volume.journalDown()
+		volume.trashDown()
+		volume.leaseDown()
+		volume.flockDown()
 		volume.untrackInFlightFileInodeDataAll()
 		delete(globals.volumeMap, volumeName)
 	}
@@ -267,6 +378,7 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 						FLockMap:                 make(map[inode.InodeNumber]*list.List),
 						inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
 						mountList:                make([]MountID, 0),
+						snapshotVolumeMap:        make(map[string]*volumeStruct),
 					}
 
 					replayLogFileName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogFileName")
@@ -288,11 +400,56 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 						return
 					}
 
+					volume.volumeSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "VolumeSize")
+					if nil != err {
+						volume.volumeSize = VolumeSizeDefault
+					}
+
+					volume.maxInodes, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxInodes")
+					if nil != err {
+						volume.maxInodes = MaxInodesDefault
+					}
+
 					volume.VolumeHandle, err = inode.FetchVolumeHandle(volumeName)
 					if nil != err {
 						return
 					}
 
+					err = volume.flockUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.leaseUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.watchUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.journalUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.quotaUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.trashUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
+					err = volume.defragUp(confMap, volumeSectionName)
+					if nil != err {
+						return
+					}
+
 					globals.volumeMap[volumeName] = volume
 				}
 			}
@@ -316,7 +473,18 @@ func Down() (err error) {
 	swiftclient.SetStarvationCallbackFunc(nil)
 
 	for _, volume = range globals.volumeMap {
+		volume.defragDown()
+		volume.watchDown()
+		volume.journalDown()
+		volume.trashDown()
+		volume.leaseDown()
+		volume.flockDown()
 		volume.untrackInFlightFileInodeDataAll()
+		for _, snapshotVolume := range volume.snapshotVolumeMap {
+			snapshotVolume.watchDown()
+			snapshotVolume.leaseDown()
+			snapshotVolume.flockDown()
+		}
 	}
 
 	if 0 < globals.inFlightFileInodeDataList.Len() {
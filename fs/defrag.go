@@ -0,0 +1,652 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+const (
+	defragDefaultMaxInodesPerPass        = uint64(16)
+	defragDefaultMaxInodesScannedPerPass = uint64(1024)
+	defragDefaultMaxDurationPerInode     = time.Second
+
+	defragDirEntriesPerReadDir = uint64(64)
+)
+
+type defragCandidateStruct struct {
+	inodeNumber         inode.InodeNumber
+	fragmentationReport inode.FragmentationReport
+}
+
+// defragCursorFrameStruct records how far the scan of a directory on the path from the root
+// to where the prior pass stopped had progressed
+type defragCursorFrameStruct struct {
+	dirInodeNumber inode.InodeNumber
+	prevBasename   string // Last Basename examined (or "" if none yet)
+}
+
+// defragStruct holds the configuration and state of a volume's background defragmentation daemon
+//
+// The daemon wakes up every interval and, if not paused and within quiet hours (if configured),
+// continues its walk of the volume's directory tree collecting a FragmentationReport for each of
+// the next maxInodesScannedPerPass FileInodes (or all of them if zero). The walk resumes at cursor,
+// starting over at the root once the prior walk has completed. Those FileInodes with more than one
+// fragment are ranked by BytesTrapped (then NumberOfFragments) and the worst maxInodesPerPass of
+// them are passed to Optimize() with a budget of maxDurationPerInode.
+//
+// If maxBytesPerSecond is non-zero, the daemon sleeps after each Optimize() call such that the
+// bytes it rewrote would not have exceeded that rate.
+type defragStruct struct {
+	sync.Mutex
+	volStruct               *volumeStruct
+	interval                time.Duration
+	maxInodesPerPass        uint64
+	maxInodesScannedPerPass uint64
+	maxDurationPerInode     time.Duration
+	maxBytesPerSecond       uint64
+	quietHoursEnabled       bool
+	quietHoursStart         time.Duration // Offset from local midnight
+	quietHoursEnd           time.Duration // Offset from local midnight (may be less than quietHoursStart)
+	paused                  bool
+	passActive              bool
+	cursor                  []defragCursorFrameStruct // Where the next pass resumes its walk (empty to start over at the root)
+	status                  DefragJobStatus
+	stopChan                chan bool
+	resumeChan              chan bool
+	wg                      sync.WaitGroup
+}
+
+func fetchVolumeStruct(volumeName string) (volStruct *volumeStruct, err error) {
+	var (
+		ok bool
+	)
+
+	globals.Lock()
+	volStruct, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("fs.fetchVolumeStruct(%v) requested for unknown volume", volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	err = nil
+	return
+}
+
+func getFragmentationReport(volumeName string, inodeNumber inode.InodeNumber) (fragmentationReport inode.FragmentationReport, err error) {
+	volStruct, err := fetchVolumeStruct(volumeName)
+	if nil != err {
+		return
+	}
+
+	fragmentationReport, err = volStruct.getFragmentationReport(inodeNumber)
+
+	return
+}
+
+func (vS *volumeStruct) getFragmentationReport(inodeNumber inode.InodeNumber) (fragmentationReport inode.FragmentationReport, err error) {
+	vS.validateVolumeRWMutex.RLock()
+	defer vS.validateVolumeRWMutex.RUnlock()
+
+	inodeLock, err := vS.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	fragmentationReport, err = vS.VolumeHandle.GetFragmentationReport(inodeNumber)
+
+	return
+}
+
+func (vS *volumeStruct) optimize(inodeNumber inode.InodeNumber, maxDuration time.Duration) (bytesOptimized uint64, err error) {
+	vS.validateVolumeRWMutex.RLock()
+	defer vS.validateVolumeRWMutex.RUnlock()
+
+	inodeLock, err := vS.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.WriteLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	bytesOptimized, err = vS.VolumeHandle.Optimize(inodeNumber, maxDuration)
+
+	return
+}
+
+// readDir returns up to maxEntries of the directory entries following prevBasename (or from the start if "")
+func (vS *volumeStruct) readDir(dirInodeNumber inode.InodeNumber, prevBasename string, maxEntries uint64) (dirEntrySlice []inode.DirEntry, err error) {
+	vS.validateVolumeRWMutex.RLock()
+	defer vS.validateVolumeRWMutex.RUnlock()
+
+	inodeLock, err := vS.initInodeLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if "" == prevBasename {
+		dirEntrySlice, _, err = vS.VolumeHandle.ReadDir(dirInodeNumber, maxEntries, 0)
+	} else {
+		dirEntrySlice, _, err = vS.VolumeHandle.ReadDir(dirInodeNumber, maxEntries, 0, prevBasename)
+	}
+
+	return
+}
+
+// parseQuietHours converts a "HH:MM-HH:MM" string into offsets from midnight
+func parseQuietHours(quietHours string) (start time.Duration, end time.Duration, err error) {
+	var (
+		endTime       time.Time
+		quietHoursSet []string
+		startTime     time.Time
+	)
+
+	quietHoursSet = strings.Split(quietHours, "-")
+	if 2 != len(quietHoursSet) {
+		err = fmt.Errorf("DefragQuietHours \"%v\" must be of the form HH:MM-HH:MM", quietHours)
+		return
+	}
+
+	startTime, err = time.Parse("15:04", strings.TrimSpace(quietHoursSet[0]))
+	if nil != err {
+		err = fmt.Errorf("DefragQuietHours \"%v\" has an invalid start time: %v", quietHours, err)
+		return
+	}
+	endTime, err = time.Parse("15:04", strings.TrimSpace(quietHoursSet[1]))
+	if nil != err {
+		err = fmt.Errorf("DefragQuietHours \"%v\" has an invalid end time: %v", quietHours, err)
+		return
+	}
+
+	start = time.Duration(startTime.Hour())*time.Hour + time.Duration(startTime.Minute())*time.Minute
+	end = time.Duration(endTime.Hour())*time.Hour + time.Duration(endTime.Minute())*time.Minute
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) defragUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	var (
+		defrag     *defragStruct
+		interval   time.Duration
+		quietHours string
+	)
+
+	interval, err = confMap.FetchOptionValueDuration(volumeSectionName, "DefragInterval")
+	if (nil != err) || (time.Duration(0) == interval) {
+		// Background defragmentation not enabled for this volume
+		err = nil
+		return
+	}
+
+	defrag = &defragStruct{
+		volStruct:  vS,
+		interval:   interval,
+		paused:     false,
+		stopChan:   make(chan bool, 1),
+		resumeChan: make(chan bool, 1),
+	}
+
+	defrag.maxInodesPerPass, err = confMap.FetchOptionValueUint64(volumeSectionName, "DefragMaxInodesPerPass")
+	if nil != err {
+		defrag.maxInodesPerPass = defragDefaultMaxInodesPerPass
+	}
+
+	defrag.maxInodesScannedPerPass, err = confMap.FetchOptionValueUint64(volumeSectionName, "DefragMaxInodesScannedPerPass")
+	if nil != err {
+		defrag.maxInodesScannedPerPass = defragDefaultMaxInodesScannedPerPass
+	}
+
+	defrag.maxDurationPerInode, err = confMap.FetchOptionValueDuration(volumeSectionName, "DefragMaxDurationPerInode")
+	if nil != err {
+		defrag.maxDurationPerInode = defragDefaultMaxDurationPerInode
+	}
+
+	defrag.maxBytesPerSecond, err = confMap.FetchOptionValueUint64(volumeSectionName, "DefragMaxBytesPerSecond")
+	if nil != err {
+		defrag.maxBytesPerSecond = 0 // Unlimited
+	}
+
+	quietHours, err = confMap.FetchOptionValueString(volumeSectionName, "DefragQuietHours")
+	if (nil == err) && ("" != quietHours) {
+		defrag.quietHoursStart, defrag.quietHoursEnd, err = parseQuietHours(quietHours)
+		if nil != err {
+			err = fmt.Errorf("Volume %v: %v", vS.volumeName, err)
+			return
+		}
+		defrag.quietHoursEnabled = true
+	} else {
+		defrag.quietHoursEnabled = false
+	}
+
+	defrag.status.State = DefragJobIdle
+
+	logger.Infof("Background defragmentation for volume %v enabled with DefragInterval %v", vS.volumeName, defrag.interval)
+
+	vS.dataMutex.Lock()
+	vS.defrag = defrag
+	vS.dataMutex.Unlock()
+
+	defrag.wg.Add(1)
+	go defrag.daemon()
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) defragDown() {
+	vS.dataMutex.Lock()
+	defrag := vS.defrag
+	vS.defrag = nil
+	vS.dataMutex.Unlock()
+
+	if nil == defrag {
+		return
+	}
+
+	defrag.stopChan <- true
+	defrag.wg.Wait()
+}
+
+// inQuietHours reports whether the daemon may run at the specified time (always true if DefragQuietHours not set)
+func (defrag *defragStruct) inQuietHours(now time.Time) (inQuietHours bool) {
+	var (
+		sinceMidnight time.Duration
+	)
+
+	if !defrag.quietHoursEnabled {
+		inQuietHours = true
+		return
+	}
+
+	sinceMidnight = time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+
+	if defrag.quietHoursStart <= defrag.quietHoursEnd {
+		inQuietHours = (defrag.quietHoursStart <= sinceMidnight) && (sinceMidnight < defrag.quietHoursEnd)
+	} else {
+		// Quiet hours span midnight
+		inQuietHours = (defrag.quietHoursStart <= sinceMidnight) || (sinceMidnight < defrag.quietHoursEnd)
+	}
+
+	return
+}
+
+func (defrag *defragStruct) daemon() {
+	var (
+		paused  bool
+		stopped bool
+	)
+
+	defer defrag.wg.Done()
+
+	for {
+		select {
+		case _ = <-defrag.stopChan:
+			return
+		case _ = <-time.After(defrag.interval):
+			defrag.Lock()
+			paused = defrag.paused
+			defrag.Unlock()
+
+			if paused || !defrag.inQuietHours(time.Now()) {
+				continue
+			}
+
+			stopped = defrag.pass()
+			if stopped {
+				return
+			}
+		}
+	}
+}
+
+// honorControls is called between steps of a pass to honor stopChan, pauses, and the end of quiet hours
+func (defrag *defragStruct) honorControls() (stopped bool, abandoned bool) {
+	var (
+		paused bool
+	)
+
+	for {
+		select {
+		case _ = <-defrag.stopChan:
+			stopped = true
+			abandoned = true
+			return
+		default:
+		}
+
+		defrag.Lock()
+		paused = defrag.paused
+		defrag.Unlock()
+
+		if !paused {
+			break
+		}
+
+		select {
+		case _ = <-defrag.stopChan:
+			stopped = true
+			abandoned = true
+			return
+		case _ = <-defrag.resumeChan:
+		}
+	}
+
+	stopped = false
+	abandoned = !defrag.inQuietHours(time.Now())
+
+	return
+}
+
+// pass continues the scan of the volume followed by the optimization of the worst offenders found
+func (defrag *defragStruct) pass() (stopped bool) {
+	var (
+		abandoned           bool
+		budgetedDuration    time.Duration
+		bytesOptimized      uint64
+		candidate           defragCandidateStruct
+		candidateMap        map[inode.InodeNumber]inode.FragmentationReport
+		candidates          []defragCandidateStruct
+		err                 error
+		fragmentationReport inode.FragmentationReport
+		inodeNumber         inode.InodeNumber
+		optimizeDuration    time.Duration
+		optimizeStart       time.Time
+	)
+
+	stats.IncrementOperations(&stats.FsDefragPassOps)
+
+	defrag.Lock()
+	defrag.passActive = true
+	if !defrag.paused {
+		defrag.status.State = DefragJobRunning
+	}
+	defrag.status.PassStartTime = time.Now()
+	defrag.status.PassEndTime = time.Time{}
+	defrag.status.InodesScanned = 0
+	defrag.status.InodesOptimized = 0
+	defrag.status.BytesOptimized = 0
+	defrag.status.CurrentInodeNumber = 0
+	defrag.Unlock()
+
+	defer func() {
+		defrag.Lock()
+		defrag.passActive = false
+		if !defrag.paused {
+			defrag.status.State = DefragJobIdle
+		}
+		defrag.status.CurrentInodeNumber = 0
+		if !abandoned {
+			defrag.status.PassEndTime = time.Now()
+			defrag.status.PassesCompleted++
+		}
+		defrag.Unlock()
+	}()
+
+	candidateMap = make(map[inode.InodeNumber]inode.FragmentationReport)
+
+	stopped, abandoned = defrag.scan(candidateMap)
+	if stopped || abandoned {
+		return
+	}
+
+	candidates = make([]defragCandidateStruct, 0, len(candidateMap))
+
+	for inodeNumber, fragmentationReport = range candidateMap {
+		candidates = append(candidates, defragCandidateStruct{inodeNumber: inodeNumber, fragmentationReport: fragmentationReport})
+	}
+
+	// Worst offenders first: most BytesTrapped, then most NumberOfFragments
+
+	sort.Slice(candidates, func(i int, j int) bool {
+		if candidates[i].fragmentationReport.BytesTrapped != candidates[j].fragmentationReport.BytesTrapped {
+			return candidates[i].fragmentationReport.BytesTrapped > candidates[j].fragmentationReport.BytesTrapped
+		}
+		return candidates[i].fragmentationReport.NumberOfFragments > candidates[j].fragmentationReport.NumberOfFragments
+	})
+
+	if uint64(len(candidates)) > defrag.maxInodesPerPass {
+		candidates = candidates[:defrag.maxInodesPerPass]
+	}
+
+	for _, candidate = range candidates {
+		stopped, abandoned = defrag.honorControls()
+		if stopped || abandoned {
+			return
+		}
+
+		defrag.Lock()
+		defrag.status.CurrentInodeNumber = candidate.inodeNumber
+		defrag.Unlock()
+
+		optimizeStart = time.Now()
+
+		stats.IncrementOperations(&stats.FsDefragOptimizeOps)
+
+		bytesOptimized, err = defrag.volStruct.optimize(candidate.inodeNumber, defrag.maxDurationPerInode)
+		if nil != err {
+			// FileInode may have been removed since the scan... just move on to the next one
+			logger.WarnfWithError(err, "Defragmentation of volume %v inode %v failed", defrag.volStruct.volumeName, candidate.inodeNumber)
+			continue
+		}
+
+		defrag.Lock()
+		defrag.status.InodesOptimized++
+		defrag.status.BytesOptimized += bytesOptimized
+		defrag.Unlock()
+
+		if 0 != defrag.maxBytesPerSecond {
+			optimizeDuration = time.Since(optimizeStart)
+			budgetedDuration = time.Duration(bytesOptimized) * time.Second / time.Duration(defrag.maxBytesPerSecond)
+			if budgetedDuration > optimizeDuration {
+				select {
+				case _ = <-defrag.stopChan:
+					stopped = true
+					abandoned = true
+					return
+				case _ = <-time.After(budgetedDuration - optimizeDuration):
+				}
+			}
+		}
+	}
+
+	stopped = false
+	abandoned = false
+	return
+}
+
+// scan resumes the walk of the volume's directory tree at cursor, adding each FileInode with more than one
+// fragment to candidateMap, until either maxInodesScannedPerPass FileInodes have been examined or the walk
+// has completed (leaving cursor empty such that the next scan starts over at the root)
+func (defrag *defragStruct) scan(candidateMap map[inode.InodeNumber]inode.FragmentationReport) (stopped bool, abandoned bool) {
+	var (
+		descended           bool
+		dirEntry            inode.DirEntry
+		dirEntrySlice       []inode.DirEntry
+		err                 error
+		fragmentationReport inode.FragmentationReport
+		frame               *defragCursorFrameStruct
+		inodeType           inode.InodeType
+		inodesScanned       uint64
+	)
+
+	if 0 == len(defrag.cursor) {
+		defrag.cursor = []defragCursorFrameStruct{{dirInodeNumber: inode.RootDirInodeNumber, prevBasename: ""}}
+	}
+
+	inodesScanned = 0
+
+	for (0 < len(defrag.cursor)) && ((0 == defrag.maxInodesScannedPerPass) || (inodesScanned < defrag.maxInodesScannedPerPass)) {
+		frame = &defrag.cursor[len(defrag.cursor)-1]
+
+		dirEntrySlice, err = defrag.volStruct.readDir(frame.dirInodeNumber, frame.prevBasename, defragDirEntriesPerReadDir)
+		if (nil != err) || (0 == len(dirEntrySlice)) {
+			// Directory exhausted (or removed since we descended into it)... so resume the walk of its parent
+			defrag.cursor = defrag.cursor[:len(defrag.cursor)-1]
+			continue
+		}
+
+		descended = false
+
+		for _, dirEntry = range dirEntrySlice {
+			frame.prevBasename = dirEntry.Basename
+
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			stopped, abandoned = defrag.honorControls()
+			if stopped || abandoned {
+				return
+			}
+
+			inodeType, err = defrag.volStruct.VolumeHandle.GetType(dirEntry.InodeNumber)
+			if nil != err {
+				// Directory entry may have been removed since ReadDir()
+				continue
+			}
+
+			switch inodeType {
+			case inode.DirType:
+				// Note: frame is invalidated by the append... so the remaining dirEntrySlice is re-read upon return
+				defrag.cursor = append(defrag.cursor, defragCursorFrameStruct{dirInodeNumber: dirEntry.InodeNumber, prevBasename: ""})
+				descended = true
+			case inode.FileType:
+				fragmentationReport, err = defrag.volStruct.getFragmentationReport(dirEntry.InodeNumber)
+				if nil != err {
+					continue
+				}
+
+				defrag.Lock()
+				defrag.status.InodesScanned++
+				defrag.Unlock()
+
+				inodesScanned++
+
+				if 1 < fragmentationReport.NumberOfFragments {
+					// Note: A hard linked FileInode merely replaces its prior candidateMap entry
+					candidateMap[dirEntry.InodeNumber] = fragmentationReport
+				}
+			default:
+				// Nothing to defragment in a SymlinkInode
+			}
+
+			if descended || ((0 != defrag.maxInodesScannedPerPass) && (inodesScanned >= defrag.maxInodesScannedPerPass)) {
+				break
+			}
+		}
+	}
+
+	stopped = false
+	abandoned = false
+	return
+}
+
+func fetchDefragJobStatus(volumeName string) (defragJobStatus DefragJobStatus, err error) {
+	volStruct, err := fetchVolumeStruct(volumeName)
+	if nil != err {
+		return
+	}
+
+	volStruct.dataMutex.Lock()
+	defrag := volStruct.defrag
+	volStruct.dataMutex.Unlock()
+
+	if nil == defrag {
+		defragJobStatus.State = DefragJobDisabled
+		err = nil
+		return
+	}
+
+	defrag.Lock()
+	defragJobStatus = defrag.status
+	defrag.Unlock()
+
+	err = nil
+	return
+}
+
+func pauseDefragJob(volumeName string) (err error) {
+	volStruct, err := fetchVolumeStruct(volumeName)
+	if nil != err {
+		return
+	}
+
+	volStruct.dataMutex.Lock()
+	defrag := volStruct.defrag
+	volStruct.dataMutex.Unlock()
+
+	if nil == defrag {
+		err = fmt.Errorf("fs.pauseDefragJob(%v) requested for volume without background defragmentation", volumeName)
+		err = blunder.AddError(err, blunder.NotSupportedError)
+		return
+	}
+
+	defrag.Lock()
+	defrag.paused = true
+	defrag.status.State = DefragJobPaused
+	defrag.Unlock()
+
+	err = nil
+	return
+}
+
+func resumeDefragJob(volumeName string) (err error) {
+	volStruct, err := fetchVolumeStruct(volumeName)
+	if nil != err {
+		return
+	}
+
+	volStruct.dataMutex.Lock()
+	defrag := volStruct.defrag
+	volStruct.dataMutex.Unlock()
+
+	if nil == defrag {
+		err = fmt.Errorf("fs.resumeDefragJob(%v) requested for volume without background defragmentation", volumeName)
+		err = blunder.AddError(err, blunder.NotSupportedError)
+		return
+	}
+
+	defrag.Lock()
+	if defrag.paused {
+		defrag.paused = false
+		if defrag.passActive {
+			defrag.status.State = DefragJobRunning
+		} else {
+			defrag.status.State = DefragJobIdle
+		}
+		select {
+		case defrag.resumeChan <- true:
+		default:
+			// A prior resume is still pending
+		}
+	}
+	defrag.Unlock()
+
+	err = nil
+	return
+}
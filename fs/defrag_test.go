@@ -0,0 +1,252 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	start, end, err := parseQuietHours("01:30-05:00")
+	if nil != err {
+		t.Fatalf("parseQuietHours(\"01:30-05:00\") failed: %v", err)
+	}
+	if ((90 * time.Minute) != start) || ((5 * time.Hour) != end) {
+		t.Fatalf("parseQuietHours(\"01:30-05:00\") returned unexpected start %v end %v", start, end)
+	}
+
+	defrag := &defragStruct{
+		quietHoursEnabled: true,
+		quietHoursStart:   start,
+		quietHoursEnd:     end,
+	}
+
+	if !defrag.inQuietHours(time.Date(2017, time.January, 1, 3, 0, 0, 0, time.Local)) {
+		t.Fatalf("03:00 should be within quiet hours 01:30-05:00")
+	}
+	if defrag.inQuietHours(time.Date(2017, time.January, 1, 5, 0, 0, 0, time.Local)) {
+		t.Fatalf("05:00 should not be within quiet hours 01:30-05:00")
+	}
+
+	start, end, err = parseQuietHours("22:00-06:00")
+	if nil != err {
+		t.Fatalf("parseQuietHours(\"22:00-06:00\") failed: %v", err)
+	}
+
+	defrag.quietHoursStart = start
+	defrag.quietHoursEnd = end
+
+	if !defrag.inQuietHours(time.Date(2017, time.January, 1, 23, 0, 0, 0, time.Local)) {
+		t.Fatalf("23:00 should be within quiet hours 22:00-06:00")
+	}
+	if !defrag.inQuietHours(time.Date(2017, time.January, 1, 1, 0, 0, 0, time.Local)) {
+		t.Fatalf("01:00 should be within quiet hours 22:00-06:00")
+	}
+	if defrag.inQuietHours(time.Date(2017, time.January, 1, 12, 0, 0, 0, time.Local)) {
+		t.Fatalf("12:00 should not be within quiet hours 22:00-06:00")
+	}
+
+	defrag.quietHoursEnabled = false
+
+	if !defrag.inQuietHours(time.Date(2017, time.January, 1, 12, 0, 0, 0, time.Local)) {
+		t.Fatalf("Any time should be allowed without quiet hours")
+	}
+
+	for _, badQuietHours := range []string{"", "01:00", "01:00-", "1am-5am", "01:00-05:00-07:00"} {
+		_, _, err = parseQuietHours(badQuietHours)
+		if nil == err {
+			t.Fatalf("parseQuietHours(\"%v\") should have failed", badQuietHours)
+		}
+	}
+}
+
+func TestDefragPass(t *testing.T) {
+	defragJobStatus, err := FetchDefragJobStatus("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchDefragJobStatus(\"TestVolume\") failed: %v", err)
+	}
+	if DefragJobDisabled != defragJobStatus.State {
+		t.Fatalf("TestVolume should not have background defragmentation enabled")
+	}
+
+	err = PauseDefragJob("TestVolume")
+	if !blunder.Is(err, blunder.NotSupportedError) {
+		t.Fatalf("PauseDefragJob(\"TestVolume\") should have failed with NotSupportedError: %v", err)
+	}
+
+	_, err = FetchDefragJobStatus("NoSuchVolume")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchDefragJobStatus(\"NoSuchVolume\") should have failed with NotFoundError: %v", err)
+	}
+
+	dirInodeNumber := createTestDirectory(t, "DefragPass")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "FragmentedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	for fileOffset := uint64(0); fileOffset < 16; fileOffset += 4 {
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, fileOffset, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+		if nil != err {
+			t.Fatalf("Write(,,,,%v,,) failed: %v", fileOffset, err)
+		}
+		err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+		if nil != err {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+	}
+
+	fragmentationReport, err := GetFragmentationReport("TestVolume", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if 4 != fragmentationReport.NumberOfFragments {
+		t.Fatalf("GetFragmentationReport() before pass returned unexpected %+v", fragmentationReport)
+	}
+
+	defrag := &defragStruct{
+		volStruct:           mS.volStruct,
+		maxInodesPerPass:    defragDefaultMaxInodesPerPass,
+		maxDurationPerInode: time.Minute,
+		stopChan:            make(chan bool, 1),
+		resumeChan:          make(chan bool, 1),
+	}
+
+	stopped := defrag.pass()
+	if stopped {
+		t.Fatalf("defrag.pass() unexpectedly reported being stopped")
+	}
+
+	if (1 != defrag.status.PassesCompleted) || (0 == defrag.status.InodesScanned) || (0 == defrag.status.InodesOptimized) {
+		t.Fatalf("defrag.pass() left unexpected status %+v", defrag.status)
+	}
+	if DefragJobIdle != defrag.status.State {
+		t.Fatalf("defrag.pass() should have left State == DefragJobIdle")
+	}
+
+	fragmentationReport, err = GetFragmentationReport("TestVolume", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if (1 != fragmentationReport.NumberOfFragments) || (16 != fragmentationReport.BytesInFragments) {
+		t.Fatalf("GetFragmentationReport() after pass returned unexpected %+v", fragmentationReport)
+	}
+
+	// A stopped daemon should abandon its pass
+
+	defrag.stopChan <- true
+
+	stopped = defrag.pass()
+	if !stopped {
+		t.Fatalf("defrag.pass() should have reported being stopped")
+	}
+	if 1 != defrag.status.PassesCompleted {
+		t.Fatalf("defrag.pass() that was stopped should not have counted as completed")
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "FragmentedFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "DefragPass")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
+
+func TestDefragPassResume(t *testing.T) {
+	dirInodeNumber := createTestDirectory(t, "DefragPassResume")
+
+	fileInodeNumbers := make([]inode.InodeNumber, 0, 2)
+
+	for _, basename := range []string{"FragmentedFileA", "FragmentedFileB"} {
+		fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create(,,,,\"%v\",) failed: %v", basename, err)
+		}
+		for fileOffset := uint64(0); fileOffset < 16; fileOffset += 4 {
+			_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, fileOffset, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+			if nil != err {
+				t.Fatalf("Write(,,,,%v,,) failed: %v", fileOffset, err)
+			}
+			err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+			if nil != err {
+				t.Fatalf("Flush() failed: %v", err)
+			}
+		}
+		// Following a hole, append a run that Optimize() need not rewrite
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 32, make([]byte, 16), nil)
+		if nil != err {
+			t.Fatalf("Write(,,,,32,,) failed: %v", err)
+		}
+		err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+		if nil != err {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+		fileInodeNumbers = append(fileInodeNumbers, fileInodeNumber)
+	}
+
+	// Position the cursor as if the prior pass had just descended into DefragPassResume
+
+	defrag := &defragStruct{
+		volStruct:               mS.volStruct,
+		maxInodesPerPass:        defragDefaultMaxInodesPerPass,
+		maxInodesScannedPerPass: 1,
+		maxDurationPerInode:     time.Minute,
+		cursor: []defragCursorFrameStruct{
+			{dirInodeNumber: inode.RootDirInodeNumber, prevBasename: "DefragPassResume"},
+			{dirInodeNumber: dirInodeNumber, prevBasename: ""},
+		},
+		stopChan:   make(chan bool, 1),
+		resumeChan: make(chan bool, 1),
+	}
+
+	// Each pass should examine (and optimize) just the next FileInode
+
+	for passIndex, fileInodeNumber := range fileInodeNumbers {
+		stopped := defrag.pass()
+		if stopped {
+			t.Fatalf("defrag.pass() [%v] unexpectedly reported being stopped", passIndex)
+		}
+		if (1 != defrag.status.InodesScanned) || (1 != defrag.status.InodesOptimized) || (16 != defrag.status.BytesOptimized) {
+			t.Fatalf("defrag.pass() [%v] left unexpected status %+v", passIndex, defrag.status)
+		}
+		if (2 != len(defrag.cursor)) || (dirInodeNumber != defrag.cursor[1].dirInodeNumber) {
+			t.Fatalf("defrag.pass() [%v] left unexpected cursor %+v", passIndex, defrag.cursor)
+		}
+
+		fragmentationReport, err := GetFragmentationReport("TestVolume", fileInodeNumber)
+		if nil != err {
+			t.Fatalf("GetFragmentationReport() failed: %v", err)
+		}
+		if (2 != fragmentationReport.NumberOfFragments) || (32 != fragmentationReport.BytesInFragments) {
+			t.Fatalf("GetFragmentationReport() after pass [%v] returned unexpected %+v", passIndex, fragmentationReport)
+		}
+	}
+
+	// An unlimited pass should complete the walk and leave the cursor to start over at the root
+
+	defrag.maxInodesScannedPerPass = 0
+
+	stopped := defrag.pass()
+	if stopped {
+		t.Fatalf("defrag.pass() unexpectedly reported being stopped")
+	}
+	if 0 != len(defrag.cursor) {
+		t.Fatalf("defrag.pass() that completed its walk left unexpected cursor %+v", defrag.cursor)
+	}
+
+	for _, basename := range []string{"FragmentedFileA", "FragmentedFileB"} {
+		err := mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink(,,,,\"%v\") failed: %v", basename, err)
+		}
+	}
+	err := mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "DefragPassResume")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
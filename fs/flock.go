@@ -0,0 +1,400 @@
+package fs
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// POSIX byte-range locks are maintained per volume in FLockMap (one list per FileInode, sorted by Start).
+//
+// Since all clients of a volume are served by the single peer on which the volume is active, the lock
+// table is naturally cluster-wide. If FlockPersistence is enabled for the volume, each FileInode's lock
+// list is also recorded in its FlockStream such that, should the volume fail over to another peer, the
+// locks survive. Locks so recovered are held (with a MountID of zero) for FlockRecoveryGracePeriod
+// during which their owner (matched by ClientHost and Pid) may reclaim them. Any not reclaimed by then
+// are discarded.
+
+const (
+	flockDefaultRecoveryGracePeriod = 90 * time.Second
+)
+
+// flockWaiterStruct tracks an F_SETLKW caller blocked waiting for a conflicting lock to be dropped
+type flockWaiterStruct struct {
+	mountID   MountID
+	clientID  string
+	cancelled bool
+}
+
+func sameFlockOwner(a *FlockStruct, b *FlockStruct) bool {
+	return (a.MountID == b.MountID) && (a.ClientID == b.ClientID) && (a.Pid == b.Pid)
+}
+
+// sameFlockReclaimer reports whether recovered lock a may be reclaimed by the owner of b
+//
+// As a reclaim arrives via a different client connection than the one via which the lock was obtained,
+// ClientHost (if recorded) rather than ClientID is matched
+func sameFlockReclaimer(a *FlockStruct, b *FlockStruct) bool {
+	if ("" != a.ClientHost) || ("" != b.ClientHost) {
+		return (a.ClientHost == b.ClientHost) && (a.Pid == b.Pid)
+	}
+	return (a.ClientID == b.ClientID) && (a.Pid == b.Pid)
+}
+
+// ownsOverlappingWriteLock reports whether inFlock's owner holds a write lock overlapping inFlock's range
+// (such that inserting inFlock as a read lock downgrades it)
+func ownsOverlappingWriteLock(flockList *list.List, inFlock *FlockStruct) bool {
+	for e := flockList.Front(); e != nil; e = e.Next() {
+		elm := e.Value.(*FlockStruct)
+		if (syscall.F_WRLCK == elm.Type) && sameFlockOwner(elm, inFlock) &&
+			(elm.Start < flockEnd(inFlock)) && (inFlock.Start < flockEnd(elm)) {
+			return true
+		}
+	}
+	return false
+}
+
+// flockEnd returns the offset just beyond the range of flock (saturating for whole file locks)
+func flockEnd(flock *FlockStruct) uint64 {
+	if flock.Len > (^uint64(0) - flock.Start) {
+		return ^uint64(0)
+	}
+	return flock.Start + flock.Len
+}
+
+func (vS *volumeStruct) flockUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	var (
+		recoveryGracePeriod time.Duration
+	)
+
+	vS.flockCond = sync.NewCond(&vS.flockMutex)
+	vS.flockGeneration = 0
+	vS.flockWaiterMap = make(map[*flockWaiterStruct]struct{})
+	vS.flockHalting = false
+
+	vS.flockPersistence, err = confMap.FetchOptionValueBool(volumeSectionName, "FlockPersistence")
+	if nil != err {
+		vS.flockPersistence = false
+	}
+
+	if vS.flockPersistence {
+		recoveryGracePeriod, err = confMap.FetchOptionValueDuration(volumeSectionName, "FlockRecoveryGracePeriod")
+		if nil != err {
+			recoveryGracePeriod = flockDefaultRecoveryGracePeriod
+		}
+
+		vS.flockRecoveryDeadline = time.Now().Add(recoveryGracePeriod)
+		vS.flockRecoveryTimer = time.AfterFunc(recoveryGracePeriod, vS.flockRecoveryGracePeriodExpired)
+
+		logger.Infof("Flock persistence for volume %v enabled with FlockRecoveryGracePeriod %v", vS.volumeName, recoveryGracePeriod)
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) flockDown() {
+	vS.flockMutex.Lock()
+	defer vS.flockMutex.Unlock()
+
+	vS.flockHalting = true
+
+	if nil != vS.flockRecoveryTimer {
+		_ = vS.flockRecoveryTimer.Stop()
+		vS.flockRecoveryTimer = nil
+	}
+
+	for waiter := range vS.flockWaiterMap {
+		waiter.cancelled = true
+	}
+
+	vS.flockCond.Broadcast()
+}
+
+func (vS *volumeStruct) flockRecoveryGracePeriodExpired() {
+	vS.flockMutex.Lock()
+	defer vS.flockMutex.Unlock()
+
+	// Have any waiters retry... which will discard any unreclaimed recovered locks they encounter
+	vS.flockDroppedWhileLocked()
+}
+
+// flockDroppedWhileLocked wakes up any F_SETLKW waiters to retry
+//
+// Note: Caller must hold vS.flockMutex
+func (vS *volumeStruct) flockDroppedWhileLocked() {
+	vS.flockGeneration++
+	vS.flockCond.Broadcast()
+}
+
+func (vS *volumeStruct) registerFlockWaiter(mountID MountID, clientID string) (waiter *flockWaiterStruct) {
+	waiter = &flockWaiterStruct{
+		mountID:  mountID,
+		clientID: clientID,
+	}
+
+	vS.flockMutex.Lock()
+	waiter.cancelled = vS.flockHalting
+	vS.flockWaiterMap[waiter] = struct{}{}
+	vS.flockMutex.Unlock()
+
+	return
+}
+
+func (vS *volumeStruct) unregisterFlockWaiter(waiter *flockWaiterStruct) {
+	vS.flockMutex.Lock()
+	delete(vS.flockWaiterMap, waiter)
+	vS.flockMutex.Unlock()
+}
+
+// waitForFlockChange blocks until some lock has been dropped since flockGeneration was fetched (or the waiter is cancelled)
+func (vS *volumeStruct) waitForFlockChange(waiter *flockWaiterStruct, flockGeneration uint64) (err error) {
+	vS.flockMutex.Lock()
+	defer vS.flockMutex.Unlock()
+
+	for (flockGeneration == vS.flockGeneration) && !waiter.cancelled {
+		vS.flockCond.Wait()
+	}
+
+	if waiter.cancelled {
+		err = blunder.NewError(blunder.InterruptedError, "EINTR")
+		return
+	}
+
+	err = nil
+	return
+}
+
+// getFileLockListWhileLocked returns the lock list for inodeNumber (loading it from its FlockStream if necessary)
+//
+// Note: Caller must hold vS.flockMutex (as well as a lock on inodeNumber)
+func (vS *volumeStruct) getFileLockListWhileLocked(inodeNumber inode.InodeNumber) (flockList *list.List, err error) {
+	var (
+		buf             []byte
+		ok              bool
+		recoveredFlocks []FlockStruct
+	)
+
+	flockList, ok = vS.FLockMap[inodeNumber]
+	if ok {
+		err = nil
+		return
+	}
+
+	flockList = new(list.List)
+
+	if vS.flockPersistence {
+		buf, err = vS.VolumeHandle.GetStream(inodeNumber, FlockStream)
+		if nil == err {
+			err = json.Unmarshal(buf, &recoveredFlocks)
+			if nil == err {
+				for i := range recoveredFlocks {
+					recoveredFlock := recoveredFlocks[i]
+					recoveredFlock.MountID = MountID(0) // Awaiting reclaim by its owner
+					flockList.PushBack(&recoveredFlock)
+				}
+			} else {
+				logger.ErrorfWithError(err, "Discarding unparseable %v stream of inode %v", FlockStream, inodeNumber)
+			}
+		} else if !blunder.Is(err, blunder.StreamNotFound) {
+			return
+		}
+	}
+
+	vS.FLockMap[inodeNumber] = flockList
+
+	err = nil
+	return
+}
+
+// reclaimFlocksWhileLocked hands recovered locks of inFlock's owner back to it (or discards all
+// recovered locks if FlockRecoveryGracePeriod has elapsed), reporting if any were discarded
+//
+// Note: Caller must hold vS.flockMutex
+func (vS *volumeStruct) reclaimFlocksWhileLocked(flockList *list.List, inFlock *FlockStruct) (discarded bool) {
+	var (
+		nextElement *list.Element
+	)
+
+	discarded = false
+
+	if !vS.flockPersistence {
+		return
+	}
+
+	expired := time.Now().After(vS.flockRecoveryDeadline)
+
+	for e := flockList.Front(); e != nil; e = nextElement {
+		nextElement = e.Next()
+
+		elm := e.Value.(*FlockStruct)
+
+		if MountID(0) != elm.MountID {
+			continue
+		}
+
+		if expired {
+			flockList.Remove(e)
+			discarded = true
+			continue
+		}
+
+		if sameFlockReclaimer(elm, inFlock) {
+			elm.MountID = inFlock.MountID
+			elm.ClientID = inFlock.ClientID
+			stats.IncrementOperations(&stats.FsFlockReclaimOps)
+		}
+	}
+
+	if discarded {
+		vS.flockDroppedWhileLocked()
+	}
+
+	return
+}
+
+// persistFileLockListWhileLocked records the lock list for inodeNumber in its FlockStream
+//
+// Note: Caller must hold vS.flockMutex (as well as an exclusive lock on inodeNumber)
+func (vS *volumeStruct) persistFileLockListWhileLocked(inodeNumber inode.InodeNumber, flockList *list.List) (err error) {
+	var (
+		buf    []byte
+		flocks []FlockStruct
+	)
+
+	if 0 == flockList.Len() {
+		err = vS.VolumeHandle.DeleteStream(inodeNumber, FlockStream)
+		if blunder.Is(err, blunder.StreamNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	flocks = make([]FlockStruct, 0, flockList.Len())
+
+	for e := flockList.Front(); e != nil; e = e.Next() {
+		flocks = append(flocks, *e.Value.(*FlockStruct))
+	}
+
+	buf, err = json.Marshal(flocks)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inodeNumber, FlockStream, buf)
+
+	return
+}
+
+// ReleaseFlocks drops all locks held via this MountHandle by the client identified by clientID (or by
+// any client if clientID == "") and cancels any of its blocked F_SETLKW requests. It is called when
+// the client's connection or the mount itself goes away.
+func (mS *mountStruct) ReleaseFlocks(clientID string) (err error) {
+	var (
+		flockList    *list.List
+		inodeNumber  inode.InodeNumber
+		inodeNumbers []inode.InodeNumber
+	)
+
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	mS.volStruct.flockMutex.Lock()
+
+	for waiter := range mS.volStruct.flockWaiterMap {
+		if (waiter.mountID == mS.id) && (("" == clientID) || (waiter.clientID == clientID)) {
+			waiter.cancelled = true
+		}
+	}
+
+	mS.volStruct.flockCond.Broadcast()
+
+	inodeNumbers = make([]inode.InodeNumber, 0)
+
+	for inodeNumber, flockList = range mS.volStruct.FLockMap {
+		for e := flockList.Front(); e != nil; e = e.Next() {
+			if mS.ownsFlock(e.Value.(*FlockStruct), clientID) {
+				inodeNumbers = append(inodeNumbers, inodeNumber)
+				break
+			}
+		}
+	}
+
+	mS.volStruct.flockMutex.Unlock()
+
+	for _, inodeNumber = range inodeNumbers {
+		err = mS.releaseInodeFlocks(inodeNumber, clientID)
+		if nil != err {
+			logger.ErrorfWithError(err, "ReleaseFlocks() unable to release locks of inode %v", inodeNumber)
+		}
+	}
+
+	stats.IncrementOperations(&stats.FsFlockReleaseOps)
+
+	err = nil
+	return
+}
+
+func (mS *mountStruct) ownsFlock(flock *FlockStruct, clientID string) bool {
+	return (flock.MountID == mS.id) && (("" == clientID) || (flock.ClientID == clientID))
+}
+
+func (mS *mountStruct) releaseInodeFlocks(inodeNumber inode.InodeNumber, clientID string) (err error) {
+	var (
+		nextElement *list.Element
+		ok          bool
+		released    bool
+	)
+
+	inodeLock, err := mS.volStruct.initInodeLock(inodeNumber, nil)
+	if err != nil {
+		return
+	}
+	if mS.volStruct.flockPersistence {
+		err = inodeLock.WriteLock()
+	} else {
+		err = inodeLock.ReadLock()
+	}
+	if err != nil {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	mS.volStruct.flockMutex.Lock()
+	defer mS.volStruct.flockMutex.Unlock()
+
+	flockList, ok := mS.volStruct.FLockMap[inodeNumber]
+	if !ok {
+		return
+	}
+
+	released = false
+
+	for e := flockList.Front(); e != nil; e = nextElement {
+		nextElement = e.Next()
+		if mS.ownsFlock(e.Value.(*FlockStruct), clientID) {
+			flockList.Remove(e)
+			released = true
+		}
+	}
+
+	if !released {
+		return
+	}
+
+	mS.volStruct.flockDroppedWhileLocked()
+
+	if mS.volStruct.flockPersistence {
+		err = mS.volStruct.persistFileLockListWhileLocked(inodeNumber, flockList)
+	}
+
+	return
+}
@@ -0,0 +1,319 @@
+package fs
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func createTestLockFile(t *testing.T, basename string) (lockFileInodeNumber inode.InodeNumber) {
+	lockFileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename, inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() %v returned error: %v", basename, err)
+	}
+	return
+}
+
+func TestFlockClientOwnership(t *testing.T) {
+	basename := "TestFlockClientOwnership"
+	lockFileInodeNumber := createTestLockFile(t, basename)
+
+	// Same Pid but different clients should conflict
+
+	lockA := FlockStruct{Type: syscall.F_WRLCK, ClientID: "clientA", Pid: 1}
+	lockB := FlockStruct{Type: syscall.F_WRLCK, ClientID: "clientB", Pid: 1}
+
+	_, err := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &lockA)
+	if nil != err {
+		t.Fatalf("Write lock by clientA failed: %v", err)
+	}
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &lockB)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("Write lock by clientB with same Pid should have failed with EAGAIN: %v", err)
+	}
+
+	getLock := lockB
+	outLock, err := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_GETLK, &getLock)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("F_GETLK by clientB should have reported a conflict: %v", err)
+	}
+	if (syscall.F_WRLCK != outLock.Type) || ("clientA" != outLock.ClientID) || (mS.id != outLock.MountID) {
+		t.Fatalf("F_GETLK by clientB returned unexpected %+v", outLock)
+	}
+
+	// Releasing clientB's locks should leave clientA's lock in place
+
+	err = mS.ReleaseFlocks("clientB")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"clientB\") failed: %v", err)
+	}
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &lockB)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("Write lock by clientB should still have failed with EAGAIN: %v", err)
+	}
+
+	// Releasing clientA's locks should allow clientB to lock
+
+	err = mS.ReleaseFlocks("clientA")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"clientA\") failed: %v", err)
+	}
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &lockB)
+	if nil != err {
+		t.Fatalf("Write lock by clientB after ReleaseFlocks(\"clientA\") failed: %v", err)
+	}
+
+	lockB.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &lockB)
+	if nil != err {
+		t.Fatalf("Unlock by clientB failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename)
+	if nil != err {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
+
+func TestFlockWait(t *testing.T) {
+	basename := "TestFlockWait"
+	lockFileInodeNumber := createTestLockFile(t, basename)
+
+	holder := FlockStruct{Type: syscall.F_WRLCK, ClientID: "holder", Pid: 1}
+	waiter := FlockStruct{Type: syscall.F_WRLCK, ClientID: "waiter", Pid: 2}
+
+	_, err := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &holder)
+	if nil != err {
+		t.Fatalf("Write lock by holder failed: %v", err)
+	}
+
+	// An F_SETLKW should block until the conflicting lock is dropped
+
+	waitErrChan := make(chan error, 1)
+
+	go func() {
+		_, waitErr := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLKW, &waiter)
+		waitErrChan <- waitErr
+	}()
+
+	select {
+	case err = <-waitErrChan:
+		t.Fatalf("F_SETLKW by waiter should have blocked but returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	holder.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &holder)
+	if nil != err {
+		t.Fatalf("Unlock by holder failed: %v", err)
+	}
+
+	select {
+	case err = <-waitErrChan:
+		if nil != err {
+			t.Fatalf("F_SETLKW by waiter failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("F_SETLKW by waiter was not woken by holder's unlock")
+	}
+
+	// An F_SETLKW blocked behind waiter's lock should be interrupted by ReleaseFlocks() of its client
+
+	blocked := FlockStruct{Type: syscall.F_RDLCK, ClientID: "blocked", Pid: 3}
+
+	go func() {
+		_, waitErr := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLKW, &blocked)
+		waitErrChan <- waitErr
+	}()
+
+	select {
+	case err = <-waitErrChan:
+		t.Fatalf("F_SETLKW by blocked should have blocked but returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = mS.ReleaseFlocks("blocked")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"blocked\") failed: %v", err)
+	}
+
+	select {
+	case err = <-waitErrChan:
+		if !blunder.Is(err, blunder.InterruptedError) {
+			t.Fatalf("F_SETLKW by blocked should have failed with EINTR: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("F_SETLKW by blocked was not interrupted by ReleaseFlocks()")
+	}
+
+	err = mS.ReleaseFlocks("waiter")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"waiter\") failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename)
+	if nil != err {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
+
+func TestFlockDowngrade(t *testing.T) {
+	basename := "TestFlockDowngrade"
+	lockFileInodeNumber := createTestLockFile(t, basename)
+
+	holder := FlockStruct{Type: syscall.F_WRLCK, ClientID: "holder", Pid: 1}
+	reader := FlockStruct{Type: syscall.F_RDLCK, ClientID: "reader", Pid: 2}
+
+	_, err := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &holder)
+	if nil != err {
+		t.Fatalf("Write lock by holder failed: %v", err)
+	}
+
+	waitErrChan := make(chan error, 1)
+
+	go func() {
+		_, waitErr := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLKW, &reader)
+		waitErrChan <- waitErr
+	}()
+
+	select {
+	case err = <-waitErrChan:
+		t.Fatalf("F_SETLKW by reader should have blocked but returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Downgrading holder's write lock to a read lock should wake (and grant) the waiting reader
+
+	downgrade := FlockStruct{Type: syscall.F_RDLCK, ClientID: "holder", Pid: 1} // Not holder itself, which is now in the lock list
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &downgrade)
+	if nil != err {
+		t.Fatalf("Downgrade by holder failed: %v", err)
+	}
+
+	select {
+	case err = <-waitErrChan:
+		if nil != err {
+			t.Fatalf("F_SETLKW by reader failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("F_SETLKW by reader was not woken by holder's downgrade")
+	}
+
+	err = mS.ReleaseFlocks("holder")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"holder\") failed: %v", err)
+	}
+	err = mS.ReleaseFlocks("reader")
+	if nil != err {
+		t.Fatalf("ReleaseFlocks(\"reader\") failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename)
+	if nil != err {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
+
+func TestFlockPersistence(t *testing.T) {
+	basename := "TestFlockPersistence"
+	lockFileInodeNumber := createTestLockFile(t, basename)
+
+	vS := mS.volStruct
+
+	vS.flockMutex.Lock()
+	savedFlockPersistence := vS.flockPersistence
+	savedFlockRecoveryDeadline := vS.flockRecoveryDeadline
+	vS.flockPersistence = true
+	vS.flockRecoveryDeadline = time.Now().Add(time.Hour)
+	vS.flockMutex.Unlock()
+
+	defer func() {
+		vS.flockMutex.Lock()
+		vS.flockPersistence = savedFlockPersistence
+		vS.flockRecoveryDeadline = savedFlockRecoveryDeadline
+		vS.flockMutex.Unlock()
+	}()
+
+	// forgetFlocks simulates a failover by discarding the in-memory lock list
+	forgetFlocks := func() {
+		vS.flockMutex.Lock()
+		delete(vS.FLockMap, lockFileInodeNumber)
+		vS.flockMutex.Unlock()
+	}
+
+	owner := FlockStruct{Type: syscall.F_WRLCK, ClientID: "owner", ClientHost: "ownerHost", Pid: 1}
+	other := FlockStruct{Type: syscall.F_WRLCK, ClientID: "other", ClientHost: "otherHost", Pid: 1}
+
+	_, err := mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &owner)
+	if nil != err {
+		t.Fatalf("Write lock by owner failed: %v", err)
+	}
+
+	_, err = vS.VolumeHandle.GetStream(lockFileInodeNumber, FlockStream)
+	if nil != err {
+		t.Fatalf("Write lock by owner should have been persisted: %v", err)
+	}
+
+	forgetFlocks()
+
+	// A recovered lock should still conflict during the grace period... and be reclaimable by its owner (even
+	// via a new ClientID, as would be the case over a new connection from the same host)
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &other)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("Write lock by other should have conflicted with recovered lock: %v", err)
+	}
+
+	owner.ClientID = "ownerReconnected"
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &owner)
+	if nil != err {
+		t.Fatalf("Reclaim of recovered lock by owner failed: %v", err)
+	}
+
+	owner.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &owner)
+	if nil != err {
+		t.Fatalf("Unlock by owner failed: %v", err)
+	}
+
+	_, err = vS.VolumeHandle.GetStream(lockFileInodeNumber, FlockStream)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("Unlock of last lock should have removed %v stream: %v", FlockStream, err)
+	}
+
+	// A recovered lock not reclaimed before the grace period expires should be discarded
+
+	owner.Type = syscall.F_WRLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &owner)
+	if nil != err {
+		t.Fatalf("Write lock by owner failed: %v", err)
+	}
+
+	forgetFlocks()
+
+	vS.flockMutex.Lock()
+	vS.flockRecoveryDeadline = time.Now().Add(-time.Second)
+	vS.flockMutex.Unlock()
+
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &other)
+	if nil != err {
+		t.Fatalf("Write lock by other after grace period failed: %v", err)
+	}
+
+	other.Type = syscall.F_UNLCK
+	_, err = mS.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, lockFileInodeNumber, syscall.F_SETLK, &other)
+	if nil != err {
+		t.Fatalf("Unlock by other failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename)
+	if nil != err {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+}
@@ -0,0 +1,289 @@
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/headhunter"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// If JournalRetention is specified (and non-zero) for a volume, each namespace or attribute change made via
+// this package is recorded in the volume's change journal (see headhunter's journalRec B+Tree) as a JSON-encoded
+// JournalRecStruct keyed by a nonce. As the journal is checkpointed along with the rest of the volume's metadata,
+// a consumer (e.g. a backup tool) may fetch "everything that changed since" the cursor returned by its prior call
+// to FetchJournal().
+//
+// So as to keep the work of recording a change off the path of the change itself, journalAppend() merely queues
+// the record. Queued records are put (in order) by a per-volume goroutine (see journalDaemon()) or, should a
+// consumer call FetchJournal() first, by FetchJournal() itself. Consecutive writes to the same inode are recorded
+// only once... but only while the record of the first remains queued (i.e. before any consumer could have seen it).
+//
+// Records older than JournalRetention are trimmed from the front of the journal (at most once per
+// journalTrimInterval as records are put). Should a consumer present a cursor preceding records since
+// trimmed, FetchJournal() fails with blunder.JournalTrimmedError... and the consumer must fall back to a full scan.
+
+const (
+	journalTrimInterval = time.Minute
+	journalTrimBatch    = uint64(1024)
+
+	journalFetchMaxRecs = uint64(1024) // Also used if FetchJournal() is passed maxRecs == 0
+)
+
+func (vS *volumeStruct) journalUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	vS.journalRetention, err = confMap.FetchOptionValueDuration(volumeSectionName, "JournalRetention")
+	if nil != err {
+		vS.journalRetention = time.Duration(0) // Journal not enabled
+	}
+
+	vS.journalHeadhunterVolumeHandle, err = headhunter.FetchVolumeHandle(vS.volumeName)
+	if nil != err {
+		return
+	}
+
+	vS.journalPendingRecs = make([]JournalRecStruct, 0)
+	vS.journalLastTrimTime = time.Now()
+
+	// The journal may be enabled later (e.g. by tests)... so journalDaemon() is always started
+
+	vS.journalKickChan = make(chan struct{}, 1)
+	vS.journalStopChan = make(chan struct{}, 1)
+
+	vS.journalWG.Add(1)
+	go vS.journalDaemon()
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) journalDown() {
+	vS.journalStopChan <- struct{}{}
+	vS.journalWG.Wait()
+}
+
+// journalDaemon puts queued journal records whenever kicked by journalAppend() (and, finally, when stopped)
+func (vS *volumeStruct) journalDaemon() {
+	for {
+		select {
+		case <-vS.journalKickChan:
+			vS.journalPutPending()
+		case <-vS.journalStopChan:
+			vS.journalPutPending()
+			vS.journalWG.Done()
+			return
+		}
+	}
+}
+
+// journalAppend queues the record of a change (if the volume's journal is enabled)
+func (vS *volumeStruct) journalAppend(op JournalOp, parentInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) {
+	var (
+		lastJournalRec *JournalRecStruct
+	)
+
+	if time.Duration(0) == vS.journalRetention {
+		return
+	}
+
+	vS.journalMutex.Lock()
+
+	if (JournalOpWrite == op) && (0 < len(vS.journalPendingRecs)) {
+		lastJournalRec = &vS.journalPendingRecs[len(vS.journalPendingRecs)-1]
+		if (JournalOpWrite == lastJournalRec.Op) && (inodeNumber == lastJournalRec.InodeNumber) {
+			vS.journalMutex.Unlock()
+			return
+		}
+	}
+
+	vS.journalPendingRecs = append(vS.journalPendingRecs, JournalRecStruct{
+		Op:                op,
+		InodeNumber:       inodeNumber,
+		ParentInodeNumber: parentInodeNumber,
+		Basename:          basename,
+		Time:              time.Now(),
+	})
+
+	vS.journalMutex.Unlock()
+
+	select {
+	case vS.journalKickChan <- struct{}{}:
+	default:
+		// journalDaemon() has already been kicked
+	}
+}
+
+// journalPutPending puts the queued journal records
+//
+// Errors are logged rather than failing the (already completed) changes
+func (vS *volumeStruct) journalPutPending() {
+	var (
+		err          error
+		journalNonce uint64
+		journalRecs  []JournalRecStruct
+		value        []byte
+	)
+
+	vS.journalPutMutex.Lock()
+	defer vS.journalPutMutex.Unlock()
+
+	vS.journalMutex.Lock()
+	journalRecs = vS.journalPendingRecs
+	vS.journalPendingRecs = make([]JournalRecStruct, 0)
+	vS.journalMutex.Unlock()
+
+	if 0 == len(journalRecs) {
+		return
+	}
+
+	for _, journalRec := range journalRecs {
+		journalNonce, err = vS.journalHeadhunterVolumeHandle.FetchNonce()
+		if nil != err {
+			logger.ErrorfWithError(err, "Journal record of volume %s inode %v could not fetch a nonce", vS.volumeName, journalRec.InodeNumber)
+			continue
+		}
+
+		journalRec.Nonce = journalNonce
+
+		value, err = json.Marshal(journalRec)
+		if nil != err {
+			logger.Fatalf("json.Marshal() of JournalRecStruct failed: %v", err)
+		}
+
+		err = vS.journalHeadhunterVolumeHandle.PutJournalRec(journalNonce, value)
+		if nil != err {
+			logger.ErrorfWithError(err, "Journal record of volume %s inode %v could not be put", vS.volumeName, journalRec.InodeNumber)
+			continue
+		}
+
+		stats.IncrementOperations(&stats.FsJournalRecordOps)
+	}
+
+	now := time.Now()
+
+	if journalTrimInterval <= now.Sub(vS.journalLastTrimTime) {
+		vS.journalLastTrimTime = now
+		vS.journalTrimWhileLocked(now.Add(-vS.journalRetention))
+	}
+}
+
+// journalTrimWhileLocked discards journal records made before expirationTime
+//
+// Note: Caller must hold vS.journalPutMutex
+func (vS *volumeStruct) journalTrimWhileLocked(expirationTime time.Time) {
+	var (
+		err              error
+		journalNonces    []uint64
+		journalRec       JournalRecStruct
+		lastExpiredNonce uint64
+		values           [][]byte
+	)
+
+	for {
+		journalNonces, values, err = vS.journalHeadhunterVolumeHandle.FetchJournalRecs(0, journalTrimBatch)
+		if nil != err {
+			logger.ErrorfWithError(err, "Journal trim of volume %s could not fetch records", vS.volumeName)
+			return
+		}
+
+		lastExpiredNonce = 0
+
+		for i, value := range values {
+			err = json.Unmarshal(value, &journalRec)
+			if (nil != err) || !journalRec.Time.Before(expirationTime) {
+				break
+			}
+			lastExpiredNonce = journalNonces[i]
+		}
+
+		if 0 == lastExpiredNonce {
+			return
+		}
+
+		err = vS.journalHeadhunterVolumeHandle.TrimJournalRecs(lastExpiredNonce)
+		if nil != err {
+			logger.ErrorfWithError(err, "Journal trim of volume %s thru 0x%016X failed", vS.volumeName, lastExpiredNonce)
+			return
+		}
+
+		stats.IncrementOperations(&stats.FsJournalTrimOps)
+
+		if (uint64(len(journalNonces)) < journalTrimBatch) || (lastExpiredNonce != journalNonces[len(journalNonces)-1]) {
+			return
+		}
+	}
+}
+
+func fetchJournal(volumeName string, cursor string, maxRecs uint64) (journalRecs []JournalRecStruct, nextCursor string, err error) {
+	var (
+		afterNonce    uint64
+		journalNonces []uint64
+		ok            bool
+		values        [][]byte
+		vS            *volumeStruct
+	)
+
+	globals.Lock()
+	vS, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if "" == cursor {
+		afterNonce = 0
+	} else {
+		afterNonce, err = strconv.ParseUint(cursor, 16, 64)
+		if (nil != err) || (0 == afterNonce) {
+			err = fmt.Errorf("%s: cursor \"%s\" of volume \"%s\" is invalid", utils.GetFnName(), cursor, volumeName)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+	}
+
+	if (0 == maxRecs) || (journalFetchMaxRecs < maxRecs) {
+		maxRecs = journalFetchMaxRecs
+	}
+
+	// Ensure all changes made before this call are visible (and can no longer be coalesced with later ones)
+
+	vS.journalPutPending()
+
+	journalNonces, values, err = vS.journalHeadhunterVolumeHandle.FetchJournalRecs(afterNonce, maxRecs)
+	if nil != err {
+		if blunder.Is(err, blunder.JournalTrimmedError) {
+			err = fmt.Errorf("%s: cursor \"%s\" precedes the retention window of the change journal of volume \"%s\": %v", utils.GetFnName(), cursor, volumeName, err)
+			err = blunder.AddError(err, blunder.JournalTrimmedError)
+		}
+		return
+	}
+
+	journalRecs = make([]JournalRecStruct, len(values))
+
+	for i, value := range values {
+		err = json.Unmarshal(value, &journalRecs[i])
+		if nil != err {
+			err = fmt.Errorf("%s: journal record 0x%016X of volume \"%s\" could not be decoded: %v", utils.GetFnName(), journalNonces[i], volumeName, err)
+			return
+		}
+		journalRecs[i].Nonce = journalNonces[i]
+	}
+
+	if 0 == len(journalNonces) {
+		nextCursor = cursor
+	} else {
+		nextCursor = fmt.Sprintf("%016X", journalNonces[len(journalNonces)-1])
+	}
+
+	err = nil
+	return
+}
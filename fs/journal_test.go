@@ -0,0 +1,165 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestJournal(t *testing.T) {
+	vS := mS.volStruct
+
+	savedJournalRetention := vS.journalRetention
+	defer func() { vS.journalRetention = savedJournalRetention }()
+
+	vS.journalRetention = time.Hour
+
+	// Start from the end of whatever the journal already holds
+
+	cursor := ""
+	for {
+		journalRecs, nextCursor, err := FetchJournal("TestVolume", cursor, 0)
+		if nil != err {
+			t.Fatalf("FetchJournal() [initial] failed: %v", err)
+		}
+		cursor = nextCursor
+		if 0 == len(journalRecs) {
+			break
+		}
+	}
+
+	startCursor := cursor
+
+	dirInodeNumber := createTestDirectory(t, "TestJournal")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// Holding journalPutMutex keeps the first Write()'s record queued... so the second Write() is coalesced
+
+	vS.journalPutMutex.Lock()
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x00, 0x01}, nil)
+	if nil == err {
+		_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 2, []byte{0x02, 0x03}, nil)
+	}
+	vS.journalPutMutex.Unlock()
+	if nil != err {
+		t.Fatalf("Write() [first or second] failed: %v", err)
+	}
+
+	// Once a consumer may have fetched the Write() record, a subsequent Write() must be recorded anew
+
+	_, _, err = FetchJournal("TestVolume", cursor, 0)
+	if nil != err {
+		t.Fatalf("FetchJournal() [between writes] failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 4, []byte{0x04, 0x05}, nil)
+	if nil != err {
+		t.Fatalf("Write() [third] failed: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", dirInodeNumber, "Renamed")
+	if nil != err {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Renamed")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestJournal")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+
+	expectedJournalRecs := []JournalRecStruct{
+		{Op: JournalOpCreate, InodeNumber: dirInodeNumber, ParentInodeNumber: inode.RootDirInodeNumber, Basename: "TestJournal"},
+		{Op: JournalOpCreate, InodeNumber: fileInodeNumber, ParentInodeNumber: dirInodeNumber, Basename: "File"},
+		{Op: JournalOpWrite, InodeNumber: fileInodeNumber}, // Second Write() coalesced
+		{Op: JournalOpWrite, InodeNumber: fileInodeNumber}, // Third Write() followed a FetchJournal()
+		{Op: JournalOpRenameFrom, InodeNumber: fileInodeNumber, ParentInodeNumber: dirInodeNumber, Basename: "File"},
+		{Op: JournalOpRenameTo, InodeNumber: fileInodeNumber, ParentInodeNumber: dirInodeNumber, Basename: "Renamed"},
+		{Op: JournalOpSetattr, InodeNumber: fileInodeNumber},
+		{Op: JournalOpUnlink, InodeNumber: fileInodeNumber, ParentInodeNumber: dirInodeNumber, Basename: "Renamed"},
+		{Op: JournalOpUnlink, InodeNumber: dirInodeNumber, ParentInodeNumber: inode.RootDirInodeNumber, Basename: "TestJournal"},
+	}
+
+	// Page through the new records two at a time
+
+	journalRecs := make([]JournalRecStruct, 0, len(expectedJournalRecs))
+	cursorList := []string{cursor}
+
+	for {
+		page, nextCursor, err := FetchJournal("TestVolume", cursor, 2)
+		if nil != err {
+			t.Fatalf("FetchJournal(\"%s\") failed: %v", cursor, err)
+		}
+		if 2 < len(page) {
+			t.Fatalf("FetchJournal(\"%s\", 2) returned %v records", cursor, len(page))
+		}
+		if 0 == len(page) {
+			if nextCursor != cursor {
+				t.Fatalf("FetchJournal(\"%s\") of no records returned a new cursor \"%s\"", cursor, nextCursor)
+			}
+			break
+		}
+		journalRecs = append(journalRecs, page...)
+		cursor = nextCursor
+		cursorList = append(cursorList, cursor)
+	}
+
+	if len(expectedJournalRecs) != len(journalRecs) {
+		t.Fatalf("FetchJournal() returned %v records (expected %v): %+v", len(journalRecs), len(expectedJournalRecs), journalRecs)
+	}
+	for i, journalRec := range journalRecs {
+		expectedJournalRec := expectedJournalRecs[i]
+		if (expectedJournalRec.Op != journalRec.Op) ||
+			(expectedJournalRec.InodeNumber != journalRec.InodeNumber) ||
+			(expectedJournalRec.ParentInodeNumber != journalRec.ParentInodeNumber) ||
+			(expectedJournalRec.Basename != journalRec.Basename) {
+			t.Fatalf("FetchJournal() record %v was %+v (expected %+v)", i, journalRec, expectedJournalRec)
+		}
+		if (0 < i) && (journalRec.Nonce <= journalRecs[i-1].Nonce) {
+			t.Fatalf("FetchJournal() record %v nonce 0x%016X does not follow 0x%016X", i, journalRec.Nonce, journalRecs[i-1].Nonce)
+		}
+	}
+
+	_, _, err = FetchJournal("TestVolume", "NotACursor", 0)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("FetchJournal(\"NotACursor\") should have failed with InvalidArgError: %v", err)
+	}
+
+	_, _, err = FetchJournal("NoSuchVolume", "", 0)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchJournal() of NoSuchVolume should have failed with NotFoundError: %v", err)
+	}
+
+	// Trim everything... only a cursor at the end of the journal remains valid
+
+	vS.journalPutMutex.Lock()
+	vS.journalTrimWhileLocked(time.Now().Add(time.Second))
+	vS.journalPutMutex.Unlock()
+
+	_, _, err = FetchJournal("TestVolume", cursorList[1], 0)
+	if !blunder.Is(err, blunder.JournalTrimmedError) {
+		t.Fatalf("FetchJournal() of a trimmed cursor should have failed with JournalTrimmedError: %v", err)
+	}
+
+	if "" != startCursor {
+		_, _, err = FetchJournal("TestVolume", startCursor, 0)
+		if !blunder.Is(err, blunder.JournalTrimmedError) {
+			t.Fatalf("FetchJournal() of the starting cursor should have failed with JournalTrimmedError: %v", err)
+		}
+	}
+
+	journalRecs, nextCursor, err := FetchJournal("TestVolume", cursor, 0)
+	if (nil != err) || (0 != len(journalRecs)) || (cursor != nextCursor) {
+		t.Fatalf("FetchJournal() of the final cursor returned unexpected %+v, \"%s\" [err: %v]", journalRecs, nextCursor, err)
+	}
+}
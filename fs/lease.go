@@ -0,0 +1,528 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// An inode lease (or delegation) allows a mount's client to cache an inode's data and attributes.
+//
+// Any number of mounts may hold a read lease on an inode while a write lease is held exclusively by
+// one mount. When another mount performs an operation conflicting with a lease (a Read() or Getstat()
+// conflicts with a write lease, any operation changing the inode or a directory entry referring to it
+// conflicts with any lease), the lease is recalled via its dlm.Notify interface. This queues an
+// InodeLeaseRecallStruct for the holding mount to fetch via FetchInodeLeaseRecalls(). The conflicting
+// operation then waits for the lease to be released... or, should the holder not do so within
+// LeaseRecallTimeout, revokes it.
+//
+// While a lease is being recalled, no new leases are granted on the inode.
+
+const (
+	leaseDefaultRecallTimeout = 35 * time.Second
+)
+
+// InodeLeaseBreakStruct tracks the recall of conflicting leases begun by BreakInodeLeases()
+type InodeLeaseBreakStruct struct {
+	vS           *volumeStruct
+	inodeNumbers []inode.InodeNumber // Those for which leaseBreakingMap was incremented
+	leases       []*leaseStruct      // Those recalled
+}
+
+type leaseStruct struct {
+	mS           *mountStruct
+	inodeNumber  inode.InodeNumber
+	leaseType    InodeLeaseType
+	recalled     bool
+	releasedChan chan struct{} // closed once the lease is released (or revoked)
+}
+
+// mountLeaseRecallsStruct holds the recalls queued for a mount (synchronized via leaseMutex)
+type mountLeaseRecallsStruct struct {
+	recallList []InodeLeaseRecallStruct
+	wakeChan   chan struct{} // signalled when recallList becomes non-empty (or the volume is going down)
+}
+
+func (vS *volumeStruct) leaseUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	vS.leaseMap = make(map[inode.InodeNumber]map[MountID]*leaseStruct)
+	vS.leaseBreakingMap = make(map[inode.InodeNumber]uint64)
+	vS.leaseRecallsMap = make(map[MountID]*mountLeaseRecallsStruct)
+	vS.leaseHalting = false
+
+	vS.leaseRecallTimeout, err = confMap.FetchOptionValueDuration(volumeSectionName, "LeaseRecallTimeout")
+	if nil != err {
+		vS.leaseRecallTimeout = leaseDefaultRecallTimeout
+	}
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) leaseDown() {
+	vS.leaseMutex.Lock()
+	defer vS.leaseMutex.Unlock()
+
+	vS.leaseHalting = true
+
+	for _, inodeLeaseMap := range vS.leaseMap {
+		for _, lease := range inodeLeaseMap {
+			close(lease.releasedChan)
+		}
+	}
+
+	vS.leaseMap = make(map[inode.InodeNumber]map[MountID]*leaseStruct)
+
+	for _, mountLeaseRecalls := range vS.leaseRecallsMap {
+		select {
+		case mountLeaseRecalls.wakeChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// fetchMountLeaseRecallsWhileLocked returns the recall queue for mountID (creating it if necessary)
+//
+// Note: Caller must hold vS.leaseMutex
+func (vS *volumeStruct) fetchMountLeaseRecallsWhileLocked(mountID MountID) (mountLeaseRecalls *mountLeaseRecallsStruct) {
+	mountLeaseRecalls, ok := vS.leaseRecallsMap[mountID]
+	if !ok {
+		mountLeaseRecalls = &mountLeaseRecallsStruct{
+			recallList: make([]InodeLeaseRecallStruct, 0),
+			wakeChan:   make(chan struct{}, 1),
+		}
+		vS.leaseRecallsMap[mountID] = mountLeaseRecalls
+	}
+	return
+}
+
+// NotifyNodeChange satisfies dlm.Notify by queueing a recall of the lease for its holder
+//
+// Note: Caller must hold vS.leaseMutex
+func (lease *leaseStruct) NotifyNodeChange(reason dlm.NotifyReason) {
+	if lease.recalled {
+		return
+	}
+
+	lease.recalled = true
+
+	mountLeaseRecalls := lease.mS.volStruct.fetchMountLeaseRecallsWhileLocked(lease.mS.id)
+
+	mountLeaseRecalls.recallList = append(mountLeaseRecalls.recallList, InodeLeaseRecallStruct{
+		InodeNumber: lease.inodeNumber,
+		LeaseType:   lease.leaseType,
+		Reason:      reason,
+	})
+
+	select {
+	case mountLeaseRecalls.wakeChan <- struct{}{}:
+	default:
+	}
+
+	stats.IncrementOperations(&stats.FsLeaseRecallOps)
+}
+
+// conflictsWith reports whether an operation of the specified reason conflicts with the lease
+func (lease *leaseStruct) conflictsWith(reason dlm.NotifyReason) bool {
+	return (dlm.ReasonWriteRequest == reason) || (InodeLeaseWrite == lease.leaseType)
+}
+
+// releaseWhileLocked drops the lease, waking up any operation waiting for it to be released
+//
+// Note: Caller must hold vS.leaseMutex
+func (lease *leaseStruct) releaseWhileLocked() {
+	vS := lease.mS.volStruct
+
+	inodeLeaseMap := vS.leaseMap[lease.inodeNumber]
+	delete(inodeLeaseMap, lease.mS.id)
+	if 0 == len(inodeLeaseMap) {
+		delete(vS.leaseMap, lease.inodeNumber)
+	}
+
+	close(lease.releasedChan)
+}
+
+// breakLeases recalls the leases on inodeNumber held by other mounts that conflict with an operation of
+// the specified reason, waiting for them to be released (or revoking them after LeaseRecallTimeout)
+//
+// Note: Caller must not hold the inode's lock (nor validateVolumeRWMutex)... lest the holder be unable to flush
+func (mS *mountStruct) breakLeases(inodeNumber inode.InodeNumber, reason dlm.NotifyReason) {
+	mS.breakLeasesOfInodes([]inode.InodeNumber{inodeNumber}, reason)
+}
+
+// breakLeasesOfInodes is breakLeases() for several inodes at once (sharing a single LeaseRecallTimeout)
+func (mS *mountStruct) breakLeasesOfInodes(inodeNumbers []inode.InodeNumber, reason dlm.NotifyReason) {
+	leaseBreak := mS.BreakInodeLeases(inodeNumbers, reason)
+	if nil != leaseBreak {
+		leaseBreak.Wait()
+		leaseBreak.Done()
+	}
+}
+
+// anyLeases reports whether any leases are held on the volume (such that lookups for breakLeases() may be skipped)
+func (vS *volumeStruct) anyLeases() (leasesHeld bool) {
+	vS.leaseMutex.Lock()
+	leasesHeld = (0 < len(vS.leaseMap))
+	vS.leaseMutex.Unlock()
+	return
+}
+
+// lookupForLeaseBreak returns the inode (if any) named basename in dirInodeNumber such that its leases may be
+// broken by an operation about to change it. As breakLeases() must be called without holding any locks, the
+// inode so named could change before the operation locks dirInodeNumber... in which case the operation merely
+// fails to recall leases on a just renamed/linked in inode.
+func (mS *mountStruct) lookupForLeaseBreak(dirInodeNumber inode.InodeNumber, basename string) (inodeNumbers []inode.InodeNumber) {
+	inodeNumbers = make([]inode.InodeNumber, 0, 1)
+
+	if !mS.volStruct.anyLeases() {
+		return
+	}
+
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	dirInodeLock, err := mS.volStruct.initInodeLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = dirInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	inodeNumber, err := mS.volStruct.VolumeHandle.Lookup(dirInodeNumber, basename)
+	if nil == err {
+		inodeNumbers = append(inodeNumbers, inodeNumber)
+	}
+
+	return
+}
+
+// lookupPathForLeaseBreak is lookupForLeaseBreak() for a (middleware) path... returning both the inode (if any)
+// at fullpath and its parent directory (if any)
+func (mS *mountStruct) lookupPathForLeaseBreak(fullpath string) (inodeNumbers []inode.InodeNumber) {
+	inodeNumbers = make([]inode.InodeNumber, 0, 2)
+
+	if !mS.volStruct.anyLeases() {
+		return
+	}
+
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	for _, pathToResolve := range []string{filepath.Dir(fullpath), fullpath} {
+		if "." == pathToResolve {
+			inodeNumbers = append(inodeNumbers, inode.RootDirInodeNumber)
+			continue
+		}
+		inodeNumber, _, inodeLock, err := mS.resolvePathForRead(pathToResolve, nil)
+		if nil != err {
+			continue
+		}
+		inodeLock.Unlock()
+		inodeNumbers = append(inodeNumbers, inodeNumber)
+	}
+
+	return
+}
+
+// BreakInodeLeases recalls the leases on inodeNumbers held by other mounts that conflict with an operation
+// of the specified reason. If there are none, nil is returned. Otherwise, the returned InodeLeaseBreakStruct
+// may be used to Wait() for them to be released (or revoked after LeaseRecallTimeout)... without holding any
+// locks (e.g. jrpcfs's gate) lest the wait hold up others. Until Done() is called, no new leases on
+// inodeNumbers are granted such that the operation itself (once begun) does not have to wait again.
+func (mS *mountStruct) BreakInodeLeases(inodeNumbers []inode.InodeNumber, reason dlm.NotifyReason) (leaseBreak *InodeLeaseBreakStruct) {
+	var (
+		inodeLeaseMap map[MountID]*leaseStruct
+		inodeNumber   inode.InodeNumber
+		lease         *leaseStruct
+		notify        dlm.Notify
+		ok            bool
+		vS            *volumeStruct
+	)
+
+	vS = mS.volStruct
+
+	vS.leaseMutex.Lock()
+	defer vS.leaseMutex.Unlock()
+
+	for _, inodeNumber = range inodeNumbers {
+		inodeLeaseMap, ok = vS.leaseMap[inodeNumber]
+		if !ok {
+			// Fast path... no leases on inodeNumber
+			continue
+		}
+
+		for _, lease = range inodeLeaseMap {
+			if (lease.mS.id != mS.id) && lease.conflictsWith(reason) {
+				if nil == leaseBreak {
+					leaseBreak = &InodeLeaseBreakStruct{
+						vS:           vS,
+						inodeNumbers: make([]inode.InodeNumber, 0, len(inodeNumbers)),
+						leases:       make([]*leaseStruct, 0, len(inodeLeaseMap)),
+					}
+				}
+				leaseBreak.leases = append(leaseBreak.leases, lease)
+				notify = lease
+				notify.NotifyNodeChange(reason)
+			}
+		}
+	}
+
+	if nil == leaseBreak {
+		return
+	}
+
+	for _, inodeNumber = range inodeNumbers {
+		leaseBreak.inodeNumbers = append(leaseBreak.inodeNumbers, inodeNumber)
+		vS.leaseBreakingMap[inodeNumber]++
+	}
+
+	return
+}
+
+// Wait waits for the leases recalled by BreakInodeLeases() to be released (revoking any not released
+// within LeaseRecallTimeout)
+func (leaseBreak *InodeLeaseBreakStruct) Wait() {
+	vS := leaseBreak.vS
+
+	deadline := time.After(vS.leaseRecallTimeout)
+
+	for _, lease := range leaseBreak.leases {
+		select {
+		case <-lease.releasedChan:
+		case <-deadline:
+			vS.leaseMutex.Lock()
+			select {
+			case <-lease.releasedChan:
+				// Released just in time
+			default:
+				lease.releaseWhileLocked()
+				stats.IncrementOperations(&stats.FsLeaseRevokeOps)
+			}
+			vS.leaseMutex.Unlock()
+		}
+	}
+}
+
+// Done once again allows leases to be granted on the inodes passed to BreakInodeLeases()
+func (leaseBreak *InodeLeaseBreakStruct) Done() {
+	vS := leaseBreak.vS
+
+	vS.leaseMutex.Lock()
+	for _, inodeNumber := range leaseBreak.inodeNumbers {
+		if 1 == vS.leaseBreakingMap[inodeNumber] {
+			delete(vS.leaseBreakingMap, inodeNumber)
+		} else {
+			vS.leaseBreakingMap[inodeNumber]--
+		}
+	}
+	vS.leaseMutex.Unlock()
+}
+
+func (mS *mountStruct) AcquireInodeLease(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, inodeNumber inode.InodeNumber, leaseType InodeLeaseType) (err error) {
+	var (
+		accessMode    inode.InodeMode
+		inodeLeaseMap map[MountID]*leaseStruct
+		lease         *leaseStruct
+		mountID       MountID
+		ok            bool
+		reason        dlm.NotifyReason
+		vS            *volumeStruct
+	)
+
+	stats.IncrementOperations(&stats.FsLeaseAcquireOps)
+
+	switch leaseType {
+	case InodeLeaseRead:
+		accessMode = inode.R_OK
+		reason = dlm.ReasonReadRequest
+	case InodeLeaseWrite:
+		err = mS.checkMountWritable()
+		if nil != err {
+			return
+		}
+		accessMode = inode.W_OK
+		reason = dlm.ReasonWriteRequest
+	default:
+		err = fmt.Errorf("%s: invalid leaseType %v", utils.GetFnName(), leaseType)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	vS = mS.volStruct
+
+	vS.validateVolumeRWMutex.RLock()
+	defer vS.validateVolumeRWMutex.RUnlock()
+
+	inodeLock, err := vS.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	if !vS.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, inode.F_OK, inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !vS.VolumeHandle.Access(inodeNumber, userID, groupID, otherGroupIDs, accessMode, inode.OwnerOverride) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	vS.leaseMutex.Lock()
+	defer vS.leaseMutex.Unlock()
+
+	if vS.leaseHalting {
+		err = fmt.Errorf("%s: volume %s is going down", utils.GetFnName(), vS.volumeName)
+		err = blunder.AddError(err, blunder.TryAgainError)
+		return
+	}
+
+	if 0 < vS.leaseBreakingMap[inodeNumber] {
+		err = fmt.Errorf("%s: leases on inode %v are being recalled", utils.GetFnName(), inodeNumber)
+		err = blunder.AddError(err, blunder.TryAgainError)
+		return
+	}
+
+	inodeLeaseMap, ok = vS.leaseMap[inodeNumber]
+	if !ok {
+		inodeLeaseMap = make(map[MountID]*leaseStruct)
+		vS.leaseMap[inodeNumber] = inodeLeaseMap
+	}
+
+	for mountID, lease = range inodeLeaseMap {
+		if mountID == mS.id {
+			if lease.recalled {
+				err = fmt.Errorf("%s: lease on inode %v is being recalled", utils.GetFnName(), inodeNumber)
+				err = blunder.AddError(err, blunder.TryAgainError)
+				return
+			}
+			continue
+		}
+		if lease.conflictsWith(reason) {
+			// Recall the conflicting lease... the caller may retry once it has been released
+			lease.NotifyNodeChange(reason)
+			err = fmt.Errorf("%s: inode %v has a conflicting lease", utils.GetFnName(), inodeNumber)
+			err = blunder.AddError(err, blunder.TryAgainError)
+		}
+	}
+	if nil != err {
+		return
+	}
+
+	lease, ok = inodeLeaseMap[mS.id]
+	if ok {
+		// Upgrade (or downgrade) the existing lease
+		lease.leaseType = leaseType
+		return
+	}
+
+	inodeLeaseMap[mS.id] = &leaseStruct{
+		mS:           mS,
+		inodeNumber:  inodeNumber,
+		leaseType:    leaseType,
+		recalled:     false,
+		releasedChan: make(chan struct{}),
+	}
+
+	return
+}
+
+func (mS *mountStruct) ReleaseInodeLease(inodeNumber inode.InodeNumber) (err error) {
+	vS := mS.volStruct
+
+	stats.IncrementOperations(&stats.FsLeaseReleaseOps)
+
+	vS.leaseMutex.Lock()
+	defer vS.leaseMutex.Unlock()
+
+	lease, ok := vS.leaseMap[inodeNumber][mS.id]
+	if !ok {
+		err = fmt.Errorf("%s: no lease held on inode %v (or it was revoked)", utils.GetFnName(), inodeNumber)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	lease.releaseWhileLocked()
+
+	err = nil
+	return
+}
+
+// ReleaseInodeLeases drops all leases held via this MountHandle and discards any of its queued
+// recalls. It is called when the mount's client goes away.
+func (mS *mountStruct) ReleaseInodeLeases() (err error) {
+	vS := mS.volStruct
+
+	vS.leaseMutex.Lock()
+	defer vS.leaseMutex.Unlock()
+
+	for _, inodeLeaseMap := range vS.leaseMap {
+		lease, ok := inodeLeaseMap[mS.id]
+		if ok {
+			lease.releaseWhileLocked()
+			stats.IncrementOperations(&stats.FsLeaseReleaseOps)
+		}
+	}
+
+	mountLeaseRecalls, ok := vS.leaseRecallsMap[mS.id]
+	if ok {
+		delete(vS.leaseRecallsMap, mS.id)
+		select {
+		case mountLeaseRecalls.wakeChan <- struct{}{}:
+		default:
+		}
+	}
+
+	err = nil
+	return
+}
+
+// FetchInodeLeaseRecalls returns the recalls queued for this MountHandle, waiting up to timeout for one to arrive
+func (mS *mountStruct) FetchInodeLeaseRecalls(timeout time.Duration) (recalls []InodeLeaseRecallStruct, err error) {
+	vS := mS.volStruct
+
+	vS.leaseMutex.Lock()
+
+	mountLeaseRecalls := vS.fetchMountLeaseRecallsWhileLocked(mS.id)
+
+	if (0 == len(mountLeaseRecalls.recallList)) && !vS.leaseHalting && (time.Duration(0) < timeout) {
+		// Discard any wake-up left over from recalls already fetched (without waiting) by a prior call
+
+		select {
+		case <-mountLeaseRecalls.wakeChan:
+		default:
+		}
+
+		vS.leaseMutex.Unlock()
+
+		timer := time.NewTimer(timeout)
+		select {
+		case <-mountLeaseRecalls.wakeChan:
+		case <-timer.C:
+		}
+		_ = timer.Stop()
+
+		vS.leaseMutex.Lock()
+	}
+
+	recalls = mountLeaseRecalls.recallList
+	mountLeaseRecalls.recallList = make([]InodeLeaseRecallStruct, 0)
+
+	vS.leaseMutex.Unlock()
+
+	err = nil
+	return
+}
@@ -0,0 +1,215 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestInodeLease(t *testing.T) {
+	mountHandle, err := Mount("TestVolume", MountOptions(0))
+	if nil != err {
+		t.Fatalf("Mount(\"TestVolume\", MountOptions(0)) failed: %v", err)
+	}
+	otherMS := mountHandle.(*mountStruct)
+
+	vS := mS.volStruct
+
+	savedLeaseRecallTimeout := vS.leaseRecallTimeout
+	defer func() { vS.leaseRecallTimeout = savedLeaseRecallTimeout }()
+
+	basename := "TestInodeLease"
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename, inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() %v returned error: %v", basename, err)
+	}
+
+	err = mS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseNone)
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseNone) should have failed with InvalidArgError: %v", err)
+	}
+
+	// Read leases may be shared
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseRead) via otherMS failed: %v", err)
+	}
+	err = mS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseRead) via mS failed: %v", err)
+	}
+
+	// A write lease request should recall conflicting read leases
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseWrite)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseWrite) via otherMS should have failed with TryAgainError: %v", err)
+	}
+
+	recalls, err := mS.FetchInodeLeaseRecalls(time.Duration(0))
+	if nil != err {
+		t.Fatalf("FetchInodeLeaseRecalls() via mS failed: %v", err)
+	}
+	if (1 != len(recalls)) || (fileInodeNumber != recalls[0].InodeNumber) || (InodeLeaseRead != recalls[0].LeaseType) || (dlm.ReasonWriteRequest != recalls[0].Reason) {
+		t.Fatalf("FetchInodeLeaseRecalls() via mS returned unexpected %+v", recalls)
+	}
+
+	err = mS.ReleaseInodeLease(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("ReleaseInodeLease() via mS failed: %v", err)
+	}
+	err = mS.ReleaseInodeLease(fileInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("ReleaseInodeLease() via mS of released lease should have failed with NotFoundError: %v", err)
+	}
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseWrite)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseWrite) via otherMS failed: %v", err)
+	}
+
+	// A conflicting Write() should wait for the write lease to be released
+
+	vS.leaseRecallTimeout = time.Minute
+
+	writeErrChan := make(chan error, 1)
+
+	go func() {
+		_, writeErr := mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+		writeErrChan <- writeErr
+	}()
+
+	recalls, err = otherMS.FetchInodeLeaseRecalls(10 * time.Second)
+	if nil != err {
+		t.Fatalf("FetchInodeLeaseRecalls() via otherMS failed: %v", err)
+	}
+	if (1 != len(recalls)) || (InodeLeaseWrite != recalls[0].LeaseType) || (dlm.ReasonWriteRequest != recalls[0].Reason) {
+		t.Fatalf("FetchInodeLeaseRecalls() via otherMS returned unexpected %+v", recalls)
+	}
+
+	select {
+	case err = <-writeErrChan:
+		t.Fatalf("Write() via mS should have waited for the recalled lease to be released but returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err = mS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if !blunder.Is(err, blunder.TryAgainError) {
+		t.Fatalf("AcquireInodeLease() during recall should have failed with TryAgainError: %v", err)
+	}
+
+	err = otherMS.ReleaseInodeLease(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("ReleaseInodeLease() via otherMS failed: %v", err)
+	}
+
+	select {
+	case err = <-writeErrChan:
+		if nil != err {
+			t.Fatalf("Write() via mS failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Write() via mS was not released by ReleaseInodeLease()")
+	}
+
+	// A lease not released within LeaseRecallTimeout should be revoked
+
+	vS.leaseRecallTimeout = 100 * time.Millisecond
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseRead) via otherMS failed: %v", err)
+	}
+
+	_, err = mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, 4, nil)
+	if nil != err {
+		t.Fatalf("Read() via mS failed: %v", err)
+	}
+	recalls, err = otherMS.FetchInodeLeaseRecalls(time.Duration(0))
+	if (nil != err) || (0 != len(recalls)) {
+		t.Fatalf("Read() via mS should not have recalled a read lease [recalls: %+v err: %v]", recalls, err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x04, 0x05, 0x06, 0x07}, nil)
+	if nil != err {
+		t.Fatalf("Write() via mS failed: %v", err)
+	}
+
+	err = otherMS.ReleaseInodeLease(fileInodeNumber)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("ReleaseInodeLease() via otherMS of revoked lease should have failed with NotFoundError: %v", err)
+	}
+
+	// ReleaseInodeLeases() should drop all of a mount's leases and queued recalls
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseWrite)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseWrite) via otherMS failed: %v", err)
+	}
+
+	err = otherMS.ReleaseInodeLeases()
+	if nil != err {
+		t.Fatalf("ReleaseInodeLeases() via otherMS failed: %v", err)
+	}
+
+	err = mS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseWrite)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseWrite) via mS after ReleaseInodeLeases() failed: %v", err)
+	}
+	err = mS.ReleaseInodeLease(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("ReleaseInodeLease() via mS failed: %v", err)
+	}
+
+	// SetXAttr() and Unlink() should recall (even read) leases on the inode they change
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseRead) via otherMS failed: %v", err)
+	}
+
+	err = mS.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, "TestInodeLease", []byte{0x08}, 0)
+	if nil != err {
+		t.Fatalf("SetXAttr() via mS failed: %v", err)
+	}
+	recalls, err = otherMS.FetchInodeLeaseRecalls(time.Duration(0))
+	if (nil != err) || (1 != len(recalls)) || (fileInodeNumber != recalls[0].InodeNumber) || (dlm.ReasonWriteRequest != recalls[0].Reason) {
+		t.Fatalf("SetXAttr() via mS should have recalled otherMS's read lease [recalls: %+v err: %v]", recalls, err)
+	}
+
+	// The wake-up left over from that (already fetched) recall should not cut short a subsequent wait
+
+	fetchStartTime := time.Now()
+	recalls, err = otherMS.FetchInodeLeaseRecalls(100 * time.Millisecond)
+	if (nil != err) || (0 != len(recalls)) {
+		t.Fatalf("FetchInodeLeaseRecalls() via otherMS following a fetch of all queued recalls returned unexpected %+v [err: %v]", recalls, err)
+	}
+	if time.Since(fetchStartTime) < (100 * time.Millisecond) {
+		t.Fatalf("FetchInodeLeaseRecalls() via otherMS following a fetch of all queued recalls returned before its timeout")
+	}
+
+	err = otherMS.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, InodeLeaseRead)
+	if nil != err {
+		t.Fatalf("AcquireInodeLease(,,,,InodeLeaseRead) via otherMS failed: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, basename)
+	if nil != err {
+		t.Fatalf("Unlink() %v returned error: %v", basename, err)
+	}
+	recalls, err = otherMS.FetchInodeLeaseRecalls(time.Duration(0))
+	if (nil != err) || (1 != len(recalls)) || (fileInodeNumber != recalls[0].InodeNumber) || (dlm.ReasonWriteRequest != recalls[0].Reason) {
+		t.Fatalf("Unlink() via mS should have recalled otherMS's read lease [recalls: %+v err: %v]", recalls, err)
+	}
+
+	// BreakInodeLeases() returns nil absent conflicting leases
+
+	leaseBreak := mS.BreakInodeLeases([]inode.InodeNumber{fileInodeNumber}, dlm.ReasonWriteRequest)
+	if nil != leaseBreak {
+		t.Fatalf("BreakInodeLeases() absent leases should have returned nil")
+	}
+}
@@ -15,7 +15,13 @@ import (
 )
 
 func (vS *volumeStruct) makeLockID(inodeNumber inode.InodeNumber) (lockID string, err error) {
-	myLockID := fmt.Sprintf("vol.%s:ino.%d", vS.volumeName, inodeNumber)
+	var myLockID string
+
+	if "" == vS.snapshotName {
+		myLockID = fmt.Sprintf("vol.%s:ino.%d", vS.volumeName, inodeNumber)
+	} else {
+		myLockID = fmt.Sprintf("vol.%s/%s/%s:ino.%d", vS.volumeName, SnapshotDirName, vS.snapshotName, inodeNumber)
+	}
 
 	return myLockID, nil
 }
@@ -0,0 +1,948 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A volume's QuotaList names the [Quota:<name>] sections limiting its usage. Each such section specifies:
+//
+//   Type:        User, Group, or Tree
+//   ID:          the UserID (for Type: User) or GroupID (for Type: Group) limited
+//   Path:        the directory (for Type: Tree) beneath which everything created is limited
+//   SoftBytes:   optional; exceeding it starts the GracePeriod after which it is enforced (0 == unlimited)
+//   HardBytes:   optional; enforced immediately (0 == unlimited)
+//   SoftInodes:  optional; as for SoftBytes
+//   HardInodes:  optional; as for HardBytes
+//   GracePeriod: optional; defaults to quotaGracePeriodDefault
+//
+// Usage is that maintained by package inode (which reflects what has been flushed) plus the unflushed
+// growth of each FileInode written since (as noted by Write() in quotaUnflushedMap and dropped once the
+// FileInode is flushed). Exceeding a limit fails with blunder.QuotaExceededError (i.e. EDQUOT). Grace
+// periods are tracked in memory only and restart should the volume be remounted while over a soft limit.
+//
+// Tree quotas rely on each inode recording (in inode.MetadataStruct.QuotaTree) the quota tree it was
+// created in. When a Tree quota is first brought up, its directory and everything beneath it (not
+// already within a nested quota tree) is so tagged. Thereafter, package inode maintains the byte and
+// inode totals of each tree incrementally, so a Tree quota with no limits serves as a "project" whose
+// du-like totals are available instantly via GetXAttr() of ProjectBytesXAttrName & ProjectInodesXAttrName
+// on its directory (as well as via FetchQuotaReport()). Should Rename() move an inode between quota trees,
+// it is retagged (moving its usage along with it) before Rename() releases its directories' locks. For a
+// directory, its descendants still in the source tree are then retagged after those locks are released,
+// locking each directory only while reading quotaTreeRetagBatchSize entries at a time. Until that completes,
+// the trees' totals are transiently off by the descendants not yet retagged. A file moving into a tree with
+// limits is checked against them. As the usage of a directory's descendants is only known by walking them,
+// a directory may not be moved into a tree with limits from outside it... Rename() fails with
+// blunder.CrossDeviceError (i.e. EXDEV, as for XFS project quotas) such that tools like mv(1) fall back to
+// copying (and so checking) each file. As an inode's usage is charged to a single quota tree, Link()
+// refuses (with blunder.CrossDeviceError, i.e. EXDEV) to link an inode into a different quota tree.
+
+const (
+	quotaGracePeriodDefault = 7 * 24 * time.Hour
+	quotaTreeRetagBatchSize = uint64(1024) // Directory entries read per hold of a directory's lock while retagging
+)
+
+type quotaStruct struct {
+	name             string
+	quotaType        QuotaType
+	id               uint64 // For QuotaTypeTree, the directory's InodeNumber
+	path             string // Only for QuotaTypeTree
+	softBytes        uint64
+	hardBytes        uint64
+	softInodes       uint64
+	hardInodes       uint64
+	gracePeriod      time.Duration
+	bytesGraceStart  time.Time // Synchronized via volumeStruct.quotaMutex; zero unless softBytes exceeded
+	inodesGraceStart time.Time // Synchronized via volumeStruct.quotaMutex; zero unless softInodes exceeded
+	unflushedBytes   uint64    // Synchronized via volumeStruct.quotaMutex; sum of quotaUnflushedStruct.bytes charged here
+}
+
+type quotaUnflushedStruct struct { // Unflushed growth of a FileInode already charged to each applicable quota
+	quotas []*quotaStruct
+	bytes  uint64
+}
+
+type quotaTreeMoveStruct struct { // A directory whose descendants remain to be retagged following a Rename()
+	dirInodeNumber inode.InodeNumber
+	fromQuotaTree  inode.InodeNumber
+	toQuotaTree    inode.InodeNumber
+}
+
+func (vS *volumeStruct) quotaUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	var (
+		quota            *quotaStruct
+		quotaName        string
+		quotaNameList    []string
+		quotaSectionName string
+		quotaTypeString  string
+		uint32ID         uint32
+	)
+
+	vS.quotaList = make([]*quotaStruct, 0)
+	vS.quotaUserMap = make(map[inode.InodeUserID]*quotaStruct)
+	vS.quotaGroupMap = make(map[inode.InodeGroupID]*quotaStruct)
+	vS.quotaTreeMap = make(map[inode.InodeNumber]*quotaStruct)
+	vS.quotaUnflushedMap = make(map[inode.InodeNumber]*quotaUnflushedStruct)
+
+	quotaNameList, err = confMap.FetchOptionValueStringSlice(volumeSectionName, "QuotaList")
+	if nil != err {
+		quotaNameList = []string{} // No quotas
+	}
+
+	for _, quotaName = range quotaNameList {
+		quotaSectionName = utils.QuotaNameConfSection(quotaName)
+
+		quota = &quotaStruct{name: quotaName}
+
+		quotaTypeString, err = confMap.FetchOptionValueString(quotaSectionName, "Type")
+		if nil != err {
+			return
+		}
+
+		switch quotaTypeString {
+		case "User":
+			quota.quotaType = QuotaTypeUser
+			uint32ID, err = confMap.FetchOptionValueUint32(quotaSectionName, "ID")
+			if nil != err {
+				return
+			}
+			quota.id = uint64(uint32ID)
+		case "Group":
+			quota.quotaType = QuotaTypeGroup
+			uint32ID, err = confMap.FetchOptionValueUint32(quotaSectionName, "ID")
+			if nil != err {
+				return
+			}
+			quota.id = uint64(uint32ID)
+		case "Tree":
+			quota.quotaType = QuotaTypeTree
+			quota.path, err = confMap.FetchOptionValueString(quotaSectionName, "Path")
+			if nil != err {
+				return
+			}
+		default:
+			err = fmt.Errorf("%s: [%s]Type must be User, Group, or Tree (not \"%s\")", utils.GetFnName(), quotaSectionName, quotaTypeString)
+			return
+		}
+
+		quota.softBytes, err = confMap.FetchOptionValueUint64(quotaSectionName, "SoftBytes")
+		if nil != err {
+			quota.softBytes = 0
+		}
+		quota.hardBytes, err = confMap.FetchOptionValueUint64(quotaSectionName, "HardBytes")
+		if nil != err {
+			quota.hardBytes = 0
+		}
+		quota.softInodes, err = confMap.FetchOptionValueUint64(quotaSectionName, "SoftInodes")
+		if nil != err {
+			quota.softInodes = 0
+		}
+		quota.hardInodes, err = confMap.FetchOptionValueUint64(quotaSectionName, "HardInodes")
+		if nil != err {
+			quota.hardInodes = 0
+		}
+		quota.gracePeriod, err = confMap.FetchOptionValueDuration(quotaSectionName, "GracePeriod")
+		if nil != err {
+			quota.gracePeriod = quotaGracePeriodDefault
+		}
+
+		err = vS.quotaAdd(quota)
+		if nil != err {
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// quotaAdd installs a quota, tagging the directory tree of a QuotaTypeTree quota as necessary
+func (vS *volumeStruct) quotaAdd(quota *quotaStruct) (err error) {
+	var (
+		dirInodeNumber inode.InodeNumber
+		ok             bool
+	)
+
+	// Tagging locks each inode of the tree... so must precede taking quotaMutex (see quotaCheck())
+
+	if QuotaTypeTree == quota.quotaType {
+		dirInodeNumber, err = vS.quotaResolvePath(quota.path)
+		if nil != err {
+			return
+		}
+		quota.id = uint64(dirInodeNumber)
+		err = vS.quotaTreeTag(dirInodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	vS.quotaMutex.Lock()
+	defer vS.quotaMutex.Unlock()
+
+	switch quota.quotaType {
+	case QuotaTypeUser:
+		_, ok = vS.quotaUserMap[inode.InodeUserID(quota.id)]
+		if !ok {
+			vS.quotaUserMap[inode.InodeUserID(quota.id)] = quota
+		}
+	case QuotaTypeGroup:
+		_, ok = vS.quotaGroupMap[inode.InodeGroupID(quota.id)]
+		if !ok {
+			vS.quotaGroupMap[inode.InodeGroupID(quota.id)] = quota
+		}
+	case QuotaTypeTree:
+		_, ok = vS.quotaTreeMap[dirInodeNumber]
+		if !ok {
+			vS.quotaTreeMap[dirInodeNumber] = quota
+		}
+	default:
+		err = fmt.Errorf("%s: quota \"%s\" of volume \"%s\" has unknown type %v", utils.GetFnName(), quota.name, vS.volumeName, quota.quotaType)
+		return
+	}
+
+	if ok {
+		err = fmt.Errorf("%s: quota \"%s\" of volume \"%s\" duplicates another quota's Type and ID/Path", utils.GetFnName(), quota.name, vS.volumeName)
+		return
+	}
+
+	vS.quotaList = append(vS.quotaList, quota)
+
+	return
+}
+
+// quotaResolvePath returns the InodeNumber of the directory at path (relative to the volume's root)
+func (vS *volumeStruct) quotaResolvePath(path string) (dirInodeNumber inode.InodeNumber, err error) {
+	var (
+		basename  string
+		inodeType inode.InodeType
+	)
+
+	dirInodeNumber = inode.RootDirInodeNumber
+
+	for _, basename = range strings.Split(path, "/") {
+		if ("" == basename) || ("." == basename) {
+			continue
+		}
+		dirInodeNumber, err = vS.VolumeHandle.Lookup(dirInodeNumber, basename)
+		if nil != err {
+			err = fmt.Errorf("%s: quota tree \"%s\" of volume \"%s\" not found: %v", utils.GetFnName(), path, vS.volumeName, err)
+			return
+		}
+	}
+
+	inodeType, err = vS.VolumeHandle.GetType(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.DirType != inodeType {
+		err = fmt.Errorf("%s: quota tree \"%s\" of volume \"%s\" is not a directory", utils.GetFnName(), path, vS.volumeName)
+		return
+	}
+
+	return
+}
+
+// quotaTreeTag makes dirInodeNumber the root of a quota tree (if it is not already)
+func (vS *volumeStruct) quotaTreeTag(dirInodeNumber inode.InodeNumber) (err error) {
+	var (
+		metadata *inode.MetadataStruct
+	)
+
+	metadata, err = vS.VolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if dirInodeNumber == metadata.QuotaTree {
+		return
+	}
+
+	logger.Infof("Volume %s tagging quota tree rooted at inode %v", vS.volumeName, dirInodeNumber)
+
+	err = vS.quotaTreeRetag(dirInodeNumber, metadata.QuotaTree, dirInodeNumber)
+
+	return
+}
+
+// quotaTreeRetag moves inodeNumber (and, if it is a directory, its descendants) from fromQuotaTree to toQuotaTree
+//
+// Inodes not in fromQuotaTree (e.g. those in a nested quota tree) are left alone. As each inode is locked only
+// while it is retagged, the caller must not hold a lock on inodeNumber or any of its descendants
+func (vS *volumeStruct) quotaTreeRetag(inodeNumber inode.InodeNumber, fromQuotaTree inode.InodeNumber, toQuotaTree inode.InodeNumber) (err error) {
+	var (
+		retaggedDir bool
+	)
+
+	retaggedDir, err = vS.quotaTreeRetagInode(inodeNumber, fromQuotaTree, toQuotaTree)
+	if (nil != err) || !retaggedDir {
+		return
+	}
+
+	err = vS.quotaTreeRetagDescendants(inodeNumber, fromQuotaTree, toQuotaTree)
+
+	return
+}
+
+// quotaTreeRetagInode moves inodeNumber alone from fromQuotaTree to toQuotaTree, indicating
+// whether it was a directory (whose descendants remain to be retagged) that was so moved
+//
+// Note: Caller must not hold a lock on inodeNumber
+func (vS *volumeStruct) quotaTreeRetagInode(inodeNumber inode.InodeNumber, fromQuotaTree inode.InodeNumber, toQuotaTree inode.InodeNumber) (retaggedDir bool, err error) {
+	var (
+		inodeLock *dlm.RWLockStruct
+		metadata  *inode.MetadataStruct
+	)
+
+	inodeLock, err = vS.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.WriteLock()
+	if nil != err {
+		return
+	}
+	defer inodeLock.Unlock()
+
+	metadata, err = vS.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			err = nil // Removed since its directory entry was read
+		}
+		return
+	}
+	if fromQuotaTree != metadata.QuotaTree {
+		return
+	}
+
+	err = vS.VolumeHandle.SetQuotaTree(inodeNumber, toQuotaTree)
+	if nil != err {
+		return
+	}
+
+	retaggedDir = (inode.DirType == metadata.InodeType)
+
+	return
+}
+
+// quotaTreeRetagDescendants retags those descendants of dirInodeNumber (already
+// moved to toQuotaTree) still in fromQuotaTree
+//
+// The directory is only locked while the next quotaTreeRetagBatchSize of its entries are read. Having
+// already been retagged, entries created in it meanwhile inherit toQuotaTree. Should it no longer be in
+// toQuotaTree (i.e. it has since moved again, retagging it anew), its remaining entries are left alone.
+func (vS *volumeStruct) quotaTreeRetagDescendants(dirInodeNumber inode.InodeNumber, fromQuotaTree inode.InodeNumber, toQuotaTree inode.InodeNumber) (err error) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntrySlice []inode.DirEntry
+		dirInodeLock  *dlm.RWLockStruct
+		metadata      *inode.MetadataStruct
+		moreEntries   bool
+		prevBasename  string
+	)
+
+	dirInodeLock, err = vS.initInodeLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	prevBasename = ""
+
+	for {
+		err = dirInodeLock.ReadLock()
+		if nil != err {
+			return
+		}
+
+		metadata, err = vS.VolumeHandle.GetMetadata(dirInodeNumber)
+		if (nil != err) || (toQuotaTree != metadata.QuotaTree) {
+			dirInodeLock.Unlock()
+			if blunder.Is(err, blunder.NotFoundError) {
+				err = nil // Removed (so emptied) since retagged
+			}
+			return
+		}
+
+		if "" == prevBasename {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(dirInodeNumber, quotaTreeRetagBatchSize, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(dirInodeNumber, quotaTreeRetagBatchSize, 0, prevBasename)
+		}
+
+		dirInodeLock.Unlock()
+
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			if ("." == dirEntry.Basename) || (".." == dirEntry.Basename) {
+				continue
+			}
+
+			err = vS.quotaTreeRetag(dirEntry.InodeNumber, fromQuotaTree, toQuotaTree)
+			if nil != err {
+				return
+			}
+		}
+
+		if !moreEntries || (0 == len(dirEntrySlice)) {
+			return
+		}
+
+		prevBasename = dirEntrySlice[len(dirEntrySlice)-1].Basename
+	}
+}
+
+// quotaTreeMove retags the inode just renamed to dstBasename in dstDirInodeNumber should it have moved between
+// quota trees, thus moving its usage as well. Should it be a directory, the returned quotaTreeMove describes the
+// retagging of its descendants left to quotaTreeMoveDescendants().
+//
+// Errors are logged rather than failing the (already completed) Rename()
+//
+// Note: Caller must hold the locks on both directories
+func (vS *volumeStruct) quotaTreeMove(srcDirInodeNumber inode.InodeNumber, dstDirInodeNumber inode.InodeNumber, dstBasename string) (quotaTreeMove *quotaTreeMoveStruct) {
+	var (
+		dstDirMetadata   *inode.MetadataStruct
+		err              error
+		movedInodeNumber inode.InodeNumber
+		retaggedDir      bool
+		srcDirMetadata   *inode.MetadataStruct
+	)
+
+	if (0 == len(vS.quotaList)) || (srcDirInodeNumber == dstDirInodeNumber) {
+		return
+	}
+
+	srcDirMetadata, err = vS.VolumeHandle.GetMetadata(srcDirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s quota tree of rename source dir inode %v unknown", vS.volumeName, srcDirInodeNumber)
+		return
+	}
+	dstDirMetadata, err = vS.VolumeHandle.GetMetadata(dstDirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s quota tree of rename destination dir inode %v unknown", vS.volumeName, dstDirInodeNumber)
+		return
+	}
+
+	if srcDirMetadata.QuotaTree == dstDirMetadata.QuotaTree {
+		return
+	}
+
+	movedInodeNumber, err = vS.VolumeHandle.Lookup(dstDirInodeNumber, dstBasename)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s renamed %s in dir inode %v not found", vS.volumeName, dstBasename, dstDirInodeNumber)
+		return
+	}
+
+	retaggedDir, err = vS.quotaTreeRetagInode(movedInodeNumber, srcDirMetadata.QuotaTree, dstDirMetadata.QuotaTree)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s retag of inode %v moving from quota tree %v to %v failed", vS.volumeName, movedInodeNumber, srcDirMetadata.QuotaTree, dstDirMetadata.QuotaTree)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FsQuotaTreeMoveOps)
+
+	if retaggedDir {
+		quotaTreeMove = &quotaTreeMoveStruct{
+			dirInodeNumber: movedInodeNumber,
+			fromQuotaTree:  srcDirMetadata.QuotaTree,
+			toQuotaTree:    dstDirMetadata.QuotaTree,
+		}
+	}
+
+	return
+}
+
+// quotaTreeMoveDescendants completes a quotaTreeMove of a directory by retagging its descendants
+//
+// Note: Caller must no longer hold the locks taken by Rename()
+func (vS *volumeStruct) quotaTreeMoveDescendants(quotaTreeMove *quotaTreeMoveStruct) {
+	var (
+		err error
+	)
+
+	err = vS.quotaTreeRetagDescendants(quotaTreeMove.dirInodeNumber, quotaTreeMove.fromQuotaTree, quotaTreeMove.toQuotaTree)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s retag of descendants of dir inode %v moving from quota tree %v to %v failed", vS.volumeName, quotaTreeMove.dirInodeNumber, quotaTreeMove.fromQuotaTree, quotaTreeMove.toQuotaTree)
+	}
+}
+
+// quotaCheckMove checks the limits (if any) of the quota tree of dstDirInodeNumber before the inode srcBasename in
+// srcDirInodeNumber is renamed into it from another quota tree. A directory may not be so moved at all (failing
+// with blunder.CrossDeviceError) as its descendants' usage would need to be walked.
+//
+// Note: Caller must hold the locks on both directories
+func (vS *volumeStruct) quotaCheckMove(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber) (err error) {
+	var (
+		dstDirMetadata   *inode.MetadataStruct
+		movedInodeLock   *dlm.RWLockStruct
+		movedInodeNumber inode.InodeNumber
+		movedMetadata    *inode.MetadataStruct
+		ok               bool
+		quota            *quotaStruct
+		srcDirMetadata   *inode.MetadataStruct
+		usedBytes        uint64
+	)
+
+	if (0 == len(vS.quotaList)) || (srcDirInodeNumber == dstDirInodeNumber) {
+		return
+	}
+
+	srcDirMetadata, err = vS.VolumeHandle.GetMetadata(srcDirInodeNumber)
+	if nil != err {
+		return
+	}
+	dstDirMetadata, err = vS.VolumeHandle.GetMetadata(dstDirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if (srcDirMetadata.QuotaTree == dstDirMetadata.QuotaTree) || (inode.InodeNumber(0) == dstDirMetadata.QuotaTree) {
+		return
+	}
+
+	vS.quotaMutex.Lock()
+	quota, ok = vS.quotaTreeMap[dstDirMetadata.QuotaTree]
+	vS.quotaMutex.Unlock()
+
+	if !ok || ((0 == quota.softBytes) && (0 == quota.hardBytes) && (0 == quota.softInodes) && (0 == quota.hardInodes)) {
+		return // A project without limits
+	}
+
+	movedInodeNumber, err = vS.VolumeHandle.Lookup(srcDirInodeNumber, srcBasename)
+	if nil != err {
+		err = nil // Let Move() report the problem
+		return
+	}
+
+	movedInodeLock, err = vS.initInodeLock(movedInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = movedInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+
+	movedMetadata, err = vS.VolumeHandle.GetMetadata(movedInodeNumber)
+	if (nil == err) && (srcDirMetadata.QuotaTree == movedMetadata.QuotaTree) && (inode.DirType != movedMetadata.InodeType) {
+		usedBytes, _, err = vS.VolumeHandle.FetchInodeUsage(movedInodeNumber)
+	}
+
+	movedInodeLock.Unlock()
+
+	if nil != err {
+		return
+	}
+
+	if srcDirMetadata.QuotaTree != movedMetadata.QuotaTree {
+		return // The top of a nested quota tree (so it is not retagged)
+	}
+
+	if inode.DirType == movedMetadata.InodeType {
+		err = fmt.Errorf("%s: dir inode %v of volume \"%s\" may not move into quota tree %v from %v", utils.GetFnName(), movedInodeNumber, vS.volumeName, dstDirMetadata.QuotaTree, srcDirMetadata.QuotaTree)
+		err = blunder.AddError(err, blunder.CrossDeviceError)
+		return
+	}
+
+	vS.quotaMutex.Lock()
+	defer vS.quotaMutex.Unlock()
+
+	err = vS.quotaCheckOne(quota, time.Now(), usedBytes, 1)
+
+	return
+}
+
+// quotaTreeCheckLink fails with blunder.CrossDeviceError should targetInodeNumber be in a different quota tree than
+// dirInodeNumber (as the usage of an inode linked into both could only be charged to one of them)
+//
+// Note: Caller must hold the locks on both inodes
+func (vS *volumeStruct) quotaTreeCheckLink(dirInodeNumber inode.InodeNumber, targetInodeNumber inode.InodeNumber) (err error) {
+	var (
+		dirMetadata    *inode.MetadataStruct
+		targetMetadata *inode.MetadataStruct
+	)
+
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	dirMetadata, err = vS.VolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	targetMetadata, err = vS.VolumeHandle.GetMetadata(targetInodeNumber)
+	if nil != err {
+		return
+	}
+
+	if dirMetadata.QuotaTree != targetMetadata.QuotaTree {
+		err = fmt.Errorf("%s: inode %v of volume \"%s\" is in quota tree %v (not %v)", utils.GetFnName(), targetInodeNumber, vS.volumeName, targetMetadata.QuotaTree, dirMetadata.QuotaTree)
+		err = blunder.AddError(err, blunder.CrossDeviceError)
+	}
+
+	return
+}
+
+// quotaNoteUnflushed records the unflushed growth of fileInodeNumber (just written), charging it to each
+// applicable quota in place of whatever was previously noted for it
+//
+// Note: Caller must hold the lock on fileInodeNumber
+func (vS *volumeStruct) quotaNoteUnflushed(fileInodeNumber inode.InodeNumber) {
+	var (
+		err            error
+		metadata       *inode.MetadataStruct
+		ok             bool
+		quota          *quotaStruct
+		quotaUnflushed *quotaUnflushedStruct
+		unflushedBytes uint64
+	)
+
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	metadata, err = vS.VolumeHandle.GetMetadata(fileInodeNumber)
+	if nil == err {
+		_, unflushedBytes, err = vS.VolumeHandle.FetchInodeUsage(fileInodeNumber)
+	}
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s unflushed growth of inode %v unknown", vS.volumeName, fileInodeNumber)
+		unflushedBytes = 0
+	}
+
+	vS.quotaMutex.Lock()
+	defer vS.quotaMutex.Unlock()
+
+	vS.quotaDropUnflushedWhileLocked(fileInodeNumber)
+
+	if 0 == unflushedBytes {
+		return
+	}
+
+	quotaUnflushed = &quotaUnflushedStruct{
+		quotas: make([]*quotaStruct, 0, 3),
+		bytes:  unflushedBytes,
+	}
+
+	quota, ok = vS.quotaUserMap[metadata.UserID]
+	if ok {
+		quotaUnflushed.quotas = append(quotaUnflushed.quotas, quota)
+	}
+	quota, ok = vS.quotaGroupMap[metadata.GroupID]
+	if ok {
+		quotaUnflushed.quotas = append(quotaUnflushed.quotas, quota)
+	}
+	if inode.InodeNumber(0) != metadata.QuotaTree {
+		quota, ok = vS.quotaTreeMap[metadata.QuotaTree]
+		if ok {
+			quotaUnflushed.quotas = append(quotaUnflushed.quotas, quota)
+		}
+	}
+
+	if 0 == len(quotaUnflushed.quotas) {
+		return
+	}
+
+	for _, quota = range quotaUnflushed.quotas {
+		quota.unflushedBytes += unflushedBytes
+	}
+
+	vS.quotaUnflushedMap[fileInodeNumber] = quotaUnflushed
+}
+
+// quotaDropUnflushed drops the unflushed growth noted for fileInodeNumber (as it has been flushed or destroyed)
+func (vS *volumeStruct) quotaDropUnflushed(fileInodeNumber inode.InodeNumber) {
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	vS.quotaMutex.Lock()
+	vS.quotaDropUnflushedWhileLocked(fileInodeNumber)
+	vS.quotaMutex.Unlock()
+}
+
+// quotaDropUnflushedWhileLocked is quotaDropUnflushed() for callers already holding vS.quotaMutex
+//
+// Note: Caller must hold vS.quotaMutex
+func (vS *volumeStruct) quotaDropUnflushedWhileLocked(fileInodeNumber inode.InodeNumber) {
+	var (
+		ok             bool
+		quota          *quotaStruct
+		quotaUnflushed *quotaUnflushedStruct
+	)
+
+	quotaUnflushed, ok = vS.quotaUnflushedMap[fileInodeNumber]
+	if !ok {
+		return
+	}
+
+	for _, quota = range quotaUnflushed.quotas {
+		if quotaUnflushed.bytes < quota.unflushedBytes {
+			quota.unflushedBytes -= quotaUnflushed.bytes
+		} else {
+			quota.unflushedBytes = 0
+		}
+	}
+
+	delete(vS.quotaUnflushedMap, fileInodeNumber)
+}
+
+// quotaCheck fails with blunder.QuotaExceededError if charging addBytes and addInodes to the
+// specified owner and quota tree would exceed an applicable quota
+func (vS *volumeStruct) quotaCheck(userID inode.InodeUserID, groupID inode.InodeGroupID, quotaTree inode.InodeNumber, addBytes uint64, addInodes uint64) (err error) {
+	var (
+		now   time.Time
+		ok    bool
+		quota *quotaStruct
+	)
+
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	vS.quotaMutex.Lock()
+	defer vS.quotaMutex.Unlock()
+
+	now = time.Now()
+
+	quota, ok = vS.quotaUserMap[userID]
+	if ok {
+		err = vS.quotaCheckOne(quota, now, addBytes, addInodes)
+		if nil != err {
+			return
+		}
+	}
+
+	quota, ok = vS.quotaGroupMap[groupID]
+	if ok {
+		err = vS.quotaCheckOne(quota, now, addBytes, addInodes)
+		if nil != err {
+			return
+		}
+	}
+
+	if inode.InodeNumber(0) != quotaTree {
+		quota, ok = vS.quotaTreeMap[quotaTree]
+		if ok {
+			err = vS.quotaCheckOne(quota, now, addBytes, addInodes)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// quotaCheckInode checks the quotas applicable to an existing inode before it grows to newSize
+func (vS *volumeStruct) quotaCheckInode(inodeNumber inode.InodeNumber, newSize uint64) (err error) {
+	var (
+		metadata *inode.MetadataStruct
+	)
+
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	metadata, err = vS.VolumeHandle.GetMetadata(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if newSize <= metadata.Size {
+		return
+	}
+
+	err = vS.quotaCheck(metadata.UserID, metadata.GroupID, metadata.QuotaTree, newSize-metadata.Size, 0)
+
+	return
+}
+
+// quotaCheckCreate checks the quotas applicable to inodes about to be created in dirInodeNumber
+func (vS *volumeStruct) quotaCheckCreate(userID inode.InodeUserID, groupID inode.InodeGroupID, dirInodeNumber inode.InodeNumber, addBytes uint64, addInodes uint64) (err error) {
+	var (
+		metadata *inode.MetadataStruct
+	)
+
+	if 0 == len(vS.quotaList) {
+		return
+	}
+
+	metadata, err = vS.VolumeHandle.GetMetadata(dirInodeNumber)
+	if nil != err {
+		return
+	}
+
+	err = vS.quotaCheck(userID, groupID, metadata.QuotaTree, addBytes, addInodes)
+
+	return
+}
+
+// quotaCheckOne applies a single quota
+//
+// Note: Caller must hold vS.quotaMutex
+func (vS *volumeStruct) quotaCheckOne(quota *quotaStruct, now time.Time, addBytes uint64, addInodes uint64) (err error) {
+	var (
+		usedBytes  uint64
+		usedInodes uint64
+	)
+
+	usedBytes, usedInodes, err = vS.quotaFetchUsage(quota)
+	if nil != err {
+		return
+	}
+
+	usedBytes += quota.unflushedBytes
+
+	err = vS.quotaCheckLimit(quota, "bytes", now, usedBytes, addBytes, quota.softBytes, quota.hardBytes, &quota.bytesGraceStart)
+	if nil != err {
+		return
+	}
+
+	err = vS.quotaCheckLimit(quota, "inodes", now, usedInodes, addInodes, quota.softInodes, quota.hardInodes, &quota.inodesGraceStart)
+
+	return
+}
+
+// quotaCheckLimit applies one of a quota's soft/hard limit pairs, starting (or clearing) its grace period as necessary
+//
+// Note: Caller must hold vS.quotaMutex
+func (vS *volumeStruct) quotaCheckLimit(quota *quotaStruct, what string, now time.Time, used uint64, add uint64, soft uint64, hard uint64, graceStart *time.Time) (err error) {
+	if (0 == soft) || (used <= soft) {
+		*graceStart = time.Time{}
+	}
+
+	if 0 == add {
+		return
+	}
+
+	if (0 != hard) && (hard < used+add) {
+		err = fmt.Errorf("%s: quota \"%s\" of volume \"%s\" hard limit of %v %s exceeded", utils.GetFnName(), quota.name, vS.volumeName, hard, what)
+		err = blunder.AddError(err, blunder.QuotaExceededError)
+		stats.IncrementOperations(&stats.FsQuotaExceededOps)
+		return
+	}
+
+	if (0 != soft) && (soft < used+add) {
+		if graceStart.IsZero() {
+			*graceStart = now
+		} else if quota.gracePeriod < now.Sub(*graceStart) {
+			err = fmt.Errorf("%s: quota \"%s\" of volume \"%s\" soft limit of %v %s exceeded beyond grace period", utils.GetFnName(), quota.name, vS.volumeName, soft, what)
+			err = blunder.AddError(err, blunder.QuotaExceededError)
+			stats.IncrementOperations(&stats.FsQuotaExceededOps)
+			return
+		}
+	}
+
+	return
+}
+
+func (vS *volumeStruct) quotaFetchUsage(quota *quotaStruct) (usedBytes uint64, usedInodes uint64, err error) {
+	switch quota.quotaType {
+	case QuotaTypeUser:
+		usedBytes, usedInodes, err = vS.VolumeHandle.FetchUserUsage(inode.InodeUserID(quota.id))
+	case QuotaTypeGroup:
+		usedBytes, usedInodes, err = vS.VolumeHandle.FetchGroupUsage(inode.InodeGroupID(quota.id))
+	default: // QuotaTypeTree
+		usedBytes, usedInodes, err = vS.VolumeHandle.FetchQuotaTreeUsage(inode.InodeNumber(quota.id))
+	}
+	return
+}
+
+// quotaTreeXAttr returns the value of ProjectBytesXAttrName or ProjectInodesXAttrName for the
+// directory of a QuotaTypeTree quota... failing with blunder.StreamNotFound for any other inode
+func (vS *volumeStruct) quotaTreeXAttr(inodeNumber inode.InodeNumber, streamName string) (value []byte, err error) {
+	var (
+		ok         bool
+		usedBytes  uint64
+		usedInodes uint64
+	)
+
+	vS.quotaMutex.Lock()
+	_, ok = vS.quotaTreeMap[inodeNumber]
+	vS.quotaMutex.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: inode %v of volume \"%s\" is not the directory of a quota tree", utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.StreamNotFound)
+		return
+	}
+
+	usedBytes, usedInodes, err = vS.VolumeHandle.FetchQuotaTreeUsage(inodeNumber)
+	if nil != err {
+		return
+	}
+
+	if ProjectBytesXAttrName == streamName {
+		value = []byte(strconv.FormatUint(usedBytes, 10))
+	} else {
+		value = []byte(strconv.FormatUint(usedInodes, 10))
+	}
+
+	return
+}
+
+func fetchQuotaReport(volumeName string) (quotaReport []QuotaStatusStruct, err error) {
+	var (
+		ok          bool
+		quotaStatus QuotaStatusStruct
+		vS          *volumeStruct
+	)
+
+	globals.Lock()
+	vS, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	vS.quotaMutex.Lock()
+	defer vS.quotaMutex.Unlock()
+
+	quotaReport = make([]QuotaStatusStruct, 0, len(vS.quotaList))
+
+	for _, quota := range vS.quotaList {
+		quotaStatus = QuotaStatusStruct{
+			Name:        quota.name,
+			Type:        quota.quotaType,
+			ID:          quota.id,
+			Path:        quota.path,
+			SoftBytes:   quota.softBytes,
+			HardBytes:   quota.hardBytes,
+			SoftInodes:  quota.softInodes,
+			HardInodes:  quota.hardInodes,
+			GracePeriod: quota.gracePeriod,
+		}
+
+		quotaStatus.UsedBytes, quotaStatus.UsedInodes, err = vS.quotaFetchUsage(quota)
+		if nil != err {
+			return
+		}
+
+		if (0 != quota.softBytes) && (quota.softBytes < quotaStatus.UsedBytes) && !quota.bytesGraceStart.IsZero() {
+			quotaStatus.BytesGraceExpiration = quota.bytesGraceStart.Add(quota.gracePeriod)
+		}
+		if (0 != quota.softInodes) && (quota.softInodes < quotaStatus.UsedInodes) && !quota.inodesGraceStart.IsZero() {
+			quotaStatus.InodesGraceExpiration = quota.inodesGraceStart.Add(quota.gracePeriod)
+		}
+
+		quotaReport = append(quotaReport, quotaStatus)
+	}
+
+	return
+}
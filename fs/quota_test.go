@@ -0,0 +1,406 @@
+package fs
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestQuota(t *testing.T) {
+	vS := mS.volStruct
+
+	savedQuotaList := vS.quotaList
+	savedQuotaUserMap := vS.quotaUserMap
+	savedQuotaGroupMap := vS.quotaGroupMap
+	savedQuotaTreeMap := vS.quotaTreeMap
+	savedQuotaUnflushedMap := vS.quotaUnflushedMap
+	defer func() {
+		vS.quotaList = savedQuotaList
+		vS.quotaUserMap = savedQuotaUserMap
+		vS.quotaGroupMap = savedQuotaGroupMap
+		vS.quotaTreeMap = savedQuotaTreeMap
+		vS.quotaUnflushedMap = savedQuotaUnflushedMap
+	}()
+
+	vS.quotaList = make([]*quotaStruct, 0)
+	vS.quotaUserMap = make(map[inode.InodeUserID]*quotaStruct)
+	vS.quotaGroupMap = make(map[inode.InodeGroupID]*quotaStruct)
+	vS.quotaTreeMap = make(map[inode.InodeNumber]*quotaStruct)
+	vS.quotaUnflushedMap = make(map[inode.InodeNumber]*quotaUnflushedStruct)
+
+	dirInodeNumber := createTestDirectory(t, "TestQuota")
+
+	treeQuota := &quotaStruct{name: "TestTree", quotaType: QuotaTypeTree, path: "/TestQuota", hardInodes: 3, gracePeriod: time.Hour}
+
+	err := vS.quotaAdd(treeQuota)
+	if nil != err {
+		t.Fatalf("quotaAdd(TestTree) failed: %v", err)
+	}
+	if uint64(dirInodeNumber) != treeQuota.id {
+		t.Fatalf("quotaAdd(TestTree) resolved Path to %v (expected %v)", treeQuota.id, dirInodeNumber)
+	}
+
+	err = vS.quotaAdd(&quotaStruct{name: "DupTree", quotaType: QuotaTypeTree, path: "TestQuota"})
+	if nil == err {
+		t.Fatalf("quotaAdd(DupTree) should have failed")
+	}
+
+	// The directory itself counts against the tree's HardInodes
+
+	file1InodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File1", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(File1) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file1InodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File1) failed: %v", err)
+	}
+
+	metadata, err := vS.VolumeHandle.GetMetadata(file1InodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata(File1) failed: %v", err)
+	}
+	if dirInodeNumber != metadata.QuotaTree {
+		t.Fatalf("Create(File1) returned an inode in QuotaTree %v (expected %v)", metadata.QuotaTree, dirInodeNumber)
+	}
+
+	file2InodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File2", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(File2) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File2) failed: %v", err)
+	}
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File3", inode.PosixModePerm)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Create(File3) should have failed with QuotaExceededError: %v", err)
+	}
+	_, err = mS.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Dir", inode.PosixModePerm)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Mkdir(Dir) should have failed with QuotaExceededError: %v", err)
+	}
+	_, err = mS.Symlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Symlink", "File1")
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Symlink(Symlink) should have failed with QuotaExceededError: %v", err)
+	}
+
+	// Now exercise a hard byte limit
+
+	vS.quotaMutex.Lock()
+	treeQuota.hardInodes = 0
+	treeQuota.hardBytes = 8
+	vS.quotaMutex.Unlock()
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file1InodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, nil)
+	if nil != err {
+		t.Fatalf("Write(File1) [within HardBytes] failed: %v", err)
+	}
+
+	// Unflushed growth counts against the limit as well
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber, 0, []byte{0x08}, nil)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Write(File2) [beyond HardBytes before Flush(File1)] should have failed with QuotaExceededError: %v", err)
+	}
+
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file1InodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File1) failed: %v", err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber, 0, []byte{0x08}, nil)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Write(File2) [beyond HardBytes] should have failed with QuotaExceededError: %v", err)
+	}
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file1InodeNumber, 9)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Resize(File1) [beyond HardBytes] should have failed with QuotaExceededError: %v", err)
+	}
+
+	// A soft byte limit may be exceeded until its grace period expires
+
+	vS.quotaMutex.Lock()
+	treeQuota.hardBytes = 0
+	treeQuota.softBytes = 8
+	vS.quotaMutex.Unlock()
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber, 0, []byte{0x08}, nil)
+	if nil != err {
+		t.Fatalf("Write(File2) [within grace period] failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File2) failed: %v", err)
+	}
+
+	vS.quotaMutex.Lock()
+	bytesGraceStart := treeQuota.bytesGraceStart
+	vS.quotaMutex.Unlock()
+
+	if bytesGraceStart.IsZero() {
+		t.Fatalf("Write(File2) beyond SoftBytes should have started the grace period")
+	}
+
+	// Also enforce a User quota to confirm it is reported
+
+	err = vS.quotaAdd(&quotaStruct{name: "TestUser", quotaType: QuotaTypeUser, id: 17, hardInodes: 1, gracePeriod: time.Hour})
+	if nil != err {
+		t.Fatalf("quotaAdd(TestUser) failed: %v", err)
+	}
+
+	quotaReport, err := FetchQuotaReport("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchQuotaReport() failed: %v", err)
+	}
+	if 2 != len(quotaReport) {
+		t.Fatalf("FetchQuotaReport() returned %v quotas (expected 2)", len(quotaReport))
+	}
+	if ("TestTree" != quotaReport[0].Name) || (QuotaTypeTree != quotaReport[0].Type) || (9 != quotaReport[0].UsedBytes) || (3 != quotaReport[0].UsedInodes) {
+		t.Fatalf("FetchQuotaReport() returned unexpected %+v for TestTree", quotaReport[0])
+	}
+	if !quotaReport[0].BytesGraceExpiration.Equal(bytesGraceStart.Add(time.Hour)) {
+		t.Fatalf("FetchQuotaReport() returned BytesGraceExpiration %v (expected %v)", quotaReport[0].BytesGraceExpiration, bytesGraceStart.Add(time.Hour))
+	}
+	if ("TestUser" != quotaReport[1].Name) || (QuotaTypeUser != quotaReport[1].Type) || (17 != quotaReport[1].ID) || (0 != quotaReport[1].UsedInodes) {
+		t.Fatalf("FetchQuotaReport() returned unexpected %+v for TestUser", quotaReport[1])
+	}
+
+	// Once the grace period has expired, the soft limit is enforced
+
+	vS.quotaMutex.Lock()
+	treeQuota.bytesGraceStart = time.Now().Add(-2 * time.Hour)
+	vS.quotaMutex.Unlock()
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber, 1, []byte{0x09}, nil)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Write(File2) [after grace period] should have failed with QuotaExceededError: %v", err)
+	}
+
+	// Dropping back under the soft limit clears the grace period
+
+	err = mS.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber, 0)
+	if nil != err {
+		t.Fatalf("Resize(File2) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, file2InodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File2) failed: %v", err)
+	}
+
+	userFileInodeNumber, err := mS.Create(inode.InodeUserID(17), inode.InodeGroupID(0), nil, dirInodeNumber, "UserFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(UserFile) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeUserID(17), inode.InodeGroupID(0), nil, userFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(UserFile) failed: %v", err)
+	}
+
+	vS.quotaMutex.Lock()
+	bytesGraceStart = treeQuota.bytesGraceStart
+	vS.quotaMutex.Unlock()
+
+	if !bytesGraceStart.IsZero() {
+		t.Fatalf("Dropping below SoftBytes should have cleared the grace period")
+	}
+
+	_, err = mS.Create(inode.InodeUserID(17), inode.InodeGroupID(0), nil, dirInodeNumber, "UserFile2", inode.PosixModePerm)
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Create(UserFile2) should have failed with QuotaExceededError: %v", err)
+	}
+
+	_, err = FetchQuotaReport("NoSuchVolume")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchQuotaReport() of NoSuchVolume should have failed with NotFoundError: %v", err)
+	}
+
+	for _, basename := range []string{"File1", "File2", "UserFile"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink(%s) failed: %v", basename, err)
+		}
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestQuota")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
+
+func testProjectCheck(t *testing.T, step string, dirInodeNumber inode.InodeNumber, expectedUsedBytes uint64, expectedUsedInodes uint64) {
+	usedBytes, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, ProjectBytesXAttrName)
+	if nil != err {
+		t.Fatalf("GetXAttr(ProjectBytesXAttrName) [%s] failed: %v", step, err)
+	}
+	usedInodes, err := mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, ProjectInodesXAttrName)
+	if nil != err {
+		t.Fatalf("GetXAttr(ProjectInodesXAttrName) [%s] failed: %v", step, err)
+	}
+	if (strconv.FormatUint(expectedUsedBytes, 10) != string(usedBytes)) || (strconv.FormatUint(expectedUsedInodes, 10) != string(usedInodes)) {
+		t.Fatalf("GetXAttr() [%s] of dir inode %v returned %s bytes & %s inodes (expected %v & %v)", step, dirInodeNumber, usedBytes, usedInodes, expectedUsedBytes, expectedUsedInodes)
+	}
+}
+
+func TestQuotaTreeRename(t *testing.T) {
+	vS := mS.volStruct
+
+	savedQuotaList := vS.quotaList
+	savedQuotaUserMap := vS.quotaUserMap
+	savedQuotaGroupMap := vS.quotaGroupMap
+	savedQuotaTreeMap := vS.quotaTreeMap
+	savedQuotaUnflushedMap := vS.quotaUnflushedMap
+	defer func() {
+		vS.quotaList = savedQuotaList
+		vS.quotaUserMap = savedQuotaUserMap
+		vS.quotaGroupMap = savedQuotaGroupMap
+		vS.quotaTreeMap = savedQuotaTreeMap
+		vS.quotaUnflushedMap = savedQuotaUnflushedMap
+	}()
+
+	vS.quotaList = make([]*quotaStruct, 0)
+	vS.quotaUserMap = make(map[inode.InodeUserID]*quotaStruct)
+	vS.quotaGroupMap = make(map[inode.InodeGroupID]*quotaStruct)
+	vS.quotaTreeMap = make(map[inode.InodeNumber]*quotaStruct)
+	vS.quotaUnflushedMap = make(map[inode.InodeNumber]*quotaUnflushedStruct)
+
+	projectAInodeNumber := createTestDirectory(t, "TestProjectA")
+	projectBInodeNumber := createTestDirectory(t, "TestProjectB")
+
+	// Populate TestProjectA before making it a project to exercise tagging an existing tree
+
+	subDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectAInodeNumber, "SubDir", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Mkdir(SubDir) failed: %v", err)
+	}
+	subFileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, "SubFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(SubFile) failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subFileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+	if nil != err {
+		t.Fatalf("Write(SubFile) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subFileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(SubFile) failed: %v", err)
+	}
+
+	for _, project := range []string{"TestProjectA", "TestProjectB"} {
+		err = vS.quotaAdd(&quotaStruct{name: project, quotaType: QuotaTypeTree, path: project, gracePeriod: time.Hour})
+		if nil != err {
+			t.Fatalf("quotaAdd(%s) failed: %v", project, err)
+		}
+	}
+
+	testProjectCheck(t, "after tagging", projectAInodeNumber, 4, 3)
+	testProjectCheck(t, "after tagging", projectBInodeNumber, 0, 1)
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectAInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(File) failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, nil)
+	if nil != err {
+		t.Fatalf("Write(File) failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush(File) failed: %v", err)
+	}
+
+	testProjectCheck(t, "after Create(File)", projectAInodeNumber, 12, 4)
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectAInodeNumber, "File", projectBInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Rename(File) failed: %v", err)
+	}
+
+	testProjectCheck(t, "after Rename(File)", projectAInodeNumber, 4, 3)
+	testProjectCheck(t, "after Rename(File)", projectBInodeNumber, 8, 2)
+
+	// A hard link may not span projects (as File's usage can only be charged to one of them)
+
+	err = mS.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectAInodeNumber, "FileLink", fileInodeNumber)
+	if !blunder.Is(err, blunder.CrossDeviceError) {
+		t.Fatalf("Link(FileLink) [across projects] should have failed with CrossDeviceError: %v", err)
+	}
+	err = mS.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "FileLink", fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Link(FileLink) [within project] failed: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "FileLink")
+	if nil != err {
+		t.Fatalf("Unlink(FileLink) failed: %v", err)
+	}
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectAInodeNumber, "SubDir", projectBInodeNumber, "SubDir")
+	if nil != err {
+		t.Fatalf("Rename(SubDir) failed: %v", err)
+	}
+
+	testProjectCheck(t, "after Rename(SubDir)", projectAInodeNumber, 0, 1)
+	testProjectCheck(t, "after Rename(SubDir)", projectBInodeNumber, 12, 4)
+
+	// Moving into a project with limits is checked against them (and refused for a directory)
+
+	vS.quotaMutex.Lock()
+	vS.quotaTreeMap[projectAInodeNumber].hardBytes = 4
+	vS.quotaMutex.Unlock()
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "File", projectAInodeNumber, "File")
+	if !blunder.Is(err, blunder.QuotaExceededError) {
+		t.Fatalf("Rename(File) [beyond HardBytes] should have failed with QuotaExceededError: %v", err)
+	}
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "SubDir", projectAInodeNumber, "SubDir")
+	if !blunder.Is(err, blunder.CrossDeviceError) {
+		t.Fatalf("Rename(SubDir) [into limited project] should have failed with CrossDeviceError: %v", err)
+	}
+
+	vS.quotaMutex.Lock()
+	vS.quotaTreeMap[projectAInodeNumber].hardBytes = 0
+	vS.quotaMutex.Unlock()
+
+	testProjectCheck(t, "after failed Rename()s", projectAInodeNumber, 0, 1)
+	testProjectCheck(t, "after failed Rename()s", projectBInodeNumber, 12, 4)
+
+	// Moving out of any project drops the usage from the project entirely
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "SubDir", inode.RootDirInodeNumber, "TestProjectSubDir")
+	if nil != err {
+		t.Fatalf("Rename(SubDir) [out of project] failed: %v", err)
+	}
+
+	testProjectCheck(t, "after Rename(SubDir) out of project", projectBInodeNumber, 8, 2)
+
+	metadata, err := vS.VolumeHandle.GetMetadata(subFileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata(SubFile) failed: %v", err)
+	}
+	if inode.InodeNumber(0) != metadata.QuotaTree {
+		t.Fatalf("Rename(SubDir) out of project left SubFile in QuotaTree %v", metadata.QuotaTree)
+	}
+
+	_, err = mS.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, ProjectBytesXAttrName)
+	if !blunder.Is(err, blunder.StreamNotFound) {
+		t.Fatalf("GetXAttr(ProjectBytesXAttrName) of a non-project dir should have failed with StreamNotFound: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, subDirInodeNumber, "SubFile")
+	if nil != err {
+		t.Fatalf("Unlink(SubFile) failed: %v", err)
+	}
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, projectBInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink(File) failed: %v", err)
+	}
+	for _, dirName := range []string{"TestProjectSubDir", "TestProjectA", "TestProjectB"} {
+		err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, dirName)
+		if nil != err {
+			t.Fatalf("Rmdir(%s) failed: %v", dirName, err)
+		}
+	}
+}
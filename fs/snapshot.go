@@ -0,0 +1,233 @@
+package fs
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/headhunter"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// Snapshots are maintained by package headhunter. Each may be mounted (read-only) by passing a volumeName of
+// the form "<volumeName>/.snapshot/<snapshotName>" to Mount(). All such mounts of a given snapshot share a
+// (read-only) volumeStruct recorded in the snapshotVolumeMap of the volume's volumeStruct.
+
+// parseMountVolumeName splits a volumeName passed to Mount() into the volume's name and, if the snapshot
+// form "<volumeName>/.snapshot/<snapshotName>" was used, the name of the snapshot
+func parseMountVolumeName(mountVolumeName string) (volumeName string, snapshotName string, err error) {
+	var (
+		mountVolumeNameSlice []string
+	)
+
+	mountVolumeNameSlice = strings.Split(mountVolumeName, "/")
+
+	switch len(mountVolumeNameSlice) {
+	case 1:
+		volumeName = mountVolumeName
+		snapshotName = ""
+	case 3:
+		if (SnapshotDirName != mountVolumeNameSlice[1]) || ("" == mountVolumeNameSlice[2]) {
+			err = fmt.Errorf("%s: invalid volumeName \"%s\"", utils.GetFnName(), mountVolumeName)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+		volumeName = mountVolumeNameSlice[0]
+		snapshotName = mountVolumeNameSlice[2]
+	default:
+		err = fmt.Errorf("%s: invalid volumeName \"%s\"", utils.GetFnName(), mountVolumeName)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	err = nil
+	return
+}
+
+func fetchHeadhunterSnapshot(volumeName string, snapshotName string) (snapshot headhunter.SnapshotStruct, err error) {
+	headhunterVolumeHandle, err := headhunter.FetchVolumeHandle(volumeName)
+	if nil != err {
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	for _, snapshot = range headhunterVolumeHandle.FetchSnapshotList() {
+		if snapshot.Name == snapshotName {
+			err = nil
+			return
+		}
+	}
+
+	err = fmt.Errorf("%s: snapshot \"%s\" of volume \"%s\" not found", utils.GetFnName(), snapshotName, volumeName)
+	err = blunder.AddError(err, blunder.NotFoundError)
+	return
+}
+
+// fetchSnapshotVolumeWhileLocked returns the (read-only) volumeStruct presenting the named snapshot of vS
+//
+// Note: Caller must hold globals.Lock()
+func (vS *volumeStruct) fetchSnapshotVolumeWhileLocked(snapshotName string) (snapshotVolume *volumeStruct, err error) {
+	var (
+		ok       bool
+		snapshot headhunter.SnapshotStruct
+	)
+
+	snapshot, err = fetchHeadhunterSnapshot(vS.volumeName, snapshotName)
+	if nil != err {
+		return
+	}
+
+	snapshotVolume, ok = vS.snapshotVolumeMap[snapshotName]
+	if ok && (snapshotVolume.snapshotID == snapshot.ID) {
+		err = nil
+		return
+	}
+
+	// Either not yet mounted or mounted before the snapshot was deleted (and a new one of the same name created)
+
+	if ok {
+		snapshotVolume.watchDown()
+		snapshotVolume.leaseDown()
+		snapshotVolume.flockDown()
+	}
+
+	snapshotVolume = &volumeStruct{
+		volumeName:               vS.volumeName,
+		doCheckpointPerFlush:     false,
+		maxFlushTime:             vS.maxFlushTime,
+		volumeSize:               vS.volumeSize,
+		maxInodes:                vS.maxInodes,
+		FLockMap:                 make(map[inode.InodeNumber]*list.List),
+		flockWaiterMap:           make(map[*flockWaiterStruct]struct{}),
+		flockPersistence:         false,
+		leaseMap:                 make(map[inode.InodeNumber]map[MountID]*leaseStruct),
+		leaseBreakingMap:         make(map[inode.InodeNumber]uint64),
+		leaseRecallsMap:          make(map[MountID]*mountLeaseRecallsStruct),
+		leaseRecallTimeout:       vS.leaseRecallTimeout,
+		watchMap:                 make(map[WatchID]*watchStruct),
+		watchDirMap:              make(map[inode.InodeNumber]*watchDirStruct),
+		watchParentMap:           make(map[inode.InodeNumber]map[watchEntryStruct]struct{}),
+		watchWrittenMap:          make(map[inode.InodeNumber]struct{}),
+		watchQueueDepth:          vS.watchQueueDepth,
+		watchVolumeIdleTimeout:   vS.watchVolumeIdleTimeout,
+		inFlightFileInodeDataMap: make(map[inode.InodeNumber]*inFlightFileInodeDataStruct),
+		mountList:                make([]MountID, 0),
+		snapshotName:             snapshotName,
+		snapshotID:               snapshot.ID,
+		snapshotVolumeMap:        make(map[string]*volumeStruct),
+	}
+
+	snapshotVolume.flockCond = sync.NewCond(&snapshotVolume.flockMutex)
+
+	snapshotVolume.VolumeHandle, err = inode.FetchSnapshotVolumeHandle(vS.volumeName, snapshotName)
+	if nil != err {
+		return
+	}
+
+	vS.snapshotVolumeMap[snapshotName] = snapshotVolume
+
+	err = nil
+	return
+}
+
+func createSnapshot(volumeName string, snapshotName string) (snapshot headhunter.SnapshotStruct, err error) {
+	var (
+		headhunterVolumeHandle headhunter.VolumeHandle
+		ok                     bool
+		vS                     *volumeStruct
+	)
+
+	globals.Lock()
+	vS, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	headhunterVolumeHandle, err = headhunter.FetchVolumeHandle(volumeName)
+	if nil != err {
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	// Ensure the snapshot includes all data written prior to this call
+
+	vS.untrackInFlightFileInodeDataAll()
+
+	snapshot, err = headhunterVolumeHandle.CreateSnapshot(snapshotName)
+
+	return
+}
+
+func deleteSnapshot(volumeName string, snapshotName string) (err error) {
+	var (
+		headhunterVolumeHandle headhunter.VolumeHandle
+		ok                     bool
+		snapshotVolume         *volumeStruct
+		vS                     *volumeStruct
+	)
+
+	globals.Lock()
+	defer globals.Unlock()
+
+	vS, ok = globals.volumeMap[volumeName]
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	// Any remaining mounts of the snapshot will subsequently fail (with blunder.NotFoundError) to access it
+
+	snapshotVolume, ok = vS.snapshotVolumeMap[snapshotName]
+	if ok {
+		snapshotVolume.watchDown()
+		snapshotVolume.leaseDown()
+		snapshotVolume.flockDown()
+		delete(vS.snapshotVolumeMap, snapshotName)
+	}
+
+	headhunterVolumeHandle, err = headhunter.FetchVolumeHandle(volumeName)
+	if nil != err {
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	err = headhunterVolumeHandle.DeleteSnapshot(snapshotName)
+
+	return
+}
+
+func fetchSnapshotList(volumeName string) (snapshotList []headhunter.SnapshotStruct, err error) {
+	var (
+		headhunterVolumeHandle headhunter.VolumeHandle
+		ok                     bool
+	)
+
+	globals.Lock()
+	_, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	headhunterVolumeHandle, err = headhunter.FetchVolumeHandle(volumeName)
+	if nil != err {
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	snapshotList = headhunterVolumeHandle.FetchSnapshotList()
+
+	err = nil
+	return
+}
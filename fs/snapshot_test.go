@@ -0,0 +1,123 @@
+package fs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestSnapshotMount(t *testing.T) {
+	var (
+		modifiedContents = []byte{0x04, 0x05, 0x06, 0x07}
+		originalContents = []byte{0x00, 0x01, 0x02, 0x03}
+	)
+
+	dirInodeNumber := createTestDirectory(t, "SnapshotMount")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "SnapshotFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, originalContents, nil)
+	if nil != err {
+		t.Fatalf("Write() [original] failed: %v", err)
+	}
+
+	snapshot, err := CreateSnapshot("TestVolume", "TestSnapshot")
+	if nil != err {
+		t.Fatalf("CreateSnapshot() failed: %v", err)
+	}
+
+	snapshotList, err := FetchSnapshotList("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchSnapshotList() failed: %v", err)
+	}
+	if (1 != len(snapshotList)) || (snapshot.ID != snapshotList[0].ID) {
+		t.Fatalf("FetchSnapshotList() returned unexpected list: %v", snapshotList)
+	}
+
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, modifiedContents, nil)
+	if nil != err {
+		t.Fatalf("Write() [modified] failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	_, err = Mount("TestVolume/"+SnapshotDirName+"/TestSnapshot", MountOptions(0))
+	if !blunder.Is(err, blunder.ReadOnlyError) {
+		t.Fatalf("Mount() of snapshot without MountReadOnly should have failed with ReadOnlyError: %v", err)
+	}
+
+	_, err = Mount("TestVolume/"+SnapshotDirName+"/NoSuchSnapshot", MountReadOnly)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Mount() of missing snapshot should have failed with NotFoundError: %v", err)
+	}
+
+	snapshotMountHandle, err := Mount("TestVolume/"+SnapshotDirName+"/TestSnapshot", MountReadOnly)
+	if nil != err {
+		t.Fatalf("Mount() of snapshot failed: %v", err)
+	}
+
+	snapshotFileInodeNumber, err := snapshotMountHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, "SnapshotMount/SnapshotFile")
+	if nil != err {
+		t.Fatalf("LookupPath() in snapshot failed: %v", err)
+	}
+	if fileInodeNumber != snapshotFileInodeNumber {
+		t.Fatalf("LookupPath() in snapshot returned unexpected InodeNumber %v (expected %v)", snapshotFileInodeNumber, fileInodeNumber)
+	}
+
+	buf, err := snapshotMountHandle.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, snapshotFileInodeNumber, 0, uint64(len(originalContents)), nil)
+	if nil != err {
+		t.Fatalf("Read() in snapshot failed: %v", err)
+	}
+	if 0 != bytes.Compare(originalContents, buf) {
+		t.Fatalf("Read() in snapshot returned %v (expected %v)", buf, originalContents)
+	}
+
+	_, err = snapshotMountHandle.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, snapshotFileInodeNumber, 0, modifiedContents, nil)
+	if !blunder.Is(err, blunder.ReadOnlyError) {
+		t.Fatalf("Write() in snapshot should have failed with ReadOnlyError: %v", err)
+	}
+
+	_, err = snapshotMountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "NewFile", inode.PosixModePerm)
+	if !blunder.Is(err, blunder.ReadOnlyError) {
+		t.Fatalf("Create() in snapshot should have failed with ReadOnlyError: %v", err)
+	}
+
+	buf, err = mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(modifiedContents)), nil)
+	if nil != err {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if 0 != bytes.Compare(modifiedContents, buf) {
+		t.Fatalf("Read() returned %v (expected %v)", buf, modifiedContents)
+	}
+
+	err = DeleteSnapshot("TestVolume", "TestSnapshot")
+	if nil != err {
+		t.Fatalf("DeleteSnapshot() failed: %v", err)
+	}
+
+	_, err = snapshotMountHandle.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, snapshotFileInodeNumber, 0, uint64(len(originalContents)), nil)
+	if nil == err {
+		t.Fatalf("Read() in deleted snapshot should have failed")
+	}
+
+	err = DeleteSnapshot("TestVolume", "TestSnapshot")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("DeleteSnapshot() [again] should have failed with NotFoundError: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "SnapshotFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "SnapshotMount")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
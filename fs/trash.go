@@ -0,0 +1,622 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// If TrashRetention is specified (and non-zero) for a volume, Unlink() of a file's last link and Rmdir() of a
+// directory move the inode into the volume's trash rather than destroying it. The trash is a directory named
+// TrashDirName in the volume's root, accessible only to the root user (and omitted from Swift account listings). The name of each entry records when it
+// was deleted along with its original parent directory and basename:
+//
+//   <DeletionTime.UnixNano() in %016X>.<InodeNumber in %016X>.<ParentInodeNumber in %016X>.<Basename>
+//
+// As such, entries list in the order they were deleted. The name of its entry is also recorded in each trashed
+// inode's TrashEntryStream so that the entry of a given inode may be found without searching the trash.
+// MiddlewareDelete() moves inodes to the trash just as Unlink() and Rmdir() do.
+//
+// Every trashExpireInterval, trashDaemon() destroys entries older than TrashRetention. It locks the trash
+// directory only while reading trashBatchSize entries at a time (as does FetchTrash(), which omits entries
+// already due to be destroyed). RestoreTrash() moves an entry back to its original parent directory (first
+// restoring that directory should it also be in the trash... as is the case for each file removed by "rm -rf").
+//
+// Unlink() and Rmdir() of entries in the trash directory itself destroy them immediately.
+
+const (
+	trashExpireInterval = time.Minute
+	trashBatchSize      = uint64(1024) // Trash entries read per hold of the trash directory's lock
+)
+
+func (vS *volumeStruct) trashUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	var (
+		inodeType inode.InodeType
+	)
+
+	vS.trashRetention, err = confMap.FetchOptionValueDuration(volumeSectionName, "TrashRetention")
+	if nil != err {
+		vS.trashRetention = time.Duration(0) // Trash not enabled
+	}
+
+	vS.trashDirInodeNumber = inode.InodeNumber(0)
+	vS.trashStopChan = nil
+
+	if time.Duration(0) == vS.trashRetention {
+		err = nil
+		return
+	}
+
+	vS.trashDirInodeNumber, err = vS.VolumeHandle.Lookup(inode.RootDirInodeNumber, TrashDirName)
+	if nil == err {
+		inodeType, err = vS.VolumeHandle.GetType(vS.trashDirInodeNumber)
+		if nil != err {
+			return
+		}
+		if inode.DirType != inodeType {
+			err = fmt.Errorf("%s: %s of volume \"%s\" is not a directory", utils.GetFnName(), TrashDirName, vS.volumeName)
+			return
+		}
+	} else {
+		if !blunder.Is(err, blunder.NotFoundError) {
+			return
+		}
+
+		vS.trashDirInodeNumber, err = vS.VolumeHandle.CreateDir(inode.InodeMode(0700), inode.InodeRootUserID, inode.InodeGroupID(0))
+		if nil != err {
+			return
+		}
+
+		err = vS.VolumeHandle.Link(inode.RootDirInodeNumber, TrashDirName, vS.trashDirInodeNumber)
+		if nil != err {
+			return
+		}
+	}
+
+	vS.trashStopChan = make(chan struct{}, 1)
+
+	vS.trashWG.Add(1)
+	go vS.trashDaemon()
+
+	return
+}
+
+func (vS *volumeStruct) trashDown() {
+	if nil == vS.trashStopChan {
+		return // Trash not enabled
+	}
+
+	vS.trashStopChan <- struct{}{}
+	vS.trashWG.Wait()
+
+	vS.trashStopChan = nil
+}
+
+// trashDaemon destroys expired trash entries every trashExpireInterval until stopped
+func (vS *volumeStruct) trashDaemon() {
+	for {
+		select {
+		case <-time.After(trashExpireInterval):
+			vS.trashExpire(time.Now().Add(-vS.trashRetention))
+		case <-vS.trashStopChan:
+			vS.trashWG.Done()
+			return
+		}
+	}
+}
+
+// trashWanted indicates whether Unlink() or Rmdir() from dirInodeNumber should move the inode to the trash
+func (vS *volumeStruct) trashWanted(dirInodeNumber inode.InodeNumber) (wanted bool) {
+	wanted = (time.Duration(0) != vS.trashRetention) && (vS.trashDirInodeNumber != dirInodeNumber)
+	return
+}
+
+func trashEntryName(trashEntry *TrashEntryStruct) (name string) {
+	name = fmt.Sprintf("%016X.%016X.%016X.%s", trashEntry.DeletionTime.UnixNano(), uint64(trashEntry.InodeNumber), uint64(trashEntry.ParentInodeNumber), trashEntry.Basename)
+	return
+}
+
+func trashEntryParse(name string) (trashEntry *TrashEntryStruct, ok bool) {
+	var (
+		deletionTimeUnixNano uint64
+		err                  error
+		inodeNumber          uint64
+		nameSplit            []string
+		parentInodeNumber    uint64
+	)
+
+	nameSplit = strings.SplitN(name, ".", 4)
+	if (4 != len(nameSplit)) || (16 != len(nameSplit[0])) || (16 != len(nameSplit[1])) || (16 != len(nameSplit[2])) || ("" == nameSplit[3]) {
+		ok = false
+		return
+	}
+
+	deletionTimeUnixNano, err = strconv.ParseUint(nameSplit[0], 16, 64)
+	if nil != err {
+		ok = false
+		return
+	}
+	inodeNumber, err = strconv.ParseUint(nameSplit[1], 16, 64)
+	if nil != err {
+		ok = false
+		return
+	}
+	parentInodeNumber, err = strconv.ParseUint(nameSplit[2], 16, 64)
+	if nil != err {
+		ok = false
+		return
+	}
+
+	trashEntry = &TrashEntryStruct{
+		Name:              name,
+		InodeNumber:       inode.InodeNumber(inodeNumber),
+		ParentInodeNumber: inode.InodeNumber(parentInodeNumber),
+		Basename:          nameSplit[3],
+		DeletionTime:      time.Unix(0, int64(deletionTimeUnixNano)),
+	}
+
+	ok = true
+	return
+}
+
+// trashInsert moves basename (referencing inodeNumber) in dirInodeNumber to the trash
+//
+// Note: Caller must hold write locks (obtained via callerID) on dirInodeNumber & inodeNumber
+func (vS *volumeStruct) trashInsert(callerID dlm.CallerID, dirInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) (err error) {
+	var (
+		inodeMetadata    *inode.MetadataStruct
+		now              time.Time
+		trashDirLock     *dlm.RWLockStruct
+		trashDirMetadata *inode.MetadataStruct
+		trashEntry       *TrashEntryStruct
+	)
+
+	trashDirLock, err = vS.initInodeLock(vS.trashDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	err = trashDirLock.WriteLock()
+	if nil != err {
+		return
+	}
+
+	now = time.Now()
+
+	trashEntry = &TrashEntryStruct{
+		InodeNumber:       inodeNumber,
+		ParentInodeNumber: dirInodeNumber,
+		Basename:          basename,
+		DeletionTime:      now,
+	}
+
+	trashEntry.Name = trashEntryName(trashEntry)
+
+	err = vS.VolumeHandle.Move(dirInodeNumber, basename, vS.trashDirInodeNumber, trashEntry.Name)
+	if nil != err {
+		trashDirLock.Unlock()
+		return
+	}
+
+	err = vS.VolumeHandle.PutStream(inodeNumber, TrashEntryStream, []byte(trashEntry.Name))
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s trashed inode %v could not record its trash entry", vS.volumeName, inodeNumber)
+		err = nil // trashLookup() will not find it... but it may still be listed, restored, and expired
+	}
+
+	// Usage of an inode in the trash no longer counts against the quota tree it was removed from
+
+	if 0 < len(vS.quotaList) {
+		trashDirMetadata, err = vS.VolumeHandle.GetMetadata(vS.trashDirInodeNumber)
+		if nil == err {
+			inodeMetadata, err = vS.VolumeHandle.GetMetadata(inodeNumber)
+		}
+		if (nil == err) && (inodeNumber != inodeMetadata.QuotaTree) {
+			err = vS.VolumeHandle.SetQuotaTree(inodeNumber, trashDirMetadata.QuotaTree)
+		}
+		if nil != err {
+			logger.ErrorfWithError(err, "Volume %s trashed inode %v could not be retagged", vS.volumeName, inodeNumber)
+			err = nil
+		}
+	}
+
+	trashDirLock.Unlock()
+
+	stats.IncrementOperations(&stats.FsTrashInsertOps)
+
+	return
+}
+
+// trashExpire destroys trash entries deleted before expirationTime
+//
+// Entries that are locked (or are directories that are somehow not empty) are left for a subsequent pass.
+// Errors are logged rather than returned
+func (vS *volumeStruct) trashExpire(expirationTime time.Time) {
+	var (
+		dirEntry      inode.DirEntry
+		dirEntrySlice []inode.DirEntry
+		entryLock     *dlm.RWLockStruct
+		err           error
+		inodeType     inode.InodeType
+		moreEntries   bool
+		numDirEntries uint64
+		ok            bool
+		prevBasename  string
+		trashDirLock  *dlm.RWLockStruct
+		trashEntry    *TrashEntryStruct
+	)
+
+	trashDirLock, err = vS.initInodeLock(vS.trashDirInodeNumber, nil)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s trash could not be locked", vS.volumeName)
+		return
+	}
+
+	prevBasename = ""
+
+	for {
+		err = trashDirLock.WriteLock()
+		if nil != err {
+			logger.ErrorfWithError(err, "Volume %s trash could not be locked", vS.volumeName)
+			return
+		}
+
+		if "" == prevBasename {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(vS.trashDirInodeNumber, trashBatchSize, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(vS.trashDirInodeNumber, trashBatchSize, 0, prevBasename)
+		}
+		if nil != err {
+			trashDirLock.Unlock()
+			logger.ErrorfWithError(err, "Volume %s trash could not be read", vS.volumeName)
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			prevBasename = dirEntry.Basename
+
+			trashEntry, ok = trashEntryParse(dirEntry.Basename)
+			if !ok {
+				continue // Skips "." & ".." as well
+			}
+			if !trashEntry.DeletionTime.Before(expirationTime) {
+				trashDirLock.Unlock()
+				return
+			}
+
+			// Lock order is normally directory then entry... but this only tries so as to
+			// avoid deadlocking with trashInsert() which must take the trash dir lock last
+
+			entryLock, err = vS.initInodeLock(dirEntry.InodeNumber, trashDirLock.GetCallerID())
+			if nil != err {
+				continue
+			}
+			err = entryLock.TryWriteLock()
+			if nil != err {
+				continue
+			}
+
+			inodeType, err = vS.VolumeHandle.GetType(dirEntry.InodeNumber)
+			if nil != err {
+				entryLock.Unlock()
+				continue
+			}
+
+			if inode.DirType == inodeType {
+				numDirEntries, err = vS.VolumeHandle.NumDirEntries(dirEntry.InodeNumber)
+				if (nil != err) || (2 != numDirEntries) {
+					logger.Warnf("Volume %s trashed directory %s is not empty", vS.volumeName, dirEntry.Basename)
+					entryLock.Unlock()
+					continue
+				}
+			}
+
+			err = vS.VolumeHandle.Unlink(vS.trashDirInodeNumber, dirEntry.Basename)
+			if nil == err {
+				vS.untrackInFlightFileInodeData(dirEntry.InodeNumber, false)
+				err = vS.VolumeHandle.Destroy(dirEntry.InodeNumber)
+			}
+			if nil != err {
+				logger.ErrorfWithError(err, "Volume %s trashed %s could not be destroyed", vS.volumeName, dirEntry.Basename)
+			} else {
+				stats.IncrementOperations(&stats.FsTrashExpireOps)
+			}
+
+			entryLock.Unlock()
+		}
+
+		trashDirLock.Unlock()
+
+		if !moreEntries || (0 == len(dirEntrySlice)) {
+			return
+		}
+	}
+}
+
+// trashLookup returns the trash entry for inodeNumber (if any) as recorded in its TrashEntryStream
+func (vS *volumeStruct) trashLookup(inodeNumber inode.InodeNumber) (trashEntry *TrashEntryStruct, ok bool, err error) {
+	var (
+		inodeLock       *dlm.RWLockStruct
+		nameBuf         []byte
+		trashedInodeNum inode.InodeNumber
+	)
+
+	inodeLock, err = vS.initInodeLock(inodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = inodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	nameBuf, err = vS.VolumeHandle.GetStream(inodeNumber, TrashEntryStream)
+	inodeLock.Unlock()
+	if nil != err {
+		err = nil
+		ok = false
+		return
+	}
+
+	trashEntry, ok = trashEntryParse(string(nameBuf))
+	if !ok || (inodeNumber != trashEntry.InodeNumber) {
+		ok = false
+		return
+	}
+
+	// The record is only current while the entry remains in the trash
+
+	trashedInodeNum, err = vS.VolumeHandle.Lookup(vS.trashDirInodeNumber, trashEntry.Name)
+	if (nil != err) || (inodeNumber != trashedInodeNum) {
+		err = nil
+		ok = false
+		return
+	}
+
+	ok = true
+	return
+}
+
+// trashRestore moves trashEntry back to its original parent directory, first restoring
+// that directory should it also be in the trash
+func (vS *volumeStruct) trashRestore(trashEntry *TrashEntryStruct) (err error) {
+	var (
+		callerID                dlm.CallerID
+		entryLock               *dlm.RWLockStruct
+		inodeMetadata           *inode.MetadataStruct
+		inodeNumber             inode.InodeNumber
+		ok                      bool
+		parentDirLock           *dlm.RWLockStruct
+		parentMetadata          *inode.MetadataStruct
+		parentParentInodeNumber inode.InodeNumber
+		parentTrashEntry        *TrashEntryStruct
+		trashDirLock            *dlm.RWLockStruct
+	)
+
+	parentParentInodeNumber, err = vS.VolumeHandle.Lookup(trashEntry.ParentInodeNumber, "..")
+	if nil != err {
+		err = fmt.Errorf("%s: original parent directory of trashed %s of volume \"%s\" no longer exists", utils.GetFnName(), trashEntry.Name, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if vS.trashDirInodeNumber == parentParentInodeNumber {
+		parentTrashEntry, ok, err = vS.trashLookup(trashEntry.ParentInodeNumber)
+		if nil != err {
+			return
+		}
+		if ok {
+			err = vS.trashRestore(parentTrashEntry)
+			if nil != err {
+				return
+			}
+		}
+	}
+
+	callerID = dlm.GenerateCallerID()
+
+	parentDirLock, err = vS.initInodeLock(trashEntry.ParentInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	trashDirLock, err = vS.initInodeLock(vS.trashDirInodeNumber, callerID)
+	if nil != err {
+		return
+	}
+
+retryLock:
+	err = parentDirLock.WriteLock()
+	if nil != err {
+		return
+	}
+	err = trashDirLock.TryWriteLock()
+	if blunder.Is(err, blunder.TryAgainError) {
+		parentDirLock.Unlock()
+		goto retryLock
+	} else if blunder.IsNotSuccess(err) {
+		parentDirLock.Unlock()
+		return
+	}
+	defer parentDirLock.Unlock()
+	defer trashDirLock.Unlock()
+
+	inodeNumber, err = vS.VolumeHandle.Lookup(vS.trashDirInodeNumber, trashEntry.Name)
+	if nil != err {
+		err = fmt.Errorf("%s: %s not found in trash of volume \"%s\"", utils.GetFnName(), trashEntry.Name, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	_, err = vS.VolumeHandle.Lookup(trashEntry.ParentInodeNumber, trashEntry.Basename)
+	if nil == err {
+		err = fmt.Errorf("%s: %s of volume \"%s\" cannot be restored over existing %s", utils.GetFnName(), trashEntry.Name, vS.volumeName, trashEntry.Basename)
+		err = blunder.AddError(err, blunder.FileExistsError)
+		return
+	}
+
+	entryLock, err = vS.initInodeLock(inodeNumber, callerID)
+	if nil != err {
+		return
+	}
+	err = entryLock.WriteLock()
+	if nil != err {
+		return
+	}
+	defer entryLock.Unlock()
+
+	err = vS.VolumeHandle.Move(vS.trashDirInodeNumber, trashEntry.Name, trashEntry.ParentInodeNumber, trashEntry.Basename)
+	if nil != err {
+		return
+	}
+
+	err = vS.VolumeHandle.DeleteStream(inodeNumber, TrashEntryStream)
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s restored inode %v could not drop its trash entry", vS.volumeName, inodeNumber)
+		err = nil // trashLookup() checks that the recorded entry is still in the trash anyway
+	}
+
+	if 0 < len(vS.quotaList) {
+		parentMetadata, err = vS.VolumeHandle.GetMetadata(trashEntry.ParentInodeNumber)
+		if nil == err {
+			inodeMetadata, err = vS.VolumeHandle.GetMetadata(inodeNumber)
+		}
+		if (nil == err) && (inodeNumber != inodeMetadata.QuotaTree) {
+			err = vS.VolumeHandle.SetQuotaTree(inodeNumber, parentMetadata.QuotaTree)
+		}
+		if nil != err {
+			logger.ErrorfWithError(err, "Volume %s restored inode %v could not be retagged", vS.volumeName, inodeNumber)
+			err = nil
+		}
+	}
+
+	vS.watchNotifyEntry(WatchEventCreate, trashEntry.ParentInodeNumber, trashEntry.Basename, inodeNumber)
+	vS.journalAppend(JournalOpCreate, trashEntry.ParentInodeNumber, trashEntry.Basename, inodeNumber)
+
+	return
+}
+
+func trashFetchVolume(volumeName string) (vS *volumeStruct, err error) {
+	var (
+		ok bool
+	)
+
+	globals.Lock()
+	vS, ok = globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if time.Duration(0) == vS.trashRetention {
+		err = fmt.Errorf("%s: volume \"%s\" has no trash", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	return
+}
+
+func fetchTrash(volumeName string) (trashEntries []TrashEntryStruct, err error) {
+	var (
+		dirEntry       inode.DirEntry
+		dirEntrySlice  []inode.DirEntry
+		expirationTime time.Time
+		moreEntries    bool
+		ok             bool
+		prevBasename   string
+		trashDirLock   *dlm.RWLockStruct
+		trashEntry     *TrashEntryStruct
+		vS             *volumeStruct
+	)
+
+	vS, err = trashFetchVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	// Entries trashDaemon() has yet to destroy are omitted
+
+	expirationTime = time.Now().Add(-vS.trashRetention)
+
+	trashDirLock, err = vS.initInodeLock(vS.trashDirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+
+	trashEntries = make([]TrashEntryStruct, 0)
+
+	prevBasename = ""
+
+	for {
+		err = trashDirLock.ReadLock()
+		if nil != err {
+			return
+		}
+
+		if "" == prevBasename {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(vS.trashDirInodeNumber, trashBatchSize, 0)
+		} else {
+			dirEntrySlice, moreEntries, err = vS.VolumeHandle.ReadDir(vS.trashDirInodeNumber, trashBatchSize, 0, prevBasename)
+		}
+
+		trashDirLock.Unlock()
+
+		if nil != err {
+			return
+		}
+
+		for _, dirEntry = range dirEntrySlice {
+			trashEntry, ok = trashEntryParse(dirEntry.Basename)
+			if !ok || trashEntry.DeletionTime.Before(expirationTime) {
+				continue
+			}
+			trashEntry.InodeType, err = vS.VolumeHandle.GetType(dirEntry.InodeNumber)
+			if nil != err {
+				return
+			}
+			trashEntry.ExpirationTime = trashEntry.DeletionTime.Add(vS.trashRetention)
+			trashEntries = append(trashEntries, *trashEntry)
+		}
+
+		if !moreEntries || (0 == len(dirEntrySlice)) {
+			err = nil
+			return
+		}
+
+		prevBasename = dirEntrySlice[len(dirEntrySlice)-1].Basename
+	}
+}
+
+func restoreTrash(volumeName string, name string) (err error) {
+	var (
+		ok         bool
+		trashEntry *TrashEntryStruct
+		vS         *volumeStruct
+	)
+
+	vS, err = trashFetchVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	trashEntry, ok = trashEntryParse(name)
+	if !ok {
+		err = fmt.Errorf("%s: \"%s\" does not name a trash entry of volume \"%s\"", utils.GetFnName(), name, volumeName)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	err = vS.trashRestore(trashEntry)
+
+	return
+}
@@ -0,0 +1,195 @@
+package fs
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestTrash(t *testing.T) {
+	vS := mS.volStruct
+
+	confMap, err := conf.MakeConfMapFromStrings([]string{"Volume:TestVolume.TrashRetention=1h"})
+	if nil != err {
+		t.Fatalf("conf.MakeConfMapFromStrings() failed: %v", err)
+	}
+
+	err = vS.trashUp(confMap, "Volume:TestVolume")
+	if nil != err {
+		t.Fatalf("trashUp() failed: %v", err)
+	}
+
+	dirInodeNumber := createTestDirectory(t, "TestTrash")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	fileData := []byte{0x00, 0x01, 0x02, 0x03}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, fileData, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	// The equivalent of "rm -rf TestTrash"
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestTrash")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+
+	_, err = mS.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestTrash")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("Lookup() of removed directory should have failed with NotFoundError: %v", err)
+	}
+
+	trashEntries, err := FetchTrash("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchTrash() failed: %v", err)
+	}
+	if 2 != len(trashEntries) {
+		t.Fatalf("FetchTrash() returned %v entries (expected 2)", len(trashEntries))
+	}
+	if (fileInodeNumber != trashEntries[0].InodeNumber) || (inode.FileType != trashEntries[0].InodeType) ||
+		(dirInodeNumber != trashEntries[0].ParentInodeNumber) || ("File" != trashEntries[0].Basename) {
+		t.Fatalf("FetchTrash() returned unexpected %+v for the file", trashEntries[0])
+	}
+	if (dirInodeNumber != trashEntries[1].InodeNumber) || (inode.DirType != trashEntries[1].InodeType) ||
+		(inode.RootDirInodeNumber != trashEntries[1].ParentInodeNumber) || ("TestTrash" != trashEntries[1].Basename) {
+		t.Fatalf("FetchTrash() returned unexpected %+v for the directory", trashEntries[1])
+	}
+	if !trashEntries[0].ExpirationTime.Equal(trashEntries[0].DeletionTime.Add(time.Hour)) {
+		t.Fatalf("FetchTrash() returned ExpirationTime %v for DeletionTime %v", trashEntries[0].ExpirationTime, trashEntries[0].DeletionTime)
+	}
+
+	// Restoring the file must first restore the directory it was removed from
+
+	err = RestoreTrash("TestVolume", trashEntries[0].Name)
+	if nil != err {
+		t.Fatalf("RestoreTrash() of the file failed: %v", err)
+	}
+
+	inodeNumber, err := mS.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestTrash")
+	if (nil != err) || (dirInodeNumber != inodeNumber) {
+		t.Fatalf("Lookup() of restored directory returned %v [err: %v] (expected %v)", inodeNumber, err, dirInodeNumber)
+	}
+	inodeNumber, err = mS.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if (nil != err) || (fileInodeNumber != inodeNumber) {
+		t.Fatalf("Lookup() of restored file returned %v [err: %v] (expected %v)", inodeNumber, err, fileInodeNumber)
+	}
+	readData, err := mS.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, uint64(len(fileData)), nil)
+	if (nil != err) || (0 != bytes.Compare(fileData, readData)) {
+		t.Fatalf("Read() of restored file returned %v [err: %v] (expected %v)", readData, err, fileData)
+	}
+
+	trashEntries, err = FetchTrash("TestVolume")
+	if (nil != err) || (0 != len(trashEntries)) {
+		t.Fatalf("FetchTrash() after RestoreTrash() returned %+v [err: %v]", trashEntries, err)
+	}
+
+	err = RestoreTrash("TestVolume", "NotATrashEntry")
+	if !blunder.Is(err, blunder.InvalidArgError) {
+		t.Fatalf("RestoreTrash(\"NotATrashEntry\") should have failed with InvalidArgError: %v", err)
+	}
+
+	// Restoring over something created since fails
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() [second] failed: %v", err)
+	}
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [second] failed: %v", err)
+	}
+
+	trashEntries, err = FetchTrash("TestVolume")
+	if (nil != err) || (1 != len(trashEntries)) {
+		t.Fatalf("FetchTrash() after second Unlink() returned %+v [err: %v]", trashEntries, err)
+	}
+
+	err = RestoreTrash("TestVolume", trashEntries[0].Name)
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("RestoreTrash() over an existing file should have failed with FileExistsError: %v", err)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "File")
+	if nil != err {
+		t.Fatalf("Unlink() [third] failed: %v", err)
+	}
+
+	// MiddlewareDelete() trashes as well
+
+	_, err = mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "Object", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create(Object) failed: %v", err)
+	}
+	err = mS.MiddlewareDelete("TestTrash", "Object")
+	if nil != err {
+		t.Fatalf("MiddlewareDelete(Object) failed: %v", err)
+	}
+
+	trashEntries, err = FetchTrash("TestVolume")
+	if (nil != err) || (3 != len(trashEntries)) || ("Object" != trashEntries[2].Basename) || (dirInodeNumber != trashEntries[2].ParentInodeNumber) {
+		t.Fatalf("FetchTrash() after MiddlewareDelete() returned %+v [err: %v]", trashEntries, err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestTrash")
+	if nil != err {
+		t.Fatalf("Rmdir() [second] failed: %v", err)
+	}
+
+	// Each trashed inode records its trash entry
+
+	trashEntry, ok, err := vS.trashLookup(dirInodeNumber)
+	if (nil != err) || !ok || ("TestTrash" != trashEntry.Basename) || (inode.RootDirInodeNumber != trashEntry.ParentInodeNumber) {
+		t.Fatalf("trashLookup() of the directory returned %+v, %v [err: %v]", trashEntry, ok, err)
+	}
+
+	// Expiring everything destroys the inodes
+
+	vS.trashExpire(time.Now().Add(time.Second))
+
+	trashEntries, err = FetchTrash("TestVolume")
+	if (nil != err) || (0 != len(trashEntries)) {
+		t.Fatalf("FetchTrash() after trashExpire() returned %+v [err: %v]", trashEntries, err)
+	}
+
+	_, err = vS.VolumeHandle.GetType(fileInodeNumber)
+	if nil == err {
+		t.Fatalf("GetType() of expired file should have failed")
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, TrashDirName)
+	if !blunder.Is(err, blunder.NotPermError) {
+		t.Fatalf("Rmdir() of the trash directory should have failed with NotPermError: %v", err)
+	}
+
+	_, ok, err = vS.trashLookup(dirInodeNumber)
+	if (nil != err) || ok {
+		t.Fatalf("trashLookup() of the expired directory returned %v [err: %v]", ok, err)
+	}
+
+	// Disable the trash (and remove its directory) for subsequent tests
+
+	vS.trashDown()
+	vS.trashRetention = time.Duration(0)
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, TrashDirName)
+	if nil != err {
+		t.Fatalf("Rmdir() of the trash directory failed: %v", err)
+	}
+
+	_, err = FetchTrash("TestVolume")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchTrash() of a volume without trash should have failed with NotFoundError: %v", err)
+	}
+}
@@ -0,0 +1,618 @@
+package fs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A watch on a directory inode queues a WatchEventStruct for each create, unlink, rename, setattr, or
+// write-close (i.e. a Flush() following a Write()) performed via this package on an entry of the directory
+// (or, for setattr, on the directory itself). Events are fetched via FetchWatchEvents(), waiting (much like
+// FetchInodeLeaseRecalls()) up to a timeout for one to arrive.
+//
+// Each watch's queue holds at most WatchQueueDepth events. Should it fill, a single WatchEventOverflow
+// event is appended and subsequent events are dropped until the queue has been fetched.
+//
+// In order to report setattr and write-close events (that only name the modified inode), each watched
+// directory's entries are indexed (by reading the directory) when the first watch on it is added and kept
+// up to date as events are reported. Changes made via the Swift API are not reported.
+//
+// A watch may only be added by a user able to read the directory. Watches added via a MountHandle are removed
+// when it is unmounted. Watches added via AddVolumeWatch() (e.g. by httpserver) have no MountHandle and are
+// instead discarded by watchDaemon() once not polled for VolumeWatchIdleTimeout.
+
+const (
+	watchDefaultQueueDepth        = uint64(1024)
+	watchDefaultVolumeIdleTimeout = 5 * time.Minute
+	watchExpireInterval           = time.Minute
+)
+
+type watchStruct struct {
+	id             WatchID
+	mountID        MountID // If 0, the watch was added via AddVolumeWatch()
+	dirInodeNumber inode.InodeNumber
+	eventList      []WatchEventStruct
+	overflowed     bool          // If true, eventList ends with a WatchEventOverflow event
+	wakeChan       chan struct{} // signalled when eventList becomes non-empty (or the volume is going down)
+	lastFetchTime  time.Time
+}
+
+// watchDirStruct tracks the watches on (and the entries of) a watched directory (synchronized via watchMutex)
+type watchDirStruct struct {
+	watchMap map[WatchID]*watchStruct
+	childMap map[string]inode.InodeNumber // key == basename
+}
+
+// watchEntryStruct locates a (hard) link to an inode within a watched directory
+type watchEntryStruct struct {
+	dirInodeNumber inode.InodeNumber
+	basename       string
+}
+
+func (vS *volumeStruct) watchUp(confMap conf.ConfMap, volumeSectionName string) (err error) {
+	vS.watchMap = make(map[WatchID]*watchStruct)
+	vS.watchDirMap = make(map[inode.InodeNumber]*watchDirStruct)
+	vS.watchParentMap = make(map[inode.InodeNumber]map[watchEntryStruct]struct{})
+	vS.watchWrittenMap = make(map[inode.InodeNumber]struct{})
+	vS.watchHalting = false
+
+	vS.watchQueueDepth, err = confMap.FetchOptionValueUint64(volumeSectionName, "WatchQueueDepth")
+	if (nil != err) || (0 == vS.watchQueueDepth) {
+		vS.watchQueueDepth = watchDefaultQueueDepth
+	}
+
+	vS.watchVolumeIdleTimeout, err = confMap.FetchOptionValueDuration(volumeSectionName, "VolumeWatchIdleTimeout")
+	if nil != err {
+		vS.watchVolumeIdleTimeout = watchDefaultVolumeIdleTimeout
+	}
+
+	vS.watchStopChan = make(chan struct{}, 1)
+
+	vS.watchWG.Add(1)
+	go vS.watchDaemon()
+
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) watchDown() {
+	vS.watchMutex.Lock()
+
+	vS.watchHalting = true
+
+	for _, watch := range vS.watchMap {
+		watch.wake()
+	}
+
+	vS.watchMutex.Unlock()
+
+	if nil == vS.watchStopChan {
+		return // Snapshot volumes do not run watchDaemon()
+	}
+
+	vS.watchStopChan <- struct{}{}
+	vS.watchWG.Wait()
+
+	vS.watchStopChan = nil
+}
+
+// watchDaemon discards idle watches added via AddVolumeWatch() every watchExpireInterval until stopped
+func (vS *volumeStruct) watchDaemon() {
+	for {
+		select {
+		case <-time.After(watchExpireInterval):
+			vS.expireVolumeWatches(time.Now().Add(-vS.watchVolumeIdleTimeout))
+		case <-vS.watchStopChan:
+			vS.watchWG.Done()
+			return
+		}
+	}
+}
+
+func (watch *watchStruct) wake() {
+	select {
+	case watch.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// queueWhileLocked appends event to the watch's queue (or notes that it has overflowed)
+//
+// Note: Caller must hold vS.watchMutex
+func (watch *watchStruct) queueWhileLocked(vS *volumeStruct, event WatchEventStruct) {
+	if watch.overflowed {
+		return
+	}
+
+	if uint64(len(watch.eventList)) < vS.watchQueueDepth {
+		watch.eventList = append(watch.eventList, event)
+		stats.IncrementOperations(&stats.FsWatchEventOps)
+	} else {
+		watch.eventList = append(watch.eventList, WatchEventStruct{
+			Type:           WatchEventOverflow,
+			DirInodeNumber: watch.dirInodeNumber,
+		})
+		watch.overflowed = true
+		stats.IncrementOperations(&stats.FsWatchOverflowOps)
+	}
+
+	watch.wake()
+}
+
+// notifyWhileLocked queues event to each watch on event.DirInodeNumber
+//
+// Note: Caller must hold vS.watchMutex
+func (watchDir *watchDirStruct) notifyWhileLocked(vS *volumeStruct, event WatchEventStruct) {
+	for _, watch := range watchDir.watchMap {
+		watch.queueWhileLocked(vS, event)
+	}
+}
+
+// addEntryWhileLocked indexes basename (replacing any prior entry of that name) in watched directory dirInodeNumber
+//
+// Note: Caller must hold vS.watchMutex
+func (vS *volumeStruct) addEntryWhileLocked(watchDir *watchDirStruct, dirInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) {
+	vS.removeEntryWhileLocked(watchDir, dirInodeNumber, basename)
+
+	watchDir.childMap[basename] = inodeNumber
+
+	entrySet, ok := vS.watchParentMap[inodeNumber]
+	if !ok {
+		entrySet = make(map[watchEntryStruct]struct{})
+		vS.watchParentMap[inodeNumber] = entrySet
+	}
+	entrySet[watchEntryStruct{dirInodeNumber: dirInodeNumber, basename: basename}] = struct{}{}
+}
+
+// removeEntryWhileLocked drops basename from the index of watched directory dirInodeNumber
+//
+// Note: Caller must hold vS.watchMutex
+func (vS *volumeStruct) removeEntryWhileLocked(watchDir *watchDirStruct, dirInodeNumber inode.InodeNumber, basename string) {
+	inodeNumber, ok := watchDir.childMap[basename]
+	if !ok {
+		return
+	}
+
+	delete(watchDir.childMap, basename)
+
+	entrySet := vS.watchParentMap[inodeNumber]
+	delete(entrySet, watchEntryStruct{dirInodeNumber: dirInodeNumber, basename: basename})
+	if 0 == len(entrySet) {
+		delete(vS.watchParentMap, inodeNumber)
+		delete(vS.watchWrittenMap, inodeNumber)
+	}
+}
+
+// addWatch adds a watch on dirInodeNumber (indexing its entries if it was not already being watched)
+//
+// Note: Caller must hold (at least) a read lock on dirInodeNumber
+func (vS *volumeStruct) addWatch(mountID MountID, userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber) (watchID WatchID, err error) {
+	var (
+		dirEntry   inode.DirEntry
+		dirEntries []inode.DirEntry
+		inodeType  inode.InodeType
+	)
+
+	if !vS.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.F_OK, inode.NoOverride) {
+		err = blunder.NewError(blunder.NotFoundError, "ENOENT")
+		return
+	}
+	if !vS.VolumeHandle.Access(dirInodeNumber, userID, groupID, otherGroupIDs, inode.R_OK, inode.OwnerOverride) {
+		err = blunder.NewError(blunder.PermDeniedError, "EACCES")
+		return
+	}
+
+	inodeType, err = vS.VolumeHandle.GetType(dirInodeNumber)
+	if nil != err {
+		return
+	}
+	if inode.DirType != inodeType {
+		err = fmt.Errorf("%s: inode %v is not a directory", utils.GetFnName(), dirInodeNumber)
+		err = blunder.AddError(err, blunder.NotDirError)
+		return
+	}
+
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	if vS.watchHalting {
+		err = fmt.Errorf("%s: volume %s is going down", utils.GetFnName(), vS.volumeName)
+		err = blunder.AddError(err, blunder.TryAgainError)
+		return
+	}
+
+	watchDir, ok := vS.watchDirMap[dirInodeNumber]
+	if !ok {
+		dirEntries, _, err = vS.VolumeHandle.ReadDir(dirInodeNumber, 0, 0)
+		if nil != err {
+			return
+		}
+
+		watchDir = &watchDirStruct{
+			watchMap: make(map[WatchID]*watchStruct),
+			childMap: make(map[string]inode.InodeNumber),
+		}
+
+		for _, dirEntry = range dirEntries {
+			if ("." != dirEntry.Basename) && (".." != dirEntry.Basename) {
+				vS.addEntryWhileLocked(watchDir, dirInodeNumber, dirEntry.Basename, dirEntry.InodeNumber)
+			}
+		}
+
+		vS.watchDirMap[dirInodeNumber] = watchDir
+	}
+
+	vS.lastWatchID++
+	watchID = vS.lastWatchID
+
+	watch := &watchStruct{
+		id:             watchID,
+		mountID:        mountID,
+		dirInodeNumber: dirInodeNumber,
+		eventList:      make([]WatchEventStruct, 0),
+		overflowed:     false,
+		wakeChan:       make(chan struct{}, 1),
+		lastFetchTime:  time.Now(),
+	}
+
+	vS.watchMap[watchID] = watch
+	watchDir.watchMap[watchID] = watch
+
+	err = nil
+	return
+}
+
+// removeWatchWhileLocked drops the watch (and, if it was the directory's last, the directory's index)
+//
+// Note: Caller must hold vS.watchMutex
+func (vS *volumeStruct) removeWatchWhileLocked(watch *watchStruct) {
+	delete(vS.watchMap, watch.id)
+
+	watchDir := vS.watchDirMap[watch.dirInodeNumber]
+	delete(watchDir.watchMap, watch.id)
+
+	if 0 == len(watchDir.watchMap) {
+		for basename := range watchDir.childMap {
+			vS.removeEntryWhileLocked(watchDir, watch.dirInodeNumber, basename)
+		}
+		delete(vS.watchDirMap, watch.dirInodeNumber)
+	}
+
+	// Wake any FetchWatchEvents() in progress so that it notices the watch is gone
+
+	watch.wake()
+}
+
+// fetchWatchEvents returns the events queued for the watch, waiting up to timeout for one to arrive
+func (vS *volumeStruct) fetchWatchEvents(mountID MountID, watchID WatchID, timeout time.Duration) (events []WatchEventStruct, err error) {
+	vS.watchMutex.Lock()
+
+	watch, ok := vS.watchMap[watchID]
+	if !ok || (watch.mountID != mountID) {
+		vS.watchMutex.Unlock()
+		err = fmt.Errorf("%s: watch %v of volume %s not found", utils.GetFnName(), watchID, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	watch.lastFetchTime = time.Now()
+
+	if (0 == len(watch.eventList)) && !vS.watchHalting && (time.Duration(0) < timeout) {
+		// Discard any wake-up left over from events already fetched (without waiting) by a prior call
+
+		select {
+		case <-watch.wakeChan:
+		default:
+		}
+
+		vS.watchMutex.Unlock()
+
+		timer := time.NewTimer(timeout)
+		select {
+		case <-watch.wakeChan:
+		case <-timer.C:
+		}
+		_ = timer.Stop()
+
+		vS.watchMutex.Lock()
+	}
+
+	events = watch.eventList
+	watch.eventList = make([]WatchEventStruct, 0)
+	watch.overflowed = false
+	watch.lastFetchTime = time.Now()
+
+	vS.watchMutex.Unlock()
+
+	err = nil
+	return
+}
+
+// removeWatch drops the watch (if it was added by mountID)
+func (vS *volumeStruct) removeWatch(mountID MountID, watchID WatchID) (err error) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	watch, ok := vS.watchMap[watchID]
+	if !ok || (watch.mountID != mountID) {
+		err = fmt.Errorf("%s: watch %v of volume %s not found", utils.GetFnName(), watchID, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	vS.removeWatchWhileLocked(watch)
+
+	err = nil
+	return
+}
+
+// removeMountWatches drops all watches added by mountID
+func (vS *volumeStruct) removeMountWatches(mountID MountID) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	for _, watch := range vS.watchMap {
+		if watch.mountID == mountID {
+			vS.removeWatchWhileLocked(watch)
+		}
+	}
+}
+
+// expireVolumeWatches drops watches added via AddVolumeWatch() not polled since before expirationTime
+func (vS *volumeStruct) expireVolumeWatches(expirationTime time.Time) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	for _, watch := range vS.watchMap {
+		if (MountID(0) == watch.mountID) && watch.lastFetchTime.Before(expirationTime) {
+			vS.removeWatchWhileLocked(watch)
+		}
+	}
+}
+
+// watchNotifyEntry reports the creation (or removal) of basename in dirInodeNumber
+//
+// Note: Caller must hold a write lock on dirInodeNumber
+func (vS *volumeStruct) watchNotifyEntry(eventType WatchEventType, dirInodeNumber inode.InodeNumber, basename string, inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	watchDir, ok := vS.watchDirMap[dirInodeNumber]
+	if !ok {
+		return
+	}
+
+	if WatchEventUnlink == eventType {
+		vS.removeEntryWhileLocked(watchDir, dirInodeNumber, basename)
+	} else {
+		vS.addEntryWhileLocked(watchDir, dirInodeNumber, basename, inodeNumber)
+	}
+
+	watchDir.notifyWhileLocked(vS, WatchEventStruct{
+		Type:           eventType,
+		DirInodeNumber: dirInodeNumber,
+		Basename:       basename,
+		InodeNumber:    inodeNumber,
+	})
+}
+
+// watchLookup returns the inode to be moved by a Rename() (or 0 if neither directory is watched nor the volume journaled)
+//
+// Note: Caller must hold write locks on srcDirInodeNumber & dstDirInodeNumber
+func (vS *volumeStruct) watchLookup(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber) (inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	_, srcWatched := vS.watchDirMap[srcDirInodeNumber]
+	_, dstWatched := vS.watchDirMap[dstDirInodeNumber]
+	vS.watchMutex.Unlock()
+
+	if !srcWatched && !dstWatched && (time.Duration(0) == vS.journalRetention) {
+		inodeNumber = inode.InodeNumber(0)
+		return
+	}
+
+	inodeNumber, err := vS.VolumeHandle.Lookup(srcDirInodeNumber, srcBasename)
+	if nil != err {
+		inodeNumber = inode.InodeNumber(0)
+	}
+
+	return
+}
+
+// watchNotifyRename reports the move of srcBasename in srcDirInodeNumber to dstBasename in dstDirInodeNumber
+//
+// Note: Caller must hold write locks on srcDirInodeNumber & dstDirInodeNumber
+func (vS *volumeStruct) watchNotifyRename(srcDirInodeNumber inode.InodeNumber, srcBasename string, dstDirInodeNumber inode.InodeNumber, dstBasename string, inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	srcWatchDir, srcWatched := vS.watchDirMap[srcDirInodeNumber]
+	dstWatchDir, dstWatched := vS.watchDirMap[dstDirInodeNumber]
+
+	if !srcWatched && !dstWatched {
+		return
+	}
+
+	vS.watchCookie++
+
+	if srcWatched {
+		vS.removeEntryWhileLocked(srcWatchDir, srcDirInodeNumber, srcBasename)
+		srcWatchDir.notifyWhileLocked(vS, WatchEventStruct{
+			Type:           WatchEventRenameFrom,
+			DirInodeNumber: srcDirInodeNumber,
+			Basename:       srcBasename,
+			InodeNumber:    inodeNumber,
+			Cookie:         vS.watchCookie,
+		})
+	}
+
+	if dstWatched {
+		vS.addEntryWhileLocked(dstWatchDir, dstDirInodeNumber, dstBasename, inodeNumber)
+		dstWatchDir.notifyWhileLocked(vS, WatchEventStruct{
+			Type:           WatchEventRenameTo,
+			DirInodeNumber: dstDirInodeNumber,
+			Basename:       dstBasename,
+			InodeNumber:    inodeNumber,
+			Cookie:         vS.watchCookie,
+		})
+	}
+}
+
+// watchNotifyInode reports a setattr (or write-close) of inodeNumber to the watches on each directory
+// containing it (and, for setattr, to those on inodeNumber itself)
+//
+// Note: Caller must hold a write lock on inodeNumber
+func (vS *volumeStruct) watchNotifyInode(eventType WatchEventType, inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	defer vS.watchMutex.Unlock()
+
+	for entry := range vS.watchParentMap[inodeNumber] {
+		vS.watchDirMap[entry.dirInodeNumber].notifyWhileLocked(vS, WatchEventStruct{
+			Type:           eventType,
+			DirInodeNumber: entry.dirInodeNumber,
+			Basename:       entry.basename,
+			InodeNumber:    inodeNumber,
+		})
+	}
+
+	if WatchEventSetattr == eventType {
+		watchDir, ok := vS.watchDirMap[inodeNumber]
+		if ok {
+			watchDir.notifyWhileLocked(vS, WatchEventStruct{
+				Type:           eventType,
+				DirInodeNumber: inodeNumber,
+				Basename:       "",
+				InodeNumber:    inodeNumber,
+			})
+		}
+	}
+}
+
+// watchNoteWrite records that inodeNumber (if in a watched directory) has been written since its last Flush()
+//
+// Note: Caller must hold a write lock on inodeNumber
+func (vS *volumeStruct) watchNoteWrite(inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	_, ok := vS.watchParentMap[inodeNumber]
+	if ok {
+		vS.watchWrittenMap[inodeNumber] = struct{}{}
+	}
+	vS.watchMutex.Unlock()
+}
+
+// watchNotifyWriteClose reports a Flush() of inodeNumber if it was written since its last Flush()
+//
+// Note: Caller must hold a write lock on inodeNumber
+func (vS *volumeStruct) watchNotifyWriteClose(inodeNumber inode.InodeNumber) {
+	vS.watchMutex.Lock()
+	_, ok := vS.watchWrittenMap[inodeNumber]
+	if ok {
+		delete(vS.watchWrittenMap, inodeNumber)
+	}
+	vS.watchMutex.Unlock()
+
+	if ok {
+		vS.watchNotifyInode(WatchEventWriteClose, inodeNumber)
+	}
+}
+
+func (mS *mountStruct) AddWatch(userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber) (watchID WatchID, err error) {
+	stats.IncrementOperations(&stats.FsWatchAddOps)
+
+	mS.volStruct.validateVolumeRWMutex.RLock()
+	defer mS.volStruct.validateVolumeRWMutex.RUnlock()
+
+	dirInodeLock, err := mS.volStruct.initInodeLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = dirInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	watchID, err = mS.volStruct.addWatch(mS.id, userID, groupID, otherGroupIDs, dirInodeNumber)
+
+	return
+}
+
+func (mS *mountStruct) RemoveWatch(watchID WatchID) (err error) {
+	stats.IncrementOperations(&stats.FsWatchRemoveOps)
+
+	err = mS.volStruct.removeWatch(mS.id, watchID)
+
+	return
+}
+
+// FetchWatchEvents returns the events queued for the watch, waiting up to timeout for one to arrive
+func (mS *mountStruct) FetchWatchEvents(watchID WatchID, timeout time.Duration) (events []WatchEventStruct, err error) {
+	events, err = mS.volStruct.fetchWatchEvents(mS.id, watchID, timeout)
+	return
+}
+
+func fetchWatchVolume(volumeName string) (vS *volumeStruct, err error) {
+	globals.Lock()
+	vS, ok := globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volume \"%s\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	err = nil
+	return
+}
+
+func addVolumeWatch(volumeName string, userID inode.InodeUserID, groupID inode.InodeGroupID, otherGroupIDs []inode.InodeGroupID, dirInodeNumber inode.InodeNumber) (watchID WatchID, err error) {
+	vS, err := fetchWatchVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	vS.validateVolumeRWMutex.RLock()
+	defer vS.validateVolumeRWMutex.RUnlock()
+
+	dirInodeLock, err := vS.initInodeLock(dirInodeNumber, nil)
+	if nil != err {
+		return
+	}
+	err = dirInodeLock.ReadLock()
+	if nil != err {
+		return
+	}
+	defer dirInodeLock.Unlock()
+
+	watchID, err = vS.addWatch(MountID(0), userID, groupID, otherGroupIDs, dirInodeNumber)
+
+	return
+}
+
+func removeVolumeWatch(volumeName string, watchID WatchID) (err error) {
+	vS, err := fetchWatchVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	err = vS.removeWatch(MountID(0), watchID)
+
+	return
+}
+
+func fetchVolumeWatchEvents(volumeName string, watchID WatchID, timeout time.Duration) (events []WatchEventStruct, err error) {
+	vS, err := fetchWatchVolume(volumeName)
+	if nil != err {
+		return
+	}
+
+	events, err = vS.fetchWatchEvents(MountID(0), watchID, timeout)
+
+	return
+}
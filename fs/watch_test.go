@@ -0,0 +1,248 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/inode"
+)
+
+func TestWatch(t *testing.T) {
+	vS := mS.volStruct
+
+	savedWatchQueueDepth := vS.watchQueueDepth
+	defer func() { vS.watchQueueDepth = savedWatchQueueDepth }()
+
+	dirInodeNumber := createTestDirectory(t, "TestWatch")
+	otherDirInodeNumber := createTestDirectory(t, "TestWatchOther")
+
+	fileInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "PreExisting", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [PreExisting] failed: %v", err)
+	}
+
+	_, err = mS.AddWatch(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if !blunder.Is(err, blunder.NotDirError) {
+		t.Fatalf("AddWatch() of a file should have failed with NotDirError: %v", err)
+	}
+
+	watchID, err := mS.AddWatch(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("AddWatch() failed: %v", err)
+	}
+
+	events, err := mS.FetchWatchEvents(watchID, time.Duration(0))
+	if (nil != err) || (0 != len(events)) {
+		t.Fatalf("FetchWatchEvents() of idle watch returned unexpected %+v [err: %v]", events, err)
+	}
+
+	// A waiting FetchWatchEvents() should be woken by an event
+
+	fetchDoneChan := make(chan []WatchEventStruct, 1)
+	go func() {
+		waitedForEvents, _ := mS.FetchWatchEvents(watchID, time.Minute)
+		fetchDoneChan <- waitedForEvents
+	}()
+
+	newInodeNumber, err := mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "New", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [New] failed: %v", err)
+	}
+
+	select {
+	case events = <-fetchDoneChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("FetchWatchEvents() was not woken by Create()")
+	}
+	if (1 != len(events)) || (WatchEventCreate != events[0].Type) || ("New" != events[0].Basename) || (newInodeNumber != events[0].InodeNumber) {
+		t.Fatalf("FetchWatchEvents() after Create() returned unexpected %+v", events)
+	}
+
+	// Setattr & write-close of the pre-existing entry rely on the index built by AddWatch()
+
+	err = mS.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, Stat{StatMode: uint64(0600)})
+	if nil != err {
+		t.Fatalf("Setstat() failed: %v", err)
+	}
+	_, err = mS.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, 0, []byte{0x00, 0x01}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	err = mS.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Flush() [unwritten] failed: %v", err)
+	}
+
+	events, err = mS.FetchWatchEvents(watchID, time.Duration(0))
+	if nil != err {
+		t.Fatalf("FetchWatchEvents() failed: %v", err)
+	}
+	if (2 != len(events)) ||
+		(WatchEventSetattr != events[0].Type) || ("PreExisting" != events[0].Basename) ||
+		(WatchEventWriteClose != events[1].Type) || ("PreExisting" != events[1].Basename) {
+		t.Fatalf("FetchWatchEvents() after Setstat(), Write(), & Flush() returned unexpected %+v", events)
+	}
+
+	// The wake-up left over from those (already fetched) events should not cut short a subsequent wait
+
+	fetchStartTime := time.Now()
+	events, err = mS.FetchWatchEvents(watchID, 100*time.Millisecond)
+	if (nil != err) || (0 != len(events)) {
+		t.Fatalf("FetchWatchEvents() following a fetch of all queued events returned unexpected %+v [err: %v]", events, err)
+	}
+	if time.Since(fetchStartTime) < (100 * time.Millisecond) {
+		t.Fatalf("FetchWatchEvents() following a fetch of all queued events returned before its timeout")
+	}
+
+	// A rename out of the watched directory reports only RenameFrom... but one into it pairs with a watch there
+
+	otherWatchID, err := mS.AddWatch(inode.InodeRootUserID, inode.InodeGroupID(0), nil, otherDirInodeNumber)
+	if nil != err {
+		t.Fatalf("AddWatch() [other] failed: %v", err)
+	}
+
+	err = mS.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "New", otherDirInodeNumber, "Moved")
+	if nil != err {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+
+	events, err = mS.FetchWatchEvents(watchID, time.Duration(0))
+	if (nil != err) || (1 != len(events)) || (WatchEventRenameFrom != events[0].Type) || ("New" != events[0].Basename) || (newInodeNumber != events[0].InodeNumber) {
+		t.Fatalf("FetchWatchEvents() after Rename() returned unexpected %+v [err: %v]", events, err)
+	}
+	otherEvents, err := mS.FetchWatchEvents(otherWatchID, time.Duration(0))
+	if (nil != err) || (1 != len(otherEvents)) || (WatchEventRenameTo != otherEvents[0].Type) || ("Moved" != otherEvents[0].Basename) || (events[0].Cookie != otherEvents[0].Cookie) {
+		t.Fatalf("FetchWatchEvents() [other] after Rename() returned unexpected %+v [err: %v]", otherEvents, err)
+	}
+
+	err = mS.RemoveWatch(otherWatchID)
+	if nil != err {
+		t.Fatalf("RemoveWatch() [other] failed: %v", err)
+	}
+	err = mS.RemoveWatch(otherWatchID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("RemoveWatch() [other] of removed watch should have failed with NotFoundError: %v", err)
+	}
+
+	// A full queue should end with a single overflow event
+
+	vS.watchQueueDepth = 2
+
+	for _, basename := range []string{"A", "B", "C", "D"} {
+		_, err = mS.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename, inode.PosixModePerm)
+		if nil != err {
+			t.Fatalf("Create() [%s] failed: %v", basename, err)
+		}
+	}
+
+	events, err = mS.FetchWatchEvents(watchID, time.Duration(0))
+	if (nil != err) || (3 != len(events)) || ("A" != events[0].Basename) || ("B" != events[1].Basename) || (WatchEventOverflow != events[2].Type) {
+		t.Fatalf("FetchWatchEvents() of overflowed watch returned unexpected %+v [err: %v]", events, err)
+	}
+
+	for _, basename := range []string{"A", "B", "C", "D", "PreExisting"} {
+		err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename)
+		if nil != err {
+			t.Fatalf("Unlink() [%s] failed: %v", basename, err)
+		}
+	}
+
+	events, err = mS.FetchWatchEvents(watchID, time.Duration(0))
+	if (nil != err) || (3 != len(events)) || (WatchEventUnlink != events[0].Type) || (WatchEventOverflow != events[2].Type) {
+		t.Fatalf("FetchWatchEvents() after Unlink()s returned unexpected %+v [err: %v]", events, err)
+	}
+
+	// Watches are private to the MountHandle that added them... and removed when it is unmounted
+
+	mountHandle, err := Mount("TestVolume", MountOptions(0))
+	if nil != err {
+		t.Fatalf("Mount(\"TestVolume\", MountOptions(0)) failed: %v", err)
+	}
+
+	_, err = mountHandle.FetchWatchEvents(watchID, time.Duration(0))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchWatchEvents() via other MountHandle should have failed with NotFoundError: %v", err)
+	}
+
+	otherWatchID, err = mountHandle.AddWatch(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("AddWatch() via other MountHandle failed: %v", err)
+	}
+
+	err = mountHandle.Unmount()
+	if nil != err {
+		t.Fatalf("Unmount() failed: %v", err)
+	}
+
+	vS.watchMutex.Lock()
+	_, ok := vS.watchMap[otherWatchID]
+	vS.watchMutex.Unlock()
+	if ok {
+		t.Fatalf("Unmount() should have removed the MountHandle's watch")
+	}
+
+	// Volume watches (e.g. for httpserver) are discarded once idle
+
+	privateDirInodeNumber, err := mS.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestWatchPrivate", inode.InodeMode(0700))
+	if nil != err {
+		t.Fatalf("Mkdir() [TestWatchPrivate] failed: %v", err)
+	}
+
+	_, err = AddVolumeWatch("TestVolume", inode.InodeUserID(1000), inode.InodeGroupID(1000), nil, privateDirInodeNumber)
+	if !blunder.Is(err, blunder.PermDeniedError) {
+		t.Fatalf("AddVolumeWatch() by a user unable to read the directory should have failed with PermDeniedError: %v", err)
+	}
+
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestWatchPrivate")
+	if nil != err {
+		t.Fatalf("Rmdir() [TestWatchPrivate] failed: %v", err)
+	}
+
+	volumeWatchID, err := AddVolumeWatch("TestVolume", inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("AddVolumeWatch() failed: %v", err)
+	}
+
+	_, err = FetchVolumeWatchEvents("TestVolume", watchID, time.Duration(0))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchVolumeWatchEvents() of a MountHandle's watch should have failed with NotFoundError: %v", err)
+	}
+
+	vS.expireVolumeWatches(time.Now().Add(time.Second))
+
+	_, err = FetchVolumeWatchEvents("TestVolume", volumeWatchID, time.Duration(0))
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchVolumeWatchEvents() of an expired watch should have failed with NotFoundError: %v", err)
+	}
+
+	err = mS.RemoveWatch(watchID)
+	if nil != err {
+		t.Fatalf("RemoveWatch() failed: %v", err)
+	}
+
+	vS.watchMutex.Lock()
+	numWatchedDirs := len(vS.watchDirMap)
+	numWatchedEntries := len(vS.watchParentMap)
+	vS.watchMutex.Unlock()
+	if (0 != numWatchedDirs) || (0 != numWatchedEntries) {
+		t.Fatalf("RemoveWatch() of the last watch left %v watched directories & %v watched entries", numWatchedDirs, numWatchedEntries)
+	}
+
+	err = mS.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, otherDirInodeNumber, "Moved")
+	if nil != err {
+		t.Fatalf("Unlink() [Moved] failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestWatchOther")
+	if nil != err {
+		t.Fatalf("Rmdir() [TestWatchOther] failed: %v", err)
+	}
+	err = mS.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TestWatch")
+	if nil != err {
+		t.Fatalf("Rmdir() [TestWatch] failed: %v", err)
+	}
+}
@@ -1,12 +1,17 @@
 package fuse
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"syscall"
 	"time"
 
 	fuselib "bazil.org/fuse"
+	fusefslib "bazil.org/fuse/fs"
 	"golang.org/x/net/context"
 
 	"github.com/swiftstack/ProxyFS/blunder"
@@ -158,3 +163,55 @@ func (f File) Write(ctx context.Context, req *fuselib.WriteRequest, resp *fuseli
 	}
 	return err
 }
+
+// CloneRangeIoctlCmd is the ioctl() request (_IOW('P', 1, 4096)) issued on an open destination
+// file to clone a range of another file on the same volume into it. Its argument is three
+// little-endian uint64's (the source offset, the length, and the destination offset) followed
+// by the NUL-terminated path of the source file relative to the root of the mount (occupying
+// the remainder of the 4096 bytes). As with open(), every directory along that path must be
+// searchable (and the source file readable) by the caller. The ioctl() returns the number of
+// bytes cloned.
+const (
+	CloneRangeIoctlCmd     = uint32(0x50005001)
+	cloneRangeIoctlArgSize = 4096
+)
+
+// Fails to compile should vendor/bazil.org/fuse lack vendor-patches/bazil.org-fuse-ioctl.patch (see GLIDE.md)
+var _ fusefslib.HandleIoctler = File{}
+
+func (f File) Ioctl(ctx context.Context, req *fuselib.IoctlRequest, resp *fuselib.IoctlResponse) (err error) {
+	if (CloneRangeIoctlCmd != req.Cmd) || (cloneRangeIoctlArgSize != len(req.InData)) {
+		err = fuselib.Errno(syscall.ENOTTY)
+		return
+	}
+
+	srcOffset := binary.LittleEndian.Uint64(req.InData[0:8])
+	length := binary.LittleEndian.Uint64(req.InData[8:16])
+	dstOffset := binary.LittleEndian.Uint64(req.InData[16:24])
+
+	srcPathLen := bytes.IndexByte(req.InData[24:], 0)
+	if 0 >= srcPathLen {
+		err = fuselib.Errno(syscall.EINVAL)
+		return
+	}
+	srcPath := string(req.InData[24:(24 + srcPathLen)])
+
+	srcInodeNumber, err := f.mountHandle.LookupPath(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, srcPath)
+	if nil != err {
+		err = newFuseError(err)
+		return
+	}
+
+	clonedLength, err := f.mountHandle.CloneRange(inode.InodeUserID(req.Header.Uid), inode.InodeGroupID(req.Header.Gid), nil, srcInodeNumber, srcOffset, f.inodeNumber, dstOffset, length)
+	if nil != err {
+		err = newFuseError(err)
+		return
+	}
+
+	if clonedLength > math.MaxInt32 {
+		resp.Result = math.MaxInt32
+	} else {
+		resp.Result = int32(clonedLength)
+	}
+	return
+}
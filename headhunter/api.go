@@ -4,6 +4,7 @@ package headhunter
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/swiftstack/sortedmap"
 )
@@ -14,8 +15,36 @@ const (
 	InodeRecBPlusTree BPlusTreeType = iota
 	LogSegmentRecBPlusTree
 	BPlusTreeObjectBPlusTree
+	JournalRecBPlusTree
+	FingerprintRecBPlusTree
 )
 
+// SnapshotStruct describes a named, read-only, point-in-time image of a volume's database
+type SnapshotStruct struct {
+	ID                     uint64 // nonce assigned at creation
+	Name                   string
+	CreationTime           time.Time
+	CheckpointObjectNumber uint64 // objectNumber-named Object holding the checkpointObjectTrailerV{2|3}Struct retained by the snapshot
+}
+
+// CheckpointHealthState indicates whether or not a volume's checkpoints are succeeding
+type CheckpointHealthState uint32
+
+const (
+	CheckpointHealthy  CheckpointHealthState = iota // most recent checkpoint (if any) succeeded
+	CheckpointDegraded                              // most recent checkpoint failed... writes are quiesced while it is retried
+)
+
+// CheckpointHealthStruct describes the outcome of recent checkpoints of a volume
+type CheckpointHealthStruct struct {
+	State               CheckpointHealthState
+	ConsecutiveFailures uint64    // checkpoints that have failed since the last one to succeed
+	LastSuccessTime     time.Time // zero if no checkpoint has succeeded since the volume was brought up
+	LastFailureTime     time.Time // zero if no checkpoint has failed since the volume was brought up
+	LastFailureErr      error     // nil if no checkpoint has failed since the volume was brought up
+	NextRetryTime       time.Time // if State == CheckpointDegraded, when the failed checkpoint will next be retried
+}
+
 // VolumeHandle is used to operate on a given volume's database
 type VolumeHandle interface {
 	FetchNextCheckPointDoneWaitGroup() (wg *sync.WaitGroup)
@@ -24,14 +53,29 @@ type VolumeHandle interface {
 	PutInodeRec(inodeNumber uint64, value []byte) (err error)
 	PutInodeRecs(inodeNumbers []uint64, values [][]byte) (err error)
 	DeleteInodeRec(inodeNumber uint64) (err error)
+	FetchInodeNumbers(afterInodeNumber uint64, maxInodeNumbers uint64) (inodeNumbers []uint64, err error)
 	GetLogSegmentRec(logSegmentNumber uint64) (value []byte, err error)
 	PutLogSegmentRec(logSegmentNumber uint64, value []byte) (err error)
 	DeleteLogSegmentRec(logSegmentNumber uint64) (err error)
 	GetBPlusTreeObject(objectNumber uint64) (value []byte, err error)
 	PutBPlusTreeObject(objectNumber uint64, value []byte) (err error)
 	DeleteBPlusTreeObject(objectNumber uint64) (err error)
+	PutJournalRec(journalNonce uint64, value []byte) (err error)
+	FetchJournalRecs(afterNonce uint64, maxRecs uint64) (journalNonces []uint64, values [][]byte, err error)
+	TrimJournalRecs(trimToNonce uint64) (err error)
+	GetFingerprintRec(fingerprint uint64) (value []byte, ok bool, err error)
+	PutFingerprintRec(fingerprint uint64, value []byte) (err error)
+	DeleteFingerprintRec(fingerprint uint64) (err error)
 	DoCheckpoint() (err error)
 	FetchLayoutReport(treeType BPlusTreeType) (layoutReport sortedmap.LayoutReport, err error)
+	CreateSnapshot(name string) (snapshot SnapshotStruct, err error)
+	DeleteSnapshot(name string) (err error)
+	FetchSnapshotList() (snapshotList []SnapshotStruct)
+	FetchSnapshotExclusiveBytes(name string) (exclusiveBytes uint64, err error)
+	FetchSnapshotVolumeHandle(name string) (snapshotVolumeHandle VolumeHandle, err error)
+	SnapshotPinsLogSegment(logSegmentNumber uint64) (pinned bool)
+	FetchLogSegmentAccountName(logSegmentNumber uint64) (accountName string)
+	FetchCheckpointHealth() (checkpointHealth CheckpointHealthStruct)
 }
 
 // FetchVolumeHandle is used to fetch a VolumeHandle to use when operating on a given volume's database
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/swiftstack/sortedmap"
+
 	"github.com/swiftstack/ProxyFS/evtlog"
 	"github.com/swiftstack/ProxyFS/swiftclient"
 )
@@ -38,10 +40,10 @@ func (volume *volumeStruct) fetchNonceWhileLocked() (nonce uint64, err error) {
 		// TODO: Move this inside recordTransaction() once it is a, uh, transaction :-)
 		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionNonceRangeReserve, volume.volumeName, volume.nextNonce, newReservedToNonce-1)
 
-		// checkpointHeaderVersion2 == volume.checkpointHeaderVersion
+		// volume.checkpointHeaderVersion matches the checkpointObjectTrailerV{2|3}Struct referenced
 
 		checkpointHeaderValue = fmt.Sprintf("%016X %016X %016X %016X",
-			checkpointHeaderVersion2,
+			volume.checkpointHeaderVersion,
 			volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
 			volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength,
 			newReservedToNonce,
@@ -70,6 +72,7 @@ func (volume *volumeStruct) fetchNonceWhileLocked() (nonce uint64, err error) {
 
 func (volume *volumeStruct) FetchNonce() (nonce uint64, err error) {
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 	nonce, err = volume.fetchNonceWhileLocked()
 	volume.Unlock()
 	return
@@ -104,6 +107,7 @@ func (volume *volumeStruct) PutInodeRec(inodeNumber uint64, value []byte) (err e
 	copy(valueToTree, value)
 
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	ok, err := volume.inodeRecWrapper.bPlusTree.PatchByKey(inodeNumber, valueToTree)
 	if nil != err {
@@ -140,6 +144,7 @@ func (volume *volumeStruct) PutInodeRecs(inodeNumbers []uint64, values [][]byte)
 	}
 
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	for i, inodeNumber := range inodeNumbers {
 		ok, nonShadowingErr := volume.inodeRecWrapper.bPlusTree.PatchByKey(inodeNumber, valuesToTree[i])
@@ -167,6 +172,7 @@ func (volume *volumeStruct) PutInodeRecs(inodeNumbers []uint64, values [][]byte)
 
 func (volume *volumeStruct) DeleteInodeRec(inodeNumber uint64) (err error) {
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	_, err = volume.inodeRecWrapper.bPlusTree.DeleteByKey(inodeNumber)
 
@@ -177,6 +183,60 @@ func (volume *volumeStruct) DeleteInodeRec(inodeNumber uint64) (err error) {
 	return
 }
 
+// FetchInodeNumbers returns up to maxInodeNumbers of the InodeNumbers following afterInodeNumber (in ascending order)
+func (volume *volumeStruct) FetchInodeNumbers(afterInodeNumber uint64, maxInodeNumbers uint64) (inodeNumbers []uint64, err error) {
+	volume.Lock()
+	inodeNumbers, err = fetchInodeNumbersWhileLocked(volume.inodeRecWrapper.bPlusTree, afterInodeNumber, maxInodeNumbers)
+	volume.Unlock()
+	return
+}
+
+// fetchInodeNumbersWhileLocked pages through the inodeRec B+Tree of either a volume or a snapshot
+//
+// Note: Caller must hold volume.Lock()
+func fetchInodeNumbersWhileLocked(bPlusTree sortedmap.BPlusTree, afterInodeNumber uint64, maxInodeNumbers uint64) (inodeNumbers []uint64, err error) {
+	var (
+		found            bool
+		index            int
+		inodeNumberAsKey sortedmap.Key
+		numInodeRecs     int
+		ok               bool
+	)
+
+	numInodeRecs, err = bPlusTree.Len()
+	if nil != err {
+		return
+	}
+
+	index, found, err = bPlusTree.BisectRight(afterInodeNumber)
+	if nil != err {
+		return
+	}
+	if found {
+		index++
+	}
+
+	inodeNumbers = make([]uint64, 0)
+
+	for (index < numInodeRecs) && (uint64(len(inodeNumbers)) < maxInodeNumbers) {
+		inodeNumberAsKey, _, ok, err = bPlusTree.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("inodeRec B+Tree index %v not found", index)
+			return
+		}
+
+		inodeNumbers = append(inodeNumbers, inodeNumberAsKey.(uint64))
+
+		index++
+	}
+
+	err = nil
+	return
+}
+
 func (volume *volumeStruct) GetLogSegmentRec(logSegmentNumber uint64) (value []byte, err error) {
 	volume.Lock()
 
@@ -206,6 +266,7 @@ func (volume *volumeStruct) PutLogSegmentRec(logSegmentNumber uint64, value []by
 	copy(valueToTree, value)
 
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	ok, err := volume.logSegmentRecWrapper.bPlusTree.PatchByKey(logSegmentNumber, valueToTree)
 	if nil != err {
@@ -230,6 +291,7 @@ func (volume *volumeStruct) PutLogSegmentRec(logSegmentNumber uint64, value []by
 
 func (volume *volumeStruct) DeleteLogSegmentRec(logSegmentNumber uint64) (err error) {
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	_, err = volume.logSegmentRecWrapper.bPlusTree.DeleteByKey(logSegmentNumber)
 
@@ -269,6 +331,7 @@ func (volume *volumeStruct) PutBPlusTreeObject(objectNumber uint64, value []byte
 	copy(valueToTree, value)
 
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	ok, err := volume.bPlusTreeObjectWrapper.bPlusTree.PatchByKey(objectNumber, valueToTree)
 	if nil != err {
@@ -293,6 +356,7 @@ func (volume *volumeStruct) PutBPlusTreeObject(objectNumber uint64, value []byte
 
 func (volume *volumeStruct) DeleteBPlusTreeObject(objectNumber uint64) (err error) {
 	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
 
 	_, err = volume.bPlusTreeObjectWrapper.bPlusTree.DeleteByKey(objectNumber)
 
@@ -2,6 +2,7 @@ package headhunter
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/conf"
 	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/evtlog"
@@ -16,6 +18,7 @@ import (
 	"github.com/swiftstack/ProxyFS/ramswift"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
 )
 
 func inodeRecPutGet(t *testing.T, volume VolumeHandle, key uint64, value []byte) {
@@ -71,6 +74,21 @@ func putInodeRecsTest(t *testing.T, volume VolumeHandle) {
 		t.Fatalf("Failed to PutInodeRecs: %v", err)
 	}
 
+	inodeNumbers, err := volume.FetchInodeNumbers(0, 4)
+	if nil != err {
+		t.Fatalf("FetchInodeNumbers(0, 4) failed: %v", err)
+	}
+	if (4 != len(inodeNumbers)) || (1 != inodeNumbers[0]) || (4 != inodeNumbers[3]) {
+		t.Fatalf("FetchInodeNumbers(0, 4) returned unexpected %v", inodeNumbers)
+	}
+	inodeNumbers, err = volume.FetchInodeNumbers(inodeNumbers[3], 4)
+	if nil != err {
+		t.Fatalf("FetchInodeNumbers(4, 4) failed: %v", err)
+	}
+	if (4 != len(inodeNumbers)) || (5 != inodeNumbers[0]) || (8 != inodeNumbers[3]) {
+		t.Fatalf("FetchInodeNumbers(4, 4) returned unexpected %v", inodeNumbers)
+	}
+
 	for i := 0; i < 10; i++ {
 		var value []byte
 		value, ok, err := volume.GetInodeRec(keys[i])
@@ -88,6 +106,627 @@ func putInodeRecsTest(t *testing.T, volume VolumeHandle) {
 	}
 }
 
+func snapshotTest(t *testing.T, confMap conf.ConfMap, volume VolumeHandle) {
+	var (
+		key           uint64 = 5678
+		originalValue        = []byte{1, 2, 3}
+		modifiedValue        = []byte{4, 5, 6}
+	)
+
+	inodeRecPutGet(t, volume, key, originalValue)
+
+	snapshot, err := volume.CreateSnapshot("TestSnapshot")
+	if nil != err {
+		t.Fatalf("CreateSnapshot(\"TestSnapshot\") failed: %v", err)
+	}
+	if "TestSnapshot" != snapshot.Name {
+		t.Fatalf("CreateSnapshot(\"TestSnapshot\") returned unexpected Name: %v", snapshot.Name)
+	}
+
+	_, err = volume.CreateSnapshot("TestSnapshot")
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("CreateSnapshot(\"TestSnapshot\") [duplicate] should have failed with FileExistsError: %v", err)
+	}
+
+	snapshotList := volume.FetchSnapshotList()
+	if (1 != len(snapshotList)) || (snapshot.ID != snapshotList[0].ID) {
+		t.Fatalf("FetchSnapshotList() returned unexpected list: %v", snapshotList)
+	}
+
+	inodeRecPutGet(t, volume, key, modifiedValue)
+
+	err = volume.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() failed: %v", err)
+	}
+
+	snapshotVolume, err := volume.FetchSnapshotVolumeHandle("TestSnapshot")
+	if nil != err {
+		t.Fatalf("FetchSnapshotVolumeHandle(\"TestSnapshot\") failed: %v", err)
+	}
+
+	value, ok, err := snapshotVolume.GetInodeRec(key)
+	if (nil != err) || !ok {
+		t.Fatalf("snapshotVolume.GetInodeRec(%d) failed: %v", key, err)
+	}
+	if 0 != bytes.Compare(value, originalValue) {
+		t.Fatalf("snapshotVolume.GetInodeRec(%d) returned %v (expected %v)", key, value, originalValue)
+	}
+
+	err = snapshotVolume.PutInodeRec(key, modifiedValue)
+	if !blunder.Is(err, blunder.ReadOnlyError) {
+		t.Fatalf("snapshotVolume.PutInodeRec(%d) should have failed with ReadOnlyError: %v", key, err)
+	}
+
+	_, err = volume.FetchSnapshotExclusiveBytes("TestSnapshot")
+	if nil != err {
+		t.Fatalf("FetchSnapshotExclusiveBytes(\"TestSnapshot\") failed: %v", err)
+	}
+
+	err = swiftclient.AccountPut("TestCloneAccount", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("AccountPut(\"TestCloneAccount\") failed: %v", err)
+	}
+
+	err = FormatClone(confMap, "TestClone", "TestVolume", "TestSnapshot")
+	if nil != err {
+		t.Fatalf("FormatClone() failed: %v", err)
+	}
+
+	cloneCheckpointContainerHeaders, err := swiftclient.ContainerHead("TestCloneAccount", ".__checkpoint__")
+	if nil != err {
+		t.Fatalf("swiftclient.ContainerHead() of clone Checkpoint Container failed: %v", err)
+	}
+	if _, ok = cloneCheckpointContainerHeaders[CloneSourceHeaderName]; !ok {
+		t.Fatalf("clone Checkpoint Container missing %v header", CloneSourceHeaderName)
+	}
+
+	err = FormatClone(confMap, "TestClone", "TestVolume", "TestSnapshot")
+	if !blunder.Is(err, blunder.FileExistsError) {
+		t.Fatalf("FormatClone() [again] should have failed with FileExistsError: %v", err)
+	}
+
+	err = volume.DeleteSnapshot("TestSnapshot")
+	if !blunder.Is(err, blunder.DevBusyError) {
+		t.Fatalf("DeleteSnapshot(\"TestSnapshot\") referenced by clone should have failed with DevBusyError: %v", err)
+	}
+
+	err = ReleaseCloneReferences(confMap, "TestVolume")
+	if !blunder.Is(err, blunder.DevBusyError) {
+		t.Fatalf("ReleaseCloneReferences(,\"TestVolume\") should have failed with DevBusyError: %v", err)
+	}
+
+	err = ReleaseCloneReferences(confMap, "TestClone")
+	if nil != err {
+		t.Fatalf("ReleaseCloneReferences(,\"TestClone\") failed: %v", err)
+	}
+
+	err = volume.DeleteSnapshot("TestSnapshot")
+	if nil != err {
+		t.Fatalf("DeleteSnapshot(\"TestSnapshot\") failed: %v", err)
+	}
+
+	err = volume.DeleteSnapshot("TestSnapshot")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("DeleteSnapshot(\"TestSnapshot\") [again] should have failed with NotFoundError: %v", err)
+	}
+
+	_, _, err = snapshotVolume.GetInodeRec(key)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("snapshotVolume.GetInodeRec(%d) after DeleteSnapshot() should have failed with NotFoundError: %v", key, err)
+	}
+
+	_, err = volume.FetchSnapshotExclusiveBytes("TestSnapshot")
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("FetchSnapshotExclusiveBytes(\"TestSnapshot\") after DeleteSnapshot() should have failed with NotFoundError: %v", err)
+	}
+
+	if 0 != len(volume.FetchSnapshotList()) {
+		t.Fatalf("FetchSnapshotList() after DeleteSnapshot() should have been empty")
+	}
+
+	err = volume.DeleteInodeRec(key)
+	if nil != err {
+		t.Fatalf("Delete of key %d failed: %v", key, err)
+	}
+}
+
+func snapshotExclusiveBytesTest(t *testing.T, volume VolumeHandle) {
+	err := swiftclient.ContainerPut("TestAccount", "TestLogSegmentContainer", make(map[string][]string))
+	if nil != err {
+		t.Fatalf("ContainerPut(\"TestLogSegmentContainer\") failed: %v", err)
+	}
+
+	logSegmentNumber, err := volume.FetchNonce()
+	if nil != err {
+		t.Fatalf("FetchNonce() for LogSegment failed: %v", err)
+	}
+
+	chunkedPutContext, err := swiftclient.ObjectFetchChunkedPutContext("TestAccount", "TestLogSegmentContainer", utils.Uint64ToHexStr(logSegmentNumber))
+	if nil != err {
+		t.Fatalf("ObjectFetchChunkedPutContext() for LogSegment failed: %v", err)
+	}
+	err = chunkedPutContext.SendChunk([]byte("0123456789"))
+	if nil != err {
+		t.Fatalf("SendChunk() for LogSegment failed: %v", err)
+	}
+	err = chunkedPutContext.Close()
+	if nil != err {
+		t.Fatalf("Close() for LogSegment failed: %v", err)
+	}
+
+	err = volume.PutLogSegmentRec(logSegmentNumber, []byte("TestLogSegmentContainer"))
+	if nil != err {
+		t.Fatalf("PutLogSegmentRec() failed: %v", err)
+	}
+
+	_, err = volume.CreateSnapshot("TestExclusiveBytesSnapshot")
+	if nil != err {
+		t.Fatalf("CreateSnapshot(\"TestExclusiveBytesSnapshot\") failed: %v", err)
+	}
+
+	// While the live volume still references everything in the snapshot, it retains nothing exclusively
+
+	exclusiveBytes, err := volume.FetchSnapshotExclusiveBytes("TestExclusiveBytesSnapshot")
+	if (nil != err) || (0 != exclusiveBytes) {
+		t.Fatalf("FetchSnapshotExclusiveBytes() returned %v [err: %v] (expected 0)", exclusiveBytes, err)
+	}
+
+	// Once the live volume no longer references the LogSegment, the snapshot (eventually) retains it exclusively
+
+	err = volume.DeleteLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		t.Fatalf("DeleteLogSegmentRec() failed: %v", err)
+	}
+
+	err = volume.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		exclusiveBytes, err = volume.FetchSnapshotExclusiveBytes("TestExclusiveBytesSnapshot")
+		if nil != err {
+			t.Fatalf("FetchSnapshotExclusiveBytes() [after DeleteLogSegmentRec()] failed: %v", err)
+		}
+		if 10 <= exclusiveBytes {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("FetchSnapshotExclusiveBytes() [after DeleteLogSegmentRec()] returned %v (expected at least 10)", exclusiveBytes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	volume.(*volumeStruct).Lock()
+	logSegmentBytes, ok := volume.(*volumeStruct).logSegmentBytesMap[logSegmentNumber]
+	volume.(*volumeStruct).Unlock()
+	if !ok || (10 != logSegmentBytes) {
+		t.Fatalf("logSegmentBytesMap[0x%016X] returned %v [ok: %v] (expected 10)", logSegmentNumber, logSegmentBytes, ok)
+	}
+
+	err = volume.DeleteSnapshot("TestExclusiveBytesSnapshot")
+	if nil != err {
+		t.Fatalf("DeleteSnapshot(\"TestExclusiveBytesSnapshot\") failed: %v", err)
+	}
+}
+
+func snapshotIndexPutTest(t *testing.T, volume VolumeHandle) {
+	// Create more snapshots than Swift permits Checkpoint Container headers
+
+	for i := 0; i < 100; i++ {
+		_, err := volume.CreateSnapshot(fmt.Sprintf("TestSnapshotIndex%03d", i))
+		if nil != err {
+			t.Fatalf("CreateSnapshot(\"TestSnapshotIndex%03d\") failed: %v", i, err)
+		}
+	}
+
+	checkpointContainerHeaders, err := swiftclient.ContainerHead("TestAccount", ".__checkpoint__")
+	if nil != err {
+		t.Fatalf("swiftclient.ContainerHead() of Checkpoint Container failed: %v", err)
+	}
+	if headerValues, ok := checkpointContainerHeaders[SnapshotIndexHeaderName]; !ok || (1 != len(headerValues)) || ("" == headerValues[0]) {
+		t.Fatalf("Checkpoint Container missing %v header", SnapshotIndexHeaderName)
+	}
+}
+
+func snapshotIndexCheckTest(t *testing.T, volume VolumeHandle) {
+	snapshotList := volume.FetchSnapshotList()
+	if 100 != len(snapshotList) {
+		t.Fatalf("FetchSnapshotList() returned %d snapshots (expected 100)", len(snapshotList))
+	}
+
+	for i := 0; i < 100; i++ {
+		err := volume.DeleteSnapshot(fmt.Sprintf("TestSnapshotIndex%03d", i))
+		if nil != err {
+			t.Fatalf("DeleteSnapshot(\"TestSnapshotIndex%03d\") failed: %v", i, err)
+		}
+	}
+
+	if 0 != len(volume.FetchSnapshotList()) {
+		t.Fatalf("FetchSnapshotList() after deleting all snapshots should have been empty")
+	}
+}
+
+func journalPutTest(t *testing.T, volume VolumeHandle) (journalNonces []uint64) {
+	journalNonces = make([]uint64, 3)
+
+	for i := range journalNonces {
+		journalNonce, err := volume.FetchNonce()
+		if nil != err {
+			t.Fatalf("FetchNonce() for journalRec failed: %v", err)
+		}
+		err = volume.PutJournalRec(journalNonce, []byte{byte(i)})
+		if nil != err {
+			t.Fatalf("PutJournalRec(0x%016X) failed: %v", journalNonce, err)
+		}
+		journalNonces[i] = journalNonce
+	}
+
+	fetchedJournalNonces, values, err := volume.FetchJournalRecs(0, 2)
+	if (nil != err) || (2 != len(fetchedJournalNonces)) || (journalNonces[0] != fetchedJournalNonces[0]) || (journalNonces[1] != fetchedJournalNonces[1]) || (0 != bytes.Compare(values[1], []byte{1})) {
+		t.Fatalf("FetchJournalRecs(0, 2) returned unexpected (%v, %v, %v)", fetchedJournalNonces, values, err)
+	}
+
+	fetchedJournalNonces, _, err = volume.FetchJournalRecs(journalNonces[1], 2)
+	if (nil != err) || (1 != len(fetchedJournalNonces)) || (journalNonces[2] != fetchedJournalNonces[0]) {
+		t.Fatalf("FetchJournalRecs(0x%016X, 2) returned unexpected (%v, %v)", journalNonces[1], fetchedJournalNonces, err)
+	}
+
+	err = volume.TrimJournalRecs(journalNonces[0])
+	if nil != err {
+		t.Fatalf("TrimJournalRecs(0x%016X) failed: %v", journalNonces[0], err)
+	}
+
+	return
+}
+
+// journalCheckTest verifies the effects of journalPutTest() survived a restart of the volume
+func journalCheckTest(t *testing.T, volume VolumeHandle, journalNonces []uint64) {
+	fetchedJournalNonces, values, err := volume.FetchJournalRecs(0, 10)
+	if (nil != err) || (2 != len(fetchedJournalNonces)) || (journalNonces[1] != fetchedJournalNonces[0]) || (0 != bytes.Compare(values[1], []byte{2})) {
+		t.Fatalf("FetchJournalRecs(0, 10) after restart returned unexpected (%v, %v, %v)", fetchedJournalNonces, values, err)
+	}
+
+	fetchedJournalNonces, _, err = volume.FetchJournalRecs(journalNonces[0], 10)
+	if (nil != err) || (2 != len(fetchedJournalNonces)) {
+		t.Fatalf("FetchJournalRecs(0x%016X, 10) [last trimmed] returned unexpected (%v, %v)", journalNonces[0], fetchedJournalNonces, err)
+	}
+
+	_, _, err = volume.FetchJournalRecs(journalNonces[0]-1, 10)
+	if !blunder.Is(err, blunder.JournalTrimmedError) {
+		t.Fatalf("FetchJournalRecs(0x%016X, 10) [preceding last trimmed] should have failed with JournalTrimmedError: %v", journalNonces[0]-1, err)
+	}
+
+	err = volume.TrimJournalRecs(journalNonces[2])
+	if nil != err {
+		t.Fatalf("TrimJournalRecs(0x%016X) failed: %v", journalNonces[2], err)
+	}
+
+	fetchedJournalNonces, _, err = volume.FetchJournalRecs(0, 10)
+	if (nil != err) || (0 != len(fetchedJournalNonces)) {
+		t.Fatalf("FetchJournalRecs(0, 10) of empty journal returned unexpected (%v, %v)", fetchedJournalNonces, err)
+	}
+
+	_, _, err = volume.FetchJournalRecs(journalNonces[1], 10)
+	if !blunder.Is(err, blunder.JournalTrimmedError) {
+		t.Fatalf("FetchJournalRecs(0x%016X, 10) of empty journal should have failed with JournalTrimmedError: %v", journalNonces[1], err)
+	}
+}
+
+func fingerprintPutTest(t *testing.T, volume VolumeHandle) {
+	_, ok, err := volume.GetFingerprintRec(0x1111)
+	if (nil != err) || ok {
+		t.Fatalf("GetFingerprintRec(0x1111) before PutFingerprintRec() returned unexpected (%v, %v)", ok, err)
+	}
+
+	err = volume.PutFingerprintRec(0x1111, []byte{1})
+	if nil != err {
+		t.Fatalf("PutFingerprintRec(0x1111) failed: %v", err)
+	}
+	err = volume.PutFingerprintRec(0x2222, []byte{2})
+	if nil != err {
+		t.Fatalf("PutFingerprintRec(0x2222) failed: %v", err)
+	}
+
+	value, ok, err := volume.GetFingerprintRec(0x1111)
+	if (nil != err) || !ok || (0 != bytes.Compare(value, []byte{1})) {
+		t.Fatalf("GetFingerprintRec(0x1111) returned unexpected (%v, %v, %v)", value, ok, err)
+	}
+
+	err = volume.DeleteFingerprintRec(0x2222)
+	if nil != err {
+		t.Fatalf("DeleteFingerprintRec(0x2222) failed: %v", err)
+	}
+}
+
+// fingerprintCheckTest verifies the effects of fingerprintPutTest() survived a restart of the volume
+func fingerprintCheckTest(t *testing.T, volume VolumeHandle) {
+	value, ok, err := volume.GetFingerprintRec(0x1111)
+	if (nil != err) || !ok || (0 != bytes.Compare(value, []byte{1})) {
+		t.Fatalf("GetFingerprintRec(0x1111) after restart returned unexpected (%v, %v, %v)", value, ok, err)
+	}
+
+	_, ok, err = volume.GetFingerprintRec(0x2222)
+	if (nil != err) || ok {
+		t.Fatalf("GetFingerprintRec(0x2222) after restart returned unexpected (%v, %v)", ok, err)
+	}
+
+	err = volume.DeleteFingerprintRec(0x1111)
+	if nil != err {
+		t.Fatalf("DeleteFingerprintRec(0x1111) failed: %v", err)
+	}
+}
+
+func checkpointRecoveryTest(t *testing.T, volumeHandle VolumeHandle) {
+	var (
+		key   = uint64(5678)
+		value = []byte{21, 22, 23, 24}
+	)
+
+	volume := volumeHandle.(*volumeStruct)
+
+	err := volumeHandle.PutInodeRec(key, value)
+	if nil != err {
+		t.Fatalf("PutInodeRec(%d) failed: %v", key, err)
+	}
+
+	err = volumeHandle.PutLogSegmentRec(key, value)
+	if nil != err {
+		t.Fatalf("PutLogSegmentRec(%d) failed: %v", key, err)
+	}
+	err = volumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() prior to failure failed: %v", err)
+	}
+	err = volumeHandle.PutInodeRec(key, value)
+	if nil != err {
+		t.Fatalf("PutInodeRec(%d) [again] failed: %v", key, err)
+	}
+
+	volume.Lock()
+	logSegmentRecBPlusTreeLayout := make(map[uint64]uint64)
+	for objectNumber, objectBytes := range volume.logSegmentRecBPlusTreeLayout {
+		logSegmentRecBPlusTreeLayout[objectNumber] = objectBytes
+	}
+	volume.Unlock()
+
+	// Redirect checkpoints to a non-existent container so that they fail
+
+	volume.Lock()
+	checkpointContainerName := volume.checkpointContainerName
+	volume.checkpointContainerName = ".__missing_checkpoint__"
+	volume.Unlock()
+
+	err = volumeHandle.DoCheckpoint()
+	if nil == err {
+		t.Fatalf("DoCheckpoint() to missing checkpoint container should have failed")
+	}
+
+	checkpointHealth := volumeHandle.FetchCheckpointHealth()
+	if (CheckpointDegraded != checkpointHealth.State) || (0 == checkpointHealth.ConsecutiveFailures) || (nil == checkpointHealth.LastFailureErr) {
+		t.Fatalf("FetchCheckpointHealth() after failed checkpoint returned unexpected %+v", checkpointHealth)
+	}
+
+	volume.Lock()
+	failedCheckpointObjectRetained := (nil != volume.checkpointFailedChunkedPutContext)
+	volume.Unlock()
+	if !failedCheckpointObjectRetained {
+		t.Fatalf("Failed checkpoint should have retained the checkpoint object it could not PUT")
+	}
+
+	// Writes should now be quiesced
+
+	putDoneChan := make(chan error, 1)
+
+	go func() {
+		putDoneChan <- volumeHandle.PutInodeRec(key+1, value)
+	}()
+
+	select {
+	case err = <-putDoneChan:
+		t.Fatalf("PutInodeRec(%d) should have blocked while checkpoints are failing (err: %v)", key+1, err)
+	case <-time.After(100 * time.Millisecond):
+		// Expected
+	}
+
+	// Once checkpoints can succeed again, writes should resume
+
+	volume.Lock()
+	volume.checkpointContainerName = checkpointContainerName
+	volume.Unlock()
+
+	err = volumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() following recovery failed: %v", err)
+	}
+
+	checkpointHealth = volumeHandle.FetchCheckpointHealth()
+	if (CheckpointHealthy != checkpointHealth.State) || (0 != checkpointHealth.ConsecutiveFailures) {
+		t.Fatalf("FetchCheckpointHealth() following recovery returned unexpected %+v", checkpointHealth)
+	}
+
+	err = <-putDoneChan
+	if nil != err {
+		t.Fatalf("PutInodeRec(%d) following recovery failed: %v", key+1, err)
+	}
+
+	// Only the lost checkpoint object should have been replayed... the untouched LogSegmentRec B+Tree was not re-written
+
+	volume.Lock()
+	logSegmentRecBPlusTreeLayoutUnchanged := (len(logSegmentRecBPlusTreeLayout) == len(volume.logSegmentRecBPlusTreeLayout))
+	for objectNumber, objectBytes := range logSegmentRecBPlusTreeLayout {
+		if objectBytes != volume.logSegmentRecBPlusTreeLayout[objectNumber] {
+			logSegmentRecBPlusTreeLayoutUnchanged = false
+		}
+	}
+	volume.Unlock()
+	if !logSegmentRecBPlusTreeLayoutUnchanged {
+		t.Fatalf("Checkpoint recovery should not have re-written the LogSegmentRec B+Tree")
+	}
+
+	// Ensure the re-dirtied B+Tree nodes were correctly re-written
+
+	err = volumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() [again] following recovery failed: %v", err)
+	}
+
+	for _, k := range []uint64{key, key + 1} {
+		v, ok, nonShadowingErr := volumeHandle.GetInodeRec(k)
+		if (nil != nonShadowingErr) || !ok || (0 != bytes.Compare(v, value)) {
+			t.Fatalf("GetInodeRec(%d) following recovery returned unexpected (%v, %v, %v)", k, v, ok, nonShadowingErr)
+		}
+		err = volumeHandle.DeleteInodeRec(k)
+		if nil != err {
+			t.Fatalf("DeleteInodeRec(%d) following recovery failed: %v", k, err)
+		}
+	}
+
+	err = volumeHandle.DeleteLogSegmentRec(key)
+	if nil != err {
+		t.Fatalf("DeleteLogSegmentRec(%d) following recovery failed: %v", key, err)
+	}
+}
+
+// lostChunkedPutContextStruct stands in for a ChunkedPutContext that failed fatally retaining none of what was sent to it
+type lostChunkedPutContextStruct struct{}
+
+func (lostChunkedPutContext *lostChunkedPutContextStruct) BytesPut() (bytesPut uint64, err error) {
+	err = fmt.Errorf("lostChunkedPutContext retains nothing")
+	return
+}
+
+func (lostChunkedPutContext *lostChunkedPutContextStruct) Close() (err error) {
+	err = fmt.Errorf("lostChunkedPutContext cannot be closed")
+	return
+}
+
+func (lostChunkedPutContext *lostChunkedPutContextStruct) Read(offset uint64, length uint64) (buf []byte, err error) {
+	err = fmt.Errorf("lostChunkedPutContext retains nothing")
+	return
+}
+
+func (lostChunkedPutContext *lostChunkedPutContextStruct) SendChunk(buf []byte) (err error) {
+	err = fmt.Errorf("lostChunkedPutContext cannot send")
+	return
+}
+
+func checkpointRecoveryWithoutReplayTest(t *testing.T, volumeHandle VolumeHandle) {
+	var (
+		key   = uint64(6789)
+		value = []byte{31, 32, 33, 34}
+	)
+
+	volume := volumeHandle.(*volumeStruct)
+
+	err := volumeHandle.PutInodeRec(key, value)
+	if nil != err {
+		t.Fatalf("PutInodeRec(%d) failed: %v", key, err)
+	}
+	err = volumeHandle.PutLogSegmentRec(key, value)
+	if nil != err {
+		t.Fatalf("PutLogSegmentRec(%d) failed: %v", key, err)
+	}
+	err = volumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() prior to failure failed: %v", err)
+	}
+	err = volumeHandle.PutInodeRec(key, value)
+	if nil != err {
+		t.Fatalf("PutInodeRec(%d) [again] failed: %v", key, err)
+	}
+
+	volume.Lock()
+	logSegmentRecBPlusTreeLayout := make(map[uint64]uint64)
+	for objectNumber, objectBytes := range volume.logSegmentRecBPlusTreeLayout {
+		logSegmentRecBPlusTreeLayout[objectNumber] = objectBytes
+	}
+	checkpointContainerName := volume.checkpointContainerName
+	volume.checkpointContainerName = ".__missing_checkpoint__"
+	volume.Unlock()
+
+	err = volumeHandle.DoCheckpoint()
+	if nil == err {
+		t.Fatalf("DoCheckpoint() to missing checkpoint container should have failed")
+	}
+
+	// Have the failed checkpoint object's ChunkedPutContext lose everything sent to it so that it cannot be replayed
+
+	volume.Lock()
+	if nil == volume.checkpointFailedChunkedPutContext {
+		volume.Unlock()
+		t.Fatalf("Failed checkpoint should have retained the checkpoint object it could not PUT")
+	}
+	lostObjectNumber := volume.checkpointFailedChunkedPutContextObjectNumber
+	volume.checkpointFailedChunkedPutContext = &lostChunkedPutContextStruct{}
+	volume.checkpointContainerName = checkpointContainerName
+	volume.Unlock()
+
+	err = volumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() following unreplayable failure failed: %v", err)
+	}
+
+	checkpointHealth := volumeHandle.FetchCheckpointHealth()
+	if (CheckpointHealthy != checkpointHealth.State) || (0 != checkpointHealth.ConsecutiveFailures) {
+		t.Fatalf("FetchCheckpointHealth() following unreplayable failure returned unexpected %+v", checkpointHealth)
+	}
+
+	// Every B+Tree node should have been re-written... such that none remain in the lost checkpoint object
+
+	volume.Lock()
+	lostObjectReferenced := (0 != volume.inodeRecBPlusTreeLayout[lostObjectNumber]) || (0 != volume.logSegmentRecBPlusTreeLayout[lostObjectNumber])
+	logSegmentRecBPlusTreeLayoutUnchanged := (len(logSegmentRecBPlusTreeLayout) == len(volume.logSegmentRecBPlusTreeLayout))
+	for objectNumber, objectBytes := range logSegmentRecBPlusTreeLayout {
+		if objectBytes != volume.logSegmentRecBPlusTreeLayout[objectNumber] {
+			logSegmentRecBPlusTreeLayoutUnchanged = false
+		}
+	}
+	redirtyNeeded := volume.checkpointRedirtyNeeded
+	volume.Unlock()
+	if lostObjectReferenced {
+		t.Fatalf("Checkpoint recovery without replay left B+Tree nodes in lost checkpoint object %016X", lostObjectNumber)
+	}
+	if logSegmentRecBPlusTreeLayoutUnchanged {
+		t.Fatalf("Checkpoint recovery without replay should have re-written the LogSegmentRec B+Tree")
+	}
+	if redirtyNeeded {
+		t.Fatalf("Checkpoint recovery without replay left checkpointRedirtyNeeded set")
+	}
+
+	// Ensure the B+Trees remain intact once their nodes must be fetched from Swift
+
+	volume.Lock()
+	err = volume.inodeRecWrapper.bPlusTree.Purge(true)
+	if nil == err {
+		err = volume.logSegmentRecWrapper.bPlusTree.Purge(true)
+	}
+	volume.Unlock()
+	if nil != err {
+		t.Fatalf("Purge() following recovery without replay failed: %v", err)
+	}
+
+	v, ok, err := volumeHandle.GetInodeRec(key)
+	if (nil != err) || !ok || (0 != bytes.Compare(v, value)) {
+		t.Fatalf("GetInodeRec(%d) following recovery without replay returned unexpected (%v, %v, %v)", key, v, ok, err)
+	}
+	v, err = volumeHandle.GetLogSegmentRec(key)
+	if (nil != err) || (0 != bytes.Compare(v, value)) {
+		t.Fatalf("GetLogSegmentRec(%d) following recovery without replay returned unexpected (%v, %v)", key, v, err)
+	}
+
+	err = volumeHandle.DeleteInodeRec(key)
+	if nil != err {
+		t.Fatalf("DeleteInodeRec(%d) following recovery without replay failed: %v", key, err)
+	}
+	err = volumeHandle.DeleteLogSegmentRec(key)
+	if nil != err {
+		t.Fatalf("DeleteLogSegmentRec(%d) following recovery without replay failed: %v", key, err)
+	}
+}
+
 func TestHeadHunterAPI(t *testing.T) {
 	confStrings := []string{
 		"Logging.LogFilePath=/dev/null",
@@ -120,6 +759,9 @@ func TestHeadHunterAPI(t *testing.T) {
 		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
 		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
 		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:TestClone.AccountName=TestCloneAccount",
+		"Volume:TestClone.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestClone.CheckpointContainerStoragePolicy=gold",
 		"FSGlobals.VolumeList=TestVolume",
 		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
 		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
@@ -216,6 +858,12 @@ func TestHeadHunterAPI(t *testing.T) {
 		t.Fatalf("FetchNonce() [case 1] returned error: %v", err)
 	}
 
+	journalNonces := journalPutTest(t, volume)
+
+	fingerprintPutTest(t, volume)
+
+	snapshotIndexPutTest(t, volume)
+
 	err = Down()
 	if nil != err {
 		t.Fatalf("headhunter.Down() [case 1] returned error: %v", err)
@@ -289,6 +937,12 @@ func TestHeadHunterAPI(t *testing.T) {
 		t.Fatalf("FetchNonce() [case 2] returned unexpected nonce: %v (should have been > %v)", secondUpNonce, firstUpNonce)
 	}
 
+	journalCheckTest(t, volume, journalNonces)
+
+	fingerprintCheckTest(t, volume)
+
+	snapshotIndexCheckTest(t, volume)
+
 	var key uint64
 	key = 1234
 	value := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
@@ -315,6 +969,14 @@ func TestHeadHunterAPI(t *testing.T) {
 		t.Fatalf("Delete of key %d failed: %v", key, err)
 	}
 
+	snapshotTest(t, confMap, volume)
+
+	snapshotExclusiveBytesTest(t, volume)
+
+	checkpointRecoveryTest(t, volume)
+
+	checkpointRecoveryWithoutReplayTest(t, volume)
+
 	// Shutdown packages
 
 	err = Down()
@@ -38,11 +38,25 @@ const (
 	// uint64 in %016X indicating length of               checkpoint record at tail of object
 	// ' '
 	// uint64 in %016X indicating reservedToNonce
+	checkpointHeaderVersion3
+	// uint64 in %016X indicating checkpointHeaderVersion3
+	// ' '
+	// uint64 in %016X indicating objectNumber containing checkpoint record at tail of object
+	// ' '
+	// uint64 in %016X indicating length of               checkpoint record at tail of object
+	// ' '
+	// uint64 in %016X indicating reservedToNonce
 )
 
+// checkpointHeaderVersion3 differs from checkpointHeaderVersion2 only in that the checkpoint record at the tail
+// of the object is a checkpointObjectTrailerV3Struct (adding the journalRec and fingerprintRec B+Trees). Hence, both
+// are parsed into a checkpointHeaderV2Struct and, once loaded, a checkpointObjectTrailerV2Struct is held as the
+// equivalent checkpointObjectTrailerV3Struct (with empty journalRec and fingerprintRec B+Trees). The next
+// putCheckpoint() records version 3.
+
 type checkpointHeaderV2Struct struct {
-	CheckpointObjectTrailerV2StructObjectNumber uint64 // checkpointObjectTrailerV2Struct found at "tail" of object
-	CheckpointObjectTrailerV2StructObjectLength uint64 // this length includes the three B+Tree "layouts" appended
+	CheckpointObjectTrailerV2StructObjectNumber uint64 // checkpointObjectTrailerV{2|3}Struct found at "tail" of object
+	CheckpointObjectTrailerV2StructObjectLength uint64 // this length includes the three (or five) B+Tree "layouts" appended
 	ReservedToNonce                             uint64 // highest nonce value reserved
 }
 
@@ -64,15 +78,46 @@ type checkpointObjectTrailerV2Struct struct {
 	// bPlusTreeObjectBPlusTreeLayout serialized as [bPlusTreeObjectBPlusTreeLayoutNumElements]elementOfBPlusTreeLayoutStruct
 }
 
+type checkpointObjectTrailerV3Struct struct {
+	InodeRecBPlusTreeObjectNumber             uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of inodeRec        B+Tree
+	InodeRecBPlusTreeObjectOffset             uint64 // ...and offset into the Object where root starts
+	InodeRecBPlusTreeObjectLength             uint64 // ...and length if that root node
+	InodeRecBPlusTreeLayoutNumElements        uint64 // elements immediately follow checkpointObjectTrailerV3Struct
+	LogSegmentRecBPlusTreeObjectNumber        uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of logSegment      B+Tree
+	LogSegmentRecBPlusTreeObjectOffset        uint64 // ...and offset into the Object where root starts
+	LogSegmentRecBPlusTreeObjectLength        uint64 // ...and length if that root node
+	LogSegmentRecBPlusTreeLayoutNumElements   uint64 // elements immediately follow inodeRecBPlusTreeLayout
+	BPlusTreeObjectBPlusTreeObjectNumber      uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of bPlusTreeObject B+Tree
+	BPlusTreeObjectBPlusTreeObjectOffset      uint64 // ...and offset into the Object where root starts
+	BPlusTreeObjectBPlusTreeObjectLength      uint64 // ...and length if that root node
+	BPlusTreeObjectBPlusTreeLayoutNumElements uint64 // elements immediately follow logSegmentRecBPlusTreeLayout
+	JournalRecBPlusTreeObjectNumber           uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of journalRec      B+Tree
+	JournalRecBPlusTreeObjectOffset           uint64 // ...and offset into the Object where root starts
+	JournalRecBPlusTreeObjectLength           uint64 // ...and length if that root node
+	JournalRecBPlusTreeLayoutNumElements      uint64 // elements immediately follow bPlusTreeObjectBPlusTreeLayout
+	JournalRecTrimmedToNonce                  uint64 // journalRecs keyed at or below this nonce have been trimmed
+	FingerprintRecBPlusTreeObjectNumber       uint64 // if != 0, objectNumber-named Object in <accountName>.<checkpointContainerName> where root of fingerprintRec  B+Tree
+	FingerprintRecBPlusTreeObjectOffset       uint64 // ...and offset into the Object where root starts
+	FingerprintRecBPlusTreeObjectLength       uint64 // ...and length if that root node
+	FingerprintRecBPlusTreeLayoutNumElements  uint64 // elements immediately follow journalRecBPlusTreeLayout
+	// inodeRecBPlusTreeLayout        serialized as [inodeRecBPlusTreeLayoutNumElements       ]elementOfBPlusTreeLayoutStruct
+	// logSegmentBPlusTreeLayout      serialized as [logSegmentRecBPlusTreeLayoutNumElements  ]elementOfBPlusTreeLayoutStruct
+	// bPlusTreeObjectBPlusTreeLayout serialized as [bPlusTreeObjectBPlusTreeLayoutNumElements]elementOfBPlusTreeLayoutStruct
+	// journalRecBPlusTreeLayout      serialized as [journalRecBPlusTreeLayoutNumElements     ]elementOfBPlusTreeLayoutStruct
+	// fingerprintRecBPlusTreeLayout  serialized as [fingerprintRecBPlusTreeLayoutNumElements ]elementOfBPlusTreeLayoutStruct
+}
+
 type elementOfBPlusTreeLayoutStruct struct {
 	ObjectNumber uint64
 	ObjectBytes  uint64
 }
 
 type checkpointRequestStruct struct {
-	waitGroup        sync.WaitGroup
-	err              error
-	exitOnCompletion bool
+	waitGroup          sync.WaitGroup
+	err                error
+	exitOnCompletion   bool
+	createSnapshotName string         // if != "", snapshot the resultant checkpoint with this name
+	snapshot           SnapshotStruct // describes the snapshot created if createSnapshotName != ""
 }
 
 const (
@@ -88,6 +133,10 @@ const (
 	transactionDeleteLogSegmentRec
 	transactionPutBPlusTreeObject
 	transactionDeleteBPlusTreeObject
+	transactionPutJournalRec
+	transactionTrimJournalRecs
+	transactionPutFingerprintRec
+	transactionDeleteFingerprintRec
 )
 
 type replayLogTransactionFixedPartStruct struct { //          transactions begin on a replayLogWriteBufferAlignment boundary
@@ -160,6 +209,14 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionPutBPlusTreeObject, volume.volumeName, keys.(uint64))
 	case transactionDeleteBPlusTreeObject:
 		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionDeleteBPlusTreeObject, volume.volumeName, keys.(uint64))
+	case transactionPutJournalRec:
+		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionPutJournalRec, volume.volumeName, keys.(uint64))
+	case transactionTrimJournalRecs:
+		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionTrimJournalRecs, volume.volumeName, keys.(uint64))
+	case transactionPutFingerprintRec:
+		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionPutFingerprintRec, volume.volumeName, keys.(uint64))
+	case transactionDeleteFingerprintRec:
+		evtlog.Record(evtlog.FormatHeadhunterRecordTransactionDeleteFingerprintRec, volume.volumeName, keys.(uint64))
 	default:
 		logger.Fatalf("headhunter.recordTransaction(transactionType==%v,,) invalid", transactionType)
 	}
@@ -255,6 +312,50 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 				globals.uint64Size + //               last checkpointHeaderV2Struct.CheckpointObjectTrailerV2StructObjectNumber
 				globals.uint64Size + //               transactionType == transactionDeleteBPlusTreeObject
 				globals.uint64Size //                 objectNumber
+	case transactionPutJournalRec:
+		singleKey = keys.(uint64)
+		singleValue = values.([]byte)
+		bytesNeeded = //                              transactions begin on a replayLogWriteBufferAlignment boundary
+			globals.uint64Size + //                   checksum of everything after this field
+				globals.uint64Size + //               bytes following in this transaction
+				globals.uint64Size + //               last checkpointHeaderV2Struct.CheckpointObjectTrailerV2StructObjectNumber
+				globals.uint64Size + //               transactionType == transactionPutJournalRec
+				globals.uint64Size + //               journalNonce
+				globals.uint64Size + //               len(value)
+				uint64(len(singleValue)) //           value
+	case transactionTrimJournalRecs:
+		singleKey = keys.(uint64)
+		if nil != values {
+			logger.Fatalf("headhunter.recordTransaction(transactionType==transactionTrimJournalRecs,,) passed non-nil values")
+		}
+		bytesNeeded = //                              transactions begin on a replayLogWriteBufferAlignment boundary
+			globals.uint64Size + //                   checksum of everything after this field
+				globals.uint64Size + //               bytes following in this transaction
+				globals.uint64Size + //               last checkpointHeaderV2Struct.CheckpointObjectTrailerV2StructObjectNumber
+				globals.uint64Size + //               transactionType == transactionTrimJournalRecs
+				globals.uint64Size //                 trimToNonce
+	case transactionPutFingerprintRec:
+		singleKey = keys.(uint64)
+		singleValue = values.([]byte)
+		bytesNeeded = //                              transactions begin on a replayLogWriteBufferAlignment boundary
+			globals.uint64Size + //                   checksum of everything after this field
+				globals.uint64Size + //               bytes following in this transaction
+				globals.uint64Size + //               last checkpointHeaderV2Struct.CheckpointObjectTrailerV2StructObjectNumber
+				globals.uint64Size + //               transactionType == transactionPutFingerprintRec
+				globals.uint64Size + //               fingerprint
+				globals.uint64Size + //               len(value)
+				uint64(len(singleValue)) //           value
+	case transactionDeleteFingerprintRec:
+		singleKey = keys.(uint64)
+		if nil != values {
+			logger.Fatalf("headhunter.recordTransaction(transactionType==transactionDeleteFingerprintRec,,) passed non-nil values")
+		}
+		bytesNeeded = //                              transactions begin on a replayLogWriteBufferAlignment boundary
+			globals.uint64Size + //                   checksum of everything after this field
+				globals.uint64Size + //               bytes following in this transaction
+				globals.uint64Size + //               last checkpointHeaderV2Struct.CheckpointObjectTrailerV2StructObjectNumber
+				globals.uint64Size + //               transactionType == transactionDeleteFingerprintRec
+				globals.uint64Size //                 fingerprint
 	default:
 		logger.Fatalf("headhunter.recordTransaction(transactionType==%v,,) invalid", transactionType)
 	}
@@ -417,6 +518,66 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 	case transactionDeleteBPlusTreeObject:
 		// Fill in objectNumber
 
+		packedUint64, err = cstruct.Pack(singleKey, LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+	case transactionPutJournalRec:
+		// Fill in journalNonce
+
+		packedUint64, err = cstruct.Pack(singleKey, LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+
+		// Fill in len(value) and value
+
+		packedUint64, err = cstruct.Pack(uint64(len(singleValue)), LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], singleValue)
+		replayLogWriteBufferPosition += uint64(len(singleValue))
+	case transactionTrimJournalRecs:
+		// Fill in trimToNonce
+
+		packedUint64, err = cstruct.Pack(singleKey, LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+	case transactionPutFingerprintRec:
+		// Fill in fingerprint
+
+		packedUint64, err = cstruct.Pack(singleKey, LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+
+		// Fill in len(value) and value
+
+		packedUint64, err = cstruct.Pack(uint64(len(singleValue)), LittleEndian)
+		if nil != err {
+			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
+		}
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], packedUint64)
+		replayLogWriteBufferPosition += globals.uint64Size
+
+		_ = copy(replayLogWriteBuffer[replayLogWriteBufferPosition:], singleValue)
+		replayLogWriteBufferPosition += uint64(len(singleValue))
+	case transactionDeleteFingerprintRec:
+		// Fill in fingerprint
+
 		packedUint64, err = cstruct.Pack(singleKey, LittleEndian)
 		if nil != err {
 			logger.Fatalf("cstruct.Pack() unexpectedly returned error: %v", err)
@@ -468,41 +629,41 @@ func (volume *volumeStruct) recordTransaction(transactionType uint64, keys inter
 
 func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 	var (
-		accountHeaderValues                 []string
-		accountHeaders                      map[string][]string
-		bytesConsumed                       uint64
-		bytesNeeded                         uint64
-		checkpointContainerHeaders          map[string][]string
-		checkpointHeader                    checkpointHeaderV2Struct
-		checkpointHeaderValue               string
-		checkpointHeaderValueSlice          []string
-		checkpointHeaderValues              []string
-		checkpointObjectTrailerBuf          []byte
-		checkpointVersion                   uint64
-		computedCRC64                       uint64
-		defaultReplayLogReadBuffer          []byte
-		elementOfBPlusTreeLayout            elementOfBPlusTreeLayoutStruct
-		expectedCheckpointObjectTrailerSize uint64
-		i                                   uint64
-		inodeNumber                         uint64
-		layoutReportIndex                   uint64
-		logSegmentNumber                    uint64
-		numInodes                           uint64
-		objectNumber                        uint64
-		ok                                  bool
-		replayLogReadBuffer                 []byte
-		replayLogReadBufferPosition         uint64
-		replayLogPosition                   int64
-		replayLogSize                       int64
-		replayLogTransactionFixedPart       replayLogTransactionFixedPartStruct
-		storagePolicyHeaderValues           []string
-		value                               []byte
-		valueLen                            uint64
+		accountHeaderValues           []string
+		accountHeaders                map[string][]string
+		bytesNeeded                   uint64
+		checkpointContainerHeaders    map[string][]string
+		checkpointHeader              checkpointHeaderV2Struct
+		checkpointHeaderValue         string
+		checkpointHeaderValueSlice    []string
+		checkpointHeaderValues        []string
+		checkpointVersion             uint64
+		computedCRC64                 uint64
+		defaultReplayLogReadBuffer    []byte
+		fingerprint                   uint64
+		i                             uint64
+		inodeNumber                   uint64
+		journalNonce                  uint64
+		logSegmentNumber              uint64
+		numInodes                     uint64
+		objectNumber                  uint64
+		ok                            bool
+		replayLogReadBuffer           []byte
+		replayLogReadBufferPosition   uint64
+		replayLogPosition             int64
+		replayLogSize                 int64
+		replayLogTransactionFixedPart replayLogTransactionFixedPartStruct
+		storagePolicyHeaderValues     []string
+		trimToNonce                   uint64
+		value                         []byte
+		valueLen                      uint64
 	)
 
 	volume.inodeRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: inodeRecBPlusTreeWrapperType}
 	volume.logSegmentRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: logSegmentRecBPlusTreeWrapperType}
 	volume.bPlusTreeObjectWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: bPlusTreeObjectBPlusTreeWrapperType}
+	volume.journalRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: journalRecBPlusTreeWrapperType}
+	volume.fingerprintRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: fingerprintRecBPlusTreeWrapperType}
 
 	checkpointContainerHeaders, err = swiftclient.ContainerHead(volume.accountName, volume.checkpointContainerName)
 	if nil == err {
@@ -527,7 +688,7 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 			checkpointHeader.ReservedToNonce = firstNonceToProvide // First FetchNonce() will trigger a reserve step
 
 			checkpointHeaderValue = fmt.Sprintf("%016X %016X %016X %016X",
-				checkpointHeaderVersion2,
+				checkpointHeaderVersion3,
 				checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
 				checkpointHeader.CheckpointObjectTrailerV2StructObjectLength,
 				checkpointHeader.ReservedToNonce,
@@ -578,10 +739,10 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 		return
 	}
 
-	if checkpointHeaderVersion2 == checkpointVersion {
+	if (checkpointHeaderVersion2 == checkpointVersion) || (checkpointHeaderVersion3 == checkpointVersion) {
 		// Read in checkpointHeaderV2Struct
 
-		volume.checkpointHeaderVersion = checkpointHeaderVersion2
+		volume.checkpointHeaderVersion = checkpointVersion
 
 		if 4 != len(checkpointHeaderValueSlice) {
 			err = fmt.Errorf("Cannot parse %v/%v header %v: %v (wrong number of fields)", volume.accountName, volume.checkpointContainerName, CheckpointHeaderName, checkpointHeaderValue)
@@ -611,9 +772,11 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 		volume.inodeRecBPlusTreeLayout = make(sortedmap.LayoutReport)
 		volume.logSegmentRecBPlusTreeLayout = make(sortedmap.LayoutReport)
 		volume.bPlusTreeObjectBPlusTreeLayout = make(sortedmap.LayoutReport)
+		volume.journalRecBPlusTreeLayout = make(sortedmap.LayoutReport)
+		volume.fingerprintRecBPlusTreeLayout = make(sortedmap.LayoutReport)
 
 		if 0 == volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber {
-			volume.checkpointObjectTrailer = &checkpointObjectTrailerV2Struct{
+			volume.checkpointObjectTrailer = &checkpointObjectTrailerV3Struct{
 				InodeRecBPlusTreeObjectNumber:             0,
 				InodeRecBPlusTreeObjectOffset:             0,
 				InodeRecBPlusTreeObjectLength:             0,
@@ -626,133 +789,82 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 				BPlusTreeObjectBPlusTreeObjectOffset:      0,
 				BPlusTreeObjectBPlusTreeObjectLength:      0,
 				BPlusTreeObjectBPlusTreeLayoutNumElements: 0,
+				JournalRecBPlusTreeObjectNumber:           0,
+				JournalRecBPlusTreeObjectOffset:           0,
+				JournalRecBPlusTreeObjectLength:           0,
+				JournalRecBPlusTreeLayoutNumElements:      0,
+				JournalRecTrimmedToNonce:                  0,
+				FingerprintRecBPlusTreeObjectNumber:       0,
+				FingerprintRecBPlusTreeObjectOffset:       0,
+				FingerprintRecBPlusTreeObjectLength:       0,
+				FingerprintRecBPlusTreeLayoutNumElements:  0,
 			}
 		} else {
-			// Read in checkpointObjectTrailerV2Struct
-			checkpointObjectTrailerBuf, err =
-				swiftclient.ObjectTail(
-					volume.accountName,
-					volume.checkpointContainerName,
-					utils.Uint64ToHexStr(volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber),
-					volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength)
+			volume.checkpointObjectTrailer,
+				volume.inodeRecBPlusTreeLayout,
+				volume.logSegmentRecBPlusTreeLayout,
+				volume.bPlusTreeObjectBPlusTreeLayout,
+				volume.journalRecBPlusTreeLayout,
+				volume.fingerprintRecBPlusTreeLayout,
+				err = volume.fetchCheckpointObjectTrailer(
+				checkpointVersion,
+				volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+				volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength)
 			if nil != err {
 				return
 			}
+		}
 
-			volume.checkpointObjectTrailer = &checkpointObjectTrailerV2Struct{}
-
-			bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, volume.checkpointObjectTrailer, LittleEndian)
-			if nil != err {
-				return
-			}
-
-			// Deserialize volume.{inodeRec|logSegmentRec|bPlusTreeObject}BPlusTreeLayout LayoutReports
-
-			expectedCheckpointObjectTrailerSize = volume.checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements
-			expectedCheckpointObjectTrailerSize += volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements
-			expectedCheckpointObjectTrailerSize += volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements
-			expectedCheckpointObjectTrailerSize *= globals.elementOfBPlusTreeLayoutStructSize
-			expectedCheckpointObjectTrailerSize += bytesConsumed
-
-			if uint64(len(checkpointObjectTrailerBuf)) != expectedCheckpointObjectTrailerSize {
-				err = fmt.Errorf("volume.checkpointObjectTrailer for volume %v does not match required size", volume.volumeName)
-				return
-			}
-
-			for layoutReportIndex = 0; layoutReportIndex < volume.checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
-				checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
-				bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
-				if nil != err {
-					return
-				}
-
-				volume.inodeRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
-			}
-
-			for layoutReportIndex = 0; layoutReportIndex < volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
-				checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
-				bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
-				if nil != err {
-					return
-				}
-
-				volume.logSegmentRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
-			}
-
-			for layoutReportIndex = 0; layoutReportIndex < volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements; layoutReportIndex++ {
-				checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
-				bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
-				if nil != err {
-					return
-				}
+		// Load volume.{inodeRec|logSegmentRec|bPlusTreeObject|journalRec|fingerprintRec} B+Trees
 
-				volume.bPlusTreeObjectBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
-			}
+		err = volume.inodeRecWrapper.loadBPlusTree(
+			volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+			volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+			volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
+			volume.maxInodesPerMetadataNode,
+			globals.inodeRecCache)
+		if nil != err {
+			return
 		}
 
-		// Load volume.{inodeRec|logSegmentRec|bPlusTreeObject} B+Trees
+		err = volume.logSegmentRecWrapper.loadBPlusTree(
+			volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+			volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+			volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
+			volume.maxLogSegmentsPerMetadataNode,
+			globals.logSegmentRecCache)
+		if nil != err {
+			return
+		}
 
-		if 0 == volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber {
-			volume.inodeRecWrapper.bPlusTree =
-				sortedmap.NewBPlusTree(
-					volume.maxInodesPerMetadataNode,
-					sortedmap.CompareUint64,
-					volume.inodeRecWrapper,
-					globals.inodeRecCache)
-		} else {
-			volume.inodeRecWrapper.bPlusTree, err =
-				sortedmap.OldBPlusTree(
-					volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
-					volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
-					volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
-					sortedmap.CompareUint64,
-					volume.inodeRecWrapper,
-					globals.inodeRecCache)
-			if nil != err {
-				return
-			}
+		err = volume.bPlusTreeObjectWrapper.loadBPlusTree(
+			volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+			volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+			volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
+			volume.maxDirFileNodesPerMetadataNode,
+			globals.bPlusTreeObjectCache)
+		if nil != err {
+			return
 		}
 
-		if 0 == volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber {
-			volume.logSegmentRecWrapper.bPlusTree =
-				sortedmap.NewBPlusTree(
-					volume.maxLogSegmentsPerMetadataNode,
-					sortedmap.CompareUint64,
-					volume.logSegmentRecWrapper,
-					globals.logSegmentRecCache)
-		} else {
-			volume.logSegmentRecWrapper.bPlusTree, err =
-				sortedmap.OldBPlusTree(
-					volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
-					volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
-					volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
-					sortedmap.CompareUint64,
-					volume.logSegmentRecWrapper,
-					globals.logSegmentRecCache)
-			if nil != err {
-				return
-			}
+		err = volume.journalRecWrapper.loadBPlusTree(
+			volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectNumber,
+			volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectOffset,
+			volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectLength,
+			volume.maxJournalRecsPerMetadataNode,
+			globals.journalRecCache)
+		if nil != err {
+			return
 		}
 
-		if 0 == volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber {
-			volume.bPlusTreeObjectWrapper.bPlusTree =
-				sortedmap.NewBPlusTree(
-					volume.maxDirFileNodesPerMetadataNode,
-					sortedmap.CompareUint64,
-					volume.bPlusTreeObjectWrapper,
-					globals.bPlusTreeObjectCache)
-		} else {
-			volume.bPlusTreeObjectWrapper.bPlusTree, err =
-				sortedmap.OldBPlusTree(
-					volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
-					volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
-					volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
-					sortedmap.CompareUint64,
-					volume.bPlusTreeObjectWrapper,
-					globals.bPlusTreeObjectCache)
-			if nil != err {
-				return
-			}
+		err = volume.fingerprintRecWrapper.loadBPlusTree(
+			volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectNumber,
+			volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectOffset,
+			volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectLength,
+			volume.maxFingerprintRecsPerMetadataNode,
+			globals.fingerprintRecCache)
+		if nil != err {
+			return
 		}
 	} else {
 		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (version: %v not supported)", volume.accountName, volume.checkpointContainerName, CheckpointHeaderName, checkpointHeaderValue, checkpointVersion)
@@ -761,6 +873,20 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 
 	volume.nextNonce = volume.checkpointHeader.ReservedToNonce
 
+	// Load any snapshots recorded in the Checkpoint Container
+
+	err = volume.loadSnapshots(checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	// Note if the volume is a clone (still) sharing LogSegments with its source volume
+
+	err = volume.loadCloneSource(checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
 	// Check for the need to process a Replay Log
 
 	if "" == volume.replayLogFileName {
@@ -986,6 +1112,74 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 			if nil != err {
 				logger.Fatalf("Reply Log for Volume %s hit unexpected volume.bPlusTreeObjectWrapper.bPlusTree.DeleteByKey() failure: %v", volume.volumeName, err)
 			}
+		case transactionPutJournalRec:
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &journalNonce, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+			replayLogReadBufferPosition += globals.uint64Size
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &valueLen, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+			replayLogReadBufferPosition += globals.uint64Size
+			value = make([]byte, valueLen)
+			copy(value, replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+valueLen])
+
+			ok, err = volume.journalRecWrapper.bPlusTree.PatchByKey(journalNonce, value)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected volume.journalRecWrapper.bPlusTree.PatchByKey() failure: %v", volume.volumeName, err)
+			}
+			if !ok {
+				_, err = volume.journalRecWrapper.bPlusTree.Put(journalNonce, value)
+				if nil != err {
+					logger.Fatalf("Reply Log for Volume %s hit unexpected volume.journalRecWrapper.bPlusTree.Put() failure: %v", volume.volumeName, err)
+				}
+			}
+		case transactionTrimJournalRecs:
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &trimToNonce, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+
+			err = volume.trimJournalRecsWhileLocked(trimToNonce)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected volume.trimJournalRecsWhileLocked() failure: %v", volume.volumeName, err)
+			}
+		case transactionPutFingerprintRec:
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &fingerprint, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+			replayLogReadBufferPosition += globals.uint64Size
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &valueLen, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+			replayLogReadBufferPosition += globals.uint64Size
+			value = make([]byte, valueLen)
+			copy(value, replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+valueLen])
+
+			ok, err = volume.fingerprintRecWrapper.bPlusTree.PatchByKey(fingerprint, value)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected volume.fingerprintRecWrapper.bPlusTree.PatchByKey() failure: %v", volume.volumeName, err)
+			}
+			if !ok {
+				_, err = volume.fingerprintRecWrapper.bPlusTree.Put(fingerprint, value)
+				if nil != err {
+					logger.Fatalf("Reply Log for Volume %s hit unexpected volume.fingerprintRecWrapper.bPlusTree.Put() failure: %v", volume.volumeName, err)
+				}
+			}
+		case transactionDeleteFingerprintRec:
+			_, err = cstruct.Unpack(replayLogReadBuffer[replayLogReadBufferPosition:replayLogReadBufferPosition+globals.uint64Size], &fingerprint, LittleEndian)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected cstruct.Unpack() failure: %v", volume.volumeName, err)
+			}
+
+			_, err = volume.fingerprintRecWrapper.bPlusTree.DeleteByKey(fingerprint)
+			if nil != err {
+				logger.Fatalf("Reply Log for Volume %s hit unexpected volume.fingerprintRecWrapper.bPlusTree.DeleteByKey() failure: %v", volume.volumeName, err)
+			}
 		default:
 			// Corruption in replayLogTransactionFixedPart - so exit as if Replay Log ended here
 
@@ -1008,6 +1202,162 @@ func (volume *volumeStruct) getCheckpoint(autoFormat bool) (err error) {
 	return
 }
 
+// fetchCheckpointObjectTrailer reads in the checkpointObjectTrailerV{2|3}Struct (and the B+Tree "layouts" that follow it)
+// found at the tail of the specified object... a checkpointObjectTrailerV2Struct is returned as the equivalent
+// checkpointObjectTrailerV3Struct (and an empty journalRecBPlusTreeLayout and fingerprintRecBPlusTreeLayout)
+func (volume *volumeStruct) fetchCheckpointObjectTrailer(checkpointVersion uint64, objectNumber uint64, objectLength uint64) (checkpointObjectTrailer *checkpointObjectTrailerV3Struct, inodeRecBPlusTreeLayout sortedmap.LayoutReport, logSegmentRecBPlusTreeLayout sortedmap.LayoutReport, bPlusTreeObjectBPlusTreeLayout sortedmap.LayoutReport, journalRecBPlusTreeLayout sortedmap.LayoutReport, fingerprintRecBPlusTreeLayout sortedmap.LayoutReport, err error) {
+	var (
+		bytesConsumed                       uint64
+		checkpointObjectTrailerBuf          []byte
+		checkpointObjectTrailerV2           checkpointObjectTrailerV2Struct
+		elementOfBPlusTreeLayout            elementOfBPlusTreeLayoutStruct
+		expectedCheckpointObjectTrailerSize uint64
+		layoutReportIndex                   uint64
+	)
+
+	checkpointObjectTrailerBuf, err =
+		swiftclient.ObjectTail(
+			volume.accountName,
+			volume.checkpointContainerName,
+			utils.Uint64ToHexStr(objectNumber),
+			objectLength)
+	if nil != err {
+		return
+	}
+
+	checkpointObjectTrailer = &checkpointObjectTrailerV3Struct{}
+
+	switch checkpointVersion {
+	case checkpointHeaderVersion2:
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &checkpointObjectTrailerV2, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber = checkpointObjectTrailerV2.InodeRecBPlusTreeObjectNumber
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset = checkpointObjectTrailerV2.InodeRecBPlusTreeObjectOffset
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectLength = checkpointObjectTrailerV2.InodeRecBPlusTreeObjectLength
+		checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements = checkpointObjectTrailerV2.InodeRecBPlusTreeLayoutNumElements
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber = checkpointObjectTrailerV2.LogSegmentRecBPlusTreeObjectNumber
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset = checkpointObjectTrailerV2.LogSegmentRecBPlusTreeObjectOffset
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength = checkpointObjectTrailerV2.LogSegmentRecBPlusTreeObjectLength
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements = checkpointObjectTrailerV2.LogSegmentRecBPlusTreeLayoutNumElements
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber = checkpointObjectTrailerV2.BPlusTreeObjectBPlusTreeObjectNumber
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset = checkpointObjectTrailerV2.BPlusTreeObjectBPlusTreeObjectOffset
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength = checkpointObjectTrailerV2.BPlusTreeObjectBPlusTreeObjectLength
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements = checkpointObjectTrailerV2.BPlusTreeObjectBPlusTreeLayoutNumElements
+	case checkpointHeaderVersion3:
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, checkpointObjectTrailer, LittleEndian)
+		if nil != err {
+			return
+		}
+	default:
+		err = fmt.Errorf("checkpointObjectTrailer in object 0x%016X for volume %v has unsupported version %v", objectNumber, volume.volumeName, checkpointVersion)
+		return
+	}
+
+	// Deserialize {inodeRec|logSegmentRec|bPlusTreeObject|journalRec|fingerprintRec}BPlusTreeLayout LayoutReports
+
+	expectedCheckpointObjectTrailerSize = checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements
+	expectedCheckpointObjectTrailerSize += checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements
+	expectedCheckpointObjectTrailerSize += checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements
+	expectedCheckpointObjectTrailerSize += checkpointObjectTrailer.JournalRecBPlusTreeLayoutNumElements
+	expectedCheckpointObjectTrailerSize += checkpointObjectTrailer.FingerprintRecBPlusTreeLayoutNumElements
+	expectedCheckpointObjectTrailerSize *= globals.elementOfBPlusTreeLayoutStructSize
+	expectedCheckpointObjectTrailerSize += bytesConsumed
+
+	if uint64(len(checkpointObjectTrailerBuf)) != expectedCheckpointObjectTrailerSize {
+		err = fmt.Errorf("checkpointObjectTrailer in object 0x%016X for volume %v does not match required size", objectNumber, volume.volumeName)
+		return
+	}
+
+	inodeRecBPlusTreeLayout = make(sortedmap.LayoutReport)
+	logSegmentRecBPlusTreeLayout = make(sortedmap.LayoutReport)
+	bPlusTreeObjectBPlusTreeLayout = make(sortedmap.LayoutReport)
+	journalRecBPlusTreeLayout = make(sortedmap.LayoutReport)
+	fingerprintRecBPlusTreeLayout = make(sortedmap.LayoutReport)
+
+	for layoutReportIndex = 0; layoutReportIndex < checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		inodeRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	for layoutReportIndex = 0; layoutReportIndex < checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		logSegmentRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	for layoutReportIndex = 0; layoutReportIndex < checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		bPlusTreeObjectBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	for layoutReportIndex = 0; layoutReportIndex < checkpointObjectTrailer.JournalRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		journalRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	for layoutReportIndex = 0; layoutReportIndex < checkpointObjectTrailer.FingerprintRecBPlusTreeLayoutNumElements; layoutReportIndex++ {
+		checkpointObjectTrailerBuf = checkpointObjectTrailerBuf[bytesConsumed:]
+		bytesConsumed, err = cstruct.Unpack(checkpointObjectTrailerBuf, &elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+
+		fingerprintRecBPlusTreeLayout[elementOfBPlusTreeLayout.ObjectNumber] = elementOfBPlusTreeLayout.ObjectBytes
+	}
+
+	err = nil
+	return
+}
+
+// loadBPlusTree either creates an empty B+Tree (if rootObjectNumber == 0) or loads the B+Tree rooted where specified
+func (bPlusTreeWrapper *bPlusTreeWrapperStruct) loadBPlusTree(rootObjectNumber uint64, rootObjectOffset uint64, rootObjectLength uint64, maxKeysPerNode uint64, bPlusTreeCache sortedmap.BPlusTreeCache) (err error) {
+	if 0 == rootObjectNumber {
+		bPlusTreeWrapper.bPlusTree =
+			sortedmap.NewBPlusTree(
+				maxKeysPerNode,
+				sortedmap.CompareUint64,
+				bPlusTreeWrapper,
+				bPlusTreeCache)
+	} else {
+		bPlusTreeWrapper.bPlusTree, err =
+			sortedmap.OldBPlusTree(
+				rootObjectNumber,
+				rootObjectOffset,
+				rootObjectLength,
+				sortedmap.CompareUint64,
+				bPlusTreeWrapper,
+				bPlusTreeCache)
+		if nil != err {
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
 func (volume *volumeStruct) putCheckpoint() (err error) {
 	var (
 		bytesUsedCumulative                    uint64
@@ -1017,6 +1367,8 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		checkpointHeaderValues                 []string
 		checkpointObjectTrailerBeginningOffset uint64
 		checkpointObjectTrailerEndingOffset    uint64
+		checkpointObjectTrailerObjectLength    uint64
+		checkpointObjectTrailerObjectNumber    uint64
 		checkpointTrailerBuf                   []byte
 		combinedBPlusTreeLayout                sortedmap.LayoutReport
 		elementOfBPlusTreeLayout               elementOfBPlusTreeLayoutStruct
@@ -1027,7 +1379,7 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		treeLayoutBufSize                      uint64
 	)
 
-	volume.checkpointFlushedData = false
+	volume.checkpointFlushedData = volume.checkpointHeaderNeeded // following a failed checkpoint, its Checkpoint Header may be missing
 
 	volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
 		volume.checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
@@ -1050,6 +1402,20 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	if nil != err {
 		return
 	}
+	volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectNumber,
+		volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectOffset,
+		volume.checkpointObjectTrailer.JournalRecBPlusTreeObjectLength,
+		err = volume.journalRecWrapper.bPlusTree.Flush(false)
+	if nil != err {
+		return
+	}
+	volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectNumber,
+		volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectOffset,
+		volume.checkpointObjectTrailer.FingerprintRecBPlusTreeObjectLength,
+		err = volume.fingerprintRecWrapper.bPlusTree.Flush(false)
+	if nil != err {
+		return
+	}
 
 	if !volume.checkpointFlushedData {
 		return // since nothing was flushed, we can simply return
@@ -1067,10 +1433,20 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	if nil != err {
 		return
 	}
+	err = volume.journalRecWrapper.bPlusTree.Prune()
+	if nil != err {
+		return
+	}
+	err = volume.fingerprintRecWrapper.bPlusTree.Prune()
+	if nil != err {
+		return
+	}
 
 	volume.checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements = uint64(len(volume.inodeRecBPlusTreeLayout))
 	volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements = uint64(len(volume.logSegmentRecBPlusTreeLayout))
 	volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements = uint64(len(volume.bPlusTreeObjectBPlusTreeLayout))
+	volume.checkpointObjectTrailer.JournalRecBPlusTreeLayoutNumElements = uint64(len(volume.journalRecBPlusTreeLayout))
+	volume.checkpointObjectTrailer.FingerprintRecBPlusTreeLayoutNumElements = uint64(len(volume.fingerprintRecBPlusTreeLayout))
 
 	checkpointTrailerBuf, err = cstruct.Pack(volume.checkpointObjectTrailer, LittleEndian)
 	if nil != err {
@@ -1080,6 +1456,8 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 	treeLayoutBufSize = volume.checkpointObjectTrailer.InodeRecBPlusTreeLayoutNumElements
 	treeLayoutBufSize += volume.checkpointObjectTrailer.LogSegmentRecBPlusTreeLayoutNumElements
 	treeLayoutBufSize += volume.checkpointObjectTrailer.BPlusTreeObjectBPlusTreeLayoutNumElements
+	treeLayoutBufSize += volume.checkpointObjectTrailer.JournalRecBPlusTreeLayoutNumElements
+	treeLayoutBufSize += volume.checkpointObjectTrailer.FingerprintRecBPlusTreeLayoutNumElements
 	treeLayoutBufSize *= globals.elementOfBPlusTreeLayoutStructSize
 
 	treeLayoutBuf = make([]byte, 0, treeLayoutBufSize)
@@ -1108,6 +1486,22 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		treeLayoutBuf = append(treeLayoutBuf, elementOfBPlusTreeLayoutBuf...)
 	}
 
+	for elementOfBPlusTreeLayout.ObjectNumber, elementOfBPlusTreeLayout.ObjectBytes = range volume.journalRecBPlusTreeLayout {
+		elementOfBPlusTreeLayoutBuf, err = cstruct.Pack(&elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+		treeLayoutBuf = append(treeLayoutBuf, elementOfBPlusTreeLayoutBuf...)
+	}
+
+	for elementOfBPlusTreeLayout.ObjectNumber, elementOfBPlusTreeLayout.ObjectBytes = range volume.fingerprintRecBPlusTreeLayout {
+		elementOfBPlusTreeLayoutBuf, err = cstruct.Pack(&elementOfBPlusTreeLayout, LittleEndian)
+		if nil != err {
+			return
+		}
+		treeLayoutBuf = append(treeLayoutBuf, elementOfBPlusTreeLayoutBuf...)
+	}
+
 	err = volume.openCheckpointChunkedPutContextIfNecessary()
 	if nil != err {
 		return
@@ -1138,13 +1532,16 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		return
 	}
 
-	volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber = volume.checkpointChunkedPutContextObjectNumber
-	volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength = checkpointObjectTrailerEndingOffset - checkpointObjectTrailerBeginningOffset
+	// Only update volume.checkpointHeader once the new Checkpoint Header has been recorded in Swift
+	// (lest e.g. a subsequent nonce reservation record a reference to a failed checkpoint)
+
+	checkpointObjectTrailerObjectNumber = volume.checkpointChunkedPutContextObjectNumber
+	checkpointObjectTrailerObjectLength = checkpointObjectTrailerEndingOffset - checkpointObjectTrailerBeginningOffset
 
 	checkpointHeaderValue = fmt.Sprintf("%016X %016X %016X %016X",
-		checkpointHeaderVersion2,
-		volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
-		volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength,
+		checkpointHeaderVersion3,
+		checkpointObjectTrailerObjectNumber,
+		checkpointObjectTrailerObjectLength,
 		volume.checkpointHeader.ReservedToNonce,
 	)
 
@@ -1159,7 +1556,12 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 		return
 	}
 
-	volume.checkpointHeaderVersion = checkpointHeaderVersion2
+	volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber = checkpointObjectTrailerObjectNumber
+	volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength = checkpointObjectTrailerObjectLength
+
+	volume.checkpointHeaderVersion = checkpointHeaderVersion3
+
+	volume.invalidateSnapshotExclusiveBytesWhileLocked()
 
 	if nil != volume.replayLogFile {
 		err = volume.replayLogFile.Close()
@@ -1213,9 +1615,31 @@ func (volume *volumeStruct) putCheckpoint() (err error) {
 			delete(volume.bPlusTreeObjectBPlusTreeLayout, objectNumber)
 		}
 	}
+	for objectNumber, bytesUsedThisBPlusTree = range volume.journalRecBPlusTreeLayout {
+		bytesUsedCumulative, ok = combinedBPlusTreeLayout[objectNumber]
+		if ok {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedCumulative + bytesUsedThisBPlusTree
+		} else {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedThisBPlusTree
+		}
+		if bytesUsedThisBPlusTree == 0 {
+			delete(volume.journalRecBPlusTreeLayout, objectNumber)
+		}
+	}
+	for objectNumber, bytesUsedThisBPlusTree = range volume.fingerprintRecBPlusTreeLayout {
+		bytesUsedCumulative, ok = combinedBPlusTreeLayout[objectNumber]
+		if ok {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedCumulative + bytesUsedThisBPlusTree
+		} else {
+			combinedBPlusTreeLayout[objectNumber] = bytesUsedThisBPlusTree
+		}
+		if bytesUsedThisBPlusTree == 0 {
+			delete(volume.fingerprintRecBPlusTreeLayout, objectNumber)
+		}
+	}
 
 	for objectNumber, bytesUsedCumulative = range combinedBPlusTreeLayout {
-		if 0 == bytesUsedCumulative {
+		if (0 == bytesUsedCumulative) && !volume.snapshotsPinObjectWhileLocked(objectNumber, nil) {
 			swiftclient.ObjectDeleteAsync(
 				volume.accountName,
 				volume.checkpointContainerName,
@@ -1276,8 +1700,7 @@ func (volume *volumeStruct) closeCheckpointChunkedPutContextIfNecessary() (err e
 		bytesPut, err = volume.checkpointChunkedPutContext.BytesPut()
 		if nil == err {
 			if bytesPut >= volume.maxFlushSize {
-				err = volume.checkpointChunkedPutContext.Close()
-				volume.checkpointChunkedPutContext = nil
+				err = volume.closeCheckpointChunkedPutContextWhileLocked()
 			}
 		}
 	}
@@ -1288,8 +1711,7 @@ func (volume *volumeStruct) closeCheckpointChunkedPutContext() (err error) {
 	if nil == volume.checkpointChunkedPutContext {
 		err = fmt.Errorf("closeCheckpointChunkedPutContext() called while volume.checkpointChunkedPutContext == nil")
 	} else {
-		err = volume.checkpointChunkedPutContext.Close()
-		volume.checkpointChunkedPutContext = nil
+		err = volume.closeCheckpointChunkedPutContextWhileLocked()
 	}
 	return // err set as appropriate regardless of path
 }
@@ -1299,14 +1721,19 @@ func (volume *volumeStruct) checkpointDaemon() {
 	var (
 		checkpointRequest *checkpointRequestStruct
 		exitOnCompletion  bool
+		waitDuration      time.Duration
 	)
 
 	for {
+		volume.Lock()
+		waitDuration = volume.checkpointWaitDurationWhileLocked()
+		volume.Unlock()
+
 		select {
 		case checkpointRequest = <-volume.checkpointRequestChan:
 			// Explicitly requested checkpoint... use it below
-		case <-time.After(volume.checkpointInterval):
-			// Time to automatically do a checkpoint... so dummy up a checkpointRequest
+		case <-time.After(waitDuration):
+			// Time to automatically do (or retry) a checkpoint... so dummy up a checkpointRequest
 			checkpointRequest = &checkpointRequestStruct{exitOnCompletion: false}
 			checkpointRequest.waitGroup.Add(1) // ...even though we won't be waiting on it...
 		}
@@ -1315,40 +1742,47 @@ func (volume *volumeStruct) checkpointDaemon() {
 
 		evtlog.Record(evtlog.FormatHeadhunterCheckpointStart, volume.volumeName)
 
-		checkpointRequest.err = volume.putCheckpoint()
+		// As part of conducting a failed checkpoint - and depending upon where the early non-nil
+		// error was reported - it is highly likely that e.g. pages of the B+Trees were marked
+		// clean even though either their dirty data was not successfully posted to Swift and/or
+		// the Checkpoint Header that points to it was not successfully recorded in Swift. Hence,
+		// following such a failure, the checkpoint object that could not be PUT is replayed
+		// prior to retrying.
+
+		checkpointRequest.err = volume.replayFailedCheckpointObjectIfNecessaryWhileLocked()
+		if nil == checkpointRequest.err {
+			checkpointRequest.err = volume.putCheckpoint()
+		}
 
 		if nil == checkpointRequest.err {
 			evtlog.Record(evtlog.FormatHeadhunterCheckpointEndSuccess, volume.volumeName)
+			volume.recordCheckpointSuccessWhileLocked()
+
+			if "" != checkpointRequest.createSnapshotName {
+				// Capturing the snapshot while still holding volume.Lock() ensures that nothing it
+				// references is garbage collected before it is pinned
+
+				checkpointRequest.snapshot, checkpointRequest.err = volume.createSnapshotWhileLocked(checkpointRequest.createSnapshotName)
+			}
+
+			volume.releaseCloneSourceIfUnreferencedWhileLocked()
+
+			if nil != volume.checkpointDoneWaitGroup {
+				// Awake any others who were waiting on this checkpoint
+				volume.checkpointDoneWaitGroup.Done()
+				volume.checkpointDoneWaitGroup = nil
+			}
 		} else {
-			// As part of conducting the checkpoint - and depending upon where the early non-nil
-			// error was reported - it is highly likely that e.g. pages of the B+Trees have been
-			// marked clean even though either their dirty data has not been successfully posted
-			// to Swift and/or the Checkpoint Header that points to it has not been successfully
-			// recorded in Swift. In either case, a subsequent checkpoint may, indeed, appear to
-			// succeed and quite probably miss some of the references nodes of the B+Trees not
-			// having made it to Swift... and, yet, wrongly presume all is (now) well.
-
-			// It should also be noted that other activity (e.g. garbage collection of usually
-			// now unreferenced data) awaiting completion of this checkpoint should not have
-			// been allowed to proceed.
-
-			// For now, we will instead promptly fail right here thus preventing that subsequent
-			// checkpoint from masking the data loss. While there are alternatives (e.g. going
-			// back and marking every node of the B+Trees as being dirty - or at least those that
-			// were marked clean), such an approach will not be pursued at this time.
+			// Other activity (e.g. garbage collection of usually now unreferenced data) awaiting
+			// completion of a checkpoint must not be allowed to proceed until one succeeds
 
 			evtlog.Record(evtlog.FormatHeadhunterCheckpointEndFailure, volume.volumeName, checkpointRequest.err.Error())
-			logger.FatalfWithError(checkpointRequest.err, "Shutting down to prevent subsequent checkpoints from corrupting Swift")
+			volume.recordCheckpointFailureWhileLocked(checkpointRequest.err)
 		}
 
 		exitOnCompletion = checkpointRequest.exitOnCompletion // In case requestor re-uses checkpointRequest
 
 		checkpointRequest.waitGroup.Done() // Awake the checkpoint requestor
-		if nil != volume.checkpointDoneWaitGroup {
-			// Awake any others who were waiting on this checkpoint
-			volume.checkpointDoneWaitGroup.Done()
-			volume.checkpointDoneWaitGroup = nil
-		}
 
 		volume.Unlock()
 
@@ -1395,6 +1829,16 @@ func (volume *volumeStruct) FetchLayoutReport(treeType BPlusTreeType) (layoutRep
 		treeWrapper = volume.bPlusTreeObjectWrapper
 		treeLayoutReport = volume.bPlusTreeObjectBPlusTreeLayout
 
+	case JournalRecBPlusTree:
+		treeName = "JournalRec"
+		treeWrapper = volume.journalRecWrapper
+		treeLayoutReport = volume.journalRecBPlusTreeLayout
+
+	case FingerprintRecBPlusTree:
+		treeName = "FingerprintRec"
+		treeWrapper = volume.fingerprintRecWrapper
+		treeLayoutReport = volume.fingerprintRecBPlusTreeLayout
+
 	default:
 		err = fmt.Errorf("FetchLayoutReport(treeType %d): bad tree type.", treeType)
 		logger.ErrorfWithError(err, "volume '%s'", volume.volumeName)
@@ -0,0 +1,569 @@
+package headhunter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A clone is a volume whose database starts out as a snapshot of another (the source) volume.
+//
+// FormatClone() copies the Checkpoint Container objects of the source snapshot into the clone's Checkpoint
+// Container such that the clone's B+Trees start out identical to those of the source snapshot. The LogSegments
+// referenced by the source snapshot are not copied... the clone instead reads them from the source volume's
+// Account. The clone's nonces start at the source volume's ReservedToNonce at the time of the clone (the
+// clone's "base nonce") so any logSegmentNumber below the base nonce refers to a shared LogSegment. The clone
+// never deletes a shared LogSegment... it merely removes its reference to it.
+//
+// References to shared LogSegments are counted per source snapshot. Each clone records a header in the
+// source volume's Checkpoint Container whose name is CloneReferenceHeaderNamePrefix followed by a hex
+// encoding of the clone's Account name and whose value is:
+//
+//   uint64 in %016X indicating the ID of the source snapshot
+//   ' '
+//   Account name of the clone
+//
+// A source snapshot may not be deleted while any such header refers to it (thus its LogSegments, including
+// those no longer referenced by the source volume itself, are retained). In turn, the clone records in its
+// own Checkpoint Container a header named CloneSourceHeaderName whose value is:
+//
+//   uint64 in %016X indicating the ID of the source snapshot
+//   ' '
+//   uint64 in %016X indicating the base nonce
+//   ' '
+//   Account name of the source volume
+//   ' '
+//   Checkpoint Container name of the source volume
+//
+// Once neither the clone's logSegmentRec B+Tree nor that of any of its own snapshots references a shared
+// LogSegment, the clone removes both headers (releasing its reference on the source snapshot).
+
+const (
+	CloneReferenceHeaderNamePrefix = "X-Container-Meta-Clone-Reference-"
+	CloneSourceHeaderName          = "X-Container-Meta-Clone-Source"
+)
+
+type cloneObjectCopyStruct struct{}
+
+func (cloneObjectCopy *cloneObjectCopyStruct) BytesRemaining(bytesRemaining uint64) (chunkSize uint64) {
+	chunkSize = bytesRemaining
+	return
+}
+
+func cloneReferenceHeaderName(cloneAccountName string) (headerName string) {
+	headerName = CloneReferenceHeaderNamePrefix + fmt.Sprintf("%X", cloneAccountName)
+	return
+}
+
+// cloneReferencesSnapshot reports whether any of checkpointContainerHeaders is a clone reference to snapshotID
+func cloneReferencesSnapshot(checkpointContainerHeaders map[string][]string, snapshotID uint64) (referenced bool, err error) {
+	var (
+		headerName       string
+		headerValueSlice []string
+		headerValues     []string
+		referencedID     uint64
+	)
+
+	for headerName, headerValues = range checkpointContainerHeaders {
+		if (len(headerName) <= len(CloneReferenceHeaderNamePrefix)) || !strings.EqualFold(headerName[:len(CloneReferenceHeaderNamePrefix)], CloneReferenceHeaderNamePrefix) {
+			continue
+		}
+		if (1 != len(headerValues)) || ("" == headerValues[0]) {
+			continue
+		}
+
+		headerValueSlice = strings.SplitN(headerValues[0], " ", 2)
+
+		referencedID, err = strconv.ParseUint(headerValueSlice[0], 16, 64)
+		if nil != err {
+			err = fmt.Errorf("Cannot parse header %v: %v (bad snapshot ID)", headerName, headerValues[0])
+			return
+		}
+
+		if referencedID == snapshotID {
+			referenced = true
+			err = nil
+			return
+		}
+	}
+
+	referenced = false
+	err = nil
+	return
+}
+
+// findSnapshot returns the (not yet loaded) snapshot named snapshotName recorded in the Checkpoint Container
+// (with headers checkpointContainerHeaders)
+func (volume *volumeStruct) findSnapshot(checkpointContainerHeaders map[string][]string, snapshotName string) (snapshot *snapshotStruct, err error) {
+	var (
+		snapshotList []*snapshotStruct
+	)
+
+	snapshotList, _, err = volume.fetchSnapshots(checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	for _, snapshot = range snapshotList {
+		if snapshot.name == snapshotName {
+			return
+		}
+	}
+
+	err = fmt.Errorf("snapshot \"%v\" of volume \"%v\" not found", snapshotName, volume.volumeName)
+	err = blunder.AddError(err, blunder.NotFoundError)
+	return
+}
+
+// loadCloneSource is called by getCheckpoint() to note if the volume (still) shares LogSegments with a source volume
+func (volume *volumeStruct) loadCloneSource(checkpointContainerHeaders map[string][]string) (err error) {
+	var (
+		headerValueSlice []string
+		headerValues     []string
+		ok               bool
+	)
+
+	volume.cloneSourceSnapshotID = 0
+	volume.cloneBaseNonce = 0
+	volume.cloneSourceAccountName = ""
+	volume.cloneSourceCheckpointContainerName = ""
+
+	headerValues, ok = checkpointContainerHeaders[CloneSourceHeaderName]
+	if !ok || (0 == len(headerValues)) || ("" == headerValues[0]) {
+		err = nil
+		return
+	}
+
+	if 1 != len(headerValues) {
+		err = fmt.Errorf("Expected one single value for %v/%v header %v", volume.accountName, volume.checkpointContainerName, CloneSourceHeaderName)
+		return
+	}
+
+	headerValueSlice = strings.Split(headerValues[0], " ")
+	if 4 != len(headerValueSlice) {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (wrong number of fields)", volume.accountName, volume.checkpointContainerName, CloneSourceHeaderName, headerValues[0])
+		return
+	}
+
+	volume.cloneSourceSnapshotID, err = strconv.ParseUint(headerValueSlice[0], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (bad snapshot ID)", volume.accountName, volume.checkpointContainerName, CloneSourceHeaderName, headerValues[0])
+		return
+	}
+
+	volume.cloneBaseNonce, err = strconv.ParseUint(headerValueSlice[1], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (bad base nonce)", volume.accountName, volume.checkpointContainerName, CloneSourceHeaderName, headerValues[0])
+		return
+	}
+
+	volume.cloneSourceAccountName = headerValueSlice[2]
+	volume.cloneSourceCheckpointContainerName = headerValueSlice[3]
+
+	err = nil
+	return
+}
+
+// bPlusTreeReferencesSharedLogSegment reports whether the logSegmentRec B+Tree references any logSegmentNumber below the base nonce
+func (volume *volumeStruct) bPlusTreeReferencesSharedLogSegment(logSegmentRecBPlusTree sortedmap.BPlusTree) (referenced bool, err error) {
+	var (
+		logSegmentNumberAsKey sortedmap.Key
+		ok                    bool
+	)
+
+	// The B+Tree is sorted by logSegmentNumber... so only its first key need be examined
+
+	logSegmentNumberAsKey, _, ok, err = logSegmentRecBPlusTree.GetByIndex(0)
+	if nil != err {
+		return
+	}
+
+	referenced = ok && (logSegmentNumberAsKey.(uint64) < volume.cloneBaseNonce)
+
+	return
+}
+
+// releaseCloneSourceIfUnreferencedWhileLocked is called by checkpointDaemon() following a successful putCheckpoint()
+// to release the volume's reference on its clone source snapshot once no longer referencing any shared LogSegments
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) releaseCloneSourceIfUnreferencedWhileLocked() {
+	var (
+		checkpointContainerHeaders map[string][]string
+		err                        error
+		referenced                 bool
+		snapshot                   *snapshotStruct
+	)
+
+	if 0 == volume.cloneBaseNonce {
+		return
+	}
+
+	referenced, err = volume.bPlusTreeReferencesSharedLogSegment(volume.logSegmentRecWrapper.bPlusTree)
+	if (nil != err) || referenced {
+		return
+	}
+
+	for _, snapshot = range volume.snapshotMap {
+		referenced, err = volume.bPlusTreeReferencesSharedLogSegment(snapshot.logSegmentRecWrapper.bPlusTree)
+		if (nil != err) || referenced {
+			return
+		}
+	}
+
+	// Posting an empty value removes the header
+
+	checkpointContainerHeaders = make(map[string][]string)
+	checkpointContainerHeaders[cloneReferenceHeaderName(volume.accountName)] = []string{""}
+
+	err = swiftclient.ContainerPost(volume.cloneSourceAccountName, volume.cloneSourceCheckpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		logger.ErrorfWithError(err, "Unable to release clone reference of volume \"%v\" on %v/%v", volume.volumeName, volume.cloneSourceAccountName, volume.cloneSourceCheckpointContainerName)
+		return
+	}
+
+	checkpointContainerHeaders = make(map[string][]string)
+	checkpointContainerHeaders[CloneSourceHeaderName] = []string{""}
+
+	err = swiftclient.ContainerPost(volume.accountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		logger.ErrorfWithError(err, "Unable to remove %v/%v header %v", volume.accountName, volume.checkpointContainerName, CloneSourceHeaderName)
+		return
+	}
+
+	logger.Infof("Volume \"%v\" no longer shares LogSegments with %v (released clone reference)", volume.volumeName, volume.cloneSourceAccountName)
+
+	volume.cloneSourceSnapshotID = 0
+	volume.cloneBaseNonce = 0
+	volume.cloneSourceAccountName = ""
+	volume.cloneSourceCheckpointContainerName = ""
+}
+
+// logSegmentIsSharedWhileLocked reports whether logSegmentNumber refers to a LogSegment of the clone source volume
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) logSegmentIsSharedWhileLocked(logSegmentNumber uint64) (shared bool) {
+	shared = logSegmentNumber < volume.cloneBaseNonce
+	return
+}
+
+func (volume *volumeStruct) FetchLogSegmentAccountName(logSegmentNumber uint64) (accountName string) {
+	volume.Lock()
+	if volume.logSegmentIsSharedWhileLocked(logSegmentNumber) {
+		accountName = volume.cloneSourceAccountName
+	} else {
+		accountName = volume.accountName
+	}
+	volume.Unlock()
+	return
+}
+
+func (snapshot *snapshotStruct) FetchLogSegmentAccountName(logSegmentNumber uint64) (accountName string) {
+	accountName = snapshot.volume.FetchLogSegmentAccountName(logSegmentNumber)
+	return
+}
+
+func fetchCloneVolumeParticulars(confMap conf.ConfMap, volumeName string) (volume *volumeStruct, err error) {
+	var (
+		volumeSectionName string
+	)
+
+	volumeSectionName = utils.VolumeNameConfSection(volumeName)
+
+	volume = &volumeStruct{volumeName: volumeName}
+
+	volume.accountName, err = confMap.FetchOptionValueString(volumeSectionName, "AccountName")
+	if nil != err {
+		return
+	}
+
+	volume.checkpointContainerName, err = confMap.FetchOptionValueString(volumeSectionName, "CheckpointContainerName")
+	if nil != err {
+		return
+	}
+
+	volume.checkpointContainerStoragePolicy, err = confMap.FetchOptionValueString(volumeSectionName, "CheckpointContainerStoragePolicy")
+
+	return
+}
+
+// FormatClone formats volumeName as a (writable) clone of the snapshot named snapshotName of sourceVolumeName
+func FormatClone(confMap conf.ConfMap, volumeName string, sourceVolumeName string, snapshotName string) (err error) {
+	var (
+		accountHeaders                 map[string][]string
+		bPlusTreeObjectBPlusTreeLayout sortedmap.LayoutReport
+		checkpointContainerHeaders     map[string][]string
+		checkpointHeaderValueSlice     []string
+		checkpointHeaderValues         []string
+		checkpointVersion              uint64
+		clone                          *volumeStruct
+		cloneBaseNonce                 uint64
+		fingerprintRecBPlusTreeLayout  sortedmap.LayoutReport
+		inodeRecBPlusTreeLayout        sortedmap.LayoutReport
+		journalRecBPlusTreeLayout      sortedmap.LayoutReport
+		layout                         sortedmap.LayoutReport
+		logSegmentRecBPlusTreeLayout   sortedmap.LayoutReport
+		objectBytes                    uint64
+		objectNumber                   uint64
+		objectSet                      map[uint64]struct{}
+		ok                             bool
+		referenceAdded                 bool
+		snapshot                       *snapshotStruct
+		source                         *volumeStruct
+		sourceHeaders                  map[string][]string
+	)
+
+	err = examineCStructs()
+	if nil != err {
+		return
+	}
+
+	clone, err = fetchCloneVolumeParticulars(confMap, volumeName)
+	if nil != err {
+		return
+	}
+
+	source, err = fetchCloneVolumeParticulars(confMap, sourceVolumeName)
+	if nil != err {
+		return
+	}
+
+	if clone.accountName == source.accountName {
+		err = fmt.Errorf("clone volume \"%v\" must not share Account %v with source volume \"%v\"", volumeName, clone.accountName, sourceVolumeName)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	_, err = swiftclient.ContainerHead(clone.accountName, clone.checkpointContainerName)
+	if nil == err {
+		err = fmt.Errorf("clone volume \"%v\" already formatted", volumeName)
+		err = blunder.AddError(err, blunder.FileExistsError)
+		return
+	}
+	if 404 != blunder.HTTPCode(err) {
+		return
+	}
+
+	// Locate the source snapshot & the source volume's ReservedToNonce (our base nonce)
+
+	sourceHeaders, err = swiftclient.ContainerHead(source.accountName, source.checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	checkpointHeaderValues, ok = sourceHeaders[CloneSourceHeaderName]
+	if ok && (1 == len(checkpointHeaderValues)) && ("" != checkpointHeaderValues[0]) {
+		err = fmt.Errorf("source volume \"%v\" is itself a clone still sharing LogSegments with its source", sourceVolumeName)
+		err = blunder.AddError(err, blunder.NotSupportedError)
+		return
+	}
+
+	checkpointHeaderValues, ok = sourceHeaders[CheckpointHeaderName]
+	if !ok || (1 != len(checkpointHeaderValues)) {
+		err = fmt.Errorf("Missing %v/%v header %v", source.accountName, source.checkpointContainerName, CheckpointHeaderName)
+		return
+	}
+
+	checkpointHeaderValueSlice = strings.Split(checkpointHeaderValues[0], " ")
+	if 4 != len(checkpointHeaderValueSlice) {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v", source.accountName, source.checkpointContainerName, CheckpointHeaderName, checkpointHeaderValues[0])
+		return
+	}
+
+	checkpointVersion, err = strconv.ParseUint(checkpointHeaderValueSlice[0], 16, 64)
+	if (nil != err) || ((checkpointHeaderVersion2 != checkpointVersion) && (checkpointHeaderVersion3 != checkpointVersion)) {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (version not supported)", source.accountName, source.checkpointContainerName, CheckpointHeaderName, checkpointHeaderValues[0])
+		return
+	}
+
+	cloneBaseNonce, err = strconv.ParseUint(checkpointHeaderValueSlice[3], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v header %v: %v (bad nextNonce)", source.accountName, source.checkpointContainerName, CheckpointHeaderName, checkpointHeaderValues[0])
+		return
+	}
+
+	snapshot, err = source.findSnapshot(sourceHeaders, snapshotName)
+	if nil != err {
+		return
+	}
+
+	// Add our reference to the source snapshot... then ensure it was not concurrently deleted
+	//
+	// Note that DeleteSnapshot() first removes the snapshot from the snapshot index and then checks for
+	// references (restoring it if any are found)... so one of us is guaranteed to see the other
+
+	checkpointContainerHeaders = make(map[string][]string)
+	checkpointContainerHeaders[cloneReferenceHeaderName(clone.accountName)] = []string{fmt.Sprintf("%016X %s", snapshot.id, clone.accountName)}
+
+	err = swiftclient.ContainerPost(source.accountName, source.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	referenceAdded = true
+
+	defer func() {
+		if (nil != err) && referenceAdded {
+			checkpointContainerHeaders = make(map[string][]string)
+			checkpointContainerHeaders[cloneReferenceHeaderName(clone.accountName)] = []string{""}
+			_ = swiftclient.ContainerPost(source.accountName, source.checkpointContainerName, checkpointContainerHeaders)
+		}
+	}()
+
+	sourceHeaders, err = swiftclient.ContainerHead(source.accountName, source.checkpointContainerName)
+	if nil != err {
+		return
+	}
+
+	_, err = source.findSnapshot(sourceHeaders, snapshotName)
+	if nil != err {
+		return
+	}
+
+	// Copy the source snapshot's Checkpoint Container objects
+
+	_,
+		inodeRecBPlusTreeLayout,
+		logSegmentRecBPlusTreeLayout,
+		bPlusTreeObjectBPlusTreeLayout,
+		journalRecBPlusTreeLayout,
+		fingerprintRecBPlusTreeLayout,
+		err = source.fetchCheckpointObjectTrailer(
+		snapshot.checkpointHeaderVersion,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength)
+	if nil != err {
+		return
+	}
+
+	objectSet = make(map[uint64]struct{})
+
+	objectSet[snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber] = struct{}{}
+
+	for _, layout = range []sortedmap.LayoutReport{inodeRecBPlusTreeLayout, logSegmentRecBPlusTreeLayout, bPlusTreeObjectBPlusTreeLayout, journalRecBPlusTreeLayout, fingerprintRecBPlusTreeLayout} {
+		for objectNumber, objectBytes = range layout {
+			if 0 < objectBytes {
+				objectSet[objectNumber] = struct{}{}
+			}
+		}
+	}
+
+	checkpointContainerHeaders = make(map[string][]string)
+	checkpointContainerHeaders[StoragePolicyHeaderName] = []string{clone.checkpointContainerStoragePolicy}
+
+	err = swiftclient.ContainerPut(clone.accountName, clone.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	for objectNumber = range objectSet {
+		err = swiftclient.ObjectCopy(
+			source.accountName,
+			source.checkpointContainerName,
+			utils.Uint64ToHexStr(objectNumber),
+			clone.accountName,
+			clone.checkpointContainerName,
+			utils.Uint64ToHexStr(objectNumber),
+			&cloneObjectCopyStruct{})
+		if nil != err {
+			return
+		}
+	}
+
+	// Finally, record the clone's Checkpoint Header (referencing the copied checkpointObjectTrailerV{2|3}Struct)
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	checkpointContainerHeaders[CheckpointHeaderName] = []string{fmt.Sprintf("%016X %016X %016X %016X",
+		snapshot.checkpointHeaderVersion,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength,
+		cloneBaseNonce,
+	)}
+
+	checkpointContainerHeaders[CloneSourceHeaderName] = []string{fmt.Sprintf("%016X %016X %s %s",
+		snapshot.id,
+		cloneBaseNonce,
+		source.accountName,
+		source.checkpointContainerName,
+	)}
+
+	err = swiftclient.ContainerPost(clone.accountName, clone.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	// Mark Account as bi-modal...
+	// Note: pfs_middleware will actually see this header named AccountHeaderNameTranslated
+
+	accountHeaders = make(map[string][]string)
+	accountHeaders[AccountHeaderName] = []string{AccountHeaderValue}
+
+	err = swiftclient.AccountPost(clone.accountName, accountHeaders)
+	if nil != err {
+		return
+	}
+
+	logger.Infof("Formatted volume \"%v\" as a clone of snapshot \"%v\" of volume \"%v\"", volumeName, snapshotName, sourceVolumeName)
+
+	err = nil
+	return
+}
+
+// ReleaseCloneReferences is called prior to reformatting volumeName. It fails if any clone still references a
+// snapshot of volumeName. Otherwise, it releases any reference volumeName still holds on its own clone source.
+func ReleaseCloneReferences(confMap conf.ConfMap, volumeName string) (err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		headerName                 string
+		headerValues               []string
+		volume                     *volumeStruct
+	)
+
+	volume, err = fetchCloneVolumeParticulars(confMap, volumeName)
+	if nil != err {
+		return
+	}
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(volume.accountName, volume.checkpointContainerName)
+	if nil != err {
+		if 404 == blunder.HTTPCode(err) {
+			// Never formatted... so nothing to release
+			err = nil
+		}
+		return
+	}
+
+	for headerName, headerValues = range checkpointContainerHeaders {
+		if (len(headerName) > len(CloneReferenceHeaderNamePrefix)) && strings.EqualFold(headerName[:len(CloneReferenceHeaderNamePrefix)], CloneReferenceHeaderNamePrefix) && (1 == len(headerValues)) && ("" != headerValues[0]) {
+			err = fmt.Errorf("volume \"%v\" is referenced by a clone (%v)", volumeName, headerValues[0])
+			err = blunder.AddError(err, blunder.DevBusyError)
+			return
+		}
+	}
+
+	err = volume.loadCloneSource(checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	if 0 == volume.cloneBaseNonce {
+		err = nil
+		return
+	}
+
+	checkpointContainerHeaders = make(map[string][]string)
+	checkpointContainerHeaders[cloneReferenceHeaderName(volume.accountName)] = []string{""}
+
+	err = swiftclient.ContainerPost(volume.cloneSourceAccountName, volume.cloneSourceCheckpointContainerName, checkpointContainerHeaders)
+
+	return
+}
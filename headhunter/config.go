@@ -31,57 +31,93 @@ const (
 	inodeRecBPlusTreeWrapperType uint32 = iota
 	logSegmentRecBPlusTreeWrapperType
 	bPlusTreeObjectBPlusTreeWrapperType
+	journalRecBPlusTreeWrapperType
+	fingerprintRecBPlusTreeWrapperType
 )
 
 type bPlusTreeWrapperStruct struct {
 	volume      *volumeStruct
-	wrapperType uint32 // Either inodeRecBPlusTreeWrapperType, logSegmentRecBPlusTreeWrapperType, or bPlusTreeObjectBPlusTreeWrapperType
+	wrapperType uint32 // One of inodeRecBPlusTreeWrapperType, logSegmentRecBPlusTreeWrapperType, bPlusTreeObjectBPlusTreeWrapperType, journalRecBPlusTreeWrapperType, or fingerprintRecBPlusTreeWrapperType
+	readOnly    bool   // If true, bPlusTree belongs to a snapshot and must not be modified
 	bPlusTree   sortedmap.BPlusTree
 }
 
 type volumeStruct struct {
 	sync.Mutex
-	volumeName                       string
-	accountName                      string
-	maxFlushSize                     uint64
-	nonceValuesToReserve             uint16
-	maxInodesPerMetadataNode         uint64
-	maxLogSegmentsPerMetadataNode    uint64
-	maxDirFileNodesPerMetadataNode   uint64
-	checkpointContainerName          string
-	checkpointContainerStoragePolicy string
-	checkpointInterval               time.Duration
-	replayLogFileName                string   //      if != "", use replay log to reduce RPO to zero
-	replayLogFile                    *os.File //        opened on first Put or Delete after checkpoint
+	volumeName                        string
+	accountName                       string
+	maxFlushSize                      uint64
+	nonceValuesToReserve              uint16
+	maxInodesPerMetadataNode          uint64
+	maxLogSegmentsPerMetadataNode     uint64
+	maxDirFileNodesPerMetadataNode    uint64
+	maxJournalRecsPerMetadataNode     uint64
+	maxFingerprintRecsPerMetadataNode uint64
+	checkpointContainerName           string
+	checkpointContainerStoragePolicy  string
+	checkpointInterval                time.Duration
+	checkpointRetryDelay              time.Duration // initial delay before retrying a failed checkpoint
+	checkpointRetryMaxDelay           time.Duration // upper bound on checkpointNextRetryDelay
+	checkpointRetryExpBackoff         float64       // factor by which checkpointNextRetryDelay grows upon each failure
+	replayLogFileName                 string        //      if != "", use replay log to reduce RPO to zero
+	replayLogFile                     *os.File      //        opened on first Put or Delete after checkpoint
 	//                                                  closed/deleted on successful checkpoint
-	defaultReplayLogWriteBuffer             []byte // used for O_DIRECT writes to replay log
-	checkpointFlushedData                   bool
-	checkpointChunkedPutContext             swiftclient.ChunkedPutContext
-	checkpointChunkedPutContextObjectNumber uint64 // ultimately copied to CheckpointObjectTrailerV2StructObjectNumber
-	checkpointDoneWaitGroup                 *sync.WaitGroup
-	nextNonce                               uint64
-	checkpointRequestChan                   chan *checkpointRequestStruct
-	checkpointHeaderVersion                 uint64
-	checkpointHeader                        *checkpointHeaderV2Struct
-	checkpointObjectTrailer                 *checkpointObjectTrailerV2Struct
-	inodeRecWrapper                         *bPlusTreeWrapperStruct
-	logSegmentRecWrapper                    *bPlusTreeWrapperStruct
-	bPlusTreeObjectWrapper                  *bPlusTreeWrapperStruct
-	inodeRecBPlusTreeLayout                 sortedmap.LayoutReport
-	logSegmentRecBPlusTreeLayout            sortedmap.LayoutReport
-	bPlusTreeObjectBPlusTreeLayout          sortedmap.LayoutReport
+	defaultReplayLogWriteBuffer                   []byte // used for O_DIRECT writes to replay log
+	checkpointFlushedData                         bool
+	checkpointChunkedPutContext                   swiftclient.ChunkedPutContext
+	checkpointChunkedPutContextObjectNumber       uint64 // ultimately copied to CheckpointObjectTrailerV2StructObjectNumber
+	checkpointDoneWaitGroup                       *sync.WaitGroup
+	checkpointHealth                              CheckpointHealthStruct
+	checkpointRecoveryCond                        *sync.Cond                    // broadcast upon returning to CheckpointHealthy (uses volume.Mutex)
+	checkpointFailedChunkedPutContext             swiftclient.ChunkedPutContext // if non-nil, retains the checkpoint object a failed checkpoint could not PUT
+	checkpointFailedChunkedPutContextObjectNumber uint64                        // object number of checkpointFailedChunkedPutContext
+	checkpointHeaderNeeded                        bool                          // if true, the next checkpoint must record a Checkpoint Header even if no B+Tree nodes are dirty
+	checkpointRedirtyNeeded                       bool                          // if true, every B+Tree node must be re-dirtied as the failed checkpoint object cannot be replayed
+	checkpointNextRetryDelay                      time.Duration                 // delay to apply following the next checkpoint failure
+	nextNonce                                     uint64
+	checkpointRequestChan                         chan *checkpointRequestStruct
+	checkpointHeaderVersion                       uint64
+	checkpointHeader                              *checkpointHeaderV2Struct
+	checkpointObjectTrailer                       *checkpointObjectTrailerV3Struct
+	inodeRecWrapper                               *bPlusTreeWrapperStruct
+	logSegmentRecWrapper                          *bPlusTreeWrapperStruct
+	bPlusTreeObjectWrapper                        *bPlusTreeWrapperStruct
+	journalRecWrapper                             *bPlusTreeWrapperStruct
+	fingerprintRecWrapper                         *bPlusTreeWrapperStruct
+	inodeRecBPlusTreeLayout                       sortedmap.LayoutReport
+	logSegmentRecBPlusTreeLayout                  sortedmap.LayoutReport
+	bPlusTreeObjectBPlusTreeLayout                sortedmap.LayoutReport
+	journalRecBPlusTreeLayout                     sortedmap.LayoutReport
+	fingerprintRecBPlusTreeLayout                 sortedmap.LayoutReport
+	snapshotMap                                   map[string]*snapshotStruct // key == snapshotStruct.name
+	snapshotIndexObjectNumber                     uint64                     // if != 0, object recording the snapshots in snapshotMap
+	cloneSourceSnapshotID                         uint64                     // if cloneBaseNonce != 0
+	cloneBaseNonce                                uint64                     // if != 0, logSegmentNumbers below this are shared with clone source volume
+	cloneSourceAccountName                        string                     // if cloneBaseNonce != 0
+	cloneSourceCheckpointContainerName            string                     // if cloneBaseNonce != 0
+	snapshotExclusiveBytesGeneration              uint64                     // incremented whenever snapshots' exclusive bytes may have changed
+	snapshotExclusiveBytesComputedGeneration      uint64                     // generation as of which snapshots' exclusive bytes were last computed
+	snapshotExclusiveBytesErr                     error                      // if non-nil, reason the last computation failed
+	snapshotExclusiveBytesCond                    *sync.Cond                 // broadcast upon each computation completing (uses volume.Mutex)
+	snapshotExclusiveBytesKickChan                chan struct{}              // requests a computation by snapshotExclusiveBytesDaemon()
+	snapshotExclusiveBytesStopChan                chan struct{}              // closed to stop snapshotExclusiveBytesDaemon()
+	snapshotExclusiveBytesWG                      sync.WaitGroup             // signaled upon snapshotExclusiveBytesDaemon() exiting
+	logSegmentBytesMap                            map[uint64]uint64          // sizes of LogSegments exclusively retained by a snapshot (once fetched)
 }
 
 type globalsStruct struct {
 	crc64ECMATable                          *crc64.Table
 	uint64Size                              uint64
 	checkpointHeaderV2StructSize            uint64
-	checkpointObjectTrailerStructSize       uint64
+	checkpointObjectTrailerV2StructSize     uint64
+	checkpointObjectTrailerV3StructSize     uint64
 	elementOfBPlusTreeLayoutStructSize      uint64
 	replayLogTransactionFixedPartStructSize uint64
 	inodeRecCache                           sortedmap.BPlusTreeCache
 	logSegmentRecCache                      sortedmap.BPlusTreeCache
 	bPlusTreeObjectCache                    sortedmap.BPlusTreeCache
+	journalRecCache                         sortedmap.BPlusTreeCache
+	fingerprintRecCache                     sortedmap.BPlusTreeCache
 	volumeMap                               map[string]*volumeStruct // key == ramVolumeStruct.volumeName
 }
 
@@ -90,48 +126,23 @@ var globals globalsStruct
 // Up starts the headhunter package
 func Up(confMap conf.ConfMap) (err error) {
 	var (
-		bPlusTreeObjectCacheEvictHighLimit       uint64
-		bPlusTreeObjectCacheEvictLowLimit        uint64
-		dummyCheckpointHeaderV2Struct            checkpointHeaderV2Struct
-		dummyCheckpointObjectTrailerV2Struct     checkpointObjectTrailerV2Struct
-		dummyElementOfBPlusTreeLayoutStruct      elementOfBPlusTreeLayoutStruct
-		dummyReplayLogTransactionFixedPartStruct replayLogTransactionFixedPartStruct
-		dummyUint64                              uint64
-		inodeRecCacheEvictHighLimit              uint64
-		inodeRecCacheEvictLowLimit               uint64
-		logSegmentRecCacheEvictHighLimit         uint64
-		logSegmentRecCacheEvictLowLimit          uint64
-		primaryPeerList                          []string
-		volumeName                               string
-		volumeList                               []string
-		whoAmI                                   string
+		bPlusTreeObjectCacheEvictHighLimit uint64
+		bPlusTreeObjectCacheEvictLowLimit  uint64
+		fingerprintRecCacheEvictHighLimit  uint64
+		fingerprintRecCacheEvictLowLimit   uint64
+		inodeRecCacheEvictHighLimit        uint64
+		inodeRecCacheEvictLowLimit         uint64
+		journalRecCacheEvictHighLimit      uint64
+		journalRecCacheEvictLowLimit       uint64
+		logSegmentRecCacheEvictHighLimit   uint64
+		logSegmentRecCacheEvictLowLimit    uint64
+		primaryPeerList                    []string
+		volumeName                         string
+		volumeList                         []string
+		whoAmI                             string
 	)
 
-	// Pre-compute crc64 ECMA Table & useful cstruct sizes
-
-	globals.crc64ECMATable = crc64.MakeTable(crc64.ECMA)
-
-	globals.uint64Size, _, err = cstruct.Examine(dummyUint64)
-	if nil != err {
-		return
-	}
-
-	globals.checkpointHeaderV2StructSize, _, err = cstruct.Examine(dummyCheckpointHeaderV2Struct)
-	if nil != err {
-		return
-	}
-
-	globals.checkpointObjectTrailerStructSize, _, err = cstruct.Examine(dummyCheckpointObjectTrailerV2Struct)
-	if nil != err {
-		return
-	}
-
-	globals.elementOfBPlusTreeLayoutStructSize, _, err = cstruct.Examine(dummyElementOfBPlusTreeLayoutStruct)
-	if nil != err {
-		return
-	}
-
-	globals.replayLogTransactionFixedPartStructSize, _, err = cstruct.Examine(dummyReplayLogTransactionFixedPartStruct)
+	err = examineCStructs()
 	if nil != err {
 		return
 	}
@@ -181,6 +192,32 @@ func Up(confMap conf.ConfMap) (err error) {
 
 	globals.bPlusTreeObjectCache = sortedmap.NewBPlusTreeCache(bPlusTreeObjectCacheEvictLowLimit, bPlusTreeObjectCacheEvictHighLimit)
 
+	// The journalRec B+Tree is only ever appended to, trimmed, and paged through... so a small cache suffices
+
+	journalRecCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "JournalRecCacheEvictLowLimit")
+	if nil != err {
+		journalRecCacheEvictLowLimit = journalRecCacheDefaultEvictLowLimit
+	}
+	journalRecCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "JournalRecCacheEvictHighLimit")
+	if (nil != err) || (journalRecCacheEvictHighLimit < journalRecCacheEvictLowLimit) {
+		journalRecCacheEvictHighLimit = journalRecCacheEvictLowLimit
+	}
+
+	globals.journalRecCache = sortedmap.NewBPlusTreeCache(journalRecCacheEvictLowLimit, journalRecCacheEvictHighLimit)
+
+	// The fingerprintRec B+Tree is only populated for volumes with DedupChunkSize set
+
+	fingerprintRecCacheEvictLowLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "FingerprintRecCacheEvictLowLimit")
+	if nil != err {
+		fingerprintRecCacheEvictLowLimit = fingerprintRecCacheDefaultEvictLowLimit
+	}
+	fingerprintRecCacheEvictHighLimit, err = confMap.FetchOptionValueUint64("FSGlobals", "FingerprintRecCacheEvictHighLimit")
+	if (nil != err) || (fingerprintRecCacheEvictHighLimit < fingerprintRecCacheEvictLowLimit) {
+		fingerprintRecCacheEvictHighLimit = fingerprintRecCacheEvictLowLimit
+	}
+
+	globals.fingerprintRecCache = sortedmap.NewBPlusTreeCache(fingerprintRecCacheEvictLowLimit, fingerprintRecCacheEvictHighLimit)
+
 	globals.volumeMap = make(map[string]*volumeStruct)
 
 	for _, volumeName = range volumeList {
@@ -331,16 +368,38 @@ func Down() (err error) {
 
 // Format runs an instance of the headhunter package for formatting a new volume
 func Format(confMap conf.ConfMap, volumeName string) (err error) {
+	err = examineCStructs()
+	if nil != err {
+		return
+	}
+
+	// Init volume database...triggering format
+
+	globals.volumeMap = make(map[string]*volumeStruct)
+
+	err = upVolume(confMap, volumeName, true)
+	if nil != err {
+		return
+	}
+
+	// Shutdown and exit
+
+	err = downVolume(volumeName)
+
+	return
+}
+
+// examineCStructs pre-computes the crc64 ECMA Table & useful cstruct sizes
+func examineCStructs() (err error) {
 	var (
 		dummyCheckpointHeaderV2Struct            checkpointHeaderV2Struct
 		dummyCheckpointObjectTrailerV2Struct     checkpointObjectTrailerV2Struct
+		dummyCheckpointObjectTrailerV3Struct     checkpointObjectTrailerV3Struct
 		dummyElementOfBPlusTreeLayoutStruct      elementOfBPlusTreeLayoutStruct
 		dummyReplayLogTransactionFixedPartStruct replayLogTransactionFixedPartStruct
 		dummyUint64                              uint64
 	)
 
-	// Pre-compute crc64 ECMA Table & useful cstruct sizes
-
 	globals.crc64ECMATable = crc64.MakeTable(crc64.ECMA)
 
 	globals.uint64Size, _, err = cstruct.Examine(dummyUint64)
@@ -353,33 +412,22 @@ func Format(confMap conf.ConfMap, volumeName string) (err error) {
 		return
 	}
 
-	globals.checkpointObjectTrailerStructSize, _, err = cstruct.Examine(dummyCheckpointObjectTrailerV2Struct)
-	if nil != err {
-		return
-	}
-
-	globals.elementOfBPlusTreeLayoutStructSize, _, err = cstruct.Examine(dummyElementOfBPlusTreeLayoutStruct)
+	globals.checkpointObjectTrailerV2StructSize, _, err = cstruct.Examine(dummyCheckpointObjectTrailerV2Struct)
 	if nil != err {
 		return
 	}
 
-	globals.replayLogTransactionFixedPartStructSize, _, err = cstruct.Examine(dummyReplayLogTransactionFixedPartStruct)
+	globals.checkpointObjectTrailerV3StructSize, _, err = cstruct.Examine(dummyCheckpointObjectTrailerV3Struct)
 	if nil != err {
 		return
 	}
 
-	// Init volume database...triggering format
-
-	globals.volumeMap = make(map[string]*volumeStruct)
-
-	err = upVolume(confMap, volumeName, true)
+	globals.elementOfBPlusTreeLayoutStructSize, _, err = cstruct.Examine(dummyElementOfBPlusTreeLayoutStruct)
 	if nil != err {
 		return
 	}
 
-	// Shutdown and exit
-
-	err = downVolume(volumeName)
+	globals.replayLogTransactionFixedPartStructSize, _, err = cstruct.Examine(dummyReplayLogTransactionFixedPartStruct)
 
 	return
 }
@@ -437,6 +485,16 @@ func upVolume(confMap conf.ConfMap, volumeName string, autoFormat bool) (err err
 		return
 	}
 
+	volume.maxJournalRecsPerMetadataNode, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxJournalRecsPerMetadataNode")
+	if (nil != err) || (0 == volume.maxJournalRecsPerMetadataNode) {
+		volume.maxJournalRecsPerMetadataNode = maxJournalRecsPerMetadataNodeDefault
+	}
+
+	volume.maxFingerprintRecsPerMetadataNode, err = confMap.FetchOptionValueUint64(volumeSectionName, "MaxFingerprintRecsPerMetadataNode")
+	if (nil != err) || (0 == volume.maxFingerprintRecsPerMetadataNode) {
+		volume.maxFingerprintRecsPerMetadataNode = maxFingerprintRecsPerMetadataNodeDefault
+	}
+
 	volume.checkpointContainerName, err = confMap.FetchOptionValueString(volumeSectionName, "CheckpointContainerName")
 	if nil != err {
 		return
@@ -452,6 +510,8 @@ func upVolume(confMap conf.ConfMap, volumeName string, autoFormat bool) (err err
 		return
 	}
 
+	volume.checkpointRecoveryUp(confMap, volumeSectionName)
+
 	volume.replayLogFileName, err = confMap.FetchOptionValueString(volumeSectionName, "ReplayLogFileName")
 	if nil == err {
 		// Provision aligned buffer used to write to Replay Log
@@ -470,6 +530,8 @@ func upVolume(confMap conf.ConfMap, volumeName string, autoFormat bool) (err err
 
 	go volume.checkpointDaemon()
 
+	volume.snapshotExclusiveBytesUp()
+
 	err = nil
 	return
 }
@@ -487,6 +549,8 @@ func downVolume(volumeName string) (err error) {
 		return
 	}
 
+	volume.snapshotExclusiveBytesDown()
+
 	checkpointRequest.exitOnCompletion = true
 	checkpointRequest.waitGroup.Add(1)
 
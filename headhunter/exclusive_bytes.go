@@ -0,0 +1,338 @@
+package headhunter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// The bytes retained solely by each snapshot (i.e. those that would be freed by deleting it) are computed by
+// snapshotExclusiveBytesDaemon() rather than by FetchSnapshotExclusiveBytes() itself. Determining them requires
+// walking every snapshot's logSegmentRec B+Tree, looking up each LogSegment in the live volume's logSegmentRec
+// B+Tree, and fetching (via HEAD) the size of each LogSegment found to be exclusively retained. Doing so while
+// holding volume.Lock() would hold off all other activity on the volume for the duration.
+//
+// Instead, each snapshot's exclusive bytes are computed for all snapshots at once (counting references to each
+// Checkpoint Container object and LogSegment in a single pass over the snapshots) and cached. The snapshot
+// B+Trees are read-only, so they are walked without holding volume.Lock(). Lookups in the live volume's B+Tree
+// are made holding volume.Lock() for at most snapshotExclusiveBytesLookupBatchSize lookups at a time. As
+// LogSegments are immutable, their sizes are also cached (for as long as they remain exclusively retained).
+//
+// Whenever the snapshots' exclusive bytes may have changed (i.e. a checkpoint persists changes to the live
+// volume or a snapshot is created or deleted), snapshotExclusiveBytesGeneration is incremented. A subsequent
+// FetchSnapshotExclusiveBytes() returns the (now stale) cached value but kicks off a re-computation. Only
+// should no value yet have been computed for the snapshot will FetchSnapshotExclusiveBytes() wait for one.
+
+const (
+	snapshotExclusiveBytesLookupBatchSize = 1024
+)
+
+func (volume *volumeStruct) snapshotExclusiveBytesUp() {
+	volume.snapshotExclusiveBytesGeneration = 1 // so that the initial snapshotExclusiveBytesComputedGeneration (0) is stale
+	volume.snapshotExclusiveBytesComputedGeneration = 0
+	volume.snapshotExclusiveBytesErr = nil
+	volume.snapshotExclusiveBytesCond = sync.NewCond(&volume.Mutex)
+	volume.snapshotExclusiveBytesKickChan = make(chan struct{}, 1)
+	volume.snapshotExclusiveBytesStopChan = make(chan struct{})
+	volume.logSegmentBytesMap = make(map[uint64]uint64)
+
+	volume.snapshotExclusiveBytesWG.Add(1)
+
+	go volume.snapshotExclusiveBytesDaemon()
+}
+
+func (volume *volumeStruct) snapshotExclusiveBytesDown() {
+	close(volume.snapshotExclusiveBytesStopChan)
+
+	volume.snapshotExclusiveBytesWG.Wait()
+}
+
+// invalidateSnapshotExclusiveBytesWhileLocked notes that the snapshots' cached exclusive bytes may now be stale
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) invalidateSnapshotExclusiveBytesWhileLocked() {
+	volume.snapshotExclusiveBytesGeneration++
+}
+
+// kickSnapshotExclusiveBytesDaemonWhileLocked requests a re-computation of the snapshots' exclusive bytes (if stale)
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) kickSnapshotExclusiveBytesDaemonWhileLocked() {
+	if volume.snapshotExclusiveBytesComputedGeneration == volume.snapshotExclusiveBytesGeneration {
+		return
+	}
+
+	select {
+	case volume.snapshotExclusiveBytesKickChan <- struct{}{}:
+	default:
+		// A re-computation is already pending
+	}
+}
+
+func (volume *volumeStruct) snapshotExclusiveBytesDaemon() {
+	var (
+		err        error
+		generation uint64
+	)
+
+	for {
+		select {
+		case <-volume.snapshotExclusiveBytesKickChan:
+		case <-volume.snapshotExclusiveBytesStopChan:
+			volume.Lock()
+			volume.snapshotExclusiveBytesErr = fmt.Errorf("volume \"%v\" is going down", volume.volumeName)
+			volume.snapshotExclusiveBytesErr = blunder.AddError(volume.snapshotExclusiveBytesErr, blunder.TryAgainError)
+			volume.snapshotExclusiveBytesCond.Broadcast()
+			volume.Unlock()
+			volume.snapshotExclusiveBytesWG.Done()
+			return
+		}
+
+		for {
+			generation, err = volume.computeSnapshotExclusiveBytes()
+			if nil == err {
+				break
+			}
+
+			volume.Lock()
+			if generation != volume.snapshotExclusiveBytesGeneration {
+				// Likely failed due to a snapshot being concurrently deleted... so simply try again
+				volume.Unlock()
+				continue
+			}
+			logger.ErrorfWithError(err, "Unable to compute exclusive bytes of snapshots of volume \"%v\"", volume.volumeName)
+			volume.snapshotExclusiveBytesErr = err
+			volume.snapshotExclusiveBytesCond.Broadcast()
+			volume.Unlock()
+			break
+		}
+	}
+}
+
+// computeSnapshotExclusiveBytes computes (and caches) the exclusive bytes of each snapshot as of the returned generation
+func (volume *volumeStruct) computeSnapshotExclusiveBytes() (generation uint64, err error) {
+	var (
+		containerNameAsValue      sortedmap.Value
+		exclusiveBytesMap         map[*snapshotStruct]uint64
+		exclusiveLogSegmentsMap   map[*snapshotStruct][]uint64
+		index                     int
+		logSegmentBytes           uint64
+		logSegmentBytesMap        map[uint64]uint64
+		logSegmentContainerMap    map[uint64]string // value is LogSegment's containerName
+		logSegmentNumber          uint64
+		logSegmentNumberAsKey     sortedmap.Key
+		logSegmentReferencesMap   map[uint64]*snapshotStruct // value is nil if referenced by more than one snapshot
+		lookupsWhileLocked        int
+		numLogSegments            int
+		objectBytes               uint64
+		objectNumber              uint64
+		objectReferencesMap       map[uint64]*snapshotStruct // value is nil if pinned by more than one snapshot
+		ok                        bool
+		referencingSnapshot       *snapshotStruct
+		snapshot                  *snapshotStruct
+		snapshotList              []*snapshotStruct
+		unsizedLogSegmentsMap     map[uint64]string // value is LogSegment's containerName
+		unsizedLogSegmentsSnapMap map[uint64]*snapshotStruct
+	)
+
+	volume.Lock()
+
+	generation = volume.snapshotExclusiveBytesGeneration
+
+	snapshotList = make([]*snapshotStruct, 0, len(volume.snapshotMap))
+	for _, snapshot = range volume.snapshotMap {
+		snapshotList = append(snapshotList, snapshot)
+	}
+
+	volume.Unlock()
+
+	// Note which (if any) single snapshot references each Checkpoint Container object & LogSegment... the
+	// snapshots' B+Trees are read-only so they may be examined without holding volume.Lock()
+
+	objectReferencesMap = make(map[uint64]*snapshotStruct)
+	logSegmentReferencesMap = make(map[uint64]*snapshotStruct)
+	logSegmentContainerMap = make(map[uint64]string)
+
+	for _, snapshot = range snapshotList {
+		for objectNumber = range snapshot.pinnedObjectMap {
+			_, ok = objectReferencesMap[objectNumber]
+			if ok {
+				objectReferencesMap[objectNumber] = nil
+			} else {
+				objectReferencesMap[objectNumber] = snapshot
+			}
+		}
+
+		numLogSegments, err = snapshot.logSegmentRecWrapper.bPlusTree.Len()
+		if nil != err {
+			return
+		}
+
+		for index = 0; index < numLogSegments; index++ {
+			logSegmentNumberAsKey, containerNameAsValue, ok, err = snapshot.logSegmentRecWrapper.bPlusTree.GetByIndex(index)
+			if nil != err {
+				return
+			}
+			if !ok {
+				err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" logSegmentRec B+Tree index %v not found", utils.GetFnName(), snapshot.name, volume.volumeName, index)
+				return
+			}
+
+			logSegmentNumber = logSegmentNumberAsKey.(uint64)
+
+			_, ok = logSegmentReferencesMap[logSegmentNumber]
+			if ok {
+				logSegmentReferencesMap[logSegmentNumber] = nil
+			} else {
+				logSegmentReferencesMap[logSegmentNumber] = snapshot
+				logSegmentContainerMap[logSegmentNumber] = string(containerNameAsValue.([]byte))
+			}
+		}
+	}
+
+	// Of those, total the objects (and note the LogSegments) not referenced by the live volume
+
+	exclusiveBytesMap = make(map[*snapshotStruct]uint64)
+	exclusiveLogSegmentsMap = make(map[*snapshotStruct][]uint64)
+
+	volume.Lock()
+
+	for objectNumber, referencingSnapshot = range objectReferencesMap {
+		if (nil == referencingSnapshot) || volume.checkpointObjectInUseWhileLocked(objectNumber) {
+			continue
+		}
+		objectBytes = referencingSnapshot.pinnedObjectMap[objectNumber]
+		exclusiveBytesMap[referencingSnapshot] += objectBytes
+	}
+
+	lookupsWhileLocked = 0
+
+	for logSegmentNumber, referencingSnapshot = range logSegmentReferencesMap {
+		if (nil == referencingSnapshot) || volume.logSegmentIsSharedWhileLocked(logSegmentNumber) {
+			continue
+		}
+
+		if snapshotExclusiveBytesLookupBatchSize == lookupsWhileLocked {
+			// Give others a chance at volume.Lock()
+			volume.Unlock()
+			lookupsWhileLocked = 0
+			volume.Lock()
+		}
+
+		_, ok, err = volume.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+		lookupsWhileLocked++
+		if nil != err {
+			volume.Unlock()
+			return
+		}
+		if !ok {
+			exclusiveLogSegmentsMap[referencingSnapshot] = append(exclusiveLogSegmentsMap[referencingSnapshot], logSegmentNumber)
+		}
+	}
+
+	// Total the sizes of those LogSegments (fetching those not already known without holding volume.Lock())
+
+	logSegmentBytesMap = make(map[uint64]uint64)
+	unsizedLogSegmentsMap = make(map[uint64]string)
+	unsizedLogSegmentsSnapMap = make(map[uint64]*snapshotStruct)
+
+	for referencingSnapshot = range exclusiveLogSegmentsMap {
+		for _, logSegmentNumber = range exclusiveLogSegmentsMap[referencingSnapshot] {
+			logSegmentBytes, ok = volume.logSegmentBytesMap[logSegmentNumber]
+			if ok {
+				logSegmentBytesMap[logSegmentNumber] = logSegmentBytes
+				exclusiveBytesMap[referencingSnapshot] += logSegmentBytes
+			} else {
+				unsizedLogSegmentsMap[logSegmentNumber] = logSegmentContainerMap[logSegmentNumber]
+				unsizedLogSegmentsSnapMap[logSegmentNumber] = referencingSnapshot
+			}
+		}
+	}
+
+	volume.Unlock()
+
+	for logSegmentNumber = range unsizedLogSegmentsMap {
+		logSegmentBytes, err = swiftclient.ObjectContentLength(volume.accountName, unsizedLogSegmentsMap[logSegmentNumber], utils.Uint64ToHexStr(logSegmentNumber))
+		if nil != err {
+			return
+		}
+
+		logSegmentBytesMap[logSegmentNumber] = logSegmentBytes
+		exclusiveBytesMap[unsizedLogSegmentsSnapMap[logSegmentNumber]] += logSegmentBytes
+	}
+
+	// Finally, record the results (only retaining the sizes of LogSegments still exclusively retained)
+
+	volume.Lock()
+
+	for _, snapshot = range snapshotList {
+		snapshot.exclusiveBytes = exclusiveBytesMap[snapshot]
+		snapshot.exclusiveBytesComputed = true
+	}
+
+	volume.logSegmentBytesMap = logSegmentBytesMap
+
+	volume.snapshotExclusiveBytesComputedGeneration = generation
+	volume.snapshotExclusiveBytesErr = nil
+
+	volume.snapshotExclusiveBytesCond.Broadcast()
+
+	volume.Unlock()
+
+	err = nil
+	return
+}
+
+// FetchSnapshotExclusiveBytes returns the bytes of the Checkpoint Container objects and LogSegments retained
+// solely by the named snapshot (i.e. the bytes that would be freed by deleting it) as of their last computation
+// by snapshotExclusiveBytesDaemon()... which is requested should that value be stale.
+func (volume *volumeStruct) FetchSnapshotExclusiveBytes(name string) (exclusiveBytes uint64, err error) {
+	var (
+		ok       bool
+		snapshot *snapshotStruct
+	)
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapshot, ok = volume.snapshotMap[name]
+	if !ok {
+		err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" not found", utils.GetFnName(), name, volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	volume.kickSnapshotExclusiveBytesDaemonWhileLocked()
+
+	for !snapshot.exclusiveBytesComputed {
+		volume.snapshotExclusiveBytesCond.Wait()
+
+		if snapshot.deleted {
+			err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" not found", utils.GetFnName(), name, volume.volumeName)
+			err = blunder.AddError(err, blunder.NotFoundError)
+			return
+		}
+
+		if !snapshot.exclusiveBytesComputed {
+			if nil != volume.snapshotExclusiveBytesErr {
+				err = volume.snapshotExclusiveBytesErr
+				return
+			}
+
+			// The computation just completed began before snapshot was created... so request another
+
+			volume.kickSnapshotExclusiveBytesDaemonWhileLocked()
+		}
+	}
+
+	exclusiveBytes = snapshot.exclusiveBytes
+
+	err = nil
+	return
+}
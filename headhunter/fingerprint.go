@@ -0,0 +1,78 @@
+package headhunter
+
+import (
+	"github.com/swiftstack/sortedmap"
+)
+
+// The fingerprintRec B+Tree holds a volume's deduplication index. Each record is keyed by a (truncated) fingerprint
+// of a chunk of file data previously written to a LogSegment. Record values are opaque to headhunter (see package
+// inode for their content)... and, as they are merely hints, a missing record is not an error.
+
+const (
+	maxFingerprintRecsPerMetadataNodeDefault = uint64(256)
+
+	fingerprintRecCacheDefaultEvictLowLimit = uint64(1024)
+)
+
+func (volume *volumeStruct) GetFingerprintRec(fingerprint uint64) (value []byte, ok bool, err error) {
+	volume.Lock()
+	value, ok, err = getFingerprintRecWhileLocked(volume.fingerprintRecWrapper.bPlusTree, fingerprint)
+	volume.Unlock()
+	return
+}
+
+func (volume *volumeStruct) PutFingerprintRec(fingerprint uint64, value []byte) (err error) {
+	valueToTree := make([]byte, len(value))
+	copy(valueToTree, value)
+
+	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
+
+	ok, err := volume.fingerprintRecWrapper.bPlusTree.PatchByKey(fingerprint, valueToTree)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+	if !ok {
+		_, err = volume.fingerprintRecWrapper.bPlusTree.Put(fingerprint, valueToTree)
+		if nil != err {
+			volume.Unlock()
+			return
+		}
+	}
+
+	volume.recordTransaction(transactionPutFingerprintRec, fingerprint, value)
+
+	volume.Unlock()
+
+	err = nil
+	return
+}
+
+func (volume *volumeStruct) DeleteFingerprintRec(fingerprint uint64) (err error) {
+	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
+
+	_, err = volume.fingerprintRecWrapper.bPlusTree.DeleteByKey(fingerprint)
+
+	volume.recordTransaction(transactionDeleteFingerprintRec, fingerprint, nil)
+
+	volume.Unlock()
+
+	return
+}
+
+// getFingerprintRecWhileLocked looks up a fingerprintRec in the B+Tree of either a volume or a snapshot
+//
+// Note: Caller must hold volume.Lock()
+func getFingerprintRecWhileLocked(bPlusTree sortedmap.BPlusTree, fingerprint uint64) (value []byte, ok bool, err error) {
+	valueAsValue, ok, err := bPlusTree.GetByKey(fingerprint)
+	if (nil != err) || !ok {
+		return
+	}
+
+	value = make([]byte, len(valueAsValue.([]byte)))
+	copy(value, valueAsValue.([]byte))
+
+	return
+}
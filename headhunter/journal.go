@@ -0,0 +1,182 @@
+package headhunter
+
+import (
+	"fmt"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// The journalRec B+Tree holds a volume's change journal. Each record is keyed by a nonce fetched (via FetchNonce())
+// as it is recorded... so the B+Tree holds records in the order they were recorded. Record values are opaque to
+// headhunter (see package fs for their content).
+//
+// Records are only ever discarded from the front of the journal via TrimJournalRecs(). The highest nonce so discarded
+// is checkpointed (in checkpointObjectTrailerV3Struct.JournalRecTrimmedToNonce) so that FetchJournalRecs() is able
+// to inform a consumer that records following the last one it fetched have since been discarded.
+
+const (
+	maxJournalRecsPerMetadataNodeDefault = uint64(256)
+
+	journalRecCacheDefaultEvictLowLimit = uint64(1024)
+)
+
+func (volume *volumeStruct) PutJournalRec(journalNonce uint64, value []byte) (err error) {
+	valueToTree := make([]byte, len(value))
+	copy(valueToTree, value)
+
+	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
+
+	ok, err := volume.journalRecWrapper.bPlusTree.PatchByKey(journalNonce, valueToTree)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+	if !ok {
+		_, err = volume.journalRecWrapper.bPlusTree.Put(journalNonce, valueToTree)
+		if nil != err {
+			volume.Unlock()
+			return
+		}
+	}
+
+	volume.recordTransaction(transactionPutJournalRec, journalNonce, value)
+
+	volume.Unlock()
+
+	err = nil
+	return
+}
+
+// FetchJournalRecs returns up to maxRecs journal records following afterNonce (or, if afterNonce == 0, the oldest ones
+// retained). If records following afterNonce have been trimmed, a blunder.JournalTrimmedError is returned.
+func (volume *volumeStruct) FetchJournalRecs(afterNonce uint64, maxRecs uint64) (journalNonces []uint64, values [][]byte, err error) {
+	volume.Lock()
+	journalNonces, values, err = fetchJournalRecsWhileLocked(volume.journalRecWrapper.bPlusTree, volume.checkpointObjectTrailer.JournalRecTrimmedToNonce, afterNonce, maxRecs)
+	volume.Unlock()
+
+	if blunder.Is(err, blunder.JournalTrimmedError) {
+		err = fmt.Errorf("%s: volume \"%v\" %v", utils.GetFnName(), volume.volumeName, err)
+		err = blunder.AddError(err, blunder.JournalTrimmedError)
+	}
+
+	return
+}
+
+// TrimJournalRecs discards all journal records keyed at or below trimToNonce
+func (volume *volumeStruct) TrimJournalRecs(trimToNonce uint64) (err error) {
+	volume.Lock()
+	volume.waitForCheckpointRecoveryWhileLocked()
+
+	err = volume.trimJournalRecsWhileLocked(trimToNonce)
+	if nil != err {
+		volume.Unlock()
+		return
+	}
+
+	volume.recordTransaction(transactionTrimJournalRecs, trimToNonce, nil)
+
+	volume.Unlock()
+
+	err = nil
+	return
+}
+
+// trimJournalRecsWhileLocked is called by both TrimJournalRecs() and the replay of a transactionTrimJournalRecs
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) trimJournalRecsWhileLocked(trimToNonce uint64) (err error) {
+	var (
+		journalNonce      uint64
+		journalNonceAsKey sortedmap.Key
+		ok                bool
+	)
+
+	for {
+		journalNonceAsKey, _, ok, err = volume.journalRecWrapper.bPlusTree.GetByIndex(0)
+		if nil != err {
+			return
+		}
+		if !ok {
+			break
+		}
+
+		journalNonce = journalNonceAsKey.(uint64)
+		if journalNonce > trimToNonce {
+			break
+		}
+
+		_, err = volume.journalRecWrapper.bPlusTree.DeleteByIndex(0)
+		if nil != err {
+			return
+		}
+
+		// Only records actually discarded advance JournalRecTrimmedToNonce
+
+		volume.checkpointObjectTrailer.JournalRecTrimmedToNonce = journalNonce
+	}
+
+	err = nil
+	return
+}
+
+// fetchJournalRecsWhileLocked pages through the journalRec B+Tree of either a volume or a snapshot
+//
+// Note: Caller must hold volume.Lock()
+func fetchJournalRecsWhileLocked(bPlusTree sortedmap.BPlusTree, trimmedToNonce uint64, afterNonce uint64, maxRecs uint64) (journalNonces []uint64, values [][]byte, err error) {
+	var (
+		found             bool
+		index             int
+		journalNonceAsKey sortedmap.Key
+		numJournalRecs    int
+		ok                bool
+		valueAsValue      sortedmap.Value
+	)
+
+	if (0 != afterNonce) && (afterNonce < trimmedToNonce) {
+		err = fmt.Errorf("journal records following 0x%016X have been trimmed (through 0x%016X)", afterNonce, trimmedToNonce)
+		err = blunder.AddError(err, blunder.JournalTrimmedError)
+		return
+	}
+
+	numJournalRecs, err = bPlusTree.Len()
+	if nil != err {
+		return
+	}
+
+	index, found, err = bPlusTree.BisectRight(afterNonce)
+	if nil != err {
+		return
+	}
+	if found {
+		index++
+	}
+
+	journalNonces = make([]uint64, 0)
+	values = make([][]byte, 0)
+
+	for (index < numJournalRecs) && (uint64(len(journalNonces)) < maxRecs) {
+		journalNonceAsKey, valueAsValue, ok, err = bPlusTree.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("journalRec B+Tree index %v not found", index)
+			return
+		}
+
+		value := make([]byte, len(valueAsValue.([]byte)))
+		copy(value, valueAsValue.([]byte))
+
+		journalNonces = append(journalNonces, journalNonceAsKey.(uint64))
+		values = append(values, value)
+
+		index++
+	}
+
+	err = nil
+	return
+}
@@ -0,0 +1,295 @@
+package headhunter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A checkpoint that fails part way through will likely have marked clean B+Tree nodes whose contents
+// (or the Checkpoint Header referencing them) never made it to Swift. Only those nodes sent to the
+// checkpoint object that failed to be PUT are affected... nodes in checkpoint objects successfully PUT
+// along the way are safely in Swift. As the ChunkedPutContext of the failed object retains what was
+// sent, it is kept (see closeCheckpointChunkedPutContextWhileLocked()) and its content is re-PUT to the
+// same object before the checkpoint is retried (see replayFailedCheckpointObjectIfNecessaryWhileLocked()).
+// As such, the B+Trees' references to the lost nodes become valid once again without having to re-dirty
+// (let alone load) any other nodes. Until then, GetNode() reads such nodes from the retained context.
+// The retried checkpoint then records a new Checkpoint Header even should no nodes be dirty.
+//
+// Should the ChunkedPutContext of the failed object not retain what was sent to it (i.e. it failed fatally),
+// replay is impossible. In that case, every B+Tree node is instead re-dirtied (see redirtyBPlusTreesIfNecessaryWhileLocked())
+// so that the retried checkpoint re-writes all of them to new checkpoint objects... including those only the lost
+// object held. As the latter can no longer be fetched once evicted, this is first attempted immediately upon the
+// failure (while such nodes remain cached) and, until it succeeds, again prior to each retry.
+//
+// While in this CheckpointDegraded state, the checkpoint is retried every CheckpointRetryDelay (growing
+// by a factor of CheckpointRetryExpBackoff after each failure up to CheckpointRetryMaxDelay) and calls
+// that would modify the volume's B+Trees block until a checkpoint once again succeeds. Garbage collection
+// awaiting checkpoint completion (see FetchNextCheckPointDoneWaitGroup()) is similarly held off.
+
+const (
+	checkpointRetryDefaultDelay      = 1 * time.Second
+	checkpointRetryDefaultMaxDelay   = 1 * time.Minute
+	checkpointRetryDefaultExpBackoff = float64(2.0)
+)
+
+func (volume *volumeStruct) checkpointRecoveryUp(confMap conf.ConfMap, volumeSectionName string) {
+	var (
+		err error
+	)
+
+	volume.checkpointRetryDelay, err = confMap.FetchOptionValueDuration(volumeSectionName, "CheckpointRetryDelay")
+	if (nil != err) || (0 == volume.checkpointRetryDelay) {
+		volume.checkpointRetryDelay = checkpointRetryDefaultDelay
+	}
+
+	volume.checkpointRetryMaxDelay, err = confMap.FetchOptionValueDuration(volumeSectionName, "CheckpointRetryMaxDelay")
+	if nil != err {
+		volume.checkpointRetryMaxDelay = checkpointRetryDefaultMaxDelay
+	}
+	if volume.checkpointRetryMaxDelay < volume.checkpointRetryDelay {
+		volume.checkpointRetryMaxDelay = volume.checkpointRetryDelay
+	}
+
+	volume.checkpointRetryExpBackoff, err = confMap.FetchOptionValueFloat64(volumeSectionName, "CheckpointRetryExpBackoff")
+	if (nil != err) || (1.0 > volume.checkpointRetryExpBackoff) {
+		volume.checkpointRetryExpBackoff = checkpointRetryDefaultExpBackoff
+	}
+
+	volume.checkpointHealth = CheckpointHealthStruct{State: CheckpointHealthy}
+	volume.checkpointRecoveryCond = sync.NewCond(&volume.Mutex)
+	volume.checkpointFailedChunkedPutContext = nil
+	volume.checkpointHeaderNeeded = false
+	volume.checkpointRedirtyNeeded = false
+	volume.checkpointNextRetryDelay = volume.checkpointRetryDelay
+}
+
+// checkpointWaitDurationWhileLocked returns how long checkpointDaemon() should wait for an explicitly requested
+// checkpoint before conducting one on its own (i.e. either the next periodic checkpoint or a retry of a failed one)
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) checkpointWaitDurationWhileLocked() (waitDuration time.Duration) {
+	if CheckpointHealthy == volume.checkpointHealth.State {
+		waitDuration = volume.checkpointInterval
+	} else {
+		waitDuration = volume.checkpointHealth.NextRetryTime.Sub(time.Now())
+		if 0 > waitDuration {
+			waitDuration = 0
+		}
+	}
+	return
+}
+
+// closeCheckpointChunkedPutContextWhileLocked completes the PUT of the current checkpoint object. Should that
+// fail, the ChunkedPutContext is retained so that replayFailedCheckpointObjectIfNecessaryWhileLocked() may re-PUT
+// the B+Tree nodes sent to it.
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) closeCheckpointChunkedPutContextWhileLocked() (err error) {
+	err = volume.checkpointChunkedPutContext.Close()
+	if nil != err {
+		volume.checkpointFailedChunkedPutContext = volume.checkpointChunkedPutContext
+		volume.checkpointFailedChunkedPutContextObjectNumber = volume.checkpointChunkedPutContextObjectNumber
+	}
+
+	volume.checkpointChunkedPutContext = nil
+
+	return
+}
+
+// replayFailedCheckpointObjectIfNecessaryWhileLocked re-PUTs the checkpoint object (if any) a prior checkpoint failed
+// to PUT such that the B+Tree nodes referring to it are once again valid. Should that object not be replayable, the
+// B+Trees are instead re-dirtied.
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) replayFailedCheckpointObjectIfNecessaryWhileLocked() (err error) {
+	var (
+		buf               []byte
+		chunkedPutContext swiftclient.ChunkedPutContext
+	)
+
+	if nil != volume.checkpointFailedChunkedPutContext {
+		buf, err = volume.fetchFailedCheckpointObjectWhileLocked()
+		if nil != err {
+			volume.abandonFailedCheckpointObjectWhileLocked(err)
+		} else if 0 < len(buf) {
+			chunkedPutContext, err = swiftclient.ObjectFetchChunkedPutContext(volume.accountName,
+				volume.checkpointContainerName,
+				utils.Uint64ToHexStr(volume.checkpointFailedChunkedPutContextObjectNumber))
+			if nil != err {
+				return
+			}
+
+			err = chunkedPutContext.SendChunk(buf)
+			if nil != err {
+				return
+			}
+
+			err = chunkedPutContext.Close()
+			if nil != err {
+				return
+			}
+
+			volume.checkpointFailedChunkedPutContext = nil
+		} else {
+			volume.checkpointFailedChunkedPutContext = nil
+		}
+	}
+
+	err = volume.redirtyBPlusTreesIfNecessaryWhileLocked()
+
+	return
+}
+
+// fetchFailedCheckpointObjectWhileLocked returns what was sent to the checkpoint object a prior checkpoint failed to PUT
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) fetchFailedCheckpointObjectWhileLocked() (buf []byte, err error) {
+	var (
+		bytesPut uint64
+	)
+
+	bytesPut, err = volume.checkpointFailedChunkedPutContext.BytesPut()
+	if nil != err {
+		return
+	}
+
+	if 0 == bytesPut {
+		buf = []byte{}
+	} else {
+		buf, err = volume.checkpointFailedChunkedPutContext.Read(0, bytesPut)
+	}
+
+	return
+}
+
+// abandonFailedCheckpointObjectWhileLocked gives up on replaying the checkpoint object a prior checkpoint failed to PUT
+// and, instead, schedules the re-dirtying of every B+Tree node
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) abandonFailedCheckpointObjectWhileLocked(fetchErr error) {
+	logger.WarnfWithError(fetchErr, "Volume %s cannot replay checkpoint object %016X... will re-dirty all B+Tree nodes", volume.volumeName, volume.checkpointFailedChunkedPutContextObjectNumber)
+
+	volume.checkpointFailedChunkedPutContext = nil
+	volume.checkpointRedirtyNeeded = true
+}
+
+// redirtyBPlusTreesIfNecessaryWhileLocked marks every node of each of the volume's B+Trees dirty (loading any that had
+// been evicted) if the checkpoint object a prior checkpoint failed to PUT has been abandoned
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) redirtyBPlusTreesIfNecessaryWhileLocked() (err error) {
+	var (
+		bPlusTreeWrapper *bPlusTreeWrapperStruct
+	)
+
+	if !volume.checkpointRedirtyNeeded {
+		err = nil
+		return
+	}
+
+	for _, bPlusTreeWrapper = range []*bPlusTreeWrapperStruct{
+		volume.inodeRecWrapper,
+		volume.logSegmentRecWrapper,
+		volume.bPlusTreeObjectWrapper,
+		volume.journalRecWrapper,
+		volume.fingerprintRecWrapper,
+	} {
+		err = bPlusTreeWrapper.bPlusTree.Touch()
+		if nil != err {
+			return
+		}
+	}
+
+	volume.checkpointRedirtyNeeded = false
+
+	return
+}
+
+// recordCheckpointSuccessWhileLocked returns the volume to the CheckpointHealthy state (if not already there)
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) recordCheckpointSuccessWhileLocked() {
+	if CheckpointDegraded == volume.checkpointHealth.State {
+		logger.Infof("Volume %s checkpoint succeeded after %d consecutive failures... resuming writes", volume.volumeName, volume.checkpointHealth.ConsecutiveFailures)
+	}
+
+	volume.checkpointHealth.State = CheckpointHealthy
+	volume.checkpointHealth.ConsecutiveFailures = 0
+	volume.checkpointHealth.LastSuccessTime = time.Now()
+	volume.checkpointHealth.NextRetryTime = time.Time{}
+
+	volume.checkpointNextRetryDelay = volume.checkpointRetryDelay
+
+	volume.checkpointHeaderNeeded = false
+
+	volume.checkpointRecoveryCond.Broadcast()
+}
+
+// recordCheckpointFailureWhileLocked enters (or remains in) the CheckpointDegraded state and schedules a retry
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) recordCheckpointFailureWhileLocked(checkpointErr error) {
+	var (
+		err error
+	)
+
+	// Complete any partially sent checkpoint object (as B+Tree nodes may refer to it)... retaining it for replay should that fail
+
+	if nil != volume.checkpointChunkedPutContext {
+		err = volume.closeCheckpointChunkedPutContextWhileLocked()
+		if nil != err {
+			logger.WarnfWithError(err, "Volume %s failed to close checkpoint object %016X... will replay it", volume.volumeName, volume.checkpointFailedChunkedPutContextObjectNumber)
+		}
+	}
+
+	// Should the failed checkpoint object not be replayable, re-dirty the B+Trees before any of its nodes are evicted
+
+	if nil != volume.checkpointFailedChunkedPutContext {
+		_, err = volume.fetchFailedCheckpointObjectWhileLocked()
+		if nil != err {
+			volume.abandonFailedCheckpointObjectWhileLocked(err)
+		}
+	}
+
+	err = volume.redirtyBPlusTreesIfNecessaryWhileLocked()
+	if nil != err {
+		logger.ErrorfWithError(err, "Volume %s failed to re-dirty B+Trees... will retry", volume.volumeName)
+	}
+
+	volume.checkpointHeaderNeeded = true
+
+	volume.checkpointHealth.State = CheckpointDegraded
+	volume.checkpointHealth.ConsecutiveFailures++
+	volume.checkpointHealth.LastFailureTime = time.Now()
+	volume.checkpointHealth.LastFailureErr = checkpointErr
+	volume.checkpointHealth.NextRetryTime = volume.checkpointHealth.LastFailureTime.Add(volume.checkpointNextRetryDelay)
+
+	logger.ErrorfWithError(checkpointErr, "Volume %s checkpoint failed (%d consecutive failures)... quiescing writes and retrying in %v",
+		volume.volumeName, volume.checkpointHealth.ConsecutiveFailures, volume.checkpointNextRetryDelay)
+
+	volume.checkpointNextRetryDelay = time.Duration(float64(volume.checkpointNextRetryDelay) * volume.checkpointRetryExpBackoff)
+	if volume.checkpointNextRetryDelay > volume.checkpointRetryMaxDelay {
+		volume.checkpointNextRetryDelay = volume.checkpointRetryMaxDelay
+	}
+}
+
+// waitForCheckpointRecoveryWhileLocked blocks (temporarily releasing volume.Lock()) while checkpoints are failing
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) waitForCheckpointRecoveryWhileLocked() {
+	for CheckpointDegraded == volume.checkpointHealth.State {
+		volume.checkpointRecoveryCond.Wait()
+	}
+}
+
+func (volume *volumeStruct) FetchCheckpointHealth() (checkpointHealth CheckpointHealthStruct) {
+	volume.Lock()
+	checkpointHealth = volume.checkpointHealth
+	volume.Unlock()
+	return
+}
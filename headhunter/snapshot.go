@@ -0,0 +1,999 @@
+package headhunter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A snapshot retains the B+Trees of a checkpoint (as found via its checkpointObjectTrailerV{2|3}Struct).
+//
+// The snapshots are recorded in a "snapshot index" object in the Checkpoint Container (named, like the other
+// objects there, by a nonce) referenced by a Checkpoint Container header named SnapshotIndexHeaderName whose
+// value is the snapshot index object's objectNumber in %016X. Swift limits the number (and total size) of a
+// container's metadata headers... a budget also consumed by clone references (see clone.go). Recording the
+// snapshots in an object ensures they neither run up against that limit nor starve clone references of it.
+// The snapshot index holds one line per snapshot:
+//
+//   uint64 in %016X indicating the snapshot's ID
+//   ' '
+//   uint64 in %016X indicating objectNumber containing checkpoint record at tail of object
+//   ' '
+//   uint64 in %016X indicating length of               checkpoint record at tail of object
+//   ' '
+//   uint64 in %016X indicating creation time in nanoseconds since the Unix epoch
+//   ' '
+//   name of the snapshot
+//   ' '                                                                           (only if not checkpointHeaderVersion2)
+//   uint64 in %016X indicating checkpointHeaderVersion of checkpoint record     (only if not checkpointHeaderVersion2)
+//   '\n'
+//
+// Each change to the set of snapshots PUTs a new snapshot index object, updates the header to reference it,
+// and then deletes the prior one.
+//
+// While a snapshot exists, neither the objects holding its B+Tree nodes nor the LogSegments its logSegmentRec
+// B+Tree references will be deleted. Upon deleting a snapshot, those no longer referenced by the live volume
+// (or another snapshot) are deleted.
+
+const (
+	SnapshotIndexHeaderName = "X-Container-Meta-Snapshots"
+	SnapshotNameMaxLength   = 255
+)
+
+type snapshotStruct struct {
+	volume                   *volumeStruct
+	id                       uint64
+	name                     string
+	creationTime             time.Time
+	checkpointHeaderVersion  uint64
+	checkpointHeader         checkpointHeaderV2Struct // ReservedToNonce not used
+	pinnedObjectMap          map[uint64]uint64        // objects holding the snapshot's B+Tree nodes & checkpointObjectTrailerV{2|3}Struct (value is bytes used)
+	inodeRecWrapper          *bPlusTreeWrapperStruct
+	logSegmentRecWrapper     *bPlusTreeWrapperStruct
+	bPlusTreeObjectWrapper   *bPlusTreeWrapperStruct
+	journalRecWrapper        *bPlusTreeWrapperStruct
+	journalRecTrimmedToNonce uint64
+	fingerprintRecWrapper    *bPlusTreeWrapperStruct
+	deleted                  bool   // Synchronized via volume.Lock()
+	exclusiveBytes           uint64 // as of last computation by snapshotExclusiveBytesDaemon() (see exclusive_bytes.go)
+	exclusiveBytesComputed   bool   // if false, exclusiveBytes not yet computed
+}
+
+func validateSnapshotName(name string) (err error) {
+	if ("" == name) || ("." == name) || (".." == name) || (SnapshotNameMaxLength < len(name)) {
+		err = fmt.Errorf("%s: invalid snapshot name \"%v\"", utils.GetFnName(), name)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	for _, c := range []byte(name) {
+		if (c <= ' ') || (c > '~') || ('/' == c) {
+			err = fmt.Errorf("%s: invalid snapshot name \"%v\"", utils.GetFnName(), name)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+func (snapshot *snapshotStruct) export() (exportedSnapshot SnapshotStruct) {
+	exportedSnapshot = SnapshotStruct{
+		ID:                     snapshot.id,
+		Name:                   snapshot.name,
+		CreationTime:           snapshot.creationTime,
+		CheckpointObjectNumber: snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+	}
+	return
+}
+
+// indexLine returns the snapshot's line in the snapshot index
+func (snapshot *snapshotStruct) indexLine() (indexLine string) {
+	indexLine = utils.Uint64ToHexStr(snapshot.id) + " " + snapshot.indexValue() + "\n"
+	return
+}
+
+// indexValue returns all but the ID of the snapshot's line in the snapshot index 
+func (snapshot *snapshotStruct) indexValue() (indexValue string) {
+	indexValue = fmt.Sprintf("%016X %016X %016X %s",
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength,
+		uint64(snapshot.creationTime.UnixNano()),
+		snapshot.name,
+	)
+	if checkpointHeaderVersion2 != snapshot.checkpointHeaderVersion {
+		indexValue += fmt.Sprintf(" %016X", snapshot.checkpointHeaderVersion)
+	}
+	return
+}
+
+// load fetches the snapshot's checkpointObjectTrailerV{2|3}Struct and opens its (read-only) B+Trees
+func (snapshot *snapshotStruct) load() (err error) {
+	var (
+		bPlusTreeObjectBPlusTreeLayout sortedmap.LayoutReport
+		checkpointObjectTrailer        *checkpointObjectTrailerV3Struct
+		fingerprintRecBPlusTreeLayout  sortedmap.LayoutReport
+		inodeRecBPlusTreeLayout        sortedmap.LayoutReport
+		journalRecBPlusTreeLayout      sortedmap.LayoutReport
+		layout                         sortedmap.LayoutReport
+		logSegmentRecBPlusTreeLayout   sortedmap.LayoutReport
+		objectBytes                    uint64
+		objectNumber                   uint64
+		volume                         *volumeStruct
+	)
+
+	volume = snapshot.volume
+
+	checkpointObjectTrailer,
+		inodeRecBPlusTreeLayout,
+		logSegmentRecBPlusTreeLayout,
+		bPlusTreeObjectBPlusTreeLayout,
+		journalRecBPlusTreeLayout,
+		fingerprintRecBPlusTreeLayout,
+		err = volume.fetchCheckpointObjectTrailer(
+		snapshot.checkpointHeaderVersion,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber,
+		snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength)
+	if nil != err {
+		return
+	}
+
+	// Note that a LayoutReport may include objects no longer holding any nodes (and, hence, already deleted)
+
+	snapshot.pinnedObjectMap = make(map[uint64]uint64)
+
+	snapshot.pinnedObjectMap[snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber] = snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength
+
+	for _, layout = range []sortedmap.LayoutReport{inodeRecBPlusTreeLayout, logSegmentRecBPlusTreeLayout, bPlusTreeObjectBPlusTreeLayout, journalRecBPlusTreeLayout, fingerprintRecBPlusTreeLayout} {
+		for objectNumber, objectBytes = range layout {
+			if 0 < objectBytes {
+				snapshot.pinnedObjectMap[objectNumber] += objectBytes
+			}
+		}
+	}
+
+	snapshot.inodeRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: inodeRecBPlusTreeWrapperType, readOnly: true}
+	snapshot.logSegmentRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: logSegmentRecBPlusTreeWrapperType, readOnly: true}
+	snapshot.bPlusTreeObjectWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: bPlusTreeObjectBPlusTreeWrapperType, readOnly: true}
+	snapshot.journalRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: journalRecBPlusTreeWrapperType, readOnly: true}
+	snapshot.fingerprintRecWrapper = &bPlusTreeWrapperStruct{volume: volume, wrapperType: fingerprintRecBPlusTreeWrapperType, readOnly: true}
+
+	snapshot.journalRecTrimmedToNonce = checkpointObjectTrailer.JournalRecTrimmedToNonce
+
+	err = snapshot.inodeRecWrapper.loadBPlusTree(
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.InodeRecBPlusTreeObjectLength,
+		volume.maxInodesPerMetadataNode,
+		globals.inodeRecCache)
+	if nil != err {
+		return
+	}
+
+	err = snapshot.logSegmentRecWrapper.loadBPlusTree(
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.LogSegmentRecBPlusTreeObjectLength,
+		volume.maxLogSegmentsPerMetadataNode,
+		globals.logSegmentRecCache)
+	if nil != err {
+		return
+	}
+
+	err = snapshot.bPlusTreeObjectWrapper.loadBPlusTree(
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectNumber,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectOffset,
+		checkpointObjectTrailer.BPlusTreeObjectBPlusTreeObjectLength,
+		volume.maxDirFileNodesPerMetadataNode,
+		globals.bPlusTreeObjectCache)
+	if nil != err {
+		return
+	}
+
+	err = snapshot.journalRecWrapper.loadBPlusTree(
+		checkpointObjectTrailer.JournalRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.JournalRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.JournalRecBPlusTreeObjectLength,
+		volume.maxJournalRecsPerMetadataNode,
+		globals.journalRecCache)
+	if nil != err {
+		return
+	}
+
+	err = snapshot.fingerprintRecWrapper.loadBPlusTree(
+		checkpointObjectTrailer.FingerprintRecBPlusTreeObjectNumber,
+		checkpointObjectTrailer.FingerprintRecBPlusTreeObjectOffset,
+		checkpointObjectTrailer.FingerprintRecBPlusTreeObjectLength,
+		volume.maxFingerprintRecsPerMetadataNode,
+		globals.fingerprintRecCache)
+	if nil != err {
+		return
+	}
+
+	err = nil
+	return
+}
+
+// parseSnapshot returns the (not yet loaded) snapshot with the specified (hex) ID described by value (see indexValue())
+func (volume *volumeStruct) parseSnapshot(idAsHex string, value string) (snapshot *snapshotStruct, err error) {
+	var (
+		creationTimeAsUnixNano uint64
+		valueSlice             []string
+	)
+
+	valueSlice = strings.Split(value, " ")
+	if (4 != len(valueSlice)) && (5 != len(valueSlice)) {
+		err = fmt.Errorf("Cannot parse %v/%v snapshot %v: %v (wrong number of fields)", volume.accountName, volume.checkpointContainerName, idAsHex, value)
+		return
+	}
+
+	snapshot = &snapshotStruct{
+		volume:                  volume,
+		name:                    valueSlice[3],
+		checkpointHeaderVersion: checkpointHeaderVersion2,
+		deleted:                 false,
+	}
+
+	if 5 == len(valueSlice) {
+		snapshot.checkpointHeaderVersion, err = strconv.ParseUint(valueSlice[4], 16, 64)
+		if nil != err {
+			err = fmt.Errorf("Cannot parse %v/%v snapshot %v: %v (bad checkpointHeaderVersion)", volume.accountName, volume.checkpointContainerName, idAsHex, value)
+			return
+		}
+	}
+
+	snapshot.id, err = strconv.ParseUint(idAsHex, 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v snapshot %v (bad ID)", volume.accountName, volume.checkpointContainerName, idAsHex)
+		return
+	}
+
+	snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber, err = strconv.ParseUint(valueSlice[0], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v snapshot %v: %v (bad objectNumber)", volume.accountName, volume.checkpointContainerName, idAsHex, value)
+		return
+	}
+
+	snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength, err = strconv.ParseUint(valueSlice[1], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v snapshot %v: %v (bad objectLength)", volume.accountName, volume.checkpointContainerName, idAsHex, value)
+		return
+	}
+
+	creationTimeAsUnixNano, err = strconv.ParseUint(valueSlice[2], 16, 64)
+	if nil != err {
+		err = fmt.Errorf("Cannot parse %v/%v snapshot %v: %v (bad creationTime)", volume.accountName, volume.checkpointContainerName, idAsHex, value)
+		return
+	}
+
+	snapshot.creationTime = time.Unix(0, int64(creationTimeAsUnixNano))
+
+	err = nil
+	return
+}
+
+// fetchSnapshots returns the (not yet loaded) snapshots recorded in the snapshot index referenced by
+// checkpointContainerHeaders (if any)
+func (volume *volumeStruct) fetchSnapshots(checkpointContainerHeaders map[string][]string) (snapshotList []*snapshotStruct, snapshotIndexObjectNumber uint64, err error) {
+	var (
+		headerValues     []string
+		indexLine        string
+		indexLineSlice   []string
+		ok               bool
+		snapshot         *snapshotStruct
+		snapshotIndexBuf []byte
+	)
+
+	snapshotList = make([]*snapshotStruct, 0)
+	snapshotIndexObjectNumber = 0
+
+	headerValues, ok = checkpointContainerHeaders[SnapshotIndexHeaderName]
+	if ok && (0 < len(headerValues)) && ("" != headerValues[0]) {
+		if 1 != len(headerValues) {
+			err = fmt.Errorf("Expected one single value for %v/%v header %v", volume.accountName, volume.checkpointContainerName, SnapshotIndexHeaderName)
+			return
+		}
+
+		snapshotIndexObjectNumber, err = strconv.ParseUint(headerValues[0], 16, 64)
+		if nil != err {
+			err = fmt.Errorf("Cannot parse %v/%v header %v: %v (bad objectNumber)", volume.accountName, volume.checkpointContainerName, SnapshotIndexHeaderName, headerValues[0])
+			return
+		}
+
+		snapshotIndexBuf, err = swiftclient.ObjectLoad(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(snapshotIndexObjectNumber))
+		if nil != err {
+			return
+		}
+
+		for _, indexLine = range strings.Split(string(snapshotIndexBuf), "\n") {
+			if "" == indexLine {
+				continue
+			}
+
+			indexLineSlice = strings.SplitN(indexLine, " ", 2)
+			if 2 != len(indexLineSlice) {
+				err = fmt.Errorf("Cannot parse %v/%v snapshot index line: %v", volume.accountName, volume.checkpointContainerName, indexLine)
+				return
+			}
+
+			snapshot, err = volume.parseSnapshot(indexLineSlice[0], indexLineSlice[1])
+			if nil != err {
+				return
+			}
+
+			snapshotList = append(snapshotList, snapshot)
+		}
+	}
+
+	err = nil
+	return
+}
+
+// loadSnapshots is called by getCheckpoint() to load the snapshots recorded in the Checkpoint Container
+func (volume *volumeStruct) loadSnapshots(checkpointContainerHeaders map[string][]string) (err error) {
+	var (
+		snapshot     *snapshotStruct
+		snapshotList []*snapshotStruct
+	)
+
+	volume.snapshotMap = make(map[string]*snapshotStruct)
+
+	snapshotList, volume.snapshotIndexObjectNumber, err = volume.fetchSnapshots(checkpointContainerHeaders)
+	if nil != err {
+		return
+	}
+
+	for _, snapshot = range snapshotList {
+		err = snapshot.load()
+		if nil != err {
+			return
+		}
+
+		volume.snapshotMap[snapshot.name] = snapshot
+	}
+
+	err = nil
+	return
+}
+
+// putSnapshotIndexWhileLocked records the snapshots in volume.snapshotMap (plus addedSnapshot and less removedSnapshot,
+// either of which may be nil) in a new snapshot index
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) putSnapshotIndexWhileLocked(addedSnapshot *snapshotStruct, removedSnapshot *snapshotStruct) (err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		chunkedPutContext          swiftclient.ChunkedPutContext
+		snapshot                   *snapshotStruct
+		snapshotIndexBuf           []byte
+		snapshotIndexObjectNumber  uint64
+		snapshotList               []*snapshotStruct
+	)
+
+	snapshotList = make([]*snapshotStruct, 0, len(volume.snapshotMap)+1)
+
+	checkpointContainerHeaders = make(map[string][]string)
+
+	for _, snapshot = range volume.snapshotMap {
+		if snapshot != removedSnapshot {
+			snapshotList = append(snapshotList, snapshot)
+		}
+	}
+
+	if nil != addedSnapshot {
+		snapshotList = append(snapshotList, addedSnapshot)
+	}
+
+	sort.Slice(snapshotList, func(i int, j int) bool { return snapshotList[i].id < snapshotList[j].id })
+
+	if 0 == len(snapshotList) {
+		snapshotIndexObjectNumber = 0
+		checkpointContainerHeaders[SnapshotIndexHeaderName] = []string{""}
+	} else {
+		snapshotIndexBuf = make([]byte, 0)
+		for _, snapshot = range snapshotList {
+			snapshotIndexBuf = append(snapshotIndexBuf, snapshot.indexLine()...)
+		}
+
+		snapshotIndexObjectNumber, err = volume.fetchNonceWhileLocked()
+		if nil != err {
+			return
+		}
+
+		chunkedPutContext, err = swiftclient.ObjectFetchChunkedPutContext(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(snapshotIndexObjectNumber))
+		if nil != err {
+			return
+		}
+		err = chunkedPutContext.SendChunk(snapshotIndexBuf)
+		if nil != err {
+			return
+		}
+		err = chunkedPutContext.Close()
+		if nil != err {
+			return
+		}
+
+		checkpointContainerHeaders[SnapshotIndexHeaderName] = []string{utils.Uint64ToHexStr(snapshotIndexObjectNumber)}
+	}
+
+	err = swiftclient.ContainerPost(volume.accountName, volume.checkpointContainerName, checkpointContainerHeaders)
+	if nil != err {
+		if 0 != snapshotIndexObjectNumber {
+			swiftclient.ObjectDeleteAsync(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(snapshotIndexObjectNumber), nil, nil)
+		}
+		return
+	}
+
+	if 0 != volume.snapshotIndexObjectNumber {
+		swiftclient.ObjectDeleteAsync(volume.accountName, volume.checkpointContainerName, utils.Uint64ToHexStr(volume.snapshotIndexObjectNumber), nil, nil)
+	}
+
+	volume.snapshotIndexObjectNumber = snapshotIndexObjectNumber
+
+	err = nil
+	return
+}
+
+// createSnapshotWhileLocked is called by checkpointDaemon() following a successful putCheckpoint() to retain it as a snapshot
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) createSnapshotWhileLocked(name string) (exportedSnapshot SnapshotStruct, err error) {
+	var (
+		ok       bool
+		snapshot *snapshotStruct
+	)
+
+	err = validateSnapshotName(name)
+	if nil != err {
+		return
+	}
+
+	_, ok = volume.snapshotMap[name]
+	if ok {
+		err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" already exists", utils.GetFnName(), name, volume.volumeName)
+		err = blunder.AddError(err, blunder.FileExistsError)
+		return
+	}
+
+	if 0 == volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber {
+		err = fmt.Errorf("%s: volume \"%v\" has no checkpoint to snapshot", utils.GetFnName(), volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	snapshot = &snapshotStruct{
+		volume:                  volume,
+		name:                    name,
+		creationTime:            time.Now(),
+		checkpointHeaderVersion: volume.checkpointHeaderVersion,
+		deleted:                 false,
+	}
+
+	snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber = volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber
+	snapshot.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength = volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectLength
+
+	snapshot.id, err = volume.fetchNonceWhileLocked()
+	if nil != err {
+		return
+	}
+
+	err = snapshot.load()
+	if nil != err {
+		return
+	}
+
+	err = volume.putSnapshotIndexWhileLocked(snapshot, nil)
+	if nil != err {
+		return
+	}
+
+	volume.snapshotMap[name] = snapshot
+
+	volume.invalidateSnapshotExclusiveBytesWhileLocked()
+
+	logger.Infof("Created snapshot \"%v\" (ID 0x%016X) of volume \"%v\"", name, snapshot.id, volume.volumeName)
+
+	exportedSnapshot = snapshot.export()
+
+	err = nil
+	return
+}
+
+// snapshotsPinObjectWhileLocked reports whether any snapshot (other than excludedSnapshot, if non-nil) retains objectNumber in the Checkpoint Container
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) snapshotsPinObjectWhileLocked(objectNumber uint64, excludedSnapshot *snapshotStruct) (pinned bool) {
+	for _, snapshot := range volume.snapshotMap {
+		if snapshot == excludedSnapshot {
+			continue
+		}
+		_, pinned = snapshot.pinnedObjectMap[objectNumber]
+		if pinned {
+			return
+		}
+	}
+
+	pinned = false
+	return
+}
+
+// snapshotsPinLogSegmentWhileLocked reports whether any snapshot (other than excludedSnapshot, if non-nil) references logSegmentNumber
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) snapshotsPinLogSegmentWhileLocked(logSegmentNumber uint64, excludedSnapshot *snapshotStruct) (pinned bool) {
+	var (
+		err error
+	)
+
+	for _, snapshot := range volume.snapshotMap {
+		if snapshot == excludedSnapshot {
+			continue
+		}
+		_, pinned, err = snapshot.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumber)
+		if nil != err {
+			// Err on the side of retaining the LogSegment
+			logger.ErrorfWithError(err, "Unable to determine if snapshot \"%v\" of volume \"%v\" references LogSegment 0x%016X", snapshot.name, volume.volumeName, logSegmentNumber)
+			pinned = true
+		}
+		if pinned {
+			return
+		}
+	}
+
+	pinned = false
+	return
+}
+
+// checkpointObjectInUseWhileLocked reports whether objectNumber in the Checkpoint Container is (or may yet be) referenced by the live volume
+//
+// Note: Caller must hold volume.Lock()
+func (volume *volumeStruct) checkpointObjectInUseWhileLocked(objectNumber uint64) (inUse bool) {
+	if objectNumber == volume.checkpointHeader.CheckpointObjectTrailerV2StructObjectNumber {
+		inUse = true
+		return
+	}
+
+	if (nil != volume.checkpointChunkedPutContext) && (objectNumber == volume.checkpointChunkedPutContextObjectNumber) {
+		inUse = true
+		return
+	}
+
+	// Note that, if present with zero bytes used, the next putCheckpoint() will delete the object
+
+	_, inUse = volume.inodeRecBPlusTreeLayout[objectNumber]
+	if inUse {
+		return
+	}
+	_, inUse = volume.logSegmentRecBPlusTreeLayout[objectNumber]
+	if inUse {
+		return
+	}
+	_, inUse = volume.bPlusTreeObjectBPlusTreeLayout[objectNumber]
+	if inUse {
+		return
+	}
+	_, inUse = volume.journalRecBPlusTreeLayout[objectNumber]
+	if inUse {
+		return
+	}
+	_, inUse = volume.fingerprintRecBPlusTreeLayout[objectNumber]
+
+	return
+}
+
+func (volume *volumeStruct) CreateSnapshot(name string) (snapshot SnapshotStruct, err error) {
+	var (
+		checkpointRequest checkpointRequestStruct
+	)
+
+	err = validateSnapshotName(name)
+	if nil != err {
+		return
+	}
+
+	checkpointRequest.exitOnCompletion = false
+	checkpointRequest.createSnapshotName = name
+
+	checkpointRequest.waitGroup.Add(1)
+	volume.checkpointRequestChan <- &checkpointRequest
+	checkpointRequest.waitGroup.Wait()
+
+	snapshot = checkpointRequest.snapshot
+	err = checkpointRequest.err
+
+	return
+}
+
+func (volume *volumeStruct) DeleteSnapshot(name string) (err error) {
+	var (
+		checkpointContainerHeaders map[string][]string
+		containerNameAsValue       sortedmap.Value
+		index                      int
+		logSegmentNumberAsKey      sortedmap.Key
+		numLogSegments             int
+		objectNumber               uint64
+		ok                         bool
+		referencedByClone          bool
+		snapshot                   *snapshotStruct
+	)
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapshot, ok = volume.snapshotMap[name]
+	if !ok {
+		err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" not found", utils.GetFnName(), name, volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	err = volume.putSnapshotIndexWhileLocked(nil, snapshot)
+	if nil != err {
+		return
+	}
+
+	// A snapshot referenced by a clone must be retained (see FormatClone())
+
+	checkpointContainerHeaders, err = swiftclient.ContainerHead(volume.accountName, volume.checkpointContainerName)
+	if nil == err {
+		referencedByClone, err = cloneReferencesSnapshot(checkpointContainerHeaders, snapshot.id)
+	}
+	if (nil != err) || referencedByClone {
+		restoreErr := volume.putSnapshotIndexWhileLocked(nil, nil)
+		if nil != restoreErr {
+			logger.FatalfWithError(restoreErr, "Unable to restore snapshot \"%v\" of volume \"%v\"", name, volume.volumeName)
+		}
+
+		if nil == err {
+			err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" is referenced by a clone", utils.GetFnName(), name, volume.volumeName)
+			err = blunder.AddError(err, blunder.DevBusyError)
+		}
+		return
+	}
+
+	delete(volume.snapshotMap, name)
+
+	snapshot.deleted = true
+
+	volume.invalidateSnapshotExclusiveBytesWhileLocked()
+	volume.snapshotExclusiveBytesCond.Broadcast() // wake any FetchSnapshotExclusiveBytes() awaiting snapshot
+
+	// Delete the LogSegments referenced only by this snapshot
+
+	numLogSegments, err = snapshot.logSegmentRecWrapper.bPlusTree.Len()
+	if nil != err {
+		return
+	}
+
+	for index = 0; index < numLogSegments; index++ {
+		logSegmentNumberAsKey, containerNameAsValue, ok, err = snapshot.logSegmentRecWrapper.bPlusTree.GetByIndex(index)
+		if nil != err {
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" logSegmentRec B+Tree index %v not found", utils.GetFnName(), name, volume.volumeName, index)
+			return
+		}
+
+		_, ok, err = volume.logSegmentRecWrapper.bPlusTree.GetByKey(logSegmentNumberAsKey)
+		if nil != err {
+			return
+		}
+		if ok || volume.snapshotsPinLogSegmentWhileLocked(logSegmentNumberAsKey.(uint64), nil) || volume.logSegmentIsSharedWhileLocked(logSegmentNumberAsKey.(uint64)) {
+			continue
+		}
+
+		swiftclient.ObjectDeleteAsync(
+			volume.accountName,
+			string(containerNameAsValue.([]byte)),
+			utils.Uint64ToHexStr(logSegmentNumberAsKey.(uint64)),
+			nil,
+			nil)
+	}
+
+	// Delete the Checkpoint Container objects referenced only by this snapshot
+
+	for objectNumber = range snapshot.pinnedObjectMap {
+		if volume.checkpointObjectInUseWhileLocked(objectNumber) || volume.snapshotsPinObjectWhileLocked(objectNumber, nil) {
+			continue
+		}
+
+		swiftclient.ObjectDeleteAsync(
+			volume.accountName,
+			volume.checkpointContainerName,
+			utils.Uint64ToHexStr(objectNumber),
+			nil,
+			nil)
+	}
+
+	logger.Infof("Deleted snapshot \"%v\" (ID 0x%016X) of volume \"%v\"", name, snapshot.id, volume.volumeName)
+
+	err = nil
+	return
+}
+
+func (volume *volumeStruct) FetchSnapshotList() (snapshotList []SnapshotStruct) {
+	volume.Lock()
+
+	snapshotList = make([]SnapshotStruct, 0, len(volume.snapshotMap))
+
+	for _, snapshot := range volume.snapshotMap {
+		snapshotList = append(snapshotList, snapshot.export())
+	}
+
+	volume.Unlock()
+
+	sort.Slice(snapshotList, func(i int, j int) bool { return snapshotList[i].ID < snapshotList[j].ID })
+
+	return
+}
+
+func (volume *volumeStruct) FetchSnapshotVolumeHandle(name string) (snapshotVolumeHandle VolumeHandle, err error) {
+	volume.Lock()
+	defer volume.Unlock()
+
+	snapshot, ok := volume.snapshotMap[name]
+	if !ok {
+		err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" not found", utils.GetFnName(), name, volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	snapshotVolumeHandle = snapshot
+
+	err = nil
+	return
+}
+
+func (volume *volumeStruct) SnapshotPinsLogSegment(logSegmentNumber uint64) (pinned bool) {
+	volume.Lock()
+	pinned = volume.snapshotsPinLogSegmentWhileLocked(logSegmentNumber, nil)
+	volume.Unlock()
+	return
+}
+
+// A snapshotStruct is also a (read-only) VolumeHandle... all methods modifying it return a blunder.ReadOnlyError
+
+func snapshotReadOnlyError(fnName string, snapshot *snapshotStruct) (err error) {
+	err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" is read-only", fnName, snapshot.name, snapshot.volume.volumeName)
+	err = blunder.AddError(err, blunder.ReadOnlyError)
+	return
+}
+
+// getWhileLocked fetches a copy of the value for key in the specified B+Tree of the snapshot
+//
+// Note: Caller must hold snapshot.volume.Lock()
+func (snapshot *snapshotStruct) getWhileLocked(bPlusTree sortedmap.BPlusTree, key uint64, treeName string) (value []byte, ok bool, err error) {
+	var (
+		valueAsValue sortedmap.Value
+	)
+
+	if snapshot.deleted {
+		err = fmt.Errorf("snapshot \"%v\" of volume \"%v\" has been deleted", snapshot.name, snapshot.volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	valueAsValue, ok, err = bPlusTree.GetByKey(key)
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("0x%016X not found in snapshot \"%v\" of volume \"%v\" %v B+Tree", key, snapshot.name, snapshot.volume.volumeName, treeName)
+		return
+	}
+
+	value = make([]byte, len(valueAsValue.([]byte)))
+	copy(value, valueAsValue.([]byte))
+
+	err = nil
+	return
+}
+
+func (snapshot *snapshotStruct) FetchNextCheckPointDoneWaitGroup() (wg *sync.WaitGroup) {
+	wg = &sync.WaitGroup{} // Nothing is ever checkpointed... so there is nothing to wait for
+	return
+}
+
+func (snapshot *snapshotStruct) FetchNonce() (nonce uint64, err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) GetInodeRec(inodeNumber uint64) (value []byte, ok bool, err error) {
+	snapshot.volume.Lock()
+	value, ok, err = snapshot.getWhileLocked(snapshot.inodeRecWrapper.bPlusTree, inodeNumber, "inodeRec")
+	snapshot.volume.Unlock()
+	return
+}
+
+func (snapshot *snapshotStruct) PutInodeRec(inodeNumber uint64, value []byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) PutInodeRecs(inodeNumbers []uint64, values [][]byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DeleteInodeRec(inodeNumber uint64) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchInodeNumbers(afterInodeNumber uint64, maxInodeNumbers uint64) (inodeNumbers []uint64, err error) {
+	snapshot.volume.Lock()
+	defer snapshot.volume.Unlock()
+
+	if snapshot.deleted {
+		err = fmt.Errorf("snapshot \"%v\" of volume \"%v\" has been deleted", snapshot.name, snapshot.volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	inodeNumbers, err = fetchInodeNumbersWhileLocked(snapshot.inodeRecWrapper.bPlusTree, afterInodeNumber, maxInodeNumbers)
+	return
+}
+
+func (snapshot *snapshotStruct) GetLogSegmentRec(logSegmentNumber uint64) (value []byte, err error) {
+	snapshot.volume.Lock()
+	value, _, err = snapshot.getWhileLocked(snapshot.logSegmentRecWrapper.bPlusTree, logSegmentNumber, "logSegmentRec")
+	snapshot.volume.Unlock()
+	return
+}
+
+func (snapshot *snapshotStruct) PutLogSegmentRec(logSegmentNumber uint64, value []byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DeleteLogSegmentRec(logSegmentNumber uint64) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) GetBPlusTreeObject(objectNumber uint64) (value []byte, err error) {
+	snapshot.volume.Lock()
+	value, _, err = snapshot.getWhileLocked(snapshot.bPlusTreeObjectWrapper.bPlusTree, objectNumber, "bPlusTreeObject")
+	snapshot.volume.Unlock()
+	return
+}
+
+func (snapshot *snapshotStruct) PutBPlusTreeObject(objectNumber uint64, value []byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DeleteBPlusTreeObject(objectNumber uint64) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) PutJournalRec(journalNonce uint64, value []byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchJournalRecs(afterNonce uint64, maxRecs uint64) (journalNonces []uint64, values [][]byte, err error) {
+	snapshot.volume.Lock()
+	defer snapshot.volume.Unlock()
+
+	if snapshot.deleted {
+		err = fmt.Errorf("snapshot \"%v\" of volume \"%v\" has been deleted", snapshot.name, snapshot.volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	journalNonces, values, err = fetchJournalRecsWhileLocked(snapshot.journalRecWrapper.bPlusTree, snapshot.journalRecTrimmedToNonce, afterNonce, maxRecs)
+	if blunder.Is(err, blunder.JournalTrimmedError) {
+		err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" %v", utils.GetFnName(), snapshot.name, snapshot.volume.volumeName, err)
+		err = blunder.AddError(err, blunder.JournalTrimmedError)
+	}
+
+	return
+}
+
+func (snapshot *snapshotStruct) TrimJournalRecs(trimToNonce uint64) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) GetFingerprintRec(fingerprint uint64) (value []byte, ok bool, err error) {
+	snapshot.volume.Lock()
+	defer snapshot.volume.Unlock()
+
+	if snapshot.deleted {
+		err = fmt.Errorf("snapshot \"%v\" of volume \"%v\" has been deleted", snapshot.name, snapshot.volume.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	value, ok, err = getFingerprintRecWhileLocked(snapshot.fingerprintRecWrapper.bPlusTree, fingerprint)
+	return
+}
+
+func (snapshot *snapshotStruct) PutFingerprintRec(fingerprint uint64, value []byte) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DeleteFingerprintRec(fingerprint uint64) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DoCheckpoint() (err error) {
+	err = nil // Nothing to checkpoint
+	return
+}
+
+func (snapshot *snapshotStruct) FetchLayoutReport(treeType BPlusTreeType) (layoutReport sortedmap.LayoutReport, err error) {
+	snapshot.volume.Lock()
+	defer snapshot.volume.Unlock()
+
+	switch treeType {
+	case InodeRecBPlusTree:
+		layoutReport, err = snapshot.inodeRecWrapper.bPlusTree.FetchLayoutReport()
+	case LogSegmentRecBPlusTree:
+		layoutReport, err = snapshot.logSegmentRecWrapper.bPlusTree.FetchLayoutReport()
+	case BPlusTreeObjectBPlusTree:
+		layoutReport, err = snapshot.bPlusTreeObjectWrapper.bPlusTree.FetchLayoutReport()
+	case JournalRecBPlusTree:
+		layoutReport, err = snapshot.journalRecWrapper.bPlusTree.FetchLayoutReport()
+	case FingerprintRecBPlusTree:
+		layoutReport, err = snapshot.fingerprintRecWrapper.bPlusTree.FetchLayoutReport()
+	default:
+		err = fmt.Errorf("FetchLayoutReport(treeType %d): bad tree type.", treeType)
+	}
+
+	return
+}
+
+func (snapshot *snapshotStruct) CreateSnapshot(name string) (exportedSnapshot SnapshotStruct, err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) DeleteSnapshot(name string) (err error) {
+	err = snapshotReadOnlyError(utils.GetFnName(), snapshot)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchSnapshotList() (snapshotList []SnapshotStruct) {
+	snapshotList = make([]SnapshotStruct, 0)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchSnapshotExclusiveBytes(name string) (exclusiveBytes uint64, err error) {
+	err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" has no snapshots", utils.GetFnName(), snapshot.name, snapshot.volume.volumeName)
+	err = blunder.AddError(err, blunder.NotFoundError)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchSnapshotVolumeHandle(name string) (snapshotVolumeHandle VolumeHandle, err error) {
+	err = fmt.Errorf("%s: snapshot \"%v\" of volume \"%v\" has no snapshots", utils.GetFnName(), snapshot.name, snapshot.volume.volumeName)
+	err = blunder.AddError(err, blunder.NotFoundError)
+	return
+}
+
+func (snapshot *snapshotStruct) SnapshotPinsLogSegment(logSegmentNumber uint64) (pinned bool) {
+	pinned = snapshot.volume.SnapshotPinsLogSegment(logSegmentNumber)
+	return
+}
+
+func (snapshot *snapshotStruct) FetchCheckpointHealth() (checkpointHealth CheckpointHealthStruct) {
+	checkpointHealth = snapshot.volume.FetchCheckpointHealth()
+	return
+}
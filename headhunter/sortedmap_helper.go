@@ -55,6 +55,16 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) DumpValue(value sortedmap.Value)
 }
 
 func (bPlusTreeWrapper *bPlusTreeWrapperStruct) GetNode(objectNumber uint64, objectOffset uint64, objectLength uint64) (nodeByteSlice []byte, err error) {
+	volume := bPlusTreeWrapper.volume
+
+	// Note that a snapshot's B+Tree nodes (which may be fetched without holding volume.Lock()) are never in such an object
+
+	if !bPlusTreeWrapper.readOnly && (nil != volume.checkpointFailedChunkedPutContext) && (objectNumber == volume.checkpointFailedChunkedPutContextObjectNumber) {
+		// Node was sent to a checkpoint object that has yet to be successfully replayed (see recovery.go)
+		nodeByteSlice, err = volume.checkpointFailedChunkedPutContext.Read(objectOffset, objectLength)
+		return
+	}
+
 	nodeByteSlice, err =
 		swiftclient.ObjectGet(
 			bPlusTreeWrapper.volume.accountName,
@@ -71,6 +81,11 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) PutNode(nodeByteSlice []byte) (o
 		ok        bool
 	)
 
+	if bPlusTreeWrapper.readOnly {
+		err = fmt.Errorf("Logic error: bPlusTreeWrapper.PutNode() called for read-only (snapshot) B+Tree")
+		return
+	}
+
 	err = bPlusTreeWrapper.volume.openCheckpointChunkedPutContextIfNecessary()
 	if nil != err {
 		return
@@ -116,6 +131,22 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) PutNode(nodeByteSlice []byte) (o
 			bPlusTreeWrapper.volume.bPlusTreeObjectBPlusTreeLayout[objectNumber] = uint64(len(nodeByteSlice))
 		}
 
+	case journalRecBPlusTreeWrapperType:
+		bytesUsed, ok = bPlusTreeWrapper.volume.journalRecBPlusTreeLayout[objectNumber]
+		if ok {
+			bPlusTreeWrapper.volume.journalRecBPlusTreeLayout[objectNumber] = bytesUsed + uint64(len(nodeByteSlice))
+		} else {
+			bPlusTreeWrapper.volume.journalRecBPlusTreeLayout[objectNumber] = uint64(len(nodeByteSlice))
+		}
+
+	case fingerprintRecBPlusTreeWrapperType:
+		bytesUsed, ok = bPlusTreeWrapper.volume.fingerprintRecBPlusTreeLayout[objectNumber]
+		if ok {
+			bPlusTreeWrapper.volume.fingerprintRecBPlusTreeLayout[objectNumber] = bytesUsed + uint64(len(nodeByteSlice))
+		} else {
+			bPlusTreeWrapper.volume.fingerprintRecBPlusTreeLayout[objectNumber] = uint64(len(nodeByteSlice))
+		}
+
 	default:
 		err = fmt.Errorf("Logic error: bPlusTreeWrapper.PutNode() called for invalid wrapperType: %v", bPlusTreeWrapper.wrapperType)
 		panic(err)
@@ -132,6 +163,11 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) DiscardNode(objectNumber uint64,
 		ok        bool
 	)
 
+	if bPlusTreeWrapper.readOnly {
+		err = fmt.Errorf("Logic error: bPlusTreeWrapper.DiscardNode() called for read-only (snapshot) B+Tree")
+		return
+	}
+
 	switch bPlusTreeWrapper.wrapperType {
 
 	case inodeRecBPlusTreeWrapperType:
@@ -185,6 +221,40 @@ func (bPlusTreeWrapper *bPlusTreeWrapperStruct) DiscardNode(objectNumber uint64,
 			logger.ErrorfWithError(err, "disk corruption or logic error")
 		}
 
+	case journalRecBPlusTreeWrapperType:
+		logger.Tracef("headhunter.DiscardNode(): JournalRec Tree Object %016X  offset %d  length %d",
+			objectNumber, objectOffset, objectLength)
+		bytesUsed, ok = bPlusTreeWrapper.volume.journalRecBPlusTreeLayout[objectNumber]
+		if ok {
+			if bytesUsed < objectLength {
+				err = fmt.Errorf("Logic error: [journalRecBPlusTreeWrapperType] bPlusTreeWrapper.DiscardNode() called to dereference too many bytes in objectNumber 0x%016X", objectNumber)
+				logger.ErrorWithError(err, "bad error")
+			} else {
+				err = nil
+				bPlusTreeWrapper.volume.journalRecBPlusTreeLayout[objectNumber] = bytesUsed - objectLength
+			}
+		} else {
+			err = fmt.Errorf("Logic error: [journalRecBPlusTreeWrapperType] bPlusTreeWrapper.DiscardNode() called referencing invalid objectNumber: 0x%016X", objectNumber)
+			logger.ErrorfWithError(err, "disk corruption or logic error")
+		}
+
+	case fingerprintRecBPlusTreeWrapperType:
+		logger.Tracef("headhunter.DiscardNode(): FingerprintRec Tree Object %016X  offset %d  length %d",
+			objectNumber, objectOffset, objectLength)
+		bytesUsed, ok = bPlusTreeWrapper.volume.fingerprintRecBPlusTreeLayout[objectNumber]
+		if ok {
+			if bytesUsed < objectLength {
+				err = fmt.Errorf("Logic error: [fingerprintRecBPlusTreeWrapperType] bPlusTreeWrapper.DiscardNode() called to dereference too many bytes in objectNumber 0x%016X", objectNumber)
+				logger.ErrorWithError(err, "bad error")
+			} else {
+				err = nil
+				bPlusTreeWrapper.volume.fingerprintRecBPlusTreeLayout[objectNumber] = bytesUsed - objectLength
+			}
+		} else {
+			err = fmt.Errorf("Logic error: [fingerprintRecBPlusTreeWrapperType] bPlusTreeWrapper.DiscardNode() called referencing invalid objectNumber: 0x%016X", objectNumber)
+			logger.ErrorfWithError(err, "disk corruption or logic error")
+		}
+
 	default:
 		err = fmt.Errorf("Logic error: bPlusTreeWrapper.DiscardNode() called for invalid wrapperType: %v", bPlusTreeWrapper.wrapperType)
 		logger.ErrorfWithError(err, "this is BIG error ...")
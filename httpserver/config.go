@@ -65,6 +65,119 @@ type fsckCompletedJobWithErrorStruct struct {
 	Error     string `json:"errors"`
 }
 
+// inodeFragmentationReportStruct describes the JSON-encoded inode fragmentation GET body
+type inodeFragmentationReportStruct struct {
+	InodeNumber       uint64 `json:"inode number"`
+	NumberOfFragments uint64 `json:"number of fragments"`
+	BytesInFragments  uint64 `json:"bytes in fragments"`
+	BytesTrapped      uint64 `json:"bytes trapped"`
+}
+
+// defragJobStatusStruct describes the JSON-encoded defrag-job GET body
+type defragJobStatusStruct struct {
+	State              string `json:"state"`
+	PassesCompleted    uint64 `json:"passes completed"`
+	PassStartTime      string `json:"pass start time"`
+	PassEndTime        string `json:"pass end time"`
+	InodesScanned      uint64 `json:"inodes scanned"`
+	InodesOptimized    uint64 `json:"inodes optimized"`
+	BytesOptimized     uint64 `json:"bytes optimized"`
+	CurrentInodeNumber uint64 `json:"current inode number"`
+}
+
+// snapshotStatusStruct describes each element of the JSON-encoded snapshot GET body
+type snapshotStatusStruct struct {
+	ID                     uint64 `json:"id"`
+	Name                   string `json:"name"`
+	CreationTime           string `json:"creation time"`
+	CheckpointObjectNumber uint64 `json:"checkpoint object number"`
+	ExclusiveBytes         uint64 `json:"exclusive bytes"`
+}
+
+// watchEventStatusStruct describes each element of the JSON-encoded watch GET body
+type watchEventStatusStruct struct {
+	Type           string `json:"type"`
+	DirInodeNumber uint64 `json:"dir inode number"`
+	Basename       string `json:"basename"`
+	InodeNumber    uint64 `json:"inode number"`
+	Cookie         uint64 `json:"cookie"`
+}
+
+// journalStatusStruct describes the JSON-encoded journal GET body
+type journalStatusStruct struct {
+	Records []journalRecStatusStruct `json:"records"`
+	Cursor  string                   `json:"cursor"`
+}
+
+// journalRecStatusStruct describes each element of journalStatusStruct.Records
+type journalRecStatusStruct struct {
+	Nonce             uint64 `json:"nonce"`
+	Op                string `json:"op"`
+	InodeNumber       uint64 `json:"inode number"`
+	ParentInodeNumber uint64 `json:"parent inode number"`
+	Basename          string `json:"basename"`
+	Time              string `json:"time"`
+}
+
+// quotaStatusStruct describes each element of the JSON-encoded quota GET body
+type quotaStatusStruct struct {
+	Name                  string `json:"name"`
+	Type                  string `json:"type"`
+	ID                    uint64 `json:"id"`
+	Path                  string `json:"path"`
+	UsedBytes             uint64 `json:"used bytes"`
+	UsedInodes            uint64 `json:"used inodes"`
+	SoftBytes             uint64 `json:"soft bytes"`
+	HardBytes             uint64 `json:"hard bytes"`
+	SoftInodes            uint64 `json:"soft inodes"`
+	HardInodes            uint64 `json:"hard inodes"`
+	GracePeriod           string `json:"grace period"`
+	BytesGraceExpiration  string `json:"bytes grace expiration"`
+	InodesGraceExpiration string `json:"inodes grace expiration"`
+}
+
+// projectStatusStruct describes each element of the JSON-encoded project GET body
+type projectStatusStruct struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	InodeNumber uint64 `json:"inode number"`
+	UsedBytes   uint64 `json:"used bytes"`
+	UsedInodes  uint64 `json:"used inodes"`
+}
+
+// trashEntryStatusStruct describes each element of the JSON-encoded trash GET body
+type trashEntryStatusStruct struct {
+	Name              string `json:"name"`
+	InodeNumber       uint64 `json:"inode number"`
+	InodeType         string `json:"inode type"`
+	ParentInodeNumber uint64 `json:"parent inode number"`
+	Basename          string `json:"basename"`
+	DeletionTime      string `json:"deletion time"`
+	ExpirationTime    string `json:"expiration time"`
+}
+
+// checkpointHealthStatusStruct describes the JSON-encoded health GET body (for each volume if requesting /health)
+type checkpointHealthStatusStruct struct {
+	State               string `json:"state"`
+	ConsecutiveFailures uint64 `json:"consecutive failures"`
+	LastSuccessTime     string `json:"last success time"`
+	LastFailureTime     string `json:"last failure time"`
+	LastFailureError    string `json:"last failure error"`
+	NextRetryTime       string `json:"next retry time"`
+}
+
+// mountStatusStruct describes each active jrpcfs MountID in the JSON-encoded mounts GET body
+type mountStatusStruct struct {
+	MountID          uint64 `json:"mount id"`
+	VolumeName       string `json:"volume"`
+	ClientAddr       string `json:"client address"`
+	AuthUserID       uint64 `json:"auth user id"`
+	AuthGroupID      uint64 `json:"auth group id"`
+	CreateTime       string `json:"create time"`
+	Age              string `json:"age"`
+	LastActivityTime string `json:"last activity time"`
+}
+
 type volumeStruct struct {
 	sync.Mutex
 	name             string
@@ -6,14 +6,18 @@ import (
 	"fmt"
 	"html"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/fs"
 	"github.com/swiftstack/ProxyFS/halter"
 	"github.com/swiftstack/ProxyFS/headhunter"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/jrpcfs"
 	"github.com/swiftstack/ProxyFS/logger"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/utils"
@@ -36,6 +40,8 @@ func (h httpRequestHandler) ServeHTTP(responseWriter http.ResponseWriter, reques
 			doGet(responseWriter, request)
 		case http.MethodPost:
 			doPost(responseWriter, request)
+		case http.MethodDelete:
+			doDelete(responseWriter, request)
 		default:
 			responseWriter.WriteHeader(http.StatusMethodNotAllowed)
 		}
@@ -57,6 +63,10 @@ func doGet(responseWriter http.ResponseWriter, request *http.Request) {
 		doGetOfConfig(responseWriter, request)
 	case "/metrics" == path:
 		doGetOfMetrics(responseWriter, request)
+	case "/health" == path:
+		doGetOfHealth(responseWriter, request)
+	case "/mounts" == path:
+		doGetOfMounts(responseWriter, request)
 	case "/arm-disarm-trigger" == path:
 		doGetOfArmDisarmTrigger(responseWriter, request)
 	case strings.HasPrefix(request.URL.Path, "/trigger"):
@@ -81,6 +91,10 @@ func doGetOfIndexDotHTML(responseWriter http.ResponseWriter, request *http.Reque
 	_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
 	_, _ = responseWriter.Write(utils.StringToByteSlice("    <a href=\"/metrics\">StatsD/Prometheus Page</a>\n"))
 	_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
+	_, _ = responseWriter.Write(utils.StringToByteSlice("    <a href=\"/health\">Health Page</a>\n"))
+	_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
+	_, _ = responseWriter.Write(utils.StringToByteSlice("    <a href=\"/mounts\">Mounts Page</a>\n"))
+	_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
 	_, _ = responseWriter.Write(utils.StringToByteSlice("    Trigger Pages:\n"))
 	_, _ = responseWriter.Write(utils.StringToByteSlice("      <a href=\"/arm-disarm-trigger\">Arm/Disarm</a>\n"))
 	_, _ = responseWriter.Write(utils.StringToByteSlice("      <a href=\"/trigger\">All</a>\n"))
@@ -212,6 +226,160 @@ func doGetOfMetrics(responseWriter http.ResponseWriter, request *http.Request) {
 	sortedTwoColumnResponseWriter(statsLLRB, responseWriter)
 }
 
+func doGetOfHealth(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
+		allHealthy                bool
+		checkpointHealthStatus    checkpointHealthStatusStruct
+		checkpointHealthStatusMap map[string]checkpointHealthStatusStruct
+		err                       error
+		healthJSON                bytes.Buffer
+		healthJSONPacked          []byte
+		healthy                   bool
+		ok                        bool
+		volume                    *volumeStruct
+		volumeAsValue             sortedmap.Value
+		volumeListIndex           int
+		volumeListLen             int
+	)
+
+	volumeListLen, err = globals.volumeLLRB.Len()
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	allHealthy = true
+	checkpointHealthStatusMap = make(map[string]checkpointHealthStatusStruct)
+
+	for volumeListIndex = 0; volumeListIndex < volumeListLen; volumeListIndex++ {
+		_, volumeAsValue, ok, err = globals.volumeLLRB.GetByIndex(volumeListIndex)
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+		if !ok {
+			err = fmt.Errorf("httpserver.doGetOfHealth() indexing globals.volumeLLRB failed")
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		volume = volumeAsValue.(*volumeStruct)
+
+		checkpointHealthStatus, healthy = fetchCheckpointHealthStatus(volume)
+		if !healthy {
+			allHealthy = false
+		}
+
+		checkpointHealthStatusMap[volume.name] = checkpointHealthStatus
+	}
+
+	healthJSONPacked, err = json.Marshal(checkpointHealthStatusMap)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	// Report StatusServiceUnavailable if any volume is degraded (e.g. so that load balancers may steer clients elsewhere)
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	if allHealthy {
+		responseWriter.WriteHeader(http.StatusOK)
+	} else {
+		responseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.Indent(&healthJSON, healthJSONPacked, "", "\t")
+	_, _ = responseWriter.Write(healthJSON.Bytes())
+	_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+}
+
+func doGetOfMounts(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
+		err                     error
+		formatResponseAsJSON    bool
+		formatResponseCompactly bool
+		mountInfo               jrpcfs.MountInfoStruct
+		mountInfoList           []jrpcfs.MountInfoStruct
+		mountStatusList         []mountStatusStruct
+		mountsJSON              bytes.Buffer
+		mountsJSONPacked        []byte
+		now                     time.Time
+		ok                      bool
+		paramList               []string
+	)
+
+	formatResponseAsJSON = ("application/json" == request.Header.Get("Accept"))
+	if formatResponseAsJSON {
+		paramList, ok = request.URL.Query()["compact"]
+		formatResponseCompactly = (ok && (0 < len(paramList)) && (("true" == paramList[0]) || ("1" == paramList[0])))
+	}
+
+	now = time.Now()
+	mountInfoList = jrpcfs.FetchMountInfoList()
+	mountStatusList = make([]mountStatusStruct, 0, len(mountInfoList))
+
+	for _, mountInfo = range mountInfoList {
+		mountStatusList = append(mountStatusList, mountStatusStruct{
+			MountID:          mountInfo.MountID,
+			VolumeName:       mountInfo.VolumeName,
+			ClientAddr:       mountInfo.ClientAddr,
+			AuthUserID:       mountInfo.AuthUserID,
+			AuthGroupID:      mountInfo.AuthGroupID,
+			CreateTime:       mountInfo.CreateTime.Format(time.RFC3339),
+			Age:              now.Sub(mountInfo.CreateTime).Truncate(time.Second).String(),
+			LastActivityTime: mountInfo.LastActivityTime.Format(time.RFC3339),
+		})
+	}
+
+	if formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		mountsJSONPacked, err = json.Marshal(mountStatusList)
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		if formatResponseCompactly {
+			_, _ = responseWriter.Write(mountsJSONPacked)
+		} else {
+			json.Indent(&mountsJSON, mountsJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(mountsJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <title>Mounts</title>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>MountID</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Volume</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Client Address</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Auth UserID</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Auth GroupID</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Age</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Last Activity Time</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		for _, mountStatus := range mountStatusList {
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", mountStatus.MountID)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td><a href=\"/volume/%s\">%s</a></td>\n", mountStatus.VolumeName, html.EscapeString(mountStatus.VolumeName))))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", html.EscapeString(mountStatus.ClientAddr))))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", mountStatus.AuthUserID)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", mountStatus.AuthGroupID)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", mountStatus.Age)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", mountStatus.LastActivityTime)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
+
 func doGetOfArmDisarmTrigger(responseWriter http.ResponseWriter, request *http.Request) {
 	var (
 		availableTriggers []string
@@ -423,10 +591,21 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	case 1:
 		// Form: /volume
 	case 3:
+		// Form: /volume/<volume-name/defrag-job
 		// Form: /volume/<volume-name/fsck-job
+		// Form: /volume/<volume-name/health
+		// Form: /volume/<volume-name/journal
 		// Form: /volume/<volume-name/layout-report
+		// Form: /volume/<volume-name/project
+		// Form: /volume/<volume-name/quota
+		// Form: /volume/<volume-name/snapshot
+		// Form: /volume/<volume-name/trash
 	case 4:
 		// Form: /volume/<volume-name/fsck-job/<job-id>
+		// Form: /volume/<volume-name/snapshot/<snapshot-name>
+		// Form: /volume/<volume-name/watch/<watch-id>
+	case 5:
+		// Form: /volume/<volume-name/inode/<inode-number>/fragmentation
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -516,7 +695,7 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	// If we reach here, numPathParts is either 3 or 4
+	// If we reach here, numPathParts is 3, 4, or 5
 	volumeName = pathSplit[2]
 
 	volumeAsValue, ok, err = globals.volumeLLRB.GetByKey(volumeName)
@@ -529,6 +708,11 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	}
 	requestState.volume = volumeAsValue.(*volumeStruct)
 
+	if (5 == numPathParts) && ("inode" != pathSplit[3]) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	requestState.pathSplit = pathSplit
 	requestState.numPathParts = numPathParts
 	requestState.formatResponseAsJSON = formatResponseAsJSON
@@ -536,12 +720,39 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 
 	switch pathSplit[3] {
 
+	case "defrag-job":
+		doDefragJob(responseWriter, request, requestState)
+
 	case "fsck-job":
 		doFsckJob(responseWriter, request, requestState)
 
 	case "layout-report":
 		doLayoutReport(responseWriter, request, requestState)
 
+	case "inode":
+		doInode(responseWriter, request, requestState)
+
+	case "snapshot":
+		doSnapshot(responseWriter, request, requestState)
+
+	case "health":
+		doHealth(responseWriter, request, requestState)
+
+	case "watch":
+		doWatch(responseWriter, request, requestState)
+
+	case "journal":
+		doJournal(responseWriter, request, requestState)
+
+	case "project":
+		doProject(responseWriter, request, requestState)
+
+	case "quota":
+		doQuota(responseWriter, request, requestState)
+
+	case "trash":
+		doTrash(responseWriter, request, requestState)
+
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -549,113 +760,528 @@ func doGetOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	return
 }
 
-func doFsckJob(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+func checkpointHealthStateString(checkpointHealthState headhunter.CheckpointHealthState) (checkpointHealthStateString string) {
+	switch checkpointHealthState {
+	case headhunter.CheckpointHealthy:
+		checkpointHealthStateString = "Healthy"
+	case headhunter.CheckpointDegraded:
+		checkpointHealthStateString = "Degraded"
+	default:
+		checkpointHealthStateString = fmt.Sprintf("Unknown (%v)", checkpointHealthState)
+	}
+	return
+}
+
+func checkpointHealthTimeString(checkpointHealthTime time.Time) (checkpointHealthTimeString string) {
+	if checkpointHealthTime.IsZero() {
+		checkpointHealthTimeString = ""
+	} else {
+		checkpointHealthTimeString = checkpointHealthTime.String()
+	}
+	return
+}
+
+func fetchCheckpointHealthStatus(volume *volumeStruct) (checkpointHealthStatus checkpointHealthStatusStruct, healthy bool) {
 	var (
-		err                       error
-		pathSplit                 []string
-		numPathParts              int
-		formatResponseAsJSON      bool
-		formatResponseCompactly   bool
-		fsckCompletedJobNoError   fsckCompletedJobNoErrorStruct
-		fsckCompletedJobWithError fsckCompletedJobWithErrorStruct
-		fsckHaltedJob             fsckHaltedJobStruct
-		fsckInactive              bool
-		fsckJob                   *fsckJobStruct
-		fsckJobAsValue            sortedmap.Value
-		fsckJobID                 uint64
-		fsckJobIDAsKey            sortedmap.Key
-		fsckJobsIDListJSON        bytes.Buffer
-		fsckJobsIDListJSONPacked  []byte
-		fsckJobStatusJSON         bytes.Buffer
-		fsckJobStatusJSONPacked   []byte
-		fsckJobsCount             int
-		fsckJobsIDList            []uint64
-		fsckJobsIDListIndex       int
-		fsckJobsIndex             int
-		fsckRunningJob            fsckRunningJobStruct
-		ok                        bool
-		volume                    *volumeStruct
-		volumeName                string
+		checkpointHealth headhunter.CheckpointHealthStruct
+	)
+
+	checkpointHealth = volume.headhunterHandle.FetchCheckpointHealth()
+
+	checkpointHealthStatus.State = checkpointHealthStateString(checkpointHealth.State)
+	checkpointHealthStatus.ConsecutiveFailures = checkpointHealth.ConsecutiveFailures
+	checkpointHealthStatus.LastSuccessTime = checkpointHealthTimeString(checkpointHealth.LastSuccessTime)
+	checkpointHealthStatus.LastFailureTime = checkpointHealthTimeString(checkpointHealth.LastFailureTime)
+	if nil != checkpointHealth.LastFailureErr {
+		checkpointHealthStatus.LastFailureError = checkpointHealth.LastFailureErr.Error()
+	}
+	checkpointHealthStatus.NextRetryTime = checkpointHealthTimeString(checkpointHealth.NextRetryTime)
+
+	healthy = (headhunter.CheckpointHealthy == checkpointHealth.State)
+
+	return
+}
+
+func doHealth(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		checkpointHealthStatus  checkpointHealthStatusStruct
+		err                     error
+		formatResponseAsJSON    bool
+		formatResponseCompactly bool
+		healthJSON              bytes.Buffer
+		healthJSONPacked        []byte
+		numPathParts            int
+		volume                  *volumeStruct
+		volumeName              string
 	)
 
 	volume = requestState.volume
-	pathSplit = requestState.pathSplit
 	numPathParts = requestState.numPathParts
 	formatResponseAsJSON = requestState.formatResponseAsJSON
 	formatResponseCompactly = requestState.formatResponseCompactly
 
 	volumeName = volume.name
-	volume.Lock()
 
-	if 3 == numPathParts {
-		fsckJobsCount, err = volume.fsckJobs.Len()
+	if 3 != numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	checkpointHealthStatus, _ = fetchCheckpointHealthStatus(volume)
+
+	if formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		healthJSONPacked, err = json.Marshal(checkpointHealthStatus)
 		if nil != err {
 			logger.Fatalf("HTTP Server Logic Error: %v", err)
 		}
 
-		fsckJobsIDList = make([]uint64, 0, fsckJobsCount)
-		for fsckJobsIndex = fsckJobsCount - 1; fsckJobsIndex >= 0; fsckJobsIndex-- {
-			fsckJobIDAsKey, _, ok, err = volume.fsckJobs.GetByIndex(fsckJobsIndex)
-			if nil != err {
-				logger.Fatalf("HTTP Server Logic Error: %v", err)
-			}
-			if !ok {
-				err = fmt.Errorf("httpserver.doGetOfVolume() indexing volume.fsckJobs failed")
-				logger.Fatalf("HTTP Server Logic Error: %v", err)
-			}
-			fsckJobID = fsckJobIDAsKey.(uint64)
-
-			fsckJobsIDList = append(fsckJobsIDList, fsckJobID)
+		if formatResponseCompactly {
+			_, _ = responseWriter.Write(healthJSONPacked)
+		} else {
+			json.Indent(&healthJSON, healthJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(healthJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
 		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
 
-		if nil == volume.fsckActiveJob {
-			fsckInactive = true
-		} else {
-			// We know fsckJobRunning == volume.fsckActiveJob.state
-			select {
-			case volume.fsckActiveJob.err = <-volume.fsckActiveJob.errChan:
-				// FSCK finished at some point... make it look like it just finished now
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v Health</title>\n", volumeName)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>State</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", checkpointHealthStatus.State)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Consecutive Failures</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", checkpointHealthStatus.ConsecutiveFailures)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Last Success Time</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", checkpointHealthStatus.LastSuccessTime)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Last Failure Time</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", checkpointHealthStatus.LastFailureTime)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Last Failure Error</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", html.EscapeString(checkpointHealthStatus.LastFailureError))))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Next Retry Time</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", checkpointHealthStatus.NextRetryTime)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
 
-				volume.fsckActiveJob.state = fsckJobCompleted
-				volume.fsckActiveJob.endTime = time.Now()
-				volume.fsckActiveJob = nil
+func defragJobStateString(defragJobState fs.DefragJobState) (defragJobStateString string) {
+	switch defragJobState {
+	case fs.DefragJobDisabled:
+		defragJobStateString = "Disabled"
+	case fs.DefragJobIdle:
+		defragJobStateString = "Idle"
+	case fs.DefragJobRunning:
+		defragJobStateString = "Running"
+	case fs.DefragJobPaused:
+		defragJobStateString = "Paused"
+	default:
+		defragJobStateString = fmt.Sprintf("Unknown (%v)", defragJobState)
+	}
+	return
+}
 
-				fsckInactive = true
-			default:
-				// FSCK must still be running
+func defragJobTimeString(defragJobTime time.Time) (defragJobTimeString string) {
+	if defragJobTime.IsZero() {
+		defragJobTimeString = ""
+	} else {
+		defragJobTimeString = defragJobTime.String()
+	}
+	return
+}
 
-				fsckInactive = false
-			}
-		}
+func doDefragJob(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		defragJobStatus           fs.DefragJobStatus
+		defragJobStatusJSON       bytes.Buffer
+		defragJobStatusJSONPacked []byte
+		defragJobStatusStrings    defragJobStatusStruct
+		err                       error
+		formatResponseAsJSON      bool
+		formatResponseCompactly   bool
+		numPathParts              int
+		volume                    *volumeStruct
+		volumeName                string
+	)
 
-		volume.Unlock()
+	volume = requestState.volume
+	numPathParts = requestState.numPathParts
+	formatResponseAsJSON = requestState.formatResponseAsJSON
+	formatResponseCompactly = requestState.formatResponseCompactly
 
-		if formatResponseAsJSON {
-			responseWriter.Header().Set("Content-Type", "application/json")
-			responseWriter.WriteHeader(http.StatusOK)
+	volumeName = volume.name
 
-			fsckJobsIDListJSONPacked, err = json.Marshal(fsckJobsIDList)
-			if nil != err {
-				logger.Fatalf("HTTP Server Logic Error: %v", err)
-			}
+	if 3 != numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
 
-			if formatResponseCompactly {
-				_, _ = responseWriter.Write(fsckJobsIDListJSONPacked)
-			} else {
-				json.Indent(&fsckJobsIDListJSON, fsckJobsIDListJSONPacked, "", "\t")
-				_, _ = responseWriter.Write(fsckJobsIDListJSON.Bytes())
-				_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
-			}
+	defragJobStatus, err = fs.FetchDefragJobStatus(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
 		} else {
-			responseWriter.Header().Set("Content-Type", "text/html")
-			responseWriter.WriteHeader(http.StatusOK)
+			logger.ErrorfWithError(err, "doDefragJob(): fs.FetchDefragJobStatus() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
 
-			_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
-			_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
-			_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
-			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v FSCK Jobs</title>\n", volumeName)))
-			_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
-			_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+	defragJobStatusStrings.State = defragJobStateString(defragJobStatus.State)
+	defragJobStatusStrings.PassesCompleted = defragJobStatus.PassesCompleted
+	defragJobStatusStrings.PassStartTime = defragJobTimeString(defragJobStatus.PassStartTime)
+	defragJobStatusStrings.PassEndTime = defragJobTimeString(defragJobStatus.PassEndTime)
+	defragJobStatusStrings.InodesScanned = defragJobStatus.InodesScanned
+	defragJobStatusStrings.InodesOptimized = defragJobStatus.InodesOptimized
+	defragJobStatusStrings.BytesOptimized = defragJobStatus.BytesOptimized
+	defragJobStatusStrings.CurrentInodeNumber = uint64(defragJobStatus.CurrentInodeNumber)
+
+	if formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		defragJobStatusJSONPacked, err = json.Marshal(defragJobStatusStrings)
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		if formatResponseCompactly {
+			_, _ = responseWriter.Write(defragJobStatusJSONPacked)
+		} else {
+			json.Indent(&defragJobStatusJSON, defragJobStatusJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(defragJobStatusJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v Defrag Job</title>\n", volumeName)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>State</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", defragJobStatusStrings.State)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		if fs.DefragJobDisabled != defragJobStatus.State {
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Passes Completed</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", defragJobStatusStrings.PassesCompleted)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Pass Start Time</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", defragJobStatusStrings.PassStartTime)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Pass End Time</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", defragJobStatusStrings.PassEndTime)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Inodes Scanned</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", defragJobStatusStrings.InodesScanned)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Inodes Optimized</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", defragJobStatusStrings.InodesOptimized)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Bytes Optimized</td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", defragJobStatusStrings.BytesOptimized)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			if 0 != defragJobStatusStrings.CurrentInodeNumber {
+				_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+				_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Current Inode</td>\n"))
+				_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td><a href=\"/volume/%v/inode/%v/fragmentation\">%v</a></td>\n", volumeName, defragJobStatusStrings.CurrentInodeNumber, defragJobStatusStrings.CurrentInodeNumber)))
+				_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+			}
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		switch defragJobStatus.State {
+		case fs.DefragJobIdle, fs.DefragJobRunning:
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <form method=\"post\" action=\"/volume/%v/defrag-job/pause\">\n", volumeName)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <input type=\"submit\" value=\"Pause\">\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    </form>\n"))
+		case fs.DefragJobPaused:
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <form method=\"post\" action=\"/volume/%v/defrag-job/resume\">\n", volumeName)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <input type=\"submit\" value=\"Resume\">\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    </form>\n"))
+		default:
+			// Nothing to control for a Disabled defrag-job
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
+
+func fetchSnapshotStatus(volume *volumeStruct, snapshot headhunter.SnapshotStruct) (snapshotStatus snapshotStatusStruct, err error) {
+	snapshotStatus.ID = snapshot.ID
+	snapshotStatus.Name = snapshot.Name
+	snapshotStatus.CreationTime = snapshot.CreationTime.String()
+	snapshotStatus.CheckpointObjectNumber = snapshot.CheckpointObjectNumber
+
+	snapshotStatus.ExclusiveBytes, err = volume.headhunterHandle.FetchSnapshotExclusiveBytes(snapshot.Name)
+
+	return
+}
+
+func doSnapshot(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                     error
+		formatResponseAsJSON    bool
+		formatResponseCompactly bool
+		numPathParts            int
+		snapshot                headhunter.SnapshotStruct
+		snapshotList            []headhunter.SnapshotStruct
+		snapshotStatusList      []snapshotStatusStruct
+		snapshotsJSON           bytes.Buffer
+		snapshotsJSONPacked     []byte
+		snapshotStatus          snapshotStatusStruct
+		volume                  *volumeStruct
+		volumeName              string
+	)
+
+	volume = requestState.volume
+	numPathParts = requestState.numPathParts
+	formatResponseAsJSON = requestState.formatResponseAsJSON
+	formatResponseCompactly = requestState.formatResponseCompactly
+
+	volumeName = volume.name
+
+	snapshotList, err = fs.FetchSnapshotList(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doSnapshot(): fs.FetchSnapshotList() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	snapshotStatusList = make([]snapshotStatusStruct, 0, len(snapshotList))
+
+	for _, snapshot = range snapshotList {
+		if (4 == numPathParts) && (snapshot.Name != requestState.pathSplit[4]) {
+			continue
+		}
+
+		snapshotStatus, err = fetchSnapshotStatus(volume, snapshot)
+		if nil != err {
+			if blunder.Is(err, blunder.NotFoundError) {
+				// Snapshot must have been deleted since fetching snapshotList
+				continue
+			}
+			logger.ErrorfWithError(err, "doSnapshot(): FetchSnapshotExclusiveBytes() failed for snapshot %s of volume %s", snapshot.Name, volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		snapshotStatusList = append(snapshotStatusList, snapshotStatus)
+	}
+
+	if (4 == numPathParts) && (0 == len(snapshotStatusList)) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		if 4 == numPathParts {
+			snapshotsJSONPacked, err = json.Marshal(snapshotStatusList[0])
+		} else {
+			snapshotsJSONPacked, err = json.Marshal(snapshotStatusList)
+		}
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		if formatResponseCompactly {
+			_, _ = responseWriter.Write(snapshotsJSONPacked)
+		} else {
+			json.Indent(&snapshotsJSON, snapshotsJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(snapshotsJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v Snapshots</title>\n", volumeName)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Name</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Creation Time</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Checkpoint Object Number</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th>Exclusive Bytes</th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <th></th>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		for _, snapshotStatus = range snapshotStatusList {
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td><a href=\"/volume/%v/snapshot/%v\">%v</a></td>\n", volumeName, url.PathEscape(snapshotStatus.Name), html.EscapeString(snapshotStatus.Name))))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%s</td>\n", snapshotStatus.CreationTime)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%016X</td>\n", snapshotStatus.CheckpointObjectNumber)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", snapshotStatus.ExclusiveBytes)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("          <form method=\"post\" action=\"/volume/%v/snapshot/%v/delete\">\n", volumeName, url.PathEscape(snapshotStatus.Name))))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("            <input type=\"submit\" value=\"Delete\">\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("          </form>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("        </td>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		if 3 == numPathParts {
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <form method=\"post\" action=\"/volume/%v/snapshot\">\n", volumeName)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <input type=\"text\" name=\"name\">\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("      <input type=\"submit\" value=\"Create\">\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("    </form>\n"))
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
+
+func doFsckJob(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                       error
+		pathSplit                 []string
+		numPathParts              int
+		formatResponseAsJSON      bool
+		formatResponseCompactly   bool
+		fsckCompletedJobNoError   fsckCompletedJobNoErrorStruct
+		fsckCompletedJobWithError fsckCompletedJobWithErrorStruct
+		fsckHaltedJob             fsckHaltedJobStruct
+		fsckInactive              bool
+		fsckJob                   *fsckJobStruct
+		fsckJobAsValue            sortedmap.Value
+		fsckJobID                 uint64
+		fsckJobIDAsKey            sortedmap.Key
+		fsckJobsIDListJSON        bytes.Buffer
+		fsckJobsIDListJSONPacked  []byte
+		fsckJobStatusJSON         bytes.Buffer
+		fsckJobStatusJSONPacked   []byte
+		fsckJobsCount             int
+		fsckJobsIDList            []uint64
+		fsckJobsIDListIndex       int
+		fsckJobsIndex             int
+		fsckRunningJob            fsckRunningJobStruct
+		ok                        bool
+		volume                    *volumeStruct
+		volumeName                string
+	)
+
+	volume = requestState.volume
+	pathSplit = requestState.pathSplit
+	numPathParts = requestState.numPathParts
+	formatResponseAsJSON = requestState.formatResponseAsJSON
+	formatResponseCompactly = requestState.formatResponseCompactly
+
+	volumeName = volume.name
+	volume.Lock()
+
+	if 3 == numPathParts {
+		fsckJobsCount, err = volume.fsckJobs.Len()
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		fsckJobsIDList = make([]uint64, 0, fsckJobsCount)
+		for fsckJobsIndex = fsckJobsCount - 1; fsckJobsIndex >= 0; fsckJobsIndex-- {
+			fsckJobIDAsKey, _, ok, err = volume.fsckJobs.GetByIndex(fsckJobsIndex)
+			if nil != err {
+				logger.Fatalf("HTTP Server Logic Error: %v", err)
+			}
+			if !ok {
+				err = fmt.Errorf("httpserver.doGetOfVolume() indexing volume.fsckJobs failed")
+				logger.Fatalf("HTTP Server Logic Error: %v", err)
+			}
+			fsckJobID = fsckJobIDAsKey.(uint64)
+
+			fsckJobsIDList = append(fsckJobsIDList, fsckJobID)
+		}
+
+		if nil == volume.fsckActiveJob {
+			fsckInactive = true
+		} else {
+			// We know fsckJobRunning == volume.fsckActiveJob.state
+			select {
+			case volume.fsckActiveJob.err = <-volume.fsckActiveJob.errChan:
+				// FSCK finished at some point... make it look like it just finished now
+
+				volume.fsckActiveJob.state = fsckJobCompleted
+				volume.fsckActiveJob.endTime = time.Now()
+				volume.fsckActiveJob = nil
+
+				fsckInactive = true
+			default:
+				// FSCK must still be running
+
+				fsckInactive = false
+			}
+		}
+
+		volume.Unlock()
+
+		if formatResponseAsJSON {
+			responseWriter.Header().Set("Content-Type", "application/json")
+			responseWriter.WriteHeader(http.StatusOK)
+
+			fsckJobsIDListJSONPacked, err = json.Marshal(fsckJobsIDList)
+			if nil != err {
+				logger.Fatalf("HTTP Server Logic Error: %v", err)
+			}
+
+			if formatResponseCompactly {
+				_, _ = responseWriter.Write(fsckJobsIDListJSONPacked)
+			} else {
+				json.Indent(&fsckJobsIDListJSON, fsckJobsIDListJSONPacked, "", "\t")
+				_, _ = responseWriter.Write(fsckJobsIDListJSON.Bytes())
+				_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+			}
+		} else {
+			responseWriter.Header().Set("Content-Type", "text/html")
+			responseWriter.WriteHeader(http.StatusOK)
+
+			_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v FSCK Jobs</title>\n", volumeName)))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+			_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
 			for fsckJobsIDListIndex, fsckJobID = range fsckJobsIDList {
 				if 0 < fsckJobsIDListIndex {
 					_, _ = responseWriter.Write(utils.StringToByteSlice("    <br />\n"))
@@ -845,6 +1471,7 @@ func doLayoutReport(responseWriter http.ResponseWriter, request *http.Request, r
 		headhunter.InodeRecBPlusTree:        "Inode Record B+Tree",
 		headhunter.LogSegmentRecBPlusTree:   "Log Segment Record B+Tree",
 		headhunter.BPlusTreeObjectBPlusTree: "B+Plus Tree Objects B+Tree",
+		headhunter.JournalRecBPlusTree:      "Journal Record B+Tree",
 	}
 
 	if formatResponseAsJSON {
@@ -889,23 +1516,121 @@ func doLayoutReport(responseWriter http.ResponseWriter, request *http.Request, r
 	return
 }
 
-func doPost(responseWriter http.ResponseWriter, request *http.Request) {
-	path := strings.TrimRight(request.URL.Path, "/")
+func doInode(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                                error
+		formatResponseAsJSON               bool
+		formatResponseCompactly            bool
+		fragmentationReport                inode.FragmentationReport
+		inodeFragmentationReport           inodeFragmentationReportStruct
+		inodeFragmentationReportJSON       bytes.Buffer
+		inodeFragmentationReportJSONPacked []byte
+		inodeNumber                        uint64
+		numPathParts                       int
+		pathSplit                          []string
+		volume                             *volumeStruct
+		volumeName                         string
+	)
 
-	switch {
-	case "/arm-disarm-trigger" == path:
-		doPostOfArmDisarmTrigger(responseWriter, request)
-	case strings.HasPrefix(request.URL.Path, "/trigger"):
-		doPostOfTrigger(responseWriter, request)
-	case strings.HasPrefix(request.URL.Path, "/volume"):
-		doPostOfVolume(responseWriter, request)
-	default:
+	volume = requestState.volume
+	pathSplit = requestState.pathSplit
+	numPathParts = requestState.numPathParts
+	formatResponseAsJSON = requestState.formatResponseAsJSON
+	formatResponseCompactly = requestState.formatResponseCompactly
+
+	volumeName = volume.name
+
+	if (5 != numPathParts) || ("fragmentation" != pathSplit[5]) {
 		responseWriter.WriteHeader(http.StatusNotFound)
+		return
 	}
-}
 
-func doPostOfArmDisarmTrigger(responseWriter http.ResponseWriter, request *http.Request) {
-	var (
+	inodeNumber, err = strconv.ParseUint(pathSplit[4], 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	fragmentationReport, err = fs.GetFragmentationReport(volumeName, inode.InodeNumber(inodeNumber))
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else if blunder.Is(err, blunder.NotFileError) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+		} else {
+			logger.ErrorfWithError(err, "doInode(): fs.GetFragmentationReport() failed for volume %s inode %d", volumeName, inodeNumber)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		inodeFragmentationReport.InodeNumber = inodeNumber
+		inodeFragmentationReport.NumberOfFragments = fragmentationReport.NumberOfFragments
+		inodeFragmentationReport.BytesInFragments = fragmentationReport.BytesInFragments
+		inodeFragmentationReport.BytesTrapped = fragmentationReport.BytesTrapped
+
+		inodeFragmentationReportJSONPacked, err = json.Marshal(inodeFragmentationReport)
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		if formatResponseCompactly {
+			_, _ = responseWriter.Write(inodeFragmentationReportJSONPacked)
+		} else {
+			json.Indent(&inodeFragmentationReportJSON, inodeFragmentationReportJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(inodeFragmentationReportJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>%v Inode %v Fragmentation Report</title>\n", volumeName, inodeNumber)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Number Of Fragments</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", fragmentationReport.NumberOfFragments)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Bytes In Fragments</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", fragmentationReport.BytesInFragments)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("        <td>Bytes Trapped</td>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("        <td>%v</td>\n", fragmentationReport.BytesTrapped)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      </tr>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
+
+func doPost(responseWriter http.ResponseWriter, request *http.Request) {
+	path := strings.TrimRight(request.URL.Path, "/")
+
+	switch {
+	case "/arm-disarm-trigger" == path:
+		doPostOfArmDisarmTrigger(responseWriter, request)
+	case strings.HasPrefix(request.URL.Path, "/trigger"):
+		doPostOfTrigger(responseWriter, request)
+	case strings.HasPrefix(request.URL.Path, "/volume"):
+		doPostOfVolume(responseWriter, request)
+	default:
+		responseWriter.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func doPostOfArmDisarmTrigger(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
 		err                    error
 		haltAfterCountAsString string
 		haltAfterCountAsU32    uint32
@@ -1017,8 +1742,17 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	switch numPathParts {
 	case 3:
 		// Form: /volume/<volume-name/fsck-job
+		// Form: /volume/<volume-name/snapshot
+		// Form: /volume/<volume-name/watch
 	case 4:
+		// Form: /volume/<volume-name/defrag-job/pause
+		// Form: /volume/<volume-name/defrag-job/resume
 		// Form: /volume/<volume-name/fsck-job/<job-id>
+		// Form: /volume/<volume-name/snapshot/<snapshot-name>
+	case 5:
+		// Form: /volume/<volume-name/snapshot/<snapshot-name>/delete
+		// Form: /volume/<volume-name/trash/<trash-entry-name>/restore
+		// Form: /volume/<volume-name/watch/<watch-id>/delete
 	default:
 		responseWriter.WriteHeader(http.StatusNotFound)
 		return
@@ -1026,6 +1760,31 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 
 	volumeName = pathSplit[2]
 
+	if "defrag-job" == pathSplit[3] {
+		doPostOfDefragJob(responseWriter, request, pathSplit, numPathParts)
+		return
+	}
+
+	if "snapshot" == pathSplit[3] {
+		doPostOfSnapshot(responseWriter, request, pathSplit, numPathParts)
+		return
+	}
+
+	if "trash" == pathSplit[3] {
+		doPostOfTrash(responseWriter, request, pathSplit, numPathParts)
+		return
+	}
+
+	if "watch" == pathSplit[3] {
+		doPostOfWatch(responseWriter, request, pathSplit, numPathParts)
+		return
+	}
+
+	if 5 == numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	volumeAsValue, ok, err = globals.volumeLLRB.GetByKey(volumeName)
 	if nil != err {
 		logger.Fatalf("HTTP Server Logic Error: %v", err)
@@ -1160,6 +1919,812 @@ func doPostOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
 	responseWriter.WriteHeader(http.StatusNoContent)
 }
 
+func doPostOfDefragJob(responseWriter http.ResponseWriter, request *http.Request, pathSplit []string, numPathParts int) {
+	var (
+		err        error
+		ok         bool
+		volumeName string
+	)
+
+	volumeName = pathSplit[2]
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok || (4 != numPathParts) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch pathSplit[4] {
+	case "pause":
+		err = fs.PauseDefragJob(volumeName)
+	case "resume":
+		err = fs.ResumeDefragJob(volumeName)
+	default:
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else if blunder.Is(err, blunder.NotSupportedError) {
+			responseWriter.WriteHeader(http.StatusPreconditionFailed)
+		} else {
+			logger.ErrorfWithError(err, "doPostOfDefragJob(): %v of volume %s failed", pathSplit[4], volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseWriter.Header().Set("Location", fmt.Sprintf("/volume/%v/defrag-job", volumeName))
+	responseWriter.WriteHeader(http.StatusSeeOther)
+}
+
+func doPostOfSnapshot(responseWriter http.ResponseWriter, request *http.Request, pathSplit []string, numPathParts int) {
+	var (
+		err          error
+		ok           bool
+		snapshot     headhunter.SnapshotStruct
+		snapshotName string
+		volumeName   string
+	)
+
+	volumeName = pathSplit[2]
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch numPathParts {
+	case 3:
+		snapshotName = request.FormValue("name")
+	case 4:
+		snapshotName = pathSplit[4]
+	case 5:
+		// An HTML form is unable to issue a DELETE... so this is the equivalent
+		if "delete" != pathSplit[5] {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		err = fs.DeleteSnapshot(volumeName, pathSplit[4])
+		if nil != err {
+			writeSnapshotErrorStatus(responseWriter, err, "DeleteSnapshot", volumeName, pathSplit[4])
+			return
+		}
+		responseWriter.Header().Set("Location", fmt.Sprintf("/volume/%v/snapshot", volumeName))
+		responseWriter.WriteHeader(http.StatusSeeOther)
+		return
+	}
+
+	snapshot, err = fs.CreateSnapshot(volumeName, snapshotName)
+	if nil != err {
+		writeSnapshotErrorStatus(responseWriter, err, "CreateSnapshot", volumeName, snapshotName)
+		return
+	}
+
+	responseWriter.Header().Set("Location", fmt.Sprintf("/volume/%v/snapshot/%v", volumeName, url.PathEscape(snapshot.Name)))
+	responseWriter.WriteHeader(http.StatusCreated)
+}
+
+func writeSnapshotErrorStatus(responseWriter http.ResponseWriter, err error, operation string, volumeName string, snapshotName string) {
+	if blunder.Is(err, blunder.NotFoundError) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+	} else if blunder.Is(err, blunder.FileExistsError) {
+		responseWriter.WriteHeader(http.StatusConflict)
+	} else if blunder.Is(err, blunder.InvalidArgError) {
+		responseWriter.WriteHeader(http.StatusBadRequest)
+	} else {
+		logger.ErrorfWithError(err, "fs.%v() of snapshot %s of volume %s failed", operation, snapshotName, volumeName)
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+const (
+	watchDefaultWait = 30 * time.Second
+	watchMaxWait     = 60 * time.Second
+)
+
+// watchEventTypeString returns the name used for an fs.WatchEventType in the JSON-encoded watch GET body
+func watchEventTypeString(watchEventType fs.WatchEventType) (watchEventTypeString string) {
+	switch watchEventType {
+	case fs.WatchEventCreate:
+		watchEventTypeString = "create"
+	case fs.WatchEventUnlink:
+		watchEventTypeString = "unlink"
+	case fs.WatchEventRenameFrom:
+		watchEventTypeString = "rename from"
+	case fs.WatchEventRenameTo:
+		watchEventTypeString = "rename to"
+	case fs.WatchEventSetattr:
+		watchEventTypeString = "setattr"
+	case fs.WatchEventWriteClose:
+		watchEventTypeString = "write close"
+	case fs.WatchEventOverflow:
+		watchEventTypeString = "overflow"
+	default:
+		watchEventTypeString = fmt.Sprintf("unknown (%v)", watchEventType)
+	}
+	return
+}
+
+// doWatch long-polls for the events of a watch added via a POST to /volume/<volume-name/watch.
+// The optional timeout query parameter (e.g. "?timeout=30s") bounds the wait (see watchMaxWait).
+// The response is always JSON-encoded.
+func doWatch(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                     error
+		event                   fs.WatchEventStruct
+		eventList               []fs.WatchEventStruct
+		eventStatusList         []watchEventStatusStruct
+		eventsJSON              bytes.Buffer
+		eventsJSONPacked        []byte
+		formatResponseCompactly bool
+		ok                      bool
+		paramList               []string
+		timeout                 time.Duration
+		volumeName              string
+		watchID                 uint64
+	)
+
+	volumeName = requestState.volume.name
+
+	if 4 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	watchID, err = strconv.ParseUint(requestState.pathSplit[4], 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	timeout = watchDefaultWait
+
+	paramList, ok = request.URL.Query()["timeout"]
+	if ok && (0 < len(paramList)) {
+		timeout, err = time.ParseDuration(paramList[0])
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if timeout > watchMaxWait {
+			timeout = watchMaxWait
+		}
+	}
+
+	// Other requests must not be held up (by globals.Lock()) while waiting for an event
+
+	globals.Unlock()
+	eventList, err = fs.FetchVolumeWatchEvents(volumeName, fs.WatchID(watchID), timeout)
+	globals.Lock()
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doWatch(): fs.FetchVolumeWatchEvents() failed for watch %v of volume %s", watchID, volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	eventStatusList = make([]watchEventStatusStruct, 0, len(eventList))
+
+	for _, event = range eventList {
+		eventStatusList = append(eventStatusList, watchEventStatusStruct{
+			Type:           watchEventTypeString(event.Type),
+			DirInodeNumber: uint64(event.DirInodeNumber),
+			Basename:       event.Basename,
+			InodeNumber:    uint64(event.InodeNumber),
+			Cookie:         event.Cookie,
+		})
+	}
+
+	paramList, ok = request.URL.Query()["compact"]
+	formatResponseCompactly = (ok && (0 < len(paramList)) && (("true" == paramList[0]) || ("1" == paramList[0])))
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	eventsJSONPacked, err = json.Marshal(eventStatusList)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(eventsJSONPacked)
+	} else {
+		json.Indent(&eventsJSON, eventsJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(eventsJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// journalOpString returns the name used for an fs.JournalOp in the JSON-encoded journal GET body
+func journalOpString(journalOp fs.JournalOp) (journalOpString string) {
+	switch journalOp {
+	case fs.JournalOpCreate:
+		journalOpString = "create"
+	case fs.JournalOpUnlink:
+		journalOpString = "unlink"
+	case fs.JournalOpRenameFrom:
+		journalOpString = "rename from"
+	case fs.JournalOpRenameTo:
+		journalOpString = "rename to"
+	case fs.JournalOpSetattr:
+		journalOpString = "setattr"
+	case fs.JournalOpWrite:
+		journalOpString = "write"
+	default:
+		journalOpString = fmt.Sprintf("unknown (%v)", journalOp)
+	}
+	return
+}
+
+// doJournal pages through the change journal of a volume. The optional cursor query parameter should be the
+// cursor returned by the prior GET (or omitted to start from the oldest retained record). The optional max query
+// parameter bounds the number of records returned. Should the records following cursor have been trimmed, the
+// response is 410 Gone. The response is always JSON-encoded.
+func doJournal(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		cursor                  string
+		err                     error
+		formatResponseCompactly bool
+		journalJSON             bytes.Buffer
+		journalJSONPacked       []byte
+		journalRec              fs.JournalRecStruct
+		journalRecs             []fs.JournalRecStruct
+		journalStatus           journalStatusStruct
+		maxRecs                 uint64
+		ok                      bool
+		paramList               []string
+		volumeName              string
+	)
+
+	volumeName = requestState.volume.name
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	paramList, ok = request.URL.Query()["cursor"]
+	if ok && (0 < len(paramList)) {
+		cursor = paramList[0]
+	} else {
+		cursor = ""
+	}
+
+	paramList, ok = request.URL.Query()["max"]
+	if ok && (0 < len(paramList)) {
+		maxRecs, err = strconv.ParseUint(paramList[0], 10, 64)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		maxRecs = 0
+	}
+
+	journalRecs, journalStatus.Cursor, err = fs.FetchJournal(volumeName, cursor, maxRecs)
+	if nil != err {
+		if blunder.Is(err, blunder.JournalTrimmedError) {
+			responseWriter.WriteHeader(http.StatusGone)
+		} else if blunder.Is(err, blunder.InvalidArgError) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+		} else if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doJournal(): fs.FetchJournal() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	journalStatus.Records = make([]journalRecStatusStruct, 0, len(journalRecs))
+
+	for _, journalRec = range journalRecs {
+		journalStatus.Records = append(journalStatus.Records, journalRecStatusStruct{
+			Nonce:             journalRec.Nonce,
+			Op:                journalOpString(journalRec.Op),
+			InodeNumber:       uint64(journalRec.InodeNumber),
+			ParentInodeNumber: uint64(journalRec.ParentInodeNumber),
+			Basename:          journalRec.Basename,
+			Time:              journalRec.Time.Format(time.RFC3339),
+		})
+	}
+
+	paramList, ok = request.URL.Query()["compact"]
+	formatResponseCompactly = (ok && (0 < len(paramList)) && (("true" == paramList[0]) || ("1" == paramList[0])))
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	journalJSONPacked, err = json.Marshal(journalStatus)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	if formatResponseCompactly {
+		_, _ = responseWriter.Write(journalJSONPacked)
+	} else {
+		json.Indent(&journalJSON, journalJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(journalJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// quotaTypeString returns the name used for an fs.QuotaType in the JSON-encoded quota GET body
+func quotaTypeString(quotaType fs.QuotaType) (quotaTypeString string) {
+	switch quotaType {
+	case fs.QuotaTypeUser:
+		quotaTypeString = "user"
+	case fs.QuotaTypeGroup:
+		quotaTypeString = "group"
+	case fs.QuotaTypeTree:
+		quotaTypeString = "tree"
+	default:
+		quotaTypeString = fmt.Sprintf("unknown (%v)", quotaType)
+	}
+	return
+}
+
+// quotaGraceExpirationString formats a grace expiration (if any) for the JSON-encoded quota GET body
+func quotaGraceExpirationString(graceExpiration time.Time) (graceExpirationString string) {
+	if graceExpiration.IsZero() {
+		graceExpirationString = ""
+	} else {
+		graceExpirationString = graceExpiration.Format(time.RFC3339)
+	}
+	return
+}
+
+// doQuota reports the limits and usage of each quota of a volume. The response is always JSON-encoded.
+func doQuota(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err              error
+		quotaJSON        bytes.Buffer
+		quotaJSONPacked  []byte
+		quotaReport      []fs.QuotaStatusStruct
+		quotaStatus      fs.QuotaStatusStruct
+		quotaStatusSlice []quotaStatusStruct
+		volumeName       string
+	)
+
+	volumeName = requestState.volume.name
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	quotaReport, err = fs.FetchQuotaReport(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doQuota(): fs.FetchQuotaReport() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	quotaStatusSlice = make([]quotaStatusStruct, 0, len(quotaReport))
+
+	for _, quotaStatus = range quotaReport {
+		quotaStatusSlice = append(quotaStatusSlice, quotaStatusStruct{
+			Name:                  quotaStatus.Name,
+			Type:                  quotaTypeString(quotaStatus.Type),
+			ID:                    quotaStatus.ID,
+			Path:                  quotaStatus.Path,
+			UsedBytes:             quotaStatus.UsedBytes,
+			UsedInodes:            quotaStatus.UsedInodes,
+			SoftBytes:             quotaStatus.SoftBytes,
+			HardBytes:             quotaStatus.HardBytes,
+			SoftInodes:            quotaStatus.SoftInodes,
+			HardInodes:            quotaStatus.HardInodes,
+			GracePeriod:           quotaStatus.GracePeriod.String(),
+			BytesGraceExpiration:  quotaGraceExpirationString(quotaStatus.BytesGraceExpiration),
+			InodesGraceExpiration: quotaGraceExpirationString(quotaStatus.InodesGraceExpiration),
+		})
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	quotaJSONPacked, err = json.Marshal(quotaStatusSlice)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	if requestState.formatResponseCompactly {
+		_, _ = responseWriter.Write(quotaJSONPacked)
+	} else {
+		json.Indent(&quotaJSON, quotaJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(quotaJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+// doProject reports the (incrementally maintained) byte and inode totals of each directory tree
+// quota (i.e. "project") of a volume
+func doProject(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                error
+		projectJSON        bytes.Buffer
+		projectJSONPacked  []byte
+		projectStatus      projectStatusStruct
+		projectStatusSlice []projectStatusStruct
+		quotaReport        []fs.QuotaStatusStruct
+		quotaStatus        fs.QuotaStatusStruct
+		volumeName         string
+	)
+
+	volumeName = requestState.volume.name
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	quotaReport, err = fs.FetchQuotaReport(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doProject(): fs.FetchQuotaReport() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	projectStatusSlice = make([]projectStatusStruct, 0, len(quotaReport))
+
+	for _, quotaStatus = range quotaReport {
+		if fs.QuotaTypeTree == quotaStatus.Type {
+			projectStatusSlice = append(projectStatusSlice, projectStatusStruct{
+				Name:        quotaStatus.Name,
+				Path:        quotaStatus.Path,
+				InodeNumber: quotaStatus.ID,
+				UsedBytes:   quotaStatus.UsedBytes,
+				UsedInodes:  quotaStatus.UsedInodes,
+			})
+		}
+	}
+
+	if requestState.formatResponseAsJSON {
+		responseWriter.Header().Set("Content-Type", "application/json")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		projectJSONPacked, err = json.Marshal(projectStatusSlice)
+		if nil != err {
+			logger.Fatalf("HTTP Server Logic Error: %v", err)
+		}
+
+		if requestState.formatResponseCompactly {
+			_, _ = responseWriter.Write(projectJSONPacked)
+		} else {
+			json.Indent(&projectJSON, projectJSONPacked, "", "\t")
+			_, _ = responseWriter.Write(projectJSON.Bytes())
+			_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+		}
+	} else {
+		responseWriter.Header().Set("Content-Type", "text/html")
+		responseWriter.WriteHeader(http.StatusOK)
+
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<!DOCTYPE html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("<html>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("    <title>Volume %s Projects</title>\n", volumeName)))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </head>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  <body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    <table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("      <tr><th>Name</th><th>Path</th><th>Bytes</th><th>Inodes</th></tr>\n"))
+		for _, projectStatus = range projectStatusSlice {
+			_, _ = responseWriter.Write(utils.StringToByteSlice(fmt.Sprintf("      <tr><td>%s</td><td>%s</td><td>%d</td><td>%d</td></tr>\n",
+				html.EscapeString(projectStatus.Name), html.EscapeString(projectStatus.Path), projectStatus.UsedBytes, projectStatus.UsedInodes)))
+		}
+		_, _ = responseWriter.Write(utils.StringToByteSlice("    </table>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("  </body>\n"))
+		_, _ = responseWriter.Write(utils.StringToByteSlice("</html>\n"))
+	}
+}
+
+func trashInodeTypeString(inodeType inode.InodeType) (inodeTypeString string) {
+	switch inodeType {
+	case inode.DirType:
+		inodeTypeString = "dir"
+	case inode.FileType:
+		inodeTypeString = "file"
+	case inode.SymlinkType:
+		inodeTypeString = "symlink"
+	default:
+		inodeTypeString = fmt.Sprintf("unknown (%v)", inodeType)
+	}
+	return
+}
+
+// doTrash lists the entries (in the order they were deleted) in the trash of a volume. The response is always JSON-encoded.
+func doTrash(responseWriter http.ResponseWriter, request *http.Request, requestState requestState) {
+	var (
+		err                   error
+		trashEntries          []fs.TrashEntryStruct
+		trashEntry            fs.TrashEntryStruct
+		trashEntryStatusSlice []trashEntryStatusStruct
+		trashJSON             bytes.Buffer
+		trashJSONPacked       []byte
+		volumeName            string
+	)
+
+	volumeName = requestState.volume.name
+
+	if 3 != requestState.numPathParts {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	trashEntries, err = fs.FetchTrash(volumeName)
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "doTrash(): fs.FetchTrash() failed for volume %s", volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	trashEntryStatusSlice = make([]trashEntryStatusStruct, 0, len(trashEntries))
+
+	for _, trashEntry = range trashEntries {
+		trashEntryStatusSlice = append(trashEntryStatusSlice, trashEntryStatusStruct{
+			Name:              trashEntry.Name,
+			InodeNumber:       uint64(trashEntry.InodeNumber),
+			InodeType:         trashInodeTypeString(trashEntry.InodeType),
+			ParentInodeNumber: uint64(trashEntry.ParentInodeNumber),
+			Basename:          trashEntry.Basename,
+			DeletionTime:      trashEntry.DeletionTime.Format(time.RFC3339),
+			ExpirationTime:    trashEntry.ExpirationTime.Format(time.RFC3339),
+		})
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+
+	trashJSONPacked, err = json.Marshal(trashEntryStatusSlice)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+
+	if requestState.formatResponseCompactly {
+		_, _ = responseWriter.Write(trashJSONPacked)
+	} else {
+		json.Indent(&trashJSON, trashJSONPacked, "", "\t")
+		_, _ = responseWriter.Write(trashJSON.Bytes())
+		_, _ = responseWriter.Write(utils.StringToByteSlice("\n"))
+	}
+}
+
+func doPostOfTrash(responseWriter http.ResponseWriter, request *http.Request, pathSplit []string, numPathParts int) {
+	var (
+		err        error
+		ok         bool
+		volumeName string
+	)
+
+	volumeName = pathSplit[2]
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if (5 != numPathParts) || ("restore" != pathSplit[5]) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = fs.RestoreTrash(volumeName, pathSplit[4])
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else if blunder.Is(err, blunder.InvalidArgError) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+		} else if blunder.Is(err, blunder.FileExistsError) {
+			responseWriter.WriteHeader(http.StatusConflict)
+		} else {
+			logger.ErrorfWithError(err, "fs.RestoreTrash() of %s of volume %s failed", pathSplit[4], volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// doPostOfWatch adds a watch on the directory identified by the inode form value on behalf of the user
+// identified by the uid and gid form values (who must be able to read the directory)
+func doPostOfWatch(responseWriter http.ResponseWriter, request *http.Request, pathSplit []string, numPathParts int) {
+	var (
+		err            error
+		dirInodeNumber uint64
+		groupID        uint64
+		ok             bool
+		userID         uint64
+		volumeName     string
+		watchID        fs.WatchID
+	)
+
+	volumeName = pathSplit[2]
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch numPathParts {
+	case 3:
+		dirInodeNumber, err = strconv.ParseUint(request.FormValue("inode"), 10, 64)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		userID, err = strconv.ParseUint(request.FormValue("uid"), 10, 32)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		groupID, err = strconv.ParseUint(request.FormValue("gid"), 10, 32)
+		if nil != err {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	case 5:
+		// An HTML form is unable to issue a DELETE... so this is the equivalent
+		if "delete" != pathSplit[5] {
+			responseWriter.WriteHeader(http.StatusNotFound)
+			return
+		}
+		doDeleteOfWatch(responseWriter, volumeName, pathSplit[4])
+		return
+	default:
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	watchID, err = fs.AddVolumeWatch(volumeName, inode.InodeUserID(userID), inode.InodeGroupID(groupID), nil, inode.InodeNumber(dirInodeNumber))
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else if blunder.Is(err, blunder.PermDeniedError) {
+			responseWriter.WriteHeader(http.StatusForbidden)
+		} else if blunder.Is(err, blunder.NotDirError) {
+			responseWriter.WriteHeader(http.StatusBadRequest)
+		} else if blunder.Is(err, blunder.TryAgainError) {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			logger.ErrorfWithError(err, "fs.AddVolumeWatch() of inode %v of volume %s failed", dirInodeNumber, volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseWriter.Header().Set("Location", fmt.Sprintf("/volume/%v/watch/%v", volumeName, watchID))
+	responseWriter.WriteHeader(http.StatusCreated)
+}
+
+func doDeleteOfWatch(responseWriter http.ResponseWriter, volumeName string, watchIDAsString string) {
+	var (
+		err     error
+		ok      bool
+		watchID uint64
+	)
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	watchID, err = strconv.ParseUint(watchIDAsString, 10, 64)
+	if nil != err {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = fs.RemoveVolumeWatch(volumeName, fs.WatchID(watchID))
+	if nil != err {
+		if blunder.Is(err, blunder.NotFoundError) {
+			responseWriter.WriteHeader(http.StatusNotFound)
+		} else {
+			logger.ErrorfWithError(err, "fs.RemoveVolumeWatch() of watch %v of volume %s failed", watchID, volumeName)
+			responseWriter.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func doDelete(responseWriter http.ResponseWriter, request *http.Request) {
+	switch {
+	case strings.HasPrefix(request.URL.Path, "/volume"):
+		doDeleteOfVolume(responseWriter, request)
+	default:
+		responseWriter.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func doDeleteOfVolume(responseWriter http.ResponseWriter, request *http.Request) {
+	var (
+		err          error
+		numPathParts int
+		ok           bool
+		pathSplit    []string
+		snapshotName string
+		volumeName   string
+	)
+
+	pathSplit = strings.Split(request.URL.Path, "/") // leading  "/" places "" in pathSplit[0]
+	//                                                  pathSplit[1] must be "volume" based on how we got here
+	//                                                  trailing "/" places "" in pathSplit[len(pathSplit)-1]
+	numPathParts = len(pathSplit) - 1
+	if "" == pathSplit[numPathParts] {
+		numPathParts--
+	}
+
+	// Form: /volume/<volume-name/snapshot/<snapshot-name>
+	// Form: /volume/<volume-name/watch/<watch-id>
+
+	if (4 == numPathParts) && ("watch" == pathSplit[3]) {
+		doDeleteOfWatch(responseWriter, pathSplit[2], pathSplit[4])
+		return
+	}
+
+	if (4 != numPathParts) || ("snapshot" != pathSplit[3]) {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	volumeName = pathSplit[2]
+	snapshotName = pathSplit[4]
+
+	_, ok, err = globals.volumeLLRB.GetByKey(volumeName)
+	if nil != err {
+		logger.Fatalf("HTTP Server Logic Error: %v", err)
+	}
+	if !ok {
+		responseWriter.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = fs.DeleteSnapshot(volumeName, snapshotName)
+	if nil != err {
+		writeSnapshotErrorStatus(responseWriter, err, "DeleteSnapshot", volumeName, snapshotName)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
 func sortedTwoColumnResponseWriter(llrb sortedmap.LLRBTree, responseWriter http.ResponseWriter) {
 	var (
 		err                  error
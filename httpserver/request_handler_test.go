@@ -2,20 +2,106 @@ package httpserver
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
 
 	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/evtlog"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/headhunter"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/jrpcfs"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/ramswift"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
 )
 
 func testSetup() (err error) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "ProxyFS_test_httpserver_")
+	if nil != err {
+		return
+	}
+
+	err = os.Chdir(testDir)
+	if nil != err {
+		return
+	}
+
 	testConfMapStrings := []string{
 		"Stats.IPAddr=localhost",
 		"Stats.UDPPort=52184",
 		"Stats.BufferLength=100",
+		"Stats.MaxLatency=1s",
+		"Logging.LogFilePath=proxyfsd.log",
+		"SwiftClient.NoAuthTCPPort=45263",
+		"SwiftClient.Timeout=10s",
+		"SwiftClient.RetryLimit=5",
+		"SwiftClient.RetryLimitObject=5",
+		"SwiftClient.RetryDelay=1s",
+		"SwiftClient.RetryDelayObject=1s",
+		"SwiftClient.RetryExpBackoff=1.2",
+		"SwiftClient.RetryExpBackoffObject=2.0",
+		"SwiftClient.ChunkedConnectionPoolSize=64",
+		"SwiftClient.NonChunkedConnectionPoolSize=32",
+		"SwiftClient.StarvationCallbackFrequency=100ms",
+		"FlowControl:TestFlowControl.MaxFlushSize=10000000",
+		"FlowControl:TestFlowControl.MaxFlushTime=10s",
+		"FlowControl:TestFlowControl.ReadCacheLineSize=1000000",
+		"FlowControl:TestFlowControl.ReadCacheWeight=100",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainerStoragePolicy=silver",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainerNamePrefix=Replicated3Way_",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.ContainersPerPeer=1000",
+		"PhysicalContainerLayout:PhysicalContainerLayoutReplicated3Way.MaxObjectsPerContainer=1000000",
+		"Peer:Peer0.PrivateIPAddr=localhost",
+		"Peer:Peer0.ReadCacheQuotaFraction=0.20",
+		"Cluster.Peers=Peer0",
+		"Cluster.WhoAmI=Peer0",
+		"Volume:TestVolume.FSID=1",
+		"Volume:TestVolume.PrimaryPeer=Peer0",
+		"Volume:TestVolume.AccountName=CommonAccount",
+		"Volume:TestVolume.CheckpointContainerName=.__checkpoint__",
+		"Volume:TestVolume.CheckpointContainerStoragePolicy=gold",
+		"Volume:TestVolume.CheckpointInterval=10s",
+		"Volume:TestVolume.CheckpointIntervalsPerCompaction=100",
+		"Volume:TestVolume.DefaultPhysicalContainerLayout=PhysicalContainerLayoutReplicated3Way",
+		"Volume:TestVolume.FlowControl=TestFlowControl",
+		"Volume:TestVolume.NonceValuesToReserve=100",
+		"Volume:TestVolume.MaxEntriesPerDirNode=32",
+		"Volume:TestVolume.MaxExtentsPerFileNode=32",
+		"Volume:TestVolume.MaxInodesPerMetadataNode=32",
+		"Volume:TestVolume.MaxLogSegmentsPerMetadataNode=64",
+		"Volume:TestVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:TestVolume.DefragInterval=1h",
+		"Volume:TestVolume.TrashRetention=1h",
+		"FSGlobals.VolumeList=TestVolume",
+		"FSGlobals.InodeRecCacheEvictLowLimit=10000",
+		"FSGlobals.InodeRecCacheEvictHighLimit=10010",
+		"FSGlobals.LogSegmentRecCacheEvictLowLimit=10000",
+		"FSGlobals.LogSegmentRecCacheEvictHighLimit=10010",
+		"FSGlobals.BPlusTreeObjectCacheEvictLowLimit=10000",
+		"FSGlobals.BPlusTreeObjectCacheEvictHighLimit=10010",
+		"FSGlobals.DirEntryCacheEvictLowLimit=10000",
+		"FSGlobals.DirEntryCacheEvictHighLimit=10010",
+		"FSGlobals.FileExtentMapEvictLowLimit=10000",
+		"FSGlobals.FileExtentMapEvictHighLimit=10010",
+		"RamSwiftInfo.MaxAccountNameLength=256",
+		"RamSwiftInfo.MaxContainerNameLength=256",
+		"RamSwiftInfo.MaxObjectNameLength=1024",
+		"JSONRPCServer.TCPPort=12347",
+		"JSONRPCServer.FastTCPPort=32347",
+		"JSONRPCServer.DataPathLogging=false",
+		"HTTPServer.TCPPort=53462",
 	}
 
 	testConfMap, err := conf.MakeConfMapFromStrings(testConfMapStrings)
@@ -23,12 +109,164 @@ func testSetup() (err error) {
 		return
 	}
 
-	globals.confMap = testConfMap
+	signalHandlerIsArmed := false
+	doneChan := make(chan bool, 1)
+	go ramswift.Daemon("/dev/null", testConfMapStrings, &signalHandlerIsArmed, doneChan, unix.SIGTERM)
+
+	err = logger.Up(testConfMap)
+	if nil != err {
+		return
+	}
+
+	err = evtlog.Up(testConfMap)
+	if nil != err {
+		logger.Down()
+		return
+	}
+
+	err = stats.Up(testConfMap)
+	if nil != err {
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = dlm.Up(testConfMap)
+	if nil != err {
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = swiftclient.Up(testConfMap)
+	if nil != err {
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
 
-	return nil
+	err = headhunter.Format(testConfMap, "TestVolume")
+	if nil != err {
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = headhunter.Up(testConfMap)
+	if nil != err {
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = inode.Up(testConfMap)
+	if nil != err {
+		headhunter.Down()
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = fs.Up(testConfMap)
+	if nil != err {
+		inode.Down()
+		headhunter.Down()
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = jrpcfs.Up(testConfMap)
+	if nil != err {
+		fs.Down()
+		inode.Down()
+		headhunter.Down()
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = Up(testConfMap)
+	if nil != err {
+		jrpcfs.Down()
+		fs.Down()
+		inode.Down()
+		headhunter.Down()
+		swiftclient.Down()
+		dlm.Down()
+		stats.Down()
+		evtlog.Down()
+		logger.Down()
+		return
+	}
+
+	err = nil
+	return
 }
 
 func testTeardown() (err error) {
+	Down()
+	jrpcfs.Down()
+	fs.Down()
+	inode.Down()
+	headhunter.Down()
+	swiftclient.Down()
+	dlm.Down()
+	stats.Down()
+	evtlog.Down()
+	logger.Down()
+
+	testDir, err := os.Getwd()
+	if nil != err {
+		return
+	}
+
+	err = os.Chdir("..")
+	if nil != err {
+		return
+	}
+
+	err = os.RemoveAll(testDir)
+	if nil != err {
+		return
+	}
+
+	err = nil
+	return
+}
+
+// testDoRequest issues a request (with the optional Accept header) via ServeHTTP() returning the response
+func testDoRequest(method string, url string, accept string) (statusCode int, body []byte) {
+	request := httptest.NewRequest(method, "http://pfs.com"+url, nil)
+	if "" != accept {
+		request.Header.Set("Accept", accept)
+	}
+	responseRecorder := httptest.NewRecorder()
+
+	httpRequestHandler{}.ServeHTTP(responseRecorder, request)
+
+	response := responseRecorder.Result()
+	body, _ = ioutil.ReadAll(response.Body)
+	statusCode = response.StatusCode
+
 	return
 }
 
@@ -103,3 +341,552 @@ func TestConfigExpansion(t *testing.T) {
 
 	return
 }
+
+func TestDefragJob(t *testing.T) {
+	fetchDefragJobState := func() (state string) {
+		var (
+			defragJobStatus defragJobStatusStruct
+		)
+
+		statusCode, body := testDoRequest("GET", "/volume/TestVolume/defrag-job", "application/json")
+		if http.StatusOK != statusCode {
+			t.Fatalf("GET /volume/TestVolume/defrag-job returned %d; expected %d", statusCode, http.StatusOK)
+		}
+		err := json.Unmarshal(body, &defragJobStatus)
+		if nil != err {
+			t.Fatalf("GET /volume/TestVolume/defrag-job returned undecodable body: %v", err)
+		}
+		state = defragJobStatus.State
+		return
+	}
+
+	if "Idle" != fetchDefragJobState() {
+		t.Fatalf("defrag-job should have started out Idle")
+	}
+
+	statusCode, body := testDoRequest("GET", "/volume/TestVolume/defrag-job", "")
+	if (http.StatusOK != statusCode) || !bytes.Contains(body, []byte("/volume/TestVolume/defrag-job/pause")) {
+		t.Fatalf("GET /volume/TestVolume/defrag-job [text/html] returned %d without a Pause form", statusCode)
+	}
+
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/defrag-job/pause", "")
+	if http.StatusSeeOther != statusCode {
+		t.Fatalf("POST /volume/TestVolume/defrag-job/pause returned %d; expected %d", statusCode, http.StatusSeeOther)
+	}
+	if "Paused" != fetchDefragJobState() {
+		t.Fatalf("defrag-job should have been Paused")
+	}
+
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/defrag-job/resume", "")
+	if http.StatusSeeOther != statusCode {
+		t.Fatalf("POST /volume/TestVolume/defrag-job/resume returned %d; expected %d", statusCode, http.StatusSeeOther)
+	}
+	if "Idle" != fetchDefragJobState() {
+		t.Fatalf("defrag-job should have been Idle once resumed")
+	}
+
+	for _, notFoundRequest := range []struct {
+		method string
+		url    string
+	}{
+		{"GET", "/volume/NoSuchVolume/defrag-job"},
+		{"GET", "/volume/TestVolume/defrag-job/pause"},
+		{"POST", "/volume/NoSuchVolume/defrag-job/pause"},
+		{"POST", "/volume/TestVolume/defrag-job"},
+		{"POST", "/volume/TestVolume/defrag-job/restart"},
+	} {
+		statusCode, _ = testDoRequest(notFoundRequest.method, notFoundRequest.url, "")
+		if http.StatusNotFound != statusCode {
+			t.Fatalf("%s %s returned %d; expected %d", notFoundRequest.method, notFoundRequest.url, statusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestInodeFragmentation(t *testing.T) {
+	var (
+		inodeFragmentationReport inodeFragmentationReportStruct
+	)
+
+	mountHandle, err := fs.Mount("TestVolume", fs.MountOptions(0))
+	if nil != err {
+		t.Fatalf("fs.Mount(\"TestVolume\",) failed: %v", err)
+	}
+
+	fileInodeNumber, err := mountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "FragmentedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	for fileOffset := uint64(0); fileOffset < 16; fileOffset += 4 {
+		_, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber, fileOffset, []byte{0x00, 0x01, 0x02, 0x03}, nil)
+		if nil != err {
+			t.Fatalf("Write(,,,,%v,,) failed: %v", fileOffset, err)
+		}
+		err = mountHandle.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, fileInodeNumber)
+		if nil != err {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+	}
+
+	fragmentationURL := fmt.Sprintf("/volume/TestVolume/inode/%d/fragmentation", fileInodeNumber)
+
+	statusCode, body := testDoRequest("GET", fragmentationURL, "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET %s returned %d; expected %d", fragmentationURL, statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &inodeFragmentationReport)
+	if nil != err {
+		t.Fatalf("GET %s returned undecodable body: %v", fragmentationURL, err)
+	}
+	if (uint64(fileInodeNumber) != inodeFragmentationReport.InodeNumber) || (4 != inodeFragmentationReport.NumberOfFragments) || (16 != inodeFragmentationReport.BytesInFragments) {
+		t.Fatalf("GET %s returned unexpected %+v", fragmentationURL, inodeFragmentationReport)
+	}
+
+	statusCode, body = testDoRequest("GET", fragmentationURL, "")
+	if (http.StatusOK != statusCode) || !bytes.Contains(body, []byte("Number Of Fragments")) {
+		t.Fatalf("GET %s [text/html] returned %d without a fragmentation report", fragmentationURL, statusCode)
+	}
+
+	statusCode, _ = testDoRequest("GET", fmt.Sprintf("/volume/TestVolume/inode/%d/fragmentation", inode.RootDirInodeNumber), "")
+	if http.StatusBadRequest != statusCode {
+		t.Fatalf("GET of a DirInode's fragmentation returned %d; expected %d", statusCode, http.StatusBadRequest)
+	}
+
+	for _, notFoundURL := range []string{
+		"/volume/NoSuchVolume/inode/1/fragmentation",
+		"/volume/TestVolume/inode/NotANumber/fragmentation",
+		"/volume/TestVolume/inode/999999999/fragmentation",
+		fmt.Sprintf("/volume/TestVolume/inode/%d/layout", fileInodeNumber),
+	} {
+		statusCode, _ = testDoRequest("GET", notFoundURL, "")
+		if http.StatusNotFound != statusCode {
+			t.Fatalf("GET %s returned %d; expected %d", notFoundURL, statusCode, http.StatusNotFound)
+		}
+	}
+
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "FragmentedFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var (
+		snapshotStatus     snapshotStatusStruct
+		snapshotStatusList []snapshotStatusStruct
+	)
+
+	statusCode, _ := testDoRequest("POST", "/volume/TestVolume/snapshot?name=TestSnapshot", "")
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST /volume/TestVolume/snapshot?name=TestSnapshot returned %d; expected %d", statusCode, http.StatusCreated)
+	}
+
+	statusCode, body := testDoRequest("GET", "/volume/TestVolume/snapshot", "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /volume/TestVolume/snapshot returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err := json.Unmarshal(body, &snapshotStatusList)
+	if nil != err {
+		t.Fatalf("GET /volume/TestVolume/snapshot returned undecodable body: %v", err)
+	}
+	if (1 != len(snapshotStatusList)) || ("TestSnapshot" != snapshotStatusList[0].Name) {
+		t.Fatalf("GET /volume/TestVolume/snapshot returned unexpected %+v", snapshotStatusList)
+	}
+
+	statusCode, body = testDoRequest("GET", "/volume/TestVolume/snapshot/TestSnapshot", "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /volume/TestVolume/snapshot/TestSnapshot returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &snapshotStatus)
+	if nil != err {
+		t.Fatalf("GET /volume/TestVolume/snapshot/TestSnapshot returned undecodable body: %v", err)
+	}
+	if (snapshotStatusList[0].ID != snapshotStatus.ID) || ("TestSnapshot" != snapshotStatus.Name) {
+		t.Fatalf("GET /volume/TestVolume/snapshot/TestSnapshot returned unexpected %+v", snapshotStatus)
+	}
+
+	statusCode, body = testDoRequest("GET", "/volume/TestVolume/snapshot", "")
+	if (http.StatusOK != statusCode) || !bytes.Contains(body, []byte("/volume/TestVolume/snapshot/TestSnapshot/delete")) {
+		t.Fatalf("GET /volume/TestVolume/snapshot [text/html] returned %d without a Delete form for TestSnapshot", statusCode)
+	}
+
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/snapshot/TestSnapshot", "")
+	if http.StatusConflict != statusCode {
+		t.Fatalf("POST of a duplicate snapshot returned %d; expected %d", statusCode, http.StatusConflict)
+	}
+
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/snapshot", "")
+	if http.StatusBadRequest != statusCode {
+		t.Fatalf("POST of an unnamed snapshot returned %d; expected %d", statusCode, http.StatusBadRequest)
+	}
+
+	statusCode, _ = testDoRequest("DELETE", "/volume/TestVolume/snapshot/TestSnapshot", "")
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("DELETE /volume/TestVolume/snapshot/TestSnapshot returned %d; expected %d", statusCode, http.StatusNoContent)
+	}
+
+	// An HTML form's equivalent of DELETE
+
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/snapshot/OtherSnapshot", "")
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST /volume/TestVolume/snapshot/OtherSnapshot returned %d; expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, _ = testDoRequest("POST", "/volume/TestVolume/snapshot/OtherSnapshot/delete", "")
+	if http.StatusSeeOther != statusCode {
+		t.Fatalf("POST /volume/TestVolume/snapshot/OtherSnapshot/delete returned %d; expected %d", statusCode, http.StatusSeeOther)
+	}
+
+	for _, notFoundRequest := range []struct {
+		method string
+		url    string
+	}{
+		{"GET", "/volume/NoSuchVolume/snapshot"},
+		{"GET", "/volume/TestVolume/snapshot/TestSnapshot"},
+		{"POST", "/volume/NoSuchVolume/snapshot/TestSnapshot"},
+		{"POST", "/volume/TestVolume/snapshot/TestSnapshot/delete"},
+		{"POST", "/volume/TestVolume/snapshot/TestSnapshot/rename"},
+		{"DELETE", "/volume/NoSuchVolume/snapshot/TestSnapshot"},
+		{"DELETE", "/volume/TestVolume/snapshot/TestSnapshot"},
+	} {
+		statusCode, _ = testDoRequest(notFoundRequest.method, notFoundRequest.url, "")
+		if http.StatusNotFound != statusCode {
+			t.Fatalf("%s %s returned %d; expected %d", notFoundRequest.method, notFoundRequest.url, statusCode, http.StatusNotFound)
+		}
+	}
+}
+
+// testDegradedVolumeHandleStruct reports a degraded CheckpointHealth for the wrapped headhunter.VolumeHandle
+type testDegradedVolumeHandleStruct struct {
+	headhunter.VolumeHandle
+}
+
+func (testDegradedVolumeHandle *testDegradedVolumeHandleStruct) FetchCheckpointHealth() (checkpointHealth headhunter.CheckpointHealthStruct) {
+	checkpointHealth = testDegradedVolumeHandle.VolumeHandle.FetchCheckpointHealth()
+
+	checkpointHealth.State = headhunter.CheckpointDegraded
+	checkpointHealth.ConsecutiveFailures = 3
+	checkpointHealth.LastFailureTime = time.Now()
+	checkpointHealth.LastFailureErr = fmt.Errorf("injected checkpoint failure")
+	checkpointHealth.NextRetryTime = time.Now().Add(time.Minute)
+
+	return
+}
+
+func TestHealth(t *testing.T) {
+	var (
+		checkpointHealthStatus    checkpointHealthStatusStruct
+		checkpointHealthStatusMap map[string]checkpointHealthStatusStruct
+	)
+
+	statusCode, body := testDoRequest("GET", "/health", "")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /health returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err := json.Unmarshal(body, &checkpointHealthStatusMap)
+	if nil != err {
+		t.Fatalf("GET /health returned undecodable body: %v", err)
+	}
+	if "Healthy" != checkpointHealthStatusMap["TestVolume"].State {
+		t.Fatalf("GET /health returned unexpected %+v", checkpointHealthStatusMap)
+	}
+
+	statusCode, body = testDoRequest("GET", "/volume/TestVolume/health", "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /volume/TestVolume/health returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &checkpointHealthStatus)
+	if nil != err {
+		t.Fatalf("GET /volume/TestVolume/health returned undecodable body: %v", err)
+	}
+	if ("Healthy" != checkpointHealthStatus.State) || (0 != checkpointHealthStatus.ConsecutiveFailures) {
+		t.Fatalf("GET /volume/TestVolume/health returned unexpected %+v", checkpointHealthStatus)
+	}
+
+	for _, notFoundURL := range []string{
+		"/volume/NoSuchVolume/health",
+		"/volume/TestVolume/health/checkpoint",
+	} {
+		statusCode, _ = testDoRequest("GET", notFoundURL, "")
+		if http.StatusNotFound != statusCode {
+			t.Fatalf("GET %s returned %d; expected %d", notFoundURL, statusCode, http.StatusNotFound)
+		}
+	}
+
+	// A degraded volume should render the node unavailable
+
+	volumeAsValue, ok, err := globals.volumeLLRB.GetByKey("TestVolume")
+	if (nil != err) || !ok {
+		t.Fatalf("globals.volumeLLRB.GetByKey(\"TestVolume\") failed: ok: %v err: %v", ok, err)
+	}
+	volume := volumeAsValue.(*volumeStruct)
+
+	headhunterHandle := volume.headhunterHandle
+	volume.headhunterHandle = &testDegradedVolumeHandleStruct{VolumeHandle: headhunterHandle}
+	defer func() { volume.headhunterHandle = headhunterHandle }()
+
+	statusCode, body = testDoRequest("GET", "/health", "")
+	if http.StatusServiceUnavailable != statusCode {
+		t.Fatalf("GET /health of a degraded volume returned %d; expected %d", statusCode, http.StatusServiceUnavailable)
+	}
+	checkpointHealthStatusMap = nil
+	err = json.Unmarshal(body, &checkpointHealthStatusMap)
+	if nil != err {
+		t.Fatalf("GET /health of a degraded volume returned undecodable body: %v", err)
+	}
+	if ("Degraded" != checkpointHealthStatusMap["TestVolume"].State) || ("injected checkpoint failure" != checkpointHealthStatusMap["TestVolume"].LastFailureError) {
+		t.Fatalf("GET /health of a degraded volume returned unexpected %+v", checkpointHealthStatusMap)
+	}
+
+	statusCode, body = testDoRequest("GET", "/volume/TestVolume/health", "")
+	if (http.StatusOK != statusCode) || !bytes.Contains(body, []byte("<td>Degraded</td>")) {
+		t.Fatalf("GET /volume/TestVolume/health [text/html] of a degraded volume returned %d without its Degraded state", statusCode)
+	}
+}
+
+func TestMounts(t *testing.T) {
+	var (
+		mountReply      jrpcfs.MountReply
+		mountStatusList []mountStatusStruct
+		unmountReply    jrpcfs.Reply
+	)
+
+	jrpcfsServer := jrpcfs.NewServer()
+
+	err := jrpcfsServer.RpcMount(&jrpcfs.MountRequest{VolumeName: "TestVolume", AuthUserID: 1000, AuthGroupID: 2000}, &mountReply)
+	if nil != err {
+		t.Fatalf("RpcMount() failed: %v", err)
+	}
+
+	statusCode, body := testDoRequest("GET", "/mounts", "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /mounts returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &mountStatusList)
+	if nil != err {
+		t.Fatalf("GET /mounts returned undecodable body: %v", err)
+	}
+	if (1 != len(mountStatusList)) || (mountReply.MountID != mountStatusList[0].MountID) || ("TestVolume" != mountStatusList[0].VolumeName) || (1000 != mountStatusList[0].AuthUserID) || (2000 != mountStatusList[0].AuthGroupID) {
+		t.Fatalf("GET /mounts returned unexpected %+v", mountStatusList)
+	}
+
+	statusCode, body = testDoRequest("GET", "/mounts", "")
+	if (http.StatusOK != statusCode) || !bytes.Contains(body, []byte(fmt.Sprintf("<td>%v</td>", mountReply.MountID))) {
+		t.Fatalf("GET /mounts [text/html] returned %d without MountID %v", statusCode, mountReply.MountID)
+	}
+
+	err = jrpcfsServer.RpcUnmount(&jrpcfs.UnmountRequest{MountID: mountReply.MountID}, &unmountReply)
+	if nil != err {
+		t.Fatalf("RpcUnmount() failed: %v", err)
+	}
+
+	statusCode, body = testDoRequest("GET", "/mounts", "application/json")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /mounts [after RpcUnmount()] returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	mountStatusList = nil
+	err = json.Unmarshal(body, &mountStatusList)
+	if (nil != err) || (0 != len(mountStatusList)) {
+		t.Fatalf("GET /mounts [after RpcUnmount()] returned unexpected %+v [err: %v]", mountStatusList, err)
+	}
+
+	statusCode, _ = testDoRequest("GET", fmt.Sprintf("/mounts/%v", mountReply.MountID), "")
+	if http.StatusNotFound != statusCode {
+		t.Fatalf("GET /mounts/<MountID> returned %d; expected %d", statusCode, http.StatusNotFound)
+	}
+
+	statusCode, _ = testDoRequest("POST", "/mounts", "")
+	if http.StatusNotFound != statusCode {
+		t.Fatalf("POST /mounts returned %d; expected %d", statusCode, http.StatusNotFound)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var (
+		watchEventStatusList []watchEventStatusStruct
+	)
+
+	addWatch := func(url string) (statusCode int, location string) {
+		request := httptest.NewRequest("POST", "http://pfs.com"+url, nil)
+		responseRecorder := httptest.NewRecorder()
+
+		httpRequestHandler{}.ServeHTTP(responseRecorder, request)
+
+		statusCode = responseRecorder.Result().StatusCode
+		location = responseRecorder.Result().Header.Get("Location")
+		return
+	}
+
+	mountHandle, err := fs.Mount("TestVolume", fs.MountOptions(0))
+	if nil != err {
+		t.Fatalf("fs.Mount(\"TestVolume\",) failed: %v", err)
+	}
+
+	dirInodeNumber, err := mountHandle.Mkdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "WatchedDir", inode.InodeMode(0700))
+	if nil != err {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+
+	statusCode, location := addWatch(fmt.Sprintf("/volume/TestVolume/watch?inode=%d&uid=0&gid=0", dirInodeNumber))
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST /volume/TestVolume/watch returned %d; expected %d", statusCode, http.StatusCreated)
+	}
+
+	fileInodeNumber, err := mountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "WatchedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	statusCode, body := testDoRequest("GET", location+"?timeout=1s", "")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET %s returned %d; expected %d", location, statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &watchEventStatusList)
+	if nil != err {
+		t.Fatalf("GET %s returned undecodable body: %v", location, err)
+	}
+	if (1 != len(watchEventStatusList)) || ("create" != watchEventStatusList[0].Type) || ("WatchedFile" != watchEventStatusList[0].Basename) || (uint64(fileInodeNumber) != watchEventStatusList[0].InodeNumber) {
+		t.Fatalf("GET %s returned unexpected %+v", location, watchEventStatusList)
+	}
+
+	statusCode, _ = testDoRequest("GET", location+"?timeout=soon", "")
+	if http.StatusBadRequest != statusCode {
+		t.Fatalf("GET %s?timeout=soon returned %d; expected %d", location, statusCode, http.StatusBadRequest)
+	}
+
+	statusCode, _ = testDoRequest("DELETE", location, "")
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("DELETE %s returned %d; expected %d", location, statusCode, http.StatusNoContent)
+	}
+
+	// An HTML form's equivalent of DELETE
+
+	statusCode, otherLocation := addWatch(fmt.Sprintf("/volume/TestVolume/watch?inode=%d&uid=0&gid=0", dirInodeNumber))
+	if http.StatusCreated != statusCode {
+		t.Fatalf("POST /volume/TestVolume/watch [again] returned %d; expected %d", statusCode, http.StatusCreated)
+	}
+	statusCode, _ = testDoRequest("POST", otherLocation+"/delete", "")
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("POST %s/delete returned %d; expected %d", otherLocation, statusCode, http.StatusNoContent)
+	}
+
+	for _, errorRequest := range []struct {
+		method     string
+		url        string
+		statusCode int
+	}{
+		{"POST", fmt.Sprintf("/volume/TestVolume/watch?inode=%d&uid=1000&gid=1000", dirInodeNumber), http.StatusForbidden},
+		{"POST", fmt.Sprintf("/volume/TestVolume/watch?inode=%d&uid=0&gid=0", fileInodeNumber), http.StatusBadRequest},
+		{"POST", "/volume/TestVolume/watch?inode=WatchedDir&uid=0&gid=0", http.StatusBadRequest},
+		{"POST", fmt.Sprintf("/volume/TestVolume/watch?inode=%d", dirInodeNumber), http.StatusBadRequest},
+		{"POST", "/volume/TestVolume/watch?inode=999999999&uid=0&gid=0", http.StatusNotFound},
+		{"POST", fmt.Sprintf("/volume/NoSuchVolume/watch?inode=%d&uid=0&gid=0", dirInodeNumber), http.StatusNotFound},
+		{"POST", location + "/restore", http.StatusNotFound},
+		{"GET", location + "?timeout=1s", http.StatusNotFound},
+		{"GET", "/volume/TestVolume/watch/NotANumber", http.StatusNotFound},
+		{"DELETE", location, http.StatusNotFound},
+	} {
+		statusCode, _ = testDoRequest(errorRequest.method, errorRequest.url, "")
+		if errorRequest.statusCode != statusCode {
+			t.Fatalf("%s %s returned %d; expected %d", errorRequest.method, errorRequest.url, statusCode, errorRequest.statusCode)
+		}
+	}
+
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, "WatchedFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+	err = mountHandle.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "WatchedDir")
+	if nil != err {
+		t.Fatalf("Rmdir() failed: %v", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	var (
+		trashEntryStatus      trashEntryStatusStruct
+		trashEntryStatusSlice []trashEntryStatusStruct
+	)
+
+	mountHandle, err := fs.Mount("TestVolume", fs.MountOptions(0))
+	if nil != err {
+		t.Fatalf("fs.Mount(\"TestVolume\",) failed: %v", err)
+	}
+
+	fileInodeNumber, err := mountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile")
+	if nil != err {
+		t.Fatalf("Unlink() failed: %v", err)
+	}
+
+	statusCode, body := testDoRequest("GET", "/volume/TestVolume/trash", "")
+	if http.StatusOK != statusCode {
+		t.Fatalf("GET /volume/TestVolume/trash returned %d; expected %d", statusCode, http.StatusOK)
+	}
+	err = json.Unmarshal(body, &trashEntryStatusSlice)
+	if nil != err {
+		t.Fatalf("GET /volume/TestVolume/trash returned undecodable body: %v", err)
+	}
+	for _, trashEntryStatusElement := range trashEntryStatusSlice {
+		if uint64(fileInodeNumber) == trashEntryStatusElement.InodeNumber {
+			trashEntryStatus = trashEntryStatusElement
+		}
+	}
+	if ("TrashedFile" != trashEntryStatus.Basename) || ("file" != trashEntryStatus.InodeType) || (uint64(inode.RootDirInodeNumber) != trashEntryStatus.ParentInodeNumber) {
+		t.Fatalf("GET /volume/TestVolume/trash returned unexpected %+v", trashEntryStatusSlice)
+	}
+
+	restoreURL := "/volume/TestVolume/trash/" + url.PathEscape(trashEntryStatus.Name) + "/restore"
+
+	// Restoring over a new file of the same name should conflict
+
+	_, err = mountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile", inode.PosixModePerm)
+	if nil != err {
+		t.Fatalf("Create() [again] failed: %v", err)
+	}
+
+	statusCode, _ = testDoRequest("POST", restoreURL, "")
+	if http.StatusConflict != statusCode {
+		t.Fatalf("POST %s over an existing file returned %d; expected %d", restoreURL, statusCode, http.StatusConflict)
+	}
+
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile")
+	if nil != err {
+		t.Fatalf("Unlink() [again] failed: %v", err)
+	}
+
+	statusCode, _ = testDoRequest("POST", restoreURL, "")
+	if http.StatusNoContent != statusCode {
+		t.Fatalf("POST %s returned %d; expected %d", restoreURL, statusCode, http.StatusNoContent)
+	}
+
+	restoredInodeNumber, err := mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile")
+	if (nil != err) || (fileInodeNumber != restoredInodeNumber) {
+		t.Fatalf("Lookup() of restored file returned inode %v [err: %v]; expected %v", restoredInodeNumber, err, fileInodeNumber)
+	}
+
+	for _, errorRequest := range []struct {
+		method     string
+		url        string
+		statusCode int
+	}{
+		{"POST", restoreURL, http.StatusNotFound},
+		{"POST", "/volume/TestVolume/trash/NotATrashEntry/restore", http.StatusBadRequest},
+		{"POST", "/volume/TestVolume/trash/" + url.PathEscape(trashEntryStatus.Name) + "/undelete", http.StatusNotFound},
+		{"POST", "/volume/NoSuchVolume/trash/" + url.PathEscape(trashEntryStatus.Name) + "/restore", http.StatusNotFound},
+		{"GET", "/volume/NoSuchVolume/trash", http.StatusNotFound},
+		{"GET", "/volume/TestVolume/trash/" + url.PathEscape(trashEntryStatus.Name), http.StatusNotFound},
+	} {
+		statusCode, _ = testDoRequest(errorRequest.method, errorRequest.url, "")
+		if errorRequest.statusCode != statusCode {
+			t.Fatalf("%s %s returned %d; expected %d", errorRequest.method, errorRequest.url, statusCode, errorRequest.statusCode)
+		}
+	}
+
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "TrashedFile")
+	if nil != err {
+		t.Fatalf("Unlink() [restored] failed: %v", err)
+	}
+}
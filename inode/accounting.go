@@ -0,0 +1,939 @@
+package inode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// Each volume maintains an accounting of the number of inodes it holds and the number of (live) bytes of
+// LogSegments those inodes reference (i.e. the sum of each FileInode's LogSegmentMap). The same totals are
+// also maintained per owning UserID, per owning GroupID, and per QuotaTree (the DirInode at the top of a
+// tree of inodes tagged via SetQuotaTree() and InheritQuotaTree()). Rather than walking every inode to compute
+// these, the accounting is adjusted as each inodeRec is put or deleted and is itself kept in the inodeRec
+// B+Tree. The volume totals are keyed by accountingInodeNumber (a value headhunter.FetchNonce() never returns)
+// while the usage of each UserID, GroupID, and QuotaTree (as well as each shared LogSegment's count, see below)
+// is in its own inodeRec keyed by accountingKey() (values far above any headhunter.FetchNonce() will reach). As
+// flushInodes() puts the accounting inodeRecs it changed in the same headhunter.PutInodeRecs() call as the
+// inodeRecs that changed them, the accounting is checkpointed (and carried into snapshots) consistently with them.
+//
+// Only accountingPrepare() and accountingComplete() hold accountingMutex (briefly) during a flush. So that an
+// accounting inodeRec computed earlier is never put after one computed later, accountingPutMutex is held from
+// the time a flush computes its accounting inodeRecs until they have been put. An accounting inodeRec whose
+// usage drops to zero is put (as such) and then deleted.
+//
+// Each inMemoryInodeStruct remembers whether it has an inodeRec (onDisk) and what it contributed to the
+// accounting when last put (onDiskCharge) so that only the difference need be applied on each flush.
+// Note that Destroy() deletes the inodeRec and puts the updated accounting in two distinct operations.
+//
+// A volume formatted before the accounting was introduced (i.e. lacking the accountingInodeNumber inodeRec)
+// has its accounting computed by a scan of the inodeRec B+Tree the first time it is needed.
+//
+// The accounting also records, for each LogSegment referenced by more than one FileInode (as a result of
+// CloneRange()), the number of FileInodes beyond the first that reference it. When a FileInode ceases to
+// reference such a LogSegment (i.e. it is dropped from its LogSegmentMap or the FileInode is destroyed), the
+// count is merely decremented... the LogSegment is only deleted once the last FileInode referencing it lets go.
+//
+// Note that the bytes charged are those of the file data each FileInode references rather than those of the
+// LogSegments holding it. Hence, a LogSegment referenced by more than one FileInode (via CloneRange() or
+// deduplication) is charged once per referencing FileInode and a compressed LogSegment is charged at its
+// decompressed size. The usage reported (e.g. by StatVfs()) thus overstates the bytes physically consumed.
+
+const (
+	accountingInodeNumber = InodeNumber(0)
+
+	accountingKeyBase      = uint64(0x8000000000000000)
+	accountingKeyKindShift = 56
+	accountingKeyIDMask    = uint64(0x00FFFFFFFFFFFFFF)
+
+	accountingKeyKindUser         = uint64(1)
+	accountingKeyKindGroup        = uint64(2)
+	accountingKeyKindQuotaTree    = uint64(3)
+	accountingKeyKindSharedLogSeg = uint64(4)
+
+	accountingScanBatch = uint64(1024)
+)
+
+type accountingUsageV1Struct struct {
+	NumInodes uint64
+	UsedBytes uint64
+}
+
+type onDiskAccountingV1Struct struct { // Preceded "on disk" by CorruptionDetected then Version both in cstruct.LittleEndian form
+	NumInodes      uint64
+	UsedBytes      uint64
+	UserUsage      map[InodeUserID]accountingUsageV1Struct  // Omits UserIDs owning no inodes; "on disk" in inodeRecs keyed by accountingKey()
+	GroupUsage     map[InodeGroupID]accountingUsageV1Struct // Omits GroupIDs owning no inodes; "on disk" in inodeRecs keyed by accountingKey()
+	QuotaTreeUsage map[InodeNumber]accountingUsageV1Struct  // Omits QuotaTrees containing no inodes; "on disk" in inodeRecs keyed by accountingKey()
+	SharedLogSegs  map[uint64]uint64                        // Key == LogSegment#; Value == number of FileInodes beyond the first referencing it; "on disk" as above
+}
+
+type accountingChargeStruct struct { // What an inode contributes to the accounting
+	userID    InodeUserID
+	groupID   InodeGroupID
+	quotaTree InodeNumber
+	usedBytes uint64
+}
+
+type onDiskAccountingSharedLogSegV1Struct struct { // Preceded "on disk" by CorruptionDetected then Version both in cstruct.LittleEndian form
+	SharedCount uint64
+}
+
+type accountingDeltaStruct struct {
+	charges        []accountingChargeStruct // Charge of each inode to be put
+	applied        bool                     // If false, accounting was not available so charges were not applied
+	accountingKeys []uint64                 // Keys of the accounting inodeRecs to be put (empty if accounting is unchanged)
+	accountingRecs [][]byte                 // Accounting inodeRecs to be put
+}
+
+// accountingKey returns the key of the inodeRec holding the usage (or shared LogSegment count) of id
+//
+// Note: As id is limited to 56 bits, QuotaTree InodeNumbers and LogSegmentNumbers are assumed never to exceed that
+func accountingKey(kind uint64, id uint64) (key uint64) {
+	key = accountingKeyBase | (kind << accountingKeyKindShift) | (id & accountingKeyIDMask)
+	return
+}
+
+func (onDiskInodeV1 *onDiskInodeV1Struct) accountingCharge() (charge accountingChargeStruct) {
+	charge = accountingChargeStruct{
+		userID:    onDiskInodeV1.UserID,
+		groupID:   onDiskInodeV1.GroupID,
+		quotaTree: onDiskInodeV1.QuotaTree,
+		usedBytes: 0,
+	}
+	for _, logSegmentBytesUsed := range onDiskInodeV1.LogSegmentMap {
+		charge.usedBytes += logSegmentBytesUsed
+	}
+	return
+}
+
+func accountingAdjust(value uint64, delta uint64, credit bool) (adjustedValue uint64) {
+	if !credit {
+		adjustedValue = value + delta
+	} else if delta < value {
+		adjustedValue = value - delta
+	} else {
+		adjustedValue = 0
+	}
+	return
+}
+
+func (usage accountingUsageV1Struct) adjust(charge accountingChargeStruct, credit bool) (adjustedUsage accountingUsageV1Struct) {
+	adjustedUsage = accountingUsageV1Struct{
+		NumInodes: accountingAdjust(usage.NumInodes, 1, credit),
+		UsedBytes: accountingAdjust(usage.UsedBytes, charge.usedBytes, credit),
+	}
+	return
+}
+
+// applyWhileLocked is like onDiskAccountingV1Struct.apply() but also notes which accounting inodeRecs are now dirty
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) applyWhileLocked(charge accountingChargeStruct, credit bool) {
+	vS.accounting.apply(charge, credit)
+
+	vS.accountingDirty = true
+	vS.accountingDirtyKeys[accountingKey(accountingKeyKindUser, uint64(charge.userID))] = struct{}{}
+	vS.accountingDirtyKeys[accountingKey(accountingKeyKindGroup, uint64(charge.groupID))] = struct{}{}
+	if 0 != charge.quotaTree {
+		vS.accountingDirtyKeys[accountingKey(accountingKeyKindQuotaTree, uint64(charge.quotaTree))] = struct{}{}
+	}
+}
+
+// apply adds (or, if credit, removes) an inode's charge to the accounting
+func (onDiskAccounting *onDiskAccountingV1Struct) apply(charge accountingChargeStruct, credit bool) {
+	var (
+		usage accountingUsageV1Struct
+	)
+
+	onDiskAccounting.NumInodes = accountingAdjust(onDiskAccounting.NumInodes, 1, credit)
+	onDiskAccounting.UsedBytes = accountingAdjust(onDiskAccounting.UsedBytes, charge.usedBytes, credit)
+
+	usage = onDiskAccounting.UserUsage[charge.userID].adjust(charge, credit)
+	if 0 == usage.NumInodes {
+		delete(onDiskAccounting.UserUsage, charge.userID)
+	} else {
+		onDiskAccounting.UserUsage[charge.userID] = usage
+	}
+
+	usage = onDiskAccounting.GroupUsage[charge.groupID].adjust(charge, credit)
+	if 0 == usage.NumInodes {
+		delete(onDiskAccounting.GroupUsage, charge.groupID)
+	} else {
+		onDiskAccounting.GroupUsage[charge.groupID] = usage
+	}
+
+	if 0 != charge.quotaTree {
+		usage = onDiskAccounting.QuotaTreeUsage[charge.quotaTree].adjust(charge, credit)
+		if 0 == usage.NumInodes {
+			delete(onDiskAccounting.QuotaTreeUsage, charge.quotaTree)
+		} else {
+			onDiskAccounting.QuotaTreeUsage[charge.quotaTree] = usage
+		}
+	}
+}
+
+func (vS *volumeStruct) FetchVolumeUsage() (usedBytes uint64, numInodes uint64, err error) {
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	usedBytes = vS.accounting.UsedBytes
+	numInodes = vS.accounting.NumInodes
+
+	return
+}
+
+func (vS *volumeStruct) FetchUserUsage(userID InodeUserID) (usedBytes uint64, numInodes uint64, err error) {
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	usage := vS.accounting.UserUsage[userID]
+
+	usedBytes = usage.UsedBytes
+	numInodes = usage.NumInodes
+
+	return
+}
+
+func (vS *volumeStruct) FetchGroupUsage(groupID InodeGroupID) (usedBytes uint64, numInodes uint64, err error) {
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	usage := vS.accounting.GroupUsage[groupID]
+
+	usedBytes = usage.UsedBytes
+	numInodes = usage.NumInodes
+
+	return
+}
+
+func (vS *volumeStruct) FetchQuotaTreeUsage(quotaTree InodeNumber) (usedBytes uint64, numInodes uint64, err error) {
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	usage := vS.accounting.QuotaTreeUsage[quotaTree]
+
+	usedBytes = usage.UsedBytes
+	numInodes = usage.NumInodes
+
+	return
+}
+
+// FetchInodeUsage returns the bytes inodeNumber is charged in the accounting once next flushed (usedBytes) as well
+// as by how much that exceeds what it is charged at present (unflushedBytes)
+//
+// Note: Caller must hold a lock on inodeNumber (so that its LogSegmentMap may be read)
+func (vS *volumeStruct) FetchInodeUsage(inodeNumber InodeNumber) (usedBytes uint64, unflushedBytes uint64, err error) {
+	var (
+		charge accountingChargeStruct
+		inode  *inMemoryInodeStruct
+		ok     bool
+	)
+
+	inode, ok, err = vS.fetchInode(inodeNumber)
+	if nil != err {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: inode %v of volume '%s' is unallocated", utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	charge = inode.accountingCharge()
+
+	usedBytes = charge.usedBytes
+
+	if !inode.onDisk {
+		unflushedBytes = charge.usedBytes
+	} else if charge.usedBytes > inode.onDiskCharge.usedBytes {
+		unflushedBytes = charge.usedBytes - inode.onDiskCharge.usedBytes
+	} else {
+		unflushedBytes = 0
+	}
+
+	return
+}
+
+// SetQuotaTree tags an inode as contained in the quota tree topped by DirInode quotaTree (or, if 0, in none)
+func (vS *volumeStruct) SetQuotaTree(inodeNumber InodeNumber, quotaTree InodeNumber) (err error) {
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if quotaTree == inode.QuotaTree {
+		err = nil
+		return
+	}
+
+	inode.dirty = true
+	inode.QuotaTree = quotaTree
+	inode.AttrChangeTime = time.Now()
+
+	err = vS.flushInode(inode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	return
+}
+
+// InheritQuotaTree tags a newly created inode as contained in the same quota tree (if any) as dirInodeNumber
+func (vS *volumeStruct) InheritQuotaTree(dirInodeNumber InodeNumber, inodeNumber InodeNumber) (err error) {
+	dirInode, ok, err := vS.fetchInode(dirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: fetch of directory inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), dirInodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if 0 == dirInode.QuotaTree {
+		// Nothing to inherit
+		err = nil
+		return
+	}
+
+	err = vS.SetQuotaTree(inodeNumber, dirInode.QuotaTree)
+
+	return
+}
+
+// accountingLoadWhileLocked fetches (or, if absent, computes) the volume's accounting if not already loaded
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) accountingLoadWhileLocked() (err error) {
+	var (
+		inodeNumbers      []uint64
+		inodeRec          []byte
+		inodeRecBody      []byte
+		keyedInodeNumber  uint64
+		keyedInodeNumbers []uint64
+		lastInodeNumber   uint64
+		logSegmentNumber  uint64
+		logSegmentRefs    map[uint64]uint64
+		ok                bool
+		onDiskAccounting  onDiskAccountingV1Struct
+		onDiskInodeV1     *onDiskInodeV1Struct
+	)
+
+	if vS.accountingLoaded {
+		err = nil
+		return
+	}
+
+	inodeRec, ok, err = vS.headhunterVolumeHandle.GetInodeRec(uint64(accountingInodeNumber))
+	if (nil == err) && ok {
+		inodeRecBody, err = unpackInodeRecPreamble(accountingInodeNumber, inodeRec)
+		if nil != err {
+			return
+		}
+		err = json.Unmarshal(inodeRecBody, &onDiskAccounting)
+		if nil != err {
+			err = fmt.Errorf("%s: accounting of volume '%s' json.Unmarshal() failed: %v", utils.GetFnName(), vS.volumeName, err)
+			err = blunder.AddError(err, blunder.CorruptInodeError)
+			return
+		}
+
+		err = vS.accountingLoadKeyedRecsWhileLocked(&onDiskAccounting)
+		if nil != err {
+			return
+		}
+
+		vS.accounting = onDiskAccounting
+		vS.accountingDirty = false
+		vS.accountingLoaded = true
+
+		err = nil
+		return
+	}
+
+	logger.Infof("Computing accounting of volume '%s'", vS.volumeName)
+
+	onDiskAccounting = onDiskAccountingV1Struct{
+		NumInodes:      0,
+		UsedBytes:      0,
+		UserUsage:      make(map[InodeUserID]accountingUsageV1Struct),
+		GroupUsage:     make(map[InodeGroupID]accountingUsageV1Struct),
+		QuotaTreeUsage: make(map[InodeNumber]accountingUsageV1Struct),
+		SharedLogSegs:  make(map[uint64]uint64),
+	}
+
+	logSegmentRefs = make(map[uint64]uint64)
+
+	keyedInodeNumbers = make([]uint64, 0)
+
+	lastInodeNumber = uint64(accountingInodeNumber)
+
+	for {
+		inodeNumbers, err = vS.headhunterVolumeHandle.FetchInodeNumbers(lastInodeNumber, accountingScanBatch)
+		if nil != err {
+			err = fmt.Errorf("%s: unable to scan inodeRecs of volume '%s': %v", utils.GetFnName(), vS.volumeName, err)
+			return
+		}
+		if 0 == len(inodeNumbers) {
+			break
+		}
+
+		for _, inodeNumber := range inodeNumbers {
+			if accountingKeyBase <= inodeNumber {
+				// Any such inodeRec left from before will be rewritten (or deleted) along with the rest
+				keyedInodeNumbers = append(keyedInodeNumbers, inodeNumber)
+				continue
+			}
+
+			inodeRec, ok, err = vS.headhunterVolumeHandle.GetInodeRec(inodeNumber)
+			if (nil != err) || !ok {
+				err = fmt.Errorf("%s: unable to get inodeRec for inode %d of volume '%s': %v", utils.GetFnName(), inodeNumber, vS.volumeName, err)
+				return
+			}
+
+			onDiskInodeV1, err = unpackInodeRec(InodeNumber(inodeNumber), inodeRec)
+			if nil == err {
+				onDiskAccounting.apply(onDiskInodeV1.accountingCharge(), false)
+				for logSegmentNumber = range onDiskInodeV1.LogSegmentMap {
+					logSegmentRefs[logSegmentNumber]++
+				}
+			} else {
+				logger.WarnfWithError(err, "accounting of volume '%s' counts only the existence of inode %d", vS.volumeName, inodeNumber)
+				onDiskAccounting.NumInodes++
+			}
+		}
+
+		lastInodeNumber = inodeNumbers[len(inodeNumbers)-1]
+	}
+
+	for logSegmentNumber = range logSegmentRefs {
+		if 1 < logSegmentRefs[logSegmentNumber] {
+			onDiskAccounting.SharedLogSegs[logSegmentNumber] = logSegmentRefs[logSegmentNumber] - 1
+		}
+	}
+
+	vS.accounting = onDiskAccounting
+	vS.accountingMarkAllDirtyWhileLocked() // Put along with the next flushed inodeRecs
+	for _, keyedInodeNumber = range keyedInodeNumbers {
+		vS.accountingDirtyKeys[keyedInodeNumber] = struct{}{}
+	}
+	vS.accountingLoaded = true
+
+	err = nil
+	return
+}
+
+// accountingLoadKeyedRecsWhileLocked fills in the maps of onDiskAccounting from the keyed accounting inodeRecs
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) accountingLoadKeyedRecsWhileLocked(onDiskAccounting *onDiskAccountingV1Struct) (err error) {
+	var (
+		id              uint64
+		inodeNumbers    []uint64
+		inodeRec        []byte
+		inodeRecBody    []byte
+		lastInodeNumber uint64
+		ok              bool
+		sharedLogSeg    onDiskAccountingSharedLogSegV1Struct
+		usage           accountingUsageV1Struct
+	)
+
+	onDiskAccounting.UserUsage = make(map[InodeUserID]accountingUsageV1Struct)
+	onDiskAccounting.GroupUsage = make(map[InodeGroupID]accountingUsageV1Struct)
+	onDiskAccounting.QuotaTreeUsage = make(map[InodeNumber]accountingUsageV1Struct)
+	onDiskAccounting.SharedLogSegs = make(map[uint64]uint64)
+
+	lastInodeNumber = accountingKeyBase
+
+	for {
+		inodeNumbers, err = vS.headhunterVolumeHandle.FetchInodeNumbers(lastInodeNumber, accountingScanBatch)
+		if nil != err {
+			err = fmt.Errorf("%s: unable to scan accounting inodeRecs of volume '%s': %v", utils.GetFnName(), vS.volumeName, err)
+			return
+		}
+		if 0 == len(inodeNumbers) {
+			break
+		}
+
+		for _, inodeNumber := range inodeNumbers {
+			inodeRec, ok, err = vS.headhunterVolumeHandle.GetInodeRec(inodeNumber)
+			if (nil != err) || !ok {
+				err = fmt.Errorf("%s: unable to get accounting inodeRec 0x%016X of volume '%s': %v", utils.GetFnName(), inodeNumber, vS.volumeName, err)
+				return
+			}
+			inodeRecBody, err = unpackInodeRecPreamble(InodeNumber(inodeNumber), inodeRec)
+			if nil != err {
+				return
+			}
+
+			id = inodeNumber & accountingKeyIDMask
+
+			switch (inodeNumber &^ accountingKeyBase) >> accountingKeyKindShift {
+			case accountingKeyKindUser:
+				usage, err = unmarshalAccountingUsage(inodeRecBody)
+				if (nil == err) && (0 != usage.NumInodes) {
+					onDiskAccounting.UserUsage[InodeUserID(id)] = usage
+				}
+			case accountingKeyKindGroup:
+				usage, err = unmarshalAccountingUsage(inodeRecBody)
+				if (nil == err) && (0 != usage.NumInodes) {
+					onDiskAccounting.GroupUsage[InodeGroupID(id)] = usage
+				}
+			case accountingKeyKindQuotaTree:
+				usage, err = unmarshalAccountingUsage(inodeRecBody)
+				if (nil == err) && (0 != usage.NumInodes) {
+					onDiskAccounting.QuotaTreeUsage[InodeNumber(id)] = usage
+				}
+			case accountingKeyKindSharedLogSeg:
+				sharedLogSeg = onDiskAccountingSharedLogSegV1Struct{}
+				err = json.Unmarshal(inodeRecBody, &sharedLogSeg)
+				if (nil == err) && (0 != sharedLogSeg.SharedCount) {
+					onDiskAccounting.SharedLogSegs[id] = sharedLogSeg.SharedCount
+				}
+			default:
+				err = fmt.Errorf("unknown kind")
+			}
+			if nil != err {
+				err = fmt.Errorf("%s: accounting inodeRec 0x%016X of volume '%s' could not be decoded: %v", utils.GetFnName(), inodeNumber, vS.volumeName, err)
+				err = blunder.AddError(err, blunder.CorruptInodeError)
+				return
+			}
+		}
+
+		lastInodeNumber = inodeNumbers[len(inodeNumbers)-1]
+	}
+
+	err = nil
+	return
+}
+
+func unmarshalAccountingUsage(inodeRecBody []byte) (usage accountingUsageV1Struct, err error) {
+	err = json.Unmarshal(inodeRecBody, &usage)
+	return
+}
+
+// accountingMarkAllDirtyWhileLocked notes that all of the accounting is to be put along with the next flushed inodeRecs
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) accountingMarkAllDirtyWhileLocked() {
+	vS.accountingDirty = true
+
+	for userID := range vS.accounting.UserUsage {
+		vS.accountingDirtyKeys[accountingKey(accountingKeyKindUser, uint64(userID))] = struct{}{}
+	}
+	for groupID := range vS.accounting.GroupUsage {
+		vS.accountingDirtyKeys[accountingKey(accountingKeyKindGroup, uint64(groupID))] = struct{}{}
+	}
+	for quotaTree := range vS.accounting.QuotaTreeUsage {
+		vS.accountingDirtyKeys[accountingKey(accountingKeyKindQuotaTree, uint64(quotaTree))] = struct{}{}
+	}
+	for logSegmentNumber := range vS.accounting.SharedLogSegs {
+		vS.accountingDirtyKeys[accountingKey(accountingKeyKindSharedLogSeg, logSegmentNumber)] = struct{}{}
+	}
+}
+
+func packAccountingRec(body interface{}) (accountingRec []byte) {
+	bodyBuf, err := json.Marshal(body)
+	if nil != err {
+		logger.Fatalf("json.Marshal() of accounting inodeRec failed: %v", err)
+	}
+
+	accountingRec = make([]byte, 0, len(globals.inodeRecDefaultPreambleBuf)+len(bodyBuf))
+	accountingRec = append(accountingRec, globals.inodeRecDefaultPreambleBuf...)
+	accountingRec = append(accountingRec, bodyBuf...)
+
+	return
+}
+
+// accountingMarshalDirtyWhileLocked returns the accounting inodeRecs to be put (i.e. those not yet put since
+// last changed), treating them as no longer dirty. A keyed inodeRec whose usage is now zero is returned as such.
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) accountingMarshalDirtyWhileLocked() (accountingKeys []uint64, accountingRecs [][]byte) {
+	var (
+		id  uint64
+		key uint64
+	)
+
+	accountingKeys = make([]uint64, 0, 1+len(vS.accountingDirtyKeys))
+	accountingRecs = make([][]byte, 0, 1+len(vS.accountingDirtyKeys))
+
+	if vS.accountingDirty {
+		accountingKeys = append(accountingKeys, uint64(accountingInodeNumber))
+		accountingRecs = append(accountingRecs, packAccountingRec(onDiskAccountingV1Struct{
+			NumInodes: vS.accounting.NumInodes,
+			UsedBytes: vS.accounting.UsedBytes,
+		}))
+		vS.accountingDirty = false
+	}
+
+	for key = range vS.accountingDirtyKeys {
+		id = key & accountingKeyIDMask
+
+		accountingKeys = append(accountingKeys, key)
+
+		switch (key &^ accountingKeyBase) >> accountingKeyKindShift {
+		case accountingKeyKindUser:
+			accountingRecs = append(accountingRecs, packAccountingRec(vS.accounting.UserUsage[InodeUserID(id)]))
+		case accountingKeyKindGroup:
+			accountingRecs = append(accountingRecs, packAccountingRec(vS.accounting.GroupUsage[InodeGroupID(id)]))
+		case accountingKeyKindQuotaTree:
+			accountingRecs = append(accountingRecs, packAccountingRec(vS.accounting.QuotaTreeUsage[InodeNumber(id)]))
+		default: // accountingKeyKindSharedLogSeg
+			accountingRecs = append(accountingRecs, packAccountingRec(onDiskAccountingSharedLogSegV1Struct{SharedCount: vS.accounting.SharedLogSegs[id]}))
+		}
+
+		delete(vS.accountingDirtyKeys, key)
+	}
+
+	return
+}
+
+// accountingPutDoneWhileLocked re-marks accountingKeys dirty if their put failed. Otherwise, it returns those
+// that, having been put with zero usage (and not since changed), may now be deleted.
+//
+// Note: Caller must hold vS.accountingMutex
+func (vS *volumeStruct) accountingPutDoneWhileLocked(accountingKeys []uint64, putSucceeded bool) (emptyAccountingKeys []uint64) {
+	var (
+		dirty bool
+		id    uint64
+		key   uint64
+		ok    bool
+	)
+
+	emptyAccountingKeys = make([]uint64, 0)
+
+	for _, key = range accountingKeys {
+		if uint64(accountingInodeNumber) == key {
+			if !putSucceeded {
+				vS.accountingDirty = true
+			}
+			continue
+		}
+		if !putSucceeded {
+			vS.accountingDirtyKeys[key] = struct{}{}
+			continue
+		}
+
+		_, dirty = vS.accountingDirtyKeys[key]
+		if dirty {
+			continue
+		}
+
+		id = key & accountingKeyIDMask
+
+		switch (key &^ accountingKeyBase) >> accountingKeyKindShift {
+		case accountingKeyKindUser:
+			_, ok = vS.accounting.UserUsage[InodeUserID(id)]
+		case accountingKeyKindGroup:
+			_, ok = vS.accounting.GroupUsage[InodeGroupID(id)]
+		case accountingKeyKindQuotaTree:
+			_, ok = vS.accounting.QuotaTreeUsage[InodeNumber(id)]
+		default: // accountingKeyKindSharedLogSeg
+			_, ok = vS.accounting.SharedLogSegs[id]
+		}
+		if !ok {
+			emptyAccountingKeys = append(emptyAccountingKeys, key)
+		}
+	}
+
+	return
+}
+
+// accountingDeleteEmpty deletes accounting inodeRecs put with zero usage
+//
+// Note: Caller must hold vS.accountingPutMutex (so that no subsequent put of the same key may precede the delete)
+func (vS *volumeStruct) accountingDeleteEmpty(emptyAccountingKeys []uint64) {
+	for _, key := range emptyAccountingKeys {
+		err := vS.headhunterVolumeHandle.DeleteInodeRec(key)
+		if nil != err {
+			// Harmless... an accounting inodeRec with zero usage is treated as absent
+			logger.WarnfWithError(err, "accounting inodeRec 0x%016X of volume '%s' could not be deleted", key, vS.volumeName)
+		}
+	}
+}
+
+// accountingPrepare applies the effect of putting the inodeRecs of dirtyInodes to the volume's accounting,
+// returning the accounting inodeRecs to be put along with them
+//
+// As vS.accountingPutMutex remains held, each call must be followed by a call to accountingComplete()
+func (vS *volumeStruct) accountingPrepare(dirtyInodes []*inMemoryInodeStruct) (accountingDelta *accountingDeltaStruct) {
+	accountingDelta = &accountingDeltaStruct{
+		charges:        make([]accountingChargeStruct, len(dirtyInodes)),
+		applied:        false,
+		accountingKeys: make([]uint64, 0),
+		accountingRecs: make([][]byte, 0),
+	}
+
+	for i, inode := range dirtyInodes {
+		accountingDelta.charges[i] = inode.accountingCharge()
+	}
+
+	vS.accountingPutMutex.Lock()
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err := vS.accountingLoadWhileLocked()
+	if nil != err {
+		logger.ErrorfWithError(err, "accounting of volume '%s' not available", vS.volumeName)
+		return
+	}
+
+	for i, inode := range dirtyInodes {
+		if inode.onDisk {
+			if inode.onDiskCharge == accountingDelta.charges[i] {
+				continue
+			}
+			vS.applyWhileLocked(inode.onDiskCharge, true)
+		}
+		vS.applyWhileLocked(accountingDelta.charges[i], false)
+	}
+
+	accountingDelta.applied = true
+	accountingDelta.accountingKeys, accountingDelta.accountingRecs = vS.accountingMarshalDirtyWhileLocked()
+
+	return
+}
+
+// accountingComplete records (or, if !putSucceeded, reverses) the effect applied by accountingPrepare()
+func (vS *volumeStruct) accountingComplete(accountingDelta *accountingDeltaStruct, dirtyInodes []*inMemoryInodeStruct, putSucceeded bool) {
+	var (
+		emptyAccountingKeys []uint64
+	)
+
+	vS.accountingMutex.Lock()
+
+	if putSucceeded {
+		for i, inode := range dirtyInodes {
+			inode.onDisk = true
+			inode.onDiskCharge = accountingDelta.charges[i]
+		}
+	} else if accountingDelta.applied {
+		for i, inode := range dirtyInodes {
+			if inode.onDisk {
+				if inode.onDiskCharge == accountingDelta.charges[i] {
+					continue
+				}
+				vS.applyWhileLocked(inode.onDiskCharge, false)
+			}
+			vS.applyWhileLocked(accountingDelta.charges[i], true)
+		}
+	}
+
+	emptyAccountingKeys = vS.accountingPutDoneWhileLocked(accountingDelta.accountingKeys, putSucceeded)
+
+	vS.accountingMutex.Unlock()
+
+	vS.accountingDeleteEmpty(emptyAccountingKeys)
+
+	vS.accountingPutMutex.Unlock()
+}
+
+// destroyInodeRec deletes the inodeRec of inode and removes its contribution to the volume's accounting
+func (vS *volumeStruct) destroyInodeRec(inode *inMemoryInodeStruct) (err error) {
+	var (
+		accountingKeys      []uint64
+		accountingRecs      [][]byte
+		emptyAccountingKeys []uint64
+	)
+
+	vS.accountingPutMutex.Lock()
+	defer vS.accountingPutMutex.Unlock()
+
+	// Ensure the accounting is loaded (perhaps by scanning) before the inodeRec is deleted
+
+	vS.accountingMutex.Lock()
+	loadErr := vS.accountingLoadWhileLocked()
+	vS.accountingMutex.Unlock()
+
+	err = vS.headhunterVolumeHandle.DeleteInodeRec(uint64(inode.InodeNumber))
+	if nil != err {
+		return
+	}
+
+	vS.accountingMutex.Lock()
+
+	if !inode.onDisk {
+		vS.accountingMutex.Unlock()
+		return
+	}
+
+	inode.onDisk = false
+
+	if nil != loadErr {
+		vS.accountingMutex.Unlock()
+		logger.ErrorfWithError(loadErr, "accounting of volume '%s' not available", vS.volumeName)
+		return
+	}
+
+	vS.applyWhileLocked(inode.onDiskCharge, true)
+
+	accountingKeys, accountingRecs = vS.accountingMarshalDirtyWhileLocked()
+
+	vS.accountingMutex.Unlock()
+
+	putErr := vS.headhunterVolumeHandle.PutInodeRecs(accountingKeys, accountingRecs)
+	if nil != putErr {
+		logger.ErrorfWithError(putErr, "accounting of volume '%s' could not be put", vS.volumeName)
+	}
+
+	vS.accountingMutex.Lock()
+	emptyAccountingKeys = vS.accountingPutDoneWhileLocked(accountingKeys, nil == putErr)
+	vS.accountingMutex.Unlock()
+
+	vS.accountingDeleteEmpty(emptyAccountingKeys)
+
+	return
+}
+
+// accountingLoad ensures the volume's accounting (including the record of shared LogSegments) is available
+func (vS *volumeStruct) accountingLoad() (err error) {
+	vS.accountingMutex.Lock()
+	err = vS.accountingLoadWhileLocked()
+	vS.accountingMutex.Unlock()
+	return
+}
+
+// shareLogSegment records that one more FileInode now references logSegmentNumber
+//
+// Note: The accounting must have been loaded (e.g. via accountingLoad())
+func (vS *volumeStruct) shareLogSegment(logSegmentNumber uint64) {
+	vS.accountingMutex.Lock()
+	vS.accounting.SharedLogSegs[logSegmentNumber]++
+	vS.accountingDirtyKeys[accountingKey(accountingKeyKindSharedLogSeg, logSegmentNumber)] = struct{}{} // Put along with the next flushed inodeRecs
+	vS.accountingMutex.Unlock()
+
+	stats.IncrementOperations(&stats.LogSegShareOps)
+}
+
+// shareLiveLogSegment is like shareLogSegment() but, as the caller merely came across logSegmentNumber (e.g. via
+// a fingerprintRec), first confirms that the LogSegment has neither been deleted nor become unreferenced
+func (vS *volumeStruct) shareLiveLogSegment(logSegmentNumber uint64) (shared bool) {
+	var (
+		err          error
+		unreferenced bool
+	)
+
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	_, unreferenced = vS.unreferencedLogSegmentSet[logSegmentNumber]
+	if unreferenced {
+		return
+	}
+
+	_, err = vS.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
+	if nil != err {
+		return
+	}
+
+	vS.accounting.SharedLogSegs[logSegmentNumber]++
+	vS.accountingDirtyKeys[accountingKey(accountingKeyKindSharedLogSeg, logSegmentNumber)] = struct{}{} // Put along with the next flushed inodeRecs
+
+	stats.IncrementOperations(&stats.LogSegShareOps)
+
+	shared = true
+	return
+}
+
+// isLogSegmentShared reports whether logSegmentNumber is referenced by more than one FileInode
+func (vS *volumeStruct) isLogSegmentShared(logSegmentNumber uint64) (shared bool, err error) {
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		return
+	}
+
+	_, shared = vS.accounting.SharedLogSegs[logSegmentNumber]
+
+	return
+}
+
+// unshareLogSegments records that a FileInode no longer references each of logSegmentNumbers,
+// returning those no longer referenced by any FileInode (i.e. those that should now be deleted)
+//
+// Should the accounting not be available, no LogSegments are returned (leaving them as garbage)
+// as it cannot be known whether or not they remain shared.
+func (vS *volumeStruct) unshareLogSegments(logSegmentNumbers []uint64) (unreferencedLogSegmentNumbers []uint64) {
+	var (
+		err              error
+		logSegmentNumber uint64
+		sharedCount      uint64
+	)
+
+	unreferencedLogSegmentNumbers = make([]uint64, 0, len(logSegmentNumbers))
+
+	if 0 == len(logSegmentNumbers) {
+		return
+	}
+
+	vS.accountingMutex.Lock()
+	defer vS.accountingMutex.Unlock()
+
+	err = vS.accountingLoadWhileLocked()
+	if nil != err {
+		logger.ErrorfWithError(err, "accounting of volume '%s' not available... leaving %d LogSegments undeleted", vS.volumeName, len(logSegmentNumbers))
+		return
+	}
+
+	for _, logSegmentNumber = range logSegmentNumbers {
+		sharedCount = vS.accounting.SharedLogSegs[logSegmentNumber]
+		switch sharedCount {
+		case 0:
+			unreferencedLogSegmentNumbers = append(unreferencedLogSegmentNumbers, logSegmentNumber)
+			vS.unreferencedLogSegmentSet[logSegmentNumber] = struct{}{} // Until deleteLogSegmentAsync() deletes its logSegmentRec
+		case 1:
+			delete(vS.accounting.SharedLogSegs, logSegmentNumber)
+			vS.accountingDirtyKeys[accountingKey(accountingKeyKindSharedLogSeg, logSegmentNumber)] = struct{}{}
+			stats.IncrementOperations(&stats.GcLogSegSharedOps)
+		default:
+			vS.accounting.SharedLogSegs[logSegmentNumber] = sharedCount - 1
+			vS.accountingDirtyKeys[accountingKey(accountingKeyKindSharedLogSeg, logSegmentNumber)] = struct{}{}
+			stats.IncrementOperations(&stats.GcLogSegSharedOps)
+		}
+	}
+
+	return
+}
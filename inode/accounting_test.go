@@ -0,0 +1,240 @@
+package inode
+
+import (
+	"testing"
+)
+
+func testAccountingCheck(t *testing.T, testVolumeHandle VolumeHandle, step string, expectedUsedBytes uint64, expectedNumInodes uint64) {
+	usedBytes, numInodes, err := testVolumeHandle.FetchVolumeUsage()
+	if nil != err {
+		t.Fatalf("FetchVolumeUsage() [%s] failed: %v", step, err)
+	}
+	if (expectedUsedBytes != usedBytes) || (expectedNumInodes != numInodes) {
+		t.Fatalf("FetchVolumeUsage() [%s] returned usedBytes == %v & numInodes == %v (expected %v & %v)", step, usedBytes, numInodes, expectedUsedBytes, expectedNumInodes)
+	}
+}
+
+func TestAccounting(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	initialUsedBytes, initialNumInodes, err := testVolumeHandle.FetchVolumeUsage()
+	if nil != err {
+		t.Fatalf("FetchVolumeUsage() [initial] failed: %v", err)
+	}
+	if 0 == initialNumInodes {
+		t.Fatalf("FetchVolumeUsage() [initial] should have at least counted the RootDirInode")
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Nothing is accounted for until the inode is flushed
+
+	testAccountingCheck(t, testVolumeHandle, "after CreateFile()", initialUsedBytes, initialNumInodes)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, nil)
+	if nil != err {
+		t.Fatalf("Write() [first] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [first] failed: %v", err)
+	}
+
+	testAccountingCheck(t, testVolumeHandle, "after first Write()", initialUsedBytes+8, initialNumInodes+1)
+
+	// Overwriting half the file leaves half of the first LogSegment's bytes live
+
+	err = testVolumeHandle.Write(fileInodeNumber, 4, []byte{0x14, 0x15, 0x16, 0x17, 0x18, 0x19}, nil)
+	if nil != err {
+		t.Fatalf("Write() [second] failed: %v", err)
+	}
+
+	usedBytes, unflushedBytes, err := testVolumeHandle.FetchInodeUsage(fileInodeNumber)
+	if (nil != err) || (10 != usedBytes) || (2 != unflushedBytes) {
+		t.Fatalf("FetchInodeUsage() [before Flush()] returned %v & %v [err: %v] (expected 10 & 2)", usedBytes, unflushedBytes, err)
+	}
+
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [second] failed: %v", err)
+	}
+
+	usedBytes, unflushedBytes, err = testVolumeHandle.FetchInodeUsage(fileInodeNumber)
+	if (nil != err) || (10 != usedBytes) || (0 != unflushedBytes) {
+		t.Fatalf("FetchInodeUsage() [after Flush()] returned %v & %v [err: %v] (expected 10 & 0)", usedBytes, unflushedBytes, err)
+	}
+
+	testAccountingCheck(t, testVolumeHandle, "after second Write()", initialUsedBytes+10, initialNumInodes+1)
+
+	// Reloading the accounting should find what was put
+
+	volume.accountingMutex.Lock()
+	volume.accountingLoaded = false
+	volume.accountingMutex.Unlock()
+
+	testAccountingCheck(t, testVolumeHandle, "after reload", initialUsedBytes+10, initialNumInodes+1)
+
+	// A flush leaving the accounting unchanged must not disturb what was put
+
+	err = testVolumeHandle.SetPermMode(fileInodeNumber, InodeMode(0600))
+	if nil != err {
+		t.Fatalf("SetPermMode() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [after SetPermMode()] failed: %v", err)
+	}
+
+	volume.accountingMutex.Lock()
+	volume.accountingLoaded = false
+	err = volume.accountingLoadWhileLocked()
+	accountingDirty := volume.accountingDirty
+	volume.accountingMutex.Unlock()
+	if nil != err {
+		t.Fatalf("accountingLoadWhileLocked() [after SetPermMode()] failed: %v", err)
+	}
+	if accountingDirty {
+		t.Fatalf("accountingLoadWhileLocked() [after SetPermMode()] should have found the accounting put (rather than recompute it)")
+	}
+
+	testAccountingCheck(t, testVolumeHandle, "after SetPermMode() and reload", initialUsedBytes+10, initialNumInodes+1)
+
+	// As should recomputing the accounting of a volume lacking it
+
+	volume.accountingMutex.Lock()
+	err = volume.headhunterVolumeHandle.DeleteInodeRec(uint64(accountingInodeNumber))
+	if nil != err {
+		t.Fatalf("DeleteInodeRec(accountingInodeNumber) failed: %v", err)
+	}
+	volume.accountingLoaded = false
+	volume.accountingMutex.Unlock()
+
+	testAccountingCheck(t, testVolumeHandle, "after scan", initialUsedBytes+10, initialNumInodes+1)
+
+	// The accounting inodeRec must not be mistaken for an inode
+
+	_, err = testVolumeHandle.GetType(accountingInodeNumber)
+	if nil == err {
+		t.Fatalf("GetType(accountingInodeNumber) should have failed")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+
+	testAccountingCheck(t, testVolumeHandle, "after Destroy()", initialUsedBytes, initialNumInodes)
+}
+
+func testAccountingOwnerCheck(t *testing.T, step string, usedBytes uint64, numInodes uint64, err error, expectedUsedBytes uint64, expectedNumInodes uint64) {
+	if nil != err {
+		t.Fatalf("Fetch*Usage() [%s] failed: %v", step, err)
+	}
+	if (expectedUsedBytes != usedBytes) || (expectedNumInodes != numInodes) {
+		t.Fatalf("Fetch*Usage() [%s] returned usedBytes == %v & numInodes == %v (expected %v & %v)", step, usedBytes, numInodes, expectedUsedBytes, expectedNumInodes)
+	}
+}
+
+func TestAccountingOwners(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+	err = testVolumeHandle.SetQuotaTree(dirInodeNumber, dirInodeNumber)
+	if nil != err {
+		t.Fatalf("SetQuotaTree() failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeUserID(17), InodeGroupID(23))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	err = testVolumeHandle.InheritQuotaTree(dirInodeNumber, fileInodeNumber)
+	if nil != err {
+		t.Fatalf("InheritQuotaTree() failed: %v", err)
+	}
+
+	metadata, err := testVolumeHandle.GetMetadata(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetMetadata() failed: %v", err)
+	}
+	if dirInodeNumber != metadata.QuotaTree {
+		t.Fatalf("GetMetadata() returned QuotaTree == %v (expected %v)", metadata.QuotaTree, dirInodeNumber)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	usedBytes, numInodes, err := testVolumeHandle.FetchUserUsage(InodeUserID(17))
+	testAccountingOwnerCheck(t, "user 17 after Write()", usedBytes, numInodes, err, 8, 1)
+	usedBytes, numInodes, err = testVolumeHandle.FetchGroupUsage(InodeGroupID(23))
+	testAccountingOwnerCheck(t, "group 23 after Write()", usedBytes, numInodes, err, 8, 1)
+	usedBytes, numInodes, err = testVolumeHandle.FetchQuotaTreeUsage(dirInodeNumber)
+	testAccountingOwnerCheck(t, "quota tree after Write()", usedBytes, numInodes, err, 8, 2)
+
+	// A change of owner moves the usage
+
+	err = testVolumeHandle.SetOwnerUserID(fileInodeNumber, InodeUserID(18))
+	if nil != err {
+		t.Fatalf("SetOwnerUserID() failed: %v", err)
+	}
+
+	usedBytes, numInodes, err = testVolumeHandle.FetchUserUsage(InodeUserID(17))
+	testAccountingOwnerCheck(t, "user 17 after SetOwnerUserID()", usedBytes, numInodes, err, 0, 0)
+	usedBytes, numInodes, err = testVolumeHandle.FetchUserUsage(InodeUserID(18))
+	testAccountingOwnerCheck(t, "user 18 after SetOwnerUserID()", usedBytes, numInodes, err, 8, 1)
+
+	// Reloading the accounting should find the per-owner usage in its keyed inodeRecs
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.accountingMutex.Lock()
+	volume.accountingLoaded = false
+	volume.accountingMutex.Unlock()
+
+	usedBytes, numInodes, err = testVolumeHandle.FetchUserUsage(InodeUserID(18))
+	testAccountingOwnerCheck(t, "user 18 after reload", usedBytes, numInodes, err, 8, 1)
+	usedBytes, numInodes, err = testVolumeHandle.FetchQuotaTreeUsage(dirInodeNumber)
+	testAccountingOwnerCheck(t, "quota tree after reload", usedBytes, numInodes, err, 8, 2)
+
+	_, ok, _ := volume.headhunterVolumeHandle.GetInodeRec(accountingKey(accountingKeyKindUser, 17))
+	if ok {
+		t.Fatalf("GetInodeRec() of user 17 accounting inodeRec should have failed (as it should have been deleted)")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [file] failed: %v", err)
+	}
+
+	usedBytes, numInodes, err = testVolumeHandle.FetchUserUsage(InodeUserID(18))
+	testAccountingOwnerCheck(t, "user 18 after Destroy()", usedBytes, numInodes, err, 0, 0)
+	usedBytes, numInodes, err = testVolumeHandle.FetchGroupUsage(InodeGroupID(23))
+	testAccountingOwnerCheck(t, "group 23 after Destroy()", usedBytes, numInodes, err, 0, 0)
+	usedBytes, numInodes, err = testVolumeHandle.FetchQuotaTreeUsage(dirInodeNumber)
+	testAccountingOwnerCheck(t, "quota tree after Destroy()", usedBytes, numInodes, err, 0, 1)
+
+	err = testVolumeHandle.Destroy(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [dir] failed: %v", err)
+	}
+}
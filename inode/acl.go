@@ -0,0 +1,435 @@
+package inode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// POSIX ACLs are kept in reserved inode streams named (and formatted) as the Linux "system.posix_acl_*"
+// extended attributes. Each is a little-endian uint32 ACLVersion followed by a sequence of 8-byte entries:
+//
+//   uint16 tag (one of the ACLTag* values)
+//   uint16 perm (bitwise or of R_OK, W_OK, and X_OK)
+//   uint32 id (UserID for ACLTagUser, GroupID for ACLTagGroup, otherwise ACLUndefinedID)
+//
+// The AccessACLStreamName stream, if present, is consulted by Access() for callers other than the owner.
+// While present, the owner & other mode bits mirror its ACLTagUserObj & ACLTagOther entries and the group
+// mode bits mirror its ACLTagMask entry. An access ACL with no ACLTagUser or ACLTagGroup entries adds nothing
+// to the mode bits, so it is never stored.
+//
+// The DefaultACLStreamName stream, only present on a DirType inode, is inherited (see InheritACL()) by each
+// inode subsequently created in the directory.
+
+const (
+	AccessACLStreamName  = "system.posix_acl_access"
+	DefaultACLStreamName = "system.posix_acl_default"
+)
+
+const (
+	ACLVersion     = uint32(2)
+	ACLUndefinedID = uint32(0xFFFFFFFF)
+)
+
+const (
+	ACLTagUserObj  = uint16(0x01)
+	ACLTagUser     = uint16(0x02)
+	ACLTagGroupObj = uint16(0x04)
+	ACLTagGroup    = uint16(0x08)
+	ACLTagMask     = uint16(0x10)
+	ACLTagOther    = uint16(0x20)
+)
+
+const (
+	aclHeaderSize = 4
+	aclEntrySize  = 8
+)
+
+type ACLEntry struct {
+	Tag  uint16
+	Perm uint16
+	ID   uint32
+}
+
+// IsACLStreamName indicates whether or not streamName is one of the reserved POSIX ACL stream names
+func IsACLStreamName(streamName string) (isACLStreamName bool) {
+	isACLStreamName = (AccessACLStreamName == streamName) || (DefaultACLStreamName == streamName)
+	return
+}
+
+// UnpackACL decodes and validates an ACL, returning its entries sorted by tag (then id)
+func UnpackACL(buf []byte) (aclEntries []ACLEntry, err error) {
+	var (
+		aclEntry    ACLEntry
+		groupIDSet  map[uint32]struct{}
+		numEntries  int
+		ok          bool
+		tagCountMap map[uint16]int
+		userIDSet   map[uint32]struct{}
+	)
+
+	if (aclHeaderSize > len(buf)) || (0 != ((len(buf) - aclHeaderSize) % aclEntrySize)) {
+		err = fmt.Errorf("%s: ACL of invalid length %d", utils.GetFnName(), len(buf))
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	if ACLVersion != binary.LittleEndian.Uint32(buf[:aclHeaderSize]) {
+		err = fmt.Errorf("%s: ACL of unsupported version %d", utils.GetFnName(), binary.LittleEndian.Uint32(buf[:aclHeaderSize]))
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	numEntries = (len(buf) - aclHeaderSize) / aclEntrySize
+
+	aclEntries = make([]ACLEntry, 0, numEntries)
+	groupIDSet = make(map[uint32]struct{})
+	tagCountMap = make(map[uint16]int)
+	userIDSet = make(map[uint32]struct{})
+
+	for entryOffset := aclHeaderSize; entryOffset < len(buf); entryOffset += aclEntrySize {
+		aclEntry.Tag = binary.LittleEndian.Uint16(buf[entryOffset:])
+		aclEntry.Perm = binary.LittleEndian.Uint16(buf[entryOffset+2:])
+		aclEntry.ID = binary.LittleEndian.Uint32(buf[entryOffset+4:])
+
+		if 0 != (InodeMode(aclEntry.Perm) &^ (R_OK | W_OK | X_OK)) {
+			err = fmt.Errorf("%s: ACL entry with invalid perm 0x%X", utils.GetFnName(), aclEntry.Perm)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		switch aclEntry.Tag {
+		case ACLTagUserObj, ACLTagGroupObj, ACLTagMask, ACLTagOther:
+			aclEntry.ID = ACLUndefinedID
+		case ACLTagUser:
+			_, ok = userIDSet[aclEntry.ID]
+			if ok {
+				err = fmt.Errorf("%s: ACL contains multiple entries for UserID %d", utils.GetFnName(), aclEntry.ID)
+				err = blunder.AddError(err, blunder.InvalidArgError)
+				return
+			}
+			userIDSet[aclEntry.ID] = struct{}{}
+		case ACLTagGroup:
+			_, ok = groupIDSet[aclEntry.ID]
+			if ok {
+				err = fmt.Errorf("%s: ACL contains multiple entries for GroupID %d", utils.GetFnName(), aclEntry.ID)
+				err = blunder.AddError(err, blunder.InvalidArgError)
+				return
+			}
+			groupIDSet[aclEntry.ID] = struct{}{}
+		default:
+			err = fmt.Errorf("%s: ACL entry with invalid tag 0x%X", utils.GetFnName(), aclEntry.Tag)
+			err = blunder.AddError(err, blunder.InvalidArgError)
+			return
+		}
+
+		tagCountMap[aclEntry.Tag]++
+
+		aclEntries = append(aclEntries, aclEntry)
+	}
+
+	if (1 != tagCountMap[ACLTagUserObj]) || (1 != tagCountMap[ACLTagGroupObj]) || (1 != tagCountMap[ACLTagOther]) || (1 < tagCountMap[ACLTagMask]) {
+		err = fmt.Errorf("%s: ACL must contain exactly one each of USER_OBJ, GROUP_OBJ, and OTHER entries and at most one MASK entry", utils.GetFnName())
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	if ((0 < len(userIDSet)) || (0 < len(groupIDSet))) && (0 == tagCountMap[ACLTagMask]) {
+		err = fmt.Errorf("%s: ACL with USER or GROUP entries must contain a MASK entry", utils.GetFnName())
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	sort.Slice(aclEntries, func(i int, j int) bool {
+		if aclEntries[i].Tag != aclEntries[j].Tag {
+			return aclEntries[i].Tag < aclEntries[j].Tag
+		}
+		return aclEntries[i].ID < aclEntries[j].ID
+	})
+
+	err = nil
+	return
+}
+
+// PackACL encodes aclEntries (presumably as returned by UnpackACL())
+func PackACL(aclEntries []ACLEntry) (buf []byte) {
+	buf = make([]byte, aclHeaderSize+(len(aclEntries)*aclEntrySize))
+
+	binary.LittleEndian.PutUint32(buf, ACLVersion)
+
+	for i, aclEntry := range aclEntries {
+		entryOffset := aclHeaderSize + (i * aclEntrySize)
+		binary.LittleEndian.PutUint16(buf[entryOffset:], aclEntry.Tag)
+		binary.LittleEndian.PutUint16(buf[entryOffset+2:], aclEntry.Perm)
+		binary.LittleEndian.PutUint32(buf[entryOffset+4:], aclEntry.ID)
+	}
+
+	return
+}
+
+// aclIsMinimal indicates whether or not aclEntries is fully expressed by the mode bits
+func aclIsMinimal(aclEntries []ACLEntry) (isMinimal bool) {
+	for _, aclEntry := range aclEntries {
+		if (ACLTagUser == aclEntry.Tag) || (ACLTagGroup == aclEntry.Tag) {
+			isMinimal = false
+			return
+		}
+	}
+
+	isMinimal = true
+	return
+}
+
+// aclGroupClassIndex returns the index of the entry mirrored by the group mode bits (ACLTagMask if present)
+func aclGroupClassIndex(aclEntries []ACLEntry) (groupClassIndex int) {
+	groupClassIndex = -1
+
+	for i, aclEntry := range aclEntries {
+		switch aclEntry.Tag {
+		case ACLTagMask:
+			groupClassIndex = i
+			return
+		case ACLTagGroupObj:
+			groupClassIndex = i
+		}
+	}
+
+	return
+}
+
+// aclPermMode returns the permission bits mirroring aclEntries
+func aclPermMode(aclEntries []ACLEntry) (permMode InodeMode) {
+	permMode = 0
+
+	for _, aclEntry := range aclEntries {
+		switch aclEntry.Tag {
+		case ACLTagUserObj:
+			permMode |= InodeMode(aclEntry.Perm) << 6
+		case ACLTagOther:
+			permMode |= InodeMode(aclEntry.Perm)
+		}
+	}
+
+	permMode |= InodeMode(aclEntries[aclGroupClassIndex(aclEntries)].Perm) << 3
+
+	return
+}
+
+// aclApplyPermMode updates (in place) the entries of aclEntries mirrored by the permission bits of permMode
+func aclApplyPermMode(aclEntries []ACLEntry, permMode InodeMode) {
+	for i, aclEntry := range aclEntries {
+		switch aclEntry.Tag {
+		case ACLTagUserObj:
+			aclEntries[i].Perm = uint16((permMode >> 6) & 07)
+		case ACLTagOther:
+			aclEntries[i].Perm = uint16(permMode & 07)
+		}
+	}
+
+	aclEntries[aclGroupClassIndex(aclEntries)].Perm = uint16((permMode >> 3) & 07)
+}
+
+// aclAccess evaluates the access ACL of ourInode for a caller other than the owner (or root)
+func aclAccess(ourInode *inMemoryInodeStruct, aclEntries []ACLEntry, userID InodeUserID, groupID InodeGroupID, otherGroupIDs []InodeGroupID, accessMode InodeMode) (accessReturn bool) {
+	var (
+		groupMatched bool
+		mask         InodeMode
+	)
+
+	mask = R_OK | W_OK | X_OK
+
+	for _, aclEntry := range aclEntries {
+		if ACLTagMask == aclEntry.Tag {
+			mask = InodeMode(aclEntry.Perm)
+		}
+	}
+
+	for _, aclEntry := range aclEntries {
+		if (ACLTagUser == aclEntry.Tag) && (InodeUserID(aclEntry.ID) == userID) {
+			accessReturn = ((InodeMode(aclEntry.Perm) & mask & accessMode) == accessMode)
+			return
+		}
+	}
+
+	callerInGroup := func(entryGroupID InodeGroupID) bool {
+		if groupID == entryGroupID {
+			return true
+		}
+		for _, otherGroupID := range otherGroupIDs {
+			if otherGroupID == entryGroupID {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Access is granted if any matching group entry grants it (and denied if none do)
+
+	groupMatched = false
+
+	for _, aclEntry := range aclEntries {
+		if ((ACLTagGroupObj == aclEntry.Tag) && callerInGroup(ourInode.GroupID)) ||
+			((ACLTagGroup == aclEntry.Tag) && callerInGroup(InodeGroupID(aclEntry.ID))) {
+			if (InodeMode(aclEntry.Perm) & mask & accessMode) == accessMode {
+				accessReturn = true
+				return
+			}
+			groupMatched = true
+		}
+	}
+
+	if groupMatched {
+		accessReturn = false
+		return
+	}
+
+	accessReturn = ((((ourInode.Mode >> 0) & 07) & accessMode) == accessMode)
+	return
+}
+
+func (vS *volumeStruct) SetACL(inodeNumber InodeNumber, aclStreamName string, buf []byte) (err error) {
+	var (
+		aclEntries []ACLEntry
+	)
+
+	if !IsACLStreamName(aclStreamName) {
+		err = fmt.Errorf("%s: \"%s\" is not an ACL", utils.GetFnName(), aclStreamName)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	aclEntries, err = UnpackACL(buf)
+	if nil != err {
+		return
+	}
+
+	inode, ok, err := vS.fetchInode(inodeNumber)
+	if err != nil {
+		// this indicates disk corruption or software error
+		// (err includes volume name and inode number)
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		// disk corruption or client request for unallocated inode
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		logger.InfoWithError(err)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if AccessACLStreamName == aclStreamName {
+		inode.Mode = (inode.Mode &^ PosixModePerm) | aclPermMode(aclEntries)
+		if aclIsMinimal(aclEntries) {
+			delete(inode.StreamMap, aclStreamName)
+		} else {
+			inode.StreamMap[aclStreamName] = PackACL(aclEntries)
+		}
+	} else {
+		if DirType != inode.InodeType {
+			err = fmt.Errorf("%s: default ACL may only be set on a directory", utils.GetFnName())
+			err = blunder.AddError(err, blunder.PermDeniedError)
+			return
+		}
+		inode.StreamMap[aclStreamName] = PackACL(aclEntries)
+	}
+
+	inode.dirty = true
+
+	updateTime := time.Now()
+	inode.AttrChangeTime = updateTime
+
+	err = vS.flushInode(inode)
+	if err != nil {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	return
+}
+
+func (vS *volumeStruct) InheritACL(dirInodeNumber InodeNumber, inodeNumber InodeNumber) (err error) {
+	var (
+		aclEntries    []ACLEntry
+		defaultACLBuf []byte
+		dirInode      *inMemoryInodeStruct
+		inode         *inMemoryInodeStruct
+		ok            bool
+	)
+
+	dirInode, ok, err = vS.fetchInode(dirInodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: fetch of directory inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), dirInodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	defaultACLBuf, ok = dirInode.StreamMap[DefaultACLStreamName]
+	if !ok {
+		// Nothing to inherit
+		err = nil
+		return
+	}
+
+	aclEntries, err = UnpackACL(defaultACLBuf)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: default ACL of inode %d volume '%s' is corrupt", utils.GetFnName(), dirInodeNumber, vS.volumeName)
+		return
+	}
+
+	inode, ok, err = vS.fetchInode(inodeNumber)
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: fetch of inode failed", utils.GetFnName())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%s: failing request for inode %d volume '%s' because it is unallocated",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	// A directory also inherits the default ACL itself (to pass on to its own descendants)
+
+	if DirType == inode.InodeType {
+		inode.StreamMap[DefaultACLStreamName] = PackACL(aclEntries)
+	}
+
+	// The access ACL is the default ACL constrained by the permission bits requested at creation
+
+	for i, aclEntry := range aclEntries {
+		switch aclEntry.Tag {
+		case ACLTagUserObj:
+			aclEntries[i].Perm &= uint16((inode.Mode >> 6) & 07)
+		case ACLTagOther:
+			aclEntries[i].Perm &= uint16(inode.Mode & 07)
+		}
+	}
+	aclEntries[aclGroupClassIndex(aclEntries)].Perm &= uint16((inode.Mode >> 3) & 07)
+
+	inode.Mode = (inode.Mode &^ PosixModePerm) | aclPermMode(aclEntries)
+	if !aclIsMinimal(aclEntries) {
+		inode.StreamMap[AccessACLStreamName] = PackACL(aclEntries)
+	}
+
+	inode.dirty = true
+
+	err = vS.flushInode(inode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	return
+}
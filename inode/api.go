@@ -64,12 +64,13 @@ type MetadataStruct struct {
 	Mode                 InodeMode
 	UserID               InodeUserID
 	GroupID              InodeGroupID
+	QuotaTree            InodeNumber // DirInode of the quota tree containing this inode (or 0 if none)
 }
 
 type FragmentationReport struct {
 	NumberOfFragments uint64 // used with BytesInFragments to compute average fragment size
 	BytesInFragments  uint64 // equivalent to size of file for FileInode that is not sparse
-	BytesTrapped      uint64 // unreferenced bytes trapped in referenced log segments
+	BytesTrapped      uint64 // unreferenced (as stored, i.e. compressed) bytes trapped in referenced log segments not shared with other files
 }
 
 type DirEntry struct {
@@ -98,6 +99,8 @@ type ReadPlanStep struct {
 	ContainerName    string // If == "", Length specifies a zero-fill size
 	ObjectName       string // If == "", Length specifies a zero-fill size
 	ObjectPath       string // If == "", Length specifies a zero-fill size
+	FrameOffset      uint64 `json:",omitempty"` // If FrameLength != 0, Offset is relative to the decompressed content of
+	FrameLength      uint64 `json:",omitempty"` //   the compressed frame at [FrameOffset:FrameOffset+FrameLength) (see compress.go)
 }
 
 const (
@@ -116,6 +119,19 @@ func VolumeNameToActivePeerPrivateIPAddr(volumeName string) (activePeerPrivateIP
 	return
 }
 
+// RenewLogSegmentLease extends the lifetime of a lease returned by VolumeHandle.CreateLogSegmentLease().
+func RenewLogSegmentLease(leaseID string) (err error) {
+	err = renewLogSegmentLease(leaseID)
+	return
+}
+
+// ReleaseLogSegmentLease drops a lease returned by VolumeHandle.CreateLogSegmentLease(). Any deletions
+// of the leased LogSegments deferred while the lease was held are then performed.
+func ReleaseLogSegmentLease(leaseID string) (err error) {
+	err = releaseLogSegmentLease(leaseID)
+	return
+}
+
 // FetchVolumeHandle returns a the VolumeHandle corresponding to the name VolumeName.
 //
 // Note: The method should be considered a write operation on the RoodDirInodeNumber.
@@ -125,11 +141,28 @@ func FetchVolumeHandle(volumeName string) (volumeHandle VolumeHandle, err error)
 	return
 }
 
+// FetchSnapshotVolumeHandle returns a read-only VolumeHandle presenting the named snapshot of volumeName.
+// Each call returns a distinct VolumeHandle (with its own inode cache).
+func FetchSnapshotVolumeHandle(volumeName string, snapshotName string) (volumeHandle VolumeHandle, err error) {
+	volumeHandle, err = fetchSnapshotVolumeHandle(volumeName, snapshotName)
+	return
+}
+
 type VolumeHandle interface {
 	// Generic methods, implemented volume.go
 
 	GetFSID() (fsid uint64)
 
+	// Volume accounting methods, implemented in accounting.go
+
+	FetchVolumeUsage() (usedBytes uint64, numInodes uint64, err error)
+	FetchUserUsage(userID InodeUserID) (usedBytes uint64, numInodes uint64, err error)
+	FetchGroupUsage(groupID InodeGroupID) (usedBytes uint64, numInodes uint64, err error)
+	FetchQuotaTreeUsage(quotaTree InodeNumber) (usedBytes uint64, numInodes uint64, err error)
+	FetchInodeUsage(inodeNumber InodeNumber) (usedBytes uint64, unflushedBytes uint64, err error)
+	SetQuotaTree(inodeNumber InodeNumber, quotaTree InodeNumber) (err error)
+	InheritQuotaTree(dirInodeNumber InodeNumber, inodeNumber InodeNumber) (err error)
+
 	// Common Inode methods, implemented in inode.go
 
 	Access(inodeNumber InodeNumber, userID InodeUserID, groupID InodeGroupID, otherGroupIDs []InodeGroupID, accessMode InodeMode, override AccessOverride) (accessReturn bool)
@@ -150,9 +183,14 @@ type VolumeHandle interface {
 	PutStream(inodeNumber InodeNumber, inodeStreamName string, buf []byte) (err error)
 	DeleteStream(inodeNumber InodeNumber, inodeStreamName string) (err error)
 	GetFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error)
-	Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error)
+	Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (bytesOptimized uint64, err error)
 	Validate(inodeNumber InodeNumber) (err error)
 
+	// POSIX ACL methods, implemented in acl.go
+
+	SetACL(inodeNumber InodeNumber, aclStreamName string, buf []byte) (err error)
+	InheritACL(dirInodeNumber InodeNumber, inodeNumber InodeNumber) (err error)
+
 	// Directory Inode specific methods, implemented in dir.go
 
 	CreateDir(filePerm InodeMode, userID InodeUserID, groupID InodeGroupID) (dirInodeNumber InodeNumber, err error)
@@ -174,6 +212,11 @@ type VolumeHandle interface {
 	SetSize(fileInodeNumber InodeNumber, Size uint64) (err error)
 	Flush(fileInodeNumber InodeNumber, andPurge bool) (err error)
 	Coalesce(containingDirInode InodeNumber, combinationName string, elements []CoalesceElement) (combinationInodeNumber InodeNumber, modificationTime time.Time, numWrites uint64, err error)
+	CloneRange(srcInodeNumber InodeNumber, srcOffset uint64, dstInodeNumber InodeNumber, dstOffset uint64, length uint64) (clonedLength uint64, err error)
+
+	// LogSegment lease methods, implemented in lease.go
+
+	CreateLogSegmentLease(readPlan []ReadPlanStep) (leaseID string, err error)
 
 	// Symlink Inode specific methods, implemented in symlink.go
 
@@ -2364,8 +2364,4 @@ func TestAPI(t *testing.T) {
 	if !fileInodeMetadataAfterSetSize.AccessTime.Equal(fileInodeMetadataAfterWrote.AccessTime) {
 		t.Fatalf("fileInodeMetadataAfterSetSize.AccessTime unexpected change")
 	}
-
-	// TODO: Need to test GetFragmentationReport()
-
-	// TODO: Once implemented, need to test Optimize()
 }
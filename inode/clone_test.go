@@ -0,0 +1,127 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCloneRange(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	srcInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() [src] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(srcInodeNumber, 0, []byte("ABCDEFGH"), nil)
+	if nil != err {
+		t.Fatalf("Write() [src] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(srcInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [src] failed: %v", err)
+	}
+
+	dstInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() [dst] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(dstInodeNumber, 0, []byte("abcdefgh"), nil)
+	if nil != err {
+		t.Fatalf("Write() [dst] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(dstInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [dst] failed: %v", err)
+	}
+
+	readPlanOffset := uint64(0)
+	readPlanLength := uint64(8)
+	readPlan, err := testVolumeHandle.GetReadPlan(srcInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) {
+		t.Fatalf("GetReadPlan() [src] returned %+v [err: %v]", readPlan, err)
+	}
+	srcLogSegmentNumber := readPlan[0].LogSegmentNumber
+
+	// Cloning past the end of src is truncated
+
+	clonedLength, err := testVolumeHandle.CloneRange(srcInodeNumber, 2, dstInodeNumber, 4, 100)
+	if nil != err {
+		t.Fatalf("CloneRange() failed: %v", err)
+	}
+	if 6 != clonedLength {
+		t.Fatalf("CloneRange() returned clonedLength == %v (expected 6)", clonedLength)
+	}
+
+	buf, err := testVolumeHandle.Read(dstInodeNumber, 0, 100, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("abcdCDEFGH"), buf)) {
+		t.Fatalf("Read() [dst] returned \"%s\" [err: %v] (expected \"abcdCDEFGH\")", string(buf), err)
+	}
+
+	volume.accountingMutex.Lock()
+	sharedCount := volume.accounting.SharedLogSegs[srcLogSegmentNumber]
+	volume.accountingMutex.Unlock()
+	if 1 != sharedCount {
+		t.Fatalf("CloneRange() left SharedLogSegs[src LogSegment] == %v (expected 1)", sharedCount)
+	}
+
+	// Cloning again from the same LogSegment does not share it any further
+
+	_, err = testVolumeHandle.CloneRange(srcInodeNumber, 0, dstInodeNumber, 10, 2)
+	if nil != err {
+		t.Fatalf("CloneRange() [again] failed: %v", err)
+	}
+
+	volume.accountingMutex.Lock()
+	sharedCount = volume.accounting.SharedLogSegs[srcLogSegmentNumber]
+	volume.accountingMutex.Unlock()
+	if 1 != sharedCount {
+		t.Fatalf("CloneRange() [again] left SharedLogSegs[src LogSegment] == %v (expected 1)", sharedCount)
+	}
+
+	// Recomputing the accounting must rediscover the sharing
+
+	volume.accountingMutex.Lock()
+	err = volume.headhunterVolumeHandle.DeleteInodeRec(uint64(accountingInodeNumber))
+	if nil != err {
+		t.Fatalf("DeleteInodeRec(accountingInodeNumber) failed: %v", err)
+	}
+	volume.accountingLoaded = false
+	err = volume.accountingLoadWhileLocked()
+	if nil != err {
+		t.Fatalf("accountingLoadWhileLocked() failed: %v", err)
+	}
+	sharedCount = volume.accounting.SharedLogSegs[srcLogSegmentNumber]
+	volume.accountingMutex.Unlock()
+	if 1 != sharedCount {
+		t.Fatalf("Recomputed SharedLogSegs[src LogSegment] == %v (expected 1)", sharedCount)
+	}
+
+	// Destroying src leaves the data referenced by dst intact
+
+	err = testVolumeHandle.Destroy(srcInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [src] failed: %v", err)
+	}
+
+	volume.accountingMutex.Lock()
+	_, stillShared := volume.accounting.SharedLogSegs[srcLogSegmentNumber]
+	volume.accountingMutex.Unlock()
+	if stillShared {
+		t.Fatalf("Destroy() [src] should have removed src LogSegment from SharedLogSegs")
+	}
+
+	buf, err = testVolumeHandle.Read(dstInodeNumber, 0, 100, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("abcdCDEFGHAB"), buf)) {
+		t.Fatalf("Read() [dst after Destroy() of src] returned \"%s\" [err: %v] (expected \"abcdCDEFGHAB\")", string(buf), err)
+	}
+
+	err = testVolumeHandle.Destroy(dstInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [dst] failed: %v", err)
+	}
+}
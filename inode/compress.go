@@ -0,0 +1,274 @@
+package inode
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/swiftstack/sortedmap"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+// A volume configured with CompressChunks set compresses (via compress/flate) each chunk doSendChunk() appends
+// to a LogSegment. Should a chunk not shrink, it is sent as is. Otherwise, the resultant "frame" is sent in its
+// place and the extent recording the chunk's file data notes the frame's location in the LogSegment (FrameOffset
+// and FrameLength) with LogSegmentOffset now relative to the frame's decompressed content. As extents are split
+// and trimmed, many extents may come to refer to (different portions of) the same frame.
+//
+// The packed form of fileExtentStruct including the frame location is larger than the form that preceded it.
+// Hence, a FileInode indicates which form its extents B+Tree uses via FramedExtents. The first time an extent
+// referring to a frame is recorded for a FileInode lacking FramedExtents, it is switched to the new form (see
+// enableFramedExtents()).
+//
+// A ReadPlanStep referring to a frame carries the frame's location as well. While doReadPlan() decompresses such
+// frames (caching lines of their decompressed content in the Read Cache), clients reading LogSegments directly
+// (e.g. pfs_middleware via MiddlewareGetObject()) must fetch the entire frame, inflate it (as raw DEFLATE data),
+// and then extract [Offset:Offset+Length) of the result.
+
+var flateWriterPool = sync.Pool{
+	New: func() interface{} {
+		flateWriter, err := flate.NewWriter(nil, flate.BestSpeed)
+		if nil != err {
+			logger.Fatalf("flate.NewWriter() failed: %v", err)
+		}
+		return flateWriter
+	},
+}
+
+// compressChunk returns the compressed frame for buf... or, if buf does not shrink, buf itself
+func compressChunk(buf []byte) (sendBuf []byte, compressed bool) {
+	var (
+		err         error
+		frame       bytes.Buffer
+		flateWriter *flate.Writer
+	)
+
+	flateWriter = flateWriterPool.Get().(*flate.Writer)
+	flateWriter.Reset(&frame)
+
+	_, err = flateWriter.Write(buf)
+	if nil == err {
+		err = flateWriter.Close()
+	}
+
+	flateWriterPool.Put(flateWriter)
+
+	if (nil != err) || (frame.Len() >= len(buf)) {
+		stats.IncrementOperations(&stats.FileCompressSkipOps)
+		sendBuf = buf
+		compressed = false
+	} else {
+		sendBuf = frame.Bytes()
+		compressed = true
+	}
+
+	// Skipped chunks count too (as sent uncompressed) so that the ratio reflects all chunks
+	stats.IncrementOperationsBytesAndCompressedBytes(stats.FileCompress, uint64(len(buf)), uint64(len(sendBuf)))
+
+	return
+}
+
+func decompressFrame(frame []byte) (buf []byte, err error) {
+	flateReader := flate.NewReader(bytes.NewReader(frame))
+
+	buf, err = ioutil.ReadAll(flateReader)
+	if nil != err {
+		err = fmt.Errorf("Decompressing LogSegment frame failed: %v", err)
+		logger.ErrorWithError(err)
+		err = blunder.AddError(err, blunder.SegReadError)
+		return
+	}
+
+	err = flateReader.Close()
+	return
+}
+
+// enableFramedExtents switches fileInode's extents B+Tree to the packed form of fileExtentStruct recording frames.
+// As nodes not yet loaded are in the prior packed form, all nodes are first loaded (and marked dirty such that
+// the next flush rewrites them in the new form).
+func enableFramedExtents(fileInode *inMemoryInodeStruct) (err error) {
+	extents := fileInode.payload.(sortedmap.BPlusTree)
+
+	err = extents.Touch()
+	if nil != err {
+		return
+	}
+
+	fileInode.FramedExtents = true
+	fileInode.dirty = true
+
+	return
+}
+
+// doFramedReadPlanStep returns the data of a ReadPlanStep referring to a compressed frame. Unless the frame is
+// still in an inFlightLogSegment, all lines of its decompressed content are cached in the Read Cache (keyed by the
+// frame's location) such that subsequent reads need neither fetch nor decompress the frame again.
+func (vS *volumeStruct) doFramedReadPlanStep(fileInode *inMemoryInodeStruct, step ReadPlanStep) (buf []byte, err error) {
+	var (
+		cacheLine          []byte
+		cacheLineHitLength uint64
+		cacheLineHitOffset uint64
+		chunkOffset        uint64
+		flowControl        *flowControlStruct
+		frame              []byte
+		frameBuf           []byte
+		frameCacheLines    [][]byte
+		inFlightHit        bool
+		inFlightLogSegment *inFlightLogSegmentStruct
+		readCacheElement   *readCacheElementStruct
+		readCacheHit       bool
+		readCacheKey       readCacheKeyStruct
+		readCacheLineSize  uint64
+		remainingLength    uint64
+	)
+
+	fileInode.Lock()
+	inFlightLogSegment, inFlightHit = fileInode.inFlightLogSegmentMap[step.LogSegmentNumber]
+	if inFlightHit {
+		frame, err = inFlightLogSegment.Read(step.FrameOffset, step.FrameLength)
+		fileInode.Unlock()
+		if nil != err {
+			logger.ErrorfWithError(err, "Reading back inFlightLogSegment frame failed")
+			err = blunder.AddError(err, blunder.SegReadError)
+			return
+		}
+		stats.IncrementOperations(&stats.FileWritebackHitOps)
+		frameBuf, err = decompressFrame(frame)
+		if nil != err {
+			return
+		}
+		if (step.Offset + step.Length) > uint64(len(frameBuf)) {
+			err = fmt.Errorf("Invalid range for inFlightLogSegment frame")
+			logger.ErrorWithError(err)
+			err = blunder.AddError(err, blunder.SegReadError)
+			return
+		}
+		buf = frameBuf[step.Offset:(step.Offset + step.Length)]
+		return
+	}
+	fileInode.Unlock()
+	stats.IncrementOperations(&stats.FileWritebackMissOps)
+
+	flowControl = vS.flowControl
+	readCacheLineSize = flowControl.readCacheLineSize
+
+	readCacheKey.volumeName = vS.volumeName
+	readCacheKey.logSegmentNumber = step.LogSegmentNumber
+	readCacheKey.frameOffset = step.FrameOffset
+	readCacheKey.frameLength = step.FrameLength
+
+	buf = make([]byte, 0, step.Length)
+
+	chunkOffset = step.Offset
+	remainingLength = step.Length
+
+	for 0 < remainingLength {
+		readCacheKey.cacheLineTag = chunkOffset / readCacheLineSize
+		cacheLineHitOffset = chunkOffset % readCacheLineSize
+		if (cacheLineHitOffset + remainingLength) > readCacheLineSize {
+			cacheLineHitLength = readCacheLineSize - cacheLineHitOffset
+		} else {
+			cacheLineHitLength = remainingLength
+		}
+		flowControl.Lock()
+		readCacheElement, readCacheHit = flowControl.readCache[readCacheKey]
+		if readCacheHit {
+			flowControl.touchReadCacheElementWhileLocked(readCacheElement)
+			cacheLine = readCacheElement.cacheLine
+			flowControl.Unlock()
+			stats.IncrementOperations(&stats.FileReadcacheHitOps)
+		} else {
+			flowControl.Unlock()
+			stats.IncrementOperations(&stats.FileReadcacheMissOps)
+			if nil == frameBuf {
+				frame, err = swiftclient.ObjectGet(step.AccountName, step.ContainerName, step.ObjectName, step.FrameOffset, step.FrameLength)
+				if nil != err {
+					logger.ErrorfWithError(err, "Reading frame from LogSegment object failed")
+					err = blunder.AddError(err, blunder.SegReadError)
+					return
+				}
+				frameBuf, err = decompressFrame(frame)
+				if nil != err {
+					return
+				}
+				// Having paid to fetch and decompress the whole frame, cache all of its lines
+				frameCacheLines = flowControl.insertFrameCacheLines(readCacheKey, frameBuf)
+			}
+			if readCacheKey.cacheLineTag >= uint64(len(frameCacheLines)) {
+				err = fmt.Errorf("Invalid range for LogSegment frame")
+				logger.ErrorWithError(err)
+				err = blunder.AddError(err, blunder.SegReadError)
+				return
+			}
+			cacheLine = frameCacheLines[readCacheKey.cacheLineTag]
+		}
+		if (cacheLineHitOffset + cacheLineHitLength) > uint64(len(cacheLine)) {
+			err = fmt.Errorf("Invalid range for LogSegment frame")
+			logger.ErrorWithError(err)
+			err = blunder.AddError(err, blunder.SegReadError)
+			return
+		}
+		buf = append(buf, cacheLine[cacheLineHitOffset:(cacheLineHitOffset+cacheLineHitLength)]...)
+		chunkOffset += cacheLineHitLength
+		remainingLength -= cacheLineHitLength
+	}
+
+	err = nil
+	return
+}
+
+// insertFrameCacheLines inserts each line of the decompressed content of a frame (identified by readCacheKey,
+// ignoring its cacheLineTag) into the Read Cache (unless already present), returning all of the lines
+func (flowControl *flowControlStruct) insertFrameCacheLines(readCacheKey readCacheKeyStruct, frameBuf []byte) (cacheLines [][]byte) {
+	var (
+		cacheLine            []byte
+		cacheLineEndOffset   uint64
+		cacheLineStartOffset uint64
+		readCacheElement     *readCacheElementStruct
+		readCacheHit         bool
+	)
+
+	cacheLines = make([][]byte, 0, (uint64(len(frameBuf))+flowControl.readCacheLineSize-1)/flowControl.readCacheLineSize)
+
+	flowControl.Lock()
+
+	for cacheLineStartOffset = 0; cacheLineStartOffset < uint64(len(frameBuf)); cacheLineStartOffset = cacheLineEndOffset {
+		cacheLineEndOffset = cacheLineStartOffset + flowControl.readCacheLineSize
+		if cacheLineEndOffset > uint64(len(frameBuf)) {
+			cacheLineEndOffset = uint64(len(frameBuf))
+		}
+
+		readCacheKey.cacheLineTag = cacheLineStartOffset / flowControl.readCacheLineSize
+
+		readCacheElement, readCacheHit = flowControl.readCache[readCacheKey]
+		if readCacheHit {
+			flowControl.touchReadCacheElementWhileLocked(readCacheElement)
+			cacheLines = append(cacheLines, readCacheElement.cacheLine)
+			continue
+		}
+
+		// Copy the line so that the Read Cache does not pin all of frameBuf
+		cacheLine = make([]byte, cacheLineEndOffset-cacheLineStartOffset)
+		copy(cacheLine, frameBuf[cacheLineStartOffset:cacheLineEndOffset])
+
+		readCacheElement = &readCacheElementStruct{
+			readCacheKey: readCacheKey,
+			next:         nil,
+			prev:         nil,
+			cacheLine:    cacheLine,
+		}
+		flowControl.insertReadCacheElementWhileLocked(readCacheElement)
+
+		cacheLines = append(cacheLines, cacheLine)
+	}
+
+	flowControl.Unlock()
+
+	return
+}
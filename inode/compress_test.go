@@ -0,0 +1,153 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	// Start with a FileInode (lacking FramedExtents) of many extents separated by holes
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	expected := make([]byte, 0)
+
+	for i := 0; i < 100; i++ {
+		err = testVolumeHandle.Write(fileInodeNumber, uint64(2*i), []byte{byte(i)}, nil)
+		if nil != err {
+			t.Fatalf("Write() [uncompressed] failed: %v", err)
+		}
+		expected = append(expected, byte(i), 0)
+	}
+	expected = expected[:199]
+
+	err = testVolumeHandle.Flush(fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("Flush() [uncompressed] failed: %v", err)
+	}
+
+	// Now write a compressible chunk beyond them
+
+	volume.compressChunks = true
+	defer func() { volume.compressChunks = false }()
+
+	compressible := bytes.Repeat([]byte("ABCDEFGH"), 1024)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 200, compressible, nil)
+	if nil != err {
+		t.Fatalf("Write() [compressed] failed: %v", err)
+	}
+	expected = append(expected, 0)
+	expected = append(expected, compressible...)
+
+	readPlanOffset := uint64(200)
+	readPlanLength := uint64(len(compressible))
+	readPlan, err := testVolumeHandle.GetReadPlan(fileInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) || (0 == readPlan[0].FrameLength) || (readPlan[0].FrameLength >= uint64(len(compressible))) {
+		t.Fatalf("GetReadPlan() [compressed] returned %+v [err: %v] (expected a single compressed frame)", readPlan, err)
+	}
+
+	fileInode, ok, err := volume.fetchInode(fileInodeNumber)
+	if (nil != err) || !ok || !fileInode.FramedExtents {
+		t.Fatalf("Write() [compressed] should have enabled FramedExtents [ok: %v err: %v]", ok, err)
+	}
+
+	buf, err := testVolumeHandle.Read(fileInodeNumber, 0, uint64(len(expected)), nil)
+	if (nil != err) || (0 != bytes.Compare(expected, buf)) {
+		t.Fatalf("Read() [before Flush()] returned unexpected data [err: %v]", err)
+	}
+
+	// Overwriting the middle of the frame splits its extent in two (both still referring to the frame)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 300, []byte("xyz"), nil)
+	if nil != err {
+		t.Fatalf("Write() [overwrite] failed: %v", err)
+	}
+	copy(expected[300:], []byte("xyz"))
+
+	err = testVolumeHandle.Flush(fileInodeNumber, true)
+	if nil != err {
+		t.Fatalf("Flush() [compressed] failed: %v", err)
+	}
+
+	// Reading (twice, so as to hit the Read Cache) after a Purge() must unpack all extents correctly
+
+	buf, err = testVolumeHandle.Read(fileInodeNumber, 0, uint64(len(expected)), nil)
+	if (nil != err) || (0 != bytes.Compare(expected, buf)) {
+		t.Fatalf("Read() [after Flush()] returned unexpected data [err: %v]", err)
+	}
+
+	buf, err = testVolumeHandle.Read(fileInodeNumber, 296, 8, nil)
+	if (nil != err) || (0 != bytes.Compare(expected[296:304], buf)) {
+		t.Fatalf("Read() [after Flush() again] returned \"%s\" [err: %v] (expected \"%s\")", string(buf), err, string(expected[296:304]))
+	}
+
+	// Incompressible data is sent as is
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0xFF}, nil)
+	if nil != err {
+		t.Fatalf("Write() [incompressible] failed: %v", err)
+	}
+
+	readPlanOffset = uint64(0)
+	readPlanLength = uint64(1)
+	readPlan, err = testVolumeHandle.GetReadPlan(fileInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) || (0 != readPlan[0].FrameLength) {
+		t.Fatalf("GetReadPlan() [incompressible] returned %+v [err: %v] (expected an uncompressed step)", readPlan, err)
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
+
+func TestCompressFrameCacheLines(t *testing.T) {
+	flowControl := &flowControlStruct{
+		readCacheLineSize:  4,
+		readCacheLineCount: 100,
+		readCache:          make(map[readCacheKeyStruct]*readCacheElementStruct),
+	}
+
+	readCacheKey := readCacheKeyStruct{
+		volumeName:       "TestVolume",
+		logSegmentNumber: 1,
+		cacheLineTag:     1,
+		frameOffset:      2,
+		frameLength:      3,
+	}
+
+	// Decompressing a frame must cache all of its lines (not just the one being read)
+
+	cacheLines := flowControl.insertFrameCacheLines(readCacheKey, []byte("ABCDEFGHIJ"))
+	if (3 != len(cacheLines)) || ("ABCD" != string(cacheLines[0])) || ("EFGH" != string(cacheLines[1])) || ("IJ" != string(cacheLines[2])) {
+		t.Fatalf("insertFrameCacheLines() returned %q (expected [\"ABCD\" \"EFGH\" \"IJ\"])", cacheLines)
+	}
+	if 3 != len(flowControl.readCache) {
+		t.Fatalf("insertFrameCacheLines() left %d lines in the Read Cache (expected 3)", len(flowControl.readCache))
+	}
+	for cacheLineTag := uint64(0); cacheLineTag < 3; cacheLineTag++ {
+		readCacheKey.cacheLineTag = cacheLineTag
+		readCacheElement, ok := flowControl.readCache[readCacheKey]
+		if !ok || (string(cacheLines[cacheLineTag]) != string(readCacheElement.cacheLine)) {
+			t.Fatalf("insertFrameCacheLines() did not cache line %d", cacheLineTag)
+		}
+	}
+
+	// Lines already cached are reused
+
+	cacheLines = flowControl.insertFrameCacheLines(readCacheKey, []byte("ABCDEFGHIJ"))
+	if (3 != len(cacheLines)) || (3 != len(flowControl.readCache)) {
+		t.Fatalf("insertFrameCacheLines() [again] returned %d lines leaving %d in the Read Cache (expected 3 & 3)", len(cacheLines), len(flowControl.readCache))
+	}
+}
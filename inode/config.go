@@ -30,7 +30,9 @@ type physicalContainerLayoutStruct struct {
 type readCacheKeyStruct struct {
 	volumeName       string
 	logSegmentNumber uint64
-	cacheLineTag     uint64 // LogSegment offset / readCacheLineSize
+	cacheLineTag     uint64 // LogSegment offset / readCacheLineSize (or, if frameLength != 0, decompressed frame offset / readCacheLineSize)
+	frameOffset      uint64 // If frameLength != 0, LogSegment offset of the compressed frame whose decompressed content is cached
+	frameLength      uint64
 }
 
 type readCacheElementStruct struct {
@@ -63,6 +65,8 @@ type volumeStruct struct {
 	activePeerPrivateIPAddr        string
 	maxEntriesPerDirNode           uint64
 	maxExtentsPerFileNode          uint64
+	dedupChunkSize                 uint64                                    // if != 0, Write()s are deduplicated in chunks of this size (see dedup.go)
+	compressChunks                 bool                                      // if true, chunks sent to LogSegments are compressed (see compress.go)
 	physicalContainerLayoutSet     map[string]struct{}                       // key == physicalContainerLayoutStruct.physicalContainerLayoutName
 	physicalContainerNamePrefixSet map[string]struct{}                       // key == physicalContainerLayoutStruct.physicalContainerNamePrefix
 	physicalContainerLayoutMap     map[string]*physicalContainerLayoutStruct // key == physicalContainerLayoutStruct.physicalContainerLayoutName
@@ -70,6 +74,15 @@ type volumeStruct struct {
 	flowControl                    *flowControlStruct
 	headhunterVolumeHandle         headhunter.VolumeHandle
 	inodeCache                     map[InodeNumber]*inMemoryInodeStruct //      key == InodeNumber
+	leasedLogSegmentMap            map[uint64]uint64                    //      key == LogSegmentNumber; value == number of leases
+	deferredLogSegmentDeleteSet    map[uint64]struct{}                  //      key == LogSegmentNumber awaiting release of all leases
+	unreferencedLogSegmentSet      map[uint64]struct{}                  //      key == LogSegmentNumber awaiting deletion; synchronized via accountingMutex
+	accountingPutMutex             sync.Mutex                           //      Serializes puts of the accounting inodeRecs (see accounting.go)
+	accountingMutex                sync.Mutex
+	accountingLoaded               bool                     //      Synchronized via accountingMutex
+	accountingDirty                bool                     //      Synchronized via accountingMutex; if true, accounting totals not yet put
+	accountingDirtyKeys            map[uint64]struct{}      //      Synchronized via accountingMutex; key == accountingKey() of usage not yet put
+	accounting                     onDiskAccountingV1Struct //      Synchronized via accountingMutex
 }
 
 type globalsStruct struct {
@@ -82,11 +95,14 @@ type globalsStruct struct {
 	accountMap                   map[string]*volumeStruct      // key == volumeStruct.accountName
 	flowControlMap               map[string]*flowControlStruct // key == flowControlStruct.flowControlName
 	fileExtentStructSize         uint64                        // pre-calculated size of cstruct-packed fileExtentStruct
+	fileExtentV1StructSize       uint64                        // pre-calculated size of cstruct-packed fileExtentV1Struct
 	supportedOnDiskInodeVersions map[Version]struct{}          // key == on disk inode version
 	corruptionDetectedTrueBuf    []byte                        // holds serialized CorruptionDetected == true
 	corruptionDetectedFalseBuf   []byte                        // holds serialized CorruptionDetected == false
 	versionV1Buf                 []byte                        // holds serialized Version            == V1
 	inodeRecDefaultPreambleBuf   []byte                        // holds concatenated corruptionDetectedFalseBuf & versionV1Buf
+	logSegmentLeaseDuration      time.Duration
+	logSegmentLeaseMap           map[string]*logSegmentLeaseStruct // key == logSegmentLeaseStruct.leaseID
 }
 
 var globals globalsStruct
@@ -179,6 +195,13 @@ func Up(confMap conf.ConfMap) (err error) {
 	globals.accountMap = make(map[string]*volumeStruct)
 	globals.flowControlMap = make(map[string]*flowControlStruct)
 
+	globals.logSegmentLeaseDuration, err = confMap.FetchOptionValueDuration("FSGlobals", "LogSegmentLeaseDuration")
+	if nil != err {
+		globals.logSegmentLeaseDuration = logSegmentLeaseDefaultDuration
+	}
+
+	globals.logSegmentLeaseMap = make(map[string]*logSegmentLeaseStruct)
+
 	volumeList, err = confMap.FetchOptionValueStringSlice("FSGlobals", "VolumeList")
 	if nil != err {
 		return
@@ -193,6 +216,10 @@ func Up(confMap conf.ConfMap) (err error) {
 			physicalContainerNamePrefixSet: make(map[string]struct{}),
 			physicalContainerLayoutMap:     make(map[string]*physicalContainerLayoutStruct),
 			inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+			leasedLogSegmentMap:            make(map[uint64]uint64),
+			deferredLogSegmentDeleteSet:    make(map[uint64]struct{}),
+			unreferencedLogSegmentSet:      make(map[uint64]struct{}),
+			accountingDirtyKeys:            make(map[uint64]struct{}),
 		}
 
 		volume.fsid, err = confMap.FetchOptionValueUint64(volumeSectionName, "FSID")
@@ -255,6 +282,20 @@ func Up(confMap conf.ConfMap) (err error) {
 				return
 			}
 
+			volume.dedupChunkSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "DedupChunkSize")
+			if nil != err {
+				volume.dedupChunkSize = 0 // Default to no deduplication
+			}
+			err = dedupValidateChunkSize(volume.volumeName, volume.dedupChunkSize)
+			if nil != err {
+				return
+			}
+
+			volume.compressChunks, err = confMap.FetchOptionValueBool(volumeSectionName, "CompressChunks")
+			if nil != err {
+				volume.compressChunks = false // Default to no compression
+			}
+
 			// [Case 1] For now, physicalContainerLayoutNameSlice will simply contain only defaultPhysicalContainerLayoutName
 			//
 			// The expectation is that, at some point, multiple container layouts may be supported along with
@@ -383,6 +424,11 @@ func Up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	globals.fileExtentV1StructSize, _, err = cstruct.Examine(fileExtentV1Struct{})
+	if nil != err {
+		return
+	}
+
 	globals.supportedOnDiskInodeVersions = make(map[Version]struct{})
 
 	globals.supportedOnDiskInodeVersions[V1] = struct{}{}
@@ -716,6 +762,10 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 				physicalContainerNamePrefixSet: make(map[string]struct{}),
 				physicalContainerLayoutMap:     make(map[string]*physicalContainerLayoutStruct),
 				inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+				leasedLogSegmentMap:            make(map[uint64]uint64),
+				deferredLogSegmentDeleteSet:    make(map[uint64]struct{}),
+				unreferencedLogSegmentSet:      make(map[uint64]struct{}),
+				accountingDirtyKeys:            make(map[uint64]struct{}),
 			}
 
 			globals.volumeMap[volume.volumeName] = volume
@@ -745,6 +795,20 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 				return
 			}
 
+			volume.dedupChunkSize, err = confMap.FetchOptionValueUint64(volumeSectionName, "DedupChunkSize")
+			if nil != err {
+				volume.dedupChunkSize = 0 // Default to no deduplication
+			}
+			err = dedupValidateChunkSize(volume.volumeName, volume.dedupChunkSize)
+			if nil != err {
+				return
+			}
+
+			volume.compressChunks, err = confMap.FetchOptionValueBool(volumeSectionName, "CompressChunks")
+			if nil != err {
+				volume.compressChunks = false // Default to no compression
+			}
+
 			defaultPhysicalContainerLayoutName, err = confMap.FetchOptionValueString(volumeSectionName, "DefaultPhysicalContainerLayout")
 			if nil != err {
 				return
@@ -0,0 +1,211 @@
+package inode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/swiftstack/cstruct"
+
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// A volume configured with a non-zero DedupChunkSize deduplicates file data written to it. Write() splits its
+// buffer at multiples of DedupChunkSize (in file offset terms) and each complete, aligned chunk is fingerprinted
+// (SHA-256) by doSendChunk(). The leading 8 bytes of the digest key a record in the volume's fingerprintRec B+Tree
+// (maintained by headhunter) identifying where a chunk with that digest was previously written. If such a record
+// is found (and its full digest and length match), the extent simply refers to that range of the existing
+// LogSegment rather than sending the chunk again.
+//
+// Fingerprint records are only put once the LogSegment they describe has been successfully closed (see
+// inFlightLogSegmentFlusher()), so no extent can ever refer to data that was not durably written. As with
+// CloneRange(), a FileInode that comes to reference another FileInode's LogSegment increments that LogSegment's
+// count in the accounting's SharedLogSegs so that it is not deleted while still referenced.
+//
+// As only a chunk written by a single Write() is fingerprinted, DedupChunkSize must be a power of two no larger
+// than the largest Write() a FUSE mount issues (dedupChunkSizeMax) such that aligned, full-sized writes cover
+// whole chunks.
+//
+// Alongside the fingerprint records for a LogSegment, a record keyed by dedupLogSegmentKey() lists their
+// fingerprints. When the LogSegment is deleted (see deleteLogSegmentAsync()), dedupForgetLogSegment() uses it
+// to delete them. Should a fingerprint record nevertheless outlive the LogSegment it describes (e.g. after a
+// failed delete), it is detected (and deleted) on lookup.
+
+const (
+	dedupChunkSizeMax       = uint64(128 * 1024)
+	dedupLogSegmentKeyBase  = uint64(0x8000000000000000) // Fingerprints have this bit cleared
+	dedupFingerprintKeyMask = dedupLogSegmentKeyBase - 1
+)
+
+type fingerprintRecV1Struct struct {
+	Digest           [sha256.Size]byte
+	LogSegmentNumber uint64
+	LogSegmentOffset uint64 // If FrameLength != 0, relative to the decompressed content of the frame
+	FrameOffset      uint64
+	FrameLength      uint64 // If == 0, the chunk was not compressed (see compress.go)
+	Length           uint64
+}
+
+func dedupFingerprintOf(buf []byte) (fingerprint uint64, digest [sha256.Size]byte) {
+	digest = sha256.Sum256(buf)
+	fingerprint = binary.BigEndian.Uint64(digest[:8]) & dedupFingerprintKeyMask
+	return
+}
+
+// dedupLogSegmentKey returns the key of the record listing the fingerprints recorded for logSegmentNumber
+func dedupLogSegmentKey(logSegmentNumber uint64) (key uint64) {
+	key = dedupLogSegmentKeyBase | logSegmentNumber
+	return
+}
+
+func dedupValidateChunkSize(volumeName string, dedupChunkSize uint64) (err error) {
+	if (0 != dedupChunkSize) && ((dedupChunkSize > dedupChunkSizeMax) || (0 != (dedupChunkSize & (dedupChunkSize - 1)))) {
+		err = fmt.Errorf("Volume \"%v\" DedupChunkSize (%v) must be a power of two no larger than %v", volumeName, dedupChunkSize, dedupChunkSizeMax)
+	}
+	return
+}
+
+// dedupLookup returns the location of a previously written chunk matching digest and length. If found and
+// fileInode does not already reference the LogSegment holding it, the LogSegment is shared with fileInode.
+func (vS *volumeStruct) dedupLookup(fileInode *inMemoryInodeStruct, fingerprint uint64, digest [sha256.Size]byte, length uint64) (logSegmentNumber uint64, logSegmentOffset uint64, frameOffset uint64, frameLength uint64, found bool) {
+	var (
+		alreadyReferenced bool
+		err               error
+		fingerprintRec    fingerprintRecV1Struct
+		fingerprintRecBuf []byte
+		ok                bool
+	)
+
+	fingerprintRecBuf, ok, err = vS.headhunterVolumeHandle.GetFingerprintRec(fingerprint)
+	if (nil != err) || !ok {
+		return
+	}
+
+	_, err = cstruct.Unpack(fingerprintRecBuf, &fingerprintRec, cstruct.LittleEndian)
+	if nil != err {
+		logger.WarnfWithError(err, "volume '%s' fingerprintRec 0x%016X unpack failed", vS.volumeName, fingerprint)
+		return
+	}
+
+	if (0 != bytes.Compare(digest[:], fingerprintRec.Digest[:])) || (length != fingerprintRec.Length) {
+		return
+	}
+
+	logSegmentNumber = fingerprintRec.LogSegmentNumber
+	logSegmentOffset = fingerprintRec.LogSegmentOffset
+	frameOffset = fingerprintRec.FrameOffset
+	frameLength = fingerprintRec.FrameLength
+
+	_, alreadyReferenced = fileInode.LogSegmentMap[logSegmentNumber]
+	if alreadyReferenced {
+		found = true
+		return
+	}
+
+	found = vS.shareLiveLogSegment(logSegmentNumber)
+	if !found {
+		// LogSegment has been (or is about to be) deleted... so the fingerprintRec is stale
+
+		err = vS.headhunterVolumeHandle.DeleteFingerprintRec(fingerprint)
+		if nil != err {
+			logger.WarnfWithError(err, "volume '%s' stale fingerprintRec 0x%016X delete failed", vS.volumeName, fingerprint)
+		}
+	}
+
+	return
+}
+
+func packFingerprintRec(digest [sha256.Size]byte, logSegmentNumber uint64, logSegmentOffset uint64, frameOffset uint64, frameLength uint64, length uint64) (fingerprintRecBuf []byte) {
+	var (
+		err error
+	)
+
+	fingerprintRecBuf, err = cstruct.Pack(fingerprintRecV1Struct{
+		Digest:           digest,
+		LogSegmentNumber: logSegmentNumber,
+		LogSegmentOffset: logSegmentOffset,
+		FrameOffset:      frameOffset,
+		FrameLength:      frameLength,
+		Length:           length,
+	}, cstruct.LittleEndian)
+	if nil != err {
+		logger.Fatalf("cstruct.Pack() of fingerprintRecV1Struct failed: %v", err)
+	}
+
+	return
+}
+
+// dedupRecordLogSegment puts the fingerprint records for a (now durable) LogSegment along with the record
+// listing their fingerprints
+func (vS *volumeStruct) dedupRecordLogSegment(logSegmentNumber uint64, fingerprintRecs map[uint64][]byte) {
+	var (
+		err               error
+		fingerprint       uint64
+		fingerprintRecBuf []byte
+		fingerprintsBuf   []byte
+	)
+
+	if 0 == len(fingerprintRecs) {
+		return
+	}
+
+	fingerprintsBuf = make([]byte, 0, 8*len(fingerprintRecs))
+
+	for fingerprint, fingerprintRecBuf = range fingerprintRecs {
+		err = vS.headhunterVolumeHandle.PutFingerprintRec(fingerprint, fingerprintRecBuf)
+		if nil != err {
+			logger.WarnfWithError(err, "volume '%s' recording fingerprintRec for LogSegment 0x%016X failed", vS.volumeName, logSegmentNumber)
+			continue
+		}
+		fingerprintsBuf = append(fingerprintsBuf, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(fingerprintsBuf[len(fingerprintsBuf)-8:], fingerprint)
+	}
+
+	err = vS.headhunterVolumeHandle.PutFingerprintRec(dedupLogSegmentKey(logSegmentNumber), fingerprintsBuf)
+	if nil != err {
+		logger.WarnfWithError(err, "volume '%s' recording fingerprints of LogSegment 0x%016X failed", vS.volumeName, logSegmentNumber)
+	}
+}
+
+// dedupForgetLogSegment deletes the fingerprint records (still) describing a LogSegment being deleted
+func (vS *volumeStruct) dedupForgetLogSegment(logSegmentNumber uint64) {
+	var (
+		err               error
+		fingerprint       uint64
+		fingerprintRec    fingerprintRecV1Struct
+		fingerprintRecBuf []byte
+		fingerprintsBuf   []byte
+		ok                bool
+	)
+
+	fingerprintsBuf, ok, err = vS.headhunterVolumeHandle.GetFingerprintRec(dedupLogSegmentKey(logSegmentNumber))
+	if (nil != err) || !ok {
+		return
+	}
+
+	for ; len(fingerprintsBuf) >= 8; fingerprintsBuf = fingerprintsBuf[8:] {
+		fingerprint = binary.LittleEndian.Uint64(fingerprintsBuf[:8])
+
+		fingerprintRecBuf, ok, err = vS.headhunterVolumeHandle.GetFingerprintRec(fingerprint)
+		if (nil != err) || !ok {
+			continue
+		}
+
+		_, err = cstruct.Unpack(fingerprintRecBuf, &fingerprintRec, cstruct.LittleEndian)
+		if (nil == err) && (logSegmentNumber != fingerprintRec.LogSegmentNumber) {
+			// Since replaced by a record describing a chunk in another LogSegment
+			continue
+		}
+
+		err = vS.headhunterVolumeHandle.DeleteFingerprintRec(fingerprint)
+		if nil != err {
+			logger.WarnfWithError(err, "volume '%s' fingerprintRec 0x%016X delete failed", vS.volumeName, fingerprint)
+		}
+	}
+
+	err = vS.headhunterVolumeHandle.DeleteFingerprintRec(dedupLogSegmentKey(logSegmentNumber))
+	if nil != err {
+		logger.WarnfWithError(err, "volume '%s' fingerprints of LogSegment 0x%016X delete failed", vS.volumeName, logSegmentNumber)
+	}
+}
@@ -0,0 +1,146 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDedup(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.dedupChunkSize = 8
+	defer func() { volume.dedupChunkSize = 0 }()
+
+	srcInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() [src] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(srcInodeNumber, 0, []byte("ABCDEFGHIJKLMNOP"), nil)
+	if nil != err {
+		t.Fatalf("Write() [src] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(srcInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [src] failed: %v", err)
+	}
+
+	readPlanOffset := uint64(0)
+	readPlanLength := uint64(16)
+	readPlan, err := testVolumeHandle.GetReadPlan(srcInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) {
+		t.Fatalf("GetReadPlan() [src] returned %+v [err: %v]", readPlan, err)
+	}
+	srcLogSegmentNumber := readPlan[0].LogSegmentNumber
+
+	// Writing the same (aligned) chunks to dst must refer to src's LogSegment
+
+	dstInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() [dst] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(dstInodeNumber, 0, []byte("IJKLMNOPABCDEFGHxyz"), nil)
+	if nil != err {
+		t.Fatalf("Write() [dst] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(dstInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [dst] failed: %v", err)
+	}
+
+	readPlanOffset = uint64(0)
+	readPlanLength = uint64(16)
+	readPlan, err = testVolumeHandle.GetReadPlan(dstInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (2 != len(readPlan)) {
+		t.Fatalf("GetReadPlan() [dst] returned %+v [err: %v]", readPlan, err)
+	}
+	if (srcLogSegmentNumber != readPlan[0].LogSegmentNumber) || (8 != readPlan[0].Offset) ||
+		(srcLogSegmentNumber != readPlan[1].LogSegmentNumber) || (0 != readPlan[1].Offset) {
+		t.Fatalf("GetReadPlan() [dst] returned %+v (expected both steps to refer to src LogSegment 0x%016X)", readPlan, srcLogSegmentNumber)
+	}
+
+	buf, err := testVolumeHandle.Read(dstInodeNumber, 0, 100, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("IJKLMNOPABCDEFGHxyz"), buf)) {
+		t.Fatalf("Read() [dst] returned \"%s\" [err: %v] (expected \"IJKLMNOPABCDEFGHxyz\")", string(buf), err)
+	}
+
+	volume.accountingMutex.Lock()
+	sharedCount := volume.accounting.SharedLogSegs[srcLogSegmentNumber]
+	volume.accountingMutex.Unlock()
+	if 1 != sharedCount {
+		t.Fatalf("Write() [dst] left SharedLogSegs[src LogSegment] == %v (expected 1)", sharedCount)
+	}
+
+	// Destroying src leaves the data referenced by dst intact
+
+	err = testVolumeHandle.Destroy(srcInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [src] failed: %v", err)
+	}
+
+	buf, err = testVolumeHandle.Read(dstInodeNumber, 0, 100, nil)
+	if (nil != err) || (0 != bytes.Compare([]byte("IJKLMNOPABCDEFGHxyz"), buf)) {
+		t.Fatalf("Read() [dst after Destroy() of src] returned \"%s\" [err: %v] (expected \"IJKLMNOPABCDEFGHxyz\")", string(buf), err)
+	}
+
+	err = testVolumeHandle.Destroy(dstInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [dst] failed: %v", err)
+	}
+
+	// Deleting src's LogSegment deletes its fingerprintRecs (and the record listing them)
+
+	for _, chunk := range []string{"ABCDEFGH", "IJKLMNOP"} {
+		fingerprint, _ := dedupFingerprintOf([]byte(chunk))
+		_, ok, err := volume.headhunterVolumeHandle.GetFingerprintRec(fingerprint)
+		if (nil != err) || ok {
+			t.Fatalf("GetFingerprintRec() [\"%s\"] after LogSegment delete returned (%v, %v) (expected (false, nil))", chunk, ok, err)
+		}
+	}
+	_, ok, err := volume.headhunterVolumeHandle.GetFingerprintRec(dedupLogSegmentKey(srcLogSegmentNumber))
+	if (nil != err) || ok {
+		t.Fatalf("GetFingerprintRec() [src LogSegment] after LogSegment delete returned (%v, %v) (expected (false, nil))", ok, err)
+	}
+
+	// Nor may a (new) LogSegment refer to it
+
+	newInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, InodeRootUserID, InodeGroupID(0))
+	if nil != err {
+		t.Fatalf("CreateFile() [new] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(newInodeNumber, 0, []byte("ABCDEFGH"), nil)
+	if nil != err {
+		t.Fatalf("Write() [new] failed: %v", err)
+	}
+
+	readPlanOffset = uint64(0)
+	readPlanLength = uint64(8)
+	readPlan, err = testVolumeHandle.GetReadPlan(newInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) || (srcLogSegmentNumber == readPlan[0].LogSegmentNumber) {
+		t.Fatalf("GetReadPlan() [new] returned %+v [err: %v] (expected a new LogSegment)", readPlan, err)
+	}
+
+	err = testVolumeHandle.Destroy(newInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy() [new] failed: %v", err)
+	}
+}
+
+func TestDedupValidateChunkSize(t *testing.T) {
+	for _, dedupChunkSize := range []uint64{0, 8, 4096, dedupChunkSizeMax} {
+		err := dedupValidateChunkSize("TestVolume", dedupChunkSize)
+		if nil != err {
+			t.Fatalf("dedupValidateChunkSize(%v) failed: %v", dedupChunkSize, err)
+		}
+	}
+	for _, dedupChunkSize := range []uint64{3000, 2 * dedupChunkSizeMax} {
+		err := dedupValidateChunkSize("TestVolume", dedupChunkSize)
+		if nil == err {
+			t.Fatalf("dedupValidateChunkSize(%v) should have failed", dedupChunkSize)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package inode
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/swiftstack/sortedmap"
+)
+
+// NB: test setup and such is in api_test.go (look for TestMain function)
+
+func TestGetFragmentationReport(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of empty file failed: %v", err)
+	}
+	if (0 != fragmentationReport.NumberOfFragments) || (0 != fragmentationReport.BytesInFragments) || (0 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of empty file returned unexpected %+v", fragmentationReport)
+	}
+
+	// Two discontiguous writes land in the same LogSegment but produce two extents (with a hole between them)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0x61, 0x61, 0x61, 0x61}, nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 0, ...) failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, 8, []byte{0x62, 0x62, 0x62, 0x62}, nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 8, ...) failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of in-flight file failed: %v", err)
+	}
+	if (2 != fragmentationReport.NumberOfFragments) || (8 != fragmentationReport.BytesInFragments) || (0 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of in-flight file returned unexpected %+v", fragmentationReport)
+	}
+
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush(fileInodeNumber, false) failed: %v", err)
+	}
+
+	// Overwriting the first extent leaves its original bytes trapped in the first LogSegment
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, []byte{0x63, 0x63, 0x63, 0x63}, nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 0, ...) overwrite failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush(fileInodeNumber, false) failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of overwritten file failed: %v", err)
+	}
+	if (2 != fragmentationReport.NumberOfFragments) || (8 != fragmentationReport.BytesInFragments) || (4 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of overwritten file returned unexpected %+v", fragmentationReport)
+	}
+
+	dirInodeNumber, err := testVolumeHandle.CreateDir(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateDir() failed: %v", err)
+	}
+
+	_, err = testVolumeHandle.GetFragmentationReport(dirInodeNumber)
+	if nil == err {
+		t.Fatalf("GetFragmentationReport() of a DirInode should have failed")
+	}
+
+	err = testVolumeHandle.Destroy(dirInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(dirInodeNumber) failed: %v", err)
+	}
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumber) failed: %v", err)
+	}
+}
+
+func TestGetFragmentationReportCompressed(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.compressChunks = true
+	defer func() { volume.compressChunks = false }()
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Write two compressible chunks into the same LogSegment (so that each occupies its own frame)
+
+	chunkLength := uint64(8192)
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, bytes.Repeat([]byte("ABCDEFGH"), int(chunkLength/8)), nil)
+	if nil != err {
+		t.Fatalf("Write() [first chunk] failed: %v", err)
+	}
+	err = testVolumeHandle.Write(fileInodeNumber, chunkLength, bytes.Repeat([]byte("IJKLMNOP"), int(chunkLength/8)), nil)
+	if nil != err {
+		t.Fatalf("Write() [second chunk] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [chunks] failed: %v", err)
+	}
+
+	readPlanOffset := chunkLength
+	readPlanLength := chunkLength
+	readPlan, err := testVolumeHandle.GetReadPlan(fileInodeNumber, &readPlanOffset, &readPlanLength)
+	if (nil != err) || (1 != len(readPlan)) || (0 == readPlan[0].FrameLength) {
+		t.Fatalf("GetReadPlan() [second chunk] returned %+v [err: %v] (expected a single compressed frame)", readPlan, err)
+	}
+	secondFrameLength := readPlan[0].FrameLength
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of compressed file failed: %v", err)
+	}
+	if (2*chunkLength != fragmentationReport.BytesInFragments) || (0 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of compressed file returned unexpected %+v", fragmentationReport)
+	}
+
+	// Overwriting all but one byte of the first frame traps nothing (as the whole frame is still needed)...
+	// while overwriting all of the second frame traps exactly that frame
+
+	err = testVolumeHandle.Write(fileInodeNumber, 1, make([]byte, 2*chunkLength-1), nil)
+	if nil != err {
+		t.Fatalf("Write() [overwrite] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush() [overwrite] failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of overwritten compressed file failed: %v", err)
+	}
+	if (2*chunkLength != fragmentationReport.BytesInFragments) || (secondFrameLength != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() of overwritten compressed file returned unexpected %+v (expected BytesTrapped == %v)", fragmentationReport, secondFrameLength)
+	}
+
+	// Bytes of a LogSegment shared with another FileInode are not trapped by this one
+
+	cloneInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() [clone] failed: %v", err)
+	}
+	_, err = testVolumeHandle.CloneRange(fileInodeNumber, 0, cloneInodeNumber, 0, 1)
+	if nil != err {
+		t.Fatalf("CloneRange() failed: %v", err)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() of shared compressed file failed: %v", err)
+	}
+	if 0 != fragmentationReport.BytesTrapped {
+		t.Fatalf("GetFragmentationReport() of shared compressed file returned unexpected %+v", fragmentationReport)
+	}
+
+	err = testVolumeHandle.Destroy(cloneInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(cloneInodeNumber) failed: %v", err)
+	}
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumber) failed: %v", err)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	var (
+		expectedBuf []byte
+	)
+
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Build two runs of four file-contiguous extents (each in its own LogSegment) separated by a hole
+
+	expectedBuf = make([]byte, 48)
+
+	for _, fileOffset := range []uint64{0, 4, 8, 12, 32, 36, 40, 44} {
+		buf := []byte{byte(fileOffset), byte(fileOffset + 1), byte(fileOffset + 2), byte(fileOffset + 3)}
+		copy(expectedBuf[fileOffset:], buf)
+		err = testVolumeHandle.Write(fileInodeNumber, fileOffset, buf, nil)
+		if nil != err {
+			t.Fatalf("Write(fileInodeNumber, %d, ...) failed: %v", fileOffset, err)
+		}
+		err = testVolumeHandle.Flush(fileInodeNumber, false)
+		if nil != err {
+			t.Fatalf("Flush(fileInodeNumber, false) failed: %v", err)
+		}
+	}
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if (8 != fragmentationReport.NumberOfFragments) || (32 != fragmentationReport.BytesInFragments) {
+		t.Fatalf("GetFragmentationReport() before Optimize() returned unexpected %+v", fragmentationReport)
+	}
+
+	// An expired time budget should accomplish nothing
+
+	bytesOptimized, err := testVolumeHandle.Optimize(fileInodeNumber, time.Duration(0))
+	if nil != err {
+		t.Fatalf("Optimize(fileInodeNumber, 0) failed: %v", err)
+	}
+	if 0 != bytesOptimized {
+		t.Fatalf("Optimize(fileInodeNumber, 0) returned unexpected bytesOptimized: %v", bytesOptimized)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if 8 != fragmentationReport.NumberOfFragments {
+		t.Fatalf("GetFragmentationReport() after Optimize(fileInodeNumber, 0) returned unexpected %+v", fragmentationReport)
+	}
+
+	// A sufficient time budget should rewrite each run into a single extent
+
+	bytesOptimized, err = testVolumeHandle.Optimize(fileInodeNumber, time.Minute)
+	if nil != err {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) failed: %v", err)
+	}
+	if 32 != bytesOptimized {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) returned unexpected bytesOptimized: %v", bytesOptimized)
+	}
+
+	fragmentationReport, err = testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("GetFragmentationReport() failed: %v", err)
+	}
+	if (2 != fragmentationReport.NumberOfFragments) || (32 != fragmentationReport.BytesInFragments) || (0 != fragmentationReport.BytesTrapped) {
+		t.Fatalf("GetFragmentationReport() after Optimize() returned unexpected %+v", fragmentationReport)
+	}
+
+	fileInode, err := (testVolumeHandle.(*volumeStruct)).fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumber, FileType) failed: %v", err)
+	}
+	// Both runs were sent to the same (fresh) LogSegment
+	if 1 != len(fileInode.LogSegmentMap) {
+		t.Fatalf("Optimize() should have released all superseded LogSegments")
+	}
+	optimizedLogSegmentMap := make(map[uint64]uint64)
+	for logSegmentNumber, logSegmentBytesUsed := range fileInode.LogSegmentMap {
+		optimizedLogSegmentMap[logSegmentNumber] = logSegmentBytesUsed
+	}
+
+	// As each run now occupies a single extent, a subsequent call should rewrite nothing
+
+	bytesOptimized, err = testVolumeHandle.Optimize(fileInodeNumber, time.Minute)
+	if nil != err {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) [again] failed: %v", err)
+	}
+	if (0 != bytesOptimized) || (len(optimizedLogSegmentMap) != len(fileInode.LogSegmentMap)) {
+		t.Fatalf("Optimize() [again] should not have rewritten any runs")
+	}
+	for logSegmentNumber := range optimizedLogSegmentMap {
+		if _, ok := fileInode.LogSegmentMap[logSegmentNumber]; !ok {
+			t.Fatalf("Optimize() [again] should not have rewritten any runs")
+		}
+	}
+
+	readBuf, err := testVolumeHandle.Read(fileInodeNumber, 0, 48, nil)
+	if nil != err {
+		t.Fatalf("Read(fileInodeNumber, 0, 48) failed: %v", err)
+	}
+	if 0 != bytes.Compare(expectedBuf, readBuf) {
+		t.Fatalf("Read(fileInodeNumber, 0, 48) after Optimize() returned unexpected data")
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumber) failed: %v", err)
+	}
+}
+
+func TestOptimizeContiguous(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Write(fileInodeNumber, 0, bytes.Repeat([]byte{'A'}, 4096), nil)
+	if nil != err {
+		t.Fatalf("Write(fileInodeNumber, 0, ...) failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush(fileInodeNumber, false) failed: %v", err)
+	}
+
+	fileInode, err := volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumber, FileType) failed: %v", err)
+	}
+	if 1 != len(fileInode.LogSegmentMap) {
+		t.Fatalf("Write() followed by Flush() should have used a single LogSegment")
+	}
+
+	// Split the extent in two by re-recording its front half... yet both remain contiguous in a single LogSegment
+
+	_, extentValue, ok, err := fileInode.payload.(sortedmap.BPlusTree).GetByIndex(0)
+	if (nil != err) || !ok {
+		t.Fatalf("GetByIndex(0) failed [ok: %v, err: %v]", ok, err)
+	}
+	fileExtent := extentValue.(*fileExtentStruct)
+	err = recordWrite(fileInode, 0, 1024, fileExtent.LogSegmentNumber, fileExtent.LogSegmentOffset)
+	if nil != err {
+		t.Fatalf("recordWrite(fileInode, 0, 1024,,) failed: %v", err)
+	}
+
+	logSegmentMap := make(map[uint64]uint64)
+	for logSegmentNumber, logSegmentBytesUsed := range fileInode.LogSegmentMap {
+		logSegmentMap[logSegmentNumber] = logSegmentBytesUsed
+	}
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if (nil != err) || (2 != fragmentationReport.NumberOfFragments) {
+		t.Fatalf("GetFragmentationReport() returned unexpected %+v [err: %v]", fragmentationReport, err)
+	}
+
+	bytesOptimized, err := testVolumeHandle.Optimize(fileInodeNumber, time.Minute)
+	if nil != err {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) failed: %v", err)
+	}
+	if 0 != bytesOptimized {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) returned unexpected bytesOptimized: %v", bytesOptimized)
+	}
+
+	for logSegmentNumber := range logSegmentMap {
+		if _, ok := fileInode.LogSegmentMap[logSegmentNumber]; !ok || (1 != len(fileInode.LogSegmentMap)) {
+			t.Fatalf("Optimize() should not have rewritten extents already contiguous in a single LogSegment")
+		}
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumber) failed: %v", err)
+	}
+}
+
+func TestOptimizeContiguousCompressed(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	volume.compressChunks = true
+	defer func() { volume.compressChunks = false }()
+
+	fileInodeNumber, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	// Each compressed chunk is in its own extent... yet they are contiguous in a single LogSegment
+
+	for i := uint64(0); i < 4; i++ {
+		err = testVolumeHandle.Write(fileInodeNumber, i*1024, bytes.Repeat([]byte{byte('A' + i)}, 1024), nil)
+		if nil != err {
+			t.Fatalf("Write(fileInodeNumber, %d, ...) failed: %v", i*1024, err)
+		}
+	}
+	err = testVolumeHandle.Flush(fileInodeNumber, false)
+	if nil != err {
+		t.Fatalf("Flush(fileInodeNumber, false) failed: %v", err)
+	}
+
+	fileInode, err := volume.fetchInodeType(fileInodeNumber, FileType)
+	if nil != err {
+		t.Fatalf("fetchInodeType(fileInodeNumber, FileType) failed: %v", err)
+	}
+	if 1 != len(fileInode.LogSegmentMap) {
+		t.Fatalf("Write()s followed by a single Flush() should have used a single LogSegment")
+	}
+	logSegmentMap := make(map[uint64]uint64)
+	for logSegmentNumber, logSegmentBytesUsed := range fileInode.LogSegmentMap {
+		logSegmentMap[logSegmentNumber] = logSegmentBytesUsed
+	}
+
+	fragmentationReport, err := testVolumeHandle.GetFragmentationReport(fileInodeNumber)
+	if (nil != err) || (4 != fragmentationReport.NumberOfFragments) {
+		t.Fatalf("GetFragmentationReport() returned unexpected %+v [err: %v]", fragmentationReport, err)
+	}
+
+	bytesOptimized, err := testVolumeHandle.Optimize(fileInodeNumber, time.Minute)
+	if nil != err {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) failed: %v", err)
+	}
+	if 0 != bytesOptimized {
+		t.Fatalf("Optimize(fileInodeNumber, time.Minute) returned unexpected bytesOptimized: %v", bytesOptimized)
+	}
+
+	for logSegmentNumber := range logSegmentMap {
+		if _, ok := fileInode.LogSegmentMap[logSegmentNumber]; !ok || (1 != len(fileInode.LogSegmentMap)) {
+			t.Fatalf("Optimize() should not have rewritten extents already contiguous in a single LogSegment")
+		}
+	}
+
+	err = testVolumeHandle.Destroy(fileInodeNumber)
+	if nil != err {
+		t.Fatalf("Destroy(fileInodeNumber) failed: %v", err)
+	}
+}
@@ -17,6 +17,15 @@ import (
 )
 
 type fileExtentStruct struct {
+	FileOffset       uint64
+	Length           uint64
+	LogSegmentNumber uint64
+	LogSegmentOffset uint64 // If FrameLength != 0, relative to the decompressed content of the frame
+	FrameOffset      uint64 // If FrameLength != 0, LogSegment offset of the compressed frame holding the extent's data
+	FrameLength      uint64 // If == 0, the extent's data is not compressed
+}
+
+type fileExtentV1Struct struct { // Packed form of fileExtentStruct for FileInodes lacking FramedExtents
 	FileOffset       uint64
 	Length           uint64
 	LogSegmentNumber uint64
@@ -330,7 +339,9 @@ func (vS *volumeStruct) getReadPlanHelper(fileInode *inMemoryInodeStruct, reques
 				LogSegmentNumber: curExtent.LogSegmentNumber,
 				Offset:           curExtent.LogSegmentOffset + skipSize,
 				Length:           terminalOffset - curOffset,
-				AccountName:      vS.accountName,
+				AccountName:      vS.headhunterVolumeHandle.FetchLogSegmentAccountName(curExtent.LogSegmentNumber),
+				FrameOffset:      curExtent.FrameOffset,
+				FrameLength:      curExtent.FrameLength,
 			}
 			step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
 			if nil != err {
@@ -345,7 +356,9 @@ func (vS *volumeStruct) getReadPlanHelper(fileInode *inMemoryInodeStruct, reques
 				LogSegmentNumber: curExtent.LogSegmentNumber,
 				Offset:           curExtent.LogSegmentOffset + skipSize,
 				Length:           curExtent.Length - skipSize,
-				AccountName:      vS.accountName,
+				AccountName:      vS.headhunterVolumeHandle.FetchLogSegmentAccountName(curExtent.LogSegmentNumber),
+				FrameOffset:      curExtent.FrameOffset,
+				FrameLength:      curExtent.FrameLength,
 			}
 			step.ContainerName, step.ObjectName, step.ObjectPath, err = vS.getObjectLocationFromLogSegmentNumber(step.LogSegmentNumber)
 			if nil != err {
@@ -403,13 +416,11 @@ func decrementLogSegmentMapFileData(fileInode *inMemoryInodeStruct, logSegmentNu
 	}
 }
 
-// `recordWrite` is called by `Write` and `Wrote` to update the file inode
-// payload's record of the extents that compose the file.
-func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint64, logSegmentNumber uint64, logSegmentOffset uint64) (err error) {
+// `punchHole` eliminates extents or portions thereof that overlap the specified range
+// of the file inode's payload (leaving that range sparse).
+func punchHole(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint64) {
 	extents := fileInode.payload.(sortedmap.BPlusTree)
 
-	// First we need to eliminate extents or portions thereof that overlap the specified write
-
 	extentIndex, found, err := extents.BisectLeft(fileOffset)
 	if nil != err {
 		panic(err)
@@ -444,6 +455,8 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 					Length:           splitOutSize,
 					LogSegmentNumber: leftExtent.LogSegmentNumber,
 					LogSegmentOffset: leftExtent.LogSegmentOffset + leftExtent.Length,
+					FrameOffset:      leftExtent.FrameOffset,
+					FrameLength:      leftExtent.FrameLength,
 				}
 				ok, putErr := extents.Put(rightExtent.FileOffset, rightExtent)
 				if nil != putErr {
@@ -500,6 +513,8 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 				Length:           leftExtent.Length - overlapSize,
 				LogSegmentNumber: leftExtent.LogSegmentNumber,
 				LogSegmentOffset: leftExtent.LogSegmentOffset + overlapSize,
+				FrameOffset:      leftExtent.FrameOffset,
+				FrameLength:      leftExtent.FrameLength,
 			}
 			ok, putErr := extents.Put(rightExtent.FileOffset, rightExtent)
 			if nil != putErr {
@@ -513,6 +528,30 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 			break
 		}
 	}
+}
+
+// `recordWrite` is called by `Write` and `Wrote` to update the file inode
+// payload's record of the extents that compose the file.
+func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint64, logSegmentNumber uint64, logSegmentOffset uint64) (err error) {
+	err = recordFramedWrite(fileInode, fileOffset, length, logSegmentNumber, logSegmentOffset, 0, 0)
+	return
+}
+
+// `recordFramedWrite` is like `recordWrite` but for data that may reside in a compressed
+// frame of the LogSegment (i.e. if frameLength != 0).
+func recordFramedWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint64, logSegmentNumber uint64, logSegmentOffset uint64, frameOffset uint64, frameLength uint64) (err error) {
+	extents := fileInode.payload.(sortedmap.BPlusTree)
+
+	if (0 != frameLength) && !fileInode.FramedExtents {
+		err = enableFramedExtents(fileInode)
+		if nil != err {
+			return
+		}
+	}
+
+	// First we need to eliminate extents or portions thereof that overlap the specified write
+
+	punchHole(fileInode, fileOffset, length)
 
 	// Now that there will be no overlap, see if we can append to the preceding fileExtent
 
@@ -542,7 +581,7 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 		prevExtent = prevExtentValue.(*fileExtentStruct)
 	}
 
-	if (nil != prevExtent) && (prevExtent.LogSegmentNumber == logSegmentNumber) && ((prevExtent.FileOffset + prevExtent.Length) == fileOffset) && ((prevExtent.LogSegmentOffset + prevExtent.Length) == logSegmentOffset) {
+	if (nil != prevExtent) && (prevExtent.LogSegmentNumber == logSegmentNumber) && ((prevExtent.FileOffset + prevExtent.Length) == fileOffset) && ((prevExtent.LogSegmentOffset + prevExtent.Length) == logSegmentOffset) && (prevExtent.FrameOffset == frameOffset) && (prevExtent.FrameLength == frameLength) {
 		// APPEND Case: We are able to simply lengthen prevExtent
 
 		prevExtent.Length += length
@@ -562,6 +601,8 @@ func recordWrite(fileInode *inMemoryInodeStruct, fileOffset uint64, length uint6
 			Length:           length,
 			LogSegmentNumber: logSegmentNumber,
 			LogSegmentOffset: logSegmentOffset,
+			FrameOffset:      frameOffset,
+			FrameLength:      frameLength,
 		}
 
 		ok, putErr := extents.Put(newExtent.FileOffset, newExtent)
@@ -593,18 +634,35 @@ func (vS *volumeStruct) Write(fileInodeNumber InodeNumber, offset uint64, buf []
 
 	fileInode.dirty = true
 
-	logSegmentNumber, logSegmentOffset, err := vS.doSendChunk(fileInode, buf)
-	if nil != err {
-		logger.ErrorWithError(err)
-		return
-	}
-
 	length := uint64(len(buf))
 
-	err = recordWrite(fileInode, offset, length, logSegmentNumber, logSegmentOffset)
-	if nil != err {
-		logger.ErrorWithError(err)
-		return
+	// If deduplicating, split buf at DedupChunkSize boundaries so that aligned chunks may be found (see dedup.go)
+
+	chunkOffset := uint64(0)
+
+	for chunkOffset < length {
+		chunkLength := length - chunkOffset
+		if 0 != vS.dedupChunkSize {
+			chunkRemaining := vS.dedupChunkSize - ((offset + chunkOffset) % vS.dedupChunkSize)
+			if chunkLength > chunkRemaining {
+				chunkLength = chunkRemaining
+			}
+		}
+
+		logSegmentNumber, logSegmentOffset, frameOffset, frameLength, sendErr := vS.doSendChunk(fileInode, buf[chunkOffset:chunkOffset+chunkLength])
+		if nil != sendErr {
+			err = sendErr
+			logger.ErrorWithError(err)
+			return
+		}
+
+		err = recordFramedWrite(fileInode, offset+chunkOffset, chunkLength, logSegmentNumber, logSegmentOffset, frameOffset, frameLength)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+
+		chunkOffset += chunkLength
 	}
 
 	startingSize := fileInode.Size
@@ -854,7 +912,7 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 			// For normal steps, steal the log segment. For sparse steps (zero-fill in sparse files), just increment the
 			// file size.
 			if step.LogSegmentNumber != 0 {
-				err = recordWrite(combinationInode, sumOfElementSizes+fileOffset, step.Length, step.LogSegmentNumber, step.Offset)
+				err = recordFramedWrite(combinationInode, sumOfElementSizes+fileOffset, step.Length, step.LogSegmentNumber, step.Offset, step.FrameOffset, step.FrameLength)
 				combinationInode.NumWrites++
 				if err != nil {
 					return
@@ -963,6 +1021,97 @@ func (vS *volumeStruct) Coalesce(containingDirInodeNumber InodeNumber, combinati
 	return
 }
 
+// CloneRange makes length bytes of dstInodeNumber starting at dstOffset reference the same LogSegment data as
+// srcInodeNumber starting at srcOffset (i.e. a "reflink"). The range is truncated at the end of srcInodeNumber
+// and any sparse portions of it are punched out of dstInodeNumber. Rather than copying any data, the extents are
+// simply recorded in dstInodeNumber (adding to its LogSegmentMap). Each LogSegment dstInodeNumber did not already
+// reference is recorded as shared (see accounting.go) so that it is not deleted while any FileInode references it.
+func (vS *volumeStruct) CloneRange(srcInodeNumber InodeNumber, srcOffset uint64, dstInodeNumber InodeNumber, dstOffset uint64, length uint64) (clonedLength uint64, err error) {
+	srcInode, err := vS.fetchInodeType(srcInodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+	dstInode, err := vS.fetchInodeType(dstInodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	if srcOffset >= srcInode.Size {
+		clonedLength = 0
+		err = nil
+		return
+	}
+	if length > (srcInode.Size - srcOffset) {
+		length = srcInode.Size - srcOffset
+	}
+	if 0 == length {
+		clonedLength = 0
+		err = nil
+		return
+	}
+
+	// Sharing of LogSegments must be recorded... so fail now (rather than part way through) if it cannot be
+
+	err = vS.accountingLoad()
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: accounting of volume '%s' not available", utils.GetFnName(), vS.volumeName)
+		return
+	}
+
+	// NB: we rely on the fact that GetReadPlan causes a flush of any pending writes to disk (as does Coalesce)
+
+	readPlanOffset := srcOffset
+	readPlanLength := length
+
+	readPlanSteps, err := vS.GetReadPlan(srcInodeNumber, &readPlanOffset, &readPlanLength)
+	if nil != err {
+		return
+	}
+
+	dstInode.dirty = true
+
+	fileOffset := dstOffset
+	for _, step := range readPlanSteps {
+		if 0 == step.LogSegmentNumber {
+			punchHole(dstInode, fileOffset, step.Length)
+		} else {
+			_, alreadyReferenced := dstInode.LogSegmentMap[step.LogSegmentNumber]
+			err = recordFramedWrite(dstInode, fileOffset, step.Length, step.LogSegmentNumber, step.Offset, step.FrameOffset, step.FrameLength)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+			if !alreadyReferenced {
+				vS.shareLogSegment(step.LogSegmentNumber)
+			}
+		}
+		fileOffset += step.Length
+	}
+
+	clonedLength = fileOffset - dstOffset
+
+	if fileOffset > dstInode.Size {
+		dstInode.Size = fileOffset
+	}
+
+	updateTime := time.Now()
+	dstInode.AttrChangeTime = updateTime
+	dstInode.ModificationTime = updateTime
+	dstInode.NumWrites++
+
+	err = vS.flushInode(dstInode)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	stats.IncrementOperations(&stats.FileCloneRangeOps)
+
+	return
+}
+
 func (vS *volumeStruct) getLogSegmentContainer(logSegmentNumber uint64) (containerName string, err error) {
 	containerNameAsByteSlice, err := vS.headhunterVolumeHandle.GetLogSegmentRec(logSegmentNumber)
 	if nil != err {
@@ -998,11 +1147,18 @@ func (vS *volumeStruct) getObjectPathFromLogSegmentNumber(logSegmentNumber uint6
 }
 
 func (vS *volumeStruct) getObjectPathFromContainerNameAndLogSegmentNumber(containerName string, logSegmentNumber uint64) (objectPath string) {
-	objectPath = fmt.Sprintf("/v1/%s/%s/%016X", vS.accountName, containerName, logSegmentNumber)
+	objectPath = fmt.Sprintf("/v1/%s/%s/%016X", vS.headhunterVolumeHandle.FetchLogSegmentAccountName(logSegmentNumber), containerName, logSegmentNumber)
 	return
 }
 
 func (vS *volumeStruct) deleteLogSegmentAsync(logSegmentNumber uint64, checkpointDoneWaitGroup *sync.WaitGroup) (err error) {
+	vS.Lock()
+	deferred := vS.deferLogSegmentDeleteIfLeasedWhileLocked(logSegmentNumber)
+	vS.Unlock()
+	if deferred {
+		// Deletion will be performed once the last lease on logSegmentNumber is released (or expires)
+		return
+	}
 	containerName, err := vS.getLogSegmentContainer(logSegmentNumber)
 	if nil != err {
 		return
@@ -1012,6 +1168,18 @@ func (vS *volumeStruct) deleteLogSegmentAsync(logSegmentNumber uint64, checkpoin
 	if nil != err {
 		return
 	}
+	vS.dedupForgetLogSegment(logSegmentNumber)
+	vS.accountingMutex.Lock()
+	delete(vS.unreferencedLogSegmentSet, logSegmentNumber)
+	vS.accountingMutex.Unlock()
+	if vS.headhunterVolumeHandle.SnapshotPinsLogSegment(logSegmentNumber) {
+		// Deletion will be performed once the last snapshot referencing logSegmentNumber is deleted
+		return
+	}
+	if vS.headhunterVolumeHandle.FetchLogSegmentAccountName(logSegmentNumber) != vS.accountName {
+		// LogSegment is shared with (and will ultimately be deleted by) the volume this one was cloned from
+		return
+	}
 	swiftclient.ObjectDeleteAsync(vS.accountName, containerName, objectName, checkpointDoneWaitGroup, nil)
 	return
 }
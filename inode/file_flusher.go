@@ -1,6 +1,7 @@
 package inode
 
 import (
+	"crypto/sha256"
 	"fmt"
 
 	"github.com/swiftstack/ProxyFS/blunder"
@@ -55,6 +56,7 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 		cacheLineStartOffset uint64
 		chunkOffset          uint64
 		flowControl          *flowControlStruct
+		frameStepBuf         []byte
 		inFlightHit          bool
 		inFlightHitBuf       []byte
 		inFlightLogSegment   *inFlightLogSegmentStruct
@@ -71,7 +73,7 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 	readCacheLineSize = flowControl.readCacheLineSize
 	readCacheKey.volumeName = vS.volumeName
 
-	if 1 == len(readPlan) {
+	if (1 == len(readPlan)) && (0 == readPlan[0].FrameLength) {
 		// Possibly a trivial case (allowing for a potential zero-copy return)... three exist:
 		//   Case 1: The lone step calls for a zero-filled []byte
 		//   Case 2: The lone step is satisfied by reading from an inFlightLogSegment
@@ -171,6 +173,13 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 		if 0 == step.LogSegmentNumber {
 			// The step calls for a zero-filled []byte
 			buf = append(buf, make([]byte, step.Length)...)
+		} else if 0 != step.FrameLength {
+			// The step is satisfied by the decompressed content of a compressed frame
+			frameStepBuf, err = vS.doFramedReadPlanStep(fileInode, step)
+			if nil != err {
+				return
+			}
+			buf = append(buf, frameStepBuf...)
 		} else {
 			fileInode.Lock()
 			inFlightLogSegment, inFlightHit = fileInode.inFlightLogSegmentMap[step.LogSegmentNumber]
@@ -257,12 +266,40 @@ func (vS *volumeStruct) doReadPlan(fileInode *inMemoryInodeStruct, readPlan []Re
 	return
 }
 
-func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte) (logSegmentNumber uint64, logSegmentOffset uint64, err error) {
+// doSendChunk appends buf to fileInode's open LogSegment (or, if deduplicating, finds it already in some
+// LogSegment). If frameLength != 0, buf was sent as the compressed frame at [frameOffset:frameOffset+frameLength)
+// of the LogSegment and logSegmentOffset is relative to its decompressed content.
+func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte) (logSegmentNumber uint64, logSegmentOffset uint64, frameOffset uint64, frameLength uint64, err error) {
 	var (
+		compressed                  bool
+		dedupDigest                 [sha256.Size]byte
+		dedupFingerprint            uint64
+		dedupFound                  bool
+		dedupLookedUp               bool
 		openLogSegmentContainerName string
 		openLogSegmentObjectNumber  uint64
+		sendBuf                     []byte
+		sendOffset                  uint64
 	)
 
+	if (0 != fileInode.volume.dedupChunkSize) && (uint64(len(buf)) == fileInode.volume.dedupChunkSize) {
+		dedupFingerprint, dedupDigest = dedupFingerprintOf(buf)
+		logSegmentNumber, logSegmentOffset, frameOffset, frameLength, dedupFound = fileInode.volume.dedupLookup(fileInode, dedupFingerprint, dedupDigest, uint64(len(buf)))
+		if dedupFound {
+			stats.IncrementOperations(&stats.FileDedupHitOps)
+			err = nil
+			return
+		}
+		stats.IncrementOperations(&stats.FileDedupMissOps)
+		dedupLookedUp = true
+	}
+
+	sendBuf = buf
+
+	if fileInode.volume.compressChunks {
+		sendBuf, compressed = compressChunk(buf)
+	}
+
 	fileInode.Lock()
 	defer fileInode.Unlock()
 
@@ -298,19 +335,36 @@ func (vS *volumeStruct) doSendChunk(fileInode *inMemoryInodeStruct, buf []byte)
 
 	logSegmentNumber = fileInode.openLogSegment.logSegmentNumber
 
-	logSegmentOffset, err = fileInode.openLogSegment.BytesPut()
+	sendOffset, err = fileInode.openLogSegment.BytesPut()
 	if nil != err {
 		logger.ErrorfWithError(err, "Failed to get current LogSegmentOffset")
 		return
 	}
 
-	err = fileInode.openLogSegment.ChunkedPutContext.SendChunk(buf)
+	err = fileInode.openLogSegment.ChunkedPutContext.SendChunk(sendBuf)
 	if nil != err {
 		logger.ErrorfWithError(err, "Sending Chunked PUT chunk to LogSegment failed")
 		return
 	}
 
-	if (logSegmentOffset + uint64(len(buf))) >= fileInode.volume.flowControl.maxFlushSize {
+	if compressed {
+		logSegmentOffset = 0
+		frameOffset = sendOffset
+		frameLength = uint64(len(sendBuf))
+	} else {
+		logSegmentOffset = sendOffset
+		frameOffset = 0
+		frameLength = 0
+	}
+
+	if dedupLookedUp {
+		if nil == fileInode.openLogSegment.fingerprintRecs {
+			fileInode.openLogSegment.fingerprintRecs = make(map[uint64][]byte)
+		}
+		fileInode.openLogSegment.fingerprintRecs[dedupFingerprint] = packFingerprintRec(dedupDigest, logSegmentNumber, logSegmentOffset, frameOffset, frameLength, uint64(len(buf)))
+	}
+
+	if (sendOffset + uint64(len(sendBuf))) >= fileInode.volume.flowControl.maxFlushSize {
 		fileInode.Add(1)
 		go inFlightLogSegmentFlusher(fileInode.openLogSegment)
 		fileInode.openLogSegment = nil
@@ -363,6 +417,9 @@ func inFlightLogSegmentFlusher(inFlightLogSegment *inFlightLogSegmentStruct) {
 		return
 	}
 
+	// Now that the LogSegment is durable, its chunks may be found by dedupLookup()
+	inFlightLogSegment.fileInode.volume.dedupRecordLogSegment(inFlightLogSegment.logSegmentNumber, inFlightLogSegment.fingerprintRecs)
+
 	// Remove us from inFlightLogSegments.logSegmentsMap and let Go's Garbage Collector collect us as soon as we return/exit
 	inFlightLogSegment.fileInode.Lock()
 	delete(inFlightLogSegment.fileInode.inFlightLogSegmentMap, inFlightLogSegment.logSegmentNumber)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/swiftstack/ProxyFS/blunder"
 	"github.com/swiftstack/ProxyFS/evtlog"
 	"github.com/swiftstack/ProxyFS/halter"
+	"github.com/swiftstack/ProxyFS/headhunter"
 	"github.com/swiftstack/ProxyFS/logger"
 	"github.com/swiftstack/ProxyFS/stats"
 	"github.com/swiftstack/ProxyFS/swiftclient"
@@ -54,6 +56,8 @@ type onDiskInodeV1Struct struct { // Preceded "on disk" by CorruptionDetected th
 	PayloadObjectLength uint64            // FileInode:    B+Tree Root with Key == fileOffset, Value = fileExtent
 	SymlinkTarget       string            // SymlinkInode: target path of symbolic link
 	LogSegmentMap       map[uint64]uint64 // FileInode:    Key == LogSegment#, Value = file user data byte count
+	QuotaTree           InodeNumber       `json:",omitempty"` // DirInode of the quota tree containing this inode (if any)
+	FramedExtents       bool              `json:",omitempty"` // FileInode:    if true, extents may refer to compressed frames (see compress.go)
 }
 
 type inFlightLogSegmentStruct struct { // Used as (by reference) Value for inMemoryInodeStruct.inFlightLogSegmentMap
@@ -63,6 +67,7 @@ type inFlightLogSegmentStruct struct { // Used as (by reference) Value for inMem
 	containerName    string
 	objectName       string
 	swiftclient.ChunkedPutContext
+	fingerprintRecs map[uint64][]byte // Key == fingerprint; Value == fingerprintRec to put once LogSegment is durable (see dedup.go)
 }
 
 type inMemoryInodeStruct struct {
@@ -75,33 +80,20 @@ type inMemoryInodeStruct struct {
 	openLogSegment           *inFlightLogSegmentStruct            // FileInode only... also in inFlightLogSegmentMap
 	inFlightLogSegmentMap    map[uint64]*inFlightLogSegmentStruct // FileInode: key == logSegmentNumber
 	inFlightLogSegmentErrors map[uint64]error                     // FileInode: key == logSegmentNumber; value == err (if non nil)
+	onDisk                   bool                                 // Set once an inodeRec has been put (see accounting.go)
+	onDiskCharge             accountingChargeStruct               // What the last put inodeRec contributed to the volume's accounting
 	onDiskInodeV1Struct                                           // Real on-disk inode information embedded here
 }
 
-func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode *inMemoryInodeStruct, ok bool, err error) {
+// unpackInodeRecPreamble validates the preamble of the inodeRec fetched for inodeNumber returning what follows it
+func unpackInodeRecPreamble(inodeNumber InodeNumber, inodeRec []byte) (inodeRecBody []byte, err error) {
 	var (
 		bytesConsumedByCorruptionDetected uint64
 		bytesConsumedByVersion            uint64
 		corruptionDetected                CorruptionDetected
-		inodeRec                          []byte
-		onDiskInodeV1                     *onDiskInodeV1Struct
 		version                           Version
 	)
 
-	logger.Tracef("inode.fetchOnDiskInode(): volume '%s' inode %d", vS.volumeName, inodeNumber)
-
-	inodeRec, ok, err = vS.headhunterVolumeHandle.GetInodeRec(uint64(inodeNumber))
-	if nil != err {
-		stackStr := string(debug.Stack())
-		err = fmt.Errorf("%s: unable to get inodeRec for inode %d: %v stack: %s",
-			utils.GetFnName(), inodeNumber, err, stackStr)
-		err = blunder.AddError(err, blunder.NotFoundError)
-		return
-	}
-	if !ok {
-		return
-	}
-
 	bytesConsumedByCorruptionDetected, err = cstruct.Unpack(inodeRec, &corruptionDetected, cstruct.LittleEndian)
 	if nil != err {
 		err = fmt.Errorf("%s: unable to parse inodeRec.CorruptionDetected for inode %d: %v", utils.GetFnName(), inodeNumber, err)
@@ -126,21 +118,73 @@ func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode
 		return
 	}
 
+	inodeRecBody = inodeRec[bytesConsumedByCorruptionDetected+bytesConsumedByVersion:]
+
+	err = nil
+	return
+}
+
+// unpackInodeRec decodes the inodeRec fetched for inodeNumber
+func unpackInodeRec(inodeNumber InodeNumber, inodeRec []byte) (onDiskInodeV1 *onDiskInodeV1Struct, err error) {
+	inodeRecBody, err := unpackInodeRecPreamble(inodeNumber, inodeRec)
+	if nil != err {
+		return
+	}
+
 	onDiskInodeV1 = &onDiskInodeV1Struct{StreamMap: make(map[string][]byte)}
 
-	err = json.Unmarshal(inodeRec[bytesConsumedByCorruptionDetected+bytesConsumedByVersion:], onDiskInodeV1)
+	err = json.Unmarshal(inodeRecBody, onDiskInodeV1)
 	if nil != err {
 		err = fmt.Errorf("%s: inodeRec.<body> for inode %d json.Unmarshal() failed: %v", utils.GetFnName(), inodeNumber, err)
 		err = blunder.AddError(err, blunder.CorruptInodeError)
 		return
 	}
 
+	err = nil
+	return
+}
+
+func (vS *volumeStruct) fetchOnDiskInode(inodeNumber InodeNumber) (inMemoryInode *inMemoryInodeStruct, ok bool, err error) {
+	var (
+		inodeRec      []byte
+		onDiskInodeV1 *onDiskInodeV1Struct
+	)
+
+	logger.Tracef("inode.fetchOnDiskInode(): volume '%s' inode %d", vS.volumeName, inodeNumber)
+
+	if (accountingInodeNumber == inodeNumber) || (accountingKeyBase <= uint64(inodeNumber)) {
+		// The inodeRec so keyed holds (part of) the volume's accounting (see accounting.go)
+		ok = false
+		err = fmt.Errorf("%s: inode %d is reserved", utils.GetFnName(), inodeNumber)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	inodeRec, ok, err = vS.headhunterVolumeHandle.GetInodeRec(uint64(inodeNumber))
+	if nil != err {
+		stackStr := string(debug.Stack())
+		err = fmt.Errorf("%s: unable to get inodeRec for inode %d: %v stack: %s",
+			utils.GetFnName(), inodeNumber, err, stackStr)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	onDiskInodeV1, err = unpackInodeRec(inodeNumber, inodeRec)
+	if nil != err {
+		return
+	}
+
 	inMemoryInode = &inMemoryInodeStruct{
 		dirty:                    true,
 		volume:                   vS,
 		openLogSegment:           nil,
 		inFlightLogSegmentMap:    make(map[uint64]*inFlightLogSegmentStruct),
 		inFlightLogSegmentErrors: make(map[uint64]error),
+		onDisk:                   true,
+		onDiskCharge:             onDiskInodeV1.accountingCharge(),
 		onDiskInodeV1Struct:      *onDiskInodeV1,
 	}
 
@@ -350,10 +394,12 @@ func (vS *volumeStruct) flushInodeNumber(inodeNumber InodeNumber) (err error) {
 
 func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 	var (
+		accountingDelta           *accountingDeltaStruct
 		checkpointDoneWaitGroup   *sync.WaitGroup
 		dirtyInodeNumbers         []uint64
 		dirtyInodeRecBytes        []byte
 		dirtyInodeRecs            [][]byte
+		dirtyInodes               []*inMemoryInodeStruct
 		emptyLogSegments          []uint64
 		emptyLogSegmentsThisInode []uint64
 		inode                     *inMemoryInodeStruct
@@ -380,6 +426,7 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 	// Assemble slice of "dirty" inodes while flushing them
 	dirtyInodeNumbers = make([]uint64, 0, len(inodes))
 	dirtyInodeRecs = make([][]byte, 0, len(inodes))
+	dirtyInodes = make([]*inMemoryInodeStruct, 0, len(inodes))
 	emptyLogSegments = make([]uint64, 0)
 
 	for _, inode = range inodes {
@@ -456,12 +503,22 @@ func (vS *volumeStruct) flushInodes(inodes []*inMemoryInodeStruct) (err error) {
 			dirtyInodeRecBytes = append(dirtyInodeRecBytes, onDiskInodeV1Buf...)
 			dirtyInodeNumbers = append(dirtyInodeNumbers, uint64(inode.InodeNumber))
 			dirtyInodeRecs = append(dirtyInodeRecs, dirtyInodeRecBytes)
+			dirtyInodes = append(dirtyInodes, inode)
 		}
 	}
 
+	// LogSegments still referenced by other FileInodes (see CloneRange()) must not be deleted
+	emptyLogSegments = vS.unshareLogSegments(emptyLogSegments)
+
 	// Go update HeadHunter (if necessary)
 	if 0 < len(dirtyInodeNumbers) {
+		// Any change in the volume's accounting is put along with the inodeRecs that caused it
+
+		accountingDelta = vS.accountingPrepare(dirtyInodes)
+		dirtyInodeNumbers = append(dirtyInodeNumbers, accountingDelta.accountingKeys...)
+		dirtyInodeRecs = append(dirtyInodeRecs, accountingDelta.accountingRecs...)
 		err = vS.headhunterVolumeHandle.PutInodeRecs(dirtyInodeNumbers, dirtyInodeRecs)
+		vS.accountingComplete(accountingDelta, dirtyInodes, nil == err)
 		if nil != err {
 			evtlog.Record(evtlog.FormatFlushInodesErrorOnHeadhunterPut, vS.volumeName, err.Error())
 			logger.ErrorWithError(err)
@@ -561,6 +618,64 @@ func volumeNameToActivePeerPrivateIPAddr(volumeName string) (activePeerPrivateIP
 	return
 }
 
+func fetchSnapshotVolumeHandle(volumeName string, snapshotName string) (volumeHandle VolumeHandle, err error) {
+	var (
+		headhunterSnapshotVolumeHandle headhunter.VolumeHandle
+		snapshotVolume                 *volumeStruct
+	)
+
+	globals.Lock()
+	volume, ok := globals.volumeMap[volumeName]
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("%s: volumeName \"%v\" not found", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	volume.Lock()
+	defer volume.Unlock()
+
+	if !volume.active {
+		err = fmt.Errorf("%s: volumeName \"%v\" not active", utils.GetFnName(), volumeName)
+		err = blunder.AddError(err, blunder.NotActiveError)
+		return
+	}
+
+	headhunterSnapshotVolumeHandle, err = volume.headhunterVolumeHandle.FetchSnapshotVolumeHandle(snapshotName)
+	if nil != err {
+		return
+	}
+
+	// The snapshot shares the (unchanging) configuration of its volume but has its own inode cache
+
+	snapshotVolume = &volumeStruct{
+		fsid:                           volume.fsid,
+		volumeName:                     volume.volumeName,
+		accountName:                    volume.accountName,
+		active:                         volume.active,
+		activePeerPrivateIPAddr:        volume.activePeerPrivateIPAddr,
+		maxEntriesPerDirNode:           volume.maxEntriesPerDirNode,
+		maxExtentsPerFileNode:          volume.maxExtentsPerFileNode,
+		physicalContainerLayoutSet:     volume.physicalContainerLayoutSet,
+		physicalContainerNamePrefixSet: volume.physicalContainerNamePrefixSet,
+		physicalContainerLayoutMap:     volume.physicalContainerLayoutMap,
+		defaultPhysicalContainerLayout: volume.defaultPhysicalContainerLayout,
+		flowControl:                    volume.flowControl,
+		headhunterVolumeHandle:         headhunterSnapshotVolumeHandle,
+		inodeCache:                     make(map[InodeNumber]*inMemoryInodeStruct),
+		leasedLogSegmentMap:            make(map[uint64]uint64),
+		deferredLogSegmentDeleteSet:    make(map[uint64]struct{}),
+		unreferencedLogSegmentSet:      make(map[uint64]struct{}),
+	}
+
+	volumeHandle = snapshotVolume
+
+	err = nil
+	return
+}
+
 func fetchVolumeHandle(volumeName string) (volumeHandle VolumeHandle, err error) {
 	globals.Lock()
 	volume, ok := globals.volumeMap[volumeName]
@@ -753,6 +868,19 @@ func (vS *volumeStruct) Access(inodeNumber InodeNumber, userID InodeUserID, grou
 		return
 	}
 
+	// An access ACL (if present) is consulted for all other callers
+
+	aclBuf, ok := ourInode.StreamMap[AccessACLStreamName]
+	if ok {
+		aclEntries, aclErr := UnpackACL(aclBuf)
+		if nil == aclErr {
+			accessReturn = aclAccess(ourInode, aclEntries, userID, groupID, otherGroupIDs, accessMode)
+			return
+		}
+		logger.ErrorfWithError(aclErr, "%s: access ACL of inode %d volume '%s' is corrupt... ignoring it",
+			utils.GetFnName(), inodeNumber, vS.volumeName)
+	}
+
 	groupIDCheck := (groupID == ourInode.GroupID)
 	if !groupIDCheck {
 		for _, otherGroupID := range otherGroupIDs {
@@ -835,7 +963,7 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 		_ = vS.doFileInodeDataFlush(ourInode)
 	}
 
-	err = vS.headhunterVolumeHandle.DeleteInodeRec(uint64(inodeNumber))
+	err = vS.destroyInodeRec(ourInode)
 	if nil != err {
 		logger.ErrorWithError(err)
 		return
@@ -862,7 +990,14 @@ func (vS *volumeStruct) Destroy(inodeNumber InodeNumber) (err error) {
 
 		checkpointDoneWaitGroup := vS.headhunterVolumeHandle.FetchNextCheckPointDoneWaitGroup()
 
+		logSegmentNumbers := make([]uint64, 0, len(ourInode.LogSegmentMap))
 		for logSegmentNumber := range ourInode.LogSegmentMap {
+			logSegmentNumbers = append(logSegmentNumbers, logSegmentNumber)
+		}
+
+		// LogSegments still referenced by other FileInodes (see CloneRange()) must not be deleted
+
+		for _, logSegmentNumber := range vS.unshareLogSegments(logSegmentNumbers) {
 			deleteSegmentErr := vS.deleteLogSegmentAsync(logSegmentNumber, checkpointDoneWaitGroup)
 			if nil != deleteSegmentErr {
 				logger.WarnfWithError(deleteSegmentErr, "couldn't delete destroy'd log segment")
@@ -911,6 +1046,7 @@ func (vS *volumeStruct) GetMetadata(inodeNumber InodeNumber) (metadata *Metadata
 		Mode:                 inode.Mode,
 		UserID:               inode.UserID,
 		GroupID:              inode.GroupID,
+		QuotaTree:            inode.QuotaTree,
 	}
 
 	pos := 0
@@ -1164,6 +1300,21 @@ func (vS *volumeStruct) SetPermMode(inodeNumber InodeNumber, filePerm InodeMode)
 	inode.dirty = true
 	inode.Mode = fileMode
 
+	// Keep any access ACL in sync with the new permission bits
+
+	aclBuf, ok := inode.StreamMap[AccessACLStreamName]
+	if ok {
+		aclEntries, aclErr := UnpackACL(aclBuf)
+		if nil == aclErr {
+			aclApplyPermMode(aclEntries, fileMode)
+			inode.StreamMap[AccessACLStreamName] = PackACL(aclEntries)
+		} else {
+			logger.ErrorfWithError(aclErr, "%s: access ACL of inode %d volume '%s' is corrupt... removing it",
+				utils.GetFnName(), inodeNumber, vS.volumeName)
+			delete(inode.StreamMap, AccessACLStreamName)
+		}
+	}
+
 	updateTime := time.Now()
 	inode.AttrChangeTime = updateTime
 
@@ -1383,13 +1534,310 @@ func (vS *volumeStruct) DeleteStream(inodeNumber InodeNumber, inodeStreamName st
 	return
 }
 
+// logSegmentRangeStruct is a range of bytes of a LogSegment (as stored, i.e. compressed frames are not decompressed)
+type logSegmentRangeStruct struct {
+	offset uint64
+	length uint64
+}
+
+// logSegmentRangesBytes returns the number of distinct bytes covered by (possibly overlapping) logSegmentRanges
+func logSegmentRangesBytes(logSegmentRanges []logSegmentRangeStruct) (bytes uint64) {
+	var (
+		end             uint64
+		logSegmentRange logSegmentRangeStruct
+	)
+
+	sort.Slice(logSegmentRanges, func(i int, j int) bool { return logSegmentRanges[i].offset < logSegmentRanges[j].offset })
+
+	bytes = 0
+	end = 0
+
+	for _, logSegmentRange = range logSegmentRanges {
+		if logSegmentRange.offset > end {
+			end = logSegmentRange.offset
+		}
+		if (logSegmentRange.offset + logSegmentRange.length) > end {
+			bytes += (logSegmentRange.offset + logSegmentRange.length) - end
+			end = logSegmentRange.offset + logSegmentRange.length
+		}
+	}
+
+	return
+}
+
 func (vS *volumeStruct) GetFragmentationReport(inodeNumber InodeNumber) (fragmentationReport FragmentationReport, err error) {
-	err = fmt.Errorf("GetFragmentationReport not yet implemented")
+	var (
+		extentIndex         int
+		extentValue         sortedmap.Value
+		extents             sortedmap.BPlusTree
+		fileExtent          *fileExtentStruct
+		fileInode           *inMemoryInodeStruct
+		inFlightHit         bool
+		inFlightLogSegment  *inFlightLogSegmentStruct
+		logSegmentLength    uint64
+		logSegmentNumber    uint64
+		logSegmentRangesMap map[uint64][]logSegmentRangeStruct
+		logSegmentShared    bool
+		numExtents          int
+		objectContainerName string
+		objectName          string
+		ok                  bool
+		referencedBytes     uint64
+	)
+
+	fileInode, err = vS.fetchInodeType(inodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	// Each extent in the file's B+Tree is a fragment... and their lengths sum to the live bytes. Each
+	// also references a range of its LogSegment (or, if compressed, the entire frame holding its data).
+
+	extents = fileInode.payload.(sortedmap.BPlusTree)
+
+	numExtents, err = extents.Len()
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: extents.Len() for inode %d volume '%s' failed", utils.GetFnName(), inodeNumber, vS.volumeName)
+		return
+	}
+
+	logSegmentRangesMap = make(map[uint64][]logSegmentRangeStruct)
+
+	for extentIndex = 0; extentIndex < numExtents; extentIndex++ {
+		_, extentValue, ok, err = extents.GetByIndex(extentIndex)
+		if nil != err {
+			logger.ErrorfWithError(err, "%s: extents.GetByIndex(%d) for inode %d volume '%s' failed", utils.GetFnName(), extentIndex, inodeNumber, vS.volumeName)
+			return
+		}
+		if !ok {
+			err = fmt.Errorf("%s: extents.GetByIndex(%d) for inode %d volume '%s' returned !ok", utils.GetFnName(), extentIndex, inodeNumber, vS.volumeName)
+			err = blunder.AddError(err, blunder.CorruptInodeError)
+			logger.ErrorWithError(err)
+			return
+		}
+		fileExtent = extentValue.(*fileExtentStruct)
+		fragmentationReport.NumberOfFragments++
+		fragmentationReport.BytesInFragments += fileExtent.Length
+
+		if 0 == fileExtent.FrameLength {
+			logSegmentRangesMap[fileExtent.LogSegmentNumber] = append(logSegmentRangesMap[fileExtent.LogSegmentNumber], logSegmentRangeStruct{offset: fileExtent.LogSegmentOffset, length: fileExtent.Length})
+		} else {
+			logSegmentRangesMap[fileExtent.LogSegmentNumber] = append(logSegmentRangesMap[fileExtent.LogSegmentNumber], logSegmentRangeStruct{offset: fileExtent.FrameOffset, length: fileExtent.FrameLength})
+		}
+	}
+
+	// Any bytes in a referenced LogSegment not covered by an extent are trapped... unless the LogSegment is
+	// shared with another FileInode (as they may be referenced by it and would not be freed by Optimize() anyway)
+
+	for logSegmentNumber = range fileInode.LogSegmentMap {
+		logSegmentShared, err = vS.isLogSegmentShared(logSegmentNumber)
+		if nil != err {
+			logger.ErrorfWithError(err, "%s: unable to determine if LogSegment 0x%016X of inode %d volume '%s' is shared", utils.GetFnName(), logSegmentNumber, inodeNumber, vS.volumeName)
+			return
+		}
+		if logSegmentShared {
+			continue
+		}
+
+		fileInode.Lock()
+		inFlightLogSegment, inFlightHit = fileInode.inFlightLogSegmentMap[logSegmentNumber]
+		if inFlightHit {
+			logSegmentLength, err = inFlightLogSegment.BytesPut()
+		}
+		fileInode.Unlock()
+
+		if !inFlightHit {
+			objectContainerName, objectName, _, err = vS.getObjectLocationFromLogSegmentNumber(logSegmentNumber)
+			if nil == err {
+				logSegmentLength, err = swiftclient.ObjectContentLength(vS.headhunterVolumeHandle.FetchLogSegmentAccountName(logSegmentNumber), objectContainerName, objectName)
+			}
+		}
+		if nil != err {
+			logger.ErrorfWithError(err, "%s: unable to determine length of LogSegment 0x%016X of inode %d volume '%s'", utils.GetFnName(), logSegmentNumber, inodeNumber, vS.volumeName)
+			return
+		}
+
+		referencedBytes = logSegmentRangesBytes(logSegmentRangesMap[logSegmentNumber])
+
+		if logSegmentLength > referencedBytes {
+			fragmentationReport.BytesTrapped += logSegmentLength - referencedBytes
+		}
+	}
+
+	err = nil
 	return
 }
 
-func (vS *volumeStruct) Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (err error) {
-	err = fmt.Errorf("Optimize not yet implemented")
+// Optimize rewrites runs of file-contiguous extents of a FileInode into fresh LogSegments. Each run
+// is read back (via the Read Cache or inFlightLogSegments) and resent via doSendChunk() such that it
+// occupies a single extent. Runs are limited to flowControl.maxFlushSize bytes. Holes are preserved.
+//
+// A run whose extents are already contiguous in a single LogSegment (e.g. one rewritten by a prior
+// call) is skipped. Work stops once maxDuration has elapsed. As such, a subsequent call effectively
+// resumes where work stopped without that point having to be remembered.
+//
+// The number of bytes actually rewritten is returned in bytesOptimized. As file content is unchanged,
+// neither ModificationTime nor AttrChangeTime are updated. Superseded LogSegments are released by the
+// flushInode() performed prior to returning.
+func (vS *volumeStruct) Optimize(inodeNumber InodeNumber, maxDuration time.Duration) (bytesOptimized uint64, err error) {
+	var (
+		deadline         time.Time
+		extentIndex      int
+		extentValue      sortedmap.Value
+		extents          sortedmap.BPlusTree
+		fileExtent       *fileExtentStruct
+		fileInode        *inMemoryInodeStruct
+		frameLength      uint64
+		frameOffset      uint64
+		logSegmentNumber uint64
+		logSegmentOffset uint64
+		maxRunLength     uint64
+		nextFileOffset   uint64
+		ok               bool
+		prevExtent       *fileExtentStruct
+		readPlan         []ReadPlanStep
+		readPlanBytes    uint64
+		runBuf           []byte
+		runContiguous    bool
+		runExtents       uint64
+		runFileOffset    uint64
+		runLength        uint64
+	)
+
+	stats.IncrementOperations(&stats.FileOptimizeOps)
+
+	deadline = time.Now().Add(maxDuration)
+
+	bytesOptimized = 0
+
+	fileInode, err = vS.fetchInodeType(inodeNumber, FileType)
+	if nil != err {
+		logger.ErrorWithError(err)
+		return
+	}
+
+	extents = fileInode.payload.(sortedmap.BPlusTree)
+
+	maxRunLength = vS.flowControl.maxFlushSize
+
+	nextFileOffset = 0
+
+	for time.Now().Before(deadline) {
+		// Locate the run of file-contiguous extents starting at (or straddling) nextFileOffset
+
+		extentIndex, _, err = extents.BisectLeft(nextFileOffset)
+		if nil != err {
+			logger.ErrorfWithError(err, "%s: extents.BisectLeft(%d) for inode %d volume '%s' failed", utils.GetFnName(), nextFileOffset, inodeNumber, vS.volumeName)
+			return
+		}
+		if 0 > extentIndex {
+			extentIndex = 0
+		}
+
+		runExtents = 0
+		runContiguous = true
+
+		for {
+			_, extentValue, ok, err = extents.GetByIndex(extentIndex)
+			if nil != err {
+				logger.ErrorfWithError(err, "%s: extents.GetByIndex(%d) for inode %d volume '%s' failed", utils.GetFnName(), extentIndex, inodeNumber, vS.volumeName)
+				return
+			}
+			if !ok {
+				// We have reached the end of extents
+				break
+			}
+			fileExtent = extentValue.(*fileExtentStruct)
+			if 0 == runExtents {
+				if (fileExtent.FileOffset + fileExtent.Length) <= nextFileOffset {
+					// Preceeding extent ends prior to nextFileOffset... so skip it
+					extentIndex++
+					continue
+				}
+				runFileOffset = fileExtent.FileOffset
+				runLength = fileExtent.Length
+			} else {
+				if (runFileOffset + runLength) != fileExtent.FileOffset {
+					// A hole ends the run
+					break
+				}
+				if (runLength + fileExtent.Length) > maxRunLength {
+					// Run would become too large to send as a single chunk
+					break
+				}
+				runLength += fileExtent.Length
+				if !fileExtentsContiguous(prevExtent, fileExtent) {
+					runContiguous = false
+				}
+			}
+			prevExtent = fileExtent
+			runExtents++
+			extentIndex++
+		}
+
+		if 0 == runExtents {
+			// We have reached the end of the file
+			break
+		}
+
+		if !runContiguous {
+			readPlan, readPlanBytes, err = vS.getReadPlanHelper(fileInode, &runFileOffset, &runLength)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+			runBuf, err = vS.doReadPlan(fileInode, readPlan, readPlanBytes)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+			fileInode.dirty = true
+
+			logSegmentNumber, logSegmentOffset, frameOffset, frameLength, err = vS.doSendChunk(fileInode, runBuf)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+			err = recordFramedWrite(fileInode, runFileOffset, runLength, logSegmentNumber, logSegmentOffset, frameOffset, frameLength)
+			if nil != err {
+				logger.ErrorWithError(err)
+				return
+			}
+
+			bytesOptimized += runLength
+		}
+
+		nextFileOffset = runFileOffset + runLength
+	}
+
+	if fileInode.dirty {
+		err = vS.flushInode(fileInode)
+		if nil != err {
+			logger.ErrorWithError(err)
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// fileExtentsContiguous reports whether nextExtent immediately follows prevExtent in the same LogSegment (or, if
+// compressed, is held in the frame immediately following that of prevExtent)
+func fileExtentsContiguous(prevExtent *fileExtentStruct, nextExtent *fileExtentStruct) (contiguous bool) {
+	if prevExtent.LogSegmentNumber != nextExtent.LogSegmentNumber {
+		contiguous = false
+	} else if (0 == prevExtent.FrameLength) && (0 == nextExtent.FrameLength) {
+		contiguous = ((prevExtent.LogSegmentOffset + prevExtent.Length) == nextExtent.LogSegmentOffset)
+	} else if (0 != prevExtent.FrameLength) && (0 != nextExtent.FrameLength) {
+		contiguous = ((prevExtent.FrameOffset + prevExtent.FrameLength) == nextExtent.FrameOffset)
+	} else {
+		contiguous = false
+	}
 	return
 }
 
@@ -1450,6 +1898,10 @@ func validateFileExtents(ourInode *inMemoryInodeStruct) (err error) {
 
 	for _, planStep := range readPlan {
 		stepEndOffset := planStep.Offset + planStep.Length
+		if 0 != planStep.FrameLength {
+			// planStep.Offset is relative to the decompressed content of the frame
+			stepEndOffset = planStep.FrameOffset + planStep.FrameLength
+		}
 		endOffset, ok := objectPathToEndOffset[planStep.ObjectPath]
 		if !ok || stepEndOffset > endOffset {
 			objectPathToEndOffset[planStep.ObjectPath] = stepEndOffset
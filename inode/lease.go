@@ -0,0 +1,245 @@
+package inode
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+	"github.com/swiftstack/ProxyFS/utils"
+)
+
+// A LogSegment lease pins the LogSegments referenced by a ReadPlan handed out to a client that
+// will be reading the LogSegments directly (e.g. the pfs_middleware serving an object GET).
+//
+// While any lease references a LogSegment, deleteLogSegmentAsync() merely records that the
+// LogSegment should be deleted. Once the last lease referencing it is released (or expires),
+// the deferred deletion is performed. Note that leases are not persisted... so, should ProxyFS
+// restart, any deferred deletions are lost (leaving the LogSegment as garbage).
+
+const (
+	logSegmentLeaseDefaultDuration = 30 * time.Second
+)
+
+type logSegmentLeaseStruct struct {
+	leaseID           string
+	volume            *volumeStruct
+	logSegmentNumbers []uint64    // each LogSegment appears only once
+	timer             *time.Timer // fires upon lease expiration
+}
+
+func (vS *volumeStruct) CreateLogSegmentLease(readPlan []ReadPlanStep) (leaseID string, err error) {
+	var (
+		lease               *logSegmentLeaseStruct
+		leaseNonce          uint64
+		logSegmentNumber    uint64
+		logSegmentNumberSet map[uint64]struct{}
+		readPlanStep        ReadPlanStep
+	)
+
+	stats.IncrementOperations(&stats.LogSegLeaseCreateOps)
+
+	logSegmentNumberSet = make(map[uint64]struct{})
+
+	for _, readPlanStep = range readPlan {
+		if 0 == readPlanStep.LogSegmentNumber {
+			// Zero-fill ReadPlanStep... so no LogSegment to pin
+			continue
+		}
+		logSegmentNumberSet[readPlanStep.LogSegmentNumber] = struct{}{}
+	}
+
+	if 0 == len(logSegmentNumberSet) {
+		// Nothing to pin... so no lease needed
+		leaseID = ""
+		err = nil
+		return
+	}
+
+	leaseNonce, err = vS.headhunterVolumeHandle.FetchNonce()
+	if nil != err {
+		logger.ErrorfWithError(err, "%s: headhunter.FetchNonce() for volume '%s' failed", utils.GetFnName(), vS.volumeName)
+		return
+	}
+
+	lease = &logSegmentLeaseStruct{
+		leaseID:           fmt.Sprintf("%s-%016X", vS.volumeName, leaseNonce),
+		volume:            vS,
+		logSegmentNumbers: make([]uint64, 0, len(logSegmentNumberSet)),
+	}
+
+	vS.Lock()
+	for logSegmentNumber = range logSegmentNumberSet {
+		vS.leasedLogSegmentMap[logSegmentNumber]++
+		lease.logSegmentNumbers = append(lease.logSegmentNumbers, logSegmentNumber)
+	}
+	vS.Unlock()
+
+	globals.Lock()
+	globals.logSegmentLeaseMap[lease.leaseID] = lease
+	lease.timer = time.AfterFunc(globals.logSegmentLeaseDuration, lease.expire)
+	globals.Unlock()
+
+	leaseID = lease.leaseID
+
+	err = nil
+	return
+}
+
+func renewLogSegmentLease(leaseID string) (err error) {
+	var (
+		lease *logSegmentLeaseStruct
+		ok    bool
+	)
+
+	stats.IncrementOperations(&stats.LogSegLeaseRenewOps)
+
+	if "" == leaseID {
+		// Nothing was pinned by this (non-)lease
+		err = nil
+		return
+	}
+
+	globals.Lock()
+
+	lease, ok = globals.logSegmentLeaseMap[leaseID]
+	if !ok {
+		globals.Unlock()
+		err = fmt.Errorf("%s: lease '%s' not found (or has expired)", utils.GetFnName(), leaseID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	if !lease.timer.Stop() {
+		// Lease expired... but expire() has yet to remove it from globals.logSegmentLeaseMap
+		globals.Unlock()
+		err = fmt.Errorf("%s: lease '%s' has expired", utils.GetFnName(), leaseID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	lease.timer.Reset(globals.logSegmentLeaseDuration)
+
+	globals.Unlock()
+
+	err = nil
+	return
+}
+
+func releaseLogSegmentLease(leaseID string) (err error) {
+	var (
+		lease *logSegmentLeaseStruct
+		ok    bool
+	)
+
+	stats.IncrementOperations(&stats.LogSegLeaseReleaseOps)
+
+	if "" == leaseID {
+		// Nothing was pinned by this (non-)lease
+		err = nil
+		return
+	}
+
+	globals.Lock()
+
+	lease, ok = globals.logSegmentLeaseMap[leaseID]
+	if !ok {
+		globals.Unlock()
+		err = fmt.Errorf("%s: lease '%s' not found (or has expired)", utils.GetFnName(), leaseID)
+		err = blunder.AddError(err, blunder.NotFoundError)
+		return
+	}
+
+	_ = lease.timer.Stop()
+
+	delete(globals.logSegmentLeaseMap, leaseID)
+
+	globals.Unlock()
+
+	lease.unpin()
+
+	err = nil
+	return
+}
+
+func (lease *logSegmentLeaseStruct) expire() {
+	var (
+		ok bool
+	)
+
+	globals.Lock()
+	_, ok = globals.logSegmentLeaseMap[lease.leaseID]
+	if ok {
+		delete(globals.logSegmentLeaseMap, lease.leaseID)
+	}
+	globals.Unlock()
+
+	if !ok {
+		// Lease was released concurrently with its expiration
+		return
+	}
+
+	stats.IncrementOperations(&stats.LogSegLeaseExpireOps)
+
+	logger.Infof("LogSegment lease '%s' expired", lease.leaseID)
+
+	lease.unpin()
+}
+
+// unpin drops the lease's references on its LogSegments, performing any deferred deletions
+func (lease *logSegmentLeaseStruct) unpin() {
+	var (
+		checkpointDoneWaitGroup   *sync.WaitGroup
+		deferredLogSegmentNumbers []uint64
+		deleteDeferred            bool
+		err                       error
+		logSegmentNumber          uint64
+		vS                        *volumeStruct
+	)
+
+	vS = lease.volume
+
+	deferredLogSegmentNumbers = make([]uint64, 0)
+
+	vS.Lock()
+	for _, logSegmentNumber = range lease.logSegmentNumbers {
+		if 1 < vS.leasedLogSegmentMap[logSegmentNumber] {
+			vS.leasedLogSegmentMap[logSegmentNumber]--
+			continue
+		}
+		delete(vS.leasedLogSegmentMap, logSegmentNumber)
+		_, deleteDeferred = vS.deferredLogSegmentDeleteSet[logSegmentNumber]
+		if deleteDeferred {
+			delete(vS.deferredLogSegmentDeleteSet, logSegmentNumber)
+			deferredLogSegmentNumbers = append(deferredLogSegmentNumbers, logSegmentNumber)
+		}
+	}
+	vS.Unlock()
+
+	if 0 == len(deferredLogSegmentNumbers) {
+		return
+	}
+
+	checkpointDoneWaitGroup = vS.headhunterVolumeHandle.FetchNextCheckPointDoneWaitGroup()
+
+	for _, logSegmentNumber = range deferredLogSegmentNumbers {
+		err = vS.deleteLogSegmentAsync(logSegmentNumber, checkpointDoneWaitGroup)
+		if nil != err {
+			logger.WarnfWithError(err, "couldn't delete previously leased log segment")
+		}
+	}
+}
+
+// deferLogSegmentDeleteIfLeasedWhileLocked, if the LogSegment is leased, records that it should be deleted once unleased
+//
+// Note: Caller must hold vS.Lock()
+func (vS *volumeStruct) deferLogSegmentDeleteIfLeasedWhileLocked(logSegmentNumber uint64) (deferred bool) {
+	_, deferred = vS.leasedLogSegmentMap[logSegmentNumber]
+	if deferred {
+		vS.deferredLogSegmentDeleteSet[logSegmentNumber] = struct{}{}
+		stats.IncrementOperations(&stats.LogSegLeaseDeferredDeleteOps)
+	}
+	return
+}
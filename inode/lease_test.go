@@ -0,0 +1,209 @@
+package inode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/swiftclient"
+)
+
+func TestLogSegmentLease(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	ino, err := testVolumeHandle.CreateFile(PosixModePerm, 0, 0)
+	if nil != err {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+
+	err = testVolumeHandle.Write(ino, 0, []byte{0x24, 0x24, 0x24, 0x24}, nil)
+	if nil != err {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(ino, false)
+	if nil != err {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	offset := uint64(0)
+	length := uint64(4)
+
+	readPlan, err := testVolumeHandle.GetReadPlan(ino, &offset, &length)
+	if nil != err {
+		t.Fatalf("GetReadPlan() failed: %v", err)
+	}
+	if 1 != len(readPlan) {
+		t.Fatalf("GetReadPlan() returned unexpected %+v", readPlan)
+	}
+
+	var leasedSegmentNumber uint64
+	for segmentNumber := range volume.inodeCache[ino].LogSegmentMap {
+		leasedSegmentNumber = segmentNumber
+	}
+
+	containerName, objectName, _, err := volume.getObjectLocationFromLogSegmentNumber(leasedSegmentNumber)
+	if nil != err {
+		t.Fatalf("getObjectLocationFromLogSegmentNumber() failed: %v", err)
+	}
+
+	// A ReadPlan of only zero-fill needs no lease
+
+	leaseID, err := testVolumeHandle.CreateLogSegmentLease([]ReadPlanStep{ReadPlanStep{Length: 4}})
+	if nil != err {
+		t.Fatalf("CreateLogSegmentLease() of zero-fill ReadPlan failed: %v", err)
+	}
+	if "" != leaseID {
+		t.Fatalf("CreateLogSegmentLease() of zero-fill ReadPlan should have returned an empty leaseID")
+	}
+	err = RenewLogSegmentLease(leaseID)
+	if nil != err {
+		t.Fatalf("RenewLogSegmentLease(\"\") failed: %v", err)
+	}
+	err = ReleaseLogSegmentLease(leaseID)
+	if nil != err {
+		t.Fatalf("ReleaseLogSegmentLease(\"\") failed: %v", err)
+	}
+
+	// Take two leases on the LogSegment
+
+	leaseID, err = testVolumeHandle.CreateLogSegmentLease(readPlan)
+	if nil != err {
+		t.Fatalf("CreateLogSegmentLease() failed: %v", err)
+	}
+	secondLeaseID, err := testVolumeHandle.CreateLogSegmentLease(readPlan)
+	if nil != err {
+		t.Fatalf("CreateLogSegmentLease() [second] failed: %v", err)
+	}
+	if leaseID == secondLeaseID {
+		t.Fatalf("CreateLogSegmentLease() should have returned distinct leaseIDs")
+	}
+
+	err = RenewLogSegmentLease(leaseID)
+	if nil != err {
+		t.Fatalf("RenewLogSegmentLease() failed: %v", err)
+	}
+
+	// Overwrite the file so that its original LogSegment becomes garbage
+
+	err = testVolumeHandle.Write(ino, 0, []byte{0x25, 0x25, 0x25, 0x25}, nil)
+	if nil != err {
+		t.Fatalf("Write() [overwrite] failed: %v", err)
+	}
+	err = testVolumeHandle.Flush(ino, false)
+	if nil != err {
+		t.Fatalf("Flush() [overwrite] failed: %v", err)
+	}
+
+	err = volume.headhunterVolumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() failed: %v", err)
+	}
+
+	time.Sleep(1 * time.Second)
+
+	_, err = volume.getLogSegmentContainer(leasedSegmentNumber)
+	if nil != err {
+		t.Fatalf("leased LogSegment 0x%016X should not have been deleted: %v", leasedSegmentNumber, err)
+	}
+	_, err = swiftclient.ObjectGet(volume.accountName, containerName, objectName, 0, 4)
+	if nil != err {
+		t.Fatalf("leased LogSegment 0x%016X object should still be readable: %v", leasedSegmentNumber, err)
+	}
+
+	// Releasing only one of the leases should still leave the LogSegment in place
+
+	err = ReleaseLogSegmentLease(leaseID)
+	if nil != err {
+		t.Fatalf("ReleaseLogSegmentLease() failed: %v", err)
+	}
+	err = ReleaseLogSegmentLease(leaseID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("ReleaseLogSegmentLease() of released lease should have failed with NotFoundError: %v", err)
+	}
+	err = RenewLogSegmentLease(leaseID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("RenewLogSegmentLease() of released lease should have failed with NotFoundError: %v", err)
+	}
+
+	_, err = volume.getLogSegmentContainer(leasedSegmentNumber)
+	if nil != err {
+		t.Fatalf("LogSegment 0x%016X still leased should not have been deleted: %v", leasedSegmentNumber, err)
+	}
+
+	// Releasing the last lease should perform the deferred deletion
+
+	err = ReleaseLogSegmentLease(secondLeaseID)
+	if nil != err {
+		t.Fatalf("ReleaseLogSegmentLease() [second] failed: %v", err)
+	}
+
+	err = volume.headhunterVolumeHandle.DoCheckpoint()
+	if nil != err {
+		t.Fatalf("DoCheckpoint() failed: %v", err)
+	}
+
+	_, err = volume.getLogSegmentContainer(leasedSegmentNumber)
+	if nil == err {
+		t.Fatalf("unleased LogSegment 0x%016X should have been deleted", leasedSegmentNumber)
+	}
+
+	for try := 0; try < 100; try++ {
+		_, err = swiftclient.ObjectGet(volume.accountName, containerName, objectName, 0, 4)
+		if nil != err {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if nil == err {
+		t.Fatalf("unleased LogSegment 0x%016X object should have been deleted", leasedSegmentNumber)
+	}
+
+	err = testVolumeHandle.Destroy(ino)
+	if nil != err {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+}
+
+func TestLogSegmentLeaseExpiration(t *testing.T) {
+	testVolumeHandle, err := FetchVolumeHandle("TestVolume")
+	if nil != err {
+		t.Fatalf("FetchVolumeHandle(\"TestVolume\") failed: %v", err)
+	}
+
+	volume := testVolumeHandle.(*volumeStruct)
+
+	savedLogSegmentLeaseDuration := globals.logSegmentLeaseDuration
+	globals.logSegmentLeaseDuration = 100 * time.Millisecond
+	defer func() { globals.logSegmentLeaseDuration = savedLogSegmentLeaseDuration }()
+
+	leaseID, err := testVolumeHandle.CreateLogSegmentLease([]ReadPlanStep{ReadPlanStep{LogSegmentNumber: 1, Length: 1}})
+	if nil != err {
+		t.Fatalf("CreateLogSegmentLease() failed: %v", err)
+	}
+
+	volume.Lock()
+	leaseCount := volume.leasedLogSegmentMap[1]
+	volume.Unlock()
+	if 1 != leaseCount {
+		t.Fatalf("CreateLogSegmentLease() should have pinned LogSegment 0x0000000000000001")
+	}
+
+	time.Sleep(time.Second)
+
+	err = RenewLogSegmentLease(leaseID)
+	if !blunder.Is(err, blunder.NotFoundError) {
+		t.Fatalf("RenewLogSegmentLease() of expired lease should have failed with NotFoundError: %v", err)
+	}
+
+	volume.Lock()
+	_, stillLeased := volume.leasedLogSegmentMap[1]
+	volume.Unlock()
+	if stillLeased {
+		t.Fatalf("expired lease should have unpinned LogSegment 0x0000000000000001")
+	}
+}
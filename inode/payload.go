@@ -103,12 +103,32 @@ func (c *fileInodeCallbacks) PackValue(value sortedmap.Value) (packedValue []byt
 		err = fmt.Errorf("PackValue() arg is not a *fileExtentStruct")
 		return
 	}
-	packedValue, err = cstruct.Pack(fileExtent, sortedmap.OnDiskByteOrder)
+	if c.inode.FramedExtents {
+		packedValue, err = cstruct.Pack(fileExtent, sortedmap.OnDiskByteOrder)
+		if nil != err {
+			return
+		}
+		if uint64(len(packedValue)) != globals.fileExtentStructSize {
+			err = fmt.Errorf("PackValue() should have produced len(packedValue) == %v", globals.fileExtentStructSize)
+		}
+		return
+	}
+	if 0 != fileExtent.FrameLength {
+		err = fmt.Errorf("PackValue() arg refers to a compressed frame but FileInode lacks FramedExtents")
+		return
+	}
+	fileExtentV1 := &fileExtentV1Struct{
+		FileOffset:       fileExtent.FileOffset,
+		Length:           fileExtent.Length,
+		LogSegmentNumber: fileExtent.LogSegmentNumber,
+		LogSegmentOffset: fileExtent.LogSegmentOffset,
+	}
+	packedValue, err = cstruct.Pack(fileExtentV1, sortedmap.OnDiskByteOrder)
 	if nil != err {
 		return
 	}
-	if uint64(len(packedValue)) != globals.fileExtentStructSize {
-		err = fmt.Errorf("PackValue() should have produced len(packedValue) == %v", globals.fileExtentStructSize)
+	if uint64(len(packedValue)) != globals.fileExtentV1StructSize {
+		err = fmt.Errorf("PackValue() should have produced len(packedValue) == %v", globals.fileExtentV1StructSize)
 	}
 	return
 }
@@ -124,17 +144,37 @@ func (c *fileInodeCallbacks) UnpackKey(payloadData []byte) (key sortedmap.Key, b
 }
 
 func (c *fileInodeCallbacks) UnpackValue(payloadData []byte) (value sortedmap.Value, bytesConsumed uint64, err error) {
-	if uint64(len(payloadData)) < globals.fileExtentStructSize {
-		err = fmt.Errorf("UnpackValue() arg not big enough to encode fileExtentStruct")
+	if c.inode.FramedExtents {
+		if uint64(len(payloadData)) < globals.fileExtentStructSize {
+			err = fmt.Errorf("UnpackValue() arg not big enough to encode fileExtentStruct")
+			return
+		}
+		valueAsFileExtentPtr := &fileExtentStruct{}
+		_, err = cstruct.Unpack(payloadData, valueAsFileExtentPtr, sortedmap.OnDiskByteOrder)
+		if nil != err {
+			return
+		}
+		value = valueAsFileExtentPtr
+		bytesConsumed = globals.fileExtentStructSize
+		err = nil
+		return
+	}
+	if uint64(len(payloadData)) < globals.fileExtentV1StructSize {
+		err = fmt.Errorf("UnpackValue() arg not big enough to encode fileExtentV1Struct")
 		return
 	}
-	valueAsFileExtentPtr := &fileExtentStruct{}
-	_, err = cstruct.Unpack(payloadData, valueAsFileExtentPtr, sortedmap.OnDiskByteOrder)
+	valueAsFileExtentV1 := fileExtentV1Struct{}
+	_, err = cstruct.Unpack(payloadData, &valueAsFileExtentV1, sortedmap.OnDiskByteOrder)
 	if nil != err {
 		return
 	}
-	value = valueAsFileExtentPtr
-	bytesConsumed = globals.fileExtentStructSize
+	value = &fileExtentStruct{
+		FileOffset:       valueAsFileExtentV1.FileOffset,
+		Length:           valueAsFileExtentV1.Length,
+		LogSegmentNumber: valueAsFileExtentV1.LogSegmentNumber,
+		LogSegmentOffset: valueAsFileExtentV1.LogSegmentOffset,
+	}
+	bytesConsumed = globals.fileExtentV1StructSize
 	err = nil
 	return
 }
@@ -128,6 +128,15 @@ import (
 //
 // Structs used by Swift middleware are defined below.
 
+// AcquireInodeLeaseRequest is the request object for RpcAcquireInodeLease.
+//
+// LeaseType is one of fs.InodeLeaseRead or fs.InodeLeaseWrite. Should the inode have a conflicting
+// lease, it is recalled and an EAGAIN returned... the caller may retry once the recall completes.
+type AcquireInodeLeaseRequest struct {
+	InodeHandle
+	LeaseType uint32
+}
+
 // ChmodRequest is the request object for RpcChmod.
 type ChmodRequest struct {
 	InodeHandle
@@ -154,6 +163,87 @@ type ChownPathRequest struct {
 	GroupID int32
 }
 
+// CloneRangeRequest is the request object for RpcCloneRange.
+//
+// Length bytes of the file identified by InodeNumber starting at Offset are made to reference the
+// same data as the file identified by SrcInodeNumber starting at SrcOffset. The range is truncated
+// at the end of the source file.
+type CloneRangeRequest struct {
+	InodeHandle
+	Offset         uint64
+	SrcInodeNumber uint64
+	SrcOffset      uint64
+	Length         uint64
+}
+
+// CloneRangeReply is the reply object for RpcCloneRange.
+type CloneRangeReply struct {
+	ClonedLength uint64
+}
+
+// CompoundRequest is the request object for RpcCompound.
+//
+// Steps are executed in order (see compound.go). The MountID in each step's request is ignored
+// in favor of the MountID here. Unless ContinueOnError is set, execution stops at the first step
+// to fail.
+type CompoundRequest struct {
+	MountID         uint64
+	Steps           []CompoundStep
+	ContinueOnError bool
+	connection      *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// CompoundStep is a single step of a CompoundRequest. Exactly one of the request pointers must be set.
+//
+// A non-zero InodeNumberFromStep (numbered from 1) replaces the InodeNumber of the request (the
+// SrcDirInodeNumber for a RenameRequest) with the InodeNumber resulting from that earlier step.
+// A non-zero TargetInodeNumberFromStep similarly replaces the TargetInodeNumber of a LinkRequest
+// (the DstDirInodeNumber for a RenameRequest).
+type CompoundStep struct {
+	InodeNumberFromStep       uint64
+	TargetInodeNumberFromStep uint64
+	Chmod                     *ChmodRequest       `json:",omitempty"`
+	Chown                     *ChownRequest       `json:",omitempty"`
+	Create                    *CreateRequest      `json:",omitempty"`
+	GetStat                   *GetStatRequest     `json:",omitempty"`
+	GetXAttr                  *GetXAttrRequest    `json:",omitempty"`
+	Link                      *LinkRequest        `json:",omitempty"`
+	ListXAttr                 *ListXAttrRequest   `json:",omitempty"`
+	Lookup                    *LookupRequest      `json:",omitempty"`
+	Mkdir                     *MkdirRequest       `json:",omitempty"`
+	ReadSymlink               *ReadSymlinkRequest `json:",omitempty"`
+	RemoveXAttr               *RemoveXAttrRequest `json:",omitempty"`
+	Rename                    *RenameRequest      `json:",omitempty"`
+	Resize                    *ResizeRequest      `json:",omitempty"`
+	Rmdir                     *UnlinkRequest      `json:",omitempty"`
+	Setstat                   *SetstatRequest     `json:",omitempty"`
+	SetTime                   *SetTimeRequest     `json:",omitempty"`
+	SetXAttr                  *SetXAttrRequest    `json:",omitempty"`
+	Symlink                   *SymlinkRequest     `json:",omitempty"`
+	Type                      *TypeRequest        `json:",omitempty"`
+	Unlink                    *UnlinkRequest      `json:",omitempty"`
+}
+
+// CompoundReply is the reply object for RpcCompound. StepReplies holds one entry for each executed step.
+type CompoundReply struct {
+	StepReplies []CompoundStepReply
+}
+
+// CompoundStepReply is the result of a single step of a CompoundRequest.
+//
+// Errno is zero if the step succeeded. InodeNumber is the inode created or found by the step
+// (Create, Mkdir, Symlink, Lookup, GetStat) or, otherwise, the inode the step operated upon.
+// At most one of the reply pointers is set (depending on the step's request type).
+type CompoundStepReply struct {
+	Errno       uint64
+	InodeNumber uint64
+	GetStat     *StatStruct       `json:",omitempty"`
+	GetXAttr    *GetXAttrReply    `json:",omitempty"`
+	ListXAttr   *ListXAttrReply   `json:",omitempty"`
+	ReadSymlink *ReadSymlinkReply `json:",omitempty"`
+	Type        *TypeReply        `json:",omitempty"`
+}
+
 // CreateRequest is the request object for RpcCreate.
 type CreateRequest struct {
 	InodeHandle
@@ -182,6 +272,141 @@ type DirEntry struct {
 	NextDirLocation uint32
 }
 
+// FetchInodeLeaseRecallsRequest is the request object for RpcFetchInodeLeaseRecalls.
+//
+// The call waits up to TimeoutMs for a recall to be queued for MountID.
+type FetchInodeLeaseRecallsRequest struct {
+	MountID    uint64
+	TimeoutMs  uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// InodeLeaseRecall describes a lease being recalled. The holder should flush any
+// dirty state for InodeNumber and then call RpcReleaseInodeLease.
+type InodeLeaseRecall struct {
+	InodeNumber uint64
+	LeaseType   uint32
+	Reason      uint32 // dlm.ReasonWriteRequest or dlm.ReasonReadRequest
+}
+
+// FetchInodeLeaseRecallsReply is the reply object for RpcFetchInodeLeaseRecalls.
+type FetchInodeLeaseRecallsReply struct {
+	Recalls []InodeLeaseRecall
+}
+
+// FetchJournalRequest is the request object for RpcFetchJournal.
+//
+// Cursor is either "" (to start from the oldest retained record) or the Cursor
+// returned by a prior RpcFetchJournal. If MaxRecs is 0, a server default applies.
+type FetchJournalRequest struct {
+	MountID    uint64
+	Cursor     string
+	MaxRecs    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// JournalRec describes a change recorded in a volume's change journal. Op is
+// one of the fs.JournalOp* values. ParentInodeNumber & Basename are only set
+// for those ops naming a directory entry. Time is in nanoseconds since the epoch.
+type JournalRec struct {
+	Nonce             uint64
+	Op                uint32
+	InodeNumber       uint64
+	ParentInodeNumber uint64
+	Basename          string
+	Time              int64
+}
+
+// FetchJournalReply is the reply object for RpcFetchJournal.
+//
+// Records are in the order they were recorded. Should records following the
+// request's Cursor have since been trimmed, the call fails with errno ERANGE.
+type FetchJournalReply struct {
+	Records []JournalRec
+	Cursor  string
+}
+
+// FetchQuotaReportRequest is the request object for RpcFetchQuotaReport.
+type FetchQuotaReportRequest struct {
+	MountID    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// QuotaStatus describes the limits and usage of one of a volume's quotas. Type
+// is one of the fs.QuotaType* values. A limit of 0 is not enforced. GracePeriod
+// is in nanoseconds. Each GraceExpiration is in nanoseconds since the epoch (or
+// 0 unless usage exceeds the corresponding soft limit).
+type QuotaStatus struct {
+	Name                  string
+	Type                  uint32
+	ID                    uint64
+	Path                  string
+	UsedBytes             uint64
+	UsedInodes            uint64
+	SoftBytes             uint64
+	HardBytes             uint64
+	SoftInodes            uint64
+	HardInodes            uint64
+	GracePeriod           int64
+	BytesGraceExpiration  int64
+	InodesGraceExpiration int64
+}
+
+// FetchQuotaReportReply is the reply object for RpcFetchQuotaReport.
+type FetchQuotaReportReply struct {
+	Quotas []QuotaStatus
+}
+
+// FetchTrashRequest is the request object for RpcFetchTrash.
+type FetchTrashRequest struct {
+	MountID    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// TrashEntry describes an inode in a volume's trash. Name is passed to RpcRestoreTrash
+// to move it back to ParentInodeNumber/Basename. InodeType is one of the inode.*Type
+// values. DeletionTime & ExpirationTime are in nanoseconds since the epoch.
+type TrashEntry struct {
+	Name              string
+	InodeNumber       uint64
+	InodeType         uint32
+	ParentInodeNumber uint64
+	Basename          string
+	DeletionTime      int64
+	ExpirationTime    int64
+}
+
+// FetchTrashReply is the reply object for RpcFetchTrash.
+type FetchTrashReply struct {
+	Entries []TrashEntry
+}
+
+// FetchWatchEventsRequest is the request object for RpcFetchWatchEvents.
+//
+// The call waits up to TimeoutMs for an event to be queued for WatchID.
+type FetchWatchEventsRequest struct {
+	MountID    uint64
+	WatchID    uint64
+	TimeoutMs  uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// WatchEvent describes a change to (an entry of) a watched directory. Type is
+// one of the fs.WatchEvent* values. A WatchEventOverflow event indicates that
+// subsequent events were lost (so the directory should be re-read).
+type WatchEvent struct {
+	Type           uint32
+	DirInodeNumber uint64
+	Basename       string
+	InodeNumber    uint64
+	Cookie         uint64 // Pairs the RenameFrom & RenameTo events of a single rename
+}
+
+// FetchWatchEventsReply is the reply object for RpcFetchWatchEvents.
+type FetchWatchEventsReply struct {
+	Events []WatchEvent
+}
+
 // FlushRequest is the request object for RpcFlush.
 type FlushRequest struct {
 	InodeHandle
@@ -236,6 +461,7 @@ type FlockReply struct {
 type InodeHandle struct {
 	MountID     uint64
 	InodeNumber uint64
+	connection  *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // InodeReply is the reply object for requests that return an inode number.
@@ -251,8 +477,9 @@ type LogRequest struct {
 
 // LookupPathRequest is the request object for RpcLookupPath.
 type LookupPathRequest struct {
-	MountID  uint64
-	Fullpath string
+	MountID    uint64
+	Fullpath   string
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // LinkRequest is the request object for RpcLinkPath.
@@ -304,11 +531,18 @@ type MkdirPathRequest struct {
 }
 
 // MountRequest is the request object for RpcMount.
+//
+// AuthUserID and AuthGroupID are bound to the returned MountID and used for all
+// data path requests (RpcRead, RpcWrite, and the fast I/O port) made with it.
+//
+// AuthToken must match one of JSONRPCServer.AuthTokenList (if configured).
 type MountRequest struct {
 	VolumeName   string
 	MountOptions uint64
 	AuthUserID   uint64
 	AuthGroupID  uint64
+	AuthToken    string
+	connection   *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // MountReply is the reply object for RpcMount.
@@ -319,8 +553,9 @@ type MountReply struct {
 
 // PathHandle is embedded in a number of the request objects.
 type PathHandle struct {
-	MountID  uint64
-	Fullpath string
+	MountID    uint64
+	Fullpath   string
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // ReaddirPlusRequest is the request object for RpcReaddirPlus.
@@ -391,6 +626,11 @@ type ReadSymlinkReply struct {
 	Target string
 }
 
+// ReleaseInodeLeaseRequest is the request object for RpcReleaseInodeLease.
+type ReleaseInodeLeaseRequest struct {
+	InodeHandle
+}
+
 type RemoveXAttrRequest struct {
 	InodeHandle
 	AttrName string
@@ -408,6 +648,7 @@ type RenameRequest struct {
 	SrcBasename       string
 	DstDirInodeNumber uint64
 	DstBasename       string
+	connection        *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // RenamePathRequest is the request object for RpcRenamePath.
@@ -425,6 +666,13 @@ type Reply struct {
 	SendTimeNsec    int64
 }
 
+// RestoreTrashRequest is the request object for RpcRestoreTrash.
+type RestoreTrashRequest struct {
+	MountID    uint64
+	Name       string
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
 // ResizeRequest is the request object for RpcResize.
 type ResizeRequest struct {
 	InodeHandle
@@ -465,7 +713,8 @@ type SetXAttrPathRequest struct {
 
 // StatVFSRequest is the request object for RpcStatVFS.
 type StatVFSRequest struct {
-	MountID uint64
+	MountID    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
 }
 
 // StatVFS is used when filesystem stats need to be conveyed. It is used by RpcStatVFS.
@@ -542,6 +791,29 @@ type UnlinkPathRequest struct {
 	PathHandle
 }
 
+// UnmountRequest is the request object for RpcUnmount.
+type UnmountRequest struct {
+	MountID    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
+// WatchAddRequest is the request object for RpcWatchAdd.
+type WatchAddRequest struct {
+	InodeHandle
+}
+
+// WatchAddReply is the reply object for RpcWatchAdd.
+type WatchAddReply struct {
+	WatchID uint64
+}
+
+// WatchRemoveRequest is the request object for RpcWatchRemove.
+type WatchRemoveRequest struct {
+	MountID    uint64
+	WatchID    uint64
+	connection *connectionStruct // Set (if received over a connection) by connectionServerCodecStruct.ReadRequestBody()
+}
+
 // WriteRequest is the request object for RpcWrite.
 type WriteRequest struct {
 	InodeHandle
@@ -0,0 +1,306 @@
+package jrpcfs
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+	"unsafe"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+	"github.com/swiftstack/ProxyFS/stats"
+)
+
+// By default, both the JSON-RPC and fast I/O listeners accept plain TCP connections from anyone able to
+// reach PrivateIPAddr. The following optional JSONRPCServer settings tighten this:
+//
+//   TLSCertFile & TLSKeyFile - serve both listeners over TLS using this (PEM encoded) certificate & key
+//   TLSClientCAFile          - additionally require (mutual TLS) a client certificate signed by one of these CAs
+//   AuthTokenList            - require clients to present one of these shared secrets
+//
+// With AuthTokenList non-empty, a JSON-RPC connection may only issue RpcPing() and RpcMount() until an
+// RpcMount() supplies a valid AuthToken. A fast I/O connection must open with a handshake consisting of
+// a uint64 token length followed by the token itself. The server replies with a uint64 errno (zero on
+// success) and, on failure, closes the connection. A connection that presented a client certificate
+// verified against TLSClientCAFile (e.g. from the Swift Proxy middleware) is considered authenticated
+// without an AuthToken. AuthTokenList (unlike the TLS settings) may be changed via a SIGHUP triggered
+// confMap change.
+//
+// Failed TLS handshakes and failed token checks are counted in stats so that they may be alarmed on.
+
+const (
+	tlsHandshakeTimeout = 10 * time.Second
+	ioAuthTokenMaxLen   = uint64(4096)
+)
+
+func authUp(confMap conf.ConfMap) (err error) {
+	var (
+		certificate     tls.Certificate
+		clientCAFile    string
+		clientCAFileErr error
+		clientCAPEM     []byte
+		tlsCertFile     string
+		tlsCertFileErr  error
+		tlsKeyFile      string
+		tlsKeyFileErr   error
+	)
+
+	globals.tlsConfig = nil
+
+	tlsCertFile, tlsCertFileErr = confMap.FetchOptionValueString("JSONRPCServer", "TLSCertFile")
+	tlsKeyFile, tlsKeyFileErr = confMap.FetchOptionValueString("JSONRPCServer", "TLSKeyFile")
+	clientCAFile, clientCAFileErr = confMap.FetchOptionValueString("JSONRPCServer", "TLSClientCAFile")
+
+	if (nil == tlsCertFileErr) && ("" != tlsCertFile) {
+		if (nil != tlsKeyFileErr) || ("" == tlsKeyFile) {
+			err = fmt.Errorf("JSONRPCServer.TLSCertFile specified without JSONRPCServer.TLSKeyFile")
+			return
+		}
+
+		certificate, err = tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+		if nil != err {
+			err = fmt.Errorf("tls.LoadX509KeyPair(\"%s\", \"%s\") failed: %v", tlsCertFile, tlsKeyFile, err)
+			return
+		}
+
+		globals.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			MinVersion:   tls.VersionTLS12,
+		}
+
+		if (nil == clientCAFileErr) && ("" != clientCAFile) {
+			clientCAPEM, err = ioutil.ReadFile(clientCAFile)
+			if nil != err {
+				err = fmt.Errorf("ioutil.ReadFile(\"%s\") failed: %v", clientCAFile, err)
+				return
+			}
+
+			globals.tlsConfig.ClientCAs = x509.NewCertPool()
+			if !globals.tlsConfig.ClientCAs.AppendCertsFromPEM(clientCAPEM) {
+				err = fmt.Errorf("JSONRPCServer.TLSClientCAFile (\"%s\") contains no PEM encoded certificates", clientCAFile)
+				return
+			}
+			globals.tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else {
+		if (nil == clientCAFileErr) && ("" != clientCAFile) {
+			err = fmt.Errorf("JSONRPCServer.TLSClientCAFile specified without JSONRPCServer.TLSCertFile")
+			return
+		}
+	}
+
+	authTokensUpdate(confMap)
+
+	err = nil
+	return
+}
+
+// authTokensUpdate (re)loads AuthTokenList
+//
+// Note: Caller must either be Up() or hold globals.gate.Lock()
+func authTokensUpdate(confMap conf.ConfMap) {
+	var (
+		authToken     string
+		authTokenList []string
+		err           error
+	)
+
+	globals.authTokenList = make([][]byte, 0)
+
+	authTokenList, err = confMap.FetchOptionValueStringSlice("JSONRPCServer", "AuthTokenList")
+	if nil != err {
+		return
+	}
+
+	for _, authToken = range authTokenList {
+		if "" != authToken {
+			globals.authTokenList = append(globals.authTokenList, []byte(authToken))
+		}
+	}
+}
+
+// authRequired indicates whether or not clients must present an AuthToken
+//
+// Note: Caller must hold globals.gate.RLock()
+func authRequired() bool {
+	return 0 < len(globals.authTokenList)
+}
+
+// authTokenValid checks authToken against AuthTokenList (in constant time for each entry)
+//
+// Note: Caller must hold globals.gate.RLock()
+func authTokenValid(authToken []byte) (valid bool) {
+	if !authRequired() {
+		valid = true
+		return
+	}
+
+	valid = false
+
+	for _, validAuthToken := range globals.authTokenList {
+		if 1 == subtle.ConstantTimeCompare(authToken, validAuthToken) {
+			valid = true
+		}
+	}
+
+	if !valid {
+		stats.IncrementOperations(&stats.JrpcfsAuthFailedOps)
+	}
+
+	return
+}
+
+// authListen is net.Listen() wrapped (if configured) in TLS
+func authListen(ipAddr string, portString string) (listener net.Listener, err error) {
+	listener, err = net.Listen("tcp", net.JoinHostPort(ipAddr, portString))
+	if nil != err {
+		return
+	}
+
+	if nil != globals.tlsConfig {
+		listener = tls.NewListener(listener, globals.tlsConfig)
+	}
+
+	return
+}
+
+// authTLSHandshake completes the TLS handshake (if conn uses TLS) so that failures may be counted
+func authTLSHandshake(conn net.Conn) (err error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		err = nil
+		return
+	}
+
+	err = tlsConn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if nil != err {
+		return
+	}
+
+	err = tlsConn.Handshake()
+	if nil != err {
+		stats.IncrementOperations(&stats.JrpcfsTLSHandshakeFailedOps)
+		logger.WarnfWithError(err, "TLS handshake with %s failed", conn.RemoteAddr())
+		return
+	}
+
+	err = tlsConn.SetDeadline(time.Time{})
+
+	return
+}
+
+// authVerifiedClientCert indicates whether or not conn presented a client certificate verified against TLSClientCAFile
+func authVerifiedClientCert(conn net.Conn) bool {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return false
+	}
+
+	return 0 < len(tlsConn.ConnectionState().VerifiedChains)
+}
+
+// ioAuthHandshake conducts the fast I/O port's AuthToken handshake (if AuthTokenList is non-empty)
+func ioAuthHandshake(conn net.Conn) (err error) {
+	var (
+		authToken       []byte
+		authTokenLen    uint64
+		authTokenLenBuf [8]byte
+		errno           uint64
+	)
+
+	globals.gate.RLock()
+	required := authRequired()
+	globals.gate.RUnlock()
+
+	if !required || authVerifiedClientCert(conn) {
+		err = nil
+		return
+	}
+
+	_, err = io.ReadFull(conn, authTokenLenBuf[:])
+	if nil != err {
+		return
+	}
+	authTokenLen = *(*uint64)(unsafe.Pointer(&authTokenLenBuf[0]))
+
+	if ioAuthTokenMaxLen < authTokenLen {
+		stats.IncrementOperations(&stats.JrpcfsAuthFailedOps)
+		err = fmt.Errorf("ioAuthHandshake: token length (%v) from %s exceeds %v", authTokenLen, conn.RemoteAddr(), ioAuthTokenMaxLen)
+		err = blunder.AddError(err, blunder.NotPermError)
+		errno = uint64(blunder.Errno(err))
+		_ = putResponseWrite(conn, makeBytesUint64(errno))
+		return
+	}
+
+	authToken = make([]byte, authTokenLen)
+
+	_, err = io.ReadFull(conn, authToken)
+	if nil != err {
+		return
+	}
+
+	globals.gate.RLock()
+	valid := authTokenValid(authToken)
+	globals.gate.RUnlock()
+
+	if valid {
+		err = nil
+	} else {
+		err = fmt.Errorf("ioAuthHandshake: invalid token from %s", conn.RemoteAddr())
+		err = blunder.AddError(err, blunder.NotPermError)
+	}
+
+	errno = uint64(blunder.Errno(err))
+
+	writeErr := putResponseWrite(conn, makeBytesUint64(errno))
+	if nil == err {
+		err = writeErr
+	}
+
+	return
+}
+
+func (mountRequest *MountRequest) setConnection(connection *connectionStruct) {
+	mountRequest.connection = connection
+}
+
+// authenticate records that an RpcMount() over connection supplied a valid AuthToken
+func (connection *connectionStruct) authenticate() {
+	connection.Lock()
+	connection.authenticated = true
+	connection.Unlock()
+}
+
+// authCheckRequest rejects all but RpcPing() and RpcMount() on a connection not yet authenticated
+func (connection *connectionStruct) authCheckRequest(serviceMethod string) (err error) {
+	globals.gate.RLock()
+	required := authRequired()
+	globals.gate.RUnlock()
+
+	if !required || ("Server.RpcPing" == serviceMethod) || ("Server.RpcMount" == serviceMethod) {
+		err = nil
+		return
+	}
+
+	connection.Lock()
+	authenticated := connection.authenticated
+	connection.Unlock()
+
+	if authenticated {
+		err = nil
+		return
+	}
+
+	stats.IncrementOperations(&stats.JrpcfsAuthFailedOps)
+
+	err = blunder.NewError(blunder.NotPermError, "%s requires prior RpcMount() with a valid AuthToken", serviceMethod)
+	rpcEncodeError(&err)
+
+	return
+}
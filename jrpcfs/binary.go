@@ -0,0 +1,218 @@
+package jrpcfs
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"unsafe"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// JSON encoding & decoding of large replies (e.g. ReaddirPlusReply and GetContainerReply) is costly. If
+// JSONRPCServer.BinaryTCPPort is specified, an alternate listener serves the same Server methods using
+// a binary encoding. A connection to it opens with a negotiation consisting of a uint64 length followed
+// by the name of the requested encoding. The server replies with a uint64 errno (zero if the encoding
+// is supported) and, on failure, closes the connection.
+//
+// The only encoding currently supported is "gob", using the framing of the standard net/rpc package
+// (each gob message is length prefixed). Hence, once negotiated, a Go client may simply use
+// rpc.NewClient(conn). Note that gob is unable to convey a struct with no exported fields, so methods
+// with such a request or reply (e.g. RpcCreateContainer and RpcDelete) remain JSON-RPC only.
+//
+// TLS and AuthToken requirements (see auth.go) apply to this listener just as they do to TCPPort.
+
+const (
+	binaryEncodingGob        = "gob"
+	binaryEncodingNameMaxLen = uint64(64)
+)
+
+var binaryListener net.Listener
+
+// gobServerCodecStruct mirrors the (unexported) rpc.ServerCodec used by rpc.ServeConn()
+type gobServerCodecStruct struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func binaryServerUp(ipAddr string, binaryPortString string) {
+	var err error
+
+	if "" == binaryPortString {
+		return
+	}
+
+	binaryListener, err = authListen(ipAddr, binaryPortString)
+	if err != nil {
+		logger.ErrorfWithError(err, "net.Listen %s:%s failed", ipAddr, binaryPortString)
+		return
+	}
+
+	globals.connLock.Lock()
+	globals.listeners = append(globals.listeners, binaryListener)
+	globals.connLock.Unlock()
+
+	globals.listenersWG.Add(1)
+	go binaryServerLoop()
+}
+
+func binaryServerLoop() {
+	for {
+		conn, err := binaryListener.Accept()
+		if err != nil {
+			if !globals.halting {
+				logger.ErrorfWithError(err, "net.Accept failed for binary RPC listener\n")
+			}
+			globals.listenersWG.Done()
+			return
+		}
+
+		globals.connWG.Add(1)
+
+		globals.connLock.Lock()
+		elm := globals.connections.PushBack(conn)
+		globals.connLock.Unlock()
+
+		go func() {
+			var (
+				serverCodec rpc.ServerCodec
+			)
+
+			err := authTLSHandshake(conn)
+			if nil == err {
+				serverCodec, err = binaryNegotiate(conn)
+			}
+			if nil == err {
+				connection := newConnection(conn)
+				srv.ServeCodec(&connectionServerCodecStruct{ServerCodec: serverCodec, connection: connection})
+				connection.release()
+			} else {
+				conn.Close()
+			}
+			globals.connLock.Lock()
+			globals.connections.Remove(elm)
+			globals.connLock.Unlock()
+			globals.connWG.Done()
+		}()
+	}
+}
+
+// binaryNegotiate conducts the encoding negotiation opening each connection to the binary RPC listener
+func binaryNegotiate(conn net.Conn) (serverCodec rpc.ServerCodec, err error) {
+	var (
+		encodingName       []byte
+		encodingNameLen    uint64
+		encodingNameLenBuf [8]byte
+		errno              uint64
+		writeErr           error
+	)
+
+	_, err = io.ReadFull(conn, encodingNameLenBuf[:])
+	if nil != err {
+		return
+	}
+	encodingNameLen = *(*uint64)(unsafe.Pointer(&encodingNameLenBuf[0]))
+
+	if binaryEncodingNameMaxLen < encodingNameLen {
+		err = fmt.Errorf("binaryNegotiate: encoding name length (%v) from %s exceeds %v", encodingNameLen, conn.RemoteAddr(), binaryEncodingNameMaxLen)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		errno = uint64(blunder.Errno(err))
+		_ = putResponseWrite(conn, makeBytesUint64(errno))
+		return
+	}
+
+	encodingName = make([]byte, encodingNameLen)
+
+	_, err = io.ReadFull(conn, encodingName)
+	if nil != err {
+		return
+	}
+
+	switch string(encodingName) {
+	case binaryEncodingGob:
+		serverCodec = newGobServerCodec(conn)
+		errno = 0
+	default:
+		err = fmt.Errorf("binaryNegotiate: encoding \"%s\" requested by %s not supported", string(encodingName), conn.RemoteAddr())
+		err = blunder.AddError(err, blunder.NotSupportedError)
+		errno = uint64(blunder.Errno(err))
+	}
+
+	writeErr = putResponseWrite(conn, makeBytesUint64(errno))
+	if nil == err {
+		err = writeErr
+	}
+
+	return
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) (serverCodec *gobServerCodecStruct) {
+	encBuf := bufio.NewWriter(conn)
+
+	serverCodec = &gobServerCodecStruct{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+		closed: false,
+	}
+
+	return
+}
+
+func (serverCodec *gobServerCodecStruct) ReadRequestHeader(r *rpc.Request) (err error) {
+	err = serverCodec.dec.Decode(r)
+	return
+}
+
+func (serverCodec *gobServerCodecStruct) ReadRequestBody(body interface{}) (err error) {
+	err = serverCodec.dec.Decode(body)
+	return
+}
+
+func (serverCodec *gobServerCodecStruct) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	err = serverCodec.enc.Encode(r)
+	if nil != err {
+		if nil == serverCodec.encBuf.Flush() {
+			// gob couldn't encode the header... should not happen, so if it does, shut down the connection
+			logger.ErrorfWithError(err, "gob encoding of %s response header failed", r.ServiceMethod)
+			_ = serverCodec.Close()
+		}
+		return
+	}
+
+	err = serverCodec.enc.Encode(body)
+	if nil != err {
+		if nil == serverCodec.encBuf.Flush() {
+			// Was a gob problem encoding the body but the header has been written... shut down the connection to signal that it is broken
+			logger.ErrorfWithError(err, "gob encoding of %s response body failed", r.ServiceMethod)
+			_ = serverCodec.Close()
+		}
+		return
+	}
+
+	err = serverCodec.encBuf.Flush()
+
+	return
+}
+
+func (serverCodec *gobServerCodecStruct) Close() (err error) {
+	if serverCodec.closed {
+		// Only call serverCodec.rwc.Close() once; otherwise the semantics are undefined
+		err = nil
+		return
+	}
+
+	serverCodec.closed = true
+
+	err = serverCodec.rwc.Close()
+
+	return
+}
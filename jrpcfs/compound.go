@@ -0,0 +1,324 @@
+package jrpcfs
+
+import (
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// RpcCompound executes a sequence of metadata operations (expressed using the request objects of the
+// corresponding single operation RPCs) in a single round trip. This greatly benefits workloads (e.g.
+// untar or git checkout via Samba) dominated by small metadata operations.
+//
+// Each step is performed exactly as its single operation RPC would (see compoundStep()). As globals.gate.RLock()
+// is held for the entire sequence, a SIGHUP-triggered confMap change cannot take effect part way through it...
+// except while a step recalling conflicting leases releases it during the wait, just as the single operation
+// RPC would. Steps are not atomic... a failing step does not undo the steps before it.
+//
+// Since the inode created (or found) by one step is frequently the subject of the next (e.g. Create
+// followed by Setstat & SetXAttr), a step may refer to the InodeNumber resulting from an earlier step
+// rather than supplying an InodeNumber itself (see CompoundStep).
+
+const compoundMaxSteps = 1024 // Bounds the size of (and time taken by) a single RpcCompound()
+
+func (s *Server) RpcCompound(in *CompoundRequest, reply *CompoundReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	if compoundMaxSteps < len(in.Steps) {
+		err = blunder.NewError(blunder.InvalidArgError, "RpcCompound() limited to %v steps (%v requested)", compoundMaxSteps, len(in.Steps))
+		return
+	}
+
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	_, err = lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	reply.StepReplies = make([]CompoundStepReply, 0, len(in.Steps))
+
+	for stepIndex := range in.Steps {
+		stepReply := CompoundStepReply{}
+
+		stepErr := in.resolveStep(stepIndex, reply.StepReplies)
+		if nil == stepErr {
+			stepErr = compoundStep(in.connection, in.MountID, &in.Steps[stepIndex], &stepReply)
+		}
+
+		if nil != stepErr {
+			stepReply.Errno = uint64(blunder.Errno(stepErr))
+			logger.DebugfIDWithError(internalDebug, stepErr, "RpcCompound() step %v failed", stepIndex+1)
+		}
+
+		reply.StepReplies = append(reply.StepReplies, stepReply)
+
+		if (nil != stepErr) && !in.ContinueOnError {
+			break
+		}
+	}
+
+	err = nil
+	return
+}
+
+// requestFields returns the number of request pointers set in step along with the (last) set request
+// and pointers to its fields that are overridden by RpcCompound()
+func (step *CompoundStep) requestFields() (numRequests int, request connectionRequest, mountID *uint64, inodeNumber *uint64, targetInodeNumber *uint64) {
+	numRequests = 0
+
+	if nil != step.Chmod {
+		numRequests++
+		request = step.Chmod
+		mountID, inodeNumber = &step.Chmod.MountID, &step.Chmod.InodeNumber
+	}
+	if nil != step.Chown {
+		numRequests++
+		request = step.Chown
+		mountID, inodeNumber = &step.Chown.MountID, &step.Chown.InodeNumber
+	}
+	if nil != step.Create {
+		numRequests++
+		request = step.Create
+		mountID, inodeNumber = &step.Create.MountID, &step.Create.InodeNumber
+	}
+	if nil != step.GetStat {
+		numRequests++
+		request = step.GetStat
+		mountID, inodeNumber = &step.GetStat.MountID, &step.GetStat.InodeNumber
+	}
+	if nil != step.GetXAttr {
+		numRequests++
+		request = step.GetXAttr
+		mountID, inodeNumber = &step.GetXAttr.MountID, &step.GetXAttr.InodeNumber
+	}
+	if nil != step.Link {
+		numRequests++
+		request = step.Link
+		mountID, inodeNumber, targetInodeNumber = &step.Link.MountID, &step.Link.InodeNumber, &step.Link.TargetInodeNumber
+	}
+	if nil != step.ListXAttr {
+		numRequests++
+		request = step.ListXAttr
+		mountID, inodeNumber = &step.ListXAttr.MountID, &step.ListXAttr.InodeNumber
+	}
+	if nil != step.Lookup {
+		numRequests++
+		request = step.Lookup
+		mountID, inodeNumber = &step.Lookup.MountID, &step.Lookup.InodeNumber
+	}
+	if nil != step.Mkdir {
+		numRequests++
+		request = step.Mkdir
+		mountID, inodeNumber = &step.Mkdir.MountID, &step.Mkdir.InodeNumber
+	}
+	if nil != step.ReadSymlink {
+		numRequests++
+		request = step.ReadSymlink
+		mountID, inodeNumber = &step.ReadSymlink.MountID, &step.ReadSymlink.InodeNumber
+	}
+	if nil != step.RemoveXAttr {
+		numRequests++
+		request = step.RemoveXAttr
+		mountID, inodeNumber = &step.RemoveXAttr.MountID, &step.RemoveXAttr.InodeNumber
+	}
+	if nil != step.Rename {
+		numRequests++
+		request = step.Rename
+		mountID, inodeNumber, targetInodeNumber = &step.Rename.MountID, &step.Rename.SrcDirInodeNumber, &step.Rename.DstDirInodeNumber
+	}
+	if nil != step.Resize {
+		numRequests++
+		request = step.Resize
+		mountID, inodeNumber = &step.Resize.MountID, &step.Resize.InodeNumber
+	}
+	if nil != step.Rmdir {
+		numRequests++
+		request = step.Rmdir
+		mountID, inodeNumber = &step.Rmdir.MountID, &step.Rmdir.InodeNumber
+	}
+	if nil != step.Setstat {
+		numRequests++
+		request = step.Setstat
+		mountID, inodeNumber = &step.Setstat.MountID, &step.Setstat.InodeNumber
+	}
+	if nil != step.SetTime {
+		numRequests++
+		request = step.SetTime
+		mountID, inodeNumber = &step.SetTime.MountID, &step.SetTime.InodeNumber
+	}
+	if nil != step.SetXAttr {
+		numRequests++
+		request = step.SetXAttr
+		mountID, inodeNumber = &step.SetXAttr.MountID, &step.SetXAttr.InodeNumber
+	}
+	if nil != step.Symlink {
+		numRequests++
+		request = step.Symlink
+		mountID, inodeNumber = &step.Symlink.MountID, &step.Symlink.InodeNumber
+	}
+	if nil != step.Type {
+		numRequests++
+		request = step.Type
+		mountID, inodeNumber = &step.Type.MountID, &step.Type.InodeNumber
+	}
+	if nil != step.Unlink {
+		numRequests++
+		request = step.Unlink
+		mountID, inodeNumber = &step.Unlink.MountID, &step.Unlink.InodeNumber
+	}
+
+	return
+}
+
+// resolveStep validates in.Steps[stepIndex] and fills in its MountID (and connection) and any InodeNumbers
+// referencing the results of earlier steps (as recorded in stepReplies)
+func (in *CompoundRequest) resolveStep(stepIndex int, stepReplies []CompoundStepReply) (err error) {
+	step := &in.Steps[stepIndex]
+
+	numRequests, request, mountID, inodeNumber, targetInodeNumber := step.requestFields()
+	if 1 != numRequests {
+		err = blunder.NewError(blunder.InvalidArgError, "RpcCompound() step %v specifies %v requests (must be exactly 1)", stepIndex+1, numRequests)
+		return
+	}
+
+	*mountID = in.MountID
+	request.setConnection(in.connection)
+
+	if 0 != step.InodeNumberFromStep {
+		*inodeNumber, err = compoundStepResult(stepIndex, step.InodeNumberFromStep, stepReplies)
+		if nil != err {
+			return
+		}
+	}
+
+	if 0 != step.TargetInodeNumberFromStep {
+		if nil == targetInodeNumber {
+			err = blunder.NewError(blunder.InvalidArgError, "RpcCompound() step %v has no target InodeNumber to replace", stepIndex+1)
+			return
+		}
+		*targetInodeNumber, err = compoundStepResult(stepIndex, step.TargetInodeNumberFromStep, stepReplies)
+		if nil != err {
+			return
+		}
+	}
+
+	err = nil
+	return
+}
+
+// compoundStepResult returns the InodeNumber resulting from (successful, earlier) step fromStep (numbered from 1)
+func compoundStepResult(stepIndex int, fromStep uint64, stepReplies []CompoundStepReply) (inodeNumber uint64, err error) {
+	if fromStep > uint64(stepIndex) {
+		err = blunder.NewError(blunder.InvalidArgError, "RpcCompound() step %v may only refer to an earlier step (not step %v)", stepIndex+1, fromStep)
+		return
+	}
+
+	fromStepReply := stepReplies[fromStep-1]
+
+	if 0 != fromStepReply.Errno {
+		err = blunder.NewError(blunder.InvalidArgError, "RpcCompound() step %v refers to failed step %v", stepIndex+1, fromStep)
+		return
+	}
+
+	inodeNumber = fromStepReply.InodeNumber
+	err = nil
+	return
+}
+
+// compoundStep performs a single (resolved) step via the same perform method (of the step's request
+// type) as its corresponding single operation RPC... including the recall of conflicting leases. As an
+// earlier step may have released globals.gate while awaiting such a recall, the mount is looked up anew.
+//
+// Note: Caller must hold globals.gate.RLock()
+func compoundStep(connection *connectionStruct, mountID uint64, step *CompoundStep, stepReply *CompoundStepReply) (err error) {
+	var (
+		ino         inode.InodeNumber
+		inodeReply  InodeReply
+		mountHandle fs.MountHandle
+		statStruct  *StatStruct
+	)
+
+	mountHandle, err = lookupMountHandle(connection, mountID)
+	if nil != err {
+		return
+	}
+
+	switch {
+	case nil != step.Chmod:
+		stepReply.InodeNumber = step.Chmod.InodeNumber
+		err = step.Chmod.perform(mountHandle)
+	case nil != step.Chown:
+		stepReply.InodeNumber = step.Chown.InodeNumber
+		err = step.Chown.perform(mountHandle)
+	case nil != step.Create:
+		err = step.Create.perform(mountHandle, &inodeReply)
+		stepReply.InodeNumber = inodeReply.InodeNumber
+	case nil != step.GetStat:
+		statStruct = &StatStruct{}
+		err = step.GetStat.perform(mountHandle, statStruct)
+		if nil == err {
+			stepReply.GetStat = statStruct
+			stepReply.InodeNumber = statStruct.StatInodeNumber
+		}
+	case nil != step.GetXAttr:
+		stepReply.InodeNumber = step.GetXAttr.InodeNumber
+		stepReply.GetXAttr = &GetXAttrReply{}
+		err = step.GetXAttr.perform(mountHandle, stepReply.GetXAttr)
+		stepReply.GetXAttr.AttrValueSize = uint64(len(stepReply.GetXAttr.AttrValue))
+	case nil != step.Link:
+		stepReply.InodeNumber = step.Link.InodeNumber
+		err = step.Link.perform(mountHandle)
+	case nil != step.ListXAttr:
+		stepReply.InodeNumber = step.ListXAttr.InodeNumber
+		stepReply.ListXAttr = &ListXAttrReply{}
+		err = step.ListXAttr.perform(mountHandle, stepReply.ListXAttr)
+	case nil != step.Lookup:
+		err = step.Lookup.perform(mountHandle, &inodeReply)
+		stepReply.InodeNumber = inodeReply.InodeNumber
+	case nil != step.Mkdir:
+		err = step.Mkdir.perform(mountHandle, &inodeReply)
+		stepReply.InodeNumber = inodeReply.InodeNumber
+	case nil != step.ReadSymlink:
+		stepReply.InodeNumber = step.ReadSymlink.InodeNumber
+		stepReply.ReadSymlink = &ReadSymlinkReply{}
+		err = step.ReadSymlink.perform(mountHandle, stepReply.ReadSymlink)
+	case nil != step.RemoveXAttr:
+		stepReply.InodeNumber = step.RemoveXAttr.InodeNumber
+		err = step.RemoveXAttr.perform(mountHandle)
+	case nil != step.Rename:
+		stepReply.InodeNumber = step.Rename.SrcDirInodeNumber
+		err = step.Rename.perform(mountHandle)
+	case nil != step.Resize:
+		stepReply.InodeNumber = step.Resize.InodeNumber
+		err = step.Resize.perform(mountHandle)
+	case nil != step.Rmdir:
+		stepReply.InodeNumber = step.Rmdir.InodeNumber
+		err = step.Rmdir.performRmdir(mountHandle)
+	case nil != step.Setstat:
+		stepReply.InodeNumber = step.Setstat.InodeNumber
+		err = step.Setstat.perform(mountHandle)
+	case nil != step.SetTime:
+		stepReply.InodeNumber = step.SetTime.InodeNumber
+		err = step.SetTime.perform(mountHandle)
+	case nil != step.SetXAttr:
+		stepReply.InodeNumber = step.SetXAttr.InodeNumber
+		err = step.SetXAttr.perform(mountHandle)
+	case nil != step.Symlink:
+		ino, err = step.Symlink.perform(mountHandle)
+		stepReply.InodeNumber = uint64(ino)
+	case nil != step.Type:
+		stepReply.InodeNumber = step.Type.InodeNumber
+		stepReply.Type = &TypeReply{}
+		err = step.Type.perform(mountHandle, stepReply.Type)
+	case nil != step.Unlink:
+		stepReply.InodeNumber = step.Unlink.InodeNumber
+		err = step.Unlink.performUnlink(mountHandle)
+	}
+
+	return
+}
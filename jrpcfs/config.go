@@ -2,16 +2,35 @@ package jrpcfs
 
 import (
 	"container/list"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/swiftstack/ProxyFS/conf"
 	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
 	"github.com/swiftstack/ProxyFS/utils"
 )
 
+// mountStruct binds the credentials supplied to RpcMount() to the resulting MountID. These are
+// enforced on data path requests (i.e. RpcRead(), RpcWrite(), and the fast I/O port's ReadOp
+// and WriteOp) that otherwise carry no credentials of their own. Hence, a MountID may only be used
+// over the connection RpcMount() returned it on (or, on the fast I/O port, from that connection's host).
+//
+// The remaining fields track the mount's lifecycle (see mount.go).
+type mountStruct struct {
+	mountHandle      fs.MountHandle
+	userID           inode.InodeUserID
+	groupID          inode.InodeGroupID
+	connection       *connectionStruct // Connection over which RpcMount() was called (nil if none)
+	clientAddr       string
+	createTime       time.Time
+	lastActivityTime time.Time // Synchronized via globals.Lock()
+}
+
 type globalsStruct struct {
 	sync.Mutex
 
@@ -19,23 +38,31 @@ type globalsStruct struct {
 	//                   API Requests RLock()/RUnlock
 	//                   SIGHUP confMap changes Lock()/Unlock()
 
-	whoAmI          string
-	ipAddr          string
-	portString      string
-	fastPortString  string
-	dataPathLogging bool
+	whoAmI           string
+	ipAddr           string
+	portString       string
+	fastPortString   string
+	binaryPortString string // "" if binary RPC listener (see binary.go) not enabled
+	dataPathLogging  bool
 
 	// Map used to enumerate volumes served by this peer
 	volumeMap map[string]bool // key == volumeName; value is ignored
 
-	// Map used to store volumes already mounted for bimodal support
-	// TODO: These never get purged !!!
-	mountIDMap  map[uint64]fs.MountHandle
-	lastMountID uint64
+	// Map used to store MountIDs returned by RpcMount()... purged by RpcUnmount(), when the connection
+	// over which RpcMount() was called closes, or (if MountIdleTimeout is non-zero) once idle that long
+	mountIDMap map[uint64]*mountStruct // key == MountID (random... see allocateMountID())
+
+	mountIdleTimeout      time.Duration
+	mountReaperStopChan   chan struct{}
+	mountReaperDoneWaiter sync.WaitGroup
 
-	// Map used to store volumes already mounted for bimodal support
+	// Map used to store volumes already mounted for bimodal support (one per volume... purged should it be removed)
 	bimodalMountMap map[string]fs.MountHandle
 
+	// Optional TLS and AuthToken settings (see auth.go)
+	tlsConfig     *tls.Config // nil if TLS not enabled
+	authTokenList [][]byte    // empty if AuthToken not required
+
 	// Connection list and listener list to close during shutdown:
 	halting     bool
 	connLock    sync.Mutex
@@ -56,8 +83,7 @@ func Up(confMap conf.ConfMap) (err error) {
 		volumeName      string
 	)
 
-	globals.mountIDMap = make(map[uint64]fs.MountHandle)
-	globals.lastMountID = uint64(0) // The only invalid MountID
+	globals.mountIDMap = make(map[uint64]*mountStruct)
 
 	globals.bimodalMountMap = make(map[string]fs.MountHandle)
 
@@ -87,6 +113,12 @@ func Up(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	// Fetch (optional) binaryPort number from config file
+	globals.binaryPortString, err = confMap.FetchOptionValueString("JSONRPCServer", "BinaryTCPPort")
+	if nil != err {
+		globals.binaryPortString = "" // Binary RPC listener not enabled
+	}
+
 	// Set data path logging level to true, so that all trace logging is controlled by settings
 	// in the logger package. To enable jrpcfs trace logging, set Logging.TraceLevelLogging to jrpcfs.
 	// This will enable all jrpcfs trace logs, including those formerly controled by globals.dataPathLogging.
@@ -125,7 +157,15 @@ func Up(confMap conf.ConfMap) (err error) {
 		}
 	}
 
-	globals.listeners = make([]net.Listener, 0, 2)
+	err = authUp(confMap)
+	if nil != err {
+		logger.ErrorfWithError(err, "failed to configure JSONRPCServer TLS/AuthToken settings")
+		return
+	}
+
+	mountReaperUp(confMap)
+
+	globals.listeners = make([]net.Listener, 0, 3)
 	globals.connections = list.New()
 
 	// Init JSON RPC server stuff
@@ -134,15 +174,19 @@ func Up(confMap conf.ConfMap) (err error) {
 	// Now kick off our other, faster RPC server
 	ioServerUp(globals.ipAddr, globals.fastPortString)
 
+	// ...and, optionally, the binary encoded variant of the JSON RPC server
+	binaryServerUp(globals.ipAddr, globals.binaryPortString)
+
 	return
 }
 
 func PauseAndContract(confMap conf.ConfMap) (err error) {
 	var (
+		binaryPortString   string
 		dataPathLogging    bool
 		fastPortString     string
 		ipAddr             string
-		mountHandle        fs.MountHandle
+		mount              *mountStruct
 		mountID            uint64
 		ok                 bool
 		portString         string
@@ -195,6 +239,15 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 		return
 	}
 
+	binaryPortString, err = confMap.FetchOptionValueString("JSONRPCServer", "BinaryTCPPort")
+	if nil != err {
+		binaryPortString = ""
+	}
+	if binaryPortString != globals.binaryPortString {
+		err = fmt.Errorf("confMap change not allowed to alter [JSONRPCServer]BinaryTCPPort")
+		return
+	}
+
 	dataPathLogging, err = confMap.FetchOptionValueBool("JSONRPCServer", "DataPathLogging")
 	if nil != err {
 		err = fmt.Errorf("confMap.FetchOptionValueString(\"JSONRPCServer\", \"DataPathLogging\") failed: %v", err)
@@ -241,8 +294,8 @@ func PauseAndContract(confMap conf.ConfMap) (err error) {
 		_, ok = updatedVolumeMap[volumeName]
 		if !ok {
 			removedVolumeList = append(removedVolumeList, volumeName)
-			for mountID, mountHandle = range globals.mountIDMap {
-				if mountHandle.VolumeName() == volumeName {
+			for mountID, mount = range globals.mountIDMap {
+				if mount.mountHandle.VolumeName() == volumeName {
 					removedMountIDList = append(removedMountIDList, mountID)
 				}
 			}
@@ -302,6 +355,8 @@ func ExpandAndResume(confMap conf.ConfMap) (err error) {
 
 	globals.volumeMap = updatedVolumeMap
 
+	authTokensUpdate(confMap)
+
 	globals.gate.Unlock()
 
 	err = nil
@@ -314,6 +369,7 @@ func Down() (err error) {
 
 	jsonRpcServerDown()
 	ioServerDown()
+	mountReaperDown()
 
 	// Close the listeners first, so that there are no new connections.
 	globals.connLock.Lock()
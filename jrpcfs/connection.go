@@ -0,0 +1,153 @@
+package jrpcfs
+
+import (
+	"net"
+	"net/rpc"
+	"sync"
+)
+
+// connectionStruct tracks the state of a JSON-RPC connection needed to release the POSIX byte-range
+// locks and inode leases obtained over it once it goes away.
+//
+// Locks are owned by (MountID, ClientID, Pid) where ClientID is the connection's remote address. Hence,
+// closing one connection releases only the locks obtained over it (even if other connections from the
+// same client host share the MountID). Locks also record the ClientHost such that, should the volume
+// have FlockPersistence enabled, they may be reclaimed over a new connection after a failover.
+type connectionStruct struct {
+	sync.Mutex
+	clientID        string
+	clientHost      string
+	remoteAddr      string
+	authenticated   bool                // Set once an RpcMount() over this connection supplied a valid AuthToken
+	flockMountIDSet map[uint64]struct{} // MountIDs for which RpcFlock() has been called over this connection
+	leaseMountIDSet map[uint64]struct{} // MountIDs for which RpcAcquireInodeLease() has been called over this connection
+	mountIDSet      map[uint64]struct{} // MountIDs returned by RpcMount() over this connection
+}
+
+// connectionRequest is implemented by requests needing to know which connection they arrived on
+type connectionRequest interface {
+	setConnection(connection *connectionStruct)
+}
+
+// Each request carrying a MountID (including those embedding an InodeHandle or PathHandle) is a
+// connectionRequest so that lookupMount() may confirm the MountID was returned over the same connection
+
+func (inodeHandle *InodeHandle) setConnection(connection *connectionStruct) {
+	inodeHandle.connection = connection
+}
+
+func (pathHandle *PathHandle) setConnection(connection *connectionStruct) {
+	pathHandle.connection = connection
+}
+
+func (compoundRequest *CompoundRequest) setConnection(connection *connectionStruct) {
+	compoundRequest.connection = connection
+}
+
+func (fetchInodeLeaseRecallsRequest *FetchInodeLeaseRecallsRequest) setConnection(connection *connectionStruct) {
+	fetchInodeLeaseRecallsRequest.connection = connection
+}
+
+func (fetchJournalRequest *FetchJournalRequest) setConnection(connection *connectionStruct) {
+	fetchJournalRequest.connection = connection
+}
+
+func (fetchQuotaReportRequest *FetchQuotaReportRequest) setConnection(connection *connectionStruct) {
+	fetchQuotaReportRequest.connection = connection
+}
+
+func (fetchTrashRequest *FetchTrashRequest) setConnection(connection *connectionStruct) {
+	fetchTrashRequest.connection = connection
+}
+
+func (fetchWatchEventsRequest *FetchWatchEventsRequest) setConnection(connection *connectionStruct) {
+	fetchWatchEventsRequest.connection = connection
+}
+
+func (lookupPathRequest *LookupPathRequest) setConnection(connection *connectionStruct) {
+	lookupPathRequest.connection = connection
+}
+
+func (renameRequest *RenameRequest) setConnection(connection *connectionStruct) {
+	renameRequest.connection = connection
+}
+
+func (restoreTrashRequest *RestoreTrashRequest) setConnection(connection *connectionStruct) {
+	restoreTrashRequest.connection = connection
+}
+
+func (statVFSRequest *StatVFSRequest) setConnection(connection *connectionStruct) {
+	statVFSRequest.connection = connection
+}
+
+func (unmountRequest *UnmountRequest) setConnection(connection *connectionStruct) {
+	unmountRequest.connection = connection
+}
+
+func (watchRemoveRequest *WatchRemoveRequest) setConnection(connection *connectionStruct) {
+	watchRemoveRequest.connection = connection
+}
+
+// connectionServerCodecStruct wraps a connection's rpc.ServerCodec to hand each connectionRequest its connectionStruct
+type connectionServerCodecStruct struct {
+	rpc.ServerCodec
+	connection    *connectionStruct
+	serviceMethod string // Of the request whose body is next to be read
+}
+
+func newConnection(conn net.Conn) (connection *connectionStruct) {
+	clientHost, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if nil != err {
+		clientHost = conn.RemoteAddr().String()
+	}
+
+	connection = &connectionStruct{
+		clientID:        conn.RemoteAddr().String(),
+		clientHost:      clientHost,
+		remoteAddr:      conn.RemoteAddr().String(),
+		authenticated:   authVerifiedClientCert(conn),
+		flockMountIDSet: make(map[uint64]struct{}),
+		leaseMountIDSet: make(map[uint64]struct{}),
+		mountIDSet:      make(map[uint64]struct{}),
+	}
+
+	return
+}
+
+func (codec *connectionServerCodecStruct) ReadRequestHeader(r *rpc.Request) (err error) {
+	err = codec.ServerCodec.ReadRequestHeader(r)
+	if nil == err {
+		codec.serviceMethod = r.ServiceMethod
+	} else {
+		codec.serviceMethod = ""
+	}
+	return
+}
+
+func (codec *connectionServerCodecStruct) ReadRequestBody(body interface{}) (err error) {
+	err = codec.ServerCodec.ReadRequestBody(body)
+	if nil == err {
+		err = codec.connection.authCheckRequest(codec.serviceMethod)
+		if nil != err {
+			return
+		}
+		request, ok := body.(connectionRequest)
+		if ok {
+			request.setConnection(codec.connection)
+		}
+	}
+	return
+}
+
+// release drops everything obtained over the now closed connection
+func (connection *connectionStruct) release() {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	connection.Lock()
+	defer connection.Unlock()
+
+	connection.releaseFlocksWhileLocked()
+	connection.releaseInodeLeasesWhileLocked()
+	connection.releaseMountsWhileLocked()
+}
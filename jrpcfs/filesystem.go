@@ -2,16 +2,21 @@
 package jrpcfs
 
 import (
+	cryptoRand "crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"path/filepath"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/fs"
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
@@ -37,7 +42,7 @@ func jsonRpcServerUp(ipAddr string, portString string) {
 		return
 	}
 
-	jrpcListener, err = net.Listen("tcp", net.JoinHostPort(ipAddr, portString))
+	jrpcListener, err = authListen(ipAddr, portString)
 	if err != nil {
 		logger.ErrorfWithError(err, "net.Listen %s:%s failed", ipAddr, portString)
 		return
@@ -70,7 +75,13 @@ func jrpcServerLoop() {
 		globals.connLock.Unlock()
 
 		go func() {
-			srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+			if nil == authTLSHandshake(conn) {
+				connection := newConnection(conn)
+				srv.ServeCodec(&connectionServerCodecStruct{ServerCodec: jsonrpc.NewServerCodec(conn), connection: connection})
+				connection.release()
+			} else {
+				conn.Close()
+			}
 			globals.connLock.Lock()
 			globals.connections.Remove(elm)
 			globals.connLock.Unlock()
@@ -193,20 +204,127 @@ var saveChannelSize int = 1000
 // Default values here are false
 var loggedOutOfStatsRoom map[OpType]bool = make(map[OpType]bool)
 
-func allocateMountID(mountHandle fs.MountHandle) (mountID uint64) {
+// allocateMountID binds mountHandle and the credentials supplied to RpcMount() to a new MountID. As
+// possession of a MountID confers its credentials, MountIDs are random (rather than sequential) such
+// that another client cannot simply guess them.
+func allocateMountID(mountHandle fs.MountHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, connection *connectionStruct) (mountID uint64) {
+	var (
+		alreadyAllocated bool
+		err              error
+		mountIDBuf       [8]byte
+	)
+
+	mount := &mountStruct{
+		mountHandle: mountHandle,
+		userID:      userID,
+		groupID:     groupID,
+		connection:  connection,
+		createTime:  time.Now(),
+	}
+	mount.lastActivityTime = mount.createTime
+	if nil != connection {
+		mount.clientAddr = connection.remoteAddr
+	}
+
 	globals.Lock()
-	globals.lastMountID++
-	mountID = globals.lastMountID
-	globals.mountIDMap[mountID] = mountHandle
+	for {
+		_, err = cryptoRand.Read(mountIDBuf[:])
+		if nil != err {
+			logger.Fatalf("crypto/rand.Read() failed: %v", err)
+		}
+		mountID = binary.LittleEndian.Uint64(mountIDBuf[:])
+		_, alreadyAllocated = globals.mountIDMap[mountID]
+		if (0 != mountID) && !alreadyAllocated { // 0 is never a valid MountID
+			break
+		}
+	}
+	globals.mountIDMap[mountID] = mount
 	globals.Unlock()
+
+	connection.trackMount(mountID)
+
+	return
+}
+
+func lookupMountHandle(connection *connectionStruct, mountID uint64) (mountHandle fs.MountHandle, err error) {
+	mountHandle, _, _, err = lookupMount(connection, mountID)
+	return
+}
+
+// lookupMount returns both the MountHandle and the credentials bound to mountID by RpcMount(). As the
+// credentials are bound only to the MountID, it must have been returned by an RpcMount() over connection
+// (or, if connection is nil, e.g. in a test, by an RpcMount() not made over one).
+func lookupMount(connection *connectionStruct, mountID uint64) (mountHandle fs.MountHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, err error) {
+	if nil != connection {
+		connection.Lock()
+		defer connection.Unlock()
+	}
+
+	mountHandle, userID, groupID, err = lookupMountWhileLocked(connection, mountID)
+	return
+}
+
+// lookupMountHandleWhileLocked is lookupMountHandle() for a caller already holding connection.Lock()
+//
+// Note: Caller must hold connection.Lock() (if connection is non-nil)
+func lookupMountHandleWhileLocked(connection *connectionStruct, mountID uint64) (mountHandle fs.MountHandle, err error) {
+	mountHandle, _, _, err = lookupMountWhileLocked(connection, mountID)
+	return
+}
+
+// lookupMountWhileLocked is lookupMount() for a caller already holding connection.Lock()
+//
+// Note: Caller must hold connection.Lock() (if connection is non-nil)
+func lookupMountWhileLocked(connection *connectionStruct, mountID uint64) (mountHandle fs.MountHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, err error) {
+	var (
+		mount *mountStruct
+		ok    bool
+	)
+
+	if nil == connection {
+		ok = true
+	} else {
+		_, ok = connection.mountIDSet[mountID]
+	}
+
+	if ok {
+		globals.Lock()
+		mount, ok = globals.mountIDMap[mountID]
+		if ok && (mount.connection == connection) {
+			mount.lastActivityTime = time.Now()
+		} else {
+			ok = false
+		}
+		globals.Unlock()
+	}
+
+	if ok {
+		mountHandle = mount.mountHandle
+		userID = mount.userID
+		groupID = mount.groupID
+		err = nil
+	} else {
+		err = fmt.Errorf("MountID %v not found in jrpcfs globals.mountIDMap", mountID)
+		err = blunder.AddError(err, blunder.BadMountIDError)
+	}
 	return
 }
 
-func lookupMountHandle(mountID uint64) (mountHandle fs.MountHandle, err error) {
+// lookupIOMount is lookupMount() for the fast I/O port. As requests arrive over a separate connection,
+// mountID must instead have been returned by an RpcMount() over a connection from the same clientHost.
+func lookupIOMount(clientHost string, mountID uint64) (mountHandle fs.MountHandle, userID inode.InodeUserID, groupID inode.InodeGroupID, err error) {
 	globals.Lock()
-	mountHandle, ok := globals.mountIDMap[mountID]
+	mount, ok := globals.mountIDMap[mountID]
+	if ok && (nil != mount.connection) && (clientHost == mount.connection.clientHost) {
+		mount.lastActivityTime = time.Now()
+	} else {
+		ok = false
+	}
 	globals.Unlock()
 	if ok {
+		mountHandle = mount.mountHandle
+		userID = mount.userID
+		groupID = mount.groupID
 		err = nil
 	} else {
 		err = fmt.Errorf("MountID %v not found in jrpcfs globals.mountIDMap", mountID)
@@ -627,7 +745,7 @@ func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 
     var src_ino, tgt_ino inode.InodeNumber
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -666,6 +784,25 @@ func splitPath(fullpath string) (parentDir string, basename string) {
 	return
 }
 
+func (s *Server) RpcAcquireInodeLease(in *AcquireInodeLeaseRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	in.connection.trackInodeLeaseMount(in.MountID)
+
+	err = mountHandle.AcquireInodeLease(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), fs.InodeLeaseType(in.LeaseType))
+	return
+}
+
 func (s *Server) RpcChown(in *ChownRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -674,11 +811,19 @@ func (s *Server) RpcChown(in *ChownRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a ChownRequest on behalf of RpcChown() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *ChownRequest) perform(mountHandle fs.MountHandle) (err error) {
 	// NOTE: We currently just store and return per-inode ownership info.
 	//       We do not check/enforce it; that is the caller's responsibility.
 
@@ -689,6 +834,12 @@ func (s *Server) RpcChown(in *ChownRequest, reply *Reply) (err error) {
 	if in.GroupID != -1 {
 		stat[fs.StatGroupID] = uint64(in.GroupID)
 	}
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
@@ -701,7 +852,7 @@ func (s *Server) RpcChownPath(in *ChownPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -723,6 +874,12 @@ func (s *Server) RpcChownPath(in *ChownPathRequest, reply *Reply) (err error) {
 	if in.GroupID != -1 {
 		stat[fs.StatGroupID] = uint64(in.GroupID)
 	}
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, ino)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
 	return
 }
@@ -738,15 +895,29 @@ func (s *Server) RpcChmod(in *ChmodRequest, reply *Reply) (err error) {
 	// NOTE: We currently just store and return per-inode ownership info.
 	//       We do not check/enforce it; that is the caller's responsibility.
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a ChmodRequest on behalf of RpcChmod() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *ChmodRequest) perform(mountHandle fs.MountHandle) (err error) {
 	// Samba includes the file mode in in.FileMode, but only the permssion
 	// bits can be changed by SetStat().
 	stat := make(fs.Stat)
 	stat[fs.StatMode] = uint64(in.FileMode) & 07777
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
@@ -759,7 +930,7 @@ func (s *Server) RpcChmodPath(in *ChmodPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -779,10 +950,39 @@ func (s *Server) RpcChmodPath(in *ChmodPathRequest, reply *Reply) (err error) {
 	// bits can be changed by SetStat().
 	stat := make(fs.Stat)
 	stat[fs.StatMode] = uint64(in.FileMode) & 07777
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, ino)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
 	return
 }
 
+func (s *Server) RpcCloneRange(in *CloneRangeRequest, reply *CloneRangeReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, userID, groupID, err := lookupMount(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
+	reply.ClonedLength, err = mountHandle.CloneRange(userID, groupID, nil, inode.InodeNumber(in.SrcInodeNumber), in.SrcOffset, inode.InodeNumber(in.InodeNumber), in.Offset, in.Length)
+	return
+}
+
 func (s *Server) RpcCreate(in *CreateRequest, reply *InodeReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -791,11 +991,19 @@ func (s *Server) RpcCreate(in *CreateRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle, reply)
+	return
+}
+
+// perform carries out a CreateRequest on behalf of RpcCreate() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *CreateRequest) perform(mountHandle fs.MountHandle, reply *InodeReply) (err error) {
 	fino, err := mountHandle.Create(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
 	reply.InodeNumber = uint64(fino)
 	return
@@ -809,7 +1017,7 @@ func (s *Server) RpcCreatePath(in *CreatePathRequest, reply *InodeReply) (err er
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -838,15 +1046,55 @@ func (s *Server) RpcCreatePath(in *CreatePathRequest, reply *InodeReply) (err er
 	return
 }
 
+func (s *Server) RpcFetchInodeLeaseRecalls(in *FetchInodeLeaseRecallsRequest, reply *FetchInodeLeaseRecallsReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	// Note that globals.gate is only held while looking up the mount... not while waiting for a recall
+
+	globals.gate.RLock()
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	globals.gate.RUnlock()
+	if nil != err {
+		return
+	}
+
+	timeout := time.Duration(in.TimeoutMs) * time.Millisecond
+	if timeout > maxInodeLeaseRecallsWait {
+		timeout = maxInodeLeaseRecallsWait
+	}
+
+	recalls, err := mountHandle.FetchInodeLeaseRecalls(timeout)
+	if nil != err {
+		return
+	}
+
+	reply.Recalls = make([]InodeLeaseRecall, 0, len(recalls))
+	for _, recall := range recalls {
+		reply.Recalls = append(reply.Recalls, InodeLeaseRecall{
+			InodeNumber: uint64(recall.InodeNumber),
+			LeaseType:   uint32(recall.LeaseType),
+			Reason:      uint32(recall.Reason),
+		})
+	}
+	return
+}
+
 func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	globals.gate.RLock()
-	defer globals.gate.RUnlock()
+	gateHeld := true
+	defer func() {
+		if gateHeld {
+			globals.gate.RUnlock()
+		}
+	}()
 
 	flog := logger.TraceEnter("in.", in)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -857,6 +1105,14 @@ func (s *Server) RpcFlock(in *FlockRequest, reply *FlockReply) (err error) {
 	flock.Start = in.FlockStart
 	flock.Len = in.FlockLen
 	flock.Pid = in.FlockPid
+	in.connection.setFlockOwner(in.MountID, &flock)
+
+	if syscall.F_SETLKW == in.FlockCmd {
+		// Don't hold off SIGHUP-triggered confMap changes while (potentially indefinitely) waiting for a
+		// conflicting lock to be dropped... fs will cancel the wait should the volume go away
+		globals.gate.RUnlock()
+		gateHeld = false
+	}
 
 	lockStruct, err := mountHandle.Flock(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.FlockCmd, &flock)
 	if lockStruct != nil {
@@ -903,7 +1159,7 @@ func (s *Server) RpcFlush(in *FlushRequest, reply *Reply) (err error) {
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.Flush()")
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil == err {
 		err = mountHandle.Flush(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
 	}
@@ -940,7 +1196,6 @@ func (s *Server) RpcGetStat(in *GetStatRequest, reply *StatStruct) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
 
-	var stat fs.Stat
 	var profiler = utils.NewProfilerIf(doProfiling, "getstat")
 
 	flog := logger.TraceEnter("in.", in)
@@ -948,14 +1203,11 @@ func (s *Server) RpcGetStat(in *GetStatRequest, reply *StatStruct) (err error) {
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.Getstat()")
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil == err {
-		stat, err = mountHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+		err = in.perform(mountHandle, reply)
 	}
 	profiler.AddEventNow("after fs.Getstat()")
-	if err == nil {
-		reply.fsStatToStatStruct(stat)
-	}
 
 	// Save profiler with server op stats
 	profiler.Close()
@@ -964,6 +1216,23 @@ func (s *Server) RpcGetStat(in *GetStatRequest, reply *StatStruct) (err error) {
 	return
 }
 
+// perform carries out a GetStatRequest on behalf of RpcGetStat() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *GetStatRequest) perform(mountHandle fs.MountHandle, reply *StatStruct) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonReadRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
+	stat, err := mountHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
+	if nil == err {
+		reply.fsStatToStatStruct(stat)
+	}
+	return
+}
+
 func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err error) {
 	var profiler = utils.NewProfilerIf(doProfiling, "getstat_path")
 
@@ -974,7 +1243,7 @@ func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -992,6 +1261,12 @@ func (s *Server) RpcGetStatPath(in *GetStatPathRequest, reply *StatStruct) (err
 
 	// Do the GetStat
 	profiler.AddEventNow("before fs.Getstat()")
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonReadRequest, ino)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	stat, err := mountHandle.Getstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino))
 	profiler.AddEventNow("after fs.Getstat()")
 	if err == nil {
@@ -1016,9 +1291,9 @@ func (s *Server) RpcGetXAttr(in *GetXAttrRequest, reply *GetXAttrReply) (err err
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	profiler.AddEventNow("before fs.GetXAttr()")
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil == err {
-		reply.AttrValue, err = mountHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
+		err = in.perform(mountHandle, reply)
 	}
 	profiler.AddEventNow("after fs.GetXAttr()")
 
@@ -1029,6 +1304,14 @@ func (s *Server) RpcGetXAttr(in *GetXAttrRequest, reply *GetXAttrReply) (err err
 	return
 }
 
+// perform carries out a GetXAttrRequest on behalf of RpcGetXAttr() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *GetXAttrRequest) perform(mountHandle fs.MountHandle, reply *GetXAttrReply) (err error) {
+	reply.AttrValue, err = mountHandle.GetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
+	return
+}
+
 func (s *Server) RpcGetXAttrPath(in *GetXAttrPathRequest, reply *GetXAttrReply) (err error) {
 	var profiler = utils.NewProfilerIf(doProfiling, "getxattr_path")
 
@@ -1039,7 +1322,7 @@ func (s *Server) RpcGetXAttrPath(in *GetXAttrPathRequest, reply *GetXAttrReply)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1086,7 +1369,7 @@ func (s *Server) RpcLookupPath(in *LookupPathRequest, reply *InodeReply) (err er
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1113,10 +1396,24 @@ func (s *Server) RpcLink(in *LinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a LinkRequest on behalf of RpcLink() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *LinkRequest) perform(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber), inode.InodeNumber(in.TargetInodeNumber))
 	if nil != err {
 		return
 	}
+	defer resumeInodeLeases()
 
 	err = mountHandle.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeNumber(in.TargetInodeNumber))
 	return
@@ -1130,7 +1427,7 @@ func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1151,6 +1448,12 @@ func (s *Server) RpcLinkPath(in *LinkPathRequest, reply *Reply) (err error) {
 	}
 
 	// Do the link
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, srcIno, tgtIno)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Link(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcIno, basename, tgtIno)
 	return
 }
@@ -1163,11 +1466,19 @@ func (s *Server) RpcListXAttr(in *ListXAttrRequest, reply *ListXAttrReply) (err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle, reply)
+	return
+}
+
+// perform carries out a ListXAttrRequest on behalf of RpcListXAttr() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *ListXAttrRequest) perform(mountHandle fs.MountHandle, reply *ListXAttrReply) (err error) {
 	reply.AttrNames, err = mountHandle.ListXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
 	return
 }
@@ -1180,7 +1491,7 @@ func (s *Server) RpcListXAttrPath(in *ListXAttrPathRequest, reply *ListXAttrRepl
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1208,19 +1519,14 @@ func (s *Server) RpcLookup(in *LookupRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
 	profiler.AddEventNow("before fs.Lookup()")
-	ino, err := mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	err = in.perform(mountHandle, reply)
 	profiler.AddEventNow("after fs.Lookup()")
-	// line below is for testing fault injection
-	//err = blunder.AddError(err, blunder.TryAgainError)
-	if err == nil {
-		reply.InodeNumber = uint64(ino)
-	}
 
 	// Save profiler with server op stats
 	profiler.Close()
@@ -1229,6 +1535,19 @@ func (s *Server) RpcLookup(in *LookupRequest, reply *InodeReply) (err error) {
 	return
 }
 
+// perform carries out a LookupRequest on behalf of RpcLookup() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *LookupRequest) perform(mountHandle fs.MountHandle, reply *InodeReply) (err error) {
+	ino, err := mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
+	// line below is for testing fault injection
+	//err = blunder.AddError(err, blunder.TryAgainError)
+	if err == nil {
+		reply.InodeNumber = uint64(ino)
+	}
+	return
+}
+
 func (s *Server) RpcMkdir(in *MkdirRequest, reply *InodeReply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1237,11 +1556,19 @@ func (s *Server) RpcMkdir(in *MkdirRequest, reply *InodeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle, reply)
+	return
+}
+
+// perform carries out a MkdirRequest on behalf of RpcMkdir() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *MkdirRequest) perform(mountHandle fs.MountHandle, reply *InodeReply) (err error) {
 	ino, err := mountHandle.Mkdir(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, inode.InodeMode(in.FileMode))
 	reply.InodeNumber = uint64(ino)
 	return
@@ -1255,7 +1582,7 @@ func (s *Server) RpcMkdirPath(in *MkdirPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1291,9 +1618,26 @@ func (s *Server) RpcMount(in *MountRequest, reply *MountReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
+	// Credentials are carried as uint64 on the wire but must fit in an InodeUserID/InodeGroupID
+
+	if (uint64(math.MaxUint32) < in.AuthUserID) || (uint64(math.MaxUint32) < in.AuthGroupID) {
+		err = fmt.Errorf("RpcMount: AuthUserID (%v) and AuthGroupID (%v) must each fit in 32 bits", in.AuthUserID, in.AuthGroupID)
+		err = blunder.AddError(err, blunder.InvalidArgError)
+		return
+	}
+
+	if !authTokenValid([]byte(in.AuthToken)) {
+		err = fmt.Errorf("RpcMount: invalid AuthToken")
+		err = blunder.AddError(err, blunder.NotPermError)
+		return
+	}
+	if nil != in.connection {
+		in.connection.authenticate()
+	}
+
 	mountHandle, err := fs.Mount(in.VolumeName, fs.MountOptions(in.MountOptions))
 	if err == nil {
-		reply.MountID = allocateMountID(mountHandle)
+		reply.MountID = allocateMountID(mountHandle, inode.InodeUserID(in.AuthUserID), inode.InodeGroupID(in.AuthGroupID), in.connection)
 		reply.RootDirInodeNumber = uint64(inode.RootDirInodeNumber)
 	}
 	return
@@ -1330,9 +1674,14 @@ func (s *Server) RpcRead(in *ReadRequest, reply *ReadReply) (err error) {
 	}()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, userID, groupID, err := lookupMount(in.connection, in.MountID)
 	if nil == err {
-		reply.Buf, err = mountHandle.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Length, nil)
+		var resumeInodeLeases func()
+		mountHandle, resumeInodeLeases, err = breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonReadRequest, inode.InodeNumber(in.InodeNumber))
+		if nil == err {
+			reply.Buf, err = mountHandle.Read(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Length, nil)
+			resumeInodeLeases()
+		}
 	}
 
 	reply.RequestTimeSec = UnixSec(requestRecTime)
@@ -1393,7 +1742,7 @@ func (s *Server) rpcReaddirInternal(in interface{}, reply *ReaddirReply, profile
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(iH.MountID)
+	mountHandle, err := lookupMountHandle(iH.connection, iH.MountID)
 	if nil != err {
 		return
 	}
@@ -1452,7 +1801,7 @@ func (s *Server) rpcReaddirPlusInternal(in interface{}, reply *ReaddirPlusReply,
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(iH.MountID)
+	mountHandle, err := lookupMountHandle(iH.connection, iH.MountID)
 	if err != nil {
 		return
 	}
@@ -1484,11 +1833,19 @@ func (s *Server) RpcReadSymlink(in *ReadSymlinkRequest, reply *ReadSymlinkReply)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle, reply)
+	return
+}
+
+// perform carries out a ReadSymlinkRequest on behalf of RpcReadSymlink() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *ReadSymlinkRequest) perform(mountHandle fs.MountHandle, reply *ReadSymlinkReply) (err error) {
 	target, err := mountHandle.Readsymlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
 	reply.Target = target
 	return
@@ -1502,7 +1859,7 @@ func (s *Server) RpcReadSymlinkPath(in *ReadSymlinkPathRequest, reply *ReadSymli
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1518,6 +1875,23 @@ func (s *Server) RpcReadSymlinkPath(in *ReadSymlinkPathRequest, reply *ReadSymli
 	return
 }
 
+func (s *Server) RpcReleaseInodeLease(in *ReleaseInodeLeaseRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.ReleaseInodeLease(inode.InodeNumber(in.InodeNumber))
+	return
+}
+
 func (s *Server) RpcRemovetXAttr(in *RemoveXAttrRequest, reply *Reply) (err error) {
 	globals.gate.RLock()
 	defer globals.gate.RUnlock()
@@ -1526,11 +1900,25 @@ func (s *Server) RpcRemovetXAttr(in *RemoveXAttrRequest, reply *Reply) (err erro
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a RemoveXAttrRequest on behalf of RpcRemovetXAttr() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *RemoveXAttrRequest) perform(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName)
 	return
 }
@@ -1543,7 +1931,7 @@ func (s *Server) RpcRemoveAttrPath(in *RemoveXAttrPathRequest, reply *Reply) (er
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1553,6 +1941,12 @@ func (s *Server) RpcRemoveAttrPath(in *RemoveXAttrPathRequest, reply *Reply) (er
 		return
 	}
 
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(ino))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.RemoveXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino), in.AttrName)
 	return
 }
@@ -1565,10 +1959,24 @@ func (s *Server) RpcRename(in *RenameRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a RenameRequest on behalf of RpcRename() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *RenameRequest) perform(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, append(leaseBreakTargets(mountHandle, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename), leaseBreakTargets(mountHandle, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename)...)...)
 	if nil != err {
 		return
 	}
+	defer resumeInodeLeases()
 
 	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.SrcDirInodeNumber), in.SrcBasename, inode.InodeNumber(in.DstDirInodeNumber), in.DstBasename)
 	return
@@ -1582,7 +1990,7 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1606,6 +2014,12 @@ func (s *Server) RpcRenamePath(in *RenamePathRequest, reply *Reply) (err error)
 	}
 
 	// Do the rename
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, append(leaseBreakTargets(mountHandle, srcIno, srcBasename), leaseBreakTargets(mountHandle, dstIno, dstBasename)...)...)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Rename(inode.InodeRootUserID, inode.InodeGroupID(0), nil, srcIno, srcBasename, dstIno, dstBasename)
 	return
 }
@@ -1618,11 +2032,25 @@ func (s *Server) RpcResize(in *ResizeRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a ResizeRequest on behalf of RpcResize() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *ResizeRequest) perform(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Resize(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.NewSize)
 	return
 }
@@ -1635,11 +2063,25 @@ func (s *Server) RpcRmdir(in *UnlinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.performRmdir(mountHandle)
+	return
+}
+
+// performRmdir carries out an UnlinkRequest (naming a directory) on behalf of RpcRmdir() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *UnlinkRequest) performRmdir(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, leaseBreakTargets(mountHandle, inode.InodeNumber(in.InodeNumber), in.Basename)...)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	return
 }
@@ -1652,7 +2094,7 @@ func (s *Server) RpcRmdirPath(in *UnlinkPathRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1667,6 +2109,12 @@ func (s *Server) RpcRmdirPath(in *UnlinkPathRequest, reply *Reply) (err error) {
 	}
 
 	// Do the rmdir
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, leaseBreakTargets(mountHandle, ino, basename)...)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Rmdir(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, basename)
 	return
 }
@@ -1679,11 +2127,19 @@ func (s *Server) RpcSetstat(in *SetstatRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a SetstatRequest on behalf of RpcSetstat() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *SetstatRequest) perform(mountHandle fs.MountHandle) (err error) {
 	stat := make(fs.Stat)
 	stat[fs.StatCRTime] = in.CRTimeNs
 	stat[fs.StatCTime] = in.CTimeNs
@@ -1692,6 +2148,12 @@ func (s *Server) RpcSetstat(in *SetstatRequest, reply *Reply) (err error) {
 	stat[fs.StatSize] = in.Size
 	stat[fs.StatNLink] = in.NumLinks
 	// XXX TODO: add in mode/userid/groupid?
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
@@ -1704,14 +2166,28 @@ func (s *Server) RpcSetTime(in *SetTimeRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a SetTimeRequest on behalf of RpcSetTime() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *SetTimeRequest) perform(mountHandle fs.MountHandle) (err error) {
 	stat := make(fs.Stat)
 	stat[fs.StatMTime] = in.MTimeNs
 	stat[fs.StatATime] = in.ATimeNs
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), stat)
 	return
 }
@@ -1724,7 +2200,7 @@ func (s *Server) RpcSetTimePath(in *SetTimePathRequest, reply *Reply) (err error
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1738,6 +2214,12 @@ func (s *Server) RpcSetTimePath(in *SetTimePathRequest, reply *Reply) (err error
 	stat := make(fs.Stat)
 	stat[fs.StatMTime] = in.MTimeNs
 	stat[fs.StatATime] = in.ATimeNs
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, ino)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Setstat(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, stat)
 	return
 }
@@ -1750,11 +2232,25 @@ func (s *Server) RpcSetXAttr(in *SetXAttrRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a SetXAttrRequest on behalf of RpcSetXAttr() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *SetXAttrRequest) perform(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.AttrName, in.AttrValue, in.AttrFlags)
 	return
 }
@@ -1767,7 +2263,7 @@ func (s *Server) RpcSetXAttrPath(in *SetXAttrPathRequest, reply *Reply) (err err
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1777,6 +2273,12 @@ func (s *Server) RpcSetXAttrPath(in *SetXAttrPathRequest, reply *Reply) (err err
 		return
 	}
 
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(ino))
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.SetXAttr(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ino), in.AttrName, in.AttrValue, in.AttrFlags)
 	return
 }
@@ -1789,7 +2291,7 @@ func (s *Server) RpcStatVFS(in *StatVFSRequest, reply *StatVFS) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1823,12 +2325,20 @@ func (s *Server) RpcSymlink(in *SymlinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
-	_, err = mountHandle.Symlink(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, in.Target)
+	_, err = in.perform(mountHandle)
+	return
+}
+
+// perform carries out a SymlinkRequest on behalf of RpcSymlink() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *SymlinkRequest) perform(mountHandle fs.MountHandle) (ino inode.InodeNumber, err error) {
+	ino, err = mountHandle.Symlink(inode.InodeUserID(in.UserID), inode.InodeGroupID(in.GroupID), nil, inode.InodeNumber(in.InodeNumber), in.Basename, in.Target)
 	return
 }
 
@@ -1840,7 +2350,7 @@ func (s *Server) RpcSymlinkPath(in *SymlinkPathRequest, reply *Reply) (err error
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1866,11 +2376,19 @@ func (s *Server) RpcType(in *TypeRequest, reply *TypeReply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
 
+	err = in.perform(mountHandle, reply)
+	return
+}
+
+// perform carries out a TypeRequest on behalf of RpcType() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock()
+func (in *TypeRequest) perform(mountHandle fs.MountHandle, reply *TypeReply) (err error) {
 	ftype, err := mountHandle.GetType(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber))
 	// Cast as a uint16 here to get the underlying DT_* constant
 	reply.FileType = uint16(ftype)
@@ -1885,10 +2403,24 @@ func (s *Server) RpcUnlink(in *UnlinkRequest, reply *Reply) (err error) {
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = in.performUnlink(mountHandle)
+	return
+}
+
+// performUnlink carries out an UnlinkRequest on behalf of RpcUnlink() and RpcCompound()
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func (in *UnlinkRequest) performUnlink(mountHandle fs.MountHandle) (err error) {
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, leaseBreakTargets(mountHandle, inode.InodeNumber(in.InodeNumber), in.Basename)...)
 	if nil != err {
 		return
 	}
+	defer resumeInodeLeases()
 
 	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Basename)
 	return
@@ -1902,7 +2434,7 @@ func (s *Server) RpcUnlinkPath(in *UnlinkPathRequest, reply *Reply) (err error)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
 	if nil != err {
 		return
 	}
@@ -1917,10 +2449,33 @@ func (s *Server) RpcUnlinkPath(in *UnlinkPathRequest, reply *Reply) (err error)
 	}
 
 	// Do the unlink
+	mountHandle, resumeInodeLeases, err := breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, leaseBreakTargets(mountHandle, ino, basename)...)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
+
 	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, ino, basename)
 	return
 }
 
+func (s *Server) RpcUnmount(in *UnmountRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	_, err = lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = unmountMountID(in.MountID)
+	return
+}
+
 func (s *Server) RpcWrite(in *WriteRequest, reply *WriteReply) (err error) {
 	var size uint64
 
@@ -1956,10 +2511,15 @@ func (s *Server) RpcWrite(in *WriteRequest, reply *WriteReply) (err error) {
 	}()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	mountHandle, err := lookupMountHandle(in.MountID)
+	mountHandle, userID, groupID, err := lookupMount(in.connection, in.MountID)
 	if nil == err {
-		size, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Buf, nil)
-		reply.Size = uint64(size)
+		var resumeInodeLeases func()
+		mountHandle, resumeInodeLeases, err = breakInodeLeasesOutsideGate(in.connection, in.MountID, mountHandle, dlm.ReasonWriteRequest, inode.InodeNumber(in.InodeNumber))
+		if nil == err {
+			size, err = mountHandle.Write(userID, groupID, nil, inode.InodeNumber(in.InodeNumber), in.Offset, in.Buf, nil)
+			reply.Size = uint64(size)
+			resumeInodeLeases()
+		}
 	}
 
 	reply.RequestTimeSec = UnixSec(requestRecTime)
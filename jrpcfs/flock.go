@@ -0,0 +1,42 @@
+package jrpcfs
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// setFlockOwner fills in the ClientID and ClientHost of an RpcFlock() request (recording its MountID for releaseFlocksWhileLocked())
+func (connection *connectionStruct) setFlockOwner(mountID uint64, flock *fs.FlockStruct) {
+	if nil == connection {
+		// RpcFlock() not called via a connection (e.g. in a test)
+		flock.ClientID = ""
+		flock.ClientHost = ""
+		return
+	}
+
+	connection.Lock()
+	connection.flockMountIDSet[mountID] = struct{}{}
+	connection.Unlock()
+
+	flock.ClientID = connection.clientID
+	flock.ClientHost = connection.clientHost
+}
+
+// releaseFlocksWhileLocked drops the locks obtained (and cancels the lock waits begun) over the now closed connection
+//
+// Note: Caller must hold both globals.gate.RLock() and connection.Lock()
+func (connection *connectionStruct) releaseFlocksWhileLocked() {
+	for mountID := range connection.flockMountIDSet {
+		mountHandle, err := lookupMountHandleWhileLocked(connection, mountID)
+		if nil != err {
+			// Mount has already gone away (taking its locks with it)
+			continue
+		}
+		err = mountHandle.ReleaseFlocks(connection.clientID)
+		if nil != err {
+			logger.ErrorfWithError(err, "ReleaseFlocks() for MountID %v ClientID %v failed", mountID, connection.clientID)
+		}
+	}
+
+	connection.flockMountIDSet = make(map[uint64]struct{})
+}
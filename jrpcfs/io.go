@@ -9,6 +9,7 @@ import (
 	"unsafe"
 
 	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/fs"
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
@@ -26,7 +27,7 @@ func ioServerUp(ipAddr string, fastPortString string) {
 
 	qserver = NewServer()
 
-	ioListener, err = net.Listen("tcp", net.JoinHostPort(ipAddr, fastPortString))
+	ioListener, err = authListen(ipAddr, fastPortString)
 	if err != nil {
 		logger.ErrorfWithError(err, "net.Listen %s:%s failed", ipAddr, fastPortString)
 		return
@@ -59,7 +60,11 @@ func ioServerLoop() {
 		globals.connLock.Unlock()
 
 		go func() {
-			ioHandle(conn)
+			if (nil == authTLSHandshake(conn)) && (nil == ioAuthHandshake(conn)) {
+				ioHandle(conn)
+			} else {
+				conn.Close()
+			}
 			globals.connLock.Lock()
 			globals.connections.Remove(elm)
 			globals.connLock.Unlock()
@@ -357,13 +362,24 @@ func makeReq(bytes []byte, req *ioRequest) {
 
 func ioHandle(conn net.Conn) {
 	var (
-		mountHandle fs.MountHandle
+		clientHost        string
+		groupID           inode.InodeGroupID
+		mountHandle       fs.MountHandle
+		resumeInodeLeases func()
+		userID            inode.InodeUserID
 	)
 
 	// NOTE: Allocate 64k buffer and context on the stack; this function runs in a goroutine
 	//       and only processes one request at a time.
 	//
 	//var dataStorage [64 * 1024]byte
+	// MountIDs used over this connection must have been returned by RpcMount() over a JSON-RPC connection from the same host
+
+	clientHost, _, splitErr := net.SplitHostPort(conn.RemoteAddr().String())
+	if nil != splitErr {
+		clientHost = conn.RemoteAddr().String()
+	}
+
 	ctxStorage := ioContext{op: InvalidOp}
 	ctx := &ctxStorage
 	// XXX TODO: no sync.Pool for now, just alloc on the stack
@@ -408,9 +424,16 @@ func ioHandle(conn net.Conn) {
 			}
 
 			profiler.AddEventNow("before fs.Write()")
-			mountHandle, err = lookupMountHandle(ctx.req.mountID)
+			mountHandle, userID, groupID, err = lookupIOMount(clientHost, ctx.req.mountID)
 			if err == nil {
-				ctx.resp.ioSize, err = mountHandle.Write(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.data, profiler)
+				mountHandle, resumeInodeLeases, err = breakMountInodeLeasesOutsideGate(mountHandle, func() (fs.MountHandle, error) {
+					ioMountHandle, _, _, ioErr := lookupIOMount(clientHost, ctx.req.mountID)
+					return ioMountHandle, ioErr
+				}, dlm.ReasonWriteRequest, []inode.InodeNumber{inode.InodeNumber(ctx.req.inodeID)})
+				if err == nil {
+					ctx.resp.ioSize, err = mountHandle.Write(userID, groupID, nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.data, profiler)
+					resumeInodeLeases()
+				}
 			}
 			profiler.AddEventNow("after fs.Write()")
 
@@ -426,9 +449,16 @@ func ioHandle(conn net.Conn) {
 			}
 
 			profiler.AddEventNow("before fs.Read()")
-			mountHandle, err = lookupMountHandle(ctx.req.mountID)
+			mountHandle, userID, groupID, err = lookupIOMount(clientHost, ctx.req.mountID)
 			if err == nil {
-				ctx.data, err = mountHandle.Read(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.req.length, profiler)
+				mountHandle, resumeInodeLeases, err = breakMountInodeLeasesOutsideGate(mountHandle, func() (fs.MountHandle, error) {
+					ioMountHandle, _, _, ioErr := lookupIOMount(clientHost, ctx.req.mountID)
+					return ioMountHandle, ioErr
+				}, dlm.ReasonReadRequest, []inode.InodeNumber{inode.InodeNumber(ctx.req.inodeID)})
+				if err == nil {
+					ctx.data, err = mountHandle.Read(userID, groupID, nil, inode.InodeNumber(ctx.req.inodeID), ctx.req.offset, ctx.req.length, profiler)
+					resumeInodeLeases()
+				}
 			}
 			profiler.AddEventNow("after fs.Read()")
 
@@ -0,0 +1,44 @@
+package jrpcfs
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// A client (e.g. a backup tool) pages through the change journal of a mounted volume by passing the Cursor
+// returned by each RpcFetchJournal() call to the next. As the Cursor is opaque (and remains valid across
+// restarts of the volume), a client may persist it to later fetch only what changed since. Should the records
+// following a Cursor have been trimmed (see the volume's JournalRetention), the call fails with errno ERANGE.
+
+func (s *Server) RpcFetchJournal(in *FetchJournalRequest, reply *FetchJournalReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	journalRecs, cursor, err := fs.FetchJournal(mountHandle.VolumeName(), in.Cursor, in.MaxRecs)
+	if nil != err {
+		return
+	}
+
+	reply.Records = make([]JournalRec, 0, len(journalRecs))
+	for _, journalRec := range journalRecs {
+		reply.Records = append(reply.Records, JournalRec{
+			Nonce:             journalRec.Nonce,
+			Op:                uint32(journalRec.Op),
+			InodeNumber:       uint64(journalRec.InodeNumber),
+			ParentInodeNumber: uint64(journalRec.ParentInodeNumber),
+			Basename:          journalRec.Basename,
+			Time:              journalRec.Time.UnixNano(),
+		})
+	}
+	reply.Cursor = cursor
+	return
+}
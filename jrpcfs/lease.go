@@ -0,0 +1,94 @@
+package jrpcfs
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/dlm"
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// maxInodeLeaseRecallsWait bounds how long RpcFetchInodeLeaseRecalls() will wait for a recall
+const maxInodeLeaseRecallsWait = 60 * time.Second
+
+// trackInodeLeaseMount records that a lease was acquired via mountID (for releaseInodeLeasesWhileLocked())
+func (connection *connectionStruct) trackInodeLeaseMount(mountID uint64) {
+	if nil == connection {
+		// RpcAcquireInodeLease() not called via a connection (e.g. in a test)
+		return
+	}
+
+	connection.Lock()
+	connection.leaseMountIDSet[mountID] = struct{}{}
+	connection.Unlock()
+}
+
+// releaseInodeLeasesWhileLocked drops the leases obtained over the now closed connection
+//
+// Note: Caller must hold both globals.gate.RLock() and connection.Lock()
+func (connection *connectionStruct) releaseInodeLeasesWhileLocked() {
+	for mountID := range connection.leaseMountIDSet {
+		mountHandle, err := lookupMountHandleWhileLocked(connection, mountID)
+		if nil != err {
+			// Mount has already gone away (taking its leases with it)
+			continue
+		}
+		err = mountHandle.ReleaseInodeLeases()
+		if nil != err {
+			logger.ErrorfWithError(err, "ReleaseInodeLeases() for MountID %v failed", mountID)
+		}
+	}
+
+	connection.leaseMountIDSet = make(map[uint64]struct{})
+}
+
+// breakInodeLeasesOutsideGate recalls the leases (held via other mounts) on inodeNumbers conflicting with an
+// operation of the specified reason about to be performed via mountID. As waiting for them to be released may
+// take up to LeaseRecallTimeout, globals.gate is not held while waiting (just as RpcFlock() does for F_SETLKW)
+// lest a SIGHUP-triggered confMap change be held off. Until the returned resume func is called, no new leases
+// on inodeNumbers are granted... so the operation itself need not wait. As the mount may have gone away in the
+// meantime, the MountHandle for mountID is looked up again and returned.
+//
+// Note: Caller must hold globals.gate.RLock() (which is held again upon return)
+func breakInodeLeasesOutsideGate(connection *connectionStruct, mountID uint64, mountHandle fs.MountHandle, reason dlm.NotifyReason, inodeNumbers ...inode.InodeNumber) (fs.MountHandle, func(), error) {
+	return breakMountInodeLeasesOutsideGate(mountHandle, func() (fs.MountHandle, error) { return lookupMountHandle(connection, mountID) }, reason, inodeNumbers)
+}
+
+// breakMountInodeLeasesOutsideGate is breakInodeLeasesOutsideGate() for a mount looked up via lookupMount
+func breakMountInodeLeasesOutsideGate(mountHandle fs.MountHandle, lookupMount func() (fs.MountHandle, error), reason dlm.NotifyReason, inodeNumbers []inode.InodeNumber) (currentMountHandle fs.MountHandle, resume func(), err error) {
+	leaseBreak := mountHandle.BreakInodeLeases(inodeNumbers, reason)
+	if nil == leaseBreak {
+		// Fast path... no conflicting leases
+		currentMountHandle = mountHandle
+		resume = func() {}
+		err = nil
+		return
+	}
+
+	globals.gate.RUnlock()
+	leaseBreak.Wait()
+	globals.gate.RLock()
+
+	currentMountHandle, err = lookupMount()
+	if nil != err {
+		leaseBreak.Done()
+		return
+	}
+
+	resume = leaseBreak.Done
+	return
+}
+
+// leaseBreakTargets returns dirInodeNumber along with the inode (if any) named basename in it... those
+// whose leases are broken by an Unlink(), Rename(), or Rmdir() of basename
+func leaseBreakTargets(mountHandle fs.MountHandle, dirInodeNumber inode.InodeNumber, basename string) (inodeNumbers []inode.InodeNumber) {
+	inodeNumbers = []inode.InodeNumber{dirInodeNumber}
+
+	inodeNumber, err := mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, basename)
+	if nil == err {
+		inodeNumbers = append(inodeNumbers, inodeNumber)
+	}
+
+	return
+}
@@ -2,8 +2,10 @@ package jrpcfs
 
 import (
 	"fmt"
+	"path"
 
 	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/dlm"
 	"github.com/swiftstack/ProxyFS/fs"
 	"github.com/swiftstack/ProxyFS/inode"
 	"github.com/swiftstack/ProxyFS/logger"
@@ -213,7 +215,7 @@ func (s *Server) RpcGetObject(in *GetObjectReq, reply *GetObjectReply) (err erro
 
 	mountRelativePath := vContainerName + "/" + objectName
 
-	reply.FileSize, reply.ModificationTime, reply.InodeNumber, reply.NumWrites, reply.Metadata, err = mountHandle.MiddlewareGetObject(volumeName, mountRelativePath, in.ReadEntsIn, &reply.ReadEntsOut)
+	reply.FileSize, reply.ModificationTime, reply.InodeNumber, reply.NumWrites, reply.Metadata, reply.LeaseId, err = mountHandle.MiddlewareGetObject(volumeName, mountRelativePath, in.ReadEntsIn, &reply.ReadEntsOut)
 	if err != nil {
 		return err
 	}
@@ -354,6 +356,33 @@ func (s *Server) RpcCoalesce(in *CoalesceReq, reply *CoalesceReply) (err error)
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
 	_, destContainer, destObject, _, mountHandle, err := mountIfNotMounted(in.VirtPath)
+	if nil != err {
+		return
+	}
+
+	// Break leases on the destination and elements (and their directories) without holding globals.gate
+
+	leaseBreakPaths := make([]string, 0, 2*(1+len(in.ElementAccountRelativePaths)))
+	for _, coalescePath := range append([]string{destContainer + "/" + destObject}, in.ElementAccountRelativePaths...) {
+		leaseBreakPaths = append(leaseBreakPaths, path.Dir(coalescePath), coalescePath)
+	}
+
+	leaseBreakInodeNumbers := make([]inode.InodeNumber, 0, len(leaseBreakPaths))
+	for _, leaseBreakPath := range leaseBreakPaths {
+		leaseBreakInodeNumber, lookupErr := mountHandle.LookupPath(inode.InodeRootUserID, inode.InodeGroupID(0), nil, leaseBreakPath)
+		if nil == lookupErr {
+			leaseBreakInodeNumbers = append(leaseBreakInodeNumbers, leaseBreakInodeNumber)
+		}
+	}
+
+	mountHandle, resumeInodeLeases, err := breakMountInodeLeasesOutsideGate(mountHandle, func() (fs.MountHandle, error) {
+		_, _, _, _, remountHandle, remountErr := mountIfNotMounted(in.VirtPath)
+		return remountHandle, remountErr
+	}, dlm.ReasonWriteRequest, leaseBreakInodeNumbers)
+	if nil != err {
+		return
+	}
+	defer resumeInodeLeases()
 
 	reply.InodeNumber, reply.NumWrites, reply.ModificationTime, err = mountHandle.MiddlewareCoalesce(destContainer+"/"+destObject, in.ElementAccountRelativePaths)
 	return
@@ -371,7 +400,7 @@ func (s *Server) RpcRenewLease(in *RenewLeaseReq, reply *RenewLeaseReply) (err e
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	// This is currently a stub, as there's not yet any idea of a lease, so there's nothing to renew.
+	err = fs.MiddlewareRenewLease(in.LeaseId)
 	return
 }
 
@@ -386,7 +415,7 @@ func (s *Server) RpcReleaseLease(in *ReleaseLeaseReq, reply *ReleaseLeaseReply)
 	defer func() { flog.TraceExitErr("reply.", err, reply) }()
 	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
 
-	// This is currently a stub, as there's not yet any idea of a lease, so there's nothing to release.
+	err = fs.MiddlewareReleaseLease(in.LeaseId)
 	return
 }
 
@@ -2,11 +2,15 @@ package jrpcfs
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/rpc"
 	"os"
 	"strings"
 	"testing"
 	"time"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 
@@ -87,6 +91,8 @@ func testSetup() []func() {
 		"Volume:SomeVolume.MaxInodesPerMetadataNode=32",
 		"Volume:SomeVolume.MaxLogSegmentsPerMetadataNode=64",
 		"Volume:SomeVolume.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:SomeVolume.JournalRetention=1h",
+		"Volume:SomeVolume.QuotaList=JrpcfsTestQuota",
 		"Volume:SomeVolume2.FSID=2",
 		"Volume:SomeVolume2.PrimaryPeer=Peer0",
 		"Volume:SomeVolume2.AccountName=" + testAccountName2,
@@ -102,6 +108,12 @@ func testSetup() []func() {
 		"Volume:SomeVolume2.MaxInodesPerMetadataNode=32",
 		"Volume:SomeVolume2.MaxLogSegmentsPerMetadataNode=64",
 		"Volume:SomeVolume2.MaxDirFileNodesPerMetadataNode=16",
+		"Volume:SomeVolume2.TrashRetention=1h",
+		"Quota:JrpcfsTestQuota.Type=User",
+		"Quota:JrpcfsTestQuota.ID=1000",
+		"Quota:JrpcfsTestQuota.SoftInodes=1000000",
+		"Quota:JrpcfsTestQuota.HardInodes=2000000",
+		"Quota:JrpcfsTestQuota.GracePeriod=24h",
 		"FlowControl:JrpcfsTestFlowControl.MaxFlushSize=10027008",
 		"FlowControl:JrpcfsTestFlowControl.MaxFlushTime=2s",
 		"FlowControl:JrpcfsTestFlowControl.ReadCacheLineSize=1000000",
@@ -116,6 +128,7 @@ func testSetup() []func() {
 		"PhysicalContainerLayout:SomeContainerLayout2.MaxObjectsPerContainer=1234567",
 		"JSONRPCServer.TCPPort=12346",     // 12346 instead of 12345 so that test can run if proxyfsd is already running
 		"JSONRPCServer.FastTCPPort=32346", // ...and similarly here...
+		"JSONRPCServer.BinaryTCPPort=22346",
 		"JSONRPCServer.DataPathLogging=false",
 	}
 
@@ -1590,6 +1603,14 @@ func TestRpcGetObjectMetadata(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(uint64(22), reply.FileSize)
 	assert.Equal(statResult[fs.StatMTime], reply.ModificationTime)
+	assert.NotEqual("", reply.LeaseId)
+
+	err = server.RpcRenewLease(&RenewLeaseReq{LeaseId: reply.LeaseId}, &RenewLeaseReply{})
+	assert.Nil(err)
+	err = server.RpcReleaseLease(&ReleaseLeaseReq{LeaseId: reply.LeaseId}, &ReleaseLeaseReply{})
+	assert.Nil(err)
+	err = server.RpcReleaseLease(&ReleaseLeaseReq{LeaseId: reply.LeaseId}, &ReleaseLeaseReply{})
+	assert.NotNil(err)
 }
 
 func TestRpcGetObjectSymlinkFollowing(t *testing.T) {
@@ -2312,3 +2333,770 @@ func TestRpcCoalesceSubdirOfAFile(t *testing.T) {
 	assert.NotNil(err)
 	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotDirError), err.Error())
 }
+
+func TestRpcMountCredentials(t *testing.T) {
+	var (
+		fileContents = []byte("for root's eyes only")
+	)
+
+	assert := assert.New(t)
+	server := &Server{}
+
+	rpcClientConn, rpcServerConn := net.Pipe()
+	defer rpcClientConn.Close()
+	defer rpcServerConn.Close()
+
+	connection := newConnection(rpcServerConn)
+
+	rootMountReply := MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume", connection: connection}, &rootMountReply)
+	assert.Nil(err)
+
+	userMountReply := MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", AuthUserID: 1000, AuthGroupID: 1000, connection: connection}, &userMountReply)
+	assert.Nil(err)
+
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", AuthUserID: uint64(1) << 32}, &MountReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.InvalidArgError), err.Error())
+
+	mountHandle, err := lookupMountHandle(connection, rootMountReply.MountID)
+	assert.Nil(err)
+	fileInode, err := mountHandle.Create(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "root-only.txt", inode.InodeMode(0600))
+	assert.Nil(err)
+
+	// RpcWrite() and RpcRead() are performed with the credentials bound to the MountID
+
+	writeReply := WriteReply{}
+	err = server.RpcWrite(&WriteRequest{InodeHandle: InodeHandle{MountID: rootMountReply.MountID, InodeNumber: uint64(fileInode), connection: connection}, Buf: fileContents}, &writeReply)
+	assert.Nil(err)
+
+	err = server.RpcWrite(&WriteRequest{InodeHandle: InodeHandle{MountID: userMountReply.MountID, InodeNumber: uint64(fileInode), connection: connection}, Buf: fileContents}, &WriteReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.PermDeniedError), err.Error())
+
+	err = server.RpcRead(&ReadRequest{InodeHandle: InodeHandle{MountID: userMountReply.MountID, InodeNumber: uint64(fileInode), connection: connection}, Length: uint64(len(fileContents))}, &ReadReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.PermDeniedError), err.Error())
+
+	// A MountID (and the credentials bound to it) may not be used over another connection
+
+	otherClientConn, otherServerConn := net.Pipe()
+	defer otherClientConn.Close()
+	defer otherServerConn.Close()
+
+	otherConnection := newConnection(otherServerConn)
+
+	err = server.RpcRead(&ReadRequest{InodeHandle: InodeHandle{MountID: rootMountReply.MountID, InodeNumber: uint64(fileInode), connection: otherConnection}, Length: uint64(len(fileContents))}, &ReadReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+
+	err = server.RpcRead(&ReadRequest{InodeHandle: InodeHandle{MountID: rootMountReply.MountID, InodeNumber: uint64(fileInode)}, Length: uint64(len(fileContents))}, &ReadReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+
+	// Nor may the fast I/O port use it from another host
+
+	_, _, _, err = lookupIOMount("other-host", rootMountReply.MountID)
+	assert.NotNil(err)
+	assert.True(blunder.Is(err, blunder.BadMountIDError))
+
+	// As are ReadOp and WriteOp on the fast I/O port
+
+	clientConn, serverConn := net.Pipe()
+	go ioHandle(serverConn)
+
+	doIO := func(opType uint64, mountID uint64, buf []byte) (resp ioResponse) {
+		req := ioRequest{opType: opType, mountID: mountID, inodeID: uint64(fileInode), offset: 0, length: uint64(len(buf))}
+		_, err := clientConn.Write(makeBytesReq(&req))
+		assert.Nil(err)
+		if 1001 == opType {
+			_, err = clientConn.Write(buf)
+			assert.Nil(err)
+		}
+		respBytes := make([]byte, ioResponseSize)
+		_, err = io.ReadFull(clientConn, respBytes)
+		assert.Nil(err)
+		resp = *(*ioResponse)(unsafe.Pointer(&respBytes[0]))
+		if (1002 == opType) && (0 < resp.ioSize) {
+			_, err = io.ReadFull(clientConn, make([]byte, resp.ioSize))
+			assert.Nil(err)
+		}
+		return
+	}
+
+	resp := doIO(1001, userMountReply.MountID, fileContents)
+	assert.Equal(uint64(blunder.PermDeniedError), resp.errno)
+	resp = doIO(1002, userMountReply.MountID, fileContents)
+	assert.Equal(uint64(blunder.PermDeniedError), resp.errno)
+	resp = doIO(1002, rootMountReply.MountID, fileContents)
+	assert.Equal(uint64(0), resp.errno)
+	assert.Equal(uint64(len(fileContents)), resp.ioSize)
+
+	clientConn.Close()
+
+	err = mountHandle.Unlink(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "root-only.txt")
+	assert.Nil(err)
+
+	connection.release()
+}
+
+func TestRpcMountAuthToken(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	globals.gate.Lock()
+	globals.authTokenList = [][]byte{[]byte("open-sesame")}
+	globals.gate.Unlock()
+
+	defer func() {
+		globals.gate.Lock()
+		globals.authTokenList = make([][]byte, 0)
+		globals.gate.Unlock()
+	}()
+
+	connection := &connectionStruct{mountIDSet: make(map[uint64]struct{})}
+
+	err := connection.authCheckRequest("Server.RpcGetStat")
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotPermError), err.Error())
+	assert.Nil(connection.authCheckRequest("Server.RpcPing"))
+	assert.Nil(connection.authCheckRequest("Server.RpcMount"))
+
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", AuthToken: "abracadabra", connection: connection}, &MountReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotPermError), err.Error())
+	assert.NotNil(connection.authCheckRequest("Server.RpcGetStat"))
+
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", AuthToken: "open-sesame", connection: connection}, &MountReply{})
+	assert.Nil(err)
+	assert.Nil(connection.authCheckRequest("Server.RpcGetStat"))
+
+	// The fast I/O port expects a token length & token handshake
+
+	doHandshake := func(authToken string) (errno uint64, err error) {
+		clientConn, serverConn := net.Pipe()
+		defer clientConn.Close()
+
+		handshakeErrChan := make(chan error, 1)
+		go func() {
+			handshakeErrChan <- ioAuthHandshake(serverConn)
+		}()
+
+		_, _ = clientConn.Write(makeBytesUint64(uint64(len(authToken))))
+		_, _ = clientConn.Write([]byte(authToken))
+		errnoBuf := make([]byte, 8)
+		_, _ = io.ReadFull(clientConn, errnoBuf)
+		errno = *(*uint64)(unsafe.Pointer(&errnoBuf[0]))
+		err = <-handshakeErrChan
+		return
+	}
+
+	errno, err := doHandshake("abracadabra")
+	assert.NotNil(err)
+	assert.Equal(uint64(blunder.NotPermError), errno)
+
+	errno, err = doHandshake("open-sesame")
+	assert.Nil(err)
+	assert.Equal(uint64(0), errno)
+}
+
+func TestRpcUnmount(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountInfoFound := func(mountID uint64) (found bool) {
+		for _, mountInfo := range FetchMountInfoList() {
+			if mountID == mountInfo.MountID {
+				found = true
+			}
+		}
+		return
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	connection := newConnection(serverConn)
+
+	// RpcUnmount() drops a MountID... once
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume", connection: connection}, mountReply)
+	assert.Nil(err)
+	assert.True(mountInfoFound(mountReply.MountID))
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: mountReply.MountID}, &Reply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+	assert.True(mountInfoFound(mountReply.MountID))
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: mountReply.MountID, connection: connection}, &Reply{})
+	assert.Nil(err)
+	assert.False(mountInfoFound(mountReply.MountID))
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: mountReply.MountID, connection: connection}, &Reply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+
+	// Closing the connection over which RpcMount() was called drops the MountID
+
+	mountReply = &MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", connection: connection}, mountReply)
+	assert.Nil(err)
+	assert.True(mountInfoFound(mountReply.MountID))
+
+	connection.release()
+	assert.False(mountInfoFound(mountReply.MountID))
+
+	// A MountID idle since before the cutoff is reaped
+
+	mountReply = &MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+	assert.True(mountInfoFound(mountReply.MountID))
+
+	reapIdleMounts(time.Now().Add(-time.Hour))
+	assert.True(mountInfoFound(mountReply.MountID))
+
+	reapIdleMounts(time.Now().Add(time.Second))
+	assert.False(mountInfoFound(mountReply.MountID))
+}
+
+func TestRpcCompound(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	// Steps may refer to the InodeNumber resulting from earlier steps
+
+	compoundRequest := &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps: []CompoundStep{
+			{Mkdir: &MkdirRequest{InodeHandle: InodeHandle{InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "compound-dir", FileMode: 0755}},
+			{InodeNumberFromStep: 1, Create: &CreateRequest{Basename: "compound-file", FileMode: 0644}},
+			{InodeNumberFromStep: 2, SetXAttr: &SetXAttrRequest{AttrName: "user.compound", AttrValue: []byte("value")}},
+			{InodeNumberFromStep: 2, GetStat: &GetStatRequest{}},
+			{InodeNumberFromStep: 1, Lookup: &LookupRequest{Basename: "compound-file"}},
+			{InodeNumberFromStep: 5, GetXAttr: &GetXAttrRequest{AttrName: "user.compound"}},
+		},
+	}
+	compoundReply := &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(6, len(compoundReply.StepReplies))
+	for _, stepReply := range compoundReply.StepReplies {
+		assert.Equal(uint64(0), stepReply.Errno)
+	}
+	dirInodeNumber := compoundReply.StepReplies[0].InodeNumber
+	fileInodeNumber := compoundReply.StepReplies[1].InodeNumber
+	assert.NotEqual(uint64(0), fileInodeNumber)
+	assert.Equal(fileInodeNumber, compoundReply.StepReplies[3].GetStat.StatInodeNumber)
+	assert.Equal(fileInodeNumber, compoundReply.StepReplies[4].InodeNumber)
+	assert.Equal([]byte("value"), compoundReply.StepReplies[5].GetXAttr.AttrValue)
+
+	// Execution stops at the first failing step unless ContinueOnError is set
+
+	compoundRequest = &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps: []CompoundStep{
+			{Lookup: &LookupRequest{InodeHandle: InodeHandle{InodeNumber: dirInodeNumber}, Basename: "missing"}},
+			{InodeNumberFromStep: 1, GetStat: &GetStatRequest{}},
+			{GetStat: &GetStatRequest{InodeHandle: InodeHandle{InodeNumber: dirInodeNumber}}},
+		},
+	}
+	compoundReply = &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(1, len(compoundReply.StepReplies))
+	assert.Equal(uint64(blunder.NotFoundError), compoundReply.StepReplies[0].Errno)
+
+	compoundRequest.ContinueOnError = true
+	compoundReply = &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(3, len(compoundReply.StepReplies))
+	assert.Equal(uint64(blunder.NotFoundError), compoundReply.StepReplies[0].Errno)
+	assert.Equal(uint64(blunder.InvalidArgError), compoundReply.StepReplies[1].Errno)
+	assert.Equal(uint64(0), compoundReply.StepReplies[2].Errno)
+
+	// A step must specify exactly one request and may only refer to earlier steps
+
+	compoundRequest = &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps: []CompoundStep{
+			{},
+			{InodeNumberFromStep: 3, GetStat: &GetStatRequest{}},
+		},
+		ContinueOnError: true,
+	}
+	compoundReply = &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(2, len(compoundReply.StepReplies))
+	assert.Equal(uint64(blunder.InvalidArgError), compoundReply.StepReplies[0].Errno)
+	assert.Equal(uint64(blunder.InvalidArgError), compoundReply.StepReplies[1].Errno)
+
+	// Steps recall conflicting leases (held via other mounts) just as the single operation RPCs do
+
+	otherMountReply := &MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, otherMountReply)
+	assert.Nil(err)
+
+	err = server.RpcAcquireInodeLease(&AcquireInodeLeaseRequest{InodeHandle: InodeHandle{MountID: otherMountReply.MountID, InodeNumber: fileInodeNumber}, LeaseType: uint32(fs.InodeLeaseWrite)}, &Reply{})
+	assert.Nil(err)
+
+	compoundRequest = &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps: []CompoundStep{
+			{SetXAttr: &SetXAttrRequest{InodeHandle: InodeHandle{InodeNumber: fileInodeNumber}, AttrName: "user.compound", AttrValue: []byte("recalled")}},
+		},
+	}
+	compoundReply = &CompoundReply{}
+	compoundErrChan := make(chan error, 1)
+
+	go func() {
+		compoundErrChan <- server.RpcCompound(compoundRequest, compoundReply)
+	}()
+
+	fetchInodeLeaseRecallsReply := &FetchInodeLeaseRecallsReply{}
+	err = server.RpcFetchInodeLeaseRecalls(&FetchInodeLeaseRecallsRequest{MountID: otherMountReply.MountID, TimeoutMs: 10000}, fetchInodeLeaseRecallsReply)
+	assert.Nil(err)
+	if assert.Equal(1, len(fetchInodeLeaseRecallsReply.Recalls)) {
+		assert.Equal(fileInodeNumber, fetchInodeLeaseRecallsReply.Recalls[0].InodeNumber)
+		assert.Equal(uint32(dlm.ReasonWriteRequest), fetchInodeLeaseRecallsReply.Recalls[0].Reason)
+	}
+
+	// ...without holding globals.gate while awaiting the release (lest a confMap change be held off)
+
+	gateLockedChan := make(chan struct{})
+
+	go func() {
+		globals.gate.Lock()
+		globals.gate.Unlock()
+		close(gateLockedChan)
+	}()
+
+	select {
+	case <-gateLockedChan:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RpcCompound() held globals.gate while awaiting lease recall")
+	}
+
+	err = server.RpcReleaseInodeLease(&ReleaseInodeLeaseRequest{InodeHandle: InodeHandle{MountID: otherMountReply.MountID, InodeNumber: fileInodeNumber}}, &Reply{})
+	assert.Nil(err)
+
+	select {
+	case err = <-compoundErrChan:
+		assert.Nil(err)
+		assert.Equal(1, len(compoundReply.StepReplies))
+		assert.Equal(uint64(0), compoundReply.StepReplies[0].Errno)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RpcCompound() was not released by RpcReleaseInodeLease()")
+	}
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: otherMountReply.MountID}, &Reply{})
+	assert.Nil(err)
+
+	// Clean up
+
+	compoundRequest = &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps: []CompoundStep{
+			{Unlink: &UnlinkRequest{InodeHandle: InodeHandle{InodeNumber: dirInodeNumber}, Basename: "compound-file"}},
+			{Rmdir: &UnlinkRequest{InodeHandle: InodeHandle{InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "compound-dir"}},
+		},
+	}
+	compoundReply = &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(2, len(compoundReply.StepReplies))
+	assert.Equal(uint64(0), compoundReply.StepReplies[1].Errno)
+
+	err = server.RpcCompound(&CompoundRequest{MountID: 0}, &CompoundReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+}
+
+func TestRpcCompoundGate(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	mountHandle, err := lookupMountHandle(nil, mountReply.MountID)
+	assert.Nil(err)
+
+	compoundRequest := &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps:   make([]CompoundStep, compoundMaxSteps),
+	}
+	compoundRequest.Steps[0] = CompoundStep{Mkdir: &MkdirRequest{InodeHandle: InodeHandle{InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "compound-gate-dir", FileMode: 0755}}
+	for stepIndex := 1; stepIndex < compoundMaxSteps; stepIndex++ {
+		compoundRequest.Steps[stepIndex] = CompoundStep{InodeNumberFromStep: 1, Mkdir: &MkdirRequest{Basename: fmt.Sprintf("%04d", stepIndex), FileMode: 0755}}
+	}
+	compoundReply := &CompoundReply{}
+	compoundErrChan := make(chan error, 1)
+
+	go func() {
+		compoundErrChan <- server.RpcCompound(compoundRequest, compoundReply)
+	}()
+
+	// Once the compound is underway, a SIGHUP-triggered confMap change must await its completion
+
+	var dirInodeNumber inode.InodeNumber
+
+	for {
+		dirInodeNumber, err = mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, inode.RootDirInodeNumber, "compound-gate-dir")
+		if nil == err {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	globals.gate.Lock()
+	subdirsCreated := 0
+	for stepIndex := 1; stepIndex < compoundMaxSteps; stepIndex++ {
+		_, err = mountHandle.Lookup(inode.InodeRootUserID, inode.InodeGroupID(0), nil, dirInodeNumber, fmt.Sprintf("%04d", stepIndex))
+		if nil == err {
+			subdirsCreated++
+		}
+	}
+	globals.gate.Unlock()
+
+	assert.Equal(compoundMaxSteps-1, subdirsCreated)
+
+	err = <-compoundErrChan
+	assert.Nil(err)
+	assert.Equal(compoundMaxSteps, len(compoundReply.StepReplies))
+
+	// Clean up
+
+	compoundRequest = &CompoundRequest{
+		MountID: mountReply.MountID,
+		Steps:   make([]CompoundStep, compoundMaxSteps),
+	}
+	for stepIndex := 1; stepIndex < compoundMaxSteps; stepIndex++ {
+		compoundRequest.Steps[stepIndex-1] = CompoundStep{Rmdir: &UnlinkRequest{InodeHandle: InodeHandle{InodeNumber: uint64(dirInodeNumber)}, Basename: fmt.Sprintf("%04d", stepIndex)}}
+	}
+	compoundRequest.Steps[compoundMaxSteps-1] = CompoundStep{Rmdir: &UnlinkRequest{InodeHandle: InodeHandle{InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "compound-gate-dir"}}
+	compoundReply = &CompoundReply{}
+
+	err = server.RpcCompound(compoundRequest, compoundReply)
+	assert.Nil(err)
+	assert.Equal(compoundMaxSteps, len(compoundReply.StepReplies))
+	assert.Equal(uint64(0), compoundReply.StepReplies[compoundMaxSteps-1].Errno)
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: mountReply.MountID}, &Reply{})
+	assert.Nil(err)
+}
+
+func TestBinaryRPC(t *testing.T) {
+	assert := assert.New(t)
+
+	doNegotiate := func(encodingName string) (conn net.Conn, errno uint64, err error) {
+		conn, err = net.Dial("tcp", "localhost:22346")
+		if nil != err {
+			return
+		}
+
+		_, _ = conn.Write(makeBytesUint64(uint64(len(encodingName))))
+		_, _ = conn.Write([]byte(encodingName))
+		errnoBuf := make([]byte, 8)
+		_, err = io.ReadFull(conn, errnoBuf)
+		errno = *(*uint64)(unsafe.Pointer(&errnoBuf[0]))
+		return
+	}
+
+	conn, errno, err := doNegotiate("cstruct")
+	assert.Nil(err)
+	assert.Equal(uint64(blunder.NotSupportedError), errno)
+	conn.Close()
+
+	conn, errno, err = doNegotiate(binaryEncodingGob)
+	assert.Nil(err)
+	assert.Equal(uint64(0), errno)
+
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	pingReply := &PingReply{}
+	err = client.Call("Server.RpcPing", &PingReq{Message: "ping"}, pingReply)
+	assert.Nil(err)
+	assert.Equal("pong 4 bytes", pingReply.Message)
+
+	mountReply := &MountReply{}
+	err = client.Call("Server.RpcMount", &MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	statReply := &StatStruct{}
+	err = client.Call("Server.RpcGetStat", &GetStatRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: mountReply.RootDirInodeNumber}}, statReply)
+	assert.Nil(err)
+	assert.Equal(mountReply.RootDirInodeNumber, statReply.StatInodeNumber)
+
+	err = client.Call("Server.RpcGetStat", &GetStatRequest{InodeHandle: InodeHandle{MountID: 0, InodeNumber: mountReply.RootDirInodeNumber}}, statReply)
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.BadMountIDError), err.Error())
+}
+
+func TestRpcWatch(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	mkdirReply := &InodeReply{}
+	err = server.RpcMkdir(&MkdirRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "watch-dir", FileMode: 0755}, mkdirReply)
+	assert.Nil(err)
+
+	watchAddReply := &WatchAddReply{}
+	err = server.RpcWatchAdd(&WatchAddRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: mkdirReply.InodeNumber}}, watchAddReply)
+	assert.Nil(err)
+
+	// An outstanding RpcFetchWatchEvents() returns as soon as an event is queued
+
+	fetchDoneChan := make(chan *FetchWatchEventsReply, 1)
+	go func() {
+		fetchWatchEventsReply := &FetchWatchEventsReply{}
+		fetchErr := server.RpcFetchWatchEvents(&FetchWatchEventsRequest{MountID: mountReply.MountID, WatchID: watchAddReply.WatchID, TimeoutMs: 30000}, fetchWatchEventsReply)
+		assert.Nil(fetchErr)
+		fetchDoneChan <- fetchWatchEventsReply
+	}()
+
+	createReply := &InodeReply{}
+	err = server.RpcCreate(&CreateRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: mkdirReply.InodeNumber}, Basename: "watch-file", FileMode: 0644}, createReply)
+	assert.Nil(err)
+
+	select {
+	case fetchWatchEventsReply := <-fetchDoneChan:
+		assert.Equal(1, len(fetchWatchEventsReply.Events))
+		if 1 == len(fetchWatchEventsReply.Events) {
+			assert.Equal(uint32(fs.WatchEventCreate), fetchWatchEventsReply.Events[0].Type)
+			assert.Equal(mkdirReply.InodeNumber, fetchWatchEventsReply.Events[0].DirInodeNumber)
+			assert.Equal("watch-file", fetchWatchEventsReply.Events[0].Basename)
+			assert.Equal(createReply.InodeNumber, fetchWatchEventsReply.Events[0].InodeNumber)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("RpcFetchWatchEvents() was not woken by RpcCreate()")
+	}
+
+	err = server.RpcUnlink(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: mkdirReply.InodeNumber}, Basename: "watch-file"}, &Reply{})
+	assert.Nil(err)
+
+	fetchWatchEventsReply := &FetchWatchEventsReply{}
+	err = server.RpcFetchWatchEvents(&FetchWatchEventsRequest{MountID: mountReply.MountID, WatchID: watchAddReply.WatchID}, fetchWatchEventsReply)
+	assert.Nil(err)
+	assert.Equal(1, len(fetchWatchEventsReply.Events))
+	if 1 == len(fetchWatchEventsReply.Events) {
+		assert.Equal(uint32(fs.WatchEventUnlink), fetchWatchEventsReply.Events[0].Type)
+	}
+
+	// Once removed, the watch is gone
+
+	err = server.RpcWatchRemove(&WatchRemoveRequest{MountID: mountReply.MountID, WatchID: watchAddReply.WatchID}, &Reply{})
+	assert.Nil(err)
+
+	err = server.RpcFetchWatchEvents(&FetchWatchEventsRequest{MountID: mountReply.MountID, WatchID: watchAddReply.WatchID}, &FetchWatchEventsReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotFoundError), err.Error())
+
+	err = server.RpcRmdir(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "watch-dir"}, &Reply{})
+	assert.Nil(err)
+
+	// A watch is added with the credentials bound to the MountID
+
+	err = server.RpcMkdir(&MkdirRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "private-watch-dir", FileMode: 0700}, mkdirReply)
+	assert.Nil(err)
+
+	userMountReply := &MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume", AuthUserID: 1000, AuthGroupID: 1000}, userMountReply)
+	assert.Nil(err)
+
+	err = server.RpcWatchAdd(&WatchAddRequest{InodeHandle: InodeHandle{MountID: userMountReply.MountID, InodeNumber: mkdirReply.InodeNumber}}, &WatchAddReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.PermDeniedError), err.Error())
+
+	err = server.RpcUnmount(&UnmountRequest{MountID: userMountReply.MountID}, &Reply{})
+	assert.Nil(err)
+
+	err = server.RpcRmdir(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "private-watch-dir"}, &Reply{})
+	assert.Nil(err)
+}
+
+func TestRpcFetchJournal(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	// Skip past whatever other tests have already recorded
+
+	cursor := ""
+	for {
+		fetchJournalReply := &FetchJournalReply{}
+		err = server.RpcFetchJournal(&FetchJournalRequest{MountID: mountReply.MountID, Cursor: cursor}, fetchJournalReply)
+		assert.Nil(err)
+		if (nil != err) || (0 == len(fetchJournalReply.Records)) {
+			break
+		}
+		cursor = fetchJournalReply.Cursor
+	}
+
+	mkdirReply := &InodeReply{}
+	err = server.RpcMkdir(&MkdirRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "journal-dir", FileMode: 0755}, mkdirReply)
+	assert.Nil(err)
+
+	err = server.RpcRmdir(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "journal-dir"}, &Reply{})
+	assert.Nil(err)
+
+	fetchJournalReply := &FetchJournalReply{}
+	err = server.RpcFetchJournal(&FetchJournalRequest{MountID: mountReply.MountID, Cursor: cursor, MaxRecs: 1}, fetchJournalReply)
+	assert.Nil(err)
+	assert.Equal(1, len(fetchJournalReply.Records))
+	if 1 == len(fetchJournalReply.Records) {
+		assert.Equal(uint32(fs.JournalOpCreate), fetchJournalReply.Records[0].Op)
+		assert.Equal(mkdirReply.InodeNumber, fetchJournalReply.Records[0].InodeNumber)
+		assert.Equal(uint64(inode.RootDirInodeNumber), fetchJournalReply.Records[0].ParentInodeNumber)
+		assert.Equal("journal-dir", fetchJournalReply.Records[0].Basename)
+	}
+
+	err = server.RpcFetchJournal(&FetchJournalRequest{MountID: mountReply.MountID, Cursor: fetchJournalReply.Cursor}, fetchJournalReply)
+	assert.Nil(err)
+	assert.Equal(1, len(fetchJournalReply.Records))
+	if 1 == len(fetchJournalReply.Records) {
+		assert.Equal(uint32(fs.JournalOpUnlink), fetchJournalReply.Records[0].Op)
+		assert.Equal("journal-dir", fetchJournalReply.Records[0].Basename)
+	}
+
+	err = server.RpcFetchJournal(&FetchJournalRequest{MountID: mountReply.MountID, Cursor: "NotACursor"}, &FetchJournalReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.InvalidArgError), err.Error())
+}
+
+func TestRpcFetchQuotaReport(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	fetchQuotaReportReply := &FetchQuotaReportReply{}
+	err = server.RpcFetchQuotaReport(&FetchQuotaReportRequest{MountID: mountReply.MountID}, fetchQuotaReportReply)
+	assert.Nil(err)
+	assert.Equal(1, len(fetchQuotaReportReply.Quotas))
+	if 1 == len(fetchQuotaReportReply.Quotas) {
+		assert.Equal("JrpcfsTestQuota", fetchQuotaReportReply.Quotas[0].Name)
+		assert.Equal(uint32(fs.QuotaTypeUser), fetchQuotaReportReply.Quotas[0].Type)
+		assert.Equal(uint64(1000), fetchQuotaReportReply.Quotas[0].ID)
+		assert.Equal(uint64(1000000), fetchQuotaReportReply.Quotas[0].SoftInodes)
+		assert.Equal(uint64(2000000), fetchQuotaReportReply.Quotas[0].HardInodes)
+		assert.Equal(uint64(0), fetchQuotaReportReply.Quotas[0].HardBytes)
+		assert.Equal(int64(24*time.Hour), fetchQuotaReportReply.Quotas[0].GracePeriod)
+		assert.Equal(int64(0), fetchQuotaReportReply.Quotas[0].InodesGraceExpiration)
+	}
+}
+
+func TestRpcTrash(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume2"}, mountReply)
+	assert.Nil(err)
+
+	mkdirReply := &InodeReply{}
+	err = server.RpcMkdir(&MkdirRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "trash-dir", FileMode: 0755}, mkdirReply)
+	assert.Nil(err)
+
+	err = server.RpcRmdir(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "trash-dir"}, &Reply{})
+	assert.Nil(err)
+
+	lookupReply := &InodeReply{}
+	err = server.RpcLookup(&LookupRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "trash-dir"}, lookupReply)
+	assert.NotNil(err)
+
+	fetchTrashReply := &FetchTrashReply{}
+	err = server.RpcFetchTrash(&FetchTrashRequest{MountID: mountReply.MountID}, fetchTrashReply)
+	assert.Nil(err)
+	assert.Equal(1, len(fetchTrashReply.Entries))
+	if 1 != len(fetchTrashReply.Entries) {
+		return
+	}
+	assert.Equal(mkdirReply.InodeNumber, fetchTrashReply.Entries[0].InodeNumber)
+	assert.Equal(uint32(inode.DirType), fetchTrashReply.Entries[0].InodeType)
+	assert.Equal(uint64(inode.RootDirInodeNumber), fetchTrashReply.Entries[0].ParentInodeNumber)
+	assert.Equal("trash-dir", fetchTrashReply.Entries[0].Basename)
+	assert.Equal(int64(time.Hour), fetchTrashReply.Entries[0].ExpirationTime-fetchTrashReply.Entries[0].DeletionTime)
+
+	err = server.RpcRestoreTrash(&RestoreTrashRequest{MountID: mountReply.MountID, Name: fetchTrashReply.Entries[0].Name}, &Reply{})
+	assert.Nil(err)
+
+	err = server.RpcLookup(&LookupRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "trash-dir"}, lookupReply)
+	assert.Nil(err)
+	assert.Equal(mkdirReply.InodeNumber, lookupReply.InodeNumber)
+
+	err = server.RpcRestoreTrash(&RestoreTrashRequest{MountID: mountReply.MountID, Name: fetchTrashReply.Entries[0].Name}, &Reply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotFoundError), err.Error())
+
+	mountReply = &MountReply{}
+	err = server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	err = server.RpcFetchTrash(&FetchTrashRequest{MountID: mountReply.MountID}, &FetchTrashReply{})
+	assert.NotNil(err)
+	assert.Equal(fmt.Sprintf("errno: %d", blunder.NotFoundError), err.Error())
+}
+
+func TestRpcCloneRange(t *testing.T) {
+	assert := assert.New(t)
+	server := &Server{}
+
+	mountReply := &MountReply{}
+	err := server.RpcMount(&MountRequest{VolumeName: "SomeVolume"}, mountReply)
+	assert.Nil(err)
+
+	srcCreateReply := &InodeReply{}
+	err = server.RpcCreate(&CreateRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "clone-src", FileMode: 0644}, srcCreateReply)
+	assert.Nil(err)
+	err = server.RpcWrite(&WriteRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: srcCreateReply.InodeNumber}, Buf: []byte("cloned contents")}, &WriteReply{})
+	assert.Nil(err)
+
+	dstCreateReply := &InodeReply{}
+	err = server.RpcCreate(&CreateRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "clone-dst", FileMode: 0644}, dstCreateReply)
+	assert.Nil(err)
+
+	cloneRangeReply := &CloneRangeReply{}
+	err = server.RpcCloneRange(&CloneRangeRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: dstCreateReply.InodeNumber}, Offset: 0, SrcInodeNumber: srcCreateReply.InodeNumber, SrcOffset: 7, Length: 100}, cloneRangeReply)
+	assert.Nil(err)
+	assert.Equal(uint64(8), cloneRangeReply.ClonedLength)
+
+	readReply := &ReadReply{}
+	err = server.RpcRead(&ReadRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: dstCreateReply.InodeNumber}, Offset: 0, Length: 100}, readReply)
+	assert.Nil(err)
+	assert.Equal([]byte("contents"), readReply.Buf)
+
+	err = server.RpcCloneRange(&CloneRangeRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, SrcInodeNumber: srcCreateReply.InodeNumber, Length: 100}, &CloneRangeReply{})
+	assert.NotNil(err)
+
+	err = server.RpcUnlink(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "clone-src"}, &Reply{})
+	assert.Nil(err)
+	err = server.RpcUnlink(&UnlinkRequest{InodeHandle: InodeHandle{MountID: mountReply.MountID, InodeNumber: uint64(inode.RootDirInodeNumber)}, Basename: "clone-dst"}, &Reply{})
+	assert.Nil(err)
+}
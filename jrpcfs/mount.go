@@ -0,0 +1,197 @@
+package jrpcfs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/swiftstack/ProxyFS/blunder"
+	"github.com/swiftstack/ProxyFS/conf"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// Each MountID returned by RpcMount() holds an fs.MountHandle (and, with it, any locks and inode leases
+// obtained via it). A MountID is dropped (and its fs.MountHandle unmounted) when:
+//
+//   RpcUnmount() is called for it
+//   the connection over which RpcMount() was called closes
+//   it has seen no activity for JSONRPCServer.MountIdleTimeout (if non-zero... the default)
+//
+// Any request referencing a MountID (including those on the fast I/O port) counts as activity.
+
+// MountInfoStruct describes an active MountID (as returned by FetchMountInfoList())
+type MountInfoStruct struct {
+	MountID          uint64
+	VolumeName       string
+	ClientAddr       string // "" if RpcMount() was not called over a connection
+	AuthUserID       uint64
+	AuthGroupID      uint64
+	CreateTime       time.Time
+	LastActivityTime time.Time
+}
+
+// FetchMountInfoList returns a description of each active MountID (sorted by MountID)
+func FetchMountInfoList() (mountInfoList []MountInfoStruct) {
+	globals.Lock()
+
+	mountInfoList = make([]MountInfoStruct, 0, len(globals.mountIDMap))
+
+	for mountID, mount := range globals.mountIDMap {
+		mountInfoList = append(mountInfoList, MountInfoStruct{
+			MountID:          mountID,
+			VolumeName:       mount.mountHandle.VolumeName(),
+			ClientAddr:       mount.clientAddr,
+			AuthUserID:       uint64(mount.userID),
+			AuthGroupID:      uint64(mount.groupID),
+			CreateTime:       mount.createTime,
+			LastActivityTime: mount.lastActivityTime,
+		})
+	}
+
+	globals.Unlock()
+
+	sort.Slice(mountInfoList, func(i, j int) bool { return mountInfoList[i].MountID < mountInfoList[j].MountID })
+
+	return
+}
+
+// trackMount records that mountID was returned by RpcMount() over the connection (for releaseMountsWhileLocked())
+func (connection *connectionStruct) trackMount(mountID uint64) {
+	if nil == connection {
+		// RpcMount() not called via a connection (e.g. in a test)
+		return
+	}
+
+	connection.Lock()
+	connection.mountIDSet[mountID] = struct{}{}
+	connection.Unlock()
+}
+
+// releaseMountsWhileLocked unmounts the MountIDs returned by RpcMount() over the now closed connection
+//
+// Note: Caller must hold both globals.gate.RLock() and connection.Lock()
+func (connection *connectionStruct) releaseMountsWhileLocked() {
+	for mountID := range connection.mountIDSet {
+		err := removeMountID(mountID)
+		if (nil != err) && !blunder.Is(err, blunder.BadMountIDError) {
+			logger.ErrorfWithError(err, "Unmount() of MountID %v failed", mountID)
+		}
+	}
+
+	connection.mountIDSet = make(map[uint64]struct{})
+}
+
+// unmountMountID drops mountID (e.g. for RpcUnmount())
+//
+// Note: Caller must hold globals.gate.RLock()
+func unmountMountID(mountID uint64) (err error) {
+	var (
+		connection *connectionStruct
+	)
+
+	globals.Lock()
+	mount, ok := globals.mountIDMap[mountID]
+	if ok {
+		connection = mount.connection
+	}
+	globals.Unlock()
+
+	if nil != connection {
+		connection.Lock()
+		delete(connection.mountIDSet, mountID)
+		connection.Unlock()
+	}
+
+	err = removeMountID(mountID)
+
+	return
+}
+
+// removeMountID removes mountID from globals.mountIDMap and unmounts its fs.MountHandle
+//
+// Note: Caller must hold globals.gate.RLock()
+func removeMountID(mountID uint64) (err error) {
+	globals.Lock()
+	mount, ok := globals.mountIDMap[mountID]
+	if ok {
+		delete(globals.mountIDMap, mountID)
+	}
+	globals.Unlock()
+
+	if !ok {
+		err = fmt.Errorf("MountID %v not found in jrpcfs globals.mountIDMap", mountID)
+		err = blunder.AddError(err, blunder.BadMountIDError)
+		return
+	}
+
+	err = mount.mountHandle.Unmount()
+
+	return
+}
+
+func mountReaperUp(confMap conf.ConfMap) {
+	var (
+		err error
+	)
+
+	globals.mountIdleTimeout, err = confMap.FetchOptionValueDuration("JSONRPCServer", "MountIdleTimeout")
+	if nil != err {
+		globals.mountIdleTimeout = 0 // Never reap idle mounts
+	}
+
+	globals.mountReaperStopChan = make(chan struct{})
+
+	if 0 < globals.mountIdleTimeout {
+		globals.mountReaperDoneWaiter.Add(1)
+		go mountReaper()
+	}
+}
+
+func mountReaperDown() {
+	close(globals.mountReaperStopChan)
+	globals.mountReaperDoneWaiter.Wait()
+}
+
+// mountReaper periodically drops MountIDs idle for at least globals.mountIdleTimeout
+func mountReaper() {
+	ticker := time.NewTicker(globals.mountIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-globals.mountReaperStopChan:
+			globals.mountReaperDoneWaiter.Done()
+			return
+		case <-ticker.C:
+			reapIdleMounts(time.Now().Add(-globals.mountIdleTimeout))
+		}
+	}
+}
+
+// reapIdleMounts drops MountIDs with no activity since idleCutoff
+func reapIdleMounts(idleCutoff time.Time) {
+	var (
+		idleMountIDList []uint64
+	)
+
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	idleMountIDList = make([]uint64, 0)
+
+	globals.Lock()
+	for mountID, mount := range globals.mountIDMap {
+		if mount.lastActivityTime.Before(idleCutoff) {
+			idleMountIDList = append(idleMountIDList, mountID)
+		}
+	}
+	globals.Unlock()
+
+	for _, mountID := range idleMountIDList {
+		logger.Infof("Reaping MountID %v idle since before %v", mountID, idleCutoff)
+		err := unmountMountID(mountID)
+		if (nil != err) && !blunder.Is(err, blunder.BadMountIDError) {
+			logger.ErrorfWithError(err, "Unmount() of idle MountID %v failed", mountID)
+		}
+	}
+}
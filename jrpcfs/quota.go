@@ -0,0 +1,57 @@
+package jrpcfs
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// RpcFetchQuotaReport returns the limits and (as of the last flush) usage of each quota of a mounted volume
+func (s *Server) RpcFetchQuotaReport(in *FetchQuotaReportRequest, reply *FetchQuotaReportReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	quotaReport, err := fs.FetchQuotaReport(mountHandle.VolumeName())
+	if nil != err {
+		return
+	}
+
+	reply.Quotas = make([]QuotaStatus, 0, len(quotaReport))
+	for _, quotaStatus := range quotaReport {
+		reply.Quotas = append(reply.Quotas, QuotaStatus{
+			Name:                  quotaStatus.Name,
+			Type:                  uint32(quotaStatus.Type),
+			ID:                    quotaStatus.ID,
+			Path:                  quotaStatus.Path,
+			UsedBytes:             quotaStatus.UsedBytes,
+			UsedInodes:            quotaStatus.UsedInodes,
+			SoftBytes:             quotaStatus.SoftBytes,
+			HardBytes:             quotaStatus.HardBytes,
+			SoftInodes:            quotaStatus.SoftInodes,
+			HardInodes:            quotaStatus.HardInodes,
+			GracePeriod:           int64(quotaStatus.GracePeriod),
+			BytesGraceExpiration:  quotaGraceExpirationToUnixNano(quotaStatus.BytesGraceExpiration),
+			InodesGraceExpiration: quotaGraceExpirationToUnixNano(quotaStatus.InodesGraceExpiration),
+		})
+	}
+	return
+}
+
+func quotaGraceExpirationToUnixNano(graceExpiration time.Time) (unixNano int64) {
+	if graceExpiration.IsZero() {
+		unixNano = 0
+	} else {
+		unixNano = graceExpiration.UnixNano()
+	}
+	return
+}
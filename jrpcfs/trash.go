@@ -0,0 +1,58 @@
+package jrpcfs
+
+import (
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// RpcFetchTrash returns the entries (in the order they were deleted) in the trash of a mounted volume
+func (s *Server) RpcFetchTrash(in *FetchTrashRequest, reply *FetchTrashReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	trashEntries, err := fs.FetchTrash(mountHandle.VolumeName())
+	if nil != err {
+		return
+	}
+
+	reply.Entries = make([]TrashEntry, 0, len(trashEntries))
+	for _, trashEntry := range trashEntries {
+		reply.Entries = append(reply.Entries, TrashEntry{
+			Name:              trashEntry.Name,
+			InodeNumber:       uint64(trashEntry.InodeNumber),
+			InodeType:         uint32(trashEntry.InodeType),
+			ParentInodeNumber: uint64(trashEntry.ParentInodeNumber),
+			Basename:          trashEntry.Basename,
+			DeletionTime:      trashEntry.DeletionTime.UnixNano(),
+			ExpirationTime:    trashEntry.ExpirationTime.UnixNano(),
+		})
+	}
+	return
+}
+
+// RpcRestoreTrash moves the named trash entry of a mounted volume back to its original parent directory
+func (s *Server) RpcRestoreTrash(in *RestoreTrashRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = fs.RestoreTrash(mountHandle.VolumeName(), in.Name)
+	return
+}
@@ -0,0 +1,96 @@
+package jrpcfs
+
+import (
+	"time"
+
+	"github.com/swiftstack/ProxyFS/fs"
+	"github.com/swiftstack/ProxyFS/inode"
+	"github.com/swiftstack/ProxyFS/logger"
+)
+
+// A client watches a directory by calling RpcWatchAdd() and then keeping an RpcFetchWatchEvents() call
+// outstanding on its connection. As net/rpc offers no way for the server to initiate a message, this is
+// how events are pushed to the client: each call returns as soon as an event is queued for the watch (or
+// once TimeoutMs, bounded by maxWatchEventsWait, passes) and the client immediately issues the next one.
+//
+// A watch belongs to the MountID that added it and so is removed by RpcUnmount() or, for mounts made
+// over it, when the connection closes.
+
+// maxWatchEventsWait bounds how long RpcFetchWatchEvents() will wait for an event
+const maxWatchEventsWait = 60 * time.Second
+
+func (s *Server) RpcWatchAdd(in *WatchAddRequest, reply *WatchAddReply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, userID, groupID, err := lookupMount(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	watchID, err := mountHandle.AddWatch(userID, groupID, nil, inode.InodeNumber(in.InodeNumber))
+	if nil != err {
+		return
+	}
+
+	reply.WatchID = uint64(watchID)
+	return
+}
+
+func (s *Server) RpcWatchRemove(in *WatchRemoveRequest, reply *Reply) (err error) {
+	globals.gate.RLock()
+	defer globals.gate.RUnlock()
+
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	if nil != err {
+		return
+	}
+
+	err = mountHandle.RemoveWatch(fs.WatchID(in.WatchID))
+	return
+}
+
+func (s *Server) RpcFetchWatchEvents(in *FetchWatchEventsRequest, reply *FetchWatchEventsReply) (err error) {
+	flog := logger.TraceEnter("in.", in)
+	defer func() { flog.TraceExitErr("reply.", err, reply) }()
+	defer func() { rpcEncodeError(&err) }() // Encode error for return by RPC
+
+	// Note that globals.gate is only held while looking up the mount... not while waiting for an event
+
+	globals.gate.RLock()
+	mountHandle, err := lookupMountHandle(in.connection, in.MountID)
+	globals.gate.RUnlock()
+	if nil != err {
+		return
+	}
+
+	timeout := time.Duration(in.TimeoutMs) * time.Millisecond
+	if timeout > maxWatchEventsWait {
+		timeout = maxWatchEventsWait
+	}
+
+	events, err := mountHandle.FetchWatchEvents(fs.WatchID(in.WatchID), timeout)
+	if nil != err {
+		return
+	}
+
+	reply.Events = make([]WatchEvent, 0, len(events))
+	for _, event := range events {
+		reply.Events = append(reply.Events, WatchEvent{
+			Type:           uint32(event.Type),
+			DirInodeNumber: uint64(event.DirInodeNumber),
+			Basename:       event.Basename,
+			InodeNumber:    uint64(event.InodeNumber),
+			Cookie:         event.Cookie,
+		})
+	}
+	return
+}
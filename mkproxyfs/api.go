@@ -22,11 +22,14 @@ const (
 	ModeNew Mode = iota
 	ModeOnlyIfNeeded
 	ModeReformat
+	ModeClone
 )
 
 func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings []string) (err error) {
 	var (
 		accountName       string
+		cloneSnapshotName string
+		cloneVolumeName   string
 		confMap           conf.ConfMap
 		containerList     []string
 		containerName     string
@@ -42,8 +45,9 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 	case ModeNew:
 	case ModeOnlyIfNeeded:
 	case ModeReformat:
+	case ModeClone:
 	default:
-		err = fmt.Errorf("mode (%v) must be one of ModeNew (%v), ModeOnlyIfNeeded (%v), or ModeReformat (%v)", mode, ModeNew, ModeOnlyIfNeeded, ModeReformat)
+		err = fmt.Errorf("mode (%v) must be one of ModeNew (%v), ModeOnlyIfNeeded (%v), ModeReformat (%v), or ModeClone (%v)", mode, ModeNew, ModeOnlyIfNeeded, ModeReformat, ModeClone)
 		return
 	}
 
@@ -75,6 +79,20 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 		return
 	}
 
+	if ModeClone == mode {
+		cloneVolumeName, err = confMap.FetchOptionValueString(utils.VolumeNameConfSection(volumeNameToFormat), "CloneSourceVolumeName")
+		if nil != err {
+			err = fmt.Errorf("mode == ModeClone (%v) requires CloneSourceVolumeName: %v", ModeClone, err)
+			return
+		}
+
+		cloneSnapshotName, err = confMap.FetchOptionValueString(utils.VolumeNameConfSection(volumeNameToFormat), "CloneSourceSnapshotName")
+		if nil != err {
+			err = fmt.Errorf("mode == ModeClone (%v) requires CloneSourceSnapshotName: %v", ModeClone, err)
+			return
+		}
+	}
+
 	// Call Up() for required packages (deferring their Down() calls until function return)
 
 	err = logger.Up(confMap)
@@ -140,6 +158,11 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 
 			err = fmt.Errorf("%v found to be non-empty with mode == ModeNew (%v)", accountName, ModeNew)
 			return
+		case ModeClone:
+			// If Swift Account is not empty && ModeClone, exit with failure
+
+			err = fmt.Errorf("%v found to be non-empty with mode == ModeClone (%v)", accountName, ModeClone)
+			return
 		case ModeOnlyIfNeeded:
 			// If Swift Account is not empty && ModeOnlyIfNeeded, exit successfully
 
@@ -147,6 +170,13 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 			return
 		case ModeReformat:
 			// If Swift Account is not empty && ModeReformat, clear out accountName
+			// (unless a clone depends on it) after releasing any reference it holds as a clone
+
+			err = headhunter.ReleaseCloneReferences(confMap, volumeNameToFormat)
+			if nil != err {
+				err = fmt.Errorf("unable to reformat %v: %v", accountName, err)
+				return
+			}
 
 			for !isEmpty {
 				for _, containerName = range containerList {
@@ -209,7 +239,11 @@ func Format(mode Mode, volumeNameToFormat string, confFile string, confStrings [
 
 	// Format Swift Account (who's error return will suffice for this function's error return)
 
-	err = headhunter.Format(confMap, volumeNameToFormat)
+	if ModeClone == mode {
+		err = headhunter.FormatClone(confMap, volumeNameToFormat, cloneVolumeName, cloneSnapshotName)
+	} else {
+		err = headhunter.Format(confMap, volumeNameToFormat)
+	}
 
 	return
 }
@@ -12,11 +12,15 @@ import (
 func usage() {
 	fmt.Println("mkproxyfs -?")
 	fmt.Println("   Prints this help text")
-	fmt.Println("mkproxyfs -N|-I|-F VolumeNameToFormat ConfFile [ConfFileOverrides]*")
+	fmt.Println("mkproxyfs -N|-I|-F|-C VolumeNameToFormat ConfFile [ConfFileOverrides]*")
 	fmt.Println("   -N indicates that VolumeNameToFormat must be empty")
 	fmt.Println("   -I indicates that VolumeNameToFormat should only be formatted if necessary")
 	fmt.Println("   -F indicates that VolumeNameToFormat should be formatted regardless")
 	fmt.Println("      Note: This may take awhile to clear out existing objects/containers")
+	fmt.Println("   -C indicates that VolumeNameToFormat (which must be empty) should be formatted")
+	fmt.Println("      as a writable clone of the snapshot named by its CloneSourceSnapshotName")
+	fmt.Println("      of the volume named by its CloneSourceVolumeName")
+	fmt.Println("      Note: These may be supplied as ConfFileOverrides")
 	fmt.Println("  VolumeNameToFormat indicates which Volume in ConfFile is to be formatted")
 	fmt.Println("      Note: VolumeNameToFormat need not be marked as active on any peer")
 	fmt.Println("  ConfFile specifies the .conf file as also passed to proxyfsd et. al.")
@@ -46,6 +50,8 @@ func main() {
 		mode = mkproxyfs.ModeOnlyIfNeeded
 	case "-F":
 		mode = mkproxyfs.ModeReformat
+	case "-C":
+		mode = mkproxyfs.ModeClone
 	default:
 		usage()
 		os.Exit(1)
@@ -83,6 +83,7 @@ import six
 import socket
 import time
 import xml.etree.ElementTree as ET
+import zlib
 from six.moves.urllib import parse as urllib_parse
 from StringIO import StringIO
 
@@ -120,6 +121,10 @@ DIRECTORY_CONTENT_TYPE = "application/directory"
 
 ZERO_FILL_PATH = "/0"
 
+# Prefix of the path of a compressed frame of a log segment; the rest is
+# "<frame-offset>/<frame-length>" followed by the log segment's path.
+FRAME_PATH_PREFIX = "/frame/"
+
 LEASE_RENEWAL_INTERVAL = 5  # seconds
 
 ORIGINAL_MD5_HEADER = "X-Object-Sysmeta-ProxyFS-Initial-MD5"
@@ -207,6 +212,11 @@ def listing_iter_from_read_plan(read_plan):
         ("/v1/AUTH_test/Replicated3Way_1/0000000000000078", None, None, 0, 18),
         ("/v1/AUTH_test/Replicated3Way_1/000000000000007A", None, None, 0, 88),
     ]
+
+    A read-plan entry with a non-zero "FrameLength" refers to a compressed
+    frame of its log segment; its "Offset" is relative to the frame's
+    decompressed content. Such an entry becomes a path beginning with
+    FRAME_PATH_PREFIX (which ZeroFiller knows how to serve).
     """
     if read_plan is None:
         # ProxyFS likes to send null values instead of empty lists.
@@ -216,7 +226,7 @@ def listing_iter_from_read_plan(read_plan):
     # RPC-response parser all the way to here, but it's inefficient, in both
     # CPU cycles and programmer brainpower, to create some intermediate
     # representation just to avoid GoCase.
-    return [(rpe["ObjectPath"] or ZERO_FILL_PATH,
+    return [(segment_path_from_read_plan_entry(rpe),
              None,  # we don't know the segment's ETag
              None,  # we don't know the segment's length
              rpe["Offset"],
@@ -224,6 +234,15 @@ def listing_iter_from_read_plan(read_plan):
             for rpe in read_plan]
 
 
+def segment_path_from_read_plan_entry(rpe):
+    if not rpe["ObjectPath"]:
+        return ZERO_FILL_PATH
+    if rpe.get("FrameLength"):
+        return "%s%d/%d%s" % (FRAME_PATH_PREFIX, rpe["FrameOffset"],
+                              rpe["FrameLength"], rpe["ObjectPath"])
+    return rpe["ObjectPath"]
+
+
 def x_timestamp_from_epoch_ns(epoch_ns):
     """
     Convert a ProxyFS-style Unix timestamp to a Swift X-Timestamp header.
@@ -465,6 +484,10 @@ class ZeroFiller(object):
     """
     Internal middleware to handle the zero-fill portions of sparse files for
     object GET responses.
+
+    It also handles the compressed portions of files: it fetches the whole
+    compressed frame from the log segment, inflates it, and returns the
+    requested range of the frame's decompressed content.
     """
     ZEROES = "\x00" * 4096
 
@@ -484,9 +507,44 @@ class ZeroFiller(object):
                          "Content-Range": "%d-%d/%d" % (start, end, nbytes)},
                 app_iter=self.yield_n_zeroes(nbytes))
             return resp
+        elif req.path.startswith(FRAME_PATH_PREFIX):
+            return self.get_frame_range(req)
         else:
             return self.app
 
+    def get_frame_range(self, req):
+        frame_offset, frame_length, object_path = \
+            req.path[len(FRAME_PATH_PREFIX):].split("/", 2)
+        frame_offset = int(frame_offset)
+        frame_length = int(frame_length)
+
+        frame_req = swift_code.make_subrequest(
+            req.environ, path="/" + object_path + "?multipart-manifest=get",
+            method='GET',
+            headers={'x-auth-token': req.headers.get('x-auth-token'),
+                     'Range': "bytes=%d-%d" % (
+                         frame_offset, frame_offset + frame_length - 1)},
+            swift_source='PFS')
+        frame_resp = frame_req.get_response(self.app)
+        if not frame_resp.is_success:
+            return frame_resp
+
+        try:
+            # ProxyFS writes each frame as raw DEFLATE data (no zlib header)
+            content = zlib.decompress(frame_resp.body, -zlib.MAX_WBITS)
+        except zlib.error:
+            return swob.HTTPInternalServerError(request=req)
+
+        start, end = req.range.ranges[0]
+        if end is None or end >= len(content):
+            end = len(content) - 1
+        nbytes = end - start + 1
+        return swob.Response(
+            request=req, status=206,
+            headers={"Content-Length": nbytes,
+                     "Content-Range": "%d-%d/%d" % (start, end, len(content))},
+            body=content[start:end + 1])
+
     def yield_n_zeroes(self, n):
         # It's a little clunky, but it does avoid creating new strings of
         # zeroes over and over again, and it uses only a small amount of
@@ -19,6 +19,7 @@ import hashlib
 import json
 import mock
 import unittest
+import zlib
 from StringIO import StringIO
 from swift.common import swob
 from xml.etree import ElementTree
@@ -701,6 +702,51 @@ class TestObjectGet(BaseMiddlewareTest):
         self.assertEqual(status, "200 OK")
         self.assertEqual(body, "sparse" + ("\x00" * 10000) + "file")
 
+    def test_GET_compressed_frame(self):
+        # A compressed portion of a file refers to a (raw DEFLATE) frame
+        # somewhere in a log segment; the read-plan entry's offset is
+        # relative to the frame's decompressed content.
+        compressor = zlib.compressobj(9, zlib.DEFLATED, -zlib.MAX_WBITS)
+        frame = compressor.compress("xxxxxcompressed-contentxxxxx")
+        frame += compressor.flush()
+
+        self.app.register(
+            'GET', '/v1/AUTH_test/InternalContainerName/00000000000000D1',
+            200, {},
+            "plain:" + frame + "-junkety-junk")
+
+        def mock_RpcGetObject(get_object_req):
+            return {
+                "error": None,
+                "result": {
+                    "FileSize": 24,
+                    "Metadata": "",
+                    "InodeNumber": 1246,
+                    "NumWrites": 2,
+                    "ModificationTime": 1481152134331862558,
+                    "LeaseId": "e7e5f1b54ab5e1fb2d5d1f3d8f0f5b8d",
+                    "ReadEntsOut": [{
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/00000000000000D1"),
+                        "Offset": 0,
+                        "Length": 6
+                    }, {
+                        "ObjectPath": ("/v1/AUTH_test/InternalContainer"
+                                       "Name/00000000000000D1"),
+                        "Offset": 5,
+                        "Length": 18,
+                        "FrameOffset": 6,
+                        "FrameLength": len(frame)}]}}
+
+        req = swob.Request.blank('/v1/AUTH_test/c/compressed-file')
+
+        self.fake_rpc.register_handler(
+            "Server.RpcGetObject", mock_RpcGetObject)
+        status, headers, body = self.call_pfs(req)
+
+        self.assertEqual(status, "200 OK")
+        self.assertEqual(body, "plain:compressed-content")
+
     def test_GET_multiple_segments(self):
         # Typically, a GET request will include data from multiple log
         # segments. Small files written all at once might fit in a single
@@ -21,6 +21,7 @@ const (
 	SwiftObjTail                                // uses operations and bytes stats
 	SwiftObjPutCtxRead                          // uses operations, op bucketed bytes, and bytes stats
 	SwiftObjPutCtxSendChunk                     // uses operations, op bucketed bytes, and bytes stats
+	FileCompress                                // uses operations, bytes, and compressed bytes stats
 )
 
 func (ms MultipleStat) findStatStrings(numBytes uint64) (ops *string, bytes *string, entries *string, bbytes *string, app *string, overw *string) {
@@ -187,6 +188,10 @@ func (ms MultipleStat) findStatStrings(numBytes uint64) (ops *string, bytes *str
 		} else {
 			bbytes = &SwiftObjReadOpsOver64K
 		}
+	case FileCompress:
+		// file compress uses operations, bytes, and compressed bytes stats (see findCompressedBytesStatString())
+		ops = &FileCompressOps
+		bytes = &FileCompressBytes
 	case SwiftObjTail:
 		// swiftclient object-tail uses operations and bytes stats
 		ops = &SwiftObjTailOps
@@ -248,6 +253,15 @@ func computeBucketSuffix(bytes uint64) (suffix string) {
 	return
 }
 
+// findCompressedBytesStatString returns the compressed bytes stat (if any) of a MultipleStat.
+func (ms MultipleStat) findCompressedBytesStatString() (cbytes *string) {
+	switch ms {
+	case FileCompress:
+		cbytes = &FileCompressCompressedBytes
+	}
+	return
+}
+
 // Dump returns a map of all accumulated stats since process start.
 //
 //   Key   is a string containing the name of the stat
@@ -298,6 +312,13 @@ func incrementOperationsAndBytes(stat MultipleStat, bytes uint64) {
 	incrementSomething(bytesStat, bytes)
 }
 
+func incrementOperationsBytesAndCompressedBytes(stat MultipleStat, bytes uint64, compressedBytes uint64) {
+	opsStat, bytesStat, _, _, _, _ := stat.findStatStrings(bytes)
+	incrementSomething(opsStat, 1)
+	incrementSomething(bytesStat, bytes)
+	incrementSomething(stat.findCompressedBytesStatString(), compressedBytes)
+}
+
 func incrementOperationsEntriesAndBytes(stat MultipleStat, entries uint64, bytes uint64) {
 	opsStat, bytesStat, entriesStat, _, _, _ := stat.findStatStrings(bytes)
 	incrementSomething(opsStat, 1)
@@ -333,6 +354,14 @@ func IncrementOperationsAndBytes(stat MultipleStat, bytes uint64) {
 	go incrementOperationsAndBytes(stat, bytes)
 }
 
+// IncrementOperationsBytesAndCompressedBytes sends an increment of .operations, .bytes, and .compressed.bytes to statsd.
+//
+// The ratio of .bytes to .compressed.bytes is the achieved compression ratio.
+func IncrementOperationsBytesAndCompressedBytes(stat MultipleStat, bytes uint64, compressedBytes uint64) {
+	// Do this in a goroutine since channel operations are suprisingly expensive due to locking underneath
+	go incrementOperationsBytesAndCompressedBytes(stat, bytes, compressedBytes)
+}
+
 // IncrementOperationsEntriesAndBytes sends an increment of .operations, .entries, and .bytes to statsd.
 func IncrementOperationsEntriesAndBytes(stat MultipleStat, entries uint64, bytes uint64) {
 	// Do this in a goroutine since channel operations are suprisingly expensive due to locking underneath
@@ -225,6 +225,7 @@ func testSendStats() {
 	time.Sleep(sleepDuration)
 	IncrementOperationsBucketedBytesAndAppendedOverwritten(FileWrite, 65536, 600, 700)
 	IncrementOperationsAndBucketedBytes(FileReadplan, 131072)
+	IncrementOperationsBytesAndCompressedBytes(FileCompress, 8192, 2048)
 }
 
 func testVerifyStats() {
@@ -265,6 +266,9 @@ func testVerifyStats() {
 		FileReadplanOps + ":1|c",
 		FileReadplanOpsOver64K + ":1|c",
 		FileReadplanBytes + ":131072|c",
+		FileCompressOps + ":1|c",
+		FileCompressBytes + ":8192|c",
+		FileCompressCompressedBytes + ":2048|c",
 	}
 
 	// Check that the stats sent to the TCP/UDP port are what we expect
@@ -38,6 +38,8 @@ var (
 	FsMwGetContainerOps               = "proxyfs.fs.middleware_get_container.operations"
 	FsMwPutContainerOps               = "proxyfs.fs.middleware_put_container.operations"
 	FsMwGetObjOps                     = "proxyfs.fs.middleware_get_object.operations"
+	FsMwRenewLeaseOps                 = "proxyfs.fs.middleware_renew_lease.operations"
+	FsMwReleaseLeaseOps               = "proxyfs.fs.middleware_release_lease.operations"
 	FsReaddirOps                      = "proxyfs.fs.readdir.operations"
 	FsReaddirOneOps                   = "proxyfs.fs.one_readdir.operations"
 	FsReaddirPlusOps                  = "proxyfs.fs.plus_readdir.operations"
@@ -50,6 +52,7 @@ var (
 	FsUnlinkOps                       = "proxyfs.fs.unlink.operations"
 	FsRmdirOps                        = "proxyfs.fs.rmdir.operations"
 	FsWriteOps                        = "proxyfs.fs.write.operations"
+	FsCloneRangeOps                   = "proxyfs.fs.clone_range.operations"
 	FsValidateOps                     = "proxyfs.fs.validate.operations"
 	FsProvisionObjOps                 = "proxyfs.fs.provision_object.operations"
 	FsAcctToVolumeOps                 = "proxyfs.fs.acct_to_volume.operations"
@@ -59,6 +62,35 @@ var (
 	FsRemoveXattrOps                  = "proxyfs.fs.remove_xattr.operations"
 	FsSetXattrOps                     = "proxyfs.fs.set_xattr.operations"
 	FsFlockOps                        = "proxyfs.fs.flock.operations"
+	FsFlockWaitOps                    = "proxyfs.fs.flock.wait.operations"
+	FsFlockReleaseOps                 = "proxyfs.fs.flock.release.operations"
+	FsFlockReclaimOps                 = "proxyfs.fs.flock.reclaim.operations"
+	FsLeaseAcquireOps                 = "proxyfs.fs.lease.acquire.operations"
+	FsLeaseReleaseOps                 = "proxyfs.fs.lease.release.operations"
+	FsLeaseRecallOps                  = "proxyfs.fs.lease.recall.operations"
+	FsLeaseRevokeOps                  = "proxyfs.fs.lease.revoke.operations"
+	FsWatchAddOps                     = "proxyfs.fs.watch.add.operations"
+	FsWatchRemoveOps                  = "proxyfs.fs.watch.remove.operations"
+	FsWatchEventOps                   = "proxyfs.fs.watch.event.operations"
+	FsWatchOverflowOps                = "proxyfs.fs.watch.overflow.operations"
+	FsJournalRecordOps                = "proxyfs.fs.journal.record.operations"
+	FsJournalTrimOps                  = "proxyfs.fs.journal.trim.operations"
+	FsJournalFetchOps                 = "proxyfs.fs.journal.fetch.operations"
+	FsQuotaExceededOps                = "proxyfs.fs.quota.exceeded.operations"
+	FsQuotaReportOps                  = "proxyfs.fs.quota.report.operations"
+	FsQuotaTreeMoveOps                = "proxyfs.fs.quota.tree.move.operations"
+	FsTrashInsertOps                  = "proxyfs.fs.trash.insert.operations"
+	FsTrashExpireOps                  = "proxyfs.fs.trash.expire.operations"
+	FsTrashFetchOps                   = "proxyfs.fs.trash.fetch.operations"
+	FsTrashRestoreOps                 = "proxyfs.fs.trash.restore.operations"
+	FsFragmentationReportOps          = "proxyfs.fs.fragmentation_report.operations"
+	FsDefragJobStatusOps              = "proxyfs.fs.defrag_job.status.operations"
+	FsDefragJobPauseOps               = "proxyfs.fs.defrag_job.pause.operations"
+	FsDefragJobResumeOps              = "proxyfs.fs.defrag_job.resume.operations"
+	FsDefragPassOps                   = "proxyfs.fs.defrag.pass.operations"
+	FsSnapshotCreateOps               = "proxyfs.fs.snapshot.create.operations"
+	FsSnapshotDeleteOps               = "proxyfs.fs.snapshot.delete.operations"
+	FsDefragOptimizeOps               = "proxyfs.fs.defrag.optimize.operations"
 	DirCreateOps                      = "proxyfs.inode.directory.create.operations"
 	DirCreateSuccessOps               = "proxyfs.inode.directory.create.success.operations"
 	DirLinkOps                        = "proxyfs.inode.directory.link.operations"
@@ -76,6 +108,12 @@ var (
 	FileCreateSuccessOps              = "proxyfs.inode.file.create.success.operations"
 	FileWritebackHitOps               = "proxyfs.inode.file.writeback.hit.operations"
 	FileWritebackMissOps              = "proxyfs.inode.file.writeback.miss.operations"
+	FileDedupHitOps                   = "proxyfs.inode.file.dedup.hit.operations"
+	FileDedupMissOps                  = "proxyfs.inode.file.dedup.miss.operations"
+	FileCompressOps                   = "proxyfs.inode.file.compress.operations"
+	FileCompressBytes                 = "proxyfs.inode.file.compress.bytes"
+	FileCompressCompressedBytes       = "proxyfs.inode.file.compress.compressed.bytes"
+	FileCompressSkipOps               = "proxyfs.inode.file.compress.skip.operations"
 	FileReadcacheHitOps               = "proxyfs.inode.file.readcache.hit.operations"
 	FileReadcacheMissOps              = "proxyfs.inode.file.readcache.miss.operations"
 	FileReadOps                       = "proxyfs.inode.file.read.operations"
@@ -114,9 +152,18 @@ var (
 	FileWroteBytes                    = "proxyfs.inode.file.wrote.bytes"
 	DirSetsizeOps                     = "proxyfs.inode.directory.setsize.operations"
 	FileFlushOps                      = "proxyfs.inode.file.flush.operations"
+	FileOptimizeOps                   = "proxyfs.inode.file.optimize.operations"
+	FileCloneRangeOps                 = "proxyfs.inode.file.clone-range.operations"
 	LogSegCreateOps                   = "proxyfs.inode.file.log-segment.create.operations"
+	LogSegLeaseCreateOps              = "proxyfs.inode.file.log-segment.lease.create.operations"
+	LogSegLeaseRenewOps               = "proxyfs.inode.file.log-segment.lease.renew.operations"
+	LogSegLeaseReleaseOps             = "proxyfs.inode.file.log-segment.lease.release.operations"
+	LogSegLeaseExpireOps              = "proxyfs.inode.file.log-segment.lease.expire.operations"
+	LogSegLeaseDeferredDeleteOps      = "proxyfs.inode.file.log-segment.lease.deferred-delete.operations"
+	LogSegShareOps                    = "proxyfs.inode.file.log-segment.share.operations"
 	GcLogSegDeleteOps                 = "proxyfs.inode.garbage-collection.log-segment.delete.operations"
 	GcLogSegOps                       = "proxyfs.inode.garbage-collection.log-segment.operations"
+	GcLogSegSharedOps                 = "proxyfs.inode.garbage-collection.log-segment.shared.operations"
 	DirDestroyOps                     = "proxyfs.inode.directory.destroy.operations"
 	FileDestroyOps                    = "proxyfs.inode.file.destroy.operations"
 	SymlinkDestroyOps                 = "proxyfs.inode.symlink.destroy.operations"
@@ -140,6 +187,8 @@ var (
 	JrpcfsIoReadOps64K                = "proxyfs.jrpcfs.read.operations.size-32KB-to-64KB"
 	JrpcfsIoReadOpsOver64K            = "proxyfs.jrpcfs.read.operations.size-over-64KB"
 	JrpcfsIoReadBytes                 = "proxyfs.jrpcfs.read.bytes"
+	JrpcfsAuthFailedOps               = "proxyfs.jrpcfs.auth.failed.operations"
+	JrpcfsTLSHandshakeFailedOps       = "proxyfs.jrpcfs.tls.handshake.failed.operations"
 	SwiftAccountDeleteOps             = "proxyfs.swiftclient.account-delete"
 	SwiftAccountGetOps                = "proxyfs.swiftclient.account-get"
 	SwiftAccountHeadOps               = "proxyfs.swiftclient.account-head"
@@ -20,6 +20,7 @@ var (
 	physicalContinaerLayoutNameConfSectionPrefix = "PhysicalContainerLayout:"
 	flowControlNameConfSectionPrefix             = "FlowControl:"
 	peerNameConfSectionPrefix                    = "Peer:"
+	quotaNameConfSectionPrefix                   = "Quota:"
 )
 
 // TODO: Remove AdjustConfSectionNamespacingAsNecessary() when no longer needed
@@ -51,11 +52,13 @@ func AdjustConfSectionNamespacingAsNecessary(confMap conf.ConfMap) (err error) {
 		physicalContinaerLayoutNameConfSectionPrefix = ""
 		flowControlNameConfSectionPrefix = ""
 		peerNameConfSectionPrefix = ""
+		quotaNameConfSectionPrefix = ""
 	} else { // namespacedWhoAmISectionExists
 		volumeNameConfSectionPrefix = "Volume:"
 		physicalContinaerLayoutNameConfSectionPrefix = "PhysicalContainerLayout:"
 		flowControlNameConfSectionPrefix = "FlowControl:"
 		peerNameConfSectionPrefix = "Peer:"
+		quotaNameConfSectionPrefix = "Quota:"
 	}
 
 	err = nil
@@ -82,6 +85,11 @@ func PeerNameConfSection(peerName string) (sectionName string) {
 	return
 }
 
+func QuotaNameConfSection(quotaName string) (sectionName string) {
+	sectionName = quotaNameConfSectionPrefix + quotaName
+	return
+}
+
 // TryLockMutex is used to support a timeout a the lock request
 type TryLockMutex struct {
 	c chan struct{} // a  lock()    request             writes a struct{} to   c